@@ -0,0 +1,143 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/adityaraj/agentflow/internal/crypt"
+	"github.com/adityaraj/agentflow/internal/git"
+	"github.com/adityaraj/agentflow/internal/runtime"
+	"github.com/adityaraj/agentflow/internal/state"
+	"github.com/adityaraj/agentflow/internal/ui"
+)
+
+// newApplyCmd creates the `apply` command, which applies the changes a run
+// saved as patches, once someone has reviewed them.
+func newApplyCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "apply <run-id> [task...]",
+		Short: "Apply the changes a run saved as patches to the checkout",
+		Long: `Applies the patches a run saved to the repository containing the current
+directory: the changes of its 'write: patch' tasks, and of the whole run if it
+ran with settings.workspace.
+
+Without tasks, applies the run's workspace patch, if any, then the patches of
+the tasks that succeeded. Naming tasks applies only theirs, failed ones
+included. Each patch is applied whole or not at all.
+
+The patches are in the run directory, named after their tasks, for review
+first ('cortex decrypt' them if the run was encrypted).`,
+		Example: `  cortex apply 20250114-093012
+  cortex apply 20250114-093012 refactor docs`,
+		Args:         cobra.MinimumNArgs(1),
+		SilenceUsage: true, // A patch that doesn't apply isn't a usage error
+		RunE:         applyPatches,
+	}
+	cmd.Flags().BoolVar(&noColor, "no-color", false, "Disable colored output")
+	return cmd
+}
+
+func applyPatches(cmd *cobra.Command, args []string) error {
+	if noColor {
+		ui.SetColorsEnabled(false)
+	}
+
+	runID := strings.TrimPrefix(args[0], "run-")
+	session, err := state.FindSession(runID)
+	if err != nil {
+		return err
+	}
+	cwd, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+	if !git.IsRepo(cwd) {
+		return fmt.Errorf("%s isn't in a git repository to apply the changes to", cwd)
+	}
+	root, err := git.Root(cwd)
+	if err != nil {
+		return err
+	}
+
+	patches, err := runPatches(session.RunDir, args[1:])
+	if err != nil {
+		return err
+	}
+	if len(patches) == 0 {
+		ui.Info("Run %s saved no changes to apply", runID)
+		return nil
+	}
+
+	failed := 0
+	for _, name := range patches {
+		patch, err := crypt.ReadFile(filepath.Join(session.RunDir, name))
+		if err == nil {
+			err = git.ApplyPatch(root, string(patch))
+		}
+		if err != nil {
+			ui.Error("%s didn't apply: %s", name, err)
+			failed++
+			continue
+		}
+		ui.Success("Applied %s to %s", name, root)
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d patches didn't apply", failed, len(patches))
+	}
+	return nil
+}
+
+// runPatches returns the run files in runDir holding the patches to apply
+// for tasks, in order; with no tasks, the run's workspace patch and those
+// of its successful tasks.
+func runPatches(runDir string, tasks []string) ([]string, error) {
+	var patches []string
+	for _, task := range tasks {
+		result, err := state.ReadTaskResult(runDir, task)
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("the run has no task %q", task)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if result.Patch == "" {
+			return nil, fmt.Errorf("task %q saved no changes; only 'write: patch' tasks do", task)
+		}
+		patches = append(patches, result.Patch)
+	}
+	if len(tasks) > 0 {
+		return patches, nil
+	}
+
+	if _, err := os.Stat(filepath.Join(runDir, runtime.WorkspacePatchFile)); err == nil {
+		patches = append(patches, runtime.WorkspacePatchFile)
+	}
+	entries, err := os.ReadDir(runDir)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, entry := range entries {
+		task, ok := strings.CutSuffix(entry.Name(), ".json")
+		if !ok {
+			continue
+		}
+		result, err := state.ReadTaskResult(runDir, task)
+		if err != nil || result.TaskName != task || result.Patch == "" {
+			continue // run.json, manifest.json, or a task without a patch
+		}
+		if !result.Success {
+			ui.Warning("Skipping %s: task %q failed (name it to apply it anyway)", result.Patch, task)
+			continue
+		}
+		names = append(names, result.Patch)
+	}
+	sort.Strings(names)
+	return append(patches, names...), nil
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/adityaraj/agentflow/internal/crypt"
+)
+
+// newDecryptCmd creates the `decrypt` command, which prints run files and
+// logs written with settings.encrypt in plaintext.
+func newDecryptCmd() *cobra.Command {
+	var generateKey bool
+	cmd := &cobra.Command{
+		Use:   "decrypt <file>",
+		Short: "Print an encrypted run file or log in plaintext",
+		Long: `Prints a file written with settings.encrypt, decrypted with the key in
+` + crypt.KeyEnv + `: a run file such as run.json or manifest.json, the project
+memory, or a --log-file. Plaintext files and log lines are printed as they are.
+
+Cortex commands that read runs, such as sessions, report, and rerun, decrypt
+them on their own; this is for reading the files directly.
+
+--generate-key prints a new random key to set in ` + crypt.KeyEnv + `.`,
+		Example: `  cortex decrypt --generate-key
+  cortex decrypt ~/.cortex/sessions/api/run-20240104-200000/run.json
+  cortex decrypt cortex.log | grep ERROR`,
+		Args: func(cmd *cobra.Command, args []string) error {
+			if generateKey {
+				return cobra.NoArgs(cmd, args)
+			}
+			return cobra.ExactArgs(1)(cmd, args)
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if generateKey {
+				key, err := crypt.GenerateKey()
+				if err != nil {
+					return err
+				}
+				fmt.Println(key)
+				return nil
+			}
+
+			data, err := os.ReadFile(args[0])
+			if err != nil {
+				return err
+			}
+			key, err := crypt.EnvKey()
+			if err != nil {
+				return err
+			}
+			if crypt.IsEncryptedLog(data) {
+				return crypt.DecryptLines(os.Stdout, bytes.NewReader(data), key)
+			}
+			data, err = crypt.Decrypt(key, data)
+			if err != nil {
+				return err
+			}
+			_, err = os.Stdout.Write(data)
+			return err
+		},
+	}
+	cmd.Flags().BoolVar(&generateKey, "generate-key", false, "Print a new random key instead")
+	return cmd
+}
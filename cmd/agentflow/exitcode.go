@@ -0,0 +1,108 @@
+package main
+
+import (
+	"errors"
+
+	"github.com/spf13/cobra"
+
+	"github.com/adityaraj/agentflow/internal/config"
+	"github.com/adityaraj/agentflow/internal/state"
+)
+
+// Exit codes, so CI pipelines can branch on why Cortex failed. They are part
+// of the CLI's contract and don't change between releases.
+const (
+	ExitSuccess    = 0 // Everything ran and succeeded
+	ExitTaskFailed = 1 // One or more tasks failed
+	ExitConfig     = 2 // Invalid Cortexfile, flags, or arguments
+	ExitCancelled  = 3 // The run was interrupted
+	ExitBudget     = 4 // A token budget ran out
+	ExitInternal   = 5 // Anything else went wrong
+	ExitDeferred   = 6 // settings.schedule kept the run from starting
+)
+
+// exitError is an error that ends the process with a specific exit code.
+type exitError struct {
+	code int
+	err  error
+}
+
+func (e *exitError) Error() string { return e.err.Error() }
+func (e *exitError) Unwrap() error { return e.err }
+
+// withExit makes err end the process with code. A nil err stays nil.
+func withExit(code int, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &exitError{code: code, err: err}
+}
+
+// exitCode returns the process exit code for an error returned by a
+// command: the code it was given with withExit, ExitConfig for
+// configuration errors, or ExitInternal.
+func exitCode(err error) int {
+	if err == nil {
+		return ExitSuccess
+	}
+	var e *exitError
+	if errors.As(err, &e) {
+		return e.code
+	}
+	if config.ErrorCode(err) != "" {
+		return ExitConfig
+	}
+	return ExitInternal
+}
+
+// failedExitCode returns the exit code for a workflow that didn't succeed:
+// exitCode of its error, or ExitTaskFailed if it returned none.
+func failedExitCode(err error) int {
+	if err == nil {
+		return ExitTaskFailed
+	}
+	return exitCode(err)
+}
+
+// runExitCode classifies a run that failed: cancelled if it was
+// interrupted, budget if a token budget ran out, otherwise task failure.
+func runExitCode(result *state.RunResult, cancelled bool) int {
+	if cancelled {
+		return ExitCancelled
+	}
+	code := ExitTaskFailed
+	if result == nil {
+		return code
+	}
+	for _, t := range result.Tasks {
+		switch t.ErrorCategory {
+		case state.ErrorCancelled:
+			return ExitCancelled
+		case state.ErrorBudget:
+			code = ExitBudget
+		}
+	}
+	return code
+}
+
+// enableUsageExitCodes makes argument and flag errors of every command
+// under root exit with ExitConfig.
+func enableUsageExitCodes(root *cobra.Command) {
+	flagErrors := root.FlagErrorFunc()
+	root.SetFlagErrorFunc(func(cmd *cobra.Command, err error) error {
+		return withExit(ExitConfig, flagErrors(cmd, err))
+	})
+
+	var walk func(cmd *cobra.Command)
+	walk = func(cmd *cobra.Command) {
+		if args := cmd.Args; args != nil {
+			cmd.Args = func(cmd *cobra.Command, a []string) error {
+				return withExit(ExitConfig, args(cmd, a))
+			}
+		}
+		for _, sub := range cmd.Commands() {
+			walk(sub)
+		}
+	}
+	walk(root)
+}
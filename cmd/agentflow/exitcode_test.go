@@ -0,0 +1,73 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/adityaraj/agentflow/internal/config"
+	"github.com/adityaraj/agentflow/internal/state"
+)
+
+// TestExitCodes tests the exit code of each kind of failure, which CI
+// pipelines rely on.
+func TestExitCodes(t *testing.T) {
+	failed := func(categories ...state.ErrorCategory) *state.RunResult {
+		run := &state.RunResult{}
+		for _, c := range categories {
+			run.Tasks = append(run.Tasks, state.TaskResult{ErrorCategory: c})
+		}
+		return run
+	}
+	_, outsideWindow := checkSchedule(&config.ScheduleConfig{Window: "01:00-02:00"}, nil, "api",
+		time.Date(2026, 3, 10, 12, 0, 0, 0, time.Local))
+
+	tests := []struct {
+		name string
+		code int
+		want int
+	}{
+		{"success", exitCode(nil), ExitSuccess},
+		{"workflow failed without an error", failedExitCode(nil), ExitTaskFailed},
+		{"task failed", runExitCode(failed(state.ErrorFailed), false), ExitTaskFailed},
+		{"no result", runExitCode(nil, false), ExitTaskFailed},
+		{"config error", exitCode(config.NewCodedError(config.CodeNoTasks, "", 0, "no tasks", "")), ExitConfig},
+		{"wrapped config error", exitCode(fmt.Errorf("load: %w", config.NewCodedError(config.CodeNoTasks, "", 0, "no tasks", ""))), ExitConfig},
+		{"invalid flag", failedExitCode(withExit(ExitConfig, errors.New("invalid --sort"))), ExitConfig},
+		{"interrupted", runExitCode(failed(state.ErrorFailed), true), ExitCancelled},
+		{"task cancelled", runExitCode(failed(state.ErrorBudget, state.ErrorCancelled), false), ExitCancelled},
+		{"budget", runExitCode(failed(state.ErrorFailed, state.ErrorBudget), false), ExitBudget},
+		{"internal", exitCode(errors.New("disk on fire")), ExitInternal},
+		{"deferred by schedule", exitCode(outsideWindow), ExitDeferred},
+		{"wrapped exit code", exitCode(fmt.Errorf("config a.yml: %w", withExit(ExitBudget, errors.New("budget")))), ExitBudget},
+	}
+	for _, tt := range tests {
+		if tt.code != tt.want {
+			t.Errorf("%s: exit code %d, want %d", tt.name, tt.code, tt.want)
+		}
+	}
+	if withExit(ExitConfig, nil) != nil {
+		t.Errorf("withExit(nil) isn't nil")
+	}
+}
+
+// TestUsageExitCodes tests that flag and argument errors exit with
+// ExitConfig.
+func TestUsageExitCodes(t *testing.T) {
+	newRoot := func() *cobra.Command {
+		root := &cobra.Command{Use: "cortex", SilenceErrors: true, SilenceUsage: true}
+		root.AddCommand(&cobra.Command{Use: "run", Args: cobra.NoArgs, RunE: func(*cobra.Command, []string) error { return nil }})
+		enableUsageExitCodes(root)
+		return root
+	}
+	for _, args := range [][]string{{"run", "--nope"}, {"run", "extra"}} {
+		root := newRoot()
+		root.SetArgs(args)
+		if code := exitCode(root.Execute()); code != ExitConfig {
+			t.Errorf("cortex %v: exit code %d, want %d", args, code, ExitConfig)
+		}
+	}
+}
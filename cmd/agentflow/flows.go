@@ -0,0 +1,170 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+
+	"github.com/adityaraj/agentflow/internal/config"
+	"github.com/adityaraj/agentflow/internal/flows"
+	"github.com/adityaraj/agentflow/internal/runtime"
+	"github.com/adityaraj/agentflow/internal/ui"
+)
+
+// loadWorkflow loads a Cortexfile, selects the workflow and task group
+// named on the command line, and splices in the published workflows its
+// tasks use, pinning new references in cortex.lock (or, with --frozen,
+// failing if the lock doesn't already cover them). With --offline, they
+// must also be in the flow cache already. Deprecation warnings are
+// printed on stderr, so they don't mix with output meant for scripts.
+func loadWorkflow(path string) (*config.AgentflowConfig, error) {
+	cfg, err := config.LoadConfig(path)
+	if err != nil {
+		return nil, err
+	}
+	for _, d := range cfg.Deprecations {
+		fmt.Fprintln(os.Stderr, ui.YellowText("⚠ ")+d.Error())
+	}
+	all := flows.References(cfg)
+	if err := config.SelectWorkflow(cfg, workflowName); err != nil {
+		return nil, err
+	}
+	if groupName != "" {
+		if err := config.SelectGroup(cfg, groupName); err != nil {
+			return nil, err
+		}
+	}
+	if !usesFlows(cfg) {
+		return cfg, nil
+	}
+
+	lock, err := flows.LoadLock(filepath.Dir(path))
+	if err != nil {
+		return nil, err
+	}
+	lock.Frozen = frozenLock
+	lock.Offline = offline
+	lock.Keep = all // Pins of the workflows not selected
+	if err := flows.Expand(cfg, lock); err != nil {
+		return nil, err
+	}
+	if lock.Changed() {
+		if err := lock.Save(); err != nil {
+			return nil, err
+		}
+	}
+	return cfg, nil
+}
+
+// lockToolVersions records the CLI versions of the tools the workflow's
+// agents use in cortex.lock, warning when one changed since it was recorded.
+// With --frozen, a new or changed version is an error instead. It returns
+// the versions found.
+func lockToolVersions(path string, cfg *config.AgentflowConfig, registry *runtime.AgentRegistry) (map[string]string, error) {
+	lock, err := flows.LoadLock(filepath.Dir(path))
+	if err != nil {
+		return nil, err
+	}
+	lock.Frozen = frozenLock
+	versions := registry.ToolVersions(workflowTools(cfg))
+	notes, err := lock.CheckTools(versions)
+	if err != nil {
+		return nil, err
+	}
+	for _, note := range notes {
+		ui.Warning("%s since %s was written", note, flows.LockFile)
+	}
+	if lock.Changed() {
+		return versions, lock.Save()
+	}
+	return versions, nil
+}
+
+// workflowTools lists the tools used by the workflow's agents.
+func workflowTools(cfg *config.AgentflowConfig) []string {
+	seen := make(map[string]bool)
+	var tools []string
+	for _, agent := range cfg.Agents {
+		if !seen[agent.Tool] {
+			seen[agent.Tool] = true
+			tools = append(tools, agent.Tool)
+		}
+	}
+	return tools
+}
+
+// usesFlows reports whether any task uses a published workflow.
+func usesFlows(cfg *config.AgentflowConfig) bool {
+	return len(flows.References(cfg)) > 0
+}
+
+// newUpdateCmd creates the `update` command, which moves pinned flows to
+// their newest matching versions.
+func newUpdateCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "update [uses...]",
+		Short: "Update the published workflows pinned in cortex.lock",
+		Long: `Resolves each 'uses' reference again and records the result in cortex.lock.
+A version like @v2 moves to the newest v2.x.y tag; a branch moves to its latest
+commit. Pass references to update only those.`,
+		Example: `  cortex update
+  cortex update github.com/org/cortex-flows/review@v2`,
+		RunE: updateFlows,
+	}
+	cmd.Flags().StringArrayVarP(&configFiles, "file", "f", nil, "Path to Cortexfile")
+	return cmd
+}
+
+func updateFlows(cmd *cobra.Command, args []string) error {
+	paths, err := resolveConfigFiles()
+	if err != nil {
+		return err
+	}
+	if len(paths) == 0 {
+		return fmt.Errorf("no Cortexfile found")
+	}
+	path := paths[0]
+
+	cfg, err := config.LoadConfig(path)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	if !usesFlows(cfg) {
+		ui.Info("No tasks use published workflows")
+		return nil
+	}
+
+	lock, err := flows.LoadLock(filepath.Dir(path))
+	if err != nil {
+		return err
+	}
+	changes, err := flows.Update(cfg, lock, args)
+	if err != nil {
+		return err
+	}
+	if len(changes) == 0 {
+		ui.Success("All workflows are up to date")
+		return nil
+	}
+	if err := lock.Save(); err != nil {
+		return err
+	}
+	for _, c := range changes {
+		ui.Success("%s: %s → %s", c.Uses, describeVersion(c.From), describeVersion(c.To))
+	}
+	return nil
+}
+
+// describeVersion formats a pinned version as "v2.1.0 (abc1234)".
+func describeVersion(v flows.LockedFlow) string {
+	if v.Commit == "" {
+		return "unpinned"
+	}
+	commit := v.Commit[:min(7, len(v.Commit))]
+	if v.Version == "" {
+		return commit
+	}
+	return v.Version + " (" + commit + ")"
+}
@@ -3,10 +3,12 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"os/signal"
 	"path/filepath"
+	"slices"
 	"sort"
 	"strings"
 	"sync"
@@ -15,13 +17,20 @@ import (
 
 	"github.com/spf13/cobra"
 
+	"github.com/adityaraj/agentflow/internal/alert"
 	"github.com/adityaraj/agentflow/internal/config"
+	"github.com/adityaraj/agentflow/internal/crypt"
+	"github.com/adityaraj/agentflow/internal/email"
+	"github.com/adityaraj/agentflow/internal/flows"
 	"github.com/adityaraj/agentflow/internal/observability"
 	"github.com/adityaraj/agentflow/internal/planner"
+	"github.com/adityaraj/agentflow/internal/report"
 	"github.com/adityaraj/agentflow/internal/runtime"
 	"github.com/adityaraj/agentflow/internal/runtime/adapters/claude"
 	"github.com/adityaraj/agentflow/internal/runtime/adapters/opencode"
 	"github.com/adityaraj/agentflow/internal/runtime/adapters/shell"
+	"github.com/adityaraj/agentflow/internal/secrets"
+	"github.com/adityaraj/agentflow/internal/snapshot"
 	"github.com/adityaraj/agentflow/internal/state"
 	"github.com/adityaraj/agentflow/internal/ui"
 	"github.com/adityaraj/agentflow/internal/webhook"
@@ -48,6 +57,30 @@ var (
 	logFormat   string
 	logLevel    string
 	logFile     string
+	dirtyTree   string
+	snapshotRun bool
+	workspace   bool
+	maxTokens   int
+	ignoreSched bool
+	preamble    string
+	baseRef     string
+	frozenLock  bool
+	offline     bool
+
+	// setOverrides are the --set path=value overrides of the Cortexfile
+	setOverrides []string
+
+	// workflowName selects one of the Cortexfile's named workflows, and
+	// groupName one of its task groups
+	workflowName string
+	groupName    string
+
+	// Flags of cortex report
+	reportFormat string
+	reportOut    string
+
+	// logOutput is the opened --log-file, if any
+	logOutput *os.File
 )
 
 func main() {
@@ -61,13 +94,21 @@ func main() {
 		Short:   "AI agent orchestrator",
 		Long:    "Cortex orchestrates AI agent workflows defined in YAML.",
 		Version: versionStr,
+
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			if err := configureGlobalStore(cmd, args); err != nil {
+				return err
+			}
+			return configureGlobalNetwork(cmd, args)
+		},
 	}
 
 	// Run command
 	runCmd := &cobra.Command{
-		Use:   "run",
+		Use:   "run [workflow | url]",
 		Short: "Execute the Cortexfile workflow",
-		Long:  "Loads and executes tasks defined in Cortexfile.yml",
+		Long:  "Loads and executes tasks defined in Cortexfile.yml, one of its named workflows, or a remote Cortexfile (an https URL or git::host/owner/repo//path@version)",
+		Args:  cobra.MaximumNArgs(1),
 		RunE:  runWorkflow,
 	}
 
@@ -85,17 +126,34 @@ func main() {
 	runCmd.Flags().StringVar(&logFormat, "log-format", "text", "Log format: text or json")
 	runCmd.Flags().StringVar(&logLevel, "log-level", "info", "Log level: debug, info, warn, error")
 	runCmd.Flags().StringVar(&logFile, "log-file", "", "Log file path (default: stderr)")
+	runCmd.Flags().StringVar(&dirtyTree, "dirty-tree", "", "Policy for uncommitted changes before write tasks: refuse, stash, or proceed")
+	runCmd.Flags().BoolVar(&snapshotRun, "snapshot", false, "Snapshot the workdir before write tasks (restore with 'cortex rollback')")
+	runCmd.Flags().BoolVar(&workspace, "workspace", false, "Run in a copy of the repository under .cortex/workspaces, offering the changes as a patch")
+	runCmd.Flags().StringVar(&preamble, "preamble", "", "File prepended to every AI task's prompt (overrides the Cortexfile preamble)")
+	runCmd.Flags().StringVar(&baseRef, "base", "", "Git ref diff-scoped tasks compare against (default: origin's default branch)")
+	runCmd.Flags().BoolVar(&frozenLock, "frozen", false, "Fail instead of changing cortex.lock (for CI)")
+	runCmd.Flags().StringVarP(&groupName, "group", "g", "", "Run only the tasks of this group, and the tasks they need")
+	runCmd.Flags().BoolVar(&offline, "offline", false, "Run without network access; fail validation if the workflow needs it")
+	runCmd.Flags().IntVar(&maxTokens, "max-tokens", 0, "Token budget for the run; running tasks are stopped once it is exceeded (0 = no limit)")
+	runCmd.Flags().BoolVar(&ignoreSched, "ignore-schedule", false, "Run outside settings.schedule's window and past its daily quotas")
+	runCmd.Flags().BoolVarP(&quietOutput, "quiet", "q", false, "Print errors only")
+	runCmd.Flags().BoolVar(&summaryOutput, "summary", false, "Print only the final table of tasks, with status, duration, tokens, and cost")
+	runCmd.Flags().StringVarP(&outputFormat, "output", "o", "text", "Output format: text, or json for each run's result")
+	runCmd.Flags().StringArrayVar(&setOverrides, "set", nil, "Override a Cortexfile value, e.g. agents.coder.model=opus (repeatable)")
+	runCmd.MarkFlagsMutuallyExclusive("quiet", "summary")
 
 	// Validate command
 	validateCmd := &cobra.Command{
-		Use:   "validate",
+		Use:   "validate [workflow]",
 		Short: "Validate the Cortexfile without running",
-		Long:  "Checks the Cortexfile for errors without executing tasks",
+		Long:  "Checks the Cortexfile for errors without executing tasks. Without a workflow name, every named workflow is checked.",
+		Args:  cobra.MaximumNArgs(1),
 		RunE:  validateConfig,
 	}
 
 	var validateFile string
 	validateCmd.Flags().StringVarP(&validateFile, "file", "f", "", "Path to Cortexfile (default: auto-detect)")
+	validateCmd.Flags().BoolVar(&offline, "offline", false, "Also check that the workflow can run without network access")
 
 	// Sessions command
 	sessionsCmd := &cobra.Command{
@@ -112,6 +170,7 @@ func main() {
 	sessionsCmd.Flags().StringVar(&sessionProject, "project", "", "Filter by project name")
 	sessionsCmd.Flags().IntVar(&sessionLimit, "limit", 10, "Maximum number of sessions to show")
 	sessionsCmd.Flags().BoolVar(&sessionFailed, "failed", false, "Show only failed sessions")
+	sessionsCmd.AddCommand(newSessionsGCCmd())
 
 	// Init command - create template files
 	initCmd := &cobra.Command{
@@ -133,15 +192,17 @@ func main() {
 
 	// Dry-run command - show what would execute without running
 	dryRunCmd := &cobra.Command{
-		Use:   "dry-run",
+		Use:   "dry-run [workflow]",
 		Short: "Show what would execute without running",
 		Long:  "Displays the execution plan with expanded prompts without actually running tasks",
+		Args:  cobra.MaximumNArgs(1),
 		RunE:  dryRunWorkflow,
 	}
 
 	var dryRunJSON bool
 	dryRunCmd.Flags().StringArrayVarP(&configFiles, "file", "f", nil, "Path to Cortexfile(s)")
 	dryRunCmd.Flags().BoolVar(&dryRunJSON, "json", false, "Output in JSON format")
+	dryRunCmd.Flags().StringVarP(&groupName, "group", "g", "", "Show only the tasks of this group, and the tasks they need")
 	dryRunCmd.Flags().BoolVar(&noColor, "no-color", false, "Disable colored output")
 
 	// Master command - run MasterCortex.yml
@@ -164,17 +225,20 @@ func main() {
 
 	// Graph command - visualize DAG
 	graphCmd := &cobra.Command{
-		Use:   "graph",
+		Use:   "graph [workflow]",
 		Short: "Visualize the task execution graph",
-		Long:  "Displays the task dependency graph as ASCII art or Graphviz DOT format",
+		Long:  "Displays the task dependency graph as ASCII art, Graphviz DOT, or a Mermaid flowchart. DOT and Mermaid exports carry a legend and where they came from.",
+		Args:  cobra.MaximumNArgs(1),
 		RunE:  showGraph,
 	}
 
 	var graphFormat string
 	var graphCompact bool
 	graphCmd.Flags().StringArrayVarP(&configFiles, "file", "f", nil, "Path to Cortexfile(s)")
-	graphCmd.Flags().StringVar(&graphFormat, "format", "ascii", "Output format: ascii or dot")
+	graphCmd.Flags().StringVar(&graphFormat, "format", "ascii", "Output format: ascii, dot, or mermaid")
+	graphCmd.Flags().StringVarP(&groupName, "group", "g", "", "Show only the tasks of this group, and the tasks they need")
 	graphCmd.Flags().BoolVar(&graphCompact, "compact", false, "Show compact single-line representation")
+	graphCmd.Flags().String("run", "", "Label DOT and Mermaid output with the run it documents")
 	graphCmd.Flags().BoolVar(&noColor, "no-color", false, "Disable colored output")
 
 	rootCmd.AddCommand(runCmd)
@@ -185,17 +249,108 @@ func main() {
 	rootCmd.AddCommand(masterCmd)
 	rootCmd.AddCommand(graphCmd)
 
+	// Rollback command - restore a pre-run snapshot
+	rollbackCmd := &cobra.Command{
+		Use:   "rollback <run-id>",
+		Short: "Restore the workdir to its state before a run",
+		Long:  "Restores the snapshot taken before a run (requires settings.snapshot or --snapshot)",
+		Args:  cobra.ExactArgs(1),
+		RunE:  rollbackRun,
+	}
+
+	var rollbackYes bool
+	rollbackCmd.Flags().BoolVarP(&rollbackYes, "yes", "y", false, "Skip the confirmation prompt")
+	rollbackCmd.Flags().BoolVar(&noColor, "no-color", false, "Disable colored output")
+
+	rootCmd.AddCommand(rollbackCmd)
+
+	// Report command - render a run as HTML
+	reportCmd := &cobra.Command{
+		Use:   "report <run-id>",
+		Short: "Write the HTML or SARIF report for a run",
+		Long: `Renders a run's results, including agent tool-call transcripts, to report.html in its run directory.
+
+With --format sarif, writes the findings tasks reported as JSON to results.sarif
+instead, for GitHub code scanning and other SARIF consumers.`,
+		Args: cobra.ExactArgs(1),
+		RunE: reportRun,
+	}
+
+	reportCmd.Flags().StringVar(&reportFormat, "format", "html", "Report format: html or sarif")
+	reportCmd.Flags().StringVar(&reportOut, "out", "", "File to write the SARIF log to, or - for stdout (default: in the run directory)")
+	reportCmd.Flags().BoolVar(&noColor, "no-color", false, "Disable colored output")
+
+	rootCmd.AddCommand(reportCmd)
+	rootCmd.AddCommand(newPlanCmd())
+	rootCmd.AddCommand(newTemplateCmd())
+	rootCmd.AddCommand(newUpdateCmd())
+	rootCmd.AddCommand(newRerunCmd())
+	rootCmd.AddCommand(newProvenanceCmd())
+	rootCmd.AddCommand(newSchemaCmd())
+	rootCmd.AddCommand(newServeCmd())
+	rootCmd.AddCommand(newDecryptCmd())
+	rootCmd.AddCommand(newApplyCmd())
+	rootCmd.AddCommand(newMigrateCmd())
+	rootCmd.AddCommand(newStatsCmd())
+
+	enableSuggestions(rootCmd)
+	enableUsageExitCodes(rootCmd)
+
 	if err := rootCmd.Execute(); err != nil {
-		os.Exit(1)
+		os.Exit(exitCode(err))
 	}
 }
 
 func runWorkflow(cmd *cobra.Command, args []string) error {
+	if err := applyEnvFlags(cmd); err != nil {
+		return err
+	}
+	if len(args) > 0 {
+		if flows.IsRemote(args[0]) {
+			configFiles = append(configFiles, args[0])
+		} else {
+			workflowName = args[0]
+		}
+	}
+
 	// Handle color settings
 	if noColor {
 		ui.SetColorsEnabled(false)
 	}
 
+	switch outputFormat {
+	case "text", "json":
+	default:
+		return withExit(ExitConfig, fmt.Errorf("invalid --output %q: use text or json", outputFormat))
+	}
+	jsonOutput := outputFormat == "json"
+	if jsonOutput && summaryOutput {
+		return withExit(ExitConfig, fmt.Errorf("--summary can't be used with --output json"))
+	}
+
+	// Keep stdout for the final output, if any, in --quiet, --summary, and
+	// --output json
+	if quietOutput || summaryOutput || jsonOutput {
+		cmd.SilenceUsage = true // Failures are reported, not usage mistakes
+		restore, err := silenceStdout()
+		if err != nil {
+			return withExit(ExitInternal, err)
+		}
+		defer func() {
+			restore()
+			switch {
+			case jsonOutput:
+				if err := writeRunsJSON(os.Stdout, finishedRuns); err != nil {
+					ui.Error("Failed to write JSON output: %s", err)
+				}
+			case summaryOutput:
+				printSummaryTable(os.Stdout, finishedRuns)
+			default:
+				printFailures(finishedRuns)
+			}
+		}()
+	}
+
 	// Set up structured logging if enabled
 	if cmd.Flags().Changed("log-format") || cmd.Flags().Changed("log-level") || cmd.Flags().Changed("log-file") {
 		setupLogger(cmd)
@@ -212,18 +367,19 @@ func runWorkflow(cmd *cobra.Command, args []string) error {
 	configPaths, err := resolveConfigFiles()
 	if err != nil {
 		ui.Error("Failed to resolve config files: %s", err)
-		return err
+		return withExit(ExitConfig, err)
 	}
 
 	if len(configPaths) == 0 {
 		ui.Error("No Cortexfile found")
-		return fmt.Errorf("no Cortexfile found")
+		return withExit(ExitConfig, fmt.Errorf("no Cortexfile found"))
 	}
 
 	// Run each config file
 	var allSuccess = true
 	var totalTasks int
 	var successfulRuns int
+	exit := ExitSuccess // Exit code of the first config that failed
 
 	for i, configPath := range configPaths {
 		if len(configPaths) > 1 {
@@ -242,6 +398,9 @@ func runWorkflow(cmd *cobra.Command, args []string) error {
 		} else {
 			allSuccess = false
 		}
+		if !success && exit == ExitSuccess {
+			exit = failedExitCode(err)
+		}
 		totalTasks += tasks
 	}
 
@@ -258,11 +417,43 @@ func runWorkflow(cmd *cobra.Command, args []string) error {
 	}
 
 	if !allSuccess {
-		return fmt.Errorf("workflow completed with failures")
+		return withExit(exit, fmt.Errorf("workflow completed with failures"))
 	}
 	return nil
 }
 
+// newDiskGuard watches the free space of the disks a run writes to: session
+// storage, the working directories, and the temp directory.
+func newDiskGuard(minMB int, cwd string, plan *planner.ExecutionPlan) *runtime.DiskGuard {
+	paths := []string{cwd, os.TempDir()}
+	if home, err := ui.GetCortexHome(); err == nil {
+		paths = append(paths, home)
+	}
+	for _, t := range plan.Tasks {
+		paths = append(paths, t.Workdir)
+	}
+	return runtime.NewDiskGuard(minMB, paths...)
+}
+
+// newAgentRegistry registers the adapters for the supported tools.
+func newAgentRegistry(stream bool) *runtime.AgentRegistry {
+	registry := runtime.NewAgentRegistry()
+
+	claudeAdapter := claude.New()
+	claudeAdapter.SetStreamLogs(stream)
+	registry.Register("claude-code", claudeAdapter)
+
+	opencodeAdapter := opencode.New()
+	opencodeAdapter.SetStreamLogs(stream)
+	registry.Register("opencode", opencodeAdapter)
+
+	shellAdapter := shell.New()
+	shellAdapter.SetStreamLogs(stream)
+	registry.Register("shell", shellAdapter)
+
+	return registry
+}
+
 func runSingleConfig(cmd *cobra.Command, configPath string) (bool, int, error) {
 	// Load global config
 	globalCfg, err := config.LoadGlobalConfig()
@@ -281,15 +472,29 @@ func runSingleConfig(cmd *cobra.Command, configPath string) (bool, int, error) {
 	}
 
 	ui.PrintSetupStart()
+	if workflowName != "" {
+		displayPath += " (workflow " + workflowName + ")"
+	}
+	if groupName != "" {
+		displayPath += " (group " + groupName + ")"
+	}
 	ui.PrintSetupStep("Loading " + displayPath)
-	localCfg, err := config.LoadConfig(configPath)
+	localCfg, err := loadWorkflow(configPath)
 	if err != nil {
-		return false, 0, fmt.Errorf("failed to load config: %w", err)
+		return false, 0, withExit(ExitConfig, fmt.Errorf("failed to load config: %w", err))
+	}
+	if err := config.ApplySet(localCfg, setOverrides); err != nil {
+		return false, 0, withExit(ExitConfig, err)
 	}
 
 	ui.PrintSetupStep("Validating configuration")
 	if err := config.ValidateWithFile(localCfg, configPath); err != nil {
-		return false, 0, err
+		return false, 0, withExit(ExitConfig, err)
+	}
+	if offline {
+		if err := config.ValidateOffline(localCfg, configPath); err != nil {
+			return false, 0, withExit(ExitConfig, err)
+		}
 	}
 
 	// Build CLI settings override
@@ -302,9 +507,56 @@ func runSingleConfig(cmd *cobra.Command, configPath string) (bool, int, error) {
 	}
 	// Stream is on by default, --no-stream disables it
 	cliSettings.Stream = streamLogs && !noStream
+	if cmd.Flags().Changed("dirty-tree") {
+		if !config.IsValidDirtyTreePolicy(dirtyTree) {
+			return false, 0, withExit(ExitConfig, fmt.Errorf("invalid --dirty-tree %q: use refuse, stash, or proceed", dirtyTree))
+		}
+		cliSettings.DirtyTree = dirtyTree
+	}
+	cliSettings.Snapshot = snapshotRun
+	cliSettings.Workspace = workspace
+	if maxTokens < 0 {
+		return false, 0, withExit(ExitConfig, fmt.Errorf("invalid --max-tokens %d: cannot be negative", maxTokens))
+	}
+	cliSettings.MaxTokens = maxTokens
+	cliSettings.Base = baseRef
 
-	// Merge configs: CLI > local > global
-	merged := config.MergeConfigs(globalCfg, localCfg, cliSettings)
+	// Merge configs: CLI > CORTEX_* environment > local > global
+	if s := localCfg.Settings; s != nil && s.CABundle != "" && !filepath.IsAbs(s.CABundle) {
+		s.CABundle = filepath.Join(filepath.Dir(configPath), s.CABundle)
+	}
+	merged, err := config.MergeConfigsWithEnv(globalCfg, localCfg, cliSettings, os.LookupEnv)
+	if err != nil {
+		return false, 0, withExit(ExitConfig, err)
+	}
+	for _, e := range merged.Emails {
+		if err := email.Validate(e); err != nil {
+			return false, 0, withExit(ExitConfig, err)
+		}
+	}
+	for _, a := range merged.Alerts {
+		if err := alert.Validate(a); err != nil {
+			return false, 0, withExit(ExitConfig, err)
+		}
+	}
+	if err := merged.Settings.Retention.Validate(); err != nil {
+		return false, 0, withExit(ExitConfig, err)
+	}
+	stateKey, err := loadStateKey(merged.Settings.Encrypt)
+	if err != nil {
+		return false, 0, withExit(ExitConfig, err)
+	}
+	runStore, err := openStateStore(merged.Settings.Store, stateKey)
+	if err != nil {
+		return false, 0, withExit(ExitConfig, err)
+	}
+
+	// Apply the Cortexfile's proxy and CA settings on top of the global ones
+	if s := localCfg.Settings; s != nil && (s.Proxy != "" || s.NoProxy != "" || s.CABundle != "") {
+		if err := configureNetwork(merged.Settings); err != nil {
+			return false, 0, err
+		}
+	}
 
 	// Handle parallel execution flags
 	// Default is parallel ON (from global config)
@@ -323,6 +575,24 @@ func runSingleConfig(cmd *cobra.Command, configPath string) (bool, int, error) {
 		ui.Error("Failed to build plan: %s", err)
 		return false, 0, err
 	}
+	plan.Workflow = workflowName
+	if preamble != "" {
+		content, err := config.ReadPromptFile(preamble, "--preamble")
+		if err != nil {
+			return false, 0, fmt.Errorf("failed to read --preamble %q: %w", preamble, err)
+		}
+		if len(config.ExtractTemplateVars(string(content))) > 0 {
+			return false, 0, fmt.Errorf("--preamble %s: cannot reference task outputs", preamble)
+		}
+		plan.Preamble = string(content)
+	}
+
+	// Resolve secrets up front, so a missing one fails before any task runs
+	secretValues, err := secrets.Resolve(context.Background(), localCfg.Secrets)
+	if err != nil {
+		ui.Error("Failed to resolve secrets: %s", err)
+		return false, 0, err
+	}
 
 	// Show execution mode
 	var levelCount, effectiveMax int
@@ -357,15 +627,119 @@ func runSingleConfig(cmd *cobra.Command, configPath string) (bool, int, error) {
 		return false, 0, err
 	}
 
-	store, err := state.NewStore(cwd)
+	// Don't start a run that would fill the disk partway through
+	disk := newDiskGuard(merged.Settings.MinDiskMB, cwd, plan)
+	if err := disk.Check(); err != nil {
+		ui.Error("%s", err)
+		return false, 0, fmt.Errorf("%w; free up space or adjust settings.min_disk_mb", err)
+	}
+
+	// Keep to the schedule's window and daily quotas
+	runMaxTokens := merged.Settings.MaxTokens
+	if !ignoreSched {
+		left, err := checkSchedule(merged.Settings.Schedule, runStore, filepath.Base(cwd), time.Now())
+		if err != nil {
+			ui.Error("%s", err)
+			return false, 0, err
+		}
+		if left > 0 && (runMaxTokens == 0 || left < runMaxTokens) {
+			ui.Info("Daily token quota: %s left", ui.FormatTokenCount(left))
+			runMaxTokens = left
+		}
+	}
+
+	store, err := state.NewRunStore(cwd)
 	if err != nil {
 		ui.Error("Failed to create state store: %s", err)
 		return false, 0, err
 	}
+	store.SetRetention(merged.Settings.Retention)
+	store.SetSecrets(secrets.NewRedactor(secretValues))
+	store.SetKey(stateKey)
+	store.SetBackend(runStore)
 
 	// Print session info
 	ui.PrintSessionInfo(store.RunID(), store.RunDir())
 
+	// Run in a copy of the repository, leaving the checkout untouched
+	var ws *runtime.Workspace
+	if merged.Settings.Workspace {
+		ws, err = runtime.PrepareWorkspace(plan, store.RunID())
+		if err != nil {
+			ui.Error("%s", err)
+			return false, 0, err
+		}
+		defer func() {
+			if err := ws.Remove(); err != nil {
+				ui.Warning("Failed to remove workspace %s: %s", ws.Dir, err)
+			}
+		}()
+		ui.Info("Running in workspace %s", ws.Dir)
+	}
+
+	// Capture the pre-run state, including any uncommitted work; a
+	// workspace needs neither this nor the dirty-tree policy
+	if merged.Settings.Snapshot && ws == nil {
+		snap, err := runtime.SnapshotWorkdir(plan, store.RunDir(), store.RunID())
+		if err != nil {
+			ui.Error("Failed to snapshot workdir: %s", err)
+			return false, 0, err
+		}
+		if snap != nil {
+			ui.Info("Snapshot saved (%s); undo with: cortex rollback %s", snap.Method, store.RunID())
+		}
+	}
+
+	// Summarize the repo once if prompts reference {{context.repo}}
+	if err := runtime.PrepareContext(plan, store); err != nil {
+		ui.Error("%s", err)
+		return false, 0, err
+	}
+	if pack, ok := plan.Context[config.ContextRepo]; ok {
+		ui.Info("Repo context generated (%s)", ui.FormatBytes(int64(len(pack))))
+	}
+
+	// Resolve {{retrieve}} placeholders against the embedding index
+	if err := runtime.PrepareRetrieval(context.Background(), plan, localCfg.Retrieval, filepath.Dir(store.RunDir())); err != nil {
+		ui.Error("%s", err)
+		return false, 0, err
+	}
+	if n := len(plan.Retrieved); n > 0 {
+		ui.Info("Retrieved snippets for %d queries", n)
+	}
+
+	// Find what changed since the base ref for diff-scoped tasks
+	if err := runtime.PrepareDiff(plan, merged.Settings.Base); err != nil {
+		ui.Error("%s", err)
+		return false, 0, err
+	}
+	if plan.Diff != nil {
+		ui.Info("Diff scope: %d files changed since %s", len(plan.Diff.Files), plan.Diff.Base)
+	}
+
+	// Load outputs of the last successful run for {{runs.last_success.outputs.X}}
+	if err := runtime.PreparePriorOutputs(plan, store); err != nil {
+		ui.Error("%s", err)
+		return false, 0, err
+	}
+	if plan.PriorOutputs != nil {
+		if plan.PriorRunID != "" {
+			ui.Info("Using outputs of run %s", plan.PriorRunID)
+		} else {
+			ui.Warning("No previous successful run; prior outputs will be empty")
+		}
+	}
+
+	// Keep agent changes separate from uncommitted work
+	if ws == nil {
+		restoreTree, err := runtime.PrepareWorkingTree(plan, merged.Settings.DirtyTree, store.RunID())
+		if err != nil {
+			ui.Error("%s", err)
+			return false, 0, err
+		}
+		defer restoreTree()
+	}
+
 	// Get project name
 	projectName := filepath.Base(cwd)
 
@@ -381,7 +755,12 @@ func runSingleConfig(cmd *cobra.Command, configPath string) (bool, int, error) {
 	)
 
 	// Set up webhook manager
-	webhookMgr := webhook.NewManager(merged.Webhooks)
+	webhooks := merged.Webhooks
+	if offline && len(webhooks) > 0 {
+		ui.Warning("Webhooks disabled (--offline)")
+		webhooks = nil
+	}
+	webhookMgr := webhook.NewManager(webhooks)
 	if webhookMgr.HasWebhooks() {
 		ui.Info("Webhooks configured: %d", webhookMgr.Count())
 	}
@@ -390,19 +769,20 @@ func runSingleConfig(cmd *cobra.Command, configPath string) (bool, int, error) {
 	webhookMgr.Send(webhook.NewRunStartEvent(store.RunID(), projectName))
 
 	// Set up agent registry
-	registry := runtime.NewAgentRegistry()
+	registry := newAgentRegistry(merged.Settings.Stream)
 
-	claudeAdapter := claude.New()
-	claudeAdapter.SetStreamLogs(merged.Settings.Stream)
-	registry.Register("claude-code", claudeAdapter)
-
-	opencodeAdapter := opencode.New()
-	opencodeAdapter.SetStreamLogs(merged.Settings.Stream)
-	registry.Register("opencode", opencodeAdapter)
+	toolVersions, err := lockToolVersions(configPath, localCfg, registry)
+	if err != nil {
+		ui.Error("%s", err)
+		return false, 0, err
+	}
 
-	shellAdapter := shell.New()
-	shellAdapter.SetStreamLogs(merged.Settings.Stream)
-	registry.Register("shell", shellAdapter)
+	// Record the resolved inputs so the run can be reproduced with `cortex rerun`
+	runSettings := merged.Settings
+	runSettings.Parallel = useParallel
+	if err := writeManifest(store, configPath, localCfg, plan, runSettings, toolVersions); err != nil {
+		ui.Warning("Failed to write run manifest: %s", err)
+	}
 
 	// Create executor with config
 	executor := runtime.NewExecutorWithConfig(runtime.ExecutorConfig{
@@ -412,6 +792,27 @@ func runSingleConfig(cmd *cobra.Command, configPath string) (bool, int, error) {
 		Verbose:     merged.Settings.Verbose,
 		Parallel:    useParallel,
 		MaxParallel: merged.Settings.MaxParallel,
+		MaxTokens:   runMaxTokens,
+		Shell:       merged.Settings.Shell,
+		MaxCPU:      merged.Settings.MaxCPU,
+		MaxMemory:   merged.Settings.MaxMemory,
+		Disk:        disk,
+		Heartbeat:   time.Duration(merged.Settings.Heartbeat) * time.Second,
+		Secrets:     secretValues,
+		OnProgress: func(ev runtime.ProgressEvent) {
+			webhookMgr.Send(webhook.NewTaskProgressEvent(store.RunID(), projectName, webhook.TaskEvent{
+				Name:     ev.Task,
+				Agent:    ev.Agent,
+				Tool:     ev.Tool,
+				Model:    ev.Model,
+				Elapsed:  ev.Elapsed.Round(time.Second).String(),
+				Bytes:    ev.Bytes,
+				Tokens:   ev.Tokens,
+				Activity: ev.Activity,
+				Edits:    ev.Edits,
+				LastLine: ev.LastLine,
+			}))
+		},
 	})
 
 	// Set up context with cancellation on interrupt
@@ -433,10 +834,37 @@ func runSingleConfig(cmd *cobra.Command, configPath string) (bool, int, error) {
 	startTime := time.Now()
 	result, err := executor.Execute(ctx, plan)
 	duration := time.Since(startTime)
+	recordRun(store, result)
+	if ws != nil && result != nil {
+		offerWorkspacePatch(ws, store, result.Success)
+	}
 
 	// Wait for pending webhooks
 	defer webhookMgr.Wait()
 
+	// The HTML report would hold the run in plaintext; `cortex report`
+	// renders it on demand
+	var reportPath string
+	if !store.Encrypted() {
+		var reportErr error
+		reportPath, reportErr = report.WriteFile(store.RunDir(), store.Retained(result), projectName)
+		if reportErr != nil {
+			ui.Warning("Failed to write report: %s", reportErr)
+		}
+	}
+
+	if err := writeProvenance(store, result, configPath, toolVersions); err != nil {
+		ui.Warning("Failed to write provenance: %s", err)
+	}
+
+	if runMaxTokens > 0 {
+		used := ui.FormatTokenCount(executor.TokensUsed())
+		if result != nil && slices.ContainsFunc(result.Tasks, func(t state.TaskResult) bool { return t.TokenUsage.Estimated }) {
+			used = "~" + used + " (partly estimated)"
+		}
+		ui.Info("Token budget: %s of %s used", used, ui.FormatTokenCount(runMaxTokens))
+	}
+
 	// Send run_complete event
 	webhookMgr.Send(webhook.NewRunCompleteEvent(
 		store.RunID(),
@@ -445,6 +873,10 @@ func runSingleConfig(cmd *cobra.Command, configPath string) (bool, int, error) {
 		duration,
 		result.Success,
 	))
+	sendEmails(merged.Emails, email.NewRun(projectName, store.RunDir(), reportPath, store.Retained(result)))
+	if ctx.Err() == nil { // Interrupted runs don't count toward alerts
+		sendAlerts(merged.Alerts, filepath.Dir(store.RunDir()), projectName, result)
+	}
 
 	if err != nil {
 		observability.Error("Workflow execution failed",
@@ -458,7 +890,7 @@ func runSingleConfig(cmd *cobra.Command, configPath string) (bool, int, error) {
 			}),
 		)
 		ui.PrintSummary(false, store.RunDir())
-		return false, len(result.Tasks), err
+		return false, len(result.Tasks), withExit(runExitCode(result, ctx.Err() != nil), err)
 	}
 
 	// Log run complete
@@ -479,9 +911,250 @@ func runSingleConfig(cmd *cobra.Command, configPath string) (bool, int, error) {
 	return result.Success, len(result.Tasks), nil
 }
 
+// sendEmails sends the emails configured for runs like run, warning about
+// any that fail.
+func sendEmails(emails []config.EmailConfig, run email.Run) {
+	if offline && len(emails) > 0 {
+		ui.Warning("Emails disabled (--offline)")
+		return
+	}
+	for _, e := range emails {
+		if !e.MatchesRun(run.Success) {
+			continue
+		}
+		if err := email.Send(e, run, os.LookupEnv); err != nil {
+			ui.Warning("Failed to email %s: %s", strings.Join(e.To, ", "), err)
+			continue
+		}
+		ui.Info("Emailed %s", strings.Join(e.To, ", "))
+	}
+}
+
+// sendAlerts counts result in its schedule's failure streak, stored in
+// sessionsDir, and opens or resolves the incidents of alerts accordingly,
+// warning about any that fail.
+func sendAlerts(alerts []config.AlertConfig, sessionsDir, project string, result *state.RunResult) {
+	if len(alerts) == 0 {
+		return
+	}
+	if offline {
+		ui.Warning("Alerts disabled (--offline)")
+		return
+	}
+	schedule := alert.Schedule(workflowName, groupName)
+	before, failures, err := alert.RecordRun(sessionsDir, schedule, result.RunID, result.Success)
+	if err != nil {
+		ui.Warning("Failed to record run for alerts: %s", err)
+		return
+	}
+
+	source, _ := os.Hostname()
+	key := alert.DedupKey(project, schedule)
+	for _, a := range alerts {
+		after := alert.After(a)
+		switch {
+		case !result.Success && failures >= after:
+			inc := alert.Incident{
+				DedupKey: key,
+				Summary:  fmt.Sprintf("Cortex: %s %s failed %d runs in a row", project, schedule, failures),
+				Source:   source,
+				Details: map[string]string{
+					"run_id":       result.RunID,
+					"failed_tasks": strings.Join(failedTaskNames(result), ", "),
+				},
+			}
+			if err := alert.Trigger(a, inc, os.LookupEnv); err != nil {
+				ui.Warning("Failed to alert %s: %s", a.Provider, err)
+				continue
+			}
+			ui.Info("Alerted %s: %d failed runs in a row", a.Provider, failures)
+		case result.Success && before >= after:
+			if err := alert.Resolve(a, key, source, os.LookupEnv); err != nil {
+				ui.Warning("Failed to resolve %s alert: %s", a.Provider, err)
+				continue
+			}
+			ui.Info("Resolved %s alert", a.Provider)
+		}
+	}
+}
+
+// failedTaskNames lists the tasks that failed in result.
+func failedTaskNames(result *state.RunResult) []string {
+	var names []string
+	for _, t := range result.Tasks {
+		if !t.Success && t.Skipped == "" {
+			names = append(names, t.TaskName)
+		}
+	}
+	return names
+}
+
+func rollbackRun(cmd *cobra.Command, args []string) error {
+	if noColor {
+		ui.SetColorsEnabled(false)
+	}
+
+	runID := strings.TrimPrefix(args[0], "run-")
+	session, err := state.FindSession(runID)
+	if err != nil {
+		ui.Error("%s", err)
+		return err
+	}
+
+	snap, err := snapshot.Load(session.RunDir)
+	if err != nil {
+		ui.Error("Cannot roll back run %s: %s", runID, err)
+		return err
+	}
+
+	yes, _ := cmd.Flags().GetBool("yes")
+	if !yes {
+		fmt.Printf("Restore %s to its state at %s (%s snapshot)?\n",
+			snap.Source, snap.CreatedAt.Format("2006-01-02 15:04:05"), snap.Method)
+		fmt.Printf("%sAll changes made since then will be lost.%s [y/N] ", ui.Yellow, ui.Reset)
+
+		var answer string
+		fmt.Scanln(&answer)
+		if !strings.EqualFold(strings.TrimSpace(answer), "y") {
+			fmt.Println("Aborted.")
+			return nil
+		}
+	}
+
+	if err := snap.Restore(); err != nil {
+		ui.Error("Rollback failed: %s", err)
+		return err
+	}
+
+	ui.Success("Restored %s to its state before run %s", snap.Source, runID)
+	return nil
+}
+
+func reportRun(cmd *cobra.Command, args []string) error {
+	if noColor {
+		ui.SetColorsEnabled(false)
+	}
+	if reportFormat != "html" && reportFormat != "sarif" {
+		return withExit(ExitConfig, fmt.Errorf("invalid --format %q: must be html or sarif", reportFormat))
+	}
+
+	runID := strings.TrimPrefix(args[0], "run-")
+	session, err := state.FindSession(runID)
+	if err != nil {
+		ui.Error("%s", err)
+		return err
+	}
+
+	run, err := state.GetSession(session.Project, runID)
+	if err != nil {
+		ui.Error("Cannot load run %s: %s", runID, err)
+		return err
+	}
+
+	if reportFormat == "sarif" {
+		return writeSARIFReport(session.RunDir, run)
+	}
+
+	path, err := report.WriteFile(session.RunDir, run, session.Project)
+	if err != nil {
+		ui.Error("%s", err)
+		return err
+	}
+
+	ui.Success("Report written to %s", path)
+	return nil
+}
+
+// writeSARIFReport writes the findings of run as SARIF to --out, or to
+// results.sarif in its run directory.
+func writeSARIFReport(runDir string, run *state.RunResult) error {
+	if reportOut == "-" {
+		_, err := report.RenderSARIF(os.Stdout, run, version)
+		return err
+	}
+	path := reportOut
+	if path == "" {
+		path = filepath.Join(runDir, report.SARIFFileName)
+	}
+	n, err := report.WriteSARIF(path, run, version)
+	if err != nil {
+		ui.Error("%s", err)
+		return err
+	}
+	if n == 0 {
+		ui.Warning("No task of run %s reported findings", run.RunID)
+	}
+	ui.Success("SARIF log with %d finding(s) written to %s", n, path)
+	return nil
+}
+
 func validateConfig(cmd *cobra.Command, args []string) error {
+	if err := applyEnvFlags(cmd); err != nil {
+		return err
+	}
 	ui.PrintCompactBanner(version)
 
+	if len(args) > 0 {
+		workflowName = args[0]
+		return validateWorkflow()
+	}
+
+	// Without a name, check every workflow the Cortexfile defines
+	names := definedWorkflows()
+	if len(names) == 0 {
+		return validateWorkflow()
+	}
+	for _, name := range names {
+		workflowName = name
+		if name == "" {
+			ui.Info("Top-level tasks")
+		} else {
+			ui.Info("Workflow %s", ui.BoldText(name))
+		}
+		if err := validateWorkflow(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// definedWorkflows returns the names of the Cortexfile's named workflows,
+// with "" first for its top-level tasks if it has any. It returns nil if
+// the Cortexfile has no named workflows or can't be read.
+func definedWorkflows() []string {
+	paths, err := resolveConfigFiles()
+	if err != nil || len(paths) == 0 {
+		return nil
+	}
+	cfg, err := config.LoadConfig(paths[0])
+	if err != nil || len(cfg.Workflows) == 0 {
+		return nil
+	}
+	var names []string
+	if len(cfg.Tasks) > 0 {
+		names = append(names, "")
+	}
+	return append(names, config.WorkflowNames(cfg)...)
+}
+
+// validateWorkflow validates the selected workflow and shows its plan.
+// lintSettings returns the settings validation warnings use: the global
+// config, then the Cortexfile's, then the environment.
+func lintSettings(cfg *config.AgentflowConfig) config.SettingsConfig {
+	global, err := config.LoadGlobalConfig()
+	if err != nil {
+		global = &config.GlobalConfig{Settings: config.DefaultSettings()}
+	}
+	// Only the settings are merged, leaving the agents as they are
+	local := &config.AgentflowConfig{Settings: cfg.Settings}
+	merged, err := config.MergeConfigsWithEnv(global, local, nil, os.LookupEnv)
+	if err != nil {
+		merged = config.MergeConfigs(global, local, nil) // Run reports the bad variable
+	}
+	return merged.Settings
+}
+
+func validateWorkflow() error {
 	cfg, configPath, err := loadConfig()
 	if err != nil {
 		ui.Error("Validation failed: %s", err)
@@ -493,6 +1166,12 @@ func validateConfig(cmd *cobra.Command, args []string) error {
 		ui.Error("Validation failed:\n%s", err)
 		return err
 	}
+	if offline {
+		if err := config.ValidateOffline(cfg, configPath); err != nil {
+			ui.Error("Offline validation failed:\n%s", err)
+			return err
+		}
+	}
 
 	// Build plan to verify DAG is valid
 	plan, err := planner.BuildPlan(cfg)
@@ -502,6 +1181,9 @@ func validateConfig(cmd *cobra.Command, args []string) error {
 	}
 
 	ui.Success("Configuration is valid!")
+	for _, w := range config.Warnings(cfg, lintSettings(cfg), configPath) {
+		ui.Warning("%s", w)
+	}
 	fmt.Printf("  %sAgents:%s %d\n", ui.Dim, ui.Reset, len(cfg.Agents))
 	fmt.Printf("  %sTasks:%s  %d\n", ui.Dim, ui.Reset, len(cfg.Tasks))
 	fmt.Println()
@@ -546,10 +1228,45 @@ type DryRunOutput struct {
 	TotalTasks  int          `json:"total_tasks"`
 	TotalLevels int          `json:"total_levels"`
 	Tasks       []DryRunTask `json:"tasks"`
+
+	Errors []DryRunError `json:"errors,omitempty"` // Why the workflow can't run, if it can't
+}
+
+// DryRunError represents a configuration error in dry-run output
+type DryRunError struct {
+	Code    string `json:"code,omitempty"` // Stable error code, e.g. CORTEX-VAL-008
+	Message string `json:"message"`
+	Hint    string `json:"hint,omitempty"`
+
+	Suggestion string `json:"suggestion,omitempty"` // Closest defined name to a misspelled one
+}
+
+// writeDryRunErrors prints err as dry-run JSON output, one entry per
+// configuration error.
+func writeDryRunErrors(configPath string, err error) {
+	output := DryRunOutput{ConfigFile: configPath, Tasks: []DryRunTask{}}
+	var many *config.ConfigErrors
+	var one *config.ConfigError
+	switch {
+	case errors.As(err, &many):
+		for _, e := range many.Errors {
+			output.Errors = append(output.Errors, DryRunError{Code: e.Code, Message: e.Message, Hint: e.Hint, Suggestion: e.Suggestion})
+		}
+	case errors.As(err, &one):
+		output.Errors = append(output.Errors, DryRunError{Code: one.Code, Message: one.Message, Hint: one.Hint, Suggestion: one.Suggestion})
+	default:
+		output.Errors = append(output.Errors, DryRunError{Message: err.Error()})
+	}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	enc.Encode(output)
 }
 
 func dryRunWorkflow(cmd *cobra.Command, args []string) error {
 	jsonOutput, _ := cmd.Flags().GetBool("json")
+	if len(args) > 0 {
+		workflowName = args[0]
+	}
 
 	// Handle color settings
 	if noColor || jsonOutput {
@@ -575,9 +1292,11 @@ func dryRunWorkflow(cmd *cobra.Command, args []string) error {
 	configPath := configPaths[0]
 
 	// Load config
-	localCfg, err := config.LoadConfig(configPath)
+	localCfg, err := loadWorkflow(configPath)
 	if err != nil {
-		if !jsonOutput {
+		if jsonOutput {
+			writeDryRunErrors(configPath, err)
+		} else {
 			ui.Error("Failed to load config: %s", err)
 		}
 		return err
@@ -585,7 +1304,9 @@ func dryRunWorkflow(cmd *cobra.Command, args []string) error {
 
 	// Validate
 	if err := config.ValidateWithFile(localCfg, configPath); err != nil {
-		if !jsonOutput {
+		if jsonOutput {
+			writeDryRunErrors(configPath, err)
+		} else {
 			ui.Error("Validation failed: %s", err)
 		}
 		return err
@@ -670,6 +1391,15 @@ func dryRunWorkflow(cmd *cobra.Command, args []string) error {
 					if len(t.Dependencies) > 0 {
 						fmt.Printf("    %sNeeds:%s %s\n", ui.Dim, ui.Reset, strings.Join(t.Dependencies, ", "))
 					}
+					if t.When != "" {
+						fmt.Printf("    %sWhen:%s  %s\n", ui.Dim, ui.Reset, t.When)
+					}
+					if t.Timeout > 0 {
+						fmt.Printf("    %sTimeout:%s %s\n", ui.Dim, ui.Reset, t.Timeout)
+					}
+					if t.Retries > 0 {
+						fmt.Printf("    %sRetries:%s %d %s(backoff %s)%s\n", ui.Dim, ui.Reset, t.Retries, ui.Dim, t.RetryBackoff, ui.Reset)
+					}
 
 					if t.Workdir != "" {
 						fmt.Printf("    %sWorkdir:%s %s\n", ui.Dim, ui.Reset, t.Workdir)
@@ -705,6 +1435,9 @@ func dryRunWorkflow(cmd *cobra.Command, args []string) error {
 func showGraph(cmd *cobra.Command, args []string) error {
 	format, _ := cmd.Flags().GetString("format")
 	compactGraph, _ := cmd.Flags().GetBool("compact")
+	if len(args) > 0 {
+		workflowName = args[0]
+	}
 
 	// Handle color settings
 	if noColor || format == "dot" {
@@ -726,7 +1459,7 @@ func showGraph(cmd *cobra.Command, args []string) error {
 	configPath := configPaths[0]
 
 	// Load config
-	localCfg, err := config.LoadConfig(configPath)
+	localCfg, err := loadWorkflow(configPath)
 	if err != nil {
 		ui.Error("Failed to load config: %s", err)
 		return err
@@ -750,10 +1483,28 @@ func showGraph(cmd *cobra.Command, args []string) error {
 		fmt.Println(planner.RenderCompact(plan.DAG))
 	} else {
 		graphFormat := planner.FormatASCII
-		if format == "dot" {
+		switch format {
+		case "dot":
 			graphFormat = planner.FormatDOT
+		case "mermaid":
+			graphFormat = planner.FormatMermaid
 		}
-		fmt.Print(planner.RenderGraph(plan.DAG, plan.Tasks, graphFormat))
+		runID, _ := cmd.Flags().GetString("run")
+		// Relative, so shared diagrams don't show local paths
+		shownPath := configPath
+		if cwd, err := os.Getwd(); err == nil {
+			if rel, err := filepath.Rel(cwd, configPath); err == nil && !strings.HasPrefix(rel, "..") {
+				shownPath = filepath.ToSlash(rel)
+			}
+		}
+		meta := planner.GraphMeta{
+			ConfigFile: shownPath,
+			Workflow:   workflowName,
+			RunID:      runID,
+			Generated:  time.Now(),
+			Version:    version,
+		}
+		fmt.Print(planner.RenderGraph(plan.DAG, plan.Tasks, graphFormat, meta))
 	}
 
 	return nil
@@ -872,6 +1623,11 @@ func showProjectSessions(project string, limit int, failedOnly bool) error {
 			ui.Dim, ui.Reset, s.TaskCount,
 			durationStr, tokenInfo,
 		)
+
+		if s.FailedTask != "" && s.ErrorCategory != "" {
+			fmt.Printf("      %sFailed:%s %s %s(%s)%s %s%s%s\n",
+				ui.Dim, ui.Reset, s.FailedTask, ui.Red, s.ErrorCategory, ui.Reset, ui.Dim, s.ErrorCategory.Code(), ui.Reset)
+		}
 	}
 
 	fmt.Println()
@@ -893,7 +1649,7 @@ func loadConfig() (*config.AgentflowConfig, string, error) {
 
 	ui.Info("Loading %s", path)
 
-	cfg, err := config.LoadConfig(path)
+	cfg, err := loadWorkflow(path)
 	if err != nil {
 		return nil, path, fmt.Errorf("failed to load config: %w", err)
 	}
@@ -909,16 +1665,19 @@ func loadConfig() (*config.AgentflowConfig, string, error) {
 // resolveConfigFiles expands glob patterns and returns all matching config files
 func resolveConfigFiles() ([]string, error) {
 	if len(configFiles) == 0 {
-		// Auto-detect in current directory
+		// Auto-detect in the current directory or a parent
 		cwd, err := os.Getwd()
 		if err != nil {
 			return nil, fmt.Errorf("failed to get working directory: %w", err)
 		}
 
-		path, err := config.FindCortexfile(cwd)
+		path, err := config.FindCortexfileUp(cwd)
 		if err != nil {
 			return nil, err
 		}
+		if err := enterProjectRoot(filepath.Dir(path), cwd); err != nil {
+			return nil, err
+		}
 		return []string{path}, nil
 	}
 
@@ -926,6 +1685,20 @@ func resolveConfigFiles() ([]string, error) {
 	seen := make(map[string]bool)
 
 	for _, pattern := range configFiles {
+		if flows.IsRemote(pattern) {
+			path, sum, err := flows.FetchConfig(pattern, offline)
+			if err != nil {
+				return nil, err
+			}
+			// On stderr, like the project root notice
+			fmt.Fprintln(os.Stderr, ui.OrangeText("ℹ ")+"Using "+pattern+" ("+sum+")")
+			if !seen[path] {
+				seen[path] = true
+				result = append(result, path)
+			}
+			continue
+		}
+
 		// Check if it's a glob pattern
 		if containsGlobChars(pattern) {
 			matches, err := filepath.Glob(pattern)
@@ -954,6 +1727,21 @@ func resolveConfigFiles() ([]string, error) {
 	return result, nil
 }
 
+// enterProjectRoot changes to dir, where an auto-detected Cortexfile was
+// found, if it is a parent of cwd. Runs started from a subdirectory then
+// use the project root as their default workdir and for session storage.
+func enterProjectRoot(dir, cwd string) error {
+	if dir == cwd {
+		return nil
+	}
+	if err := os.Chdir(dir); err != nil {
+		return fmt.Errorf("failed to change to project root: %w", err)
+	}
+	// On stderr, so exports like 'graph --format dot > graph.dot' stay clean
+	fmt.Fprintln(os.Stderr, ui.OrangeText("ℹ ")+"Project root: "+dir)
+	return nil
+}
+
 // containsGlobChars checks if a string contains glob pattern characters
 func containsGlobChars(s string) bool {
 	for _, c := range s {
@@ -1060,11 +1848,14 @@ func runMasterWorkflow(cmd *cobra.Command, args []string) error {
 			ui.Error("Failed to get working directory: %s", err)
 			return err
 		}
-		masterPath, err = config.FindMasterCortex(cwd)
+		masterPath, err = config.FindMasterCortexUp(cwd)
 		if err != nil {
 			ui.Error("No MasterCortex.yml found. Create one with: cortex init --master")
 			return err
 		}
+		if err := enterProjectRoot(filepath.Dir(masterPath), cwd); err != nil {
+			return err
+		}
 	}
 
 	ui.Info("Loading %s", masterPath)
@@ -1146,9 +1937,12 @@ func runMasterWorkflow(cmd *cobra.Command, args []string) error {
 
 	successCount := 0
 	totalTasks := 0
+	exit := ExitSuccess // Exit code of the first workflow that failed
 	for _, r := range results {
 		if r.Success {
 			successCount++
+		} else if exit == ExitSuccess {
+			exit = failedExitCode(r.Error)
 		}
 		totalTasks += r.Tasks
 	}
@@ -1161,7 +1955,7 @@ func runMasterWorkflow(cmd *cobra.Command, args []string) error {
 	fmt.Printf("  %sTotal tasks: %d, Duration: %s%s\n\n", ui.Dim, totalTasks, duration.Round(time.Second), ui.Reset)
 
 	if successCount < len(results) {
-		return fmt.Errorf("master workflow completed with failures")
+		return withExit(exit, fmt.Errorf("master workflow completed with failures"))
 	}
 	return nil
 }
@@ -1189,7 +1983,7 @@ func executeWorkflowsSequential(cmd *cobra.Command, workflows []config.WorkflowE
 
 		if !canRun {
 			ui.Warning("Skipping %s: dependencies not met", w.Name)
-			results = append(results, workflowResult{Name: w.Name, Success: false, Error: fmt.Errorf("dependencies not met")})
+			results = append(results, workflowResult{Name: w.Name, Success: false, Error: withExit(ExitTaskFailed, fmt.Errorf("dependencies not met"))})
 			continue
 		}
 
@@ -1286,7 +2080,7 @@ func executeWorkflowsParallel(cmd *cobra.Command, workflows []config.WorkflowEnt
 		}
 
 		if !canRun {
-			results[i] = workflowResult{Name: w.Name, Success: false, Error: fmt.Errorf("dependencies not met")}
+			results[i] = workflowResult{Name: w.Name, Success: false, Error: withExit(ExitTaskFailed, fmt.Errorf("dependencies not met"))}
 			continue
 		}
 
@@ -1318,6 +2112,51 @@ func maxOrDefault(val, def int) int {
 	return def
 }
 
+// envFlags are the flags without a matching setting that CORTEX_* variables
+// can also set (e.g. CORTEX_LOG_LEVEL for --log-level). Settings are read
+// from the environment when configs are merged.
+var envFlags = []string{"log-level", "log-format", "log-file", "no-color", "no-stream", "frozen", "offline", "group"}
+
+// applyEnvFlags sets the envFlags of cmd that weren't given on the command
+// line from their environment variables, so flags still take precedence.
+func applyEnvFlags(cmd *cobra.Command) error {
+	for _, name := range envFlags {
+		flag := cmd.Flags().Lookup(name)
+		if flag == nil || flag.Changed {
+			continue
+		}
+		key := config.EnvName(name)
+		value := strings.TrimSpace(os.Getenv(key))
+		if value == "" {
+			continue
+		}
+		if err := cmd.Flags().Set(name, value); err != nil {
+			return fmt.Errorf("invalid %s %q: %w", key, value, err)
+		}
+	}
+	return nil
+}
+
+// loadStateKey returns the key run files are encrypted with when encrypt
+// is on, and encrypts the rest of the --log-file with it. Returns nil when
+// encryption is off.
+func loadStateKey(encrypt bool) ([]byte, error) {
+	if !encrypt {
+		return nil, nil
+	}
+	key, err := crypt.EnvKey()
+	if err != nil {
+		return nil, err
+	}
+	if key == nil {
+		return nil, fmt.Errorf("settings.encrypt is on but %s is not set; generate a key with 'cortex decrypt --generate-key'", crypt.KeyEnv)
+	}
+	if logOutput != nil {
+		observability.GetGlobalLogger().SetOutput(crypt.NewLineWriter(logOutput, key))
+	}
+	return key, nil
+}
+
 // setupLogger configures the global logger based on CLI flags
 func setupLogger(cmd *cobra.Command) {
 	format := observability.FormatText
@@ -1334,6 +2173,7 @@ func setupLogger(cmd *cobra.Command) {
 			ui.Warning("Failed to open log file %s: %s", logFile, err)
 		} else {
 			output = f
+			logOutput = f
 		}
 	}
 
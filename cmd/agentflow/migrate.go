@@ -0,0 +1,104 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/spf13/cobra"
+
+	"github.com/adityaraj/agentflow/internal/config"
+	"github.com/adityaraj/agentflow/internal/flows"
+	"github.com/adityaraj/agentflow/internal/ui"
+)
+
+// newMigrateCmd creates the `migrate` command, which lists the deprecated
+// fields and names a Cortexfile uses and, with --fix-deprecations,
+// rewrites them.
+func newMigrateCmd() *cobra.Command {
+	var fix bool
+	cmd := &cobra.Command{
+		Use:   "migrate",
+		Short: "List or fix the deprecated fields a Cortexfile uses",
+		Long: `Lists the deprecated fields and names the Cortexfile and the files it includes
+use, the warnings run and validate print as they load it.
+
+With --fix-deprecations, each deprecated field with a replacement is renamed in
+place, keeping the files' formatting and comments, and a Cortexfile still named
+Agentfile.yml is renamed to Cortexfile.yml. Fields that can't simply be renamed
+are listed for you to change.`,
+		Example: `  cortex migrate
+  cortex migrate --fix-deprecations
+  cortex migrate --fix-deprecations -f ci/Cortexfile.yml`,
+		Args:         cobra.NoArgs,
+		SilenceUsage: true, // Config errors aren't usage errors
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if slices.ContainsFunc(configFiles, flows.IsRemote) {
+				return fmt.Errorf("remote Cortexfiles can't be migrated here; fix them where they're published")
+			}
+			paths, err := resolveConfigFiles()
+			if err != nil {
+				return err
+			}
+			if len(paths) == 0 {
+				return fmt.Errorf("no Cortexfile found")
+			}
+			path := paths[0]
+
+			// Files that fail to load for other reasons still get fixed
+			files := []string{path}
+			var deprecations []*config.ConfigError
+			if cfg, err := config.LoadConfig(path); err == nil {
+				deprecations = cfg.Deprecations
+				for _, d := range deprecations {
+					if d.File != "" && !slices.Contains(files, d.File) {
+						files = append(files, d.File)
+					}
+				}
+			} else if !fix {
+				return err
+			}
+
+			if !fix {
+				if len(deprecations) == 0 {
+					ui.Success("No deprecated fields in %s", path)
+					return nil
+				}
+				for _, d := range deprecations {
+					ui.Warning("%s", d)
+				}
+				ui.Info("Run 'cortex migrate --fix-deprecations' to update the files")
+				return nil
+			}
+
+			changed := 0
+			for _, file := range files {
+				fixed, unfixed, err := config.FixDeprecations(file)
+				if err != nil {
+					return err
+				}
+				for _, f := range fixed {
+					ui.Success("%s", f)
+				}
+				for _, u := range unfixed {
+					ui.Warning("%s", u)
+				}
+				changed += len(fixed)
+			}
+			renamed, err := config.FixLegacyName(path)
+			if err != nil {
+				return err
+			}
+			if renamed != path {
+				ui.Success("Renamed %s to %s", path, renamed)
+				changed++
+			}
+			if changed == 0 {
+				ui.Success("Nothing to fix in %s", path)
+			}
+			return nil
+		},
+	}
+	cmd.Flags().BoolVar(&fix, "fix-deprecations", false, "Rewrite deprecated fields to their replacements")
+	cmd.Flags().StringArrayVarP(&configFiles, "file", "f", nil, "Path to Cortexfile")
+	return cmd
+}
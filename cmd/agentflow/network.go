@@ -0,0 +1,44 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+
+	"github.com/adityaraj/agentflow/internal/config"
+	"github.com/adityaraj/agentflow/internal/network"
+	"github.com/adityaraj/agentflow/internal/ui"
+)
+
+// configureNetwork applies the proxy and CA bundle settings to Cortex's own
+// requests and to the tools it runs.
+func configureNetwork(settings config.SettingsConfig) error {
+	opts := network.Options{
+		Proxy:    settings.Proxy,
+		NoProxy:  settings.NoProxy,
+		CABundle: settings.CABundle,
+	}
+	if home, err := ui.GetCortexHome(); err == nil {
+		opts.Dir = filepath.Join(home, "network")
+	}
+	return network.Configure(opts)
+}
+
+// configureGlobalNetwork applies the network settings of the global config
+// and CORTEX_* variables before any command runs, so every command (not
+// just runs) can reach the network through a proxy.
+func configureGlobalNetwork(cmd *cobra.Command, args []string) error {
+	global, err := config.LoadGlobalConfig()
+	if err != nil {
+		return nil // Commands that use the global config report this themselves
+	}
+	settings := global.Settings
+	if err := config.ApplyEnv(&settings, os.LookupEnv); err != nil {
+		return nil // Reported by the commands that merge settings
+	}
+	if err := configureNetwork(settings); err != nil {
+		ui.Warning("Ignoring network settings: %s", err)
+	}
+	return nil
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"text/tabwriter"
+
+	"github.com/adityaraj/agentflow/internal/state"
+	"github.com/adityaraj/agentflow/internal/ui"
+)
+
+// Output modes of cortex run, for CI logs: --quiet prints errors only, and
+// --summary only the final table of tasks. --output json prints each run's
+// result as JSON instead, for automation.
+var (
+	quietOutput   bool
+	summaryOutput bool
+	outputFormat  string
+)
+
+// finishedRun is a run executed by this process.
+type finishedRun struct {
+	*state.RunResult
+	Dir   string          // Run directory
+	store *state.RunStore // Writes files to Dir, encrypted if the run's files are
+}
+
+var (
+	finishedMu   sync.Mutex
+	finishedRuns []finishedRun // In the order they finished
+)
+
+// recordRun keeps the result of a finished run for the final output, as
+// store keeps it: with its secrets masked and its retention settings
+// applied, since the output often ends up in CI logs.
+func recordRun(store *state.RunStore, result *state.RunResult) {
+	if result == nil {
+		return
+	}
+	finishedMu.Lock()
+	defer finishedMu.Unlock()
+	finishedRuns = append(finishedRuns, finishedRun{RunResult: store.Retained(result), Dir: store.RunDir(), store: store})
+}
+
+// silenceStdout discards everything printed to stdout, including agent
+// output, until restore is called. Errors go to stderr meanwhile.
+func silenceStdout() (restore func(), err error) {
+	devNull, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to silence output: %w", err)
+	}
+	stdout := os.Stdout
+	os.Stdout = devNull
+	ui.SetQuiet(true)
+	return func() {
+		os.Stdout = stdout
+		devNull.Close()
+	}, nil
+}
+
+// printFailures reports the tasks that failed in runs, one line each.
+func printFailures(runs []finishedRun) {
+	for _, run := range runs {
+		for _, t := range run.Tasks {
+			if t.Success || t.Skipped != "" {
+				continue
+			}
+			reason := string(t.ErrorCategory)
+			if t.ErrorCategory == state.ErrorFailed || reason == "" {
+				reason = fmt.Sprintf("exit code %d", t.ExitCode)
+			}
+			if code := t.ErrorCategory.Code(); code != "" {
+				reason += " [" + code + "]"
+			}
+			ui.Error("Task %s failed: %s (run %s)", t.TaskName, reason, run.RunID)
+		}
+	}
+}
+
+// printSummaryTable writes one row per task of runs, with a total per run.
+func printSummaryTable(w io.Writer, runs []finishedRun) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for i, run := range runs {
+		if i > 0 {
+			fmt.Fprintln(tw)
+		}
+		if len(runs) > 1 {
+			fmt.Fprintf(tw, "Run %s\n", run.RunID)
+		}
+		fmt.Fprintln(tw, "TASK\tSTATUS\tDURATION\tTOKENS\tCOST")
+		for _, t := range run.Tasks {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n",
+				t.TaskName, taskStatus(t), t.Duration, formatTokens(t.TokenUsage), formatCost(t.TokenUsage.CostUSD))
+		}
+		run.CalculateTotalTokens()
+		status := "ok"
+		if !run.Success {
+			status = "failed"
+		}
+		fmt.Fprintf(tw, "TOTAL\t%s\t%s\t%s\t%s\n",
+			status, state.FormatDuration(run.EndTime.Sub(run.StartTime)),
+			formatTokens(run.TokenUsage), formatCost(run.TokenUsage.CostUSD))
+	}
+	tw.Flush()
+	if slices.ContainsFunc(runs, func(r finishedRun) bool { return r.TokenUsage.Estimated }) {
+		fmt.Fprintln(w, "~ estimated: the tool didn't report usage, so it was counted from the text")
+	}
+}
+
+// taskStatus is a task's STATUS in the summary table.
+func taskStatus(t state.TaskResult) string {
+	switch {
+	case t.Skipped != "":
+		return "skipped"
+	case t.Success:
+		return "ok"
+	case t.ErrorCategory != "" && t.ErrorCategory != state.ErrorFailed:
+		return "failed (" + string(t.ErrorCategory) + ")"
+	}
+	return "failed"
+}
+
+// formatTokens formats a token count, marking estimated ones with "~".
+func formatTokens(u state.TokenUsage) string {
+	switch {
+	case u.TotalTokens == 0:
+		return "-"
+	case u.Estimated:
+		return "~" + ui.FormatTokenCount(u.TotalTokens)
+	}
+	return ui.FormatTokenCount(u.TotalTokens)
+}
+
+func formatCost(usd float64) string {
+	if usd == 0 {
+		return "-"
+	}
+	return fmt.Sprintf("$%.4f", usd)
+}
+
+// maxInlineOutput is the size above which --output json refers to a task's
+// stdout, stderr, or prompt by file instead of including it.
+const maxInlineOutput = 4 << 10
+
+// jsonRun is a RunResult as printed by --output json.
+type jsonRun struct {
+	*state.RunResult
+	RunDir string     `json:"run_dir"`
+	Tasks  []jsonTask `json:"tasks"`
+}
+
+// jsonTask is a TaskResult as printed by --output json. Large outputs are
+// written to files in the run directory and referenced by path, and the
+// transcript is left in ResultFile.
+type jsonTask struct {
+	state.TaskResult
+	ResultFile string `json:"result_file"`           // The full task result
+	StdoutFile string `json:"stdout_file,omitempty"` // Set when stdout is too large to include
+	StderrFile string `json:"stderr_file,omitempty"` // Set when stderr is too large to include
+	PromptFile string `json:"prompt_file,omitempty"` // Set when the prompt is too large to include
+}
+
+// writeRunsJSON prints each run as a line of JSON.
+func writeRunsJSON(w io.Writer, runs []finishedRun) error {
+	enc := json.NewEncoder(w)
+	for _, run := range runs {
+		run.CalculateTotalTokens()
+		out := jsonRun{RunResult: run.RunResult, RunDir: run.Dir, Tasks: make([]jsonTask, 0, len(run.Tasks))}
+		for _, t := range run.Tasks {
+			task := jsonTask{TaskResult: t, ResultFile: filepath.Join(run.Dir, t.TaskName+".json")}
+			task.Transcript = nil
+			var err error
+			if task.Stdout, task.StdoutFile, err = spillOutput(run.store, t.TaskName, "stdout", t.Stdout); err != nil {
+				return err
+			}
+			if task.Stderr, task.StderrFile, err = spillOutput(run.store, t.TaskName, "stderr", t.Stderr); err != nil {
+				return err
+			}
+			if task.Prompt, task.PromptFile, err = spillOutput(run.store, t.TaskName, "prompt", t.Prompt); err != nil {
+				return err
+			}
+			out.Tasks = append(out.Tasks, task)
+		}
+		if err := enc.Encode(out); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// spillOutput returns text unchanged if it's small enough to include, and
+// otherwise writes it to <task>.<kind>.txt in the store's run directory,
+// encrypted like the run's other files, and returns the path.
+func spillOutput(store *state.RunStore, task, kind, text string) (inline, path string, err error) {
+	if len(text) <= maxInlineOutput {
+		return text, "", nil
+	}
+	name := task + "." + kind + ".txt"
+	if err := store.WriteRunFile(name, []byte(text)); err != nil {
+		return "", "", fmt.Errorf("failed to write %s of task %s: %w", kind, task, err)
+	}
+	return "", filepath.Join(store.RunDir(), name), nil
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/adityaraj/agentflow/internal/config"
+	"github.com/adityaraj/agentflow/internal/secrets"
+	"github.com/adityaraj/agentflow/internal/state"
+)
+
+// recordedRuns records result as cortex run does, with store, and returns
+// the runs recorded.
+func recordedRuns(t *testing.T, store *state.RunStore, result *state.RunResult) []finishedRun {
+	t.Helper()
+	finishedMu.Lock()
+	finishedRuns = nil
+	finishedMu.Unlock()
+	t.Cleanup(func() { finishedRuns = nil })
+	recordRun(store, result)
+	return finishedRuns
+}
+
+// TestRunsJSON_MasksSecrets tests that secrets never appear in the
+// --output json stream, nor in the files it spills large outputs to.
+func TestRunsJSON_MasksSecrets(t *testing.T) {
+	const token = "s3cr3t-token-value"
+	store, err := state.NewRunStoreWithPath(t.TempDir(), "api")
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.SetSecrets(secrets.NewRedactor(map[string]string{"TOKEN": token}))
+
+	result := &state.RunResult{RunID: store.RunID(), Tasks: []state.TaskResult{{
+		TaskName: "deploy",
+		Prompt:   "Deploy with " + token,
+		Stdout:   strings.Repeat("x", maxInlineOutput) + token,
+		Stderr:   "auth " + token + " failed",
+	}}}
+	var buf bytes.Buffer
+	if err := writeRunsJSON(&buf, recordedRuns(t, store, result)); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(buf.String(), token) {
+		t.Errorf("--output json contains the secret: %s", buf.String())
+	}
+	spilled, err := os.ReadFile(filepath.Join(store.RunDir(), "deploy.stdout.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(spilled), token) {
+		t.Errorf("spilled stdout contains the secret")
+	}
+	if !strings.Contains(result.Tasks[0].Prompt, token) {
+		t.Errorf("recording the run masked the result in memory")
+	}
+}
+
+// TestRunsJSON_Retention tests that --output json keeps only what
+// settings.retention allows, and encrypts spilled files with the run's.
+func TestRunsJSON_Retention(t *testing.T) {
+	store, err := state.NewRunStoreWithPath(t.TempDir(), "api")
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.SetRetention(&config.RetentionConfig{Prompts: config.RetainNone})
+	store.SetKey(bytes.Repeat([]byte{7}, 32))
+
+	prompt := "Review " + strings.Repeat("proprietary ", maxInlineOutput)
+	stdout := strings.Repeat("finding ", maxInlineOutput)
+	result := &state.RunResult{RunID: store.RunID(), Tasks: []state.TaskResult{{TaskName: "review", Prompt: prompt, Stdout: stdout}}}
+	var buf bytes.Buffer
+	if err := writeRunsJSON(&buf, recordedRuns(t, store, result)); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(buf.String(), "proprietary") || strings.Contains(buf.String(), "prompt_file") {
+		t.Errorf("--output json kept the prompt: %.200s", buf.String())
+	}
+	if _, err := os.Stat(filepath.Join(store.RunDir(), "review.prompt.txt")); !os.IsNotExist(err) {
+		t.Errorf("prompt spilled to a file: %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(store.RunDir(), "review.stdout.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "finding") {
+		t.Errorf("spilled stdout isn't encrypted")
+	}
+}
+
+// TestSpillOutput tests that output up to maxInlineOutput is included,
+// and larger output is written to a file that the JSON refers to.
+func TestSpillOutput(t *testing.T) {
+	store, err := state.NewRunStoreWithPath(t.TempDir(), "api")
+	if err != nil {
+		t.Fatal(err)
+	}
+	atLimit := strings.Repeat("a", maxInlineOutput)
+	overLimit := atLimit + "b"
+
+	result := &state.RunResult{RunID: store.RunID(), Tasks: []state.TaskResult{
+		{TaskName: "small", Stdout: atLimit},
+		{TaskName: "large", Stdout: overLimit, Stderr: "warning"},
+	}}
+	var buf bytes.Buffer
+	if err := writeRunsJSON(&buf, recordedRuns(t, store, result)); err != nil {
+		t.Fatal(err)
+	}
+	var out jsonRun
+	if err := json.Unmarshal(buf.Bytes(), &out); err != nil {
+		t.Fatal(err)
+	}
+
+	small, large := out.Tasks[0], out.Tasks[1]
+	if small.Stdout != atLimit || small.StdoutFile != "" {
+		t.Errorf("output of exactly %d bytes: stdout %d bytes, stdout_file %q; want it included", maxInlineOutput, len(small.Stdout), small.StdoutFile)
+	}
+	if _, err := os.Stat(filepath.Join(store.RunDir(), "small.stdout.txt")); !os.IsNotExist(err) {
+		t.Errorf("output of exactly %d bytes was written to a file", maxInlineOutput)
+	}
+
+	wantPath := filepath.Join(store.RunDir(), "large.stdout.txt")
+	if large.Stdout != "" || large.StdoutFile != wantPath {
+		t.Errorf("output of %d bytes: stdout %d bytes, stdout_file %q; want only %q", len(overLimit), len(large.Stdout), large.StdoutFile, wantPath)
+	}
+	if data, err := os.ReadFile(wantPath); err != nil || string(data) != overLimit {
+		t.Errorf("%s holds %d bytes (%v), want the %d-byte output", wantPath, len(data), err, len(overLimit))
+	}
+	if large.Stderr != "warning" || large.StderrFile != "" {
+		t.Errorf("small stderr next to large stdout: %q, %q", large.Stderr, large.StderrFile)
+	}
+	if want := filepath.Join(store.RunDir(), "large.json"); large.ResultFile != want {
+		t.Errorf("result_file = %q, want %q", large.ResultFile, want)
+	}
+}
+
+// TestSummaryTable_Estimated tests that the summary marks token counts
+// estimated from the text, and says so, but not reported ones.
+func TestSummaryTable_Estimated(t *testing.T) {
+	run := &state.RunResult{RunID: "r1", Success: true, Tasks: []state.TaskResult{
+		{TaskName: "review", Success: true, TokenUsage: state.TokenUsage{TotalTokens: 1200}},
+		{TaskName: "fix", Success: true, TokenUsage: state.TokenUsage{TotalTokens: 800, Estimated: true}},
+	}}
+	var buf bytes.Buffer
+	printSummaryTable(&buf, []finishedRun{{RunResult: run}})
+	out := buf.String()
+	for _, want := range []string{"~800", "~2,000", "~ estimated: "} {
+		if !strings.Contains(out, want) {
+			t.Errorf("summary missing %q:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "~1,200") {
+		t.Errorf("summary marks reported usage as estimated:\n%s", out)
+	}
+
+	run.Tasks = run.Tasks[:1]
+	buf.Reset()
+	printSummaryTable(&buf, []finishedRun{{RunResult: run}})
+	if strings.Contains(buf.String(), "~") {
+		t.Errorf("summary without estimates marks some:\n%s", buf.String())
+	}
+}
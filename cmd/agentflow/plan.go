@@ -0,0 +1,99 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/adityaraj/agentflow/internal/config"
+	"github.com/adityaraj/agentflow/internal/planner"
+	"github.com/adityaraj/agentflow/internal/ui"
+)
+
+// newPlanCmd creates the `plan` command, which prints the order tasks run
+// in without running them.
+func newPlanCmd() *cobra.Command {
+	planCmd := &cobra.Command{
+		Use:   "plan [workflow]",
+		Short: "Print the order tasks run in",
+		Long: `Prints the workflow's tasks in dependency order, with the agent each runs on
+and the tasks it needs. Tasks that could run at the same point are listed
+alphabetically, so the order only changes when the Cortexfile does.
+
+--order prints just the order, one task per line followed by a tab and the
+tasks it needs, for scripts and for running the steps by hand.`,
+		Example: `  cortex plan
+  cortex plan --order | cut -f1`,
+		Args:         cobra.MaximumNArgs(1),
+		SilenceUsage: true, // Config errors aren't usage errors
+		RunE:         showPlan,
+	}
+
+	planCmd.Flags().StringArrayVarP(&configFiles, "file", "f", nil, "Path to Cortexfile(s)")
+	planCmd.Flags().Bool("order", false, "Print only task names and their needs, tab-separated")
+	planCmd.Flags().StringVarP(&groupName, "group", "g", "", "Show only the tasks of this group, and the tasks they need")
+	planCmd.Flags().BoolVar(&noColor, "no-color", false, "Disable colored output")
+	return planCmd
+}
+
+func showPlan(cmd *cobra.Command, args []string) error {
+	orderOnly, _ := cmd.Flags().GetBool("order")
+	if len(args) > 0 {
+		workflowName = args[0]
+	}
+	if noColor || orderOnly {
+		ui.SetColorsEnabled(false)
+	}
+
+	configPaths, err := resolveConfigFiles()
+	if err != nil {
+		return err
+	}
+	if len(configPaths) == 0 {
+		return fmt.Errorf("no Cortexfile found")
+	}
+	configPath := configPaths[0]
+
+	cfg, err := loadWorkflow(configPath)
+	if err != nil {
+		return err
+	}
+	if err := config.ValidateWithFile(cfg, configPath); err != nil {
+		return err
+	}
+	plan, err := planner.BuildPlan(cfg)
+	if err != nil {
+		return err
+	}
+	order, err := planner.StableOrder(plan.DAG)
+	if err != nil {
+		return err
+	}
+
+	tasks := make(map[string]planner.ExecutionTask, len(plan.Tasks))
+	for _, t := range plan.Tasks {
+		tasks[t.Name] = t
+	}
+	for i, name := range order {
+		t := tasks[name]
+		needs := slices.Clone(t.Dependencies)
+		slices.Sort(needs)
+		if orderOnly {
+			fmt.Printf("%s\t%s\n", name, strings.Join(needs, ","))
+			continue
+		}
+
+		agent := t.AgentName + " -> " + t.Tool
+		if t.Model != "" {
+			agent += "/" + t.Model
+		}
+		line := fmt.Sprintf("%3d. %s %s", i+1, ui.BoldText(name), ui.DimText("("+agent+")"))
+		if len(needs) > 0 {
+			line += " needs " + strings.Join(needs, ", ")
+		}
+		fmt.Println(line)
+	}
+	return nil
+}
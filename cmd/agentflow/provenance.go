@@ -0,0 +1,163 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/adityaraj/agentflow/internal/config"
+	"github.com/adityaraj/agentflow/internal/git"
+	"github.com/adityaraj/agentflow/internal/provenance"
+	"github.com/adityaraj/agentflow/internal/state"
+	"github.com/adityaraj/agentflow/internal/ui"
+)
+
+// provenanceKeyDir holds the key provenance is signed with.
+func provenanceKeyDir() (string, error) {
+	home, err := ui.GetCortexHome()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, "provenance"), nil
+}
+
+// newProvenanceCmd creates the `provenance` command group for inspecting
+// the signed record of which agents changed which files.
+func newProvenanceCmd() *cobra.Command {
+	provenanceCmd := &cobra.Command{
+		Use:   "provenance",
+		Short: "Inspect the provenance of agent-modified files",
+		Long: `Runs with write tasks save a signed in-toto statement (SLSA provenance) in
+their run directory, recording which agent, model, and prompt changed which
+files.`,
+	}
+
+	verifyCmd := &cobra.Command{
+		Use:   "verify <run-id>",
+		Short: "Check a run's provenance signature and list the files it attests",
+		Long: `Verifies the signature on a run's provenance and checks each attested file
+against its recorded digest, flagging files changed since the run.`,
+		Example: `  cortex provenance verify 20250114-093012
+  cortex provenance verify 20250114-093012 --key team.pub`,
+		Args:         cobra.ExactArgs(1),
+		SilenceUsage: true, // A failed verification isn't a usage error
+		RunE:         verifyProvenanceCmd,
+	}
+	verifyCmd.Flags().String("key", "", "Public key to verify with (default: "+filepath.Join("~/.cortex/provenance", provenance.PublicKeyFile)+")")
+
+	provenanceCmd.AddCommand(verifyCmd)
+	return provenanceCmd
+}
+
+func verifyProvenanceCmd(cmd *cobra.Command, args []string) error {
+	keyPath, _ := cmd.Flags().GetString("key")
+	if keyPath == "" {
+		dir, err := provenanceKeyDir()
+		if err != nil {
+			return err
+		}
+		keyPath = filepath.Join(dir, provenance.PublicKeyFile)
+	}
+
+	runID := strings.TrimPrefix(args[0], "run-")
+	session, err := state.FindSession(runID)
+	if err != nil {
+		return err
+	}
+	env, err := provenance.Load(session.RunDir)
+	if err != nil {
+		return fmt.Errorf("run %s: %w", runID, err)
+	}
+	pub, err := provenance.LoadPublicKey(keyPath)
+	if err != nil {
+		return err
+	}
+	stmt, err := provenance.Verify(env, pub)
+	if err != nil {
+		return fmt.Errorf("run %s: %w", runID, err)
+	}
+	ui.Success("Signature verified (%s)", keyPath)
+
+	fmt.Println()
+	for _, t := range stmt.Predicate.BuildDefinition.InternalParameters.Tasks {
+		agent := t.Agent + " (" + t.Tool
+		if t.Model != "" {
+			agent += ", " + t.Model
+		}
+		fmt.Printf("%s  %s\n", ui.BoldText(t.Name), agent+")")
+		for _, f := range t.Files {
+			fmt.Printf("  %s\n", f)
+		}
+	}
+
+	// Paths are relative to the root of the repository the run was in
+	dir := "."
+	if m, err := state.LoadManifest(session.RunDir); err == nil {
+		dir = m.Dir
+	}
+	changed := 0
+	if root, err := git.Root(dir); err == nil {
+		for _, s := range stmt.Subject {
+			digest, err := provenance.FileDigest(filepath.Join(root, filepath.FromSlash(s.Name)))
+			if err == nil && digest == s.Digest["sha256"] {
+				continue
+			}
+			changed++
+			ui.Warning("%s has changed since run %s", s.Name, runID)
+		}
+	}
+	fmt.Println()
+	if changed > 0 {
+		return fmt.Errorf("%d of %d attested files have changed since the run", changed, len(stmt.Subject))
+	}
+	ui.Success("%d attested files match", len(stmt.Subject))
+	return nil
+}
+
+// writeProvenance signs and saves the provenance of the files the run's
+// write tasks changed, if any.
+func writeProvenance(store *state.RunStore, result *state.RunResult, configPath string, tools map[string]string) error {
+	root, err := git.Root(".")
+	if err != nil {
+		return nil // Changes are only tracked in git working trees
+	}
+
+	opts := provenance.Options{
+		Root:     root,
+		Workflow: configPath,
+		Versions: map[string]string{"cortex": version},
+	}
+	for tool, v := range tools {
+		opts.Versions[tool] = v
+	}
+	if rerunOf != nil {
+		opts.Workflow, opts.ConfigHash = rerunOf.ConfigPath, rerunOf.ConfigHash
+	} else if content, err := os.ReadFile(configPath); err == nil {
+		opts.ConfigHash = state.Hash(string(content))
+	}
+	if m, err := state.LoadManifest(store.RunDir()); err == nil {
+		opts.Commit = m.Vars[config.MetaGitCommit]
+	}
+
+	stmt, err := provenance.Build(result, opts)
+	if err != nil || stmt == nil {
+		return err
+	}
+	dir, err := provenanceKeyDir()
+	if err != nil {
+		return err
+	}
+	key, err := provenance.LoadOrCreateKey(dir)
+	if err != nil {
+		return err
+	}
+	path, err := provenance.Write(store.RunDir(), stmt, key)
+	if err != nil {
+		return err
+	}
+	ui.Info("Provenance for %d files signed (%s)", len(stmt.Subject), path)
+	return nil
+}
@@ -0,0 +1,274 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+
+	"github.com/adityaraj/agentflow/internal/config"
+	"github.com/adityaraj/agentflow/internal/git"
+	"github.com/adityaraj/agentflow/internal/planner"
+	"github.com/adityaraj/agentflow/internal/state"
+	"github.com/adityaraj/agentflow/internal/ui"
+)
+
+// rerunOf is the manifest of the run being re-executed by `cortex rerun`,
+// so the new run's manifest points back at the original Cortexfile.
+var rerunOf *state.Manifest
+
+// newRerunCmd creates the `rerun` command, which re-executes a past run
+// from its manifest.
+func newRerunCmd() *cobra.Command {
+	rerunCmd := &cobra.Command{
+		Use:   "rerun <run-id>",
+		Short: "Re-execute a run with the same resolved inputs",
+		Long: `Re-executes a past run from its manifest: the same resolved config, settings,
+and diff base commit, in the same directory. Inputs that can no longer be
+reproduced (tool versions, the checked-out commit, environment variables the
+prompts use) are flagged before the run, and tasks whose final prompt
+differs from the original run are flagged after it.`,
+		Example: `  cortex rerun 20250114-093012
+  cortex rerun 20250114-093012 --strict`,
+		Args:         cobra.ExactArgs(1),
+		SilenceUsage: true, // Task failures aren't usage errors
+		RunE:         rerunRun,
+	}
+
+	rerunCmd.Flags().Bool("strict", false, "Refuse to rerun if any input can't be reproduced")
+	rerunCmd.Flags().BoolVar(&noColor, "no-color", false, "Disable colored output")
+	return rerunCmd
+}
+
+func rerunRun(cmd *cobra.Command, args []string) error {
+	if noColor {
+		ui.SetColorsEnabled(false)
+	}
+	strict, _ := cmd.Flags().GetBool("strict")
+
+	runID := strings.TrimPrefix(args[0], "run-")
+	session, err := state.FindSession(runID)
+	if err != nil {
+		return err
+	}
+	manifest, err := state.LoadManifest(session.RunDir)
+	if err != nil {
+		return fmt.Errorf("cannot rerun %s: %w", runID, err)
+	}
+	if err := os.Chdir(manifest.Dir); err != nil {
+		return fmt.Errorf("cannot rerun %s: %w", runID, err)
+	}
+
+	if content, err := os.ReadFile(manifest.ConfigPath); err == nil && state.Hash(string(content)) != manifest.ConfigHash {
+		ui.Info("%s has changed since run %s; using the config recorded then", manifest.ConfigPath, runID)
+	}
+	drift := manifestDrift(manifest)
+	for _, d := range drift {
+		ui.Warning("Not reproducible: %s", d)
+	}
+	if strict && len(drift) > 0 {
+		return fmt.Errorf("run %s can't be reproduced exactly; rerun without --strict to run it anyway", runID)
+	}
+
+	dir, err := os.MkdirTemp("", "cortex-rerun-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+	configPath := filepath.Join(dir, "Cortexfile.yml")
+	if err := os.WriteFile(configPath, []byte(manifest.Config), 0644); err != nil {
+		return fmt.Errorf("failed to write recorded config: %w", err)
+	}
+
+	baseRef = manifest.BaseCommit
+	rerunOf = manifest
+	success, _, runErr := runSingleConfig(cmd, configPath)
+
+	if latest, err := state.ListSessions(state.SessionFilter{Project: session.Project, Limit: 1}); err == nil && len(latest) > 0 && latest[0].RunID != runID {
+		for _, d := range promptDrift(session.Project, runID, latest[0].RunID) {
+			ui.Warning("Not reproduced: %s", d)
+		}
+	}
+
+	if runErr != nil {
+		return runErr
+	}
+	if !success {
+		return withExit(ExitTaskFailed, fmt.Errorf("workflow completed with failures"))
+	}
+	return nil
+}
+
+// writeManifest saves the run's resolved inputs to its run directory: the
+// config with flows spliced in, prompt and instructions files inlined, and settings merged,
+// plus what the prompts' placeholders and tools resolved to.
+func writeManifest(store *state.RunStore, configPath string, cfg *config.AgentflowConfig, plan *planner.ExecutionPlan, settings config.SettingsConfig, tools map[string]string) error {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+
+	resolved := *cfg
+	settings.Store = "" // Only the global config sets it, and a rerun reads it from there
+	resolved.Settings = &settings
+	resolved.Preamble = plan.Preamble
+	resolved.PreambleFile = ""
+	resolved.Agents = make(map[string]config.AgentConfig, len(cfg.Agents))
+	for name, agent := range cfg.Agents {
+		agent.InstructionsFile = ""
+		resolved.Agents[name] = agent
+	}
+	resolved.Tasks = make(map[string]config.TaskConfig, len(cfg.Tasks))
+	for name, task := range cfg.Tasks {
+		task.PromptFile = ""
+		task.SchemaFile = "" // Loaded into Schema
+		resolved.Tasks[name] = task
+	}
+	data, err := yaml.Marshal(&resolved)
+	if err != nil {
+		return fmt.Errorf("failed to marshal config: %w", err)
+	}
+
+	m := &state.Manifest{
+		RunID:      store.RunID(),
+		CreatedAt:  time.Now(),
+		Dir:        cwd,
+		ConfigPath: configPath,
+		Config:     string(data),
+		Tools:      tools,
+		Vars:       make(map[string]string),
+	}
+	if rerunOf != nil {
+		m.ConfigPath, m.ConfigHash = rerunOf.ConfigPath, rerunOf.ConfigHash
+	} else if content, err := os.ReadFile(configPath); err == nil {
+		m.ConfigHash = state.Hash(string(content))
+	}
+
+	texts := plan.SharedPrompts()
+	for _, t := range plan.Tasks {
+		m.Tasks = append(m.Tasks, state.ManifestTask{
+			Name:       t.Name,
+			Agent:      t.AgentName,
+			Tool:       t.Tool,
+			Model:      t.Model,
+			PromptHash: state.Hash(t.Prompt),
+		})
+		texts = append(texts, t.Prompt, t.OutputFile)
+	}
+	for name, value := range manifestVars(texts) {
+		m.Vars[name] = value
+	}
+	if plan.Diff != nil {
+		m.BaseCommit, _ = git.ResolveCommit(".", plan.Diff.Base)
+	}
+	return store.SaveManifest(m)
+}
+
+// manifestVars returns the checked-out branch and commit, and a hash of
+// each environment variable texts reference (values aren't recorded, as
+// they may be secrets).
+func manifestVars(texts []string) map[string]string {
+	vars := map[string]string{
+		config.MetaGitBranch: manifestVar(config.MetaGitBranch),
+		config.MetaGitCommit: manifestVar(config.MetaGitCommit),
+	}
+	for _, text := range texts {
+		for _, name := range config.ExtractMetaVars(text) {
+			if strings.HasPrefix(name, "env.") {
+				vars[name] = manifestVar(name)
+			}
+		}
+	}
+	return vars
+}
+
+// manifestVar returns the current value of a manifest variable.
+func manifestVar(name string) string {
+	switch name {
+	case config.MetaGitBranch:
+		branch, _ := git.CurrentBranch(".")
+		return branch
+	case config.MetaGitCommit:
+		commit, _ := git.HeadCommit(".")
+		return commit
+	}
+	return state.Hash(os.Getenv(strings.TrimPrefix(name, "env.")))
+}
+
+// manifestDrift lists the recorded inputs of a run that differ now.
+func manifestDrift(m *state.Manifest) []string {
+	var drift []string
+
+	tools := make([]string, 0, len(m.Tools))
+	for tool := range m.Tools {
+		tools = append(tools, tool)
+	}
+	sort.Strings(tools)
+	current := newAgentRegistry(false).ToolVersions(tools)
+	for _, tool := range tools {
+		if current[tool] != m.Tools[tool] {
+			now := current[tool]
+			if now == "" {
+				now = "unavailable"
+			}
+			drift = append(drift, fmt.Sprintf("%s is %s, was %s", tool, now, m.Tools[tool]))
+		}
+	}
+
+	names := make([]string, 0, len(m.Vars))
+	for name := range m.Vars {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		now := manifestVar(name)
+		if now == m.Vars[name] {
+			continue
+		}
+		if strings.HasPrefix(name, "env.") {
+			drift = append(drift, fmt.Sprintf("$%s has changed", strings.TrimPrefix(name, "env.")))
+		} else {
+			drift = append(drift, fmt.Sprintf("%s is %q, was %q", name, now, m.Vars[name]))
+		}
+	}
+
+	if m.BaseCommit != "" && !git.RefExists(".", m.BaseCommit) {
+		drift = append(drift, fmt.Sprintf("diff base commit %s no longer exists", m.BaseCommit))
+	}
+	return drift
+}
+
+// promptDrift lists the tasks of run whose final prompt differs in rerun,
+// e.g. because an upstream output, project memory, or a prior run's
+// outputs changed.
+func promptDrift(project, run, rerun string) []string {
+	before, err := state.GetSession(project, run)
+	if err != nil {
+		return nil
+	}
+	after, err := state.GetSession(project, rerun)
+	if err != nil {
+		return nil
+	}
+
+	prompts := make(map[string]string, len(after.Tasks))
+	for _, t := range after.Tasks {
+		prompts[t.TaskName] = t.Prompt
+	}
+	var drift []string
+	for _, t := range before.Tasks {
+		prompt, ok := prompts[t.TaskName]
+		switch {
+		case !ok:
+			drift = append(drift, fmt.Sprintf("task %q didn't run", t.TaskName))
+		case prompt != t.Prompt:
+			drift = append(drift, fmt.Sprintf("task %q got a different prompt", t.TaskName))
+		}
+	}
+	return drift
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/adityaraj/agentflow/internal/config"
+	"github.com/adityaraj/agentflow/internal/planner"
+	"github.com/adityaraj/agentflow/internal/state"
+)
+
+// writeFiles writes files, by path relative to dir.
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// recordManifest loads the Cortexfile in dir and writes its manifest as
+// cortex run does, returning the manifest as it was saved.
+func recordManifest(t *testing.T, dir string, settings config.SettingsConfig) *state.Manifest {
+	t.Helper()
+	configPath := filepath.Join(dir, "Cortexfile.yml")
+	cfg, err := config.LoadConfig(configPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := config.ValidateWithFile(cfg, configPath); err != nil {
+		t.Fatal(err)
+	}
+	plan, err := planner.BuildPlan(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store, err := state.NewRunStoreWithPath(t.TempDir(), "api")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := writeManifest(store, configPath, cfg, plan, settings, map[string]string{"claude-code": "1.0.0"}); err != nil {
+		t.Fatalf("writeManifest() error = %v", err)
+	}
+	m, err := state.LoadManifest(store.RunDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+// TestManifestRoundTrip tests that the config a manifest records loads and
+// validates on its own, as cortex rerun runs it from a temp directory,
+// with files it referenced inlined.
+func TestManifestRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"Cortexfile.yml": `agents:
+  ai:
+    tool: claude-code
+    instructions_file: prompts/persona.md
+  sh: {tool: shell}
+tasks:
+  plan:
+    agent: ai
+    prompt_file: prompts/plan.md
+    expect: json
+    schema_file: plan.schema.json
+    expect_retries: 1
+  review:
+    agent: ai
+    needs: [plan]
+    prompt: "Review {{outputs.plan.files}}"
+  test:
+    agent: sh
+    command: go test ./...
+`,
+		"prompts/persona.md": "You are careful.",
+		"prompts/plan.md":    "List the files to change.",
+		"plan.schema.json":   `{"type": "object", "required": ["files"]}`,
+	})
+	settings := config.DefaultSettings()
+	settings.MaxTokens = 5000
+	settings.Store = "sqlite" // From the global config
+	m := recordManifest(t, dir, settings)
+
+	if m.ConfigPath != filepath.Join(dir, "Cortexfile.yml") || m.ConfigHash == "" {
+		t.Errorf("manifest config path %q, hash %q", m.ConfigPath, m.ConfigHash)
+	}
+	if m.Tools["claude-code"] != "1.0.0" || len(m.Tasks) != 3 {
+		t.Errorf("manifest tools %v, tasks %v", m.Tools, m.Tasks)
+	}
+	for _, task := range m.Tasks {
+		if task.PromptHash == "" {
+			t.Errorf("task %s has no prompt hash", task.Name)
+		}
+	}
+
+	// Loaded from elsewhere, as cortex rerun does
+	rerunDir := t.TempDir()
+	rerunPath := filepath.Join(rerunDir, "Cortexfile.yml")
+	writeFiles(t, rerunDir, map[string]string{"Cortexfile.yml": m.Config})
+	cfg, err := config.LoadConfig(rerunPath)
+	if err != nil {
+		t.Fatalf("LoadConfig(manifest config) error = %v\n%s", err, m.Config)
+	}
+	if err := config.ValidateWithFile(cfg, rerunPath); err != nil {
+		t.Fatalf("ValidateWithFile(manifest config) error = %v\n%s", err, m.Config)
+	}
+	plan := cfg.Tasks["plan"]
+	if plan.Prompt != "List the files to change." || plan.PromptFile != "" {
+		t.Errorf("plan prompt %q, prompt_file %q; want the file inlined", plan.Prompt, plan.PromptFile)
+	}
+	if plan.Schema["type"] != "object" || plan.SchemaFile != "" || plan.ExpectRetries != 1 {
+		t.Errorf("plan schema %v, schema_file %q, retries %d; want the file inlined", plan.Schema, plan.SchemaFile, plan.ExpectRetries)
+	}
+	if review := cfg.Tasks["review"]; review.Schema != nil || review.Expect != "" {
+		t.Errorf("review gained schema %v, expect %q", review.Schema, review.Expect)
+	}
+	if got := cfg.Agents["ai"]; got.Instructions != "You are careful." || got.InstructionsFile != "" {
+		t.Errorf("agent instructions %q, file %q; want the file inlined", got.Instructions, got.InstructionsFile)
+	}
+	if cfg.Settings == nil || cfg.Settings.MaxTokens != 5000 {
+		t.Errorf("manifest settings %+v, want the run's", cfg.Settings)
+	}
+}
+
+// TestManifestDrift tests that the inputs of a run that can't be
+// reproduced are listed, and the ones that can aren't.
+func TestManifestDrift(t *testing.T) {
+	t.Setenv("CORTEX_TEST_SAME", "same")
+	t.Setenv("CORTEX_TEST_CHANGED", "now")
+	m := &state.Manifest{
+		Tools: map[string]string{"no-such-tool": "1.0"},
+		Vars: map[string]string{
+			config.MetaGitBranch:      "cortex-test-no-such-branch",
+			config.MetaGitCommit:      manifestVar(config.MetaGitCommit),
+			"env.CORTEX_TEST_SAME":    state.Hash("same"),
+			"env.CORTEX_TEST_CHANGED": state.Hash("then"),
+		},
+		BaseCommit: "0123456789abcdef0123456789abcdef01234567",
+	}
+	got := manifestDrift(m)
+	want := []string{
+		"no-such-tool is unavailable, was 1.0",
+		"$CORTEX_TEST_CHANGED has changed",
+		`git.branch is "` + manifestVar(config.MetaGitBranch) + `", was "cortex-test-no-such-branch"`,
+		"diff base commit 0123456789abcdef0123456789abcdef01234567 no longer exists",
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("manifestDrift() =\n%q\nwant\n%q", got, want)
+	}
+
+	m = &state.Manifest{Vars: map[string]string{"env.CORTEX_TEST_SAME": state.Hash("same")}}
+	if got := manifestDrift(m); len(got) != 0 {
+		t.Errorf("manifestDrift() with nothing changed = %q", got)
+	}
+}
+
+// TestPromptDrift tests that tasks of a rerun with a different prompt, or
+// that didn't run, are listed.
+func TestPromptDrift(t *testing.T) {
+	store := state.NewJSONStore(t.TempDir(), nil)
+	state.UseStore(store)
+	t.Cleanup(func() { state.UseStore(nil) })
+
+	runs := map[string][]state.TaskResult{
+		"run1": {{TaskName: "scan", Prompt: "Scan"}, {TaskName: "review", Prompt: "Review a.go"}, {TaskName: "fix", Prompt: "Fix"}},
+		"run2": {{TaskName: "scan", Prompt: "Scan"}, {TaskName: "review", Prompt: "Review b.go"}},
+	}
+	for id, tasks := range runs {
+		if err := store.Save("api", &state.RunResult{RunID: id, Tasks: tasks}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want := []string{`task "review" got a different prompt`, `task "fix" didn't run`}
+	if got := promptDrift("api", "run1", "run2"); !slices.Equal(got, want) {
+		t.Errorf("promptDrift() = %q, want %q", got, want)
+	}
+	if got := promptDrift("api", "run1", "run1"); len(got) != 0 {
+		t.Errorf("promptDrift() of a run with itself = %q", got)
+	}
+	if got := promptDrift("api", "run1", "missing"); got != nil {
+		t.Errorf("promptDrift() with a missing rerun = %q", got)
+	}
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/adityaraj/agentflow/internal/config"
+	"github.com/adityaraj/agentflow/internal/state"
+	"github.com/adityaraj/agentflow/internal/ui"
+)
+
+// checkSchedule keeps a run from starting outside its schedule's window or
+// once the project's daily quotas are used up, counting the runs that
+// started since midnight. It returns the tokens left today, or 0 if there
+// is no daily token limit.
+func checkSchedule(s *config.ScheduleConfig, runs state.Store, project string, now time.Time) (int, error) {
+	if s == nil {
+		return 0, nil
+	}
+	if s.Window != "" {
+		window, err := config.ParseWindow(s.Window)
+		if err != nil {
+			return 0, withExit(ExitConfig, err)
+		}
+		if !window.Contains(now) {
+			return 0, withExit(ExitDeferred, fmt.Errorf("outside the schedule's window %s; next opens %s (--ignore-schedule to run anyway)",
+				s.Window, window.Next(now).Format("Mon 15:04")))
+		}
+	}
+	if s.DailyMaxTokens == 0 && s.DailyMaxCost == 0 {
+		return 0, nil
+	}
+
+	records, err := runs.Query(state.Query{Project: project, Since: config.StartOfDay(now)})
+	if err != nil {
+		return 0, err
+	}
+	var used state.TokenUsage
+	for _, r := range records {
+		used.TotalTokens += r.TokenUsage.TotalTokens
+		used.CostUSD += r.TokenUsage.CostUSD
+	}
+	if s.DailyMaxCost > 0 && used.CostUSD >= s.DailyMaxCost {
+		return 0, withExit(ExitDeferred, fmt.Errorf("daily cost quota used up: $%.2f of $%.2f today (--ignore-schedule to run anyway)",
+			used.CostUSD, s.DailyMaxCost))
+	}
+	if s.DailyMaxTokens == 0 {
+		return 0, nil
+	}
+	if used.TotalTokens >= s.DailyMaxTokens {
+		return 0, withExit(ExitDeferred, fmt.Errorf("daily token quota used up: %s of %s today (--ignore-schedule to run anyway)",
+			ui.FormatTokenCount(used.TotalTokens), ui.FormatTokenCount(s.DailyMaxTokens)))
+	}
+	return s.DailyMaxTokens - used.TotalTokens, nil
+}
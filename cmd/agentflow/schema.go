@@ -0,0 +1,33 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/adityaraj/agentflow/internal/config"
+)
+
+// newSchemaCmd creates the `schema` command, which prints the JSON Schema
+// of Cortexfile.yml.
+func newSchemaCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "schema",
+		Short: "Print the JSON Schema of Cortexfile.yml",
+		Long: `Prints a JSON Schema describing Cortexfile.yml, for editor autocompletion and
+for checking configs in CI without running cortex.
+
+With the VS Code YAML extension, save it and map it to your Cortexfiles in
+settings.json:
+
+  "yaml.schemas": {"./cortexfile.schema.json": ["Cortexfile.yml", "Cortexfile.yaml"]}`,
+		Example: `  cortex schema > cortexfile.schema.json`,
+		Args:    cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			return enc.Encode(config.Schema())
+		},
+	}
+}
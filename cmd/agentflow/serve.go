@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/adityaraj/agentflow/internal/server"
+	"github.com/adityaraj/agentflow/internal/state"
+	"github.com/adityaraj/agentflow/internal/ui"
+)
+
+// newServeCmd creates the `serve` command, which serves the project's run
+// status over HTTP.
+func newServeCmd() *cobra.Command {
+	var addr, project string
+	var origins []string
+	cmd := &cobra.Command{
+		Use:   "serve",
+		Short: "Serve run status badges, task logs, and run events over HTTP",
+		Long: `Serves the status of the project's runs over HTTP:
+
+  GET /badge/<workflow>.svg   Badge with the status and duration of the
+                              workflow's latest run ("default" for the
+                              top-level tasks)
+  GET /runs/<run-id>/tasks/<task>/logs
+                              The task's output so far, from ?offset=<bytes>;
+                              with ?follow=true, streamed as server-sent
+                              events until the task finishes
+  GET /events                 WebSocket of JSON run and task events, with
+                              task output; ?run=<run-id> (repeatable) for
+                              only those runs
+
+Runs are read from the store (settings.store) under <project>, the
+current directory's name unless --project is given. Embed a badge in a
+README with:
+
+  ![cortex](https://cortex.example.com/badge/nightly.svg)`,
+		Example: `  cortex serve
+  cortex serve --addr :8080 --project api`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if project == "" {
+				cwd, err := os.Getwd()
+				if err != nil {
+					return err
+				}
+				project = filepath.Base(cwd)
+			}
+			runs, err := state.DefaultStore()
+			if err != nil {
+				return err
+			}
+			home, err := ui.GetCortexHome()
+			if err != nil {
+				return fmt.Errorf("failed to get home directory: %w", err)
+			}
+
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+			handler := server.New(runs, home, project)
+			handler.AllowOrigins(origins...)
+			srv := &http.Server{
+				Addr:              addr,
+				Handler:           handler.Handler(),
+				ReadHeaderTimeout: 10 * time.Second,
+				// Stop streaming logs on shutdown instead of waiting for them
+				BaseContext: func(net.Listener) context.Context { return ctx },
+			}
+			go func() {
+				<-ctx.Done()
+				shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+				defer cancel()
+				_ = srv.Shutdown(shutdownCtx)
+			}()
+
+			ui.Info("Serving %s runs on http://%s", project, addr)
+			if err := srv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+				return err
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&addr, "addr", "127.0.0.1:8080", "Address to listen on")
+	cmd.Flags().StringVar(&project, "project", "", "Project whose runs to serve (default: the current directory's name)")
+	cmd.Flags().StringSliceVar(&origins, "allow-origin", nil, "Origin of a web UI allowed to subscribe to /events, e.g. https://dash.example.com (\"*\" = any)")
+	return cmd
+}
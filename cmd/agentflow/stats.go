@@ -0,0 +1,138 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/adityaraj/agentflow/internal/state"
+	"github.com/adityaraj/agentflow/internal/ui"
+)
+
+// newStatsCmd creates the `stats` command, which summarizes stored runs.
+func newStatsCmd() *cobra.Command {
+	statsCmd := &cobra.Command{
+		Use:   "stats",
+		Short: "Summarize stored runs",
+	}
+
+	var project, since, sortBy string
+	var asJSON bool
+	agentsCmd := &cobra.Command{
+		Use:   "agents",
+		Short: "Show failure rates, retries, rate limits, and flakiness per agent",
+		Long: `Shows how each agent, with its tool and model, fared across stored runs:
+how many tasks it ran and failed, how often it was retried, rate limited, or
+timed out, and how flaky it was.
+
+FLAKY is the share of its tasks whose outcome wasn't repeatable: tasks that
+passed only on retry, and tasks that passed where their previous run with the
+agent failed, or the other way around. An agent that fails a task every time
+is failing, not flaky. Skipped and cancelled tasks aren't counted.`,
+		Example: `  cortex stats agents
+  cortex stats agents --project api --since 720h --sort flaky
+  cortex stats agents --json`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			q := state.Query{Project: project}
+			if since != "" {
+				age, err := time.ParseDuration(since)
+				if err != nil || age <= 0 {
+					return withExit(ExitConfig, fmt.Errorf("invalid --since %q: use a duration like 720h", since))
+				}
+				q.Since = time.Now().Add(-age)
+			}
+			less, ok := agentStatsOrders[sortBy]
+			if !ok {
+				return withExit(ExitConfig, fmt.Errorf("invalid --sort %q: use one of %s", sortBy, strings.Join(sortedKeys(agentStatsOrders), ", ")))
+			}
+
+			store, err := state.DefaultStore()
+			if err != nil {
+				return err
+			}
+			records, err := store.Query(q)
+			if err != nil {
+				return err
+			}
+			stats := state.ComputeAgentStats(records)
+			sort.SliceStable(stats, func(i, j int) bool { return less(&stats[i], &stats[j]) })
+
+			if asJSON {
+				enc := json.NewEncoder(os.Stdout)
+				enc.SetIndent("", "  ")
+				return enc.Encode(stats)
+			}
+			if len(stats) == 0 {
+				fmt.Printf("%sNo task results found.%s\n", ui.Dim, ui.Reset)
+				return nil
+			}
+			printAgentStats(os.Stdout, stats)
+			return nil
+		},
+	}
+	agentsCmd.Flags().StringVar(&project, "project", "", "Only count runs of this project")
+	agentsCmd.Flags().StringVar(&since, "since", "", "Only count runs started less than this long ago, e.g. 720h")
+	agentsCmd.Flags().StringVar(&sortBy, "sort", "agent", "Order rows by agent, tasks, failures, or flaky")
+	agentsCmd.Flags().BoolVar(&asJSON, "json", false, "Output in JSON format")
+	statsCmd.AddCommand(agentsCmd)
+	return statsCmd
+}
+
+// agentStatsOrders are the orders of --sort, worst first.
+var agentStatsOrders = map[string]func(a, b *state.AgentStats) bool{
+	"agent":    func(a, b *state.AgentStats) bool { return false }, // As computed
+	"tasks":    func(a, b *state.AgentStats) bool { return a.Tasks > b.Tasks },
+	"failures": func(a, b *state.AgentStats) bool { return a.FailureRate() > b.FailureRate() },
+	"flaky":    func(a, b *state.AgentStats) bool { return a.Flakiness() > b.Flakiness() },
+}
+
+// printAgentStats writes one row per agent, tool, and model.
+func printAgentStats(w io.Writer, stats []state.AgentStats) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "AGENT\tTOOL\tMODEL\tTASKS\tFAILED\tRETRIES\tRATE LIMITS\tTIMEOUTS\tFLAKY\tTOP ERROR\tLAST RUN")
+	for _, s := range stats {
+		model := s.Model
+		if model == "" {
+			model = "-"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%s\t%d\t%d\t%d\t%s\t%s\t%s\n",
+			s.Agent, s.Tool, model, s.Tasks,
+			fmt.Sprintf("%d (%s)", s.Failures, formatPercent(s.FailureRate())),
+			s.Retries, s.RateLimits, s.Timeouts, formatPercent(s.Flakiness()),
+			topError(s.Errors), s.LastSeen.Format("2006-01-02"))
+	}
+	tw.Flush()
+}
+
+// topError is the most frequent failure category, or "-".
+func topError(errors map[state.ErrorCategory]int) string {
+	top, most := "-", 0
+	for _, category := range sortedKeys(errors) {
+		if errors[category] > most {
+			top, most = string(category), errors[category]
+		}
+	}
+	return top
+}
+
+func formatPercent(f float64) string {
+	return fmt.Sprintf("%.0f%%", f*100)
+}
+
+// sortedKeys returns the keys of m in order.
+func sortedKeys[K ~string, V any](m map[K]V) []K {
+	keys := make([]K, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+	return keys
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/adityaraj/agentflow/internal/config"
+	"github.com/adityaraj/agentflow/internal/state"
+	"github.com/adityaraj/agentflow/internal/ui"
+)
+
+// openStateStore opens the store run records are kept in (settings.store),
+// encrypting the runs it saves with key unless it's nil, and makes every
+// command that reads runs use it.
+func openStateStore(name string, key []byte) (state.Store, error) {
+	home, err := ui.GetCortexHome()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get home directory: %w", err)
+	}
+	store, err := state.OpenStore(name, state.StoreOptions{Dir: home, Key: key})
+	if err != nil {
+		return nil, fmt.Errorf("settings.store: %w", err)
+	}
+	state.UseStore(store)
+	return store, nil
+}
+
+// configureGlobalStore opens the store of the global config and
+// CORTEX_STORE before any command runs, so commands that list and read
+// runs find them.
+func configureGlobalStore(cmd *cobra.Command, args []string) error {
+	global, err := config.LoadGlobalConfig()
+	if err != nil {
+		return nil // Commands that use the global config report this themselves
+	}
+	settings := global.Settings
+	if err := config.ApplyEnv(&settings, os.LookupEnv); err != nil {
+		return nil // Reported by the commands that merge settings
+	}
+	if _, err := openStateStore(settings.Store, nil); err != nil {
+		ui.Warning("Reading runs from ~/.cortex/sessions: %s", err)
+	}
+	return nil
+}
+
+// newSessionsGCCmd creates the `sessions gc` command, which removes old
+// runs from the store.
+func newSessionsGCCmd() *cobra.Command {
+	var project, olderThan string
+	var keep int
+	var dryRun bool
+	cmd := &cobra.Command{
+		Use:   "gc",
+		Short: "Remove old runs",
+		Long: `Removes runs, with their run directories, that neither --keep nor
+--older-than keeps: a run is kept if it is one of its project's --keep
+newest runs or started less than --older-than ago.`,
+		Example: `  cortex sessions gc --keep 20
+  cortex sessions gc --older-than 720h --project api --dry-run`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			policy := state.GCPolicy{Project: project, KeepLast: keep, DryRun: dryRun}
+			if olderThan != "" {
+				age, err := time.ParseDuration(olderThan)
+				if err != nil || age <= 0 {
+					return withExit(ExitConfig, fmt.Errorf("invalid --older-than %q: use a duration like 720h", olderThan))
+				}
+				policy.Before = time.Now().Add(-age)
+			}
+			if keep < 0 {
+				return withExit(ExitConfig, fmt.Errorf("--keep cannot be negative"))
+			}
+			if policy.KeepLast == 0 && policy.Before.IsZero() {
+				return withExit(ExitConfig, fmt.Errorf("nothing to remove: give --keep or --older-than"))
+			}
+
+			store, err := state.DefaultStore()
+			if err != nil {
+				return err
+			}
+			removed, err := store.GC(policy)
+			verb := "Removed"
+			if dryRun {
+				verb = "Would remove"
+			}
+			for _, s := range removed {
+				fmt.Printf("  %s %s/%s (%s)\n", verb, s.Project, s.RunID, s.StartTime.Format("2006-01-02 15:04:05"))
+			}
+			if err != nil {
+				return err
+			}
+			ui.Success("%s %d runs", verb, len(removed))
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&project, "project", "", "Only remove runs of this project")
+	cmd.Flags().IntVar(&keep, "keep", 0, "Keep each project's newest N runs")
+	cmd.Flags().StringVar(&olderThan, "older-than", "", "Keep runs started less than this long ago, e.g. 720h")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "List the runs that would be removed without removing them")
+	return cmd
+}
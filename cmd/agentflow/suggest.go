@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+
+	"github.com/adityaraj/agentflow/internal/config"
+)
+
+// enableSuggestions makes mistyped subcommands and flags under root answer
+// with the closest names, ranked by the same matcher as Cortexfile errors,
+// instead of a bare usage error.
+func enableSuggestions(root *cobra.Command) {
+	root.DisableSuggestions = true // Cobra's own, replaced by unknownCommand
+	root.SetFlagErrorFunc(suggestFlag)
+
+	var walk func(cmd *cobra.Command)
+	walk = func(cmd *cobra.Command) {
+		if cmd.HasSubCommands() && !cmd.Runnable() {
+			// Command groups only take subcommands; bare, they show help
+			// as before
+			cmd.Args = unknownCommand
+			cmd.RunE = func(cmd *cobra.Command, args []string) error {
+				return cmd.Help()
+			}
+		}
+		for _, sub := range cmd.Commands() {
+			walk(sub)
+		}
+	}
+	walk(root)
+}
+
+// unknownCommand rejects arguments to a command group, suggesting the
+// subcommands closest to the first.
+func unknownCommand(cmd *cobra.Command, args []string) error {
+	if len(args) == 0 {
+		return nil
+	}
+	var names []string
+	for _, sub := range cmd.Commands() {
+		if sub.IsAvailableCommand() {
+			names = append(names, sub.Name())
+			names = append(names, sub.Aliases...)
+		}
+	}
+	err := fmt.Errorf("unknown command %q for %q", args[0], cmd.CommandPath())
+	if suggestions := config.DidYouMean(args[0], names); suggestions != "" {
+		return fmt.Errorf("%w; did you mean %s?", err, suggestions)
+	}
+	return err
+}
+
+// suggestFlag adds the closest flags of cmd to an unknown flag error.
+func suggestFlag(cmd *cobra.Command, err error) error {
+	name, ok := strings.CutPrefix(err.Error(), "unknown flag: --")
+	if !ok {
+		return err
+	}
+	// Flags holds the inherited flags too once parsing has begun, so
+	// each is taken once
+	var names []string
+	seen := make(map[string]bool)
+	collect := func(f *pflag.Flag) {
+		if !f.Hidden && !seen[f.Name] {
+			seen[f.Name] = true
+			names = append(names, f.Name)
+		}
+	}
+	cmd.Flags().VisitAll(collect)
+	cmd.InheritedFlags().VisitAll(collect)
+
+	var flags []string
+	for _, s := range config.SuggestAll(name, names, config.MaxSuggestions) {
+		flags = append(flags, "--"+s.Value)
+	}
+	if len(flags) == 0 {
+		return err
+	}
+	return fmt.Errorf("%w; did you mean %s?", err, config.JoinOr(flags))
+}
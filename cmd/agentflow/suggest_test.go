@@ -0,0 +1,66 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+)
+
+// TestSuggestions tests that mistyped subcommands and flags are answered
+// with the closest names, and bare command groups with their help.
+func TestSuggestions(t *testing.T) {
+	newRoot := func() (*cobra.Command, *bytes.Buffer) {
+		root := &cobra.Command{Use: "cortex", SilenceErrors: true, SilenceUsage: true}
+		root.PersistentFlags().String("config", "", "")
+		run := &cobra.Command{Use: "run", RunE: func(*cobra.Command, []string) error { return nil }}
+		run.Flags().String("workflow", "", "")
+		run.Flags().Bool("parallel", false, "")
+		run.Flags().Bool("internal-trace", false, "")
+		run.Flags().MarkHidden("internal-trace")
+		store := &cobra.Command{Use: "store", Short: "Manage the run store"}
+		for _, name := range []string{"migrate", "gc"} {
+			store.AddCommand(&cobra.Command{Use: name, RunE: func(*cobra.Command, []string) error { return nil }})
+		}
+		root.AddCommand(run, store)
+		enableSuggestions(root)
+		var out bytes.Buffer
+		root.SetOut(&out)
+		root.SetErr(&out)
+		return root, &out
+	}
+
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"rnu"}, `unknown command "rnu" for "cortex"; did you mean "run"?`},
+		{[]string{"store", "migrat"}, `unknown command "migrat" for "cortex store"; did you mean "migrate"?`},
+		{[]string{"run", "--workflo", "ci"}, `unknown flag: --workflo; did you mean "--workflow"?`},
+		{[]string{"run", "--confg", "a.yml"}, `unknown flag: --confg; did you mean "--config"?`},
+		{[]string{"run", "--internal-trac"}, `unknown flag: --internal-trac`},
+		{[]string{"xyzzy"}, `unknown command "xyzzy" for "cortex"`},
+		{[]string{"run", "--zzzzzz"}, `unknown flag: --zzzzzz`},
+	}
+	for _, tt := range tests {
+		root, out := newRoot()
+		root.SetArgs(tt.args)
+		if err := root.Execute(); err == nil || err.Error() != tt.want {
+			t.Errorf("cortex %v: error = %v, want %s", tt.args, err, tt.want)
+		}
+		if strings.Contains(out.String(), "Did you mean") {
+			t.Errorf("cortex %v: printed cobra's suggestions too:\n%s", tt.args, out)
+		}
+	}
+
+	// A bare command group shows its help, as before
+	root, out := newRoot()
+	root.SetArgs([]string{"store"})
+	if err := root.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	if help := out.String(); !strings.Contains(help, "Manage the run store") || !strings.Contains(help, "migrate") {
+		t.Errorf("cortex store printed\n%s\nwant its help", help)
+	}
+}
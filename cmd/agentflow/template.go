@@ -0,0 +1,320 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/adityaraj/agentflow/internal/config"
+	"github.com/adityaraj/agentflow/internal/contextpack"
+	"github.com/adityaraj/agentflow/internal/git"
+	"github.com/adityaraj/agentflow/internal/glob"
+	"github.com/adityaraj/agentflow/internal/proc"
+	"github.com/adityaraj/agentflow/internal/state"
+	"github.com/adityaraj/agentflow/internal/ui"
+	"github.com/adityaraj/agentflow/pkg/prompt"
+)
+
+// newTemplateCmd creates the `template` command group for debugging prompt
+// templates without running agents.
+func newTemplateCmd() *cobra.Command {
+	templateCmd := &cobra.Command{
+		Use:   "template",
+		Short: "Debug prompt templates",
+		Long:  "Renders prompt templates with chosen values, without running any agents",
+	}
+
+	renderCmd := &cobra.Command{
+		Use:   "render [task]",
+		Short: "Print a task's prompt with its placeholders filled in",
+		Long: `Renders a task's prompt (with the preamble) or any template file, filling
+placeholders from a past run's outputs and --var values, and prints the result.
+
+Placeholders left unfilled are listed on stderr.`,
+		Example: `  cortex template render implement --run latest
+  cortex template render review --var outputs.analyze=@analysis.md
+  cortex template render --template prompts/fix.md --var task.name=fix`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: renderTemplateCmd,
+	}
+
+	renderCmd.Flags().StringArrayVarP(&configFiles, "file", "f", nil, "Path to Cortexfile")
+	renderCmd.Flags().String("template", "", "Render this template file instead of a task's prompt")
+	renderCmd.Flags().String("run", "", "Fill {{outputs.X}} from this run ('latest' for the most recent)")
+	renderCmd.Flags().StringArray("var", nil, "Set a placeholder: name=value, or name=@file to read the value from a file")
+
+	testCmd := &cobra.Command{
+		Use:   "test [files...]",
+		Short: "Check rendered templates against assertion files",
+		Long: `Renders each case in the given assertion files and checks the result against
+its expect, contains, and not_contains entries. Without arguments, runs every
+*.template-test.yml (or .yaml) file under the current directory.
+
+Exits with an error if any case fails, so it can gate CI.`,
+		Example: `  cortex template test
+  cortex template test prompts/review.template-test.yml`,
+		SilenceUsage: true, // Failing cases aren't usage errors
+		RunE:         testTemplatesCmd,
+	}
+	testCmd.Flags().StringArrayVarP(&configFiles, "file", "f", nil, "Path to Cortexfile (for cases that name a task)")
+
+	templateCmd.AddCommand(renderCmd)
+	templateCmd.AddCommand(testCmd)
+	return templateCmd
+}
+
+func renderTemplateCmd(cmd *cobra.Command, args []string) error {
+	templateFile, _ := cmd.Flags().GetString("template")
+	runID, _ := cmd.Flags().GetString("run")
+	assignments, _ := cmd.Flags().GetStringArray("var")
+
+	if len(args) == 0 && templateFile == "" {
+		return fmt.Errorf("name a task to render or pass --template")
+	}
+
+	cwd, err := os.Getwd()
+	if err != nil {
+		return fmt.Errorf("failed to get working directory: %w", err)
+	}
+	project := filepath.Base(cwd)
+	vars := config.TemplateVars{}
+
+	var tpl string
+	if templateFile != "" {
+		content, err := os.ReadFile(templateFile)
+		if err != nil {
+			return fmt.Errorf("failed to read template: %w", err)
+		}
+		tpl = string(content)
+	} else {
+		var taskVars map[string]string
+		if tpl, taskVars, err = taskTemplate(args[0]); err != nil {
+			return err
+		}
+		for k, v := range taskVars {
+			vars[k] = v
+		}
+	}
+
+	if runID != "" {
+		if err := addRunOutputs(vars, project, runID); err != nil {
+			return err
+		}
+	}
+	if strings.Contains(tpl, "{{runs.last_success.") {
+		addLastSuccessOutputs(vars, project)
+	}
+	addMetaVars(vars, tpl)
+	for _, a := range assignments {
+		if err := addVar(vars, a); err != nil {
+			return err
+		}
+	}
+
+	rendered := config.RenderTemplate(tpl, vars)
+	fmt.Print(rendered)
+	if !strings.HasSuffix(rendered, "\n") {
+		fmt.Println()
+	}
+
+	if unfilled := config.Placeholders(rendered); len(unfilled) > 0 {
+		fmt.Fprintf(os.Stderr, "unfilled placeholders: %s\n", strings.Join(unfilled, ", "))
+	}
+	return nil
+}
+
+// taskTemplate returns the prompt a task's agent would receive before
+// placeholders are filled (the preamble, then the prompt) and the task's
+// own variables. It satisfies prompt.TaskResolver.
+func taskTemplate(name string) (string, map[string]string, error) {
+	paths, err := resolveConfigFiles()
+	if err != nil {
+		return "", nil, err
+	}
+	if len(paths) == 0 {
+		return "", nil, fmt.Errorf("no Cortexfile found")
+	}
+	cfg, err := loadWorkflow(paths[0])
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to load config: %w", err)
+	}
+
+	task, ok := cfg.Tasks[name]
+	if !ok {
+		names := make([]string, 0, len(cfg.Tasks))
+		for n := range cfg.Tasks {
+			names = append(names, n)
+		}
+		if suggestions := config.DidYouMean(name, names); suggestions != "" {
+			return "", nil, fmt.Errorf("task %q not found in %s; did you mean %s?", name, paths[0], suggestions)
+		}
+		return "", nil, fmt.Errorf("task %q not found in %s", name, paths[0])
+	}
+	vars := map[string]string{config.MetaTaskName: name, config.MetaTaskAgent: task.Agent}
+	for k, v := range cfg.Variables {
+		vars["vars."+k] = v
+	}
+
+	if task.Compress != nil {
+		return task.Compress.Input, vars, nil
+	}
+	if cfg.Agents[task.Agent].Tool == "shell" {
+		if task.CommandArgs != nil {
+			return proc.FormatArgs(task.CommandArgs), vars, nil
+		}
+		return task.Command, vars, nil
+	}
+	prompt := task.Prompt
+	if instructions := strings.TrimSpace(cfg.Agents[task.Agent].Instructions); instructions != "" {
+		prompt = instructions + "\n\n" + prompt
+	}
+	if preamble := strings.TrimSpace(cfg.Preamble); preamble != "" {
+		prompt = preamble + "\n\n" + prompt
+	}
+	return prompt, vars, nil
+}
+
+func testTemplatesCmd(cmd *cobra.Command, args []string) error {
+	files := args
+	if len(files) == 0 {
+		found, err := findTemplateTests(".")
+		if err != nil {
+			return err
+		}
+		if len(found) == 0 {
+			return fmt.Errorf("no *.template-test.yml files found")
+		}
+		files = found
+	}
+
+	passed, failed := 0, 0
+	for _, file := range files {
+		suite, err := prompt.LoadSuite(file)
+		if err != nil {
+			return err
+		}
+		fmt.Println(ui.BoldText(file))
+		for _, r := range suite.Run(taskTemplate) {
+			if r.Passed() {
+				passed++
+				fmt.Printf("  %s %s\n", ui.GreenText("✓"), r.Name)
+				continue
+			}
+			failed++
+			fmt.Printf("  %s %s\n", ui.RedText("✗"), r.Name)
+			for _, f := range r.Failures {
+				fmt.Printf("      %s\n", strings.ReplaceAll(f, "\n", "\n      "))
+			}
+		}
+	}
+
+	fmt.Println()
+	if failed > 0 {
+		return fmt.Errorf("%d of %d template tests failed", failed, passed+failed)
+	}
+	ui.Success("%d template tests passed", passed)
+	return nil
+}
+
+// findTemplateTests lists the template assertion files under dir.
+func findTemplateTests(dir string) ([]string, error) {
+	files, err := contextpack.ListFiles(dir)
+	if err != nil {
+		return nil, err
+	}
+	var tests []string
+	for _, f := range files {
+		if glob.Match("*.template-test.yml", f) || glob.Match("*.template-test.yaml", f) {
+			tests = append(tests, filepath.Join(dir, filepath.FromSlash(f)))
+		}
+	}
+	return tests, nil
+}
+
+// addRunOutputs sets {{outputs.X}} and {{run.id}} from a past run of project.
+func addRunOutputs(vars config.TemplateVars, project, runID string) error {
+	if runID == "latest" {
+		sessions, err := state.ListSessions(state.SessionFilter{Project: project, Limit: 1})
+		if err != nil {
+			return err
+		}
+		if len(sessions) == 0 {
+			return fmt.Errorf("no runs found for project %q", project)
+		}
+		runID = sessions[0].RunID
+	}
+	runID = strings.TrimPrefix(runID, "run-")
+
+	run, err := state.GetSession(project, runID)
+	if err != nil {
+		return fmt.Errorf("cannot load run %s: %w", runID, err)
+	}
+	vars[config.MetaRunID] = run.RunID
+	vars[config.MetaRunStartTime] = run.StartTime.Format(time.RFC3339)
+	for _, task := range run.Tasks {
+		vars["outputs."+task.TaskName] = task.Stdout
+	}
+	return nil
+}
+
+// addLastSuccessOutputs sets {{runs.last_success.outputs.X}} from the
+// project's most recent successful run, if there is one.
+func addLastSuccessOutputs(vars config.TemplateVars, project string) {
+	sessions, err := state.ListSessions(state.SessionFilter{Project: project})
+	if err != nil {
+		return
+	}
+	for _, s := range sessions {
+		if !s.Success {
+			continue
+		}
+		run, err := state.GetSession(project, s.RunID)
+		if err != nil {
+			continue
+		}
+		for _, task := range run.Tasks {
+			vars["runs.last_success.outputs."+task.TaskName] = task.Stdout
+		}
+		return
+	}
+}
+
+// addMetaVars sets the {{git.X}} and {{env.X}} values tpl references, as a
+// run started now would see them.
+func addMetaVars(vars config.TemplateVars, tpl string) {
+	for _, name := range config.ExtractMetaVars(tpl) {
+		if _, ok := vars[name]; ok {
+			continue
+		}
+		switch {
+		case name == config.MetaGitBranch:
+			vars[name], _ = git.CurrentBranch(".")
+		case name == config.MetaGitCommit:
+			vars[name], _ = git.HeadCommit(".")
+		case strings.HasPrefix(name, "env."):
+			vars[name] = os.Getenv(strings.TrimPrefix(name, "env."))
+		}
+	}
+}
+
+// addVar parses a --var assignment: name=value, or name=@file.
+func addVar(vars config.TemplateVars, assignment string) error {
+	name, value, ok := strings.Cut(assignment, "=")
+	name = strings.Trim(strings.TrimSpace(name), "{}")
+	if !ok || name == "" {
+		return fmt.Errorf("invalid --var %q: use name=value or name=@file", assignment)
+	}
+	if path, isFile := strings.CutPrefix(value, "@"); isFile {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("--var %s: %w", name, err)
+		}
+		value = string(content)
+	}
+	vars[name] = value
+	return nil
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/term"
+
+	"github.com/adityaraj/agentflow/internal/runtime"
+	"github.com/adityaraj/agentflow/internal/state"
+	"github.com/adityaraj/agentflow/internal/ui"
+)
+
+// offerWorkspacePatch saves the changes a run made in its workspace to
+// the run directory and, if it succeeded, offers to apply them to the
+// checkout; without a terminal to ask on, it prints how to.
+func offerWorkspacePatch(ws *runtime.Workspace, store *state.RunStore, success bool) {
+	patch, err := ws.Patch()
+	if err != nil {
+		ui.Warning("Failed to collect the workspace's changes: %s", err)
+		return
+	}
+	if patch == "" {
+		ui.Info("The run changed no files")
+		return
+	}
+	if err := store.WriteRunFile(runtime.WorkspacePatchFile, []byte(patch)); err != nil {
+		ui.Warning("Failed to save the workspace's changes: %s", err)
+		return
+	}
+	path := filepath.Join(store.RunDir(), runtime.WorkspacePatchFile)
+	apply := "cortex apply " + store.RunID()
+	if !success {
+		ui.Info("Changes of the failed run saved to %s; to apply them anyway: %s", path, apply)
+		return
+	}
+
+	interactive := term.IsTerminal(int(os.Stdin.Fd())) && !quietOutput && !summaryOutput && outputFormat == "text"
+	if !interactive {
+		ui.Info("Changes saved to %s; apply them with: %s", path, apply)
+		return
+	}
+	fmt.Printf("Apply the run's changes to %s? [y/N] ", ws.Root)
+	var answer string
+	fmt.Scanln(&answer)
+	if !strings.EqualFold(strings.TrimSpace(answer), "y") {
+		ui.Info("Not applied; apply them later with: %s", apply)
+		return
+	}
+	if err := ws.Apply(patch); err != nil {
+		ui.Error("Failed to apply the changes: %s", err)
+		ui.Info("They are saved in %s", path)
+		return
+	}
+	ui.Success("Applied the run's changes to %s", ws.Root)
+}
@@ -0,0 +1,193 @@
+// Package alert opens incidents in PagerDuty or Opsgenie when scheduled
+// runs keep failing. Runs of each workflow (a "schedule", as cron or CI
+// runs them) share a dedup key, so a failure streak is one incident that
+// is resolved by the next successful run.
+package alert
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/adityaraj/agentflow/internal/config"
+	"github.com/adityaraj/agentflow/internal/network"
+)
+
+// Default settings.
+const (
+	DefaultAfter        = 3
+	DefaultPagerDutyEnv = "CORTEX_PAGERDUTY_KEY"
+	DefaultOpsgenieEnv  = "CORTEX_OPSGENIE_KEY"
+	DefaultPagerDutyURL = "https://events.pagerduty.com/v2/enqueue"
+	DefaultOpsgenieURL  = "https://api.opsgenie.com/v2/alerts"
+)
+
+// timeout bounds each request to a provider.
+const timeout = 10 * time.Second
+
+// Incident describes a failure streak.
+type Incident struct {
+	DedupKey string            // Same for every run of the schedule
+	Summary  string            // One line, e.g. "demo: nightly failed 3 runs in a row"
+	Source   string            // Where the runs happen, e.g. the host name
+	Details  map[string]string // Run ID, results directory, failed tasks, ...
+}
+
+// DedupKey identifies the incidents of schedule in project.
+func DedupKey(project, schedule string) string {
+	return "cortex/" + project + "/" + schedule
+}
+
+// Schedule names the runs of a workflow, optionally limited to a group:
+// "default" for the top-level tasks, else the workflow name, with
+// ":<group>" appended for group runs.
+func Schedule(workflow, group string) string {
+	if workflow == "" {
+		workflow = "default"
+	}
+	if group != "" {
+		workflow += ":" + group
+	}
+	return workflow
+}
+
+// After returns the number of consecutive failed runs that raise cfg's
+// alert.
+func After(cfg config.AlertConfig) int {
+	if cfg.After > 0 {
+		return cfg.After
+	}
+	return DefaultAfter
+}
+
+// Validate checks cfg, so mistakes show up before a run rather than when
+// an alert is due.
+func Validate(cfg config.AlertConfig) error {
+	switch cfg.Provider {
+	case config.AlertPagerDuty, config.AlertOpsgenie:
+	default:
+		return fmt.Errorf("alert: invalid provider %q: use pagerduty or opsgenie", cfg.Provider)
+	}
+	if cfg.After < 0 {
+		return fmt.Errorf("alert: after must be positive, got %d", cfg.After)
+	}
+	if cfg.URL != "" {
+		if u, err := url.Parse(cfg.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("alert: invalid url %q", cfg.URL)
+		}
+	}
+	return nil
+}
+
+// Trigger opens inc, or adds to the open incident with its dedup key.
+func Trigger(cfg config.AlertConfig, inc Incident, lookup func(string) (string, bool)) error {
+	key, err := apiKey(cfg, lookup)
+	if err != nil {
+		return err
+	}
+	if cfg.Provider == config.AlertOpsgenie {
+		message := inc.Summary
+		if len(message) > 130 { // Opsgenie's limit
+			message = message[:127] + "..."
+		}
+		return post(endpoint(cfg), "GenieKey "+key, map[string]any{
+			"message":     message,
+			"alias":       inc.DedupKey,
+			"description": inc.Summary,
+			"source":      inc.Source,
+			"details":     inc.Details,
+			"tags":        []string{"cortex"},
+		})
+	}
+	return post(endpoint(cfg), "", map[string]any{
+		"routing_key":  key,
+		"event_action": "trigger",
+		"dedup_key":    inc.DedupKey,
+		"payload": map[string]any{
+			"summary":        inc.Summary,
+			"source":         inc.Source,
+			"severity":       "error",
+			"component":      "cortex",
+			"custom_details": inc.Details,
+		},
+	})
+}
+
+// Resolve closes the incident with dedupKey, if one is open.
+func Resolve(cfg config.AlertConfig, dedupKey, source string, lookup func(string) (string, bool)) error {
+	key, err := apiKey(cfg, lookup)
+	if err != nil {
+		return err
+	}
+	if cfg.Provider == config.AlertOpsgenie {
+		return post(endpoint(cfg)+"/"+url.PathEscape(dedupKey)+"/close?identifierType=alias", "GenieKey "+key,
+			map[string]any{"source": source, "note": "A later run succeeded"})
+	}
+	return post(endpoint(cfg), "", map[string]any{
+		"routing_key":  key,
+		"event_action": "resolve",
+		"dedup_key":    dedupKey,
+	})
+}
+
+// apiKey reads cfg's routing or API key from the environment.
+func apiKey(cfg config.AlertConfig, lookup func(string) (string, bool)) (string, error) {
+	name := cfg.KeyEnv
+	if name == "" {
+		name = DefaultPagerDutyEnv
+		if cfg.Provider == config.AlertOpsgenie {
+			name = DefaultOpsgenieEnv
+		}
+	}
+	key, _ := lookup(name)
+	if key == "" {
+		return "", fmt.Errorf("no %s key: set %s", cfg.Provider, name)
+	}
+	return key, nil
+}
+
+// endpoint returns cfg's API URL.
+func endpoint(cfg config.AlertConfig) string {
+	switch {
+	case cfg.URL != "":
+		return cfg.URL
+	case cfg.Provider == config.AlertOpsgenie:
+		return DefaultOpsgenieURL
+	}
+	return DefaultPagerDutyURL
+}
+
+// post sends body as JSON to u, with an Authorization header if auth is set.
+func post(u, auth string, body any) error {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, "POST", u, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Cortex/1.0")
+	if auth != "" {
+		req.Header.Set("Authorization", auth)
+	}
+
+	resp, err := network.Client(timeout).Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s returned status %d: %s", req.URL.Host, resp.StatusCode, bytes.TrimSpace(msg))
+	}
+	return nil
+}
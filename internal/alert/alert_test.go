@@ -0,0 +1,149 @@
+package alert
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/adityaraj/agentflow/internal/config"
+)
+
+// request is a request received by the fake provider.
+type request struct {
+	Path string
+	Auth string
+	Body map[string]any
+}
+
+func fakeProvider(t *testing.T, status int) (*httptest.Server, *[]request) {
+	var received []request
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req := request{Path: r.URL.RequestURI(), Auth: r.Header.Get("Authorization")}
+		if err := json.NewDecoder(r.Body).Decode(&req.Body); err != nil {
+			t.Errorf("invalid body: %v", err)
+		}
+		received = append(received, req)
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &received
+}
+
+func TestPagerDuty(t *testing.T) {
+	srv, received := fakeProvider(t, http.StatusAccepted)
+	cfg := config.AlertConfig{Provider: config.AlertPagerDuty, URL: srv.URL}
+	lookup := func(name string) (string, bool) { return "routing-key", name == DefaultPagerDutyEnv }
+
+	inc := Incident{DedupKey: DedupKey("demo", "nightly"), Summary: "demo: nightly failed 3 runs in a row", Source: "ci"}
+	if err := Trigger(cfg, inc, lookup); err != nil {
+		t.Fatalf("Trigger() error = %v", err)
+	}
+	if err := Resolve(cfg, inc.DedupKey, "ci", lookup); err != nil {
+		t.Fatalf("Resolve() error = %v", err)
+	}
+
+	if len(*received) != 2 {
+		t.Fatalf("received %d requests, want 2", len(*received))
+	}
+	trigger, resolve := (*received)[0].Body, (*received)[1].Body
+	if trigger["event_action"] != "trigger" || trigger["dedup_key"] != "cortex/demo/nightly" || trigger["routing_key"] != "routing-key" {
+		t.Errorf("trigger = %v", trigger)
+	}
+	if summary := trigger["payload"].(map[string]any)["summary"]; summary != inc.Summary {
+		t.Errorf("summary = %v", summary)
+	}
+	if resolve["event_action"] != "resolve" || resolve["dedup_key"] != "cortex/demo/nightly" {
+		t.Errorf("resolve = %v", resolve)
+	}
+}
+
+func TestOpsgenie(t *testing.T) {
+	srv, received := fakeProvider(t, http.StatusAccepted)
+	cfg := config.AlertConfig{Provider: config.AlertOpsgenie, URL: srv.URL + "/v2/alerts", KeyEnv: "OG_KEY"}
+	lookup := func(name string) (string, bool) { return "api-key", name == "OG_KEY" }
+
+	inc := Incident{DedupKey: DedupKey("demo", "default"), Summary: strings.Repeat("x", 200)}
+	if err := Trigger(cfg, inc, lookup); err != nil {
+		t.Fatalf("Trigger() error = %v", err)
+	}
+	if err := Resolve(cfg, inc.DedupKey, "ci", lookup); err != nil {
+		t.Fatalf("Resolve() error = %v", err)
+	}
+
+	create, closing := (*received)[0], (*received)[1]
+	if create.Auth != "GenieKey api-key" || create.Body["alias"] != "cortex/demo/default" {
+		t.Errorf("create = %+v", create)
+	}
+	if msg := create.Body["message"].(string); len(msg) != 130 {
+		t.Errorf("message has %d characters, want it cut to 130", len(msg))
+	}
+	if closing.Path != "/v2/alerts/cortex%2Fdemo%2Fdefault/close?identifierType=alias" {
+		t.Errorf("close path = %s", closing.Path)
+	}
+}
+
+func TestTriggerErrors(t *testing.T) {
+	srv, _ := fakeProvider(t, http.StatusBadRequest)
+	cfg := config.AlertConfig{Provider: config.AlertPagerDuty, URL: srv.URL}
+	none := func(string) (string, bool) { return "", false }
+	if err := Trigger(cfg, Incident{}, none); err == nil || !strings.Contains(err.Error(), "set CORTEX_PAGERDUTY_KEY") {
+		t.Errorf("Trigger() without key error = %v", err)
+	}
+	key := func(string) (string, bool) { return "k", true }
+	if err := Trigger(cfg, Incident{}, key); err == nil || !strings.Contains(err.Error(), "status 400") {
+		t.Errorf("Trigger() error = %v, want status 400", err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		cfg  config.AlertConfig
+		want string
+	}{
+		{config.AlertConfig{Provider: config.AlertPagerDuty}, ""},
+		{config.AlertConfig{Provider: config.AlertOpsgenie, After: 2, URL: "https://api.eu.opsgenie.com/v2/alerts"}, ""},
+		{config.AlertConfig{Provider: "slack"}, `invalid provider "slack"`},
+		{config.AlertConfig{Provider: config.AlertPagerDuty, After: -1}, "after must be positive"},
+		{config.AlertConfig{Provider: config.AlertPagerDuty, URL: "events.pagerduty.com"}, "invalid url"},
+	}
+	for _, tt := range tests {
+		err := Validate(tt.cfg)
+		if (tt.want == "") != (err == nil) || (err != nil && !strings.Contains(err.Error(), tt.want)) {
+			t.Errorf("Validate(%+v) error = %v, want %q", tt.cfg, err, tt.want)
+		}
+	}
+}
+
+func TestRecordRun(t *testing.T) {
+	dir := t.TempDir()
+	runs := []struct {
+		schedule      string
+		success       bool
+		before, after int
+	}{
+		{"nightly", false, 0, 1},
+		{"nightly", false, 1, 2},
+		{"weekly", false, 0, 1}, // Schedules have separate streaks
+		{"nightly", false, 2, 3},
+		{"nightly", true, 3, 0},
+		{"nightly", false, 0, 1},
+	}
+	for i, r := range runs {
+		before, after, err := RecordRun(dir, r.schedule, "run", r.success)
+		if err != nil {
+			t.Fatalf("RecordRun() error = %v", err)
+		}
+		if before != r.before || after != r.after {
+			t.Errorf("run %d: RecordRun() = %d, %d, want %d, %d", i, before, after, r.before, r.after)
+		}
+	}
+
+	if got := Schedule("", ""); got != "default" {
+		t.Errorf("Schedule() = %q", got)
+	}
+	if got := Schedule("nightly", "quick"); got != "nightly:quick" {
+		t.Errorf("Schedule() = %q", got)
+	}
+}
@@ -0,0 +1,54 @@
+package alert
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// StreakFile holds the failure streaks of a project's schedules, kept
+// beside the project's runs.
+const StreakFile = "alerts.json"
+
+// streak is the state of one schedule.
+type streak struct {
+	Failures  int    `json:"failures"`    // Consecutive failed runs
+	LastRunID string `json:"last_run_id"` // Latest run recorded
+}
+
+// RecordRun counts a run of schedule in the streak file in dir, returning
+// the number of consecutive failed runs before and after it: a success
+// resets the count.
+func RecordRun(dir, schedule, runID string, success bool) (before, after int, err error) {
+	path := filepath.Join(dir, StreakFile)
+	streaks := make(map[string]streak)
+	data, err := os.ReadFile(path)
+	switch {
+	case err == nil:
+		if err := json.Unmarshal(data, &streaks); err != nil {
+			return 0, 0, fmt.Errorf("invalid %s: %w", path, err)
+		}
+	case !os.IsNotExist(err):
+		return 0, 0, err
+	}
+
+	s := streaks[schedule]
+	before = s.Failures
+	if success {
+		s.Failures = 0
+	} else {
+		s.Failures++
+	}
+	s.LastRunID = runID
+	streaks[schedule] = s
+
+	data, err = json.MarshalIndent(streaks, "", "  ")
+	if err != nil {
+		return 0, 0, err
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return 0, 0, err
+	}
+	return before, s.Failures, nil
+}
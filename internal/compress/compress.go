@@ -0,0 +1,215 @@
+// Package compress shortens long text, such as build logs or the output
+// of an earlier task, to a token budget by keeping its most telling lines:
+// errors and warnings and the lines around them, headings, the opening and
+// the end. Repeated lines are counted instead of repeated, and what's left
+// out is marked, so a reader knows the text was cut.
+package compress
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/adityaraj/agentflow/internal/tokens"
+)
+
+// DefaultMaxTokens is the budget of a compress step without max_tokens.
+const DefaultMaxTokens = 2000
+
+// Line scores. A line's score decides which lines are kept when they
+// don't all fit; the highest are kept first.
+const (
+	scoreSignal  = 10  // Errors, failures, warnings
+	scoreHeading = 4   // Markdown headings, "Section:" lines, file:line locations
+	scoreEdge    = 6   // The first and last lines, fading further in
+	scoreNearby  = 0.4 // Share of a signal line's score its neighbours get
+	scoreRepeat  = -3  // Lines seen before, up to numbers
+)
+
+// edgeLines is how many lines from either end get a share of scoreEdge.
+const edgeLines = 8
+
+var (
+	signalRegex   = regexp.MustCompile(`(?i)\b(error|errors|fail|failed|failure|fatal|panic|exception|traceback|warning|warn|denied|refused|timeout|timed out|undefined|cannot|can't|unable)\b|✗|✘`)
+	headingRegex  = regexp.MustCompile(`^\s*(#{1,6}\s|[A-Z][\w ]{0,40}:\s*$|={3,}|-{3,}\s*\w)`)
+	locationRegex = regexp.MustCompile(`[\w./-]+\.\w+:\d+`)
+	digitsRegex   = regexp.MustCompile(`\d+`)
+)
+
+// line is a line of the text being compressed.
+type line struct {
+	text   string
+	count  int // Times the line occurs in a row (see collapseRepeats)
+	score  float64
+	tokens int
+	kept   bool
+}
+
+// Extract shortens text to about maxTokens tokens (estimated), keeping
+// whole lines in their original order and marking the lines left out.
+// Text within the budget is returned unchanged.
+func Extract(text string, maxTokens int) string {
+	if maxTokens <= 0 {
+		maxTokens = DefaultMaxTokens
+	}
+	if tokens.Count(text) <= maxTokens {
+		return text
+	}
+
+	lines := collapseRepeats(strings.Split(strings.TrimRight(text, "\n"), "\n"))
+	if out := render(lines, true); tokens.Count(out) <= maxTokens {
+		return out
+	}
+
+	// A single line may take at most a quarter of the budget
+	for i := range lines {
+		lines[i].text = cutLine(lines[i].text, max(maxTokens/4, 8))
+	}
+	score(lines)
+
+	// Keep the best lines while they fit, leaving room for the markers of
+	// the gaps they leave
+	marker := tokens.Count(omitted(1000))
+	order := make([]int, len(lines))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return lines[order[a]].score > lines[order[b]].score
+	})
+	used := marker // The gap before the first kept line, or after the last
+	for _, i := range order {
+		l := &lines[i]
+		if strings.TrimSpace(l.text) == "" {
+			continue // Blank lines only separate kept ones (see render)
+		}
+		cost := l.tokens + marker
+		if used+cost > maxTokens {
+			continue // A shorter line may still fit
+		}
+		l.kept = true
+		used += cost
+	}
+	return render(lines, false)
+}
+
+// collapseRepeats merges runs of identical lines into one line with a
+// count, and trims trailing whitespace.
+func collapseRepeats(texts []string) []line {
+	var lines []line
+	for _, t := range texts {
+		t = strings.TrimRight(t, " \t\r")
+		if n := len(lines); n > 0 && lines[n-1].text == t {
+			lines[n-1].count++
+			continue
+		}
+		lines = append(lines, line{text: t, count: 1})
+	}
+	return lines
+}
+
+// score sets the score and token count of each line.
+func score(lines []line) {
+	seen := make(map[string]bool)
+	signal := make([]bool, len(lines))
+	for i := range lines {
+		l := &lines[i]
+		l.tokens = tokens.Count(display(*l) + "\n")
+		if strings.TrimSpace(l.text) == "" {
+			continue
+		}
+		if signalRegex.MatchString(l.text) {
+			l.score += scoreSignal
+			signal[i] = true
+		}
+		if headingRegex.MatchString(l.text) || locationRegex.MatchString(l.text) {
+			l.score += scoreHeading
+		}
+		if edge := min(i, len(lines)-1-i); edge < edgeLines {
+			l.score += scoreEdge * float64(edgeLines-edge) / edgeLines
+		}
+		key := digitsRegex.ReplaceAllString(strings.TrimSpace(l.text), "0")
+		if seen[key] {
+			l.score += scoreRepeat
+		}
+		seen[key] = true
+		// Shorter lines say more per token
+		l.score -= float64(l.tokens) / 100
+	}
+
+	// The lines around a signal explain it
+	for i, s := range signal {
+		if !s {
+			continue
+		}
+		for _, j := range []int{i - 2, i - 1, i + 1, i + 2} {
+			if j >= 0 && j < len(lines) && !signal[j] {
+				share := scoreSignal * scoreNearby
+				if j == i-2 || j == i+2 {
+					share /= 2
+				}
+				lines[j].score += share
+			}
+		}
+	}
+}
+
+// render joins lines, all of them or only those kept, marking the gaps
+// between kept lines. Blank lines between two kept lines are kept too.
+func render(lines []line, all bool) string {
+	var b strings.Builder
+	gap := 0
+	for i, l := range lines {
+		keep := all || l.kept
+		if !keep && strings.TrimSpace(l.text) == "" && gap == 0 && i > 0 && i+1 < len(lines) && lines[i+1].kept {
+			keep = true
+		}
+		if !keep {
+			gap += l.count
+			continue
+		}
+		if gap > 0 {
+			b.WriteString(omitted(gap) + "\n")
+			gap = 0
+		}
+		b.WriteString(display(l) + "\n")
+	}
+	if gap > 0 {
+		b.WriteString(omitted(gap) + "\n")
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// display returns a line as rendered, with its repeat count.
+func display(l line) string {
+	if l.count > 1 {
+		return fmt.Sprintf("%s [repeated %d times]", l.text, l.count)
+	}
+	return l.text
+}
+
+// omitted returns the marker of n left-out lines.
+func omitted(n int) string {
+	if n == 1 {
+		return "[... 1 line omitted ...]"
+	}
+	return fmt.Sprintf("[... %d lines omitted ...]", n)
+}
+
+// cutLine shortens a line longer than maxTokens, keeping its start.
+func cutLine(text string, maxTokens int) string {
+	if tokens.Count(text) <= maxTokens {
+		return text
+	}
+	// Binary search for the longest prefix that fits, with the marker
+	const cut = " [...]"
+	n := sort.Search(len(text), func(n int) bool {
+		return tokens.Count(text[:n]+cut) > maxTokens
+	}) - 1
+	for n > 0 && !utf8.RuneStart(text[n]) {
+		n--
+	}
+	return text[:max(n, 0)] + cut
+}
@@ -0,0 +1,58 @@
+package compress
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/adityaraj/agentflow/internal/tokens"
+)
+
+func TestExtract(t *testing.T) {
+	var b strings.Builder
+	b.WriteString("=== RUN TestSuite\n")
+	for i := 0; i < 300; i++ {
+		fmt.Fprintf(&b, "ok   example.com/pkg/module%d\t0.%03ds\n", i, i)
+		if i == 150 {
+			b.WriteString("--- FAIL: TestParse (0.01s)\n")
+			b.WriteString("    parser_test.go:42: got 3 tokens, want 4\n")
+		}
+	}
+	b.WriteString("FAIL\texample.com/pkg/parser\n")
+	log := b.String()
+
+	got := Extract(log, 200)
+	if n := tokens.Count(got); n > 200 {
+		t.Errorf("Extract() = %d tokens, want at most 200", n)
+	}
+	for _, want := range []string{"=== RUN TestSuite", "--- FAIL: TestParse", "parser_test.go:42", "FAIL\texample.com/pkg/parser", "lines omitted ...]"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("Extract() lost %q:\n%s", want, got)
+		}
+	}
+	// Kept lines stay in order
+	if strings.Index(got, "TestSuite") > strings.Index(got, "TestParse") {
+		t.Errorf("Extract() reordered lines:\n%s", got)
+	}
+
+	// Text within the budget is unchanged
+	if got := Extract("short\n\n\ntext", 100); got != "short\n\n\ntext" {
+		t.Errorf("Extract(short) = %q", got)
+	}
+}
+
+func TestExtract_Repeats(t *testing.T) {
+	text := "start\n" + strings.Repeat("Downloading dependencies...\n", 500) + "done"
+	got := Extract(text, 50)
+	if want := "start\nDownloading dependencies... [repeated 500 times]\ndone"; got != want {
+		t.Errorf("Extract() = %q, want %q", got, want)
+	}
+}
+
+func TestCutLine(t *testing.T) {
+	long := strings.Repeat("é word ", 500)
+	got := cutLine(long, 40)
+	if n := tokens.Count(got); n > 40 || !strings.HasSuffix(got, " [...]") || !strings.HasPrefix(long, strings.TrimSuffix(got, " [...]")) {
+		t.Errorf("cutLine() = %q (%d tokens)", got, n)
+	}
+}
@@ -0,0 +1,74 @@
+package config
+
+import "errors"
+
+// Error codes identify each kind of configuration error and warning, so
+// scripts and support docs can refer to a failure without matching its
+// message. Codes are stable: once released, a code keeps its meaning and is
+// never reused, even if the check that reports it is removed.
+const (
+	CodeNoAgents               = "CORTEX-VAL-001" // No agents defined
+	CodeNoTasks                = "CORTEX-VAL-002" // No tasks defined
+	CodeInvalidName            = "CORTEX-VAL-003" // Agent or task name empty or with disallowed characters
+	CodeUnsupportedTool        = "CORTEX-VAL-004" // Agent uses a tool Cortex doesn't support
+	CodeMissingTool            = "CORTEX-VAL-005" // Agent has no tool
+	CodeNegativeValue          = "CORTEX-VAL-006" // A count or limit is negative
+	CodeInvalidInterchangeable = "CORTEX-VAL-007" // An interchangeable group can't be used
+	CodeUndefinedAgent         = "CORTEX-VAL-008" // Reference to an agent that isn't defined
+	CodeMissingAgent           = "CORTEX-VAL-009" // Task has no agent
+	CodeNoPrompt               = "CORTEX-VAL-010" // Task has no prompt
+	CodePromptFileNotFound     = "CORTEX-VAL-011" // Task's prompt file doesn't exist
+	CodeConflictingFields      = "CORTEX-VAL-012" // Fields that can't be used together
+	CodeMissingCommand         = "CORTEX-VAL-013" // Shell task has no command to run
+	CodeMissingVerify          = "CORTEX-VAL-014" // Option needs a 'verify' command
+	CodeWriteOnly              = "CORTEX-VAL-015" // Option only supported on write tasks
+	CodeInvalidValue           = "CORTEX-VAL-016" // Value outside the allowed set or range
+	CodeUndefinedDependency    = "CORTEX-VAL-017" // 'needs' names a task that isn't defined
+	CodeSelfDependency         = "CORTEX-VAL-018" // Task needs itself
+	CodeCircularDependency     = "CORTEX-VAL-019" // Tasks need each other in a cycle
+	CodeUndefinedGroupTask     = "CORTEX-VAL-020" // Group lists a task that isn't defined
+	CodeEmptyGroup             = "CORTEX-VAL-021" // Group lists no tasks
+	CodeInvalidTemplate        = "CORTEX-VAL-022" // Placeholder malformed or not allowed where used
+	CodeUndefinedReference     = "CORTEX-VAL-023" // Placeholder refers to something that doesn't exist
+	CodeMissingNeeds           = "CORTEX-VAL-024" // Placeholder uses a task that isn't in 'needs'
+	CodeOutputConflict         = "CORTEX-VAL-025" // Two tasks write the same output file
+	CodeYAMLParse              = "CORTEX-VAL-026" // File isn't valid YAML, JSON, or TOML
+	CodeNeedsNetwork           = "CORTEX-VAL-027" // Workflow can't run with --offline
+	CodeInvalidInclude         = "CORTEX-VAL-028" // Included file missing, unreadable, or in a cycle
+	CodeUnresolvedVariable     = "CORTEX-VAL-029" // ${VAR} refers to an unset environment variable
+	CodeUndefinedDigestTask    = "CORTEX-VAL-030" // Digest lists a task that isn't defined
+	CodeInvalidMatrix          = "CORTEX-VAL-031" // Matrix empty or too large, or its expanded names are invalid or taken
+	CodeInvalidCondition       = "CORTEX-VAL-032" // 'when' isn't a valid condition
+	CodeUnknownField           = "CORTEX-VAL-033" // Key Cortex doesn't read, e.g. a misspelled field
+	CodeInvalidSecret          = "CORTEX-VAL-034" // Secret's name or source can't be used
+	CodeExtendsCycle           = "CORTEX-VAL-035" // Agents extend each other in a cycle
+	CodeUnsupportedVersion     = "CORTEX-VAL-036" // 'version' invalid or newer than this build reads
+	CodeDeprecated             = "CORTEX-VAL-037" // Deprecated layout or name, still read (a warning)
+	CodeDuplicateDefinition    = "CORTEX-VAL-038" // Agent or task defined in two files without 'override'
+	CodeUnknownModel           = "CORTEX-VAL-039" // Model misspelled: close to one its tool accepts
+	CodeInvalidWorkdir         = "CORTEX-VAL-040" // Task's workdir isn't a directory and can't be created
+
+	CodeDisconnected      = "CORTEX-WARN-001" // Tasks form unconnected groups
+	CodeLongChain         = "CORTEX-WARN-002" // Dependency chain longer than max_depth
+	CodeHighFanIn         = "CORTEX-WARN-003" // Task needs more than max_needs tasks
+	CodeUncataloguedModel = "CORTEX-WARN-004" // Model not in its tool's catalog
+)
+
+// ErrorCode returns the code of the first configuration error in err's
+// chain, or "" if it has none.
+func ErrorCode(err error) string {
+	var many *ConfigErrors
+	if errors.As(err, &many) {
+		for _, e := range many.Errors {
+			if e.Code != "" {
+				return e.Code
+			}
+		}
+		return ""
+	}
+	var one *ConfigError
+	if errors.As(err, &one) {
+		return one.Code
+	}
+	return ""
+}
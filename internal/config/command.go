@@ -0,0 +1,99 @@
+package config
+
+import (
+	"slices"
+
+	"gopkg.in/yaml.v3"
+)
+
+// argumentListKeys are the task fields that may be written as a string, run
+// by a shell, or as a list of arguments, run directly.
+var argumentListKeys = []string{"command", "verify"}
+
+// UnmarshalYAML decodes a task, moving command and verify written as lists
+// into CommandArgs and VerifyArgs, and 'write: patch' into WritePatch:
+//
+//	command: go test ./... | tee test.log     # run by the shell
+//	command: [go, test, "{{outputs.pkg}}"]    # run directly, no shell
+func (t *TaskConfig) UnmarshalYAML(node *yaml.Node) error {
+	type plain TaskConfig
+	lists := make(map[string][]string)
+	patch := false
+	if node.Kind == yaml.MappingNode {
+		content := make([]*yaml.Node, 0, len(node.Content))
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			if key.Value == "write" && value.Kind == yaml.ScalarNode && value.Value == WriteModePatch {
+				patch = true
+				continue
+			}
+			if value.Kind == yaml.SequenceNode && slices.Contains(argumentListKeys, key.Value) {
+				var args []string
+				if err := value.Decode(&args); err != nil {
+					return err
+				}
+				if args == nil {
+					args = []string{}
+				}
+				lists[key.Value] = args
+				continue
+			}
+			content = append(content, key, value)
+		}
+		stripped := *node
+		stripped.Content = content
+		node = &stripped
+	}
+
+	if err := node.Decode((*plain)(t)); err != nil {
+		return err
+	}
+	t.CommandArgs = lists["command"]
+	t.VerifyArgs = lists["verify"]
+	if patch {
+		t.Write, t.WritePatch = true, true
+	}
+	return nil
+}
+
+// MarshalYAML encodes a task, writing CommandArgs and VerifyArgs back as
+// the command and verify lists, and WritePatch as 'write: patch'.
+func (t TaskConfig) MarshalYAML() (interface{}, error) {
+	type plain TaskConfig
+	var node yaml.Node
+	if err := node.Encode(plain(t)); err != nil {
+		return nil, err
+	}
+	if t.WritePatch {
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if node.Content[i].Value == "write" {
+				node.Content[i+1] = &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: WriteModePatch}
+			}
+		}
+	}
+	for key, args := range map[string][]string{"command": t.CommandArgs, "verify": t.VerifyArgs} {
+		if args == nil {
+			continue
+		}
+		var list yaml.Node
+		if err := list.Encode(args); err != nil {
+			return nil, err
+		}
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if node.Content[i].Value == key {
+				node.Content[i+1] = &list
+			}
+		}
+	}
+	return &node, nil
+}
+
+// HasCommand reports whether the task has a command, as a string or a list.
+func (t TaskConfig) HasCommand() bool {
+	return t.Command != "" || t.CommandArgs != nil
+}
+
+// HasVerify reports whether the task has a verify command, as a string or a list.
+func (t TaskConfig) HasVerify() bool {
+	return t.Verify != "" || t.VerifyArgs != nil
+}
@@ -0,0 +1,191 @@
+package config
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+// TestParseConfig_ArgumentLists tests that command and verify may be written
+// as strings or as argument lists.
+func TestParseConfig_ArgumentLists(t *testing.T) {
+	cfg, err := ParseConfig([]byte(`
+agents:
+  sh:
+    tool: shell
+tasks:
+  test:
+    agent: sh
+    command: [go, test, "{{outputs.pkg}}"]
+    shell: bash
+  lint:
+    agent: sh
+    command: golangci-lint run
+    verify: [go, vet, ./...]
+    verify_workdir: backend
+`), "/tmp")
+	if err != nil {
+		t.Fatalf("ParseConfig() error = %v", err)
+	}
+
+	test := cfg.Tasks["test"]
+	if test.Command != "" || !slices.Equal(test.CommandArgs, []string{"go", "test", "{{outputs.pkg}}"}) {
+		t.Errorf("test command = %q, args = %q", test.Command, test.CommandArgs)
+	}
+	if test.Shell != "bash" {
+		t.Errorf("test shell = %q, want bash", test.Shell)
+	}
+	lint := cfg.Tasks["lint"]
+	if lint.Command != "golangci-lint run" || lint.CommandArgs != nil {
+		t.Errorf("lint command = %q, args = %q", lint.Command, lint.CommandArgs)
+	}
+	if !slices.Equal(lint.VerifyArgs, []string{"go", "vet", "./..."}) || lint.VerifyWorkdir != "backend" {
+		t.Errorf("lint verify args = %q, workdir = %q", lint.VerifyArgs, lint.VerifyWorkdir)
+	}
+
+	// Lists survive a round trip, as in run manifests
+	data, err := yaml.Marshal(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, err := ParseConfig(data, "/tmp")
+	if err != nil {
+		t.Fatalf("ParseConfig(marshaled) error = %v", err)
+	}
+	if !slices.Equal(again.Tasks["test"].CommandArgs, test.CommandArgs) || !slices.Equal(again.Tasks["lint"].VerifyArgs, lint.VerifyArgs) {
+		t.Errorf("round trip lost argument lists:\n%s", data)
+	}
+}
+
+// TestValidate_ArgumentLists tests validation of argument lists and shells.
+func TestValidate_ArgumentLists(t *testing.T) {
+	agents := map[string]AgentConfig{
+		"sh":    {Tool: "shell"},
+		"coder": {Tool: "claude-code"},
+	}
+
+	tests := []struct {
+		name            string
+		task            TaskConfig
+		wantErrContains string
+	}{
+		{
+			name: "argument list with outputs and metadata",
+			task: TaskConfig{Agent: "sh", CommandArgs: []string{"deploy", "--ref={{git.commit}}", "{{outputs.build}}"}, Needs: StringList{"build"}},
+		},
+		{
+			name: "shell for a command string",
+			task: TaskConfig{Agent: "sh", Command: "make all", Shell: "pwsh"},
+		},
+		{
+			name:            "empty argument list",
+			task:            TaskConfig{Agent: "sh", CommandArgs: []string{}},
+			wantErrContains: "'command' list is empty",
+		},
+		{
+			name:            "context placeholder in argument list",
+			task:            TaskConfig{Agent: "sh", CommandArgs: []string{"echo", "{{context.repo}}"}},
+			wantErrContains: "'command' list cannot use {{context.repo}}",
+		},
+		{
+			name:            "output of a task not needed",
+			task:            TaskConfig{Agent: "sh", CommandArgs: []string{"echo", "{{outputs.build}}"}},
+			wantErrContains: "build",
+		},
+		{
+			name:            "shell without a command string",
+			task:            TaskConfig{Agent: "sh", CommandArgs: []string{"make"}, Shell: "bash"},
+			wantErrContains: "'shell' has no command to run",
+		},
+		{
+			name:            "verify_workdir without verify",
+			task:            TaskConfig{Agent: "coder", Prompt: "fix", Write: true, VerifyWorkdir: "api"},
+			wantErrContains: "'verify_workdir' requires a 'verify' command",
+		},
+		{
+			name: "verify list with fix attempts",
+			task: TaskConfig{Agent: "coder", Prompt: "fix", Write: true, VerifyArgs: []string{"go", "test"}, FixAttempts: 1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Validate(&AgentflowConfig{
+				Agents: agents,
+				Tasks: map[string]TaskConfig{
+					"build": {Agent: "sh", Command: "make"},
+					"run":   tt.task,
+				},
+			})
+
+			if tt.wantErrContains == "" {
+				if err != nil {
+					t.Errorf("expected no error, got: %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatal("expected validation error, got nil")
+			}
+			if !strings.Contains(err.Error(), tt.wantErrContains) {
+				t.Errorf("expected error containing %q, got: %v", tt.wantErrContains, err)
+			}
+		})
+	}
+}
+
+// TestParseConfig_WritePatch tests 'write: patch', which survives a round
+// trip and can't be combined with 'commit'.
+func TestParseConfig_WritePatch(t *testing.T) {
+	cfg, err := ParseConfig([]byte(`
+agents:
+  coder:
+    tool: claude-code
+tasks:
+  refactor:
+    agent: coder
+    prompt: Refactor the parser
+    write: patch
+  docs:
+    agent: coder
+    prompt: Update the docs
+    write: true
+`), "/tmp")
+	if err != nil {
+		t.Fatalf("ParseConfig() error = %v", err)
+	}
+	if task := cfg.Tasks["refactor"]; !task.Write || !task.WritePatch {
+		t.Errorf("refactor write = %v, patch = %v", task.Write, task.WritePatch)
+	}
+	if task := cfg.Tasks["docs"]; !task.Write || task.WritePatch {
+		t.Errorf("docs write = %v, patch = %v", task.Write, task.WritePatch)
+	}
+
+	data, err := yaml.Marshal(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, err := ParseConfig(data, "/tmp")
+	if err != nil {
+		t.Fatalf("ParseConfig(marshaled) error = %v", err)
+	}
+	if !again.Tasks["refactor"].WritePatch || again.Tasks["docs"].WritePatch {
+		t.Errorf("round trip changed write modes:\n%s", data)
+	}
+
+	if _, err := ParseConfig([]byte("tasks:\n  x:\n    write: diff\n"), "/tmp"); err == nil {
+		t.Error("ParseConfig() accepted 'write: diff'")
+	}
+
+	err = Validate(&AgentflowConfig{
+		Agents: map[string]AgentConfig{"coder": {Tool: "claude-code"}},
+		Tasks: map[string]TaskConfig{
+			"refactor": {Agent: "coder", Prompt: "Refactor", Write: true, WritePatch: true, Commit: &CommitConfig{}},
+		},
+	})
+	if err == nil || !strings.Contains(err.Error(), "cannot have both 'write: patch' and 'commit'") {
+		t.Errorf("Validate() with write: patch and commit: %v", err)
+	}
+}
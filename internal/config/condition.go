@@ -0,0 +1,271 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Condition is a parsed 'when' expression, which decides whether a task
+// runs. Its grammar:
+//
+//	expr    = and { "or" and }
+//	and     = unary { "and" unary }
+//	unary   = "not" unary | "(" expr ")" | operand [ op operand ]
+//	op      = "==" | "!=" | "contains" | "matches"
+//	operand = word | 'quoted' | "quoted"
+//
+// Operands may use the placeholders a prompt can, e.g.
+// "{{outputs.analyze}} contains 'SECURITY'" or "{{env.DEPLOY}} == yes";
+// they are expanded before the condition is evaluated, so outputs can't
+// change how it parses. An operand on its own is true unless it is empty,
+// "false", "no", "off", or "0". "==" and "!=" compare values without
+// surrounding whitespace, and "matches" takes a regular expression.
+type Condition struct {
+	root condNode
+}
+
+// condNode is a node of a parsed condition.
+type condNode interface {
+	eval(expand func(string) string) (bool, error)
+}
+
+// condToken is a token of a condition: an operand (quoted or not), an
+// operator or keyword, or a parenthesis.
+type condToken struct {
+	text    string
+	operand bool
+}
+
+// ParseCondition parses a 'when' expression.
+func ParseCondition(s string) (*Condition, error) {
+	tokens, err := tokenizeCondition(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("empty condition")
+	}
+	p := &condParser{tokens: tokens}
+	root, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("unexpected %q", p.tokens[p.pos].text)
+	}
+	return &Condition{root: root}, nil
+}
+
+// Eval evaluates c, expanding placeholders in its operands with expand.
+// It fails only for an invalid regular expression.
+func (c *Condition) Eval(expand func(string) string) (bool, error) {
+	return c.root.eval(expand)
+}
+
+// condKeywords are the words that aren't operands unless quoted.
+var condKeywords = map[string]bool{"and": true, "or": true, "not": true, "contains": true, "matches": true}
+
+// tokenizeCondition splits s into tokens. Placeholders are kept whole, so
+// {{outputs.x | default "none"}} is one operand.
+func tokenizeCondition(s string) ([]condToken, error) {
+	var tokens []condToken
+	for i := 0; i < len(s); {
+		switch c := s[i]; {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '(' || c == ')':
+			tokens = append(tokens, condToken{text: string(c)})
+			i++
+		case strings.HasPrefix(s[i:], "==") || strings.HasPrefix(s[i:], "!="):
+			tokens = append(tokens, condToken{text: s[i : i+2]})
+			i += 2
+		case c == '\'' || c == '"':
+			end := strings.IndexByte(s[i+1:], c)
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated string %s", s[i:])
+			}
+			tokens = append(tokens, condToken{text: s[i+1 : i+1+end], operand: true})
+			i += end + 2
+		default:
+			start := i
+			for i < len(s) && !strings.ContainsRune(" \t\n\r()'\"", rune(s[i])) &&
+				!strings.HasPrefix(s[i:], "==") && !strings.HasPrefix(s[i:], "!=") {
+				if strings.HasPrefix(s[i:], "{{") {
+					end := strings.Index(s[i:], "}}")
+					if end < 0 {
+						return nil, fmt.Errorf("unterminated placeholder %s", s[i:])
+					}
+					i += end + 2
+					continue
+				}
+				i++
+			}
+			word := s[start:i]
+			tokens = append(tokens, condToken{text: word, operand: !condKeywords[word]})
+		}
+	}
+	return tokens, nil
+}
+
+// condParser parses tokens by recursive descent.
+type condParser struct {
+	tokens []condToken
+	pos    int
+}
+
+// peek returns the next token's text if it is an operator or keyword.
+func (p *condParser) peek() string {
+	if p.pos < len(p.tokens) && !p.tokens[p.pos].operand {
+		return p.tokens[p.pos].text
+	}
+	return ""
+}
+
+func (p *condParser) or() (condNode, error) {
+	left, err := p.and()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek() == "or" {
+		p.pos++
+		right, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+		left = condBool{op: "or", left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *condParser) and() (condNode, error) {
+	left, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek() == "and" {
+		p.pos++
+		right, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		left = condBool{op: "and", left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *condParser) unary() (condNode, error) {
+	switch p.peek() {
+	case "not":
+		p.pos++
+		operand, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return condNot{operand}, nil
+	case "(":
+		p.pos++
+		expr, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek() != ")" {
+			return nil, fmt.Errorf("missing )")
+		}
+		p.pos++
+		return expr, nil
+	}
+
+	left, err := p.operand()
+	if err != nil {
+		return nil, err
+	}
+	switch op := p.peek(); op {
+	case "==", "!=", "contains", "matches":
+		p.pos++
+		right, err := p.operand()
+		if err != nil {
+			return nil, err
+		}
+		if op == "matches" && !strings.Contains(right, "{{") {
+			if _, err := regexp.Compile(right); err != nil {
+				return nil, fmt.Errorf("invalid regular expression %q: %w", right, err)
+			}
+		}
+		return condCompare{op: op, left: left, right: right}, nil
+	}
+	return condValue{left}, nil
+}
+
+func (p *condParser) operand() (string, error) {
+	if p.pos >= len(p.tokens) {
+		return "", fmt.Errorf("unexpected end of condition")
+	}
+	t := p.tokens[p.pos]
+	if !t.operand {
+		return "", fmt.Errorf("unexpected %q", t.text)
+	}
+	p.pos++
+	return t.text, nil
+}
+
+// condValue is an operand on its own.
+type condValue struct {
+	text string
+}
+
+func (n condValue) eval(expand func(string) string) (bool, error) {
+	switch strings.ToLower(strings.TrimSpace(expand(n.text))) {
+	case "", "false", "no", "off", "0":
+		return false, nil
+	}
+	return true, nil
+}
+
+// condCompare compares two operands.
+type condCompare struct {
+	op          string
+	left, right string
+}
+
+func (n condCompare) eval(expand func(string) string) (bool, error) {
+	left, right := expand(n.left), expand(n.right)
+	switch n.op {
+	case "==":
+		return strings.TrimSpace(left) == strings.TrimSpace(right), nil
+	case "!=":
+		return strings.TrimSpace(left) != strings.TrimSpace(right), nil
+	case "contains":
+		return strings.Contains(left, right), nil
+	}
+	re, err := regexp.Compile(right)
+	if err != nil {
+		return false, fmt.Errorf("invalid regular expression %q: %w", right, err)
+	}
+	return re.MatchString(left), nil
+}
+
+// condNot negates a condition.
+type condNot struct {
+	operand condNode
+}
+
+func (n condNot) eval(expand func(string) string) (bool, error) {
+	v, err := n.operand.eval(expand)
+	return !v, err
+}
+
+// condBool combines two conditions with "and" or "or", evaluating the
+// right one only if needed.
+type condBool struct {
+	op          string
+	left, right condNode
+}
+
+func (n condBool) eval(expand func(string) string) (bool, error) {
+	v, err := n.left.eval(expand)
+	if err != nil || v == (n.op == "or") {
+		return v, err
+	}
+	return n.right.eval(expand)
+}
@@ -0,0 +1,68 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCondition(t *testing.T) {
+	values := map[string]string{
+		"{{outputs.scan}}":                   "Found: SECURITY issue in auth.go\n",
+		"{{env.DEPLOY}}":                     "yes",
+		"{{env.EMPTY}}":                      "",
+		`{{outputs.lint | default "clean"}}`: "clean",
+	}
+	expand := func(s string) string {
+		if v, ok := values[s]; ok {
+			return v
+		}
+		return s
+	}
+
+	tests := []struct {
+		when string
+		want bool
+	}{
+		{"{{outputs.scan}} contains 'SECURITY'", true},
+		{"{{outputs.scan}} contains security", false},
+		{"{{env.DEPLOY}} == yes", true},
+		{"{{env.DEPLOY}}=='no'", false},
+		{"{{env.DEPLOY}} != no", true},
+		{"{{env.DEPLOY}}", true},
+		{"{{env.EMPTY}}", false},
+		{"not {{env.EMPTY}}", true},
+		{`{{outputs.scan}} matches "auth\.go"`, true},
+		{"{{env.EMPTY}} or {{env.DEPLOY}} == yes and not {{outputs.scan}} contains 'OK'", true},
+		{"({{env.EMPTY}} or {{env.DEPLOY}} == yes) and {{outputs.scan}} contains 'OK'", false},
+		{`{{outputs.lint | default "clean"}} == clean`, true},
+	}
+	for _, tt := range tests {
+		cond, err := ParseCondition(tt.when)
+		if err != nil {
+			t.Errorf("ParseCondition(%q) error = %v", tt.when, err)
+			continue
+		}
+		got, err := cond.Eval(expand)
+		if err != nil || got != tt.want {
+			t.Errorf("Eval(%q) = %v, %v, want %v", tt.when, got, err, tt.want)
+		}
+	}
+}
+
+func TestParseConditionErrors(t *testing.T) {
+	tests := map[string]string{
+		"":                          "empty condition",
+		"{{outputs.scan}} contains": "unexpected end",
+		"'SECURITY":                 "unterminated string",
+		"{{outputs.scan contains x": "unterminated placeholder",
+		"(a or b":                   "missing )",
+		"a b":                       `unexpected "b"`,
+		"a == and":                  `unexpected "and"`, // Keywords are operands only when quoted
+		"x matches '['":             "invalid regular expression",
+	}
+	for when, want := range tests {
+		if _, err := ParseCondition(when); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("ParseCondition(%q) error = %v, want %q", when, err, want)
+		}
+	}
+}
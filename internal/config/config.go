@@ -2,31 +2,437 @@
 package config
 
 import (
+	"cmp"
+	"path/filepath"
+
 	"gopkg.in/yaml.v3"
 )
 
 // AgentflowConfig represents the root configuration from Cortexfile.yml.
 type AgentflowConfig struct {
+	// Version is the layout the file is written in (see CurrentVersion);
+	// older layouts are migrated as they load.
+	Version int `yaml:"version,omitempty"`
+
+	// Include lists Cortexfiles whose agents, tasks, and other definitions
+	// are merged into this one (see resolveIncludes).
+	Include StringList `yaml:"include,omitempty"`
+
 	Agents   map[string]AgentConfig `yaml:"agents"`
 	Tasks    map[string]TaskConfig  `yaml:"tasks"`
 	Settings *SettingsConfig        `yaml:"settings"` // Optional local settings
 	Workdir  string                 `yaml:"workdir"`  // Working directory for agents (optional)
+
+	// Interchangeable maps a task tag to agents that can stand in for each
+	// other on tasks with that tag; the scheduler routes such tasks to
+	// whichever agent has free concurrency.
+	Interchangeable map[string][]string `yaml:"interchangeable"`
+
+	// Secrets maps names to where their values come from, as
+	// "provider:ref", e.g. "env:GH_TOKEN", "file:~/.config/db-password",
+	// or "command:op read op://dev/api/key". Tasks get them as
+	// {{secrets.NAME}} and in their environment as NAME, and their values
+	// are masked in logs and saved results.
+	Secrets map[string]string `yaml:"secrets,omitempty"`
+
+	// Variables are values prompts, commands, and conditions use as
+	// {{vars.NAME}}, so text shared by several tasks is written once.
+	Variables map[string]string `yaml:"variables,omitempty"`
+
+	// Preamble is prepended to every AI task's prompt, e.g. repo conventions.
+	// It may use {{task.name}}, {{task.agent}}, and {{run.id}}.
+	// PreambleFile loads it from a file relative to the Cortexfile instead.
+	Preamble     string `yaml:"preamble"`
+	PreambleFile string `yaml:"preamble_file"`
+
+	// Retrieval configures the embedding index used by {{retrieve "query"}}.
+	Retrieval *RetrievalConfig `yaml:"retrieval"`
+
+	// Digest collects the outputs of the run's tasks into one Markdown
+	// document after the run (optional).
+	Digest *DigestConfig `yaml:"digest,omitempty"`
+
+	// Workflows defines named sets of tasks that share the agents above,
+	// run with `cortex run <name>` (see SelectWorkflow).
+	Workflows map[string]NamedWorkflow `yaml:"workflows,omitempty"`
+
+	// Groups names subsets of the tasks, run with `cortex run --group
+	// <name>` together with the tasks they need (see SelectGroup).
+	Groups map[string]StringList `yaml:"groups,omitempty"`
+
+	// Sources maps "agents.NAME", "tasks.NAME", "groups.NAME", and
+	// "workflows.NAME" to the included file that defined them; definitions
+	// from the Cortexfile itself aren't listed.
+	Sources map[string]string `yaml:"-"`
+
+	// Lines maps "agents.NAME" and "tasks.NAME" to the line defining them,
+	// in the file Sources names or the Cortexfile itself.
+	Lines map[string]int `yaml:"-"`
+
+	// Unresolved lists the environment variables in Workdir that aren't
+	// set (see interpolateConfig).
+	Unresolved []string `yaml:"-"`
+
+	// Deprecations warn about deprecated layouts and names found while
+	// loading this file and the files it includes (see migrate).
+	Deprecations []*ConfigError `yaml:"-"`
+}
+
+// SourceFile returns the file that defined the named agent, task, group,
+// or workflow (section is "agents", "tasks", "groups", or "workflows"), or
+// def if it's defined in the Cortexfile itself.
+func (c *AgentflowConfig) SourceFile(section, name, def string) string {
+	if file, ok := c.Sources[section+"."+name]; ok {
+		return file
+	}
+	return def
+}
+
+// TaskWorkdir returns the directory the task runs in: its own workdir,
+// joined to the Cortexfile's unless absolute, or the Cortexfile's.
+func (c *AgentflowConfig) TaskWorkdir(task TaskConfig) string {
+	if task.Workdir == "" || filepath.IsAbs(task.Workdir) {
+		return cmp.Or(task.Workdir, c.Workdir)
+	}
+	return filepath.Join(c.Workdir, task.Workdir)
+}
+
+// DigestConfig configures the Markdown digest of a run: front matter
+// describing the run, then a section per task in dependency order.
+type DigestConfig struct {
+	// Path is where the digest is written, relative to the workdir. It may
+	// use the {{run.X}}, {{git.X}}, and {{env.X}} placeholders, e.g.
+	// "reports/{{run.id}}.md".
+	Path  string     `yaml:"path"`
+	Title string     `yaml:"title,omitempty"` // Heading of the digest (default: "Cortex run <id>")
+	Tasks StringList `yaml:"tasks,omitempty"` // Tasks to include (default: all)
+}
+
+// NamedWorkflow is one of several workflows defined in a Cortexfile.
+type NamedWorkflow struct {
+	Description string                `yaml:"description,omitempty"`
+	Tasks       map[string]TaskConfig `yaml:"tasks"`
+	Groups      map[string]StringList `yaml:"groups,omitempty"`
+
+	// Schedule replaces settings.schedule for runs of the workflow, e.g. to
+	// run a heavy audit only overnight.
+	Schedule *ScheduleConfig `yaml:"schedule,omitempty"`
+}
+
+// RetrievalConfig selects the embedding provider for retrieval and bounds
+// what it adds to prompts.
+type RetrievalConfig struct {
+	Provider  string `yaml:"provider"`    // "local" (default), "openai", or "ollama"
+	Model     string `yaml:"model"`       // Embedding model (provider default if empty)
+	URL       string `yaml:"url"`         // API base URL (provider default if empty)
+	APIKeyEnv string `yaml:"api_key_env"` // Env var holding the API key (default: OPENAI_API_KEY)
+	MaxTokens int    `yaml:"max_tokens"`  // Token budget per {{retrieve}} (default: 2000)
+}
+
+// Embedding providers for retrieval.
+const (
+	EmbedLocal  = "local"  // Hashed term vectors computed in-process; no network
+	EmbedOpenAI = "openai" // OpenAI-compatible /v1/embeddings API
+	EmbedOllama = "ollama" // Ollama /api/embed
+)
+
+// IsValidEmbedProvider checks if a retrieval provider is supported.
+// An empty value selects the local provider.
+func IsValidEmbedProvider(provider string) bool {
+	switch provider {
+	case "", EmbedLocal, EmbedOpenAI, EmbedOllama:
+		return true
+	}
+	return false
 }
 
 // AgentConfig defines an AI agent's configuration.
 type AgentConfig struct {
 	Tool  string `yaml:"tool"`  // "claude-code" or "opencode"
 	Model string `yaml:"model"` // Optional: model identifier (e.g., "sonnet", "opus")
+
+	// Extends names another agent whose settings this one takes for the
+	// fields it leaves out, e.g. a reviewer that is the coder with a
+	// different system prompt.
+	Extends string `yaml:"extends"`
+
+	// Override lets this definition replace an agent of the same name
+	// defined in an earlier file (see resolveIncludes).
+	Override bool `yaml:"override,omitempty"`
+
+	// MaxConcurrent limits how many tasks run on this agent at once (0 = no limit).
+	MaxConcurrent int `yaml:"max_concurrent"`
+
+	// SystemPrompt is given to the tool as a system prompt for every task
+	// on this agent, after Cortex's own output rules. Tools without system
+	// prompts get it at the top of the prompt instead.
+	SystemPrompt string `yaml:"system_prompt"`
+
+	// Instructions start the prompt of every task on this agent, after the
+	// preamble: a persona or shared context written once rather than in
+	// each task. InstructionsFile loads them from a file, relative to the
+	// Cortexfile.
+	Instructions     string `yaml:"instructions"`
+	InstructionsFile string `yaml:"instructions_file"`
+
+	// Unresolved lists the environment variables in Model that aren't set.
+	Unresolved []string `yaml:"-"`
 }
 
 // TaskConfig defines a single task's configuration.
 type TaskConfig struct {
+	Uses       string     `yaml:"uses"`        // Remote workflow to run in place of this task (see flows)
 	Agent      string     `yaml:"agent"`       // Reference to agent name in agents section
 	Prompt     string     `yaml:"prompt"`      // Inline prompt text (option A)
 	PromptFile string     `yaml:"prompt_file"` // Path to prompt file (option B)
 	Command    string     `yaml:"command"`     // Shell command to execute (for shell agents)
+	Shell      string     `yaml:"shell"`       // Shell for command and verify (default: settings.shell)
 	Needs      StringList `yaml:"needs"`       // Dependencies: single string or array
 	Write      bool       `yaml:"write"`       // Allow file writes (default: false)
+	Tags       StringList `yaml:"tags"`        // Labels used for routing (see Interchangeable)
+
+	// Override lets this definition replace a task of the same name
+	// defined in an earlier file (see resolveIncludes).
+	Override bool `yaml:"override,omitempty"`
+
+	// Verify is a command run in the workdir after a write task finishes.
+	// A non-zero exit marks the task as failed.
+	Verify string `yaml:"verify"`
+
+	// CommandArgs and VerifyArgs hold command and verify when they are
+	// written as argument lists, e.g. [go, test, ./...]. Lists are run
+	// directly rather than by a shell, so values filled into them can't
+	// inject shell syntax (see UnmarshalYAML).
+	CommandArgs []string `yaml:"-"`
+	VerifyArgs  []string `yaml:"-"`
+
+	// WritePatch is set by 'write: patch': the agent may change files, but
+	// in a copy of the repository, and its changes are saved as a patch
+	// for 'cortex apply' instead of landing in the checkout. Write is set
+	// too.
+	WritePatch bool `yaml:"-"`
+
+	// Workdir runs the task in this directory instead of the Cortexfile's
+	// workdir: relative to it, or absolute. It is created if missing.
+	Workdir string `yaml:"workdir"`
+
+	// VerifyWorkdir runs verify in this directory, relative to the workdir.
+	VerifyWorkdir string `yaml:"verify_workdir"`
+
+	// FixAttempts re-runs the agent with the verify output appended to the
+	// prompt when verification fails (default: 0, no fix loop).
+	FixAttempts int `yaml:"fix_attempts"`
+
+	// Retries re-runs the agent when the task fails (default: 0, no retries).
+	// RetryBackoff is the pause before the first retry, doubled before each
+	// later one (default: DefaultRetryBackoff). RetryOn limits retries to
+	// failures matching one of its entries: an exit code, e.g. "137", or a
+	// regular expression matched against the agent's output, e.g.
+	// "(?i)connection reset". Without it, any failure is retried.
+	Retries      int        `yaml:"retries"`
+	RetryBackoff string     `yaml:"retry_backoff"`
+	RetryOn      StringList `yaml:"retry_on"`
+
+	// MaxChangedFiles and MaxChangedLines cap how much a write task may change.
+	// Changes beyond either limit are reverted and the task fails (0 = no limit).
+	MaxChangedFiles int `yaml:"max_changed_files"`
+	MaxChangedLines int `yaml:"max_changed_lines"`
+
+	// OnContextOverflow selects how a task recovers when its prompt exceeds
+	// the model's context window: "truncate" (default), "summarize", or "fail".
+	OnContextOverflow string `yaml:"on_context_overflow"`
+
+	// MaxTokens caps the input and output tokens a task may use. The agent is
+	// stopped as soon as streamed usage passes the cap (0 = no limit).
+	MaxTokens int `yaml:"max_tokens"`
+
+	// Memory gives the task access to the project's memory, shared across
+	// runs: "read" exposes it as {{memory}}, "write" also appends the task's
+	// output to it after a successful run.
+	Memory string `yaml:"memory"`
+
+	// Scope "diff" limits the task to files changed since the base ref
+	// (settings.base or --base): they are listed in its prompt, with the
+	// diff, and the task is skipped when nothing changed.
+	Scope string `yaml:"scope"`
+
+	// Paths skips the task in runs with a diff (see Scope) when none of the
+	// changed files match these globs, e.g. ["api/**", "*.proto"].
+	Paths StringList `yaml:"paths"`
+
+	// Writes lists globs of the files a write task changes, e.g.
+	// ["api/**"]. Tasks that could run at once but whose globs overlap
+	// run one at a time instead, so they never edit the same files
+	// together.
+	Writes StringList `yaml:"writes"`
+
+	// Context adds the repository files most relevant to the prompt to it,
+	// within a token budget (see ContextConfig).
+	Context *ContextConfig `yaml:"context"`
+
+	// Compress makes the task a built-in step that shortens text to a
+	// token budget instead of prompting an agent (see CompressConfig). Its
+	// agent, if any, writes the shorter text; without one, the most
+	// telling lines are kept.
+	Compress *CompressConfig `yaml:"compress"`
+
+	// Normalize selects how the task's output is cleaned up before it's
+	// stored and passed to other tasks: any of "ansi", "utf8", "newlines",
+	// and "progress", or "none" to keep it as printed (default: all of
+	// them; see NormalizeSteps).
+	Normalize StringList `yaml:"normalize"`
+
+	// Expect "json" makes the task's output a JSON value, checked against
+	// Schema, a JSON Schema written inline, or the one in SchemaFile.
+	// Output that isn't one is handed back to the agent, with what's wrong
+	// with it, up to ExpectRetries times before the task fails. Dependent
+	// tasks see the parsed value, and can address its fields as
+	// {{outputs.X.field}}.
+	Expect        string         `yaml:"expect"`
+	Schema        map[string]any `yaml:"schema,omitempty"`
+	SchemaFile    string         `yaml:"schema_file,omitempty"`
+	ExpectRetries int            `yaml:"expect_retries,omitempty"`
+
+	// Commit commits the agent's changes on the run branch after a
+	// successful write task, optionally opening a pull request.
+	Commit *CommitConfig `yaml:"commit"`
+
+	// OutputFile saves the task's output to this path (relative to the
+	// workdir) after it succeeds. It may use the {{task.X}}, {{run.X}},
+	// {{git.X}}, and {{env.X}} placeholders, e.g. "reports/{{run.id}}/{{task.name}}.md".
+	OutputFile string `yaml:"output_file"`
+
+	// When is a condition (see Condition) that decides whether the task
+	// runs, e.g. "{{outputs.analyze}} contains 'SECURITY'". Tasks whose
+	// condition is false are skipped; their dependents still run.
+	When string `yaml:"when"`
+
+	// Timeout stops the task once it has run this long, e.g. "10m",
+	// killing its tool and any processes the tool started (default: no
+	// limit). It covers retries, verification, and fix attempts.
+	Timeout string `yaml:"timeout"`
+
+	// Matrix expands the task into one task per combination of these
+	// values, e.g. {service: [api, web], lang: [go, ts]} into test-api-go,
+	// test-api-ts, and so on. Fields may use {{matrix.KEY}}; see
+	// expandTasks for how dependencies are wired.
+	Matrix map[string]StringList `yaml:"matrix,omitempty"`
+
+	// Unresolved lists the environment variables in the inline prompt
+	// that aren't set (see interpolateConfig).
+	Unresolved []string `yaml:"-"`
+
+	// PromptPath is the file prompt_file resolved to, set when it was
+	// loaded into Prompt or, with Prompt left empty, when no such file
+	// exists (see resolveTaskPromptFiles).
+	PromptPath string `yaml:"-"`
+}
+
+// CommitConfig controls how a write task's changes are committed.
+type CommitConfig struct {
+	MessageTemplate string `yaml:"message_template"` // Supports {{task.name}}, {{task.agent}}, {{run.id}}
+	PR              bool   `yaml:"pr"`               // Push the run branch and open a pull/merge request
+	Base            string `yaml:"base"`             // PR target branch (default: remote's default branch)
+}
+
+// ContextConfig selects the files a task's prompt is given.
+type ContextConfig struct {
+	// Files are globs of the candidate files, relative to the workdir;
+	// those starting with "!" exclude, e.g. ["**/*.go", "!vendor/**"].
+	// Files git ignores are left out.
+	Files StringList `yaml:"files"`
+
+	// MaxTokens is the budget of the files added: the most relevant are
+	// included whole while they fit, the rest listed by path (default:
+	// 8000).
+	MaxTokens int `yaml:"max_tokens"`
+}
+
+// CompressConfig selects the text a compress step shortens, and how short.
+type CompressConfig struct {
+	// Input is the text to shorten, usually placeholders such as
+	// "{{outputs.test}}".
+	Input string `yaml:"input"`
+
+	// Files are globs of files to shorten, after Input, relative to the
+	// workdir; those starting with "!" exclude. Files git ignores are
+	// left out.
+	Files StringList `yaml:"files"`
+
+	// MaxTokens is the size to shorten the text to (default: 2000).
+	MaxTokens int `yaml:"max_tokens"`
+}
+
+// CompressTool is the tool of compress steps without an agent.
+const CompressTool = "compress"
+
+// Context overflow recovery strategies.
+const (
+	OverflowTruncate  = "truncate"  // Shorten dependency outputs (or the prompt) and retry
+	OverflowSummarize = "summarize" // Have the agent summarize oversized outputs, then retry
+	OverflowFail      = "fail"      // Fail the task without retrying
+)
+
+// IsValidOverflowStrategy checks if an on_context_overflow value is supported.
+// An empty value selects the default strategy.
+func IsValidOverflowStrategy(strategy string) bool {
+	switch strategy {
+	case "", OverflowTruncate, OverflowSummarize, OverflowFail:
+		return true
+	}
+	return false
+}
+
+// Output normalization steps (see TaskConfig.Normalize).
+const (
+	NormalizeANSI     = "ansi"     // Strip terminal colors, cursor movement, and control characters
+	NormalizeUTF8     = "utf8"     // Replace invalid UTF-8
+	NormalizeNewlines = "newlines" // Turn \r\n line endings into \n
+	NormalizeProgress = "progress" // Keep only the final state of lines redrawn with \r
+	NormalizeNone     = "none"     // Keep the output as printed
+)
+
+// ExpectJSON is the output format of 'expect: json' (see TaskConfig.Expect).
+const ExpectJSON = "json"
+
+// NormalizeSteps are the output normalization steps, all applied by
+// default.
+var NormalizeSteps = []string{NormalizeANSI, NormalizeUTF8, NormalizeNewlines, NormalizeProgress}
+
+// WriteModePatch is the value of 'write' that keeps a task's changes as
+// a patch (see TaskConfig.WritePatch).
+const WriteModePatch = "patch"
+
+// Memory access modes.
+const (
+	MemoryRead  = "read"  // Expose the project memory as {{memory}}
+	MemoryWrite = "write" // Also append the task's output to the memory
+)
+
+// IsValidMemoryMode checks if a memory value is supported.
+// An empty value means no memory access.
+func IsValidMemoryMode(mode string) bool {
+	switch mode {
+	case "", MemoryRead, MemoryWrite:
+		return true
+	}
+	return false
+}
+
+// Task scopes.
+const (
+	ScopeFull = "full" // The whole repository (default)
+	ScopeDiff = "diff" // Only files changed since the base ref
+)
+
+// IsValidScope checks if a scope value is supported.
+// An empty value selects the full repository.
+func IsValidScope(scope string) bool {
+	switch scope {
+	case "", ScopeFull, ScopeDiff:
+		return true
+	}
+	return false
 }
 
 // StringList is a custom type that can unmarshal from either a single string or an array of strings.
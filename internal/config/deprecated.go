@@ -0,0 +1,236 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// deprecatedField describes a field replaced in the schema. Files using it
+// still load: the field is read as its replacement, with a deprecation
+// warning, and 'cortex migrate --fix-deprecations' rewrites it.
+type deprecatedField struct {
+	// Replacement is the key that replaces the field, in the same place,
+	// taking the same values. "" if it has none.
+	Replacement string
+
+	// Hint says how to do without a field that has no replacement. Such
+	// fields fail to load, and are left for the user to change.
+	Hint string
+}
+
+// deprecatedFields are the deprecated fields, keyed like schemaDescriptions
+// by type and YAML key, e.g. "TaskConfig.verify_dir". A field renamed in
+// the schema moves to its new key in the struct and keeps its old one
+// here, with a row in the README.
+var deprecatedFields = map[string]deprecatedField{}
+
+// deprecatedKey is a deprecated key found in a file.
+type deprecatedKey struct {
+	Node    *yaml.Node // The key
+	Mapping *yaml.Node // The mapping holding it
+	Path    string     // Dotted path of the mapping holding it, e.g. "tasks.review"
+	Field   deprecatedField
+}
+
+// findDeprecated returns the deprecated keys in doc, which decodes into t,
+// in the order they appear.
+func findDeprecated(doc *yaml.Node, t reflect.Type) []deprecatedKey {
+	var found []deprecatedKey
+	seen := make(map[*yaml.Node]bool) // Anchored mappings are visited once
+	var walk func(n *yaml.Node, t reflect.Type, path string)
+	walk = func(n *yaml.Node, t reflect.Type, path string) {
+		if seen[n] {
+			return
+		}
+		seen[n] = true
+		switch n.Kind {
+		case yaml.DocumentNode:
+			for _, c := range n.Content {
+				walk(c, t, path)
+			}
+			return
+		case yaml.AliasNode:
+			walk(n.Alias, t, path)
+			return
+		}
+		if t.Kind() == reflect.Pointer {
+			t = t.Elem()
+		}
+
+		switch {
+		case t.Kind() == reflect.Struct && n.Kind == yaml.MappingNode:
+			fields := yamlFields(t)
+			for i := 0; i+1 < len(n.Content); i += 2 {
+				key, value := n.Content[i], n.Content[i+1]
+				if key.Value == "<<" {
+					if value.Kind == yaml.SequenceNode {
+						for _, item := range value.Content {
+							walk(item, t, path)
+						}
+					} else {
+						walk(value, t, path)
+					}
+					continue
+				}
+				if d, ok := deprecatedFields[t.Name()+"."+key.Value]; ok {
+					found = append(found, deprecatedKey{Node: key, Mapping: n, Path: path, Field: d})
+					if d.Replacement == "" {
+						continue
+					}
+					if field, ok := fields[d.Replacement]; ok {
+						walk(value, field, joinPath(path, d.Replacement))
+					}
+					continue
+				}
+				if field, ok := fields[key.Value]; ok {
+					walk(value, field, joinPath(path, key.Value))
+				}
+			}
+		case t.Kind() == reflect.Map && n.Kind == yaml.MappingNode:
+			for i := 0; i+1 < len(n.Content); i += 2 {
+				walk(n.Content[i+1], t.Elem(), joinPath(path, n.Content[i].Value))
+			}
+		case t.Kind() == reflect.Slice && n.Kind == yaml.SequenceNode:
+			for i, item := range n.Content {
+				walk(item, t.Elem(), fmt.Sprintf("%s[%d]", path, i))
+			}
+		}
+	}
+	walk(doc, t, "")
+	sort.SliceStable(found, func(i, j int) bool { return found[i].Node.Line < found[j].Node.Line })
+	return found
+}
+
+// renameDeprecated renames the deprecated keys in doc to their
+// replacements, so the file decodes as if written with them, and returns
+// a deprecation for each. A field without a replacement, or written
+// together with it, is an error.
+func renameDeprecated(doc *yaml.Node) ([]*ConfigError, error) {
+	var deprecations []*ConfigError
+	for _, d := range findDeprecated(doc, reflect.TypeOf(AgentflowConfig{})) {
+		old := d.Node.Value
+		where := ""
+		if d.Path != "" {
+			where = d.Path + ": "
+		}
+		if d.Field.Replacement == "" {
+			return nil, NewCodedError(CodeUnknownField, "", d.Node.Line,
+				fmt.Sprintf("%s'%s' is no longer supported", where, old), d.Field.Hint)
+		}
+		for i := 0; i+1 < len(d.Mapping.Content); i += 2 {
+			if d.Mapping.Content[i].Value == d.Field.Replacement {
+				return nil, NewCodedError(CodeConflictingFields, "", d.Node.Line,
+					fmt.Sprintf("%sboth '%s' and '%s', which replaces it", where, old, d.Field.Replacement),
+					fmt.Sprintf("Remove '%s'", old))
+			}
+		}
+		deprecations = append(deprecations, NewCodedError(CodeDeprecated, "", d.Node.Line,
+			fmt.Sprintf("%s'%s' is deprecated; use '%s'", where, old, d.Field.Replacement),
+			"Run 'cortex migrate --fix-deprecations' to update the file"))
+		d.Node.Value = d.Field.Replacement
+	}
+	return deprecations, nil
+}
+
+// FixDeprecations rewrites the deprecated fields of the Cortexfile at path
+// to their replacements in place, keeping its formatting and comments. It
+// returns a description of each change, and the deprecations it couldn't
+// fix, such as fields without a replacement.
+func FixDeprecations(path string) (fixed []string, unfixed []*ConfigError, err error) {
+	data, err := readLimited(path, MaxConfigBytes, "config file")
+	if err != nil {
+		return nil, nil, err
+	}
+	format := FormatOf(path)
+	doc, err := parseDocument(data, format)
+	if err != nil {
+		return nil, nil, ErrParse(path, 0, format, err.Error())
+	}
+
+	lines := bytes.SplitAfter(data, []byte("\n"))
+	for _, d := range findDeprecated(doc, reflect.TypeOf(AgentflowConfig{})) {
+		old, line := d.Node.Value, d.Node.Line
+		if d.Field.Replacement == "" {
+			unfixed = append(unfixed, NewCodedError(CodeUnknownField, path, line,
+				fmt.Sprintf("'%s' is no longer supported", old), d.Field.Hint))
+			continue
+		}
+		if line < 1 || line > len(lines) || !renameKey(&lines[line-1], old, d.Field.Replacement) {
+			unfixed = append(unfixed, NewCodedError(CodeDeprecated, path, line,
+				fmt.Sprintf("'%s' is deprecated; use '%s'", old, d.Field.Replacement),
+				"Rename it by hand; it isn't written on one line with its key"))
+			continue
+		}
+		fixed = append(fixed, fmt.Sprintf("%s:%d: %s -> %s", path, line, old, d.Field.Replacement))
+	}
+	if len(fixed) == 0 {
+		return nil, unfixed, nil
+	}
+	if err := writeFileAtomic(path, bytes.Join(lines, nil)); err != nil {
+		return nil, nil, err
+	}
+	return fixed, unfixed, nil
+}
+
+// renameKey replaces the first occurrence of key in line as a whole key,
+// bare or quoted, with replacement. It reports whether it found one.
+func renameKey(line *[]byte, key, replacement string) bool {
+	re := regexp.MustCompile(`(^|[^\w-])(["']?)` + regexp.QuoteMeta(key) + `(["']?)($|[^\w-])`)
+	loc := re.FindSubmatchIndex(*line)
+	if loc == nil {
+		return false
+	}
+	// Between the optional quotes
+	start, end := loc[5], loc[6]
+	*line = append((*line)[:start:start], append([]byte(replacement), (*line)[end:]...)...)
+	return true
+}
+
+// FixLegacyName renames a Cortexfile with the old Agentfile name to its
+// Cortexfile name, unless one already exists, and returns the new path.
+func FixLegacyName(path string) (string, error) {
+	name := filepath.Base(path)
+	if !strings.HasPrefix(name, "Agentfile.") {
+		return path, nil
+	}
+	renamed := filepath.Join(filepath.Dir(path), "Cortex"+strings.TrimPrefix(name, "Agent"))
+	if _, err := os.Stat(renamed); err == nil {
+		return "", fmt.Errorf("cannot rename %s: %s already exists", path, renamed)
+	}
+	if err := os.Rename(path, renamed); err != nil {
+		return "", err
+	}
+	return renamed, nil
+}
+
+// writeFileAtomic replaces the file at path with data, keeping its mode.
+func writeFileAtomic(path string, data []byte) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(tmp.Name(), info.Mode().Perm())
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	return err
+}
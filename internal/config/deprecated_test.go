@@ -0,0 +1,102 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// withDeprecatedFields replaces the deprecated fields for a test.
+func withDeprecatedFields(t *testing.T, fields map[string]deprecatedField) {
+	saved := deprecatedFields
+	t.Cleanup(func() { deprecatedFields = saved })
+	deprecatedFields = fields
+}
+
+func TestDeprecatedFields(t *testing.T) {
+	withDeprecatedFields(t, map[string]deprecatedField{
+		"TaskConfig.verify_dir":   {Replacement: "verify_workdir"},
+		"AgentConfig.temperature": {Hint: "Set it in the tool's own config"},
+	})
+
+	cfg, err := ParseConfig([]byte(`
+agents:
+  sh: {tool: shell}
+x-defaults: &defaults {verify_dir: web}
+tasks:
+  build: {agent: sh, command: make, verify: make test, verify_dir: api}
+  lint: {<<: *defaults, agent: sh, command: make lint, verify: make vet}
+`), ".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := cfg.Tasks["build"].VerifyWorkdir; got != "api" {
+		t.Errorf("build verify_workdir = %q, want the deprecated field's value", got)
+	}
+	if got := cfg.Tasks["lint"].VerifyWorkdir; got != "web" {
+		t.Errorf("lint verify_workdir = %q, want the merged deprecated field's value", got)
+	}
+	var got []string
+	for _, d := range cfg.Deprecations {
+		got = append(got, d.Message)
+	}
+	want := []string{"tasks.lint: 'verify_dir' is deprecated; use 'verify_workdir'", "tasks.build: 'verify_dir' is deprecated; use 'verify_workdir'"}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("deprecations = %q, want %q", got, want)
+	}
+
+	for input, wantErr := range map[string]string{
+		"agents:\n  ai: {tool: claude-code, temperature: 0.2}\n":                   "agents.ai: 'temperature' is no longer supported",
+		"tasks:\n  a: {agent: sh, command: x, verify_dir: a, verify_workdir: b}\n": "tasks.a: both 'verify_dir' and 'verify_workdir'",
+	} {
+		if _, err := ParseConfig([]byte(input), "."); err == nil || !strings.Contains(err.Error(), wantErr) {
+			t.Errorf("ParseConfig(%q) error = %v, want %q", input, err, wantErr)
+		}
+	}
+}
+
+func TestFixDeprecations(t *testing.T) {
+	withDeprecatedFields(t, map[string]deprecatedField{
+		"TaskConfig.verify_dir":   {Replacement: "verify_workdir"},
+		"AgentConfig.temperature": {Hint: "Set it in the tool's own config"},
+	})
+
+	dir := t.TempDir()
+	files := map[string]string{
+		"Cortexfile.yml": `# Build and test
+agents:
+  sh: {tool: shell}
+  ai: {tool: claude-code, temperature: 0.2}
+tasks:
+  build:
+    agent: sh
+    command: make   # verify_dir stays in comments
+    verify_dir: api
+  test: {agent: sh, command: make test, "verify_dir": web}
+`,
+		"Cortexfile.json": `{"tasks": {"build": {"agent": "sh", "command": "make", "verify_dir": "api"}}}`,
+	}
+	writeFiles(t, dir, files)
+
+	fixed, unfixed, err := FixDeprecations(filepath.Join(dir, "Cortexfile.yml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fixed) != 2 || len(unfixed) != 1 || unfixed[0].Line != 4 {
+		t.Errorf("FixDeprecations() = %q, %v", fixed, unfixed)
+	}
+	data, _ := os.ReadFile(filepath.Join(dir, "Cortexfile.yml"))
+	want := strings.NewReplacer("verify_dir: api", "verify_workdir: api", `"verify_dir"`, `"verify_workdir"`).Replace(files["Cortexfile.yml"])
+	if string(data) != want {
+		t.Errorf("fixed file:\n%s\nwant:\n%s", data, want)
+	}
+
+	if _, _, err := FixDeprecations(filepath.Join(dir, "Cortexfile.json")); err != nil {
+		t.Fatal(err)
+	}
+	data, _ = os.ReadFile(filepath.Join(dir, "Cortexfile.json"))
+	if !strings.Contains(string(data), `"verify_workdir": "api"`) {
+		t.Errorf("fixed JSON = %s", data)
+	}
+}
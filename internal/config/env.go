@@ -0,0 +1,60 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// EnvPrefix starts the names of the environment variables that override
+// settings and flags, so CI can tune a run without editing files.
+const EnvPrefix = "CORTEX_"
+
+// EnvName returns the environment variable for a setting or flag, e.g.
+// CORTEX_MAX_PARALLEL for max_parallel or --max-parallel.
+func EnvName(name string) string {
+	return EnvPrefix + strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
+}
+
+// ApplyEnv overrides settings with the environment variables that are set
+// and not empty. Each setting's variable is EnvName of its YAML key;
+// booleans accept 1, 0, true, and false. lookup is usually os.LookupEnv.
+func ApplyEnv(settings *SettingsConfig, lookup func(string) (string, bool)) error {
+	v := reflect.ValueOf(settings).Elem()
+	t := v.Type()
+	for i := range t.NumField() {
+		key, _, _ := strings.Cut(t.Field(i).Tag.Get("yaml"), ",")
+		if key == "" || key == "-" {
+			continue
+		}
+		name := EnvName(key)
+		raw, ok := lookup(name)
+		raw = strings.TrimSpace(raw)
+		if !ok || raw == "" {
+			continue
+		}
+
+		field := v.Field(i)
+		switch field.Kind() {
+		case reflect.Bool:
+			b, err := strconv.ParseBool(raw)
+			if err != nil {
+				return fmt.Errorf("invalid %s %q: use true or false", name, raw)
+			}
+			field.SetBool(b)
+		case reflect.Int:
+			n, err := strconv.Atoi(raw)
+			if err != nil {
+				return fmt.Errorf("invalid %s %q: must be a whole number", name, raw)
+			}
+			field.SetInt(int64(n))
+		case reflect.String:
+			if key == "dirty_tree" && !IsValidDirtyTreePolicy(raw) {
+				return fmt.Errorf("invalid %s %q: use refuse, stash, or proceed", name, raw)
+			}
+			field.SetString(raw)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestEnvName(t *testing.T) {
+	for in, want := range map[string]string{
+		"max_parallel": "CORTEX_MAX_PARALLEL",
+		"log-level":    "CORTEX_LOG_LEVEL",
+		"offline":      "CORTEX_OFFLINE",
+	} {
+		if got := EnvName(in); got != want {
+			t.Errorf("EnvName(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestMergeConfigsWithEnv(t *testing.T) {
+	global := &GlobalConfig{Settings: SettingsConfig{MaxParallel: 8, Verbose: true, Shell: "sh"}}
+	local := &AgentflowConfig{Settings: &SettingsConfig{MaxParallel: 4, Parallel: true, Base: "main"}}
+	env := map[string]string{
+		"CORTEX_MAX_PARALLEL": "2",
+		"CORTEX_VERBOSE":      "false",
+		"CORTEX_BASE":         "origin/develop",
+		"CORTEX_SHELL":        "", // Empty is unset
+		"CORTEX_UNKNOWN":      "ignored",
+	}
+	lookup := func(name string) (string, bool) {
+		v, ok := env[name]
+		return v, ok
+	}
+
+	merged, err := MergeConfigsWithEnv(global, local, nil, lookup)
+	if err != nil {
+		t.Fatalf("MergeConfigsWithEnv() error = %v", err)
+	}
+	s := merged.Settings
+	if s.MaxParallel != 2 || s.Verbose || s.Base != "origin/develop" || s.Shell != "sh" || !s.Parallel {
+		t.Errorf("settings = %+v, want environment over Cortexfile over global", s)
+	}
+
+	// Flags win over the environment
+	merged, err = MergeConfigsWithEnv(global, local, &SettingsConfig{MaxParallel: 6}, lookup)
+	if err != nil || merged.Settings.MaxParallel != 6 {
+		t.Errorf("MaxParallel with flag = %d, %v; want 6", merged.Settings.MaxParallel, err)
+	}
+
+	for name, value := range map[string]string{
+		"CORTEX_MAX_PARALLEL": "lots",
+		"CORTEX_SNAPSHOT":     "maybe",
+		"CORTEX_DIRTY_TREE":   "ignore",
+	} {
+		_, err := MergeConfigsWithEnv(global, local, nil, func(n string) (string, bool) {
+			return value, n == name
+		})
+		if err == nil || !strings.Contains(err.Error(), name) {
+			t.Errorf("%s=%s: error = %v, want one naming the variable", name, value, err)
+		}
+	}
+}
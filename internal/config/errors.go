@@ -12,6 +12,11 @@ type ConfigError struct {
 	Column  int    // Column number (1-based, 0 if unknown)
 	Message string // Error message
 	Hint    string // Optional hint for fixing the error
+	Code    string // Stable code for the kind of error, e.g. CORTEX-VAL-008 ("" if none)
+
+	// Suggestion is the defined name closest to a misspelled one, shown as
+	// "(did you mean ...?)" after the message ("" if none is close).
+	Suggestion string
 }
 
 // Error implements the error interface.
@@ -32,6 +37,12 @@ func (e *ConfigError) Error() string {
 
 	// Message
 	sb.WriteString(e.Message)
+	if e.Suggestion != "" {
+		sb.WriteString(fmt.Sprintf(" (did you mean %q?)", e.Suggestion))
+	}
+	if e.Code != "" {
+		sb.WriteString(" [" + e.Code + "]")
+	}
 
 	// Hint
 	if e.Hint != "" {
@@ -92,60 +103,90 @@ func NewConfigErrorWithHint(file string, line int, message, hint string) *Config
 	}
 }
 
+// NewCodedError creates a configuration error with a code and an optional
+// hint.
+func NewCodedError(code, file string, line int, message, hint string) *ConfigError {
+	return &ConfigError{
+		File:    file,
+		Line:    line,
+		Message: message,
+		Hint:    hint,
+		Code:    code,
+	}
+}
+
+// WithSuggestion sets the error's suggestion to the candidate closest to
+// input, if any is close, and returns the error.
+func (e *ConfigError) WithSuggestion(input string, candidates []string) *ConfigError {
+	e.Suggestion = SuggestClosestMatch(input, candidates)
+	return e
+}
+
 // Common error constructors
 
 // ErrUndefinedAgent creates an error for an undefined agent reference.
 func ErrUndefinedAgent(file string, line int, taskName, agentName string, availableAgents []string) *ConfigError {
-	hint := ""
-	if len(availableAgents) > 0 {
-		// Try to find a close match
-		if suggestion := SuggestClosestMatch(agentName, availableAgents); suggestion != "" {
-			hint = fmt.Sprintf("Did you mean %q? Available agents: %s", suggestion, strings.Join(availableAgents, ", "))
-		} else {
-			hint = fmt.Sprintf("Available agents: %s", strings.Join(availableAgents, ", "))
-		}
-	}
-	return &ConfigError{
+	err := &ConfigError{
 		File:    file,
 		Line:    line,
 		Message: fmt.Sprintf("task %q references undefined agent %q", taskName, agentName),
-		Hint:    hint,
+		Hint:    undefinedAgentHint(availableAgents),
+		Code:    CodeUndefinedAgent,
+	}
+	return err.WithSuggestion(agentName, availableAgents)
+}
+
+// undefinedAgentHint lists the defined agent names.
+func undefinedAgentHint(availableAgents []string) string {
+	if len(availableAgents) == 0 {
+		return ""
 	}
+	return fmt.Sprintf("Available agents: %s", strings.Join(availableAgents, ", "))
 }
 
 // ErrUnsupportedTool creates an error for an unsupported tool.
 func ErrUnsupportedTool(file string, line int, agentName, tool string) *ConfigError {
-	hint := ""
-	if suggestion := SuggestClosestMatch(tool, SupportedTools); suggestion != "" {
-		hint = fmt.Sprintf("Did you mean %q? Supported tools: %s", suggestion, strings.Join(SupportedTools, ", "))
-	} else {
-		hint = fmt.Sprintf("Supported tools: %s", strings.Join(SupportedTools, ", "))
-	}
-	return &ConfigError{
+	err := &ConfigError{
 		File:    file,
 		Line:    line,
 		Message: fmt.Sprintf("agent %q uses unsupported tool %q", agentName, tool),
-		Hint:    hint,
+		Hint:    fmt.Sprintf("Supported tools: %s", strings.Join(SupportedTools, ", ")),
+		Code:    CodeUnsupportedTool,
 	}
+	return err.WithSuggestion(tool, SupportedTools)
 }
 
 // ErrUndefinedDependency creates an error for an undefined task dependency.
 func ErrUndefinedDependency(file string, line int, taskName, depName string, availableTasks []string) *ConfigError {
 	hint := ""
 	if len(availableTasks) > 0 {
-		// Try to find a close match
-		if suggestion := SuggestClosestMatch(depName, availableTasks); suggestion != "" {
-			hint = fmt.Sprintf("Did you mean %q? Available tasks: %s", suggestion, strings.Join(availableTasks, ", "))
-		} else {
-			hint = fmt.Sprintf("Available tasks: %s", strings.Join(availableTasks, ", "))
-		}
+		hint = fmt.Sprintf("Available tasks: %s", strings.Join(availableTasks, ", "))
 	}
-	return &ConfigError{
+	err := &ConfigError{
 		File:    file,
 		Line:    line,
 		Message: fmt.Sprintf("task %q depends on undefined task %q", taskName, depName),
 		Hint:    hint,
+		Code:    CodeUndefinedDependency,
 	}
+	return err.WithSuggestion(depName, availableTasks)
+}
+
+// ErrUndefinedGroupTask creates an error for a group listing an undefined task.
+func ErrUndefinedGroupTask(file string, line int, groupName, taskName string, availableTasks []string) *ConfigError {
+	err := ErrUndefinedDependency(file, line, "", taskName, availableTasks)
+	err.Message = fmt.Sprintf("group %q lists undefined task %q", groupName, taskName)
+	err.Code = CodeUndefinedGroupTask
+	return err
+}
+
+// ErrUndefinedDigestTask creates an error for a digest listing a task
+// that doesn't exist.
+func ErrUndefinedDigestTask(file string, taskName string, availableTasks []string) *ConfigError {
+	err := ErrUndefinedDependency(file, 0, "", taskName, availableTasks)
+	err.Message = fmt.Sprintf("digest lists undefined task %q", taskName)
+	err.Code = CodeUndefinedDigestTask
+	return err
 }
 
 // ErrCircularDependency creates an error for circular dependencies.
@@ -154,6 +195,7 @@ func ErrCircularDependency(file string, cycle []string) *ConfigError {
 		File:    file,
 		Message: fmt.Sprintf("circular dependency detected: %s", strings.Join(cycle, " -> ")),
 		Hint:    "Remove one of the dependencies to break the cycle",
+		Code:    CodeCircularDependency,
 	}
 }
 
@@ -164,6 +206,7 @@ func ErrNoPrompt(file string, line int, taskName string) *ConfigError {
 		Line:    line,
 		Message: fmt.Sprintf("task %q has no prompt defined", taskName),
 		Hint:    "Add either 'prompt:' with inline text or 'prompt_file:' with a file path",
+		Code:    CodeNoPrompt,
 	}
 }
 
@@ -173,7 +216,8 @@ func ErrPromptFileNotFound(file string, line int, taskName, promptFile string) *
 		File:    file,
 		Line:    line,
 		Message: fmt.Sprintf("task %q references prompt file that doesn't exist: %s", taskName, promptFile),
-		Hint:    "Check the file path and ensure the file exists",
+		Hint:    "prompt_file paths are relative to the Cortexfile's directory, or to the workdir",
+		Code:    CodePromptFileNotFound,
 	}
 }
 
@@ -183,6 +227,7 @@ func ErrNoAgents(file string) *ConfigError {
 		File:    file,
 		Message: "no agents defined",
 		Hint:    "Add an 'agents:' section with at least one agent",
+		Code:    CodeNoAgents,
 	}
 }
 
@@ -192,6 +237,7 @@ func ErrNoTasks(file string) *ConfigError {
 		File:    file,
 		Message: "no tasks defined",
 		Hint:    "Add a 'tasks:' section with at least one task",
+		Code:    CodeNoTasks,
 	}
 }
 
@@ -202,6 +248,7 @@ func ErrEmptyAgentName(file string, line int) *ConfigError {
 		Line:    line,
 		Message: "agent name cannot be empty",
 		Hint:    "Provide a valid agent name",
+		Code:    CodeInvalidName,
 	}
 }
 
@@ -212,16 +259,73 @@ func ErrEmptyTaskName(file string, line int) *ConfigError {
 		Line:    line,
 		Message: "task name cannot be empty",
 		Hint:    "Provide a valid task name",
+		Code:    CodeInvalidName,
+	}
+}
+
+// ErrInvalidName creates an error for an agent or task name with
+// characters that aren't allowed; kind is "agent" or "task".
+func ErrInvalidName(file string, line int, kind, name string) *ConfigError {
+	if name == "" {
+		if kind == "agent" {
+			return ErrEmptyAgentName(file, line)
+		}
+		return ErrEmptyTaskName(file, line)
+	}
+	message := fmt.Sprintf("%s name %q may only contain letters, digits, '-', and '_'", kind, name)
+	if len(name) > maxNameLength {
+		message = fmt.Sprintf("%s name %q is longer than %d characters", kind, name, maxNameLength)
+	}
+	hint := "Names become file names and {{outputs.X}} placeholders, and must start with a letter or digit"
+	if normalized := NormalizeName(name); normalized != "" {
+		hint = fmt.Sprintf("Rename it to %q (and update references to it)", normalized)
+	}
+	return &ConfigError{
+		File:    file,
+		Line:    line,
+		Message: message,
+		Hint:    hint,
+		Code:    CodeInvalidName,
 	}
 }
 
 // ErrYAMLParse creates an error for YAML parsing failures.
 func ErrYAMLParse(file string, line int, details string) *ConfigError {
+	return ErrParse(file, line, FormatYAML, details)
+}
+
+// ErrParse creates an error for a config that isn't valid in its format
+// (FormatYAML, FormatJSON, or FormatTOML).
+func ErrParse(file string, line int, format, details string) *ConfigError {
+	hint := "Check YAML syntax - ensure proper indentation and formatting"
+	switch format {
+	case FormatJSON:
+		hint = "Check JSON syntax - look for missing commas, quotes, or closing brackets"
+	case FormatTOML:
+		hint = "Check TOML syntax - strings must be quoted, and each table and key defined once"
+	}
 	return &ConfigError{
 		File:    file,
 		Line:    line,
-		Message: fmt.Sprintf("YAML parse error: %s", details),
-		Hint:    "Check YAML syntax - ensure proper indentation and formatting",
+		Message: fmt.Sprintf("%s parse error: %s", strings.ToUpper(format), details),
+		Hint:    hint,
+		Code:    CodeYAMLParse,
+	}
+}
+
+// ErrUnknownField creates an error for a key that isn't a field of the
+// mapping at path ("" for the top level).
+func ErrUnknownField(file string, line int, key, path string) *ConfigError {
+	message := fmt.Sprintf("unknown field %q", key)
+	if path != "" {
+		message += " in " + path
+	}
+	return &ConfigError{
+		File:    file,
+		Line:    line,
+		Message: message,
+		Hint:    "Fix the field's spelling or remove it; 'cortex schema' lists every field Cortex reads",
+		Code:    CodeUnknownField,
 	}
 }
 
@@ -232,5 +336,17 @@ func ErrSelfDependency(file string, line int, taskName string) *ConfigError {
 		Line:    line,
 		Message: fmt.Sprintf("task %q cannot depend on itself", taskName),
 		Hint:    "Remove the self-reference from the 'needs' list",
+		Code:    CodeSelfDependency,
+	}
+}
+
+// ErrUnresolvedVariables creates an error for ${VAR} references to
+// environment variables that aren't set, found in where (e.g. "workdir").
+func ErrUnresolvedVariables(file, where string, names []string) *ConfigError {
+	return &ConfigError{
+		File:    file,
+		Message: fmt.Sprintf("%s uses unset environment variables: %s", where, formatUnresolved(names)),
+		Hint:    "Set them, give a default with ${VAR:-default}, or write $${ for a literal ${",
+		Code:    CodeUnresolvedVariable,
 	}
 }
@@ -0,0 +1,76 @@
+package config
+
+import (
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// resolveExtends fills in the fields each agent that extends another leaves
+// out with that agent's, after resolving the agent it extends in turn.
+// Agents that extend an undefined agent, or extend each other in a cycle,
+// are left for validation to report.
+func resolveExtends(config *AgentflowConfig) {
+	done := make(map[string]bool)
+	var resolve func(name string, chain []string)
+	resolve = func(name string, chain []string) {
+		agent := config.Agents[name]
+		if done[name] || agent.Extends == "" || slices.Contains(chain, name) {
+			return
+		}
+		parent, ok := config.Agents[agent.Extends]
+		if !ok {
+			return
+		}
+		resolve(agent.Extends, append(chain, name))
+		parent = config.Agents[agent.Extends]
+		config.Agents[name] = inheritAgent(agent, parent)
+		done[name] = true
+	}
+	for _, name := range sortedNames(config.Agents) {
+		resolve(name, nil)
+	}
+}
+
+// inheritAgent returns agent with the fields it leaves out taken from
+// parent. The unset variables of the model go with it.
+func inheritAgent(agent, parent AgentConfig) AgentConfig {
+	if agent.Model == "" {
+		agent.Unresolved = parent.Unresolved
+	}
+	v, p := reflect.ValueOf(&agent).Elem(), reflect.ValueOf(parent)
+	for i := 0; i < v.NumField(); i++ {
+		switch v.Type().Field(i).Name {
+		case "Extends", "Override", "Unresolved":
+			continue
+		}
+		if v.Field(i).IsZero() {
+			v.Field(i).Set(p.Field(i))
+		}
+	}
+	return agent
+}
+
+// extendsCycle returns the cycle of agents extending each other that name
+// starts, e.g. ["a", "b", "a"], or nil if it doesn't start one.
+func extendsCycle(agents map[string]AgentConfig, name string) []string {
+	chain := []string{name}
+	for next := agents[name].Extends; next != ""; next = agents[next].Extends {
+		if _, ok := agents[next]; !ok {
+			return nil
+		}
+		chain = append(chain, next)
+		if next == name {
+			return chain
+		}
+		if slices.Contains(chain[:len(chain)-1], next) {
+			return nil // A cycle name leads into but isn't part of
+		}
+	}
+	return nil
+}
+
+// formatChain formats a chain of agents as "a -> b -> a".
+func formatChain(chain []string) string {
+	return strings.Join(chain, " -> ")
+}
@@ -0,0 +1,93 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseConfig_Extends(t *testing.T) {
+	cfg, err := ParseConfig([]byte(`
+agents:
+  coder:
+    tool: claude-code
+    model: sonnet
+    max_concurrent: 2
+    system_prompt: You write Go.
+  reviewer:
+    extends: coder
+    system_prompt: You review Go.
+  senior:
+    extends: reviewer
+    model: opus
+tasks:
+  review: {agent: senior, prompt: Review}
+`), "/tmp")
+	if err != nil {
+		t.Fatalf("ParseConfig() error = %v", err)
+	}
+
+	want := map[string]AgentConfig{
+		"reviewer": {Tool: "claude-code", Model: "sonnet", MaxConcurrent: 2, SystemPrompt: "You review Go.", Extends: "coder"},
+		"senior":   {Tool: "claude-code", Model: "opus", MaxConcurrent: 2, SystemPrompt: "You review Go.", Extends: "reviewer"},
+	}
+	for name, w := range want {
+		if got := cfg.Agents[name]; got.Tool != w.Tool || got.Model != w.Model || got.MaxConcurrent != w.MaxConcurrent || got.SystemPrompt != w.SystemPrompt {
+			t.Errorf("agent %s = %+v, want %+v", name, got, w)
+		}
+	}
+	if got := cfg.Agents["coder"]; got.SystemPrompt != "You write Go." {
+		t.Errorf("base agent changed: %+v", got)
+	}
+	if err := Validate(cfg); err != nil {
+		t.Errorf("Validate() error = %v", err)
+	}
+}
+
+func TestValidate_Extends(t *testing.T) {
+	tests := []struct {
+		name            string
+		agents          map[string]AgentConfig
+		wantErrContains []string
+	}{
+		{
+			name: "undefined parent",
+			agents: map[string]AgentConfig{
+				"coder":    {Tool: "claude-code"},
+				"reviewer": {Tool: "claude-code", Extends: "codr"},
+			},
+			wantErrContains: []string{`agent "reviewer" extends undefined agent "codr"`, `did you mean "coder"`},
+		},
+		{
+			name: "cycle",
+			agents: map[string]AgentConfig{
+				"coder":    {Tool: "claude-code"},
+				"a":        {Tool: "claude-code", Extends: "b"},
+				"b":        {Tool: "claude-code", Extends: "a"},
+				"reviewer": {Tool: "claude-code", Extends: "a"},
+			},
+			wantErrContains: []string{"agents extend each other in a cycle: a -> b -> a"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &AgentflowConfig{
+				Agents: tt.agents,
+				Tasks:  map[string]TaskConfig{"fix": {Agent: "coder", Prompt: "Fix"}},
+			}
+			resolveExtends(cfg)
+			err := Validate(cfg)
+			if err == nil {
+				t.Fatal("Validate() error = nil")
+			}
+			for _, want := range tt.wantErrContains {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("Validate() error = %v, want %q", err, want)
+				}
+			}
+			if n := strings.Count(err.Error(), "cycle"); n > 1 {
+				t.Errorf("cycle reported %d times", n)
+			}
+		})
+	}
+}
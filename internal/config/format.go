@@ -0,0 +1,172 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Cortexfile formats, chosen by file extension (see FormatOf). JSON and
+// TOML files decode into the same structs as YAML and are validated the
+// same way, for teams that generate their configs.
+const (
+	FormatYAML = "yaml"
+	FormatJSON = "json"
+	FormatTOML = "toml"
+)
+
+// FormatOf returns the format of the Cortexfile at path: FormatJSON for
+// .json, FormatTOML for .toml, and FormatYAML otherwise.
+func FormatOf(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return FormatJSON
+	case ".toml":
+		return FormatTOML
+	}
+	return FormatYAML
+}
+
+// parseDocument parses data in format into a YAML node tree, checking the
+// limits of parseYAML.
+func parseDocument(data []byte, format string) (*yaml.Node, error) {
+	if format == FormatYAML {
+		return parseYAML(data)
+	}
+	if len(data) > MaxConfigBytes {
+		return nil, fmt.Errorf("config is larger than the %s limit", formatLimit(MaxConfigBytes))
+	}
+	var doc *yaml.Node
+	var err error
+	if format == FormatJSON {
+		doc, err = parseJSON(data)
+	} else {
+		doc, err = parseTOML(data)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := checkYAMLLimits(doc); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+// parseJSON parses a JSON document into a YAML node tree. yaml.v3 reads
+// most JSON itself, but not all of it (e.g. the escape \/), so it's
+// decoded token by token instead.
+func parseJSON(data []byte) (*yaml.Node, error) {
+	var newlines []int
+	for i, c := range data {
+		if c == '\n' {
+			newlines = append(newlines, i)
+		}
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	line := func(offset int64) int {
+		return sort.SearchInts(newlines, int(offset)) + 1
+	}
+
+	var value func() (*yaml.Node, error)
+	value = func() (*yaml.Node, error) {
+		// The offset before the token is where the previous one ended,
+		// which is on the token's line but for the first of a line
+		start := skipJSONSpace(data, dec.InputOffset())
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		n := &yaml.Node{Line: line(start)}
+		switch tok := tok.(type) {
+		case json.Delim:
+			if tok == '{' {
+				n.Kind, n.Tag = yaml.MappingNode, "!!map"
+				seen := make(map[string]bool)
+				for dec.More() {
+					key, err := value()
+					if err != nil {
+						return nil, err
+					}
+					if seen[key.Value] {
+						return nil, fmt.Errorf("line %d: key %q is defined twice", key.Line, key.Value)
+					}
+					seen[key.Value] = true
+					v, err := value()
+					if err != nil {
+						return nil, err
+					}
+					n.Content = append(n.Content, key, v)
+				}
+			} else {
+				n.Kind, n.Tag = yaml.SequenceNode, "!!seq"
+				for dec.More() {
+					v, err := value()
+					if err != nil {
+						return nil, err
+					}
+					n.Content = append(n.Content, v)
+				}
+			}
+			if _, err := dec.Token(); err != nil { // Closing delimiter
+				return nil, err
+			}
+		case string:
+			n.Kind, n.Tag, n.Value = yaml.ScalarNode, "!!str", tok
+		case json.Number:
+			n.Kind, n.Tag, n.Value = yaml.ScalarNode, "!!int", tok.String()
+			if strings.ContainsAny(n.Value, ".eE") {
+				n.Tag = "!!float"
+			}
+		case bool:
+			n.Kind, n.Tag, n.Value = yaml.ScalarNode, "!!bool", fmt.Sprint(tok)
+		case nil:
+			n.Kind, n.Tag, n.Value = yaml.ScalarNode, "!!null", "null"
+		}
+		return n, nil
+	}
+
+	doc := &yaml.Node{Kind: yaml.DocumentNode, Line: 1}
+	root, err := value()
+	if errors.Is(err, io.EOF) {
+		return doc, nil // Empty document
+	}
+	if err == nil {
+		if _, err = dec.Token(); err == nil {
+			err = fmt.Errorf("unexpected data after the top-level value")
+		} else if errors.Is(err, io.EOF) {
+			err = nil
+		}
+	}
+	if err != nil {
+		var syntax *json.SyntaxError
+		if errors.As(err, &syntax) {
+			return nil, fmt.Errorf("line %d: %s", line(syntax.Offset-1), syntax)
+		}
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil, fmt.Errorf("line %d: unexpected end of JSON input", line(int64(len(data))))
+		}
+		if !strings.HasPrefix(err.Error(), "line ") {
+			err = fmt.Errorf("line %d: %w", line(dec.InputOffset()), err)
+		}
+		return nil, err
+	}
+	doc.Content = []*yaml.Node{root}
+	return doc, nil
+}
+
+// skipJSONSpace returns the offset of the first byte at or after offset
+// that isn't whitespace or a separator, where the next token starts.
+func skipJSONSpace(data []byte, offset int64) int64 {
+	for offset < int64(len(data)) && strings.IndexByte(" \t\r\n,:", data[offset]) >= 0 {
+		offset++
+	}
+	return offset
+}
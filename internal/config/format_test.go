@@ -0,0 +1,186 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLoadConfig_Formats(t *testing.T) {
+	files := map[string]string{
+		"Cortexfile.yml": `
+agents:
+  coder: {tool: claude-code, model: sonnet}
+  sh: {tool: shell}
+variables:
+  pkg: ./...
+tasks:
+  review:
+    agent: coder
+    prompt: "Review the \"api\" package"
+    write: patch
+    tags: [api, web]
+    retries: 2
+  test:
+    agent: sh
+    command: [go, test, "{{vars.pkg}}"]
+    needs: review
+settings:
+  max_parallel: 4
+`,
+		"Cortexfile.json": `{
+	"agents": {
+		"coder": {"tool": "claude-code", "model": "sonnet"},
+		"sh": {"tool": "shell"}
+	},
+	"variables": {"pkg": ".\/..."},
+	"tasks": {
+		"review": {
+			"agent": "coder",
+			"prompt": "Review the \"api\" package",
+			"write": "patch",
+			"tags": ["api", "web"],
+			"retries": 2
+		},
+		"test": {"agent": "sh", "command": ["go", "test", "{{vars.pkg}}"], "needs": "review"}
+	},
+	"settings": {"max_parallel": 4}
+}`,
+		"Cortexfile.toml": `
+variables = { pkg = './...' }
+
+[agents.coder]
+tool = "claude-code"
+model = "sonnet" # Comments work
+
+[agents.sh]
+tool = "shell"
+
+[tasks.review]
+agent = "coder"
+prompt = """
+Review the "api" package"""
+write = "patch"
+tags = [
+  "api",
+  "web", # Trailing commas too
+]
+retries = 2
+
+[tasks.test]
+agent = "sh"
+command = ["go", "test", "{{vars.pkg}}"]
+needs = "review"
+
+[settings]
+max_parallel = 0x4
+`,
+	}
+
+	dir := t.TempDir()
+	configs := make(map[string]*AgentflowConfig)
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		cfg, err := LoadConfig(path)
+		if err != nil {
+			t.Fatalf("LoadConfig(%s) error = %v", name, err)
+		}
+		if err := ValidateWithFile(cfg, path); err != nil {
+			t.Errorf("ValidateWithFile(%s) error = %v", name, err)
+		}
+		configs[name] = cfg
+	}
+
+	// Definitions are on different lines in each format
+	for _, cfg := range configs {
+		cfg.Lines = nil
+	}
+	want := configs["Cortexfile.yml"]
+	if review := want.Tasks["review"]; !review.WritePatch || review.Retries != 2 {
+		t.Fatalf("YAML review = %+v", review)
+	}
+	for _, name := range []string{"Cortexfile.json", "Cortexfile.toml"} {
+		if got := configs[name]; !reflect.DeepEqual(got, want) {
+			t.Errorf("LoadConfig(%s) = %+v, want %+v", name, got, want)
+		}
+	}
+}
+
+func TestParseDocument_Errors(t *testing.T) {
+	tests := []struct {
+		format string
+		data   string
+		want   string
+	}{
+		{FormatJSON, "{\n  \"agents\": {\n    \"a\": 1,\n  }\n}", "line 3: invalid character ','"},
+		{FormatJSON, `{"tasks": {}, "tasks": {}}`, `key "tasks" is defined twice`},
+		{FormatJSON, `{"tasks": {}`, "unexpected end of JSON input"},
+		{FormatTOML, "[agents]\ncoder = claude", `line 2: invalid value "claude" (strings must be quoted)`},
+		{FormatTOML, "[tasks.a]\nagent = \"x\"\n[tasks.a]\n", "line 3: table [tasks.a] is defined twice"},
+		{FormatTOML, "a = 1\na = 2\n", "line 2: a is defined twice"},
+		{FormatTOML, "a = \"open\n", "line 1: unterminated string"},
+		{FormatTOML, "a = {b = 1}\n[a.c]\n", "a is already defined and isn't a table"},
+		{FormatTOML, "a = 1 b = 2\n", "expected the end of the line"},
+	}
+	for _, tt := range tests {
+		_, err := parseDocument([]byte(tt.data), tt.format)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("parseDocument(%s, %q) error = %v, want %q", tt.format, tt.data, err, tt.want)
+		}
+	}
+}
+
+func TestParseTOML_Values(t *testing.T) {
+	doc, err := parseTOML([]byte(`
+"quoted key" = 'C:\path'
+site."google.com" = true
+ints = [1_000, 0o17, 0b101, +7]
+floats = [3.5, 1e3, -inf]
+escapes = "tab\there \u00e9"
+joined = """one \
+         two"""
+literal = '''a\b
+c'''
+when = 1979-05-27 07:32:00Z
+
+[[fruits]]
+name = "apple"
+[fruits.color]
+main = "red"
+[[fruits]]
+name = "pear"
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got map[string]any
+	if err := doc.Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]any{
+		"quoted key": `C:\path`,
+		"site":       map[string]any{"google.com": true},
+		"ints":       []any{1000, 15, 5, 7},
+		"escapes":    "tab\there é",
+		"joined":     "one two",
+		"literal":    "a\\b\nc",
+		"when":       "1979-05-27 07:32:00Z",
+		"fruits": []any{
+			map[string]any{"name": "apple", "color": map[string]any{"main": "red"}},
+			map[string]any{"name": "pear"},
+		},
+	}
+	floats := got["floats"]
+	delete(got, "floats")
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseTOML() = %#v, want %#v", got, want)
+	}
+	if f, ok := floats.([]any); !ok || len(f) != 3 || f[0] != 3.5 || f[1] != 1000.0 {
+		t.Errorf("floats = %#v", floats)
+	}
+}
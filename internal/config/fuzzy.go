@@ -1,55 +1,49 @@
 package config
 
 import (
+	"fmt"
+	"sort"
 	"strings"
 )
 
 // LevenshteinDistance calculates the edit distance between two strings.
 // This is the minimum number of single-character edits (insertions, deletions,
 // or substitutions) required to change one string into the other.
+// Comparison is case-insensitive and by character, not byte.
 func LevenshteinDistance(s1, s2 string) int {
-	s1 = strings.ToLower(s1)
-	s2 = strings.ToLower(s2)
+	r1 := []rune(strings.ToLower(s1))
+	r2 := []rune(strings.ToLower(s2))
 
-	if len(s1) == 0 {
-		return len(s2)
+	// Keep the shorter string along the row, so memory is O(min(m, n))
+	if len(r1) < len(r2) {
+		r1, r2 = r2, r1
 	}
-	if len(s2) == 0 {
-		return len(s1)
+	if len(r2) == 0 {
+		return len(r1)
 	}
 
-	// Create matrix
-	rows := len(s1) + 1
-	cols := len(s2) + 1
-	matrix := make([][]int, rows)
-	for i := range matrix {
-		matrix[i] = make([]int, cols)
+	// Only the previous and current rows of the matrix are needed
+	prev := make([]int, len(r2)+1)
+	curr := make([]int, len(r2)+1)
+	for j := range prev {
+		prev[j] = j
 	}
-
-	// Initialize first row and column
-	for i := 0; i <= len(s1); i++ {
-		matrix[i][0] = i
-	}
-	for j := 0; j <= len(s2); j++ {
-		matrix[0][j] = j
-	}
-
-	// Fill in the rest of the matrix
-	for i := 1; i <= len(s1); i++ {
-		for j := 1; j <= len(s2); j++ {
+	for i := 1; i <= len(r1); i++ {
+		curr[0] = i
+		for j := 1; j <= len(r2); j++ {
 			cost := 1
-			if s1[i-1] == s2[j-1] {
+			if r1[i-1] == r2[j-1] {
 				cost = 0
 			}
-			matrix[i][j] = min(
-				matrix[i-1][j]+1,      // deletion
-				matrix[i][j-1]+1,      // insertion
-				matrix[i-1][j-1]+cost, // substitution
+			curr[j] = min(
+				prev[j]+1,      // deletion
+				curr[j-1]+1,    // insertion
+				prev[j-1]+cost, // substitution
 			)
 		}
+		prev, curr = curr, prev
 	}
-
-	return matrix[len(s1)][len(s2)]
+	return prev[len(r2)]
 }
 
 // FindClosestMatch finds the closest matching string from a list of candidates.
@@ -82,44 +76,200 @@ func FindClosestMatch(input string, candidates []string, maxDistance int) (strin
 	return bestMatch, bestDistance
 }
 
-// SuggestClosestMatch returns a suggestion string if a close match is found.
-// Returns empty string if no good match is found.
-func SuggestClosestMatch(input string, candidates []string) string {
-	if len(candidates) == 0 {
-		return ""
+// Suggestion is a candidate name close to what the user typed.
+type Suggestion struct {
+	Value    string
+	Distance int     // Edit distance from the input
+	Score    float64 // Similarity from 0 (nothing alike) to 1 (same but for case)
+}
+
+// minSuggestScore is the Similarity that makes a candidate worth suggesting
+// even when it needs more edits than suggestThreshold allows.
+const minSuggestScore = 0.8
+
+// SuggestAll returns up to n candidates close enough to input to suggest,
+// best first by Similarity. Candidates equal to input are skipped, since
+// they can't be what the user meant instead. n <= 0 returns every close
+// candidate.
+func SuggestAll(input string, candidates []string, n int) []Suggestion {
+	maxDistance := suggestThreshold(input)
+	var suggestions []Suggestion
+	for _, candidate := range candidates {
+		if candidate == input {
+			continue
+		}
+		distance := LevenshteinDistance(input, candidate)
+		score := Similarity(input, candidate)
+		if distance > maxDistance && score < minSuggestScore {
+			continue
+		}
+		suggestions = append(suggestions, Suggestion{Value: candidate, Distance: distance, Score: score})
 	}
 
-	// Use a threshold based on the input length
-	// For short strings (1-4 chars), allow up to 2 edits
-	// For longer strings, allow up to len/2 edits
-	maxDistance := len(input) / 2
-	if maxDistance < 2 {
-		maxDistance = 2
+	sort.Slice(suggestions, func(i, j int) bool {
+		a, b := suggestions[i], suggestions[j]
+		if a.Score != b.Score {
+			return a.Score > b.Score
+		}
+		return a.Value < b.Value
+	})
+	if n > 0 && len(suggestions) > n {
+		suggestions = suggestions[:n]
 	}
-	if maxDistance > 5 {
-		maxDistance = 5 // Cap at 5 to avoid suggesting very different strings
+	return suggestions
+}
+
+// Similarity scores how alike two names are, from 0 to 1, ignoring case.
+// It averages edit distance, which handles typos anywhere, with
+// Jaro-Winkler, which favors a shared prefix and forgives transpositions.
+// Names made of words (analyze-backend, run_tests) are also compared word
+// by word, so "backend" is close to "analyze-backend", and the better of
+// the two scores counts.
+func Similarity(a, b string) float64 {
+	a, b = strings.ToLower(a), strings.ToLower(b)
+	longest := max(len([]rune(a)), len([]rune(b)))
+	if longest == 0 {
+		return 1
 	}
+	edit := 1 - float64(LevenshteinDistance(a, b))/float64(longest)
+	return max((edit+JaroWinkler(a, b))/2, tokenSimilarity(a, b))
+}
 
-	match, distance := FindClosestMatch(input, candidates, maxDistance)
+// tokenSimilarity compares the words of two names: each word is matched
+// with the most similar word of the other name, and the average match is
+// taken in both directions.
+func tokenSimilarity(a, b string) float64 {
+	ta, tb := nameTokens(a), nameTokens(b)
+	if len(ta) < 2 && len(tb) < 2 {
+		return 0 // Single words are compared whole
+	}
+	return (bestMatches(ta, tb) + bestMatches(tb, ta)) / 2
+}
 
-	// Only suggest if the match is reasonably close
-	if match != "" && distance > 0 && distance <= maxDistance {
-		return match
+// bestMatches averages, over the words of from, the Jaro-Winkler
+// similarity of each to its closest word in to.
+func bestMatches(from, to []string) float64 {
+	if len(from) == 0 || len(to) == 0 {
+		return 0
+	}
+	var total float64
+	for _, f := range from {
+		var best float64
+		for _, t := range to {
+			best = max(best, JaroWinkler(f, t))
+		}
+		total += best
 	}
+	return total / float64(len(from))
+}
 
-	return ""
+// nameTokens splits a name into words at '-', '_', '.', '/', and spaces.
+func nameTokens(name string) []string {
+	return strings.FieldsFunc(name, func(r rune) bool {
+		return r == '-' || r == '_' || r == '.' || r == '/' || r == ' '
+	})
 }
 
-// min returns the minimum of three integers.
-func min(a, b, c int) int {
-	if a < b {
-		if a < c {
-			return a
+// JaroWinkler returns the Jaro-Winkler similarity of two strings, from 0
+// to 1: the Jaro similarity, boosted for a common prefix of up to four
+// characters. Comparison is case-insensitive.
+func JaroWinkler(a, b string) float64 {
+	r1 := []rune(strings.ToLower(a))
+	r2 := []rune(strings.ToLower(b))
+	if len(r1) == 0 && len(r2) == 0 {
+		return 1
+	}
+	if len(r1) == 0 || len(r2) == 0 {
+		return 0
+	}
+
+	// Characters match if equal and no further apart than the window
+	window := max(max(len(r1), len(r2))/2-1, 0)
+	matched1 := make([]bool, len(r1))
+	matched2 := make([]bool, len(r2))
+	matches := 0
+	for i := range r1 {
+		for j := max(0, i-window); j < min(len(r2), i+window+1); j++ {
+			if !matched2[j] && r1[i] == r2[j] {
+				matched1[i], matched2[j] = true, true
+				matches++
+				break
+			}
+		}
+	}
+	if matches == 0 {
+		return 0
+	}
+
+	// Matched characters in a different order count as half transpositions
+	transpositions := 0
+	j := 0
+	for i := range r1 {
+		if !matched1[i] {
+			continue
 		}
-		return c
+		for !matched2[j] {
+			j++
+		}
+		if r1[i] != r2[j] {
+			transpositions++
+		}
+		j++
+	}
+
+	m := float64(matches)
+	jaro := (m/float64(len(r1)) + m/float64(len(r2)) + (m-float64(transpositions)/2)/m) / 3
+
+	prefix := 0
+	for prefix < min(4, len(r1), len(r2)) && r1[prefix] == r2[prefix] {
+		prefix++
+	}
+	return jaro + float64(prefix)*0.1*(1-jaro)
+}
+
+// suggestThreshold is the most edits a suggestion for input may need:
+// up to 2 for short strings (1-4 chars), len/2 for longer ones, capped at
+// 5 to avoid suggesting very different strings.
+func suggestThreshold(input string) int {
+	return min(max(len([]rune(input))/2, 2), 5)
+}
+
+// SuggestClosestMatch returns a suggestion string if a close match is found.
+// Returns empty string if no good match is found.
+func SuggestClosestMatch(input string, candidates []string) string {
+	if s := SuggestAll(input, candidates, 1); len(s) > 0 {
+		return s[0].Value
+	}
+	return ""
+}
+
+// MaxSuggestions is how many suggestions DidYouMean offers.
+const MaxSuggestions = 3
+
+// DidYouMean lists the candidates closest to input for a "did you mean"
+// hint, e.g. `"review"` or `"lint", "list", or "last"`. Returns "" if none
+// is close.
+func DidYouMean(input string, candidates []string) string {
+	suggestions := SuggestAll(input, candidates, MaxSuggestions)
+	values := make([]string, len(suggestions))
+	for i, s := range suggestions {
+		values[i] = s.Value
+	}
+	return JoinOr(values)
+}
+
+// JoinOr quotes values and joins them as alternatives: `"a"`, `"a" or "b"`,
+// or `"a", "b", or "c"`.
+func JoinOr(values []string) string {
+	quoted := make([]string, len(values))
+	for i, v := range values {
+		quoted[i] = fmt.Sprintf("%q", v)
 	}
-	if b < c {
-		return b
+	switch len(quoted) {
+	case 0, 1:
+		return strings.Join(quoted, "")
+	case 2:
+		return quoted[0] + " or " + quoted[1]
 	}
-	return c
+	return strings.Join(quoted[:len(quoted)-1], ", ") + ", or " + quoted[len(quoted)-1]
 }
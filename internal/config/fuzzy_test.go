@@ -0,0 +1,102 @@
+package config
+
+import "testing"
+
+func TestLevenshteinDistance(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"", "abc", 3},
+		{"abc", "", 3},
+		{"review", "review", 0},
+		{"Review", "review", 0},
+		{"reveiw", "review", 2},
+		{"kitten", "sitting", 3},
+		{"sitting", "kitten", 3},
+		{"café", "cafe", 1},
+	}
+	for _, tt := range tests {
+		if got := LevenshteinDistance(tt.a, tt.b); got != tt.want {
+			t.Errorf("LevenshteinDistance(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestSuggestAll(t *testing.T) {
+	candidates := []string{"lint", "list", "last", "review", "implement"}
+
+	got := SuggestAll("lisr", candidates, 0)
+	if len(got) != 3 || got[0].Value != "list" {
+		t.Fatalf("SuggestAll(lisr) = %+v, want list first of 3", got)
+	}
+	for i := 1; i < len(got); i++ {
+		if got[i].Score > got[i-1].Score {
+			t.Errorf("SuggestAll(lisr) not ranked: %+v", got)
+		}
+	}
+	if got := SuggestAll("lisr", candidates, 1); len(got) != 1 {
+		t.Errorf("SuggestAll(lisr, n=1) = %+v, want 1 suggestion", got)
+	}
+	if got := SuggestAll("review", candidates, 0); len(got) != 0 {
+		t.Errorf("SuggestAll(review) = %+v, want no suggestions for an exact match", got)
+	}
+	if got := SuggestAll("deploy", candidates, 0); len(got) != 0 {
+		t.Errorf("SuggestAll(deploy) = %+v, want none", got)
+	}
+
+	if got := DidYouMean("revew", candidates); got != `"review"` {
+		t.Errorf("DidYouMean(revew) = %s", got)
+	}
+	if got := DidYouMean("lisr", candidates); got != `"list", "lint", or "last"` { // Shared prefix ranks lint first
+		t.Errorf("DidYouMean(lisr) = %s", got)
+	}
+}
+
+func TestJaroWinkler(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want float64
+	}{
+		{"", "", 1},
+		{"abc", "", 0},
+		{"martha", "marhta", 0.961},
+		{"dixon", "dicksonx", 0.813},
+		{"Review", "review", 1},
+		{"abc", "xyz", 0},
+	}
+	for _, tt := range tests {
+		if got := JaroWinkler(tt.a, tt.b); got < tt.want-0.001 || got > tt.want+0.001 {
+			t.Errorf("JaroWinkler(%q, %q) = %.3f, want %.3f", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestSuggestAllRanking(t *testing.T) {
+	candidates := []string{"analyze-frontend", "analyze-backend", "deploy-backend", "lint"}
+
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"analyze-backnd", "analyze-backend"},
+		{"analyze-frontnd", "analyze-frontend"},
+		{"backend-analyze", "analyze-backend"}, // Words in another order
+		{"lnit", "lint"},                       // Transposition
+	}
+	for _, tt := range tests {
+		got := SuggestAll(tt.input, candidates, 0)
+		if len(got) == 0 || got[0].Value != tt.want {
+			t.Errorf("SuggestAll(%q) = %+v, want %q first", tt.input, got, tt.want)
+		}
+	}
+
+	// A single word of a longer name is enough to find it
+	if got := SuggestAll("frontend", candidates, 1); len(got) != 1 || got[0].Value != "analyze-frontend" {
+		t.Errorf("SuggestAll(frontend) = %+v, want analyze-frontend", got)
+	}
+	if got := SuggestAll("release", candidates, 0); len(got) != 0 {
+		t.Errorf("SuggestAll(release) = %+v, want none", got)
+	}
+}
@@ -1,11 +1,10 @@
 package config
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
 	"runtime"
-
-	"gopkg.in/yaml.v3"
 )
 
 // GlobalConfig represents the global ~/.cortex/config.yml configuration.
@@ -13,6 +12,8 @@ type GlobalConfig struct {
 	Defaults DefaultsConfig  `yaml:"defaults"`
 	Settings SettingsConfig  `yaml:"settings"`
 	Webhooks []WebhookConfig `yaml:"webhooks"`
+	Emails   []EmailConfig   `yaml:"emails"`
+	Alerts   []AlertConfig   `yaml:"alerts"`
 }
 
 // DefaultsConfig contains default agent settings.
@@ -27,6 +28,149 @@ type SettingsConfig struct {
 	MaxParallel int  `yaml:"max_parallel"` // Max concurrent tasks (default: CPU cores)
 	Verbose     bool `yaml:"verbose"`      // Verbose output
 	Stream      bool `yaml:"stream"`       // Stream agent logs
+
+	// DirtyTree decides what happens when write tasks would run on a git tree
+	// with uncommitted changes: "refuse", "stash", or "proceed" (default).
+	DirtyTree string `yaml:"dirty_tree"`
+
+	// Snapshot captures the workdir before runs with write tasks so
+	// `cortex rollback <run-id>` can restore it.
+	Snapshot bool `yaml:"snapshot"`
+
+	// Workspace runs each run in its own copy of the repository, under
+	// .cortex/workspaces/<run-id>, leaving the checkout untouched; the
+	// changes of a successful run are offered as a patch to apply.
+	Workspace bool `yaml:"workspace"`
+
+	// Heartbeat is the number of seconds a task runs before it reports
+	// progress, and between reports (0 = 30, negative = off).
+	Heartbeat int `yaml:"heartbeat"`
+
+	// MaxTokens caps the input and output tokens used by the whole run.
+	// Running tasks are stopped once it is exceeded (0 = no limit).
+	MaxTokens int `yaml:"max_tokens"`
+
+	// Schedule limits when runs start and the tokens and cost they may use
+	// per day (nil = no limits).
+	Schedule *ScheduleConfig `yaml:"schedule"`
+
+	// Base is the git ref that diff-scoped tasks compare against
+	// (default: the origin remote's default branch).
+	Base string `yaml:"base"`
+
+	// Shell runs shell tasks' commands and verify commands: "sh", "bash",
+	// "pwsh", "cmd", or a path (default: /bin/sh; on Windows, sh if on
+	// PATH, else cmd.exe).
+	Shell string `yaml:"shell"`
+
+	// MaxCPU and MaxMemory defer starting parallel tasks while system-wide
+	// CPU or memory use is above these percentages, until running tasks
+	// ease off (0 = no limit).
+	MaxCPU    int `yaml:"max_cpu"`
+	MaxMemory int `yaml:"max_memory"`
+
+	// MinDiskMB is the free space, in MiB, a run needs on the disks it
+	// writes to: session storage, working directories, and the temp
+	// directory. Runs don't start with less, tasks wait for space while
+	// there is less, and running tasks are stopped below half of it
+	// (0 = 512, negative = off).
+	MinDiskMB int `yaml:"min_disk_mb"`
+
+	// Proxy is the proxy URL for outbound HTTP and HTTPS connections,
+	// including the tools Cortex runs (default: HTTPS_PROXY / HTTP_PROXY).
+	// NoProxy lists hosts to reach directly (default: NO_PROXY).
+	Proxy   string `yaml:"proxy"`
+	NoProxy string `yaml:"no_proxy"`
+
+	// CABundle is a PEM file of CA certificates to trust in addition to
+	// the system's, e.g. for a TLS-inspecting corporate proxy.
+	CABundle string `yaml:"ca_bundle"`
+
+	// MaxDepth and MaxNeeds are when 'cortex validate' warns about a
+	// dependency chain of more than MaxDepth tasks or a task that needs more
+	// than MaxNeeds others (0 = 10 and 8, negative = off).
+	MaxDepth int `yaml:"max_depth"`
+	MaxNeeds int `yaml:"max_needs"`
+
+	// Encrypt encrypts run files, the project memory, and the --log-file
+	// with AES-256-GCM, using the key in CORTEX_STATE_KEY (see the crypt
+	// package). Commands that read runs decrypt them with the same key.
+	Encrypt bool `yaml:"encrypt"`
+
+	// Retention controls what run files keep of rendered prompts and task
+	// output, for projects that can't store proprietary code in them
+	// (nil = keep everything).
+	Retention *RetentionConfig `yaml:"retention"`
+
+	// Store is where run records are kept: "json" (default), run.json files
+	// in each run directory, or "sqlite", ~/.cortex/cortex.db. Only the
+	// global config and CORTEX_STORE can set it, so every command reads
+	// runs from the same place.
+	Store string `yaml:"store"`
+}
+
+// RetentionConfig controls what session storage keeps of each task's
+// result. It applies to run files and the run's HTML report.
+type RetentionConfig struct {
+	// Prompts is "full" (default), "hash" to keep only a SHA-256 hash of
+	// each rendered prompt, or "none".
+	Prompts string `yaml:"prompts"`
+
+	// Redact lists result fields whose text is replaced with "[redacted]":
+	// "stdout", "stderr", "transcript", and "verify_output".
+	Redact StringList `yaml:"redact"`
+}
+
+// Prompt retention modes.
+const (
+	RetainFull = "full"
+	RetainHash = "hash"
+	RetainNone = "none"
+)
+
+// Redactable task result fields.
+const (
+	RedactStdout     = "stdout"
+	RedactStderr     = "stderr"
+	RedactTranscript = "transcript" // Tool call summaries, inputs, and outputs
+	RedactVerify     = "verify_output"
+)
+
+// Validate checks the retention settings. A nil config is valid.
+func (r *RetentionConfig) Validate() error {
+	if r == nil {
+		return nil
+	}
+	switch r.Prompts {
+	case "", RetainFull, RetainHash, RetainNone:
+	default:
+		return fmt.Errorf("settings.retention: invalid prompts %q (use 'full', 'hash', or 'none')", r.Prompts)
+	}
+	for _, field := range r.Redact {
+		switch field {
+		case RedactStdout, RedactStderr, RedactTranscript, RedactVerify:
+		default:
+			return fmt.Errorf("settings.retention: cannot redact %q (use 'stdout', 'stderr', 'transcript', or 'verify_output')", field)
+		}
+	}
+	return nil
+}
+
+// Dirty working tree policies.
+const (
+	DirtyTreeRefuse  = "refuse"  // Fail the run before any task starts
+	DirtyTreeStash   = "stash"   // Stash uncommitted work and restore it after the run
+	DirtyTreeProceed = "proceed" // Run anyway, mixing agent changes with existing work
+)
+
+// IsValidDirtyTreePolicy checks if a dirty_tree value is supported.
+// An empty value selects the default policy.
+func IsValidDirtyTreePolicy(policy string) bool {
+	switch policy {
+	case "", DirtyTreeRefuse, DirtyTreeStash, DirtyTreeProceed:
+		return true
+	}
+	return false
 }
 
 // WebhookConfig defines a webhook endpoint.
@@ -36,6 +180,61 @@ type WebhookConfig struct {
 	Headers map[string]string `yaml:"headers"`
 }
 
+// EmailConfig sends an email over SMTP when a run finishes.
+type EmailConfig struct {
+	SMTP        string   `yaml:"smtp"`         // Server as host:port; port 465 uses TLS, others STARTTLS when offered
+	Username    string   `yaml:"username"`     // Login, if the server requires one
+	PasswordEnv string   `yaml:"password_env"` // Env var holding the password (default: CORTEX_SMTP_PASSWORD)
+	From        string   `yaml:"from"`
+	To          []string `yaml:"to"`
+
+	// On selects the runs to email about: "always" (default), "failure",
+	// or "success".
+	On string `yaml:"on"`
+
+	// Subject and Body are Go templates (text/template) over the run; see
+	// the email package for the fields they can use.
+	Subject string `yaml:"subject"`
+	Body    string `yaml:"body"`
+
+	// AttachReport attaches the run's HTML report.
+	AttachReport bool `yaml:"attach_report"`
+}
+
+// Email conditions.
+const (
+	EmailAlways  = "always"
+	EmailFailure = "failure"
+	EmailSuccess = "success"
+)
+
+// AlertConfig opens an incident in PagerDuty or Opsgenie when runs of a
+// workflow fail several times in a row, and resolves it once one succeeds.
+type AlertConfig struct {
+	Provider string `yaml:"provider"` // "pagerduty" or "opsgenie"
+	KeyEnv   string `yaml:"key_env"`  // Env var holding the routing or API key (default: CORTEX_PAGERDUTY_KEY or CORTEX_OPSGENIE_KEY)
+	After    int    `yaml:"after"`    // Consecutive failed runs before alerting (default: 3)
+	URL      string `yaml:"url"`      // API endpoint (default: the provider's US endpoint)
+}
+
+// Alert providers.
+const (
+	AlertPagerDuty = "pagerduty"
+	AlertOpsgenie  = "opsgenie"
+)
+
+// MatchesRun checks if an email should be sent for a run that succeeded
+// or not.
+func (e *EmailConfig) MatchesRun(success bool) bool {
+	switch e.On {
+	case EmailFailure:
+		return !success
+	case EmailSuccess:
+		return success
+	}
+	return true
+}
+
 // DefaultSettings returns the default settings.
 func DefaultSettings() SettingsConfig {
 	return SettingsConfig{
@@ -43,6 +242,7 @@ func DefaultSettings() SettingsConfig {
 		MaxParallel: runtime.NumCPU(),
 		Verbose:     false,
 		Stream:      false,
+		DirtyTree:   DirtyTreeProceed,
 	}
 }
 
@@ -60,7 +260,7 @@ func LoadGlobalConfig() (*GlobalConfig, error) {
 
 // LoadGlobalConfigFromPath loads global config from a specific path.
 func LoadGlobalConfigFromPath(path string) (*GlobalConfig, error) {
-	data, err := os.ReadFile(path)
+	data, err := readLimited(path, MaxConfigBytes, "global config")
 	if err != nil {
 		if os.IsNotExist(err) {
 			return defaultGlobalConfig(), nil
@@ -69,13 +269,18 @@ func LoadGlobalConfigFromPath(path string) (*GlobalConfig, error) {
 	}
 
 	var config GlobalConfig
-	if err := yaml.Unmarshal(data, &config); err != nil {
+	if err := decodeYAML(data, &config); err != nil {
 		return nil, err
 	}
 
 	// Apply defaults for unset values
 	applyDefaults(&config)
 
+	// Paths are relative to the config file
+	if bundle := config.Settings.CABundle; bundle != "" && !filepath.IsAbs(bundle) {
+		config.Settings.CABundle = filepath.Join(filepath.Dir(path), bundle)
+	}
+
 	return &config, nil
 }
 
@@ -93,6 +298,9 @@ func applyDefaults(config *GlobalConfig) {
 	if config.Settings.MaxParallel <= 0 {
 		config.Settings.MaxParallel = defaults.MaxParallel
 	}
+	if config.Settings.DirtyTree == "" {
+		config.Settings.DirtyTree = defaults.DirtyTree
+	}
 	// Note: Parallel defaults to false from YAML, so we check if it was explicitly set
 	// This is handled by the caller with CLI flags taking precedence
 }
@@ -108,6 +316,8 @@ type MergedConfig struct {
 
 	// From global config
 	Webhooks []WebhookConfig
+	Emails   []EmailConfig
+	Alerts   []AlertConfig
 
 	// Defaults for agents
 	Defaults DefaultsConfig
@@ -116,10 +326,20 @@ type MergedConfig struct {
 // MergeConfigs combines global config, local Cortexfile, and CLI flags.
 // Priority: CLI flags > Cortexfile settings > Global config
 func MergeConfigs(global *GlobalConfig, local *AgentflowConfig, cliSettings *SettingsConfig) *MergedConfig {
+	merged, _ := MergeConfigsWithEnv(global, local, cliSettings, nil)
+	return merged
+}
+
+// MergeConfigsWithEnv is MergeConfigs with CORTEX_* environment variables
+// (see ApplyEnv) read through lookup, if not nil.
+// Priority: CLI flags > environment > Cortexfile settings > Global config
+func MergeConfigsWithEnv(global *GlobalConfig, local *AgentflowConfig, cliSettings *SettingsConfig, lookup func(string) (string, bool)) (*MergedConfig, error) {
 	merged := &MergedConfig{
 		Agents:   local.Agents,
 		Tasks:    local.Tasks,
 		Webhooks: global.Webhooks,
+		Emails:   global.Emails,
+		Alerts:   global.Alerts,
 		Defaults: global.Defaults,
 	}
 
@@ -136,6 +356,61 @@ func MergeConfigs(global *GlobalConfig, local *AgentflowConfig, cliSettings *Set
 		merged.Settings.Parallel = local.Settings.Parallel
 		merged.Settings.Verbose = local.Settings.Verbose || merged.Settings.Verbose
 		merged.Settings.Stream = local.Settings.Stream || merged.Settings.Stream
+		if local.Settings.DirtyTree != "" {
+			merged.Settings.DirtyTree = local.Settings.DirtyTree
+		}
+		merged.Settings.Snapshot = local.Settings.Snapshot || merged.Settings.Snapshot
+		merged.Settings.Workspace = local.Settings.Workspace || merged.Settings.Workspace
+		if local.Settings.Heartbeat != 0 {
+			merged.Settings.Heartbeat = local.Settings.Heartbeat
+		}
+		if local.Settings.MaxTokens > 0 {
+			merged.Settings.MaxTokens = local.Settings.MaxTokens
+		}
+		if local.Settings.Schedule != nil {
+			merged.Settings.Schedule = local.Settings.Schedule
+		}
+		if local.Settings.Base != "" {
+			merged.Settings.Base = local.Settings.Base
+		}
+		if local.Settings.Shell != "" {
+			merged.Settings.Shell = local.Settings.Shell
+		}
+		if local.Settings.MaxCPU > 0 {
+			merged.Settings.MaxCPU = local.Settings.MaxCPU
+		}
+		if local.Settings.MaxMemory > 0 {
+			merged.Settings.MaxMemory = local.Settings.MaxMemory
+		}
+		if local.Settings.MinDiskMB != 0 {
+			merged.Settings.MinDiskMB = local.Settings.MinDiskMB
+		}
+		if local.Settings.Proxy != "" {
+			merged.Settings.Proxy = local.Settings.Proxy
+		}
+		if local.Settings.NoProxy != "" {
+			merged.Settings.NoProxy = local.Settings.NoProxy
+		}
+		if local.Settings.CABundle != "" {
+			merged.Settings.CABundle = local.Settings.CABundle
+		}
+		if local.Settings.MaxDepth != 0 {
+			merged.Settings.MaxDepth = local.Settings.MaxDepth
+		}
+		if local.Settings.MaxNeeds != 0 {
+			merged.Settings.MaxNeeds = local.Settings.MaxNeeds
+		}
+		merged.Settings.Encrypt = local.Settings.Encrypt || merged.Settings.Encrypt
+		if local.Settings.Retention != nil {
+			merged.Settings.Retention = local.Settings.Retention
+		}
+	}
+
+	// Override with environment variables
+	if lookup != nil {
+		if err := ApplyEnv(&merged.Settings, lookup); err != nil {
+			return nil, err
+		}
 	}
 
 	// Override with CLI flags (highest priority)
@@ -146,6 +421,17 @@ func MergeConfigs(global *GlobalConfig, local *AgentflowConfig, cliSettings *Set
 		// CLI flags always win
 		merged.Settings.Verbose = cliSettings.Verbose || merged.Settings.Verbose
 		merged.Settings.Stream = cliSettings.Stream || merged.Settings.Stream
+		if cliSettings.DirtyTree != "" {
+			merged.Settings.DirtyTree = cliSettings.DirtyTree
+		}
+		merged.Settings.Snapshot = cliSettings.Snapshot || merged.Settings.Snapshot
+		merged.Settings.Workspace = cliSettings.Workspace || merged.Settings.Workspace
+		if cliSettings.MaxTokens > 0 {
+			merged.Settings.MaxTokens = cliSettings.MaxTokens
+		}
+		if cliSettings.Base != "" {
+			merged.Settings.Base = cliSettings.Base
+		}
 	}
 
 	// Apply default model/tool to agents that don't specify them
@@ -160,16 +446,18 @@ func MergeConfigs(global *GlobalConfig, local *AgentflowConfig, cliSettings *Set
 		}
 	}
 
-	return merged
+	return merged, nil
 }
 
 // MatchesEvent checks if a webhook should be triggered for an event.
+// Frequent events (task_progress) are only sent when listed explicitly.
 func (w *WebhookConfig) MatchesEvent(eventType string) bool {
+	optIn := eventType == "task_progress"
 	if len(w.Events) == 0 {
-		return true // No filter = all events
+		return !optIn // No filter = all events
 	}
 	for _, e := range w.Events {
-		if e == eventType || e == "*" {
+		if e == eventType || (e == "*" && !optIn) {
 			return true
 		}
 	}
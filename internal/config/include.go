@@ -0,0 +1,231 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// resolveIncludes merges the files config includes into it, clearing
+// Include. Paths are relative to baseDir, the directory of the including
+// file. Definitions are merged by name, in this order:
+//
+//   - later includes come after earlier ones
+//   - the including file comes after everything it includes
+//
+// An agent or task defined again in a later file is an error naming both
+// definitions, unless the later one sets override: true to replace the
+// earlier one whole. Groups, workflows, and interchangeable tags are
+// replaced whole by later ones. Settings, workdir, preamble, retrieval, and
+// digest are taken from the last file that sets them. Included files may
+// include others; stack holds the files being loaded, to catch cycles.
+func resolveIncludes(config *AgentflowConfig, baseDir string, stack []string) error {
+	includes := config.Include
+	config.Include = nil
+	if len(includes) == 0 {
+		return nil
+	}
+
+	includer := ""
+	if len(stack) > 0 {
+		includer = stack[len(stack)-1]
+	}
+	merged := &AgentflowConfig{}
+	var duplicates []*ConfigError
+	for _, include := range includes {
+		path := include
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(baseDir, path)
+		}
+		if abs, err := filepath.Abs(path); err == nil {
+			path = abs
+		}
+		if i := slices.Index(stack, path); i >= 0 {
+			cycle := append(slices.Clone(stack[i:]), path)
+			return NewCodedError(CodeInvalidInclude, includer, 0,
+				"include cycle: "+strings.Join(cycle, " -> "),
+				"Remove one of the includes; shared definitions can go in a file both include")
+		}
+
+		included, err := loadConfig(path, append(slices.Clone(stack), path))
+		if err != nil {
+			var configErr *ConfigError
+			var configErrs *ConfigErrors
+			if errors.As(err, &configErr) || errors.As(err, &configErrs) {
+				return err
+			}
+			return NewCodedError(CodeInvalidInclude, includer, 0,
+				fmt.Sprintf("include %q: %s", include, err),
+				"Include paths are relative to the file that includes them")
+		}
+		duplicates = append(duplicates, mergeInclude(merged, included, path, includer)...)
+	}
+	duplicates = append(duplicates, mergeInclude(merged, config, "", includer)...)
+	if len(duplicates) > 0 {
+		return &ConfigErrors{Errors: duplicates}
+	}
+	*config = *merged
+	return nil
+}
+
+// mergeInclude merges src, loaded from file ("" for the including file
+// itself, includer), into dst, overriding what dst already defines. It
+// returns an error for each agent or task src defines again without
+// override: true.
+func mergeInclude(dst, src *AgentflowConfig, file, includer string) []*ConfigError {
+	if dst.Sources == nil {
+		dst.Sources = make(map[string]string)
+	}
+	if dst.Lines == nil {
+		dst.Lines = make(map[string]int)
+	}
+	source := func(section, name string) {
+		key := section + "." + name
+		switch origin, ok := src.Sources[key]; {
+		case ok:
+			dst.Sources[key] = origin
+		case file != "":
+			dst.Sources[key] = file
+		default:
+			delete(dst.Sources, key)
+		}
+		if line, ok := src.Lines[key]; ok {
+			dst.Lines[key] = line
+		} else {
+			delete(dst.Lines, key)
+		}
+	}
+	own := file
+	if own == "" {
+		own = includer
+	}
+	location := func(c *AgentflowConfig, section, name, def string) string {
+		key := section + "." + name
+		loc := c.SourceFile(section, name, def)
+		if line := c.Lines[key]; line > 0 {
+			loc += fmt.Sprintf(":%d", line)
+		}
+		return loc
+	}
+
+	// Agents and tasks defined again must say they replace the first
+	var errs []*ConfigError
+	duplicate := func(kind, section, name string) {
+		errs = append(errs, NewCodedError(CodeDuplicateDefinition, src.SourceFile(section, name, own), src.Lines[section+"."+name],
+			fmt.Sprintf("%s %q is defined twice: in %s and in %s", kind, name, location(dst, section, name, ""), location(src, section, name, own)),
+			"Rename one, or add 'override: true' to the later definition to replace the earlier one"))
+	}
+	for _, name := range duplicateNames(dst.Agents, src.Agents, func(a AgentConfig) bool { return a.Override }) {
+		duplicate("agent", "agents", name)
+	}
+	for _, name := range duplicateNames(dst.Tasks, src.Tasks, func(t TaskConfig) bool { return t.Override }) {
+		duplicate("task", "tasks", name)
+	}
+
+	dst.Agents = mergeNamed(dst.Agents, src.Agents, "agents", source)
+	dst.Tasks = mergeNamed(dst.Tasks, src.Tasks, "tasks", source)
+	dst.Groups = mergeNamed(dst.Groups, src.Groups, "groups", source)
+	dst.Workflows = mergeNamed(dst.Workflows, src.Workflows, "workflows", source)
+	if len(src.Interchangeable) > 0 {
+		if dst.Interchangeable == nil {
+			dst.Interchangeable = make(map[string][]string)
+		}
+		maps.Copy(dst.Interchangeable, src.Interchangeable)
+	}
+	if len(src.Secrets) > 0 {
+		if dst.Secrets == nil {
+			dst.Secrets = make(map[string]string)
+		}
+		maps.Copy(dst.Secrets, src.Secrets)
+	}
+	if len(src.Variables) > 0 {
+		if dst.Variables == nil {
+			dst.Variables = make(map[string]string)
+		}
+		maps.Copy(dst.Variables, src.Variables)
+	}
+
+	if src.Settings != nil {
+		dst.Settings = src.Settings
+	}
+	if src.Workdir != "" {
+		dst.Workdir = src.Workdir
+		dst.Unresolved = src.Unresolved
+	}
+	if src.Preamble != "" {
+		dst.Preamble = src.Preamble
+	}
+	if src.Retrieval != nil {
+		dst.Retrieval = src.Retrieval
+	}
+	if src.Digest != nil {
+		dst.Digest = src.Digest
+	}
+	dst.Deprecations = append(dst.Deprecations, src.Deprecations...)
+	if len(dst.Sources) == 0 {
+		dst.Sources = nil
+	}
+	if len(dst.Lines) == 0 {
+		dst.Lines = nil
+	}
+	return errs
+}
+
+// duplicateNames returns the names, sorted, that both dst and src define
+// where src's definition doesn't override dst's.
+func duplicateNames[V any](dst, src map[string]V, override func(V) bool) []string {
+	var names []string
+	for _, name := range sortedNames(src) {
+		if _, ok := dst[name]; ok && !override(src[name]) {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// definitionLines returns the lines of the agents and tasks doc defines,
+// keyed like AgentflowConfig.Sources.
+func definitionLines(doc *yaml.Node) map[string]int {
+	root := doc
+	if root.Kind == yaml.DocumentNode && len(root.Content) > 0 {
+		root = root.Content[0]
+	}
+	if root.Kind != yaml.MappingNode {
+		return nil
+	}
+	lines := make(map[string]int)
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		section, defs := root.Content[i].Value, root.Content[i+1]
+		if (section != "agents" && section != "tasks") || defs.Kind != yaml.MappingNode {
+			continue
+		}
+		for j := 0; j+1 < len(defs.Content); j += 2 {
+			lines[section+"."+defs.Content[j].Value] = defs.Content[j].Line
+		}
+	}
+	if len(lines) == 0 {
+		return nil
+	}
+	return lines
+}
+
+// mergeNamed copies the entries of src into dst, recording where each came
+// from with source.
+func mergeNamed[V any](dst, src map[string]V, section string, source func(section, name string)) map[string]V {
+	if src == nil {
+		return dst
+	}
+	if dst == nil {
+		dst = make(map[string]V, len(src))
+	}
+	for name, v := range src {
+		dst[name] = v
+		source(section, name)
+	}
+	return dst
+}
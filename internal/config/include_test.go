@@ -0,0 +1,164 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeFiles writes files (name to content) under dir.
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestInclude(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"shared/agents.yml": `
+include: tasks.yml
+agents:
+  dev: {tool: claude-code, model: sonnet}
+  ops: {tool: shell}
+settings:
+  max_parallel: 2
+`,
+		"shared/tasks.yml": `
+tasks:
+  lint:
+    agent: ops
+    command: make lint
+  review:
+    agent: dev
+    prompt_file: review.md
+`,
+		"shared/review.md": "Review the code",
+		"override.yml": `
+agents:
+  dev: {tool: claude-code, model: opus, override: true}
+`,
+		"Cortexfile.yml": `
+include: [shared/agents.yml, override.yml]
+tasks:
+  lint:
+    agent: ops
+    command: make lint-all
+    override: true
+  test:
+    agent: ops
+    command: make test
+`,
+	})
+
+	cfg, err := LoadConfig(filepath.Join(dir, "Cortexfile.yml"))
+	if err != nil {
+		t.Fatalf("LoadConfig() error = %v", err)
+	}
+	if len(cfg.Include) != 0 {
+		t.Errorf("Include = %v, want it cleared", cfg.Include)
+	}
+	if got := cfg.Agents["dev"].Model; got != "opus" {
+		t.Errorf("dev model = %q, want the later include's opus", got)
+	}
+	if got := cfg.Tasks["lint"].Command; got != "make lint-all" {
+		t.Errorf("lint command = %q, want the including file's", got)
+	}
+	if got := cfg.Tasks["review"].Prompt; got != "Review the code" {
+		t.Errorf("review prompt = %q, want prompt_file relative to its own file", got)
+	}
+	if cfg.Settings == nil || cfg.Settings.MaxParallel != 2 {
+		t.Errorf("settings = %+v, want the included max_parallel", cfg.Settings)
+	}
+
+	tasksFile := filepath.Join(dir, "shared", "tasks.yml")
+	sources := map[string]string{
+		"agents.dev":   filepath.Join(dir, "override.yml"),
+		"agents.ops":   filepath.Join(dir, "shared", "agents.yml"),
+		"tasks.review": tasksFile,
+		"tasks.lint":   "Cortexfile.yml",
+		"tasks.test":   "Cortexfile.yml",
+	}
+	for key, want := range sources {
+		section, name, _ := strings.Cut(key, ".")
+		if got := cfg.SourceFile(section, name, "Cortexfile.yml"); got != want {
+			t.Errorf("SourceFile(%s) = %q, want %q", key, got, want)
+		}
+	}
+
+	// Errors in included definitions name the file they're in
+	task := cfg.Tasks["review"]
+	task.Agent = "nobody"
+	cfg.Tasks["review"] = task
+	err = ValidateWithFile(cfg, "Cortexfile.yml")
+	if err == nil || !strings.Contains(err.Error(), tasksFile+": task \"review\" references undefined agent") {
+		t.Errorf("ValidateWithFile() error = %v, want it located in %s", err, tasksFile)
+	}
+}
+
+func TestIncludeErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		want  string
+	}{
+		{
+			name: "cycle",
+			files: map[string]string{
+				"Cortexfile.yml": "include: a.yml\n",
+				"a.yml":          "include: b.yml\n",
+				"b.yml":          "include: a.yml\n",
+			},
+			want: "include cycle: ",
+		},
+		{
+			name:  "missing file",
+			files: map[string]string{"Cortexfile.yml": "include: nope.yml\n"},
+			want:  `include "nope.yml": failed to read config file`,
+		},
+		{
+			name: "invalid YAML",
+			files: map[string]string{
+				"Cortexfile.yml": "include: bad.yml\n",
+				"bad.yml":        "agents: [\n",
+			},
+			want: "bad.yml: YAML parse error",
+		},
+		{
+			name: "duplicate task",
+			files: map[string]string{
+				"Cortexfile.yml": "include: [a.yml, b.yml]\n",
+				"a.yml":          "tasks:\n  lint: {agent: sh, command: make lint}\n",
+				"b.yml":          "agents:\n  sh: {tool: shell}\n\ntasks:\n  lint: {agent: sh, command: make vet}\n",
+			},
+			want: `b.yml:5: task "lint" is defined twice: in DIR/a.yml:2 and in DIR/b.yml:5 [CORTEX-VAL-038]`,
+		},
+		{
+			name: "agent redefined by the including file",
+			files: map[string]string{
+				"Cortexfile.yml": "include: a.yml\nagents:\n  sh: {tool: shell, max_concurrent: 1}\n",
+				"a.yml":          "agents:\n  sh: {tool: shell}\n",
+			},
+			want: `agent "sh" is defined twice: in DIR/a.yml:2 and in DIR/Cortexfile.yml:3`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeFiles(t, dir, tt.files)
+			_, err := LoadConfig(filepath.Join(dir, "Cortexfile.yml"))
+			want := strings.ReplaceAll(tt.want, "DIR", dir)
+			if err == nil || !strings.Contains(err.Error(), want) {
+				t.Errorf("LoadConfig() error = %v, want %q", err, want)
+			}
+		})
+	}
+}
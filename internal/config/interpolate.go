@@ -0,0 +1,79 @@
+package config
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// envVarRegex matches ${VAR} and ${VAR:-default}, and $${ (an escaped "${").
+var envVarRegex = regexp.MustCompile(`\$\$\{|\$\{([A-Za-z_][A-Za-z0-9_]*)(?::-([^}]*))?\}`)
+
+// interpolateEnv replaces ${VAR} with the value of the environment variable
+// VAR, and ${VAR:-default} with default when VAR is unset or empty; $${ is
+// a literal "${". It returns the names of the variables that aren't set and
+// have no default, which are left as written.
+func interpolateEnv(s string, lookup func(string) (string, bool)) (string, []string) {
+	if !strings.Contains(s, "${") {
+		return s, nil
+	}
+	var unresolved []string
+	out := envVarRegex.ReplaceAllStringFunc(s, func(match string) string {
+		if match == "$${" {
+			return "${"
+		}
+		m := envVarRegex.FindStringSubmatch(match)
+		if value, ok := lookup(m[1]); ok && value != "" {
+			return value
+		}
+		if strings.Contains(match, ":-") {
+			return m[2]
+		}
+		if !slices.Contains(unresolved, m[1]) {
+			unresolved = append(unresolved, m[1])
+		}
+		return match
+	})
+	return out, unresolved
+}
+
+// interpolateConfig resolves environment variables in the agents' models,
+// the workdir, and the tasks' inline prompts, top-level and in named
+// workflows. Prompts loaded from prompt_file are left alone, since they
+// often contain shell snippets. Unresolved variables are recorded on the
+// agent, task, or config for validation to report.
+func interpolateConfig(config *AgentflowConfig, lookup func(string) (string, bool)) {
+	for name, agent := range config.Agents {
+		agent.Model, agent.Unresolved = interpolateEnv(agent.Model, lookup)
+		config.Agents[name] = agent
+	}
+	config.Workdir, config.Unresolved = interpolateEnv(config.Workdir, lookup)
+	interpolateTasks(config.Tasks, lookup)
+	for _, workflow := range config.Workflows {
+		interpolateTasks(workflow.Tasks, lookup)
+	}
+}
+
+// interpolateTasks resolves environment variables in the inline prompts
+// of tasks, and the input of compress steps.
+func interpolateTasks(tasks map[string]TaskConfig, lookup func(string) (string, bool)) {
+	for name, task := range tasks {
+		if task.Compress != nil {
+			c := *task.Compress
+			c.Input, task.Unresolved = interpolateEnv(c.Input, lookup)
+			task.Compress = &c
+		} else if task.PromptFile == "" {
+			task.Prompt, task.Unresolved = interpolateEnv(task.Prompt, lookup)
+		}
+		tasks[name] = task
+	}
+}
+
+// formatUnresolved lists variable names as ${A}, ${B}.
+func formatUnresolved(names []string) string {
+	vars := make([]string, len(names))
+	for i, name := range names {
+		vars[i] = "${" + name + "}"
+	}
+	return strings.Join(vars, ", ")
+}
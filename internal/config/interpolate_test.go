@@ -0,0 +1,76 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestInterpolateEnv(t *testing.T) {
+	env := map[string]string{"MODEL": "opus", "EMPTY": ""}
+	lookup := func(name string) (string, bool) {
+		v, ok := env[name]
+		return v, ok
+	}
+
+	tests := []struct {
+		in         string
+		want       string
+		unresolved []string
+	}{
+		{"${MODEL}", "opus", nil},
+		{"model-${MODEL}-x", "model-opus-x", nil},
+		{"${MISSING:-sonnet}", "sonnet", nil},
+		{"${EMPTY:-sonnet}", "sonnet", nil},
+		{"${MISSING:-}", "", nil},
+		{"${MISSING} and ${MISSING} and ${OTHER}", "${MISSING} and ${MISSING} and ${OTHER}", []string{"MISSING", "OTHER"}},
+		{"$${MODEL} costs $5", "${MODEL} costs $5", nil},
+		{"$MODEL and ${1X}", "$MODEL and ${1X}", nil},
+	}
+	for _, tt := range tests {
+		got, unresolved := interpolateEnv(tt.in, lookup)
+		if got != tt.want || strings.Join(unresolved, ",") != strings.Join(tt.unresolved, ",") {
+			t.Errorf("interpolateEnv(%q) = %q, %v, want %q, %v", tt.in, got, unresolved, tt.want, tt.unresolved)
+		}
+	}
+}
+
+func TestInterpolateConfig(t *testing.T) {
+	t.Setenv("CORTEX_TEST_MODEL", "opus")
+	t.Setenv("CORTEX_TEST_UNSET", "")
+
+	cfg, err := ParseConfig([]byte(`
+workdir: ${CORTEX_TEST_UNSET:-./src}
+agents:
+  dev: {tool: claude-code, model: "${CORTEX_TEST_MODEL}"}
+  fast: {tool: claude-code, model: "${CORTEX_TEST_FAST_MODEL}"}
+tasks:
+  review:
+    agent: dev
+    prompt: Review ${CORTEX_TEST_MODEL} changes; the API is at ${CORTEX_TEST_API_URL}
+`), t.TempDir())
+	if err != nil {
+		t.Fatalf("ParseConfig() error = %v", err)
+	}
+	if cfg.Workdir != "./src" {
+		t.Errorf("workdir = %q, want the default", cfg.Workdir)
+	}
+	if got := cfg.Agents["dev"].Model; got != "opus" {
+		t.Errorf("dev model = %q, want opus", got)
+	}
+
+	err = Validate(cfg)
+	if err == nil {
+		t.Fatal("Validate() succeeded, want unresolved variables reported")
+	}
+	for _, want := range []string{
+		`agent "fast" model uses unset environment variables: ${CORTEX_TEST_FAST_MODEL}`,
+		`task "review" prompt uses unset environment variables: ${CORTEX_TEST_API_URL}`,
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Validate() error = %v, want %q", err, want)
+		}
+	}
+	if code := ErrorCode(err); code != CodeUnresolvedVariable {
+		t.Errorf("ErrorCode() = %q, want %q", code, CodeUnresolvedVariable)
+	}
+}
@@ -0,0 +1,150 @@
+package config
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/adityaraj/agentflow/internal/textfile"
+)
+
+// Limits on what a config file may contain, so a hostile or broken file
+// fails with a clear error instead of exhausting memory.
+const (
+	// MaxConfigBytes is the largest Cortexfile, MasterCortex, or global
+	// config accepted.
+	MaxConfigBytes = 4 << 20
+
+	// MaxPromptFileBytes is the largest prompt_file, preamble_file, or
+	// instructions_file.
+	MaxPromptFileBytes = 8 << 20
+
+	// MaxYAMLDepth is how deeply mappings and sequences may nest.
+	MaxYAMLDepth = 64
+
+	// MaxYAMLNodes is how many values a file may hold once aliases are
+	// expanded; a few aliases repeating each other can otherwise describe
+	// billions of values in a few lines.
+	MaxYAMLNodes = 100_000
+)
+
+// readLimited reads the file at path, failing if it's larger than max
+// bytes. what names the file in errors.
+func readLimited(path string, max int64, what string) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	data, err := io.ReadAll(io.LimitReader(f, max+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > max {
+		return nil, fmt.Errorf("%s %s is larger than the %s limit", what, path, formatLimit(max))
+	}
+	return data, nil
+}
+
+// ReadPromptFile reads a prompt_file, preamble_file, instructions_file, or
+// other file of prompt text (what), failing if it's larger than
+// MaxPromptFileBytes or isn't text.
+func ReadPromptFile(path, what string) ([]byte, error) {
+	data, err := readLimited(path, MaxPromptFileBytes, what)
+	if err == nil && textfile.IsBinary(data) {
+		return nil, fmt.Errorf("%s %s is a binary file, not text", what, path)
+	}
+	return data, err
+}
+
+// decodeYAML is yaml.Unmarshal with the limits above.
+func decodeYAML(data []byte, out any) error {
+	doc, err := parseYAML(data)
+	if err != nil {
+		return err
+	}
+	if doc.Kind == 0 {
+		return nil // Empty document
+	}
+	return doc.Decode(out)
+}
+
+// parseYAML parses data into a node tree, checking the limits above.
+func parseYAML(data []byte) (*yaml.Node, error) {
+	if len(data) > MaxConfigBytes {
+		return nil, fmt.Errorf("config is larger than the %s limit", formatLimit(MaxConfigBytes))
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	if err := checkYAMLLimits(&doc); err != nil {
+		return nil, err
+	}
+	return &doc, nil
+}
+
+// yamlSize is the depth and expanded node count of a YAML node.
+type yamlSize struct {
+	depth int
+	nodes int
+}
+
+// checkYAMLLimits checks doc against MaxYAMLDepth and MaxYAMLNodes,
+// counting each alias as a copy of its anchor's value.
+func checkYAMLLimits(doc *yaml.Node) error {
+	sizes := make(map[*yaml.Node]yamlSize)
+	var measure func(n *yaml.Node) (yamlSize, error)
+	measure = func(n *yaml.Node) (yamlSize, error) {
+		if s, ok := sizes[n]; ok {
+			if s.nodes < 0 {
+				return s, fmt.Errorf("line %d: alias refers to a value that contains it", n.Line)
+			}
+			return s, nil
+		}
+		sizes[n] = yamlSize{nodes: -1} // In progress
+
+		var size yamlSize
+		if n.Kind == yaml.AliasNode && n.Alias != nil {
+			s, err := measure(n.Alias)
+			if err != nil {
+				return s, err
+			}
+			size = s
+		} else {
+			for _, child := range n.Content {
+				s, err := measure(child)
+				if err != nil {
+					return s, err
+				}
+				size.depth = max(size.depth, s.depth)
+				size.nodes += s.nodes
+				if size.nodes > MaxYAMLNodes {
+					return size, fmt.Errorf("line %d: config expands to more than %d values (check for aliases repeating large blocks)", n.Line, MaxYAMLNodes)
+				}
+			}
+			size.nodes++
+			if n.Kind == yaml.MappingNode || n.Kind == yaml.SequenceNode {
+				size.depth++
+			}
+			if size.depth > MaxYAMLDepth {
+				return size, fmt.Errorf("line %d: config nests deeper than %d levels", n.Line, MaxYAMLDepth)
+			}
+		}
+		sizes[n] = size
+		return size, nil
+	}
+	_, err := measure(doc)
+	return err
+}
+
+// formatLimit formats a byte limit as whole MiB or KiB.
+func formatLimit(n int64) string {
+	if n >= 1<<20 {
+		return fmt.Sprintf("%d MiB", n>>20)
+	}
+	return fmt.Sprintf("%d KiB", n>>10)
+}
@@ -0,0 +1,97 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestYAMLLimits(t *testing.T) {
+	// Each level repeats the one before ten times: 10^9 values in 10 lines
+	var bomb strings.Builder
+	bomb.WriteString("a0: &a0 [x, x, x, x, x, x, x, x, x, x]\n")
+	for i := 1; i < 10; i++ {
+		fmt.Fprintf(&bomb, "a%d: &a%d [*a%d, *a%d, *a%d, *a%d, *a%d, *a%d, *a%d, *a%d, *a%d, *a%d]\n",
+			i, i, i-1, i-1, i-1, i-1, i-1, i-1, i-1, i-1, i-1, i-1)
+	}
+
+	deep := "agents: " + strings.Repeat("[", MaxYAMLDepth+1) + strings.Repeat("]", MaxYAMLDepth+1)
+
+	tests := []struct {
+		name string
+		yaml string
+		want string
+	}{
+		{"alias bomb", bomb.String(), "expands to more than"},
+		{"deep nesting", deep, "nests deeper than"},
+		{"too large", "x: " + strings.Repeat("a", MaxConfigBytes), "larger than the 4 MiB limit"},
+	}
+	for _, tt := range tests {
+		_, err := ParseConfig([]byte(tt.yaml), t.TempDir())
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: error = %v, want %q", tt.name, err, tt.want)
+		}
+	}
+
+	// Aliases used sensibly are fine
+	ok := `
+agents:
+  sh: {tool: shell}
+x-common: &common {agent: sh, command: "true"}
+tasks:
+  a: *common
+  b: *common
+`
+	cfg, err := ParseConfig([]byte(ok), t.TempDir())
+	if err != nil {
+		t.Fatalf("ParseConfig: %v", err)
+	}
+	if cfg.Tasks["b"].Command != "true" {
+		t.Errorf("task b = %+v", cfg.Tasks["b"])
+	}
+}
+
+func TestPromptFileLimit(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "big.md"), make([]byte, MaxPromptFileBytes+1), 0644); err != nil {
+		t.Fatal(err)
+	}
+	_, err := ParseConfig([]byte("tasks:\n  a:\n    prompt_file: big.md\n"), dir)
+	if err == nil || !strings.Contains(err.Error(), "larger than the 8 MiB limit") {
+		t.Errorf("error = %v, want a size limit error", err)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "logo.png"), []byte("\x89PNG\r\n\x1a\n\x00\x00"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	_, err = ParseConfig([]byte("agents:\n  a:\n    tool: claude-code\n    instructions_file: logo.png\n"), dir)
+	if err == nil || !strings.Contains(err.Error(), "is a binary file, not text") {
+		t.Errorf("error = %v, want a binary file error", err)
+	}
+
+	// Files given on the command line, such as --preamble, are read the same way
+	logo := filepath.Join(dir, "logo.png")
+	if _, err := ReadPromptFile(logo, "--preamble"); err == nil || err.Error() != "--preamble "+logo+" is a binary file, not text" {
+		t.Errorf("ReadPromptFile() error = %v, want a binary file error", err)
+	}
+	if _, err := ReadPromptFile(filepath.Join(dir, "big.md"), "--preamble"); err == nil || !strings.Contains(err.Error(), "larger than the 8 MiB limit") {
+		t.Errorf("ReadPromptFile() error = %v, want a size limit error", err)
+	}
+}
+
+func FuzzParseConfig(f *testing.F) {
+	f.Add([]byte("agents:\n  a: {tool: shell}\ntasks:\n  t: {agent: a, command: echo}\n"))
+	f.Add([]byte("a: &a [1, 2]\nb: [*a, *a]\n"))
+	f.Add([]byte("tasks: {t: {needs: [x, y], prompt: \"{{outputs.x}}\"}}\n"))
+	f.Add([]byte("workflows: {w: {tasks: {t: {agent: a}}, groups: {g: [t]}}}\n"))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		cfg, err := ParseConfig(data, t.TempDir())
+		if err != nil {
+			return
+		}
+		// Anything that parses must be safe to validate
+		_ = ValidateWithFile(cfg, "Cortexfile.yml")
+	})
+}
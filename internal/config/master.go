@@ -4,8 +4,6 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
-
-	"gopkg.in/yaml.v3"
 )
 
 // MasterConfig represents the MasterCortex.yml configuration.
@@ -73,15 +71,25 @@ func FindMasterCortex(dir string) (string, error) {
 	return "", fmt.Errorf("no MasterCortex file found in %s (tried: %v)", dir, MasterCortexFiles)
 }
 
+// FindMasterCortexUp searches dir and then each parent directory for a
+// MasterCortex file.
+func FindMasterCortexUp(dir string) (string, error) {
+	path, err := findUp(dir, FindMasterCortex)
+	if err != nil {
+		return "", fmt.Errorf("no MasterCortex file found in %s or any parent directory", dir)
+	}
+	return path, nil
+}
+
 // LoadMasterConfig loads a MasterCortex configuration from the given path.
 func LoadMasterConfig(path string) (*MasterConfig, error) {
-	data, err := os.ReadFile(path)
+	data, err := readLimited(path, MaxConfigBytes, "master config")
 	if err != nil {
 		return nil, fmt.Errorf("failed to read master config: %w", err)
 	}
 
 	var config MasterConfig
-	if err := yaml.Unmarshal(data, &config); err != nil {
+	if err := decodeYAML(data, &config); err != nil {
 		return nil, fmt.Errorf("failed to parse master config: %w", err)
 	}
 
@@ -135,6 +143,9 @@ func ValidateMasterConfig(cfg *MasterConfig) error {
 	for _, w := range cfg.Workflows {
 		for _, dep := range w.Needs {
 			if !names[dep] {
+				if suggestions := DidYouMean(dep, sortedNames(names)); suggestions != "" {
+					return fmt.Errorf("workflow %q depends on unknown workflow %q; did you mean %s?", w.Name, dep, suggestions)
+				}
 				return fmt.Errorf("workflow %q depends on unknown workflow %q", w.Name, dep)
 			}
 		}
@@ -0,0 +1,335 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// matrixVarRegex matches {{matrix.KEY}} placeholders.
+var matrixVarRegex = regexp.MustCompile(`\{\{matrix\.([a-zA-Z0-9_-]+)\}\}`)
+
+// outputRefSimpleRegex matches {{outputs.X}} without a default.
+var outputRefSimpleRegex = regexp.MustCompile(`\{\{outputs\.([a-zA-Z0-9_-]+)\}\}`)
+
+// maxMatrixCombinations caps the tasks one matrix task expands into, so a
+// few keys with many values can't turn into millions of tasks.
+const maxMatrixCombinations = 256
+
+// expansion is one of the tasks a matrix task expands into.
+type expansion struct {
+	name   string
+	values map[string]string // Matrix key to value
+}
+
+// expandMatrices replaces each task with a matrix by a task per combination
+// of its values, for the top-level tasks and those of each named workflow.
+// See expandTasks.
+func expandMatrices(config *AgentflowConfig) error {
+	expanded, err := expandTasks(config.Tasks, config.Groups)
+	if err != nil {
+		return err
+	}
+	for base, list := range expanded {
+		origin, ok := config.Sources["tasks."+base]
+		if !ok {
+			continue
+		}
+		delete(config.Sources, "tasks."+base)
+		for _, e := range list {
+			config.Sources["tasks."+e.name] = origin
+		}
+	}
+	if config.Digest != nil {
+		config.Digest.Tasks = expandNames(config.Digest.Tasks, expanded)
+	}
+
+	for name, workflow := range config.Workflows {
+		if _, err := expandTasks(workflow.Tasks, workflow.Groups); err != nil {
+			return fmt.Errorf("workflow %q: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// expandTasks expands the matrix tasks in tasks, in place. Each expansion
+// is named after the task and its values in key order, e.g. test-api-go
+// for service: api and lang: go, and has {{matrix.KEY}} replaced by its
+// values. References to a matrix task are rewired to its expansions:
+//
+//   - needs: [test] and groups listing test name every expansion
+//   - {{outputs.test}} becomes the outputs of those expansions, one after
+//     another
+//   - a matrix task that needs another only needs the expansions whose
+//     values agree on the keys both matrices have, so deploy-api needs
+//     build-api rather than every build
+//
+// It returns the expansions of each matrix task.
+func expandTasks(tasks map[string]TaskConfig, groups map[string]StringList) (map[string][]expansion, error) {
+	expanded := make(map[string][]expansion)
+	for _, name := range sortedNames(tasks) {
+		task := tasks[name]
+		if task.Matrix == nil {
+			if refs := matrixRefs(task); len(refs) > 0 {
+				return nil, NewCodedError(CodeInvalidMatrix, "", 0,
+					fmt.Sprintf("task %q uses {{matrix.%s}} but has no matrix", name, refs[0]),
+					"Add a 'matrix:' block listing the values to run the task with")
+			}
+			continue
+		}
+		list, err := matrixExpansions(name, task)
+		if err != nil {
+			return nil, err
+		}
+		expanded[name] = list
+	}
+	if len(expanded) == 0 {
+		return nil, nil
+	}
+
+	taken := make(map[string]string) // Expansion name to its matrix task
+	for base, list := range expanded {
+		for _, e := range list {
+			if _, exists := tasks[e.name]; exists {
+				return nil, NewCodedError(CodeInvalidMatrix, "", 0,
+					fmt.Sprintf("task %q expands to %q, which is already a task", base, e.name),
+					"Rename one of the tasks")
+			}
+			if other, ok := taken[e.name]; ok {
+				return nil, NewCodedError(CodeInvalidMatrix, "", 0,
+					fmt.Sprintf("tasks %q and %q both expand to %q", other, base, e.name),
+					"Rename one of the tasks, or make the matrix values distinct")
+			}
+			taken[e.name] = base
+		}
+	}
+
+	for _, name := range sortedNames(tasks) {
+		task := tasks[name]
+		list, isMatrix := expanded[name]
+		if !isMatrix {
+			tasks[name] = rewireTask(task, nil, expanded)
+			continue
+		}
+		delete(tasks, name)
+		for _, e := range list {
+			tasks[e.name] = rewireTask(substituteMatrix(task, e.values), e.values, expanded)
+		}
+	}
+	for group, names := range groups {
+		groups[group] = expandNames(names, expanded)
+	}
+	return expanded, nil
+}
+
+// matrixExpansions lists the combinations of the values of task's matrix,
+// with the first key (alphabetically) varying slowest.
+func matrixExpansions(name string, task TaskConfig) ([]expansion, error) {
+	keys := sortedNames(task.Matrix)
+	if len(keys) == 0 {
+		return nil, NewCodedError(CodeInvalidMatrix, "", 0,
+			fmt.Sprintf("task %q: matrix is empty", name),
+			"List at least one key with values, e.g. 'matrix: {service: [api, web]}'")
+	}
+	for _, ref := range matrixRefs(task) {
+		if _, ok := task.Matrix[ref]; !ok {
+			return nil, NewCodedError(CodeInvalidMatrix, "", 0,
+				fmt.Sprintf("task %q uses {{matrix.%s}}, which its matrix doesn't define", name, ref),
+				"Matrix keys: "+strings.Join(keys, ", "))
+		}
+	}
+
+	combinations := 1
+	for _, key := range keys {
+		values := task.Matrix[key]
+		if len(values) == 0 {
+			return nil, NewCodedError(CodeInvalidMatrix, "", 0,
+				fmt.Sprintf("task %q: matrix key %q has no values", name, key),
+				"List the values to run the task with, or remove the key")
+		}
+		combinations *= len(values)
+		if combinations > maxMatrixCombinations {
+			return nil, NewCodedError(CodeInvalidMatrix, "", 0,
+				fmt.Sprintf("task %q: matrix has more than %d combinations", name, maxMatrixCombinations),
+				"Use fewer keys or values, or split the task into several matrix tasks")
+		}
+	}
+
+	list := []expansion{{name: name, values: map[string]string{}}}
+	for _, key := range keys {
+		values := task.Matrix[key]
+		var next []expansion
+		for _, e := range list {
+			for _, value := range values {
+				suffix := NormalizeName(value)
+				if suffix == "" {
+					return nil, NewCodedError(CodeInvalidMatrix, "", 0,
+						fmt.Sprintf("task %q: matrix value %q can't be part of a task name", name, value),
+						"Use values with letters or digits")
+				}
+				combo := make(map[string]string, len(e.values)+1)
+				for k, v := range e.values {
+					combo[k] = v
+				}
+				combo[key] = value
+				next = append(next, expansion{name: e.name + "-" + suffix, values: combo})
+			}
+		}
+		list = next
+	}
+
+	for _, e := range list {
+		if !IsValidName(e.name) {
+			return nil, NewCodedError(CodeInvalidMatrix, "", 0,
+				fmt.Sprintf("task %q: expanded name %q is not a valid task name", name, e.name),
+				fmt.Sprintf("Names are at most %d characters; shorten the task name or matrix values", maxNameLength))
+		}
+	}
+	return list, nil
+}
+
+// taskStrings returns pointers to the fields of task that may use
+// {{matrix.KEY}}.
+func taskStrings(task *TaskConfig) []*string {
+	fields := []*string{&task.Agent, &task.Prompt, &task.Command, &task.Verify, &task.Workdir, &task.VerifyWorkdir, &task.OutputFile, &task.When}
+	for i := range task.CommandArgs {
+		fields = append(fields, &task.CommandArgs[i])
+	}
+	for i := range task.VerifyArgs {
+		fields = append(fields, &task.VerifyArgs[i])
+	}
+	for i := range task.Tags {
+		fields = append(fields, &task.Tags[i])
+	}
+	for i := range task.Paths {
+		fields = append(fields, &task.Paths[i])
+	}
+	for i := range task.Writes {
+		fields = append(fields, &task.Writes[i])
+	}
+	if task.Context != nil {
+		for i := range task.Context.Files {
+			fields = append(fields, &task.Context.Files[i])
+		}
+	}
+	if task.Compress != nil {
+		fields = append(fields, &task.Compress.Input)
+		for i := range task.Compress.Files {
+			fields = append(fields, &task.Compress.Files[i])
+		}
+	}
+	return fields
+}
+
+// matrixRefs returns the matrix keys task's fields refer to.
+func matrixRefs(task TaskConfig) []string {
+	var keys []string
+	for _, field := range taskStrings(&task) {
+		for _, m := range matrixVarRegex.FindAllStringSubmatch(ProtectEscapes(*field), -1) {
+			if !slices.Contains(keys, m[1]) {
+				keys = append(keys, m[1])
+			}
+		}
+	}
+	return keys
+}
+
+// substituteMatrix returns a copy of task for one combination of values.
+func substituteMatrix(task TaskConfig, values map[string]string) TaskConfig {
+	task.Matrix = nil
+	task.CommandArgs = slices.Clone(task.CommandArgs)
+	task.VerifyArgs = slices.Clone(task.VerifyArgs)
+	task.Tags = slices.Clone(task.Tags)
+	task.Paths = slices.Clone(task.Paths)
+	task.Writes = slices.Clone(task.Writes)
+	if task.Context != nil {
+		c := *task.Context
+		c.Files = slices.Clone(c.Files)
+		task.Context = &c
+	}
+	if task.Compress != nil {
+		c := *task.Compress
+		c.Files = slices.Clone(c.Files)
+		task.Compress = &c
+	}
+	for _, field := range taskStrings(&task) {
+		*field = RestoreEscapes(expandVars(matrixVarRegex, ProtectEscapes(*field), values))
+	}
+	return task
+}
+
+// rewireTask points task's needs and {{outputs.X}} references at matrix
+// tasks to their expansions; values are task's own matrix values, if any.
+func rewireTask(task TaskConfig, values map[string]string, expanded map[string][]expansion) TaskConfig {
+	var needs StringList
+	for _, dep := range task.Needs {
+		if _, ok := expanded[dep]; ok {
+			needs = append(needs, matchingExpansions(expanded[dep], values)...)
+		} else {
+			needs = append(needs, dep)
+		}
+	}
+	if task.Needs != nil {
+		task.Needs = needs
+	}
+
+	rewire := func(text string) string {
+		return outputRefSimpleRegex.ReplaceAllStringFunc(text, func(ref string) string {
+			dep := outputRefSimpleRegex.FindStringSubmatch(ref)[1]
+			if _, ok := expanded[dep]; !ok {
+				return ref
+			}
+			var refs []string
+			for _, name := range matchingExpansions(expanded[dep], values) {
+				refs = append(refs, "{{outputs."+name+"}}")
+			}
+			return strings.Join(refs, "\n\n")
+		})
+	}
+	task.Prompt = rewire(task.Prompt)
+	if task.Compress != nil {
+		c := *task.Compress
+		c.Input = rewire(c.Input)
+		task.Compress = &c
+	}
+	return task
+}
+
+// matchingExpansions returns the names of the expansions whose values agree
+// with values on every key both have.
+func matchingExpansions(list []expansion, values map[string]string) []string {
+	var names []string
+	for _, e := range list {
+		match := true
+		for k, v := range e.values {
+			if own, ok := values[k]; ok && own != v {
+				match = false
+				break
+			}
+		}
+		if match {
+			names = append(names, e.name)
+		}
+	}
+	return names
+}
+
+// expandNames replaces the names of matrix tasks in names by their
+// expansions.
+func expandNames(names StringList, expanded map[string][]expansion) StringList {
+	if names == nil {
+		return nil
+	}
+	out := StringList{}
+	for _, name := range names {
+		if list, ok := expanded[name]; ok {
+			for _, e := range list {
+				out = append(out, e.name)
+			}
+		} else {
+			out = append(out, name)
+		}
+	}
+	return out
+}
@@ -0,0 +1,123 @@
+package config
+
+import (
+	"errors"
+	"slices"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestExpandMatrices(t *testing.T) {
+	cfg, err := ParseConfig([]byte(`
+agents:
+  dev: {tool: claude-code}
+  sh: {tool: shell}
+tasks:
+  build:
+    agent: sh
+    command: [make, "{{matrix.service}}"]
+    matrix:
+      service: [api, web]
+  test:
+    agent: dev
+    needs: [build]
+    prompt: "Test {{matrix.service}} in {{matrix.lang}}: {{outputs.build}}"
+    output_file: "reports/{{matrix.service}}-{{matrix.lang}}.md"
+    matrix:
+      service: [api, web]
+      lang: [Go, TypeScript]
+  summary:
+    agent: dev
+    needs: [test]
+    prompt: "Summarize: {{outputs.test}}"
+groups:
+  ci: [test]
+digest:
+  path: digest.md
+  tasks: [summary, build]
+`), t.TempDir())
+	if err != nil {
+		t.Fatalf("ParseConfig() error = %v", err)
+	}
+
+	var names []string
+	for name := range cfg.Tasks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	want := []string{
+		"build-api", "build-web", "summary",
+		"test-go-api", "test-go-web", "test-typescript-api", "test-typescript-web",
+	}
+	if !slices.Equal(names, want) {
+		t.Fatalf("tasks = %v, want %v", names, want)
+	}
+
+	if got := cfg.Tasks["build-web"].CommandArgs; !slices.Equal(got, []string{"make", "web"}) {
+		t.Errorf("build-web command = %v", got)
+	}
+	test := cfg.Tasks["test-typescript-api"]
+	if !slices.Equal(test.Needs, StringList{"build-api"}) {
+		t.Errorf("test-typescript-api needs = %v, want only build-api", test.Needs)
+	}
+	if test.Prompt != "Test api in TypeScript: {{outputs.build-api}}" {
+		t.Errorf("test-typescript-api prompt = %q", test.Prompt)
+	}
+	if test.OutputFile != "reports/api-TypeScript.md" {
+		t.Errorf("test-typescript-api output_file = %q", test.OutputFile)
+	}
+	if test.Matrix != nil {
+		t.Errorf("expanded task kept its matrix")
+	}
+
+	summary := cfg.Tasks["summary"]
+	tests := StringList{"test-go-api", "test-go-web", "test-typescript-api", "test-typescript-web"}
+	if !slices.Equal(summary.Needs, tests) {
+		t.Errorf("summary needs = %v, want every test", summary.Needs)
+	}
+	if !strings.Contains(summary.Prompt, "{{outputs.test-go-api}}\n\n{{outputs.test-go-web}}") {
+		t.Errorf("summary prompt = %q", summary.Prompt)
+	}
+	if !slices.Equal(cfg.Groups["ci"], tests) {
+		t.Errorf("group ci = %v", cfg.Groups["ci"])
+	}
+	if !slices.Equal(cfg.Digest.Tasks, StringList{"summary", "build-api", "build-web"}) {
+		t.Errorf("digest tasks = %v", cfg.Digest.Tasks)
+	}
+
+	if err := Validate(cfg); err != nil {
+		t.Errorf("Validate() error = %v", err)
+	}
+}
+
+func TestExpandMatricesErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		tasks string
+		want  string
+	}{
+		{"empty axis", `
+  t: {agent: dev, prompt: x, matrix: {service: []}}`, `matrix key "service" has no values`},
+		{"unknown key", `
+  t: {agent: dev, prompt: "{{matrix.lang}}", matrix: {service: [api]}}`, "uses {{matrix.lang}}, which its matrix doesn't define"},
+		{"no matrix", `
+  t: {agent: dev, prompt: "{{matrix.lang}}"}`, "has no matrix"},
+		{"bad value", `
+  t: {agent: dev, prompt: x, matrix: {service: ["!!"]}}`, `matrix value "!!" can't be part of a task name`},
+		{"taken name", `
+  t: {agent: dev, prompt: x, matrix: {service: [api]}}
+  t-api: {agent: dev, prompt: x}`, `expands to "t-api", which is already a task`},
+		{"too many combinations", `
+  t: {agent: dev, prompt: x, matrix: {a: [1, 2, 3, 4], b: [1, 2, 3, 4], c: [1, 2, 3, 4], d: [1, 2, 3, 4, 5]}}`, "matrix has more than 256 combinations"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseConfig([]byte("agents:\n  dev: {tool: claude-code}\ntasks:"+tt.tasks+"\n"), t.TempDir())
+			var configErr *ConfigError
+			if !errors.As(err, &configErr) || configErr.Code != CodeInvalidMatrix || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("ParseConfig() error = %v, want %s %q", err, CodeInvalidMatrix, tt.want)
+			}
+		})
+	}
+}
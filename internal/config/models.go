@@ -0,0 +1,133 @@
+package config
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// ModelCatalog describes the models a tool accepts, so misspelled models
+// are caught by validation instead of failing the run's first task.
+type ModelCatalog struct {
+	// Names are the aliases and model IDs the tool accepts, also offered
+	// as suggestions.
+	Names []string
+
+	// Prefixes start the other model IDs the tool accepts, e.g. "claude-"
+	// for dated versions not in Names.
+	Prefixes []string
+
+	// Providers are the providers of tools whose models are written
+	// "provider/model"; the model part isn't checked, since each provider
+	// adds models on its own schedule.
+	Providers []string
+}
+
+// ModelCatalogs are the model catalogs of the tools that take a model.
+var ModelCatalogs = map[string]ModelCatalog{
+	"claude-code": {
+		Names: []string{
+			"sonnet", "opus", "haiku", "opusplan", "default", "sonnet[1m]",
+			"claude-sonnet-4-5", "claude-opus-4-1", "claude-haiku-4-5",
+			"claude-sonnet-4-0", "claude-opus-4-0", "claude-3-7-sonnet-latest", "claude-3-5-haiku-latest",
+		},
+		Prefixes: []string{"claude-"},
+	},
+	"opencode": {
+		Providers: []string{
+			"anthropic", "openai", "google", "google-vertex", "amazon-bedrock", "azure",
+			"github-copilot", "openrouter", "groq", "mistral", "xai", "deepseek",
+			"fireworks-ai", "together-ai", "cerebras", "ollama", "lmstudio", "opencode",
+		},
+	},
+}
+
+// CheckModel reports whether tool is known to accept model. For an
+// unknown model it also returns the known model, or "provider/model", it
+// is closest to, if any is close enough to suggest a typo. Tools without
+// a catalog, and empty models, are always known.
+func CheckModel(tool, model string) (known bool, suggestion string) {
+	catalog, ok := ModelCatalogs[tool]
+	if !ok || model == "" {
+		return true, ""
+	}
+	if len(catalog.Providers) > 0 {
+		provider, name, ok := strings.Cut(model, "/")
+		if !ok || name == "" {
+			return false, ""
+		}
+		if slices.Contains(catalog.Providers, provider) {
+			return true, ""
+		}
+		if s := SuggestClosestMatch(provider, catalog.Providers); s != "" {
+			return false, s + "/" + name
+		}
+		return false, ""
+	}
+	if slices.Contains(catalog.Names, model) {
+		return true, ""
+	}
+	for _, prefix := range catalog.Prefixes {
+		if strings.HasPrefix(model, prefix) {
+			return true, ""
+		}
+	}
+	return false, SuggestClosestMatch(model, catalog.Names)
+}
+
+// validateModel checks an agent's model against its tool's catalog. A
+// model close to a known one is most likely a typo, and an error; other
+// unknown models are left to warnModels, since they may be newer than
+// the catalog.
+func validateModel(filePath, agentName string, agent AgentConfig) *ConfigError {
+	if !modelCheckable(agent) {
+		return nil
+	}
+	known, suggestion := CheckModel(agent.Tool, agent.Model)
+	if known || suggestion == "" {
+		return nil
+	}
+	err := NewCodedError(CodeUnknownModel, filePath, 0,
+		fmt.Sprintf("agent %q: unknown %s model %q", agentName, agent.Tool, agent.Model),
+		modelHint(agent.Tool))
+	err.Suggestion = suggestion
+	return err
+}
+
+// warnModels warns about agents whose models aren't in their tool's
+// catalog and aren't close to a model that is (see validateModel).
+func warnModels(config *AgentflowConfig, filePath string) []*ConfigError {
+	var warnings []*ConfigError
+	for _, name := range sortedNames(config.Agents) {
+		agent := config.Agents[name]
+		if !modelCheckable(agent) {
+			continue
+		}
+		if known, suggestion := CheckModel(agent.Tool, agent.Model); known || suggestion != "" {
+			continue
+		}
+		warnings = append(warnings, NewCodedError(CodeUncataloguedModel, config.SourceFile("agents", name, filePath), 0,
+			fmt.Sprintf("agent %q: %s model %q isn't one cortex knows", name, agent.Tool, agent.Model),
+			modelHint(agent.Tool)+"; newer models than cortex knows work if the tool accepts them"))
+	}
+	return warnings
+}
+
+// modelCheckable reports whether agent's model can be checked: it's set,
+// and not left to an environment variable that isn't.
+func modelCheckable(agent AgentConfig) bool {
+	return agent.Model != "" && len(agent.Unresolved) == 0
+}
+
+// modelHint lists the models, or model format, tool accepts.
+func modelHint(tool string) string {
+	catalog := ModelCatalogs[tool]
+	if len(catalog.Providers) > 0 {
+		return "Use provider/model, e.g. anthropic/claude-sonnet-4-5, with one of the providers " + strings.Join(catalog.Providers, ", ")
+	}
+	hint := "Use " + strings.Join(catalog.Names[:min(len(catalog.Names), 5)], ", ")
+	if len(catalog.Prefixes) > 0 {
+		hint += ", or a model ID starting with " + strings.Join(catalog.Prefixes, " or ")
+	}
+	return hint
+}
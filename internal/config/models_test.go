@@ -0,0 +1,66 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestCheckModel(t *testing.T) {
+	for _, tt := range []struct {
+		tool, model    string
+		wantKnown      bool
+		wantSuggestion string
+	}{
+		{"claude-code", "sonnet", true, ""},
+		{"claude-code", "claude-opus-4-1-20250805", true, ""},
+		{"claude-code", "sonet", false, "sonnet"},
+		{"claude-code", "opsu", false, "opus"},
+		{"claude-code", "arn:aws:bedrock:us-east-1:123:inference-profile/x", false, ""},
+		{"opencode", "anthropic/claude-sonnet-4-5", true, ""},
+		{"opencode", "antropic/claude-sonnet-4-5", false, "anthropic/claude-sonnet-4-5"},
+		{"opencode", "my-proxy/llama", false, ""},
+		{"opencode", "gpt-4o", false, ""},
+		{"shell", "anything", true, ""},
+		{"claude-code", "", true, ""},
+	} {
+		known, suggestion := CheckModel(tt.tool, tt.model)
+		if known != tt.wantKnown || suggestion != tt.wantSuggestion {
+			t.Errorf("CheckModel(%s, %q) = %v, %q, want %v, %q", tt.tool, tt.model, known, suggestion, tt.wantKnown, tt.wantSuggestion)
+		}
+	}
+}
+
+func TestValidateModels(t *testing.T) {
+	cfg := &AgentflowConfig{
+		Agents: map[string]AgentConfig{
+			"typo":  {Tool: "claude-code", Model: "sonet"},
+			"new":   {Tool: "opencode", Model: "my-proxy/llama"},
+			"env":   {Tool: "claude-code", Model: "${MODEL}", Unresolved: []string{"MODEL"}},
+			"right": {Tool: "claude-code", Model: "opus"},
+		},
+		Tasks: map[string]TaskConfig{
+			"a": {Agent: "right", Prompt: "test"},
+		},
+	}
+
+	// A near miss fails validation
+	err := ValidateWithFile(cfg, "Cortexfile.yml")
+	if err == nil || !strings.Contains(err.Error(), `agent "typo": unknown claude-code model "sonet" (did you mean "sonnet"?) [CORTEX-VAL-039]`) {
+		t.Errorf("ValidateWithFile() error = %v, want the typo reported", err)
+	}
+	if n := strings.Count(fmt.Sprint(err), CodeUnknownModel); n != 1 {
+		t.Errorf("ValidateWithFile() error = %v, want only the typo reported", err)
+	}
+
+	// Other unknown models only warn
+	var got []string
+	for _, w := range Warnings(cfg, SettingsConfig{}, "Cortexfile.yml") {
+		if w.Code == CodeUncataloguedModel {
+			got = append(got, w.Message)
+		}
+	}
+	if len(got) != 1 || got[0] != `agent "new": opencode model "my-proxy/llama" isn't one cortex knows` {
+		t.Errorf("model warnings = %q", got)
+	}
+}
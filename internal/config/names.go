@@ -0,0 +1,67 @@
+package config
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// namePattern is what agent and task names may contain. Names become file
+// names (task output and state files), {{outputs.X}} placeholders, and graph
+// node IDs, so they're kept to ASCII letters, digits, '-', and '_'.
+var namePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_-]*$`)
+
+// maxNameLength keeps file names derived from task names well under
+// filesystem limits.
+const maxNameLength = 64
+
+// IsValidName reports whether name can name an agent or task.
+func IsValidName(name string) bool {
+	return len(name) <= maxNameLength && namePattern.MatchString(name)
+}
+
+// NormalizeName turns name into a valid one: lowercase, accents dropped,
+// and runs of spaces and other characters replaced by a single '-'.
+// Returns "" if nothing usable is left.
+func NormalizeName(name string) string {
+	var sb strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(name) {
+		r = foldAccent(r)
+		switch {
+		case r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_'):
+			if dash && sb.Len() > 0 {
+				sb.WriteByte('-')
+			}
+			dash = false
+			sb.WriteRune(r)
+		default:
+			dash = true
+		}
+	}
+	normalized := strings.TrimLeft(sb.String(), "_")
+	if len(normalized) > maxNameLength {
+		normalized = strings.TrimRight(normalized[:maxNameLength], "-_")
+	}
+	return normalized
+}
+
+// accents maps accented Latin letters to their base letter.
+var accents = map[rune]rune{
+	'à': 'a', 'á': 'a', 'â': 'a', 'ã': 'a', 'ä': 'a', 'å': 'a',
+	'ç': 'c',
+	'è': 'e', 'é': 'e', 'ê': 'e', 'ë': 'e',
+	'ì': 'i', 'í': 'i', 'î': 'i', 'ï': 'i',
+	'ñ': 'n',
+	'ò': 'o', 'ó': 'o', 'ô': 'o', 'õ': 'o', 'ö': 'o', 'ø': 'o',
+	'ù': 'u', 'ú': 'u', 'û': 'u', 'ü': 'u',
+	'ý': 'y', 'ÿ': 'y',
+}
+
+// foldAccent returns the base letter of an accented one, or r.
+func foldAccent(r rune) rune {
+	if base, ok := accents[r]; ok {
+		return base
+	}
+	return r
+}
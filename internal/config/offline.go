@@ -0,0 +1,109 @@
+package config
+
+import (
+	"net"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// OfflineModelProviders are the opencode model providers that serve models
+// from the local machine, e.g. "ollama/llama3.1".
+var OfflineModelProviders = []string{"ollama", "lmstudio"}
+
+// ValidateOffline checks that the workflow can run without network access
+// (--offline). Shell agents run locally; opencode agents may use a model
+// from a local provider. Returns nil if the workflow is local, or a
+// ConfigErrors listing everything that would need the network.
+//
+// Commands run by shell tasks aren't inspected; a command that reaches the
+// network will still fail when it runs.
+func ValidateOffline(config *AgentflowConfig, filePath string) error {
+	errs := &ConfigErrors{}
+
+	for _, name := range sortedNames(config.Agents) {
+		agent := config.Agents[name]
+		if isOfflineAgent(agent.Tool, agent.Model) {
+			continue
+		}
+		errs.Add(NewCodedError(CodeNeedsNetwork, filePath, 0,
+			"agent \""+name+"\": tool '"+agent.Tool+"' needs network access",
+			"Offline runs support shell agents and opencode with a local model, e.g. 'model: ollama/llama3.1'"))
+	}
+
+	if r := config.Retrieval; r != nil {
+		switch r.Provider {
+		case EmbedOpenAI:
+			if r.URL == "" || !isLocalURL(r.URL) {
+				errs.Add(NewCodedError(CodeNeedsNetwork, filePath, 0,
+					"retrieval: provider 'openai' needs network access",
+					"Use 'provider: local' or 'provider: ollama', or point 'url' at a local server"))
+			}
+		case EmbedOllama:
+			if r.URL != "" && !isLocalURL(r.URL) {
+				errs.Add(NewCodedError(CodeNeedsNetwork, filePath, 0,
+					"retrieval: url "+r.URL+" is not on this machine",
+					"Point 'url' at a local Ollama server, or remove it to use the default"))
+			}
+		}
+	}
+
+	for _, name := range sortedNames(config.Tasks) {
+		task := config.Tasks[name]
+		if task.Commit != nil && task.Commit.PR {
+			errs.Add(NewCodedError(CodeNeedsNetwork, filePath, 0,
+				"task \""+name+"\": 'commit.pr' pushes to the remote and needs network access",
+				"Remove 'pr: true' to commit locally, and push once back online"))
+		}
+	}
+
+	if errs.HasErrors() {
+		return errs
+	}
+	return nil
+}
+
+// isOfflineAgent reports whether an agent with this tool and model runs
+// without network access.
+func isOfflineAgent(tool, model string) bool {
+	switch tool {
+	case "shell":
+		return true
+	case "opencode":
+		provider, _, ok := strings.Cut(model, "/")
+		if !ok {
+			return false
+		}
+		for _, p := range OfflineModelProviders {
+			if provider == p {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// isLocalURL reports whether raw points at the loopback interface.
+func isLocalURL(raw string) bool {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return false
+	}
+	host := u.Hostname()
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// sortedNames returns the keys of m in order, so errors are reported in a
+// stable order.
+func sortedNames[V any](m map[string]V) []string {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -1,32 +1,108 @@
 package config
 
 import (
+	"errors"
 	"fmt"
+	"io/fs"
 	"os"
 	"path/filepath"
-
-	"gopkg.in/yaml.v3"
+	"reflect"
 )
 
 // LoadConfig loads and parses an Agentfile from the given path.
-// It also resolves prompt_file references relative to the Agentfile directory.
+// It also resolves prompt_file references relative to the Agentfile directory,
+// merges the files it includes, resolves agents' extends, and then expands
+// matrix tasks.
 func LoadConfig(path string) (*AgentflowConfig, error) {
-	data, err := os.ReadFile(path)
+	stack := []string{path}
+	if abs, err := filepath.Abs(path); err == nil {
+		stack[0] = abs
+	}
+	config, err := loadConfig(path, stack)
+	if err != nil {
+		return nil, err
+	}
+	resolveExtends(config)
+	if err := expandMatrices(config); err != nil {
+		var configErr *ConfigError
+		if errors.As(err, &configErr) && configErr.File == "" {
+			configErr.File = path
+		}
+		return nil, err
+	}
+	return config, nil
+}
+
+// loadConfig is LoadConfig for a file included by the files in stack
+// (which ends with path itself).
+func loadConfig(path string, stack []string) (*AgentflowConfig, error) {
+	data, err := readLimited(path, MaxConfigBytes, "config file")
 	if err != nil {
 		return nil, fmt.Errorf("failed to read config file: %w", err)
 	}
 
-	return ParseConfig(data, filepath.Dir(path))
+	config, err := parseConfig(data, filepath.Dir(path), FormatOf(path), stack)
+	var configErr *ConfigError
+	if errors.As(err, &configErr) && configErr.File == "" {
+		configErr.File = path
+	}
+	if err != nil {
+		return nil, err
+	}
+	for _, d := range config.Deprecations {
+		if d.File == "" {
+			d.File = path
+		}
+	}
+	if d := legacyName(path); d != nil {
+		config.Deprecations = append(config.Deprecations, d)
+	}
+	return config, nil
 }
 
 // ParseConfig parses YAML config data and resolves prompt_file references.
-// baseDir is used to resolve relative prompt_file paths.
+// baseDir is used to resolve relative prompt_file and include paths.
 func ParseConfig(data []byte, baseDir string) (*AgentflowConfig, error) {
+	config, err := parseConfig(data, baseDir, FormatYAML, nil)
+	if err != nil {
+		return nil, err
+	}
+	resolveExtends(config)
+	if err := expandMatrices(config); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// parseConfig is ParseConfig for data in format (see FormatOf) loaded from
+// the last of the files in stack, if any.
+func parseConfig(data []byte, baseDir, format string, stack []string) (*AgentflowConfig, error) {
 	var config AgentflowConfig
 
-	if err := yaml.Unmarshal(data, &config); err != nil {
-		return nil, fmt.Errorf("failed to parse YAML: %w", err)
+	doc, err := parseDocument(data, format)
+	if err != nil {
+		return nil, ErrParse("", 0, format, err.Error())
+	}
+	deprecations, err := migrate(doc)
+	if err != nil {
+		return nil, err
+	}
+	renamed, err := renameDeprecated(doc)
+	if err != nil {
+		return nil, err
+	}
+	deprecations = append(deprecations, renamed...)
+	if err := checkKnownFields(doc, reflect.TypeOf(config)); err != nil {
+		return nil, err
 	}
+	if doc.Kind != 0 {
+		if err := doc.Decode(&config); err != nil {
+			return nil, ErrParse("", 0, format, err.Error())
+		}
+	}
+
+	config.Deprecations = deprecations
+	config.Lines = definitionLines(doc)
 
 	// Initialize maps if nil (empty config)
 	if config.Agents == nil {
@@ -36,44 +112,172 @@ func ParseConfig(data []byte, baseDir string) (*AgentflowConfig, error) {
 		config.Tasks = make(map[string]TaskConfig)
 	}
 
+	// Environment variables are resolved before prompt files are loaded,
+	// so only inline prompts are interpolated
+	interpolateConfig(&config, os.LookupEnv)
+
 	// Resolve prompt_file references
 	if err := resolvePromptFiles(&config, baseDir); err != nil {
 		return nil, err
 	}
+	if err := resolvePreambleFile(&config, baseDir); err != nil {
+		return nil, err
+	}
+	if err := resolveInstructionsFiles(&config, baseDir); err != nil {
+		return nil, err
+	}
+	if err := resolveIncludes(&config, baseDir, stack); err != nil {
+		return nil, err
+	}
 
 	return &config, nil
 }
 
-// resolvePromptFiles loads content from prompt_file paths into the Prompt field.
+// resolvePromptFiles loads content from prompt_file paths into the Prompt
+// field, and schema_file paths into Schema, for the top-level tasks and
+// those of each named workflow.
 func resolvePromptFiles(config *AgentflowConfig, baseDir string) error {
-	for name, task := range config.Tasks {
-		if task.PromptFile != "" {
-			// Resolve path relative to config file directory
-			promptPath := task.PromptFile
-			if !filepath.IsAbs(promptPath) {
-				promptPath = filepath.Join(baseDir, promptPath)
-			}
+	if err := resolveTaskPromptFiles(config.Tasks, baseDir, config.Workdir); err != nil {
+		return err
+	}
+	if err := resolveTaskSchemaFiles(config.Tasks, baseDir); err != nil {
+		return err
+	}
+	for name, workflow := range config.Workflows {
+		if err := resolveTaskPromptFiles(workflow.Tasks, baseDir, config.Workdir); err != nil {
+			return fmt.Errorf("workflow %q: %w", name, err)
+		}
+		if err := resolveTaskSchemaFiles(workflow.Tasks, baseDir); err != nil {
+			return fmt.Errorf("workflow %q: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// resolveTaskSchemaFiles loads the schema_file of each of tasks, relative
+// to the Cortexfile's directory, into Schema. Schema files are JSON, or
+// YAML.
+func resolveTaskSchemaFiles(tasks map[string]TaskConfig, baseDir string) error {
+	for name, task := range tasks {
+		if task.SchemaFile == "" {
+			continue
+		}
+		if len(task.Schema) > 0 {
+			return fmt.Errorf("task %q: cannot have both 'schema' and 'schema_file'", name)
+		}
+		path := task.SchemaFile
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(baseDir, path)
+		}
+		data, err := ReadPromptFile(path, "schema_file")
+		if err != nil {
+			return fmt.Errorf("task %q: failed to read schema_file %q: %w", name, task.SchemaFile, err)
+		}
+		if err := decodeYAML(data, &task.Schema); err != nil {
+			return fmt.Errorf("task %q: schema_file %q must hold a JSON Schema object: %w", name, task.SchemaFile, err)
+		}
+		if task.Schema == nil {
+			return fmt.Errorf("task %q: schema_file %q is empty", name, task.SchemaFile)
+		}
+		tasks[name] = task
+	}
+	return nil
+}
+
+// resolveTaskPromptFiles loads the prompt_file of each of tasks, found
+// relative to baseDir or else to workdir, and records where in PromptPath.
+// Missing files, and tasks that also have an inline prompt, are left for
+// validation to report.
+func resolveTaskPromptFiles(tasks map[string]TaskConfig, baseDir, workdir string) error {
+	for name, task := range tasks {
+		if task.PromptFile == "" || task.Prompt != "" {
+			continue
+		}
+		task.PromptPath = findPromptFile(task.PromptFile, baseDir, workdir)
+		content, err := ReadPromptFile(task.PromptPath, "prompt_file")
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("task %q: failed to read prompt_file %q: %w", name, task.PromptFile, err)
+		}
+
+		// The loaded content is the prompt, templated like an inline one
+		task.Prompt = string(content)
+		tasks[name] = task
+	}
+	return nil
+}
+
+// findPromptFile returns the path of a prompt_file: relative to the
+// Cortexfile's directory, or to the workdir if only that has it.
+func findPromptFile(path, baseDir, workdir string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	inBase := filepath.Join(baseDir, path)
+	if _, err := os.Stat(inBase); err != nil && workdir != "" {
+		inWorkdir := filepath.Join(workdir, path)
+		if _, err := os.Stat(inWorkdir); err == nil {
+			return inWorkdir
+		}
+	}
+	return inBase
+}
 
-			content, err := os.ReadFile(promptPath)
-			if err != nil {
-				return fmt.Errorf("task %q: failed to read prompt_file %q: %w", name, task.PromptFile, err)
-			}
+// resolvePreambleFile loads preamble_file into the Preamble field.
+func resolvePreambleFile(config *AgentflowConfig, baseDir string) error {
+	if config.PreambleFile == "" {
+		return nil
+	}
+	if config.Preamble != "" {
+		return fmt.Errorf("cannot have both 'preamble' and 'preamble_file'")
+	}
+
+	path := config.PreambleFile
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(baseDir, path)
+	}
+	content, err := ReadPromptFile(path, "preamble_file")
+	if err != nil {
+		return fmt.Errorf("failed to read preamble_file %q: %w", config.PreambleFile, err)
+	}
+	config.Preamble = string(content)
+	return nil
+}
+
+// resolveInstructionsFiles loads each agent's instructions_file into its
+// Instructions field.
+func resolveInstructionsFiles(config *AgentflowConfig, baseDir string) error {
+	for name, agent := range config.Agents {
+		if agent.InstructionsFile == "" {
+			continue
+		}
+		if agent.Instructions != "" {
+			return fmt.Errorf("agent %q: cannot have both 'instructions' and 'instructions_file'", name)
+		}
 
-			// Store the loaded content in Prompt field
-			task.Prompt = string(content)
-			config.Tasks[name] = task
+		path := agent.InstructionsFile
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(baseDir, path)
+		}
+		content, err := ReadPromptFile(path, "instructions_file")
+		if err != nil {
+			return fmt.Errorf("agent %q: failed to read instructions_file %q: %w", name, agent.InstructionsFile, err)
 		}
+		agent.Instructions = string(content)
+		config.Agents[name] = agent
 	}
 	return nil
 }
 
 // FindCortexfile searches for a Cortexfile in the current directory.
-// It looks for: Cortexfile.yml, Cortexfile.yaml, cortexfile.yml, cortexfile.yaml
+// It looks for: Cortexfile.yml, Cortexfile.yaml, Cortexfile.json,
+// Cortexfile.toml, cortexfile.yml, cortexfile.yaml
 // Also supports legacy: Agentfile.yml, Agentfile.yaml
 func FindCortexfile(dir string) (string, error) {
 	candidates := []string{
 		"Cortexfile.yml",
 		"Cortexfile.yaml",
+		"Cortexfile.json",
+		"Cortexfile.toml",
 		"cortexfile.yml",
 		"cortexfile.yaml",
 		// Legacy support
@@ -90,3 +294,34 @@ func FindCortexfile(dir string) (string, error) {
 
 	return "", fmt.Errorf("no Cortexfile found in %s (tried: %v)", dir, candidates[:4])
 }
+
+// FindCortexfileUp searches dir and then each parent directory for a
+// Cortexfile, the way git looks for .git, so commands work from anywhere in
+// a project. The directory it is found in is the project root.
+func FindCortexfileUp(dir string) (string, error) {
+	path, err := findUp(dir, FindCortexfile)
+	if err != nil {
+		return "", fmt.Errorf("no Cortexfile found in %s or any parent directory", dir)
+	}
+	return path, nil
+}
+
+// findUp calls find on dir and each of its parents in turn, returning the
+// first path found.
+func findUp(dir string, find func(dir string) (string, error)) (string, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	for {
+		path, err := find(dir)
+		if err == nil {
+			return path, nil
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", err
+		}
+		dir = parent
+	}
+}
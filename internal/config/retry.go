@@ -0,0 +1,30 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultRetryBackoff is the pause before a task's first retry.
+const DefaultRetryBackoff = 10 * time.Second
+
+// ParseRetryOn splits retry_on entries into exit codes and compiled
+// patterns. Entries that are integers are exit codes; the rest are
+// regular expressions.
+func ParseRetryOn(entries []string) (codes []int, patterns []*regexp.Regexp, err error) {
+	for _, entry := range entries {
+		if code, err := strconv.Atoi(strings.TrimSpace(entry)); err == nil {
+			codes = append(codes, code)
+			continue
+		}
+		re, err := regexp.Compile(entry)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid pattern %q: %w", entry, err)
+		}
+		patterns = append(patterns, re)
+	}
+	return codes, patterns, nil
+}
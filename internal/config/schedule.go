@@ -0,0 +1,95 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ScheduleConfig limits when runs start and how much they may use per
+// day, e.g. to keep heavy audits to the night. Set in settings, it applies
+// to every run of the project; set on a named workflow, it replaces the
+// settings' for runs of that workflow.
+type ScheduleConfig struct {
+	// Window is the span of local time runs may start in, as
+	// "HH:MM-HH:MM". It wraps past midnight when the end is earlier, e.g.
+	// "22:00-06:00". Runs already going when it closes carry on.
+	Window string `yaml:"window"`
+
+	// DailyMaxTokens and DailyMaxCost cap the tokens, and the cost in USD
+	// reported by the tools, that the project's runs use per day, counted
+	// since local midnight. Runs don't start once either is used up, and a
+	// run is stopped once it uses the tokens left (0 = no limit).
+	DailyMaxTokens int     `yaml:"daily_max_tokens"`
+	DailyMaxCost   float64 `yaml:"daily_max_cost"`
+}
+
+// Validate checks the schedule. A nil config is valid.
+func (s *ScheduleConfig) Validate() error {
+	if s == nil {
+		return nil
+	}
+	if s.Window != "" {
+		if _, err := ParseWindow(s.Window); err != nil {
+			return err
+		}
+	}
+	if s.DailyMaxTokens < 0 || s.DailyMaxCost < 0 {
+		return fmt.Errorf("schedule: daily quotas cannot be negative (use 0 for no limit)")
+	}
+	return nil
+}
+
+// Window is a daily span of local time, as offsets from midnight.
+type Window struct {
+	Start, End time.Duration
+}
+
+// ParseWindow parses a window written as "HH:MM-HH:MM".
+func ParseWindow(s string) (Window, error) {
+	start, end, ok := strings.Cut(s, "-")
+	if !ok {
+		return Window{}, fmt.Errorf("schedule: invalid window %q (use HH:MM-HH:MM, e.g. 22:00-06:00)", s)
+	}
+	var w Window
+	for _, t := range []struct {
+		text string
+		into *time.Duration
+	}{{start, &w.Start}, {end, &w.End}} {
+		clock, err := time.Parse("15:04", strings.TrimSpace(t.text))
+		if err != nil {
+			return Window{}, fmt.Errorf("schedule: invalid window %q (use HH:MM-HH:MM, e.g. 22:00-06:00)", s)
+		}
+		*t.into = time.Duration(clock.Hour())*time.Hour + time.Duration(clock.Minute())*time.Minute
+	}
+	if w.Start == w.End {
+		return Window{}, fmt.Errorf("schedule: window %q is empty (remove it to allow runs at any time)", s)
+	}
+	return w, nil
+}
+
+// Contains reports whether t, in its own location, falls in the window.
+func (w Window) Contains(t time.Time) bool {
+	h, m, sec := t.Clock()
+	now := time.Duration(h)*time.Hour + time.Duration(m)*time.Minute + time.Duration(sec)*time.Second
+	if w.Start < w.End {
+		return now >= w.Start && now < w.End
+	}
+	return now >= w.Start || now < w.End
+}
+
+// Next returns when the window next opens after t: today at its start,
+// or tomorrow if that has passed.
+func (w Window) Next(t time.Time) time.Time {
+	hour, minute := int(w.Start/time.Hour), int(w.Start%time.Hour/time.Minute)
+	next := time.Date(t.Year(), t.Month(), t.Day(), hour, minute, 0, 0, t.Location())
+	if !next.After(t) {
+		next = time.Date(t.Year(), t.Month(), t.Day()+1, hour, minute, 0, 0, t.Location())
+	}
+	return next
+}
+
+// StartOfDay returns the midnight that starts t's day, in its location.
+func StartOfDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
@@ -0,0 +1,112 @@
+package config
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseWindow(t *testing.T) {
+	tests := []struct {
+		window  string
+		wantErr string
+	}{
+		{"09:00-17:00", ""},
+		{"22:00-06:00", ""},
+		{" 22:30 - 6:15 ", ""},
+		{"22:00", "use HH:MM-HH:MM"},
+		{"25:00-06:00", "use HH:MM-HH:MM"},
+		{"night-day", "use HH:MM-HH:MM"},
+		{"08:00-08:00", "is empty"},
+	}
+	for _, tt := range tests {
+		_, err := ParseWindow(tt.window)
+		if tt.wantErr == "" && err != nil {
+			t.Errorf("ParseWindow(%q) error = %v", tt.window, err)
+		}
+		if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+			t.Errorf("ParseWindow(%q) error = %v, want %q", tt.window, err, tt.wantErr)
+		}
+	}
+}
+
+func TestWindowContainsAndNext(t *testing.T) {
+	at := func(clock string) time.Time {
+		t.Helper()
+		tm, err := time.ParseInLocation("2006-01-02 15:04", "2026-03-10 "+clock, time.Local)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return tm
+	}
+	tests := []struct {
+		window, now string
+		want        bool
+		next        string
+	}{
+		{"09:00-17:00", "12:00", true, "2026-03-11 09:00"},
+		{"09:00-17:00", "17:00", false, "2026-03-11 09:00"},
+		{"09:00-17:00", "08:59", false, "2026-03-10 09:00"},
+		{"22:00-06:00", "23:30", true, "2026-03-11 22:00"},
+		{"22:00-06:00", "05:59", true, "2026-03-10 22:00"},
+		{"22:00-06:00", "12:00", false, "2026-03-10 22:00"},
+	}
+	for _, tt := range tests {
+		w, err := ParseWindow(tt.window)
+		if err != nil {
+			t.Fatal(err)
+		}
+		now := at(tt.now)
+		if got := w.Contains(now); got != tt.want {
+			t.Errorf("%s Contains(%s) = %v, want %v", tt.window, tt.now, got, tt.want)
+		}
+		if got := w.Next(now).Format("2006-01-02 15:04"); got != tt.next {
+			t.Errorf("%s Next(%s) = %s, want %s", tt.window, tt.now, got, tt.next)
+		}
+	}
+}
+
+// TestSelectWorkflow_Schedule tests that a workflow's schedule replaces
+// the settings' when it's selected.
+func TestSelectWorkflow_Schedule(t *testing.T) {
+	cfg, err := ParseConfig([]byte(`
+agents:
+  ai: {tool: claude-code}
+settings:
+  max_parallel: 2
+  schedule: {daily_max_tokens: 1000}
+workflows:
+  audit:
+    schedule: {window: "22:00-06:00"}
+    tasks:
+      scan: {agent: ai, prompt: Scan}
+  review:
+    tasks:
+      read: {agent: ai, prompt: Read}
+`), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	review := *cfg
+	if err := SelectWorkflow(&review, "review"); err != nil {
+		t.Fatal(err)
+	}
+	if s := review.Settings.Schedule; s == nil || s.DailyMaxTokens != 1000 {
+		t.Errorf("review schedule = %+v, want the settings'", s)
+	}
+
+	if err := SelectWorkflow(cfg, "audit"); err != nil {
+		t.Fatal(err)
+	}
+	if s := cfg.Settings.Schedule; s == nil || s.Window != "22:00-06:00" || s.DailyMaxTokens != 0 {
+		t.Errorf("audit schedule = %+v, want the workflow's", s)
+	}
+	if cfg.Settings.MaxParallel != 2 {
+		t.Errorf("selecting the workflow lost the other settings")
+	}
+
+	cfg.Settings.Schedule = &ScheduleConfig{Window: "late"}
+	if err := ValidateWithFile(cfg, ""); err == nil || !strings.Contains(err.Error(), "settings.schedule: invalid window") {
+		t.Errorf("ValidateWithFile() with an invalid window: %v", err)
+	}
+}
@@ -0,0 +1,265 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+)
+
+// SchemaDraft is the JSON Schema version Schema follows; it's the one
+// editors' YAML support understands best.
+const SchemaDraft = "http://json-schema.org/draft-07/schema#"
+
+// Schema returns a JSON Schema describing Cortexfile.yml, for editors'
+// autocompletion and for validating configs without running cortex. It is
+// generated from AgentflowConfig and the types it contains, so it lists
+// every key Cortex reads; keys that only some tools or agents use still
+// need 'cortex validate' to check how they combine.
+func Schema() map[string]any {
+	g := &schemaGen{defs: make(map[string]any)}
+	root := g.structSchema(reflect.TypeOf(AgentflowConfig{}))
+	root["$schema"] = SchemaDraft
+	root["title"] = "Cortexfile"
+	root["description"] = "Cortex workflow: agents, the tasks they run, and how tasks depend on each other"
+	root["definitions"] = g.defs
+	return root
+}
+
+// schemaGen builds the schema, collecting nested struct types in defs.
+type schemaGen struct {
+	defs map[string]any
+}
+
+// stringOrList is the schema of StringList and of the fields that take a
+// string or a list of arguments.
+func stringOrList() map[string]any {
+	return map[string]any{
+		"type":  []string{"string", "array"},
+		"items": map[string]any{"type": "string"},
+	}
+}
+
+// typeSchema returns the schema of values of type t; struct types are
+// referenced from definitions.
+func (g *schemaGen) typeSchema(t reflect.Type) map[string]any {
+	if t == reflect.TypeOf(StringList{}) {
+		return stringOrList()
+	}
+	switch t.Kind() {
+	case reflect.Pointer:
+		return g.typeSchema(t.Elem())
+	case reflect.Struct:
+		if _, ok := g.defs[t.Name()]; !ok {
+			g.defs[t.Name()] = nil // Placeholder, in case of recursion
+			g.defs[t.Name()] = g.structSchema(t)
+		}
+		return map[string]any{"$ref": "#/definitions/" + t.Name()}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": g.typeSchema(t.Elem())}
+	case reflect.Slice:
+		return map[string]any{"type": "array", "items": g.typeSchema(t.Elem())}
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float64:
+		return map[string]any{"type": "number"}
+	}
+	return map[string]any{}
+}
+
+// structSchema returns the schema of an object with t's YAML keys.
+func (g *schemaGen) structSchema(t reflect.Type) map[string]any {
+	props := make(map[string]any)
+	for i := range t.NumField() {
+		f := t.Field(i)
+		key, _, _ := strings.Cut(f.Tag.Get("yaml"), ",")
+		if key == "-" || !f.IsExported() {
+			continue
+		}
+		if key == "" {
+			key = strings.ToLower(f.Name)
+		}
+
+		field := t.Name() + "." + key
+		var s map[string]any
+		if schemaArgumentLists[field] {
+			s = stringOrList()
+		} else if field == "TaskConfig.write" {
+			s = map[string]any{"oneOf": []any{
+				map[string]any{"type": "boolean"},
+				map[string]any{"enum": []string{WriteModePatch}},
+			}}
+		} else {
+			s = g.typeSchema(f.Type)
+		}
+		if values, ok := schemaEnums[field]; ok {
+			s["enum"] = values
+		}
+		if namedMaps[field] {
+			s["propertyNames"] = map[string]any{"pattern": namePattern.String(), "maxLength": maxNameLength}
+		}
+		if desc, ok := schemaDescriptions[field]; ok {
+			if _, ref := s["$ref"]; ref {
+				// Draft 7 ignores keywords beside $ref
+				s = map[string]any{"allOf": []any{s}}
+			}
+			s["description"] = desc
+		}
+		props[key] = s
+	}
+	return map[string]any{
+		"type":                 "object",
+		"properties":           props,
+		"additionalProperties": false,
+		"patternProperties":    map[string]any{"^" + ExtensionPrefix: map[string]any{}},
+	}
+}
+
+// schemaArgumentLists are the fields that may be a string run by a shell
+// or a list of arguments (see TaskConfig.UnmarshalYAML).
+var schemaArgumentLists = map[string]bool{
+	"TaskConfig.command": true,
+	"TaskConfig.verify":  true,
+}
+
+// namedMaps are the maps keyed by agent or task names.
+var namedMaps = map[string]bool{
+	"AgentflowConfig.agents": true,
+	"AgentflowConfig.tasks":  true,
+	"NamedWorkflow.tasks":    true,
+}
+
+// schemaEnums lists the allowed values of fields that have a fixed set.
+var schemaEnums = map[string][]string{
+	"AgentConfig.tool":               SupportedTools,
+	"TaskConfig.on_context_overflow": {OverflowTruncate, OverflowSummarize, OverflowFail},
+	"TaskConfig.memory":              {MemoryRead, MemoryWrite},
+	"TaskConfig.scope":               {ScopeFull, ScopeDiff},
+	"SettingsConfig.dirty_tree":      {DirtyTreeRefuse, DirtyTreeStash, DirtyTreeProceed},
+	"RetentionConfig.prompts":        {RetainFull, RetainHash, RetainNone},
+	"RetrievalConfig.provider":       {EmbedLocal, EmbedOpenAI, EmbedOllama},
+}
+
+// schemaDescriptions describe the fields, keyed by type and YAML key.
+var schemaDescriptions = map[string]string{
+	"AgentflowConfig.version":         "Layout the file is written in; older layouts are migrated on load",
+	"AgentflowConfig.include":         "Cortexfiles whose definitions are merged into this one, relative to this file",
+	"AgentflowConfig.agents":          "AI agents and shell runners, by name",
+	"AgentflowConfig.tasks":           "Tasks to run, by name",
+	"AgentflowConfig.settings":        "Execution settings, overriding ~/.cortex/config.yml",
+	"AgentflowConfig.workdir":         "Working directory for agents",
+	"AgentflowConfig.interchangeable": "Task tags mapped to agents that can stand in for each other",
+	"AgentflowConfig.secrets":         "Secrets by name, each read from \"env:VAR\", \"file:path\", or \"command:cmd\"",
+	"AgentflowConfig.variables":       "Values prompts, commands, and conditions use as {{vars.NAME}}",
+	"AgentflowConfig.preamble":        "Text prepended to every AI task's prompt",
+	"AgentflowConfig.preamble_file":   "File holding the preamble, relative to the Cortexfile",
+	"AgentflowConfig.retrieval":       "Embedding index used by {{retrieve \"query\"}}",
+	"AgentflowConfig.digest":          "Markdown digest of task outputs written after each run",
+	"AgentflowConfig.workflows":       "Named sets of tasks, run with 'cortex run <name>'",
+	"AgentflowConfig.groups":          "Named selections of tasks, run with 'cortex run --group <name>'",
+
+	"AgentConfig.tool":              "CLI the agent runs",
+	"AgentConfig.model":             "Model identifier, e.g. sonnet or opus",
+	"AgentConfig.extends":           "Agent whose settings this one takes for the fields it leaves out",
+	"AgentConfig.override":          "Replace an agent of the same name from an earlier file instead of failing",
+	"AgentConfig.max_concurrent":    "Most tasks running on this agent at once (0 = no limit)",
+	"AgentConfig.system_prompt":     "System prompt for every task on this agent, after Cortex's output rules",
+	"AgentConfig.instructions":      "Text starting the prompt of every task on this agent, after the preamble",
+	"AgentConfig.instructions_file": "File holding the agent's instructions, relative to the Cortexfile",
+
+	"TaskConfig.uses":                "Published workflow to run in place of this task",
+	"TaskConfig.override":            "Replace a task of the same name from an earlier file instead of failing",
+	"TaskConfig.agent":               "Agent that runs the task",
+	"TaskConfig.prompt":              "Prompt for AI agents",
+	"TaskConfig.prompt_file":         "File holding the prompt, relative to the Cortexfile (or the workdir, if only it has the file)",
+	"TaskConfig.command":             "Command for shell agents: a string run by the shell, or a list run directly",
+	"TaskConfig.shell":               "Shell for command and verify (default: settings.shell)",
+	"TaskConfig.needs":               "Tasks that must finish first",
+	"TaskConfig.write":               "Allow the agent to change files; 'patch' saves its changes as a patch for 'cortex apply' instead",
+	"TaskConfig.tags":                "Labels used for routing to interchangeable agents",
+	"TaskConfig.verify":              "Command run after a write task; a non-zero exit fails the task",
+	"TaskConfig.workdir":             "Directory the task runs in, relative to the workdir or absolute; created if missing",
+	"TaskConfig.verify_workdir":      "Directory verify runs in, relative to the workdir",
+	"TaskConfig.fix_attempts":        "Times the agent is re-run with the verify output when verification fails",
+	"TaskConfig.retries":             "Times the agent is re-run when the task fails",
+	"TaskConfig.retry_backoff":       "Pause before the first retry, doubled before each later one (default: 10s)",
+	"TaskConfig.retry_on":            "Exit codes or output patterns to retry on (default: any failure)",
+	"TaskConfig.max_changed_files":   "Most files a write task may change (0 = no limit)",
+	"TaskConfig.max_changed_lines":   "Most lines a write task may change (0 = no limit)",
+	"TaskConfig.on_context_overflow": "How to recover when the prompt exceeds the model's context window",
+	"TaskConfig.max_tokens":          "Most input and output tokens the task may use (0 = no limit)",
+	"TaskConfig.memory":              "Access to the project memory kept across runs",
+	"TaskConfig.scope":               "'diff' limits the task to files changed since the base ref",
+	"TaskConfig.paths":               "Globs; the task is skipped in diff runs when no changed file matches",
+	"TaskConfig.writes":              "Globs of the files a write task changes; tasks with overlapping ones never run at once",
+	"TaskConfig.context":             "Add the repository files most relevant to the prompt to it",
+	"TaskConfig.compress":            "Make the task a built-in step that shortens text to a token budget",
+	"TaskConfig.normalize":           "Output cleanup before it's stored and used: ansi, utf8, newlines, progress (default: all), or none",
+	"TaskConfig.expect":              "Output format the task must produce: json; invalid output is handed back to the agent",
+	"TaskConfig.schema":              "JSON Schema the task's JSON output must match (with 'expect: json')",
+	"TaskConfig.schema_file":         "Path to a JSON Schema file the task's JSON output must match, relative to the Cortexfile",
+	"TaskConfig.expect_retries":      "Times the agent is re-run when its output doesn't match 'expect' (default: 0)",
+	"TaskConfig.commit":              "Commit the task's changes on the run branch, optionally opening a PR",
+	"TaskConfig.output_file":         "Path the task's output is saved to, relative to the workdir",
+	"TaskConfig.when":                "Condition the task runs on, e.g. \"{{outputs.scan}} contains 'CRITICAL'\"; skipped when false",
+	"TaskConfig.timeout":             "Stop the task once it has run this long, e.g. 10m",
+	"TaskConfig.matrix":              "Run the task once per combination of these values, available as {{matrix.KEY}}",
+
+	"CommitConfig.message_template": "Commit message; may use {{task.name}}, {{task.agent}}, and {{run.id}}",
+	"CommitConfig.pr":               "Push the run branch and open a pull request",
+	"CommitConfig.base":             "Pull request target branch (default: the remote's default branch)",
+
+	"ContextConfig.files":      "Globs of the files to choose from, relative to the workdir; '!' excludes",
+	"ContextConfig.max_tokens": "Token budget of the files added (0 = 8000)",
+
+	"CompressConfig.input":      "Text to shorten, e.g. \"{{outputs.test}}\"",
+	"CompressConfig.files":      "Globs of files to shorten, relative to the workdir; '!' excludes",
+	"CompressConfig.max_tokens": "Size to shorten the text to, in tokens (0 = 2000)",
+
+	"DigestConfig.path":  "Where the digest is written, relative to the workdir",
+	"DigestConfig.title": "Heading of the digest",
+	"DigestConfig.tasks": "Tasks to include (default: all)",
+
+	"RetrievalConfig.provider":    "Embedding provider",
+	"RetrievalConfig.model":       "Embedding model",
+	"RetrievalConfig.url":         "API base URL",
+	"RetrievalConfig.api_key_env": "Environment variable holding the API key",
+	"RetrievalConfig.max_tokens":  "Token budget per {{retrieve}}",
+
+	"NamedWorkflow.description": "What the workflow does",
+	"NamedWorkflow.tasks":       "The workflow's tasks, by name",
+	"NamedWorkflow.schedule":    "Replaces settings.schedule for runs of the workflow",
+	"NamedWorkflow.groups":      "Named selections of the workflow's tasks",
+
+	"SettingsConfig.parallel":     "Run independent tasks in parallel",
+	"SettingsConfig.max_parallel": "Most tasks running at once",
+	"SettingsConfig.verbose":      "Verbose output",
+	"SettingsConfig.stream":       "Stream agent logs",
+	"SettingsConfig.dirty_tree":   "What to do when write tasks would run on uncommitted changes",
+	"SettingsConfig.snapshot":     "Snapshot the workdir for 'cortex rollback'",
+	"SettingsConfig.workspace":    "Run in a copy of the repository under .cortex/workspaces, offering the changes as a patch",
+	"SettingsConfig.heartbeat":    "Seconds between progress reports from running tasks (0 = 30, negative = off)",
+	"SettingsConfig.schedule":     "When runs may start, and the tokens and cost they may use per day",
+	"SettingsConfig.max_tokens":   "Token budget for the whole run (0 = no limit)",
+	"SettingsConfig.base":         "Git ref diff-scoped tasks compare against",
+	"SettingsConfig.shell":        "Shell for shell tasks and verify commands",
+	"SettingsConfig.max_cpu":      "Hold back parallel tasks while system CPU use is above this percentage",
+	"SettingsConfig.max_memory":   "Hold back parallel tasks while memory use is above this percentage",
+	"SettingsConfig.min_disk_mb":  "Free space in MiB runs need (0 = 512, negative = off)",
+	"SettingsConfig.proxy":        "Proxy URL for outbound connections",
+	"SettingsConfig.no_proxy":     "Hosts to reach without the proxy",
+	"SettingsConfig.ca_bundle":    "PEM file of extra CA certificates to trust",
+	"SettingsConfig.max_depth":    "Warn about dependency chains longer than this (0 = 10, negative = off)",
+	"SettingsConfig.max_needs":    "Warn about tasks needing more tasks than this (0 = 8, negative = off)",
+	"SettingsConfig.encrypt":      "Encrypt run files and logs with the key in CORTEX_STATE_KEY",
+	"SettingsConfig.retention":    "What run files keep of prompts and task output",
+	"SettingsConfig.store":        "Where run records are kept: json or sqlite (global config only)",
+
+	"ScheduleConfig.window":           "Local time runs may start in, as HH:MM-HH:MM; wraps past midnight, e.g. 22:00-06:00",
+	"ScheduleConfig.daily_max_tokens": "Tokens the project's runs may use per day, since local midnight (0 = no limit)",
+	"ScheduleConfig.daily_max_cost":   "Cost in USD the project's runs may use per day, as the tools report it (0 = no limit)",
+	"RetentionConfig.prompts":         "Keep rendered prompts in full, only their hash, or not at all",
+	"RetentionConfig.redact":          "Result fields replaced with [redacted] in run files",
+}
@@ -0,0 +1,59 @@
+package config
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSchema(t *testing.T) {
+	schema := Schema()
+	if _, err := json.Marshal(schema); err != nil {
+		t.Fatalf("Schema() isn't valid JSON: %v", err)
+	}
+	defs := schema["definitions"].(map[string]any)
+
+	// Every key has a description, so new fields get documented
+	for name, def := range defs {
+		props := def.(map[string]any)["properties"].(map[string]any)
+		for key, prop := range props {
+			if _, ok := prop.(map[string]any)["description"]; !ok {
+				t.Errorf("%s.%s has no description", name, key)
+			}
+		}
+	}
+
+	task := defs["TaskConfig"].(map[string]any)["properties"].(map[string]any)
+	typ := reflect.TypeOf(TaskConfig{})
+	for i := range typ.NumField() {
+		key, _, _ := strings.Cut(typ.Field(i).Tag.Get("yaml"), ",")
+		if _, ok := task[key]; key != "-" && !ok {
+			t.Errorf("TaskConfig schema missing %q", key)
+		}
+	}
+	if got := task["command"].(map[string]any)["type"]; !reflect.DeepEqual(got, []string{"string", "array"}) {
+		t.Errorf("command type = %v, want string or array", got)
+	}
+	if got := task["memory"].(map[string]any)["enum"]; !reflect.DeepEqual(got, []string{MemoryRead, MemoryWrite}) {
+		t.Errorf("memory enum = %v", got)
+	}
+	if got := task["commit"].(map[string]any)["allOf"]; got == nil {
+		t.Errorf("commit = %v, want a reference to CommitConfig", task["commit"])
+	}
+
+	root := schema["properties"].(map[string]any)
+	tasks := root["tasks"].(map[string]any)
+	if tasks["additionalProperties"].(map[string]any)["$ref"] != "#/definitions/TaskConfig" {
+		t.Errorf("tasks = %v, want a map of TaskConfig", tasks)
+	}
+	if names, ok := tasks["propertyNames"].(map[string]any); !ok || names["pattern"] != namePattern.String() {
+		t.Errorf("tasks propertyNames = %v, want the name pattern", tasks["propertyNames"])
+	}
+	if _, ok := root["include"]; !ok {
+		t.Error("schema missing include")
+	}
+	if _, ok := root["sources"]; ok {
+		t.Error("schema lists Sources, which isn't read from YAML")
+	}
+}
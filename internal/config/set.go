@@ -0,0 +1,170 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// loadTimeKeys are the fields acted on while the Cortexfile loads, so
+// setting them afterwards would change nothing.
+var loadTimeKeys = []string{"version", "include", "override", "extends", "matrix", "uses", "prompt_file", "preamble_file", "instructions_file"}
+
+// ApplySet overrides values of a loaded config, for one-off changes that
+// shouldn't need an edit of the Cortexfile. Each override is "path=value":
+// path is a dot-separated chain of YAML keys and names, e.g.
+// agents.coder.model or settings.max_parallel, and value is read as YAML,
+// so numbers, booleans, and lists like [a, b] work. Text fields take value
+// as written. Agents and tasks must exist; entries of other maps, like
+// variables.NAME, are added if missing.
+func ApplySet(config *AgentflowConfig, overrides []string) error {
+	for _, override := range overrides {
+		key, value, ok := strings.Cut(override, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return fmt.Errorf("invalid --set %q: use path=value, e.g. agents.coder.model=opus", override)
+		}
+		path := strings.Split(key, ".")
+		if slices.Contains(loadTimeKeys, path[len(path)-1]) {
+			return fmt.Errorf("invalid --set %s: %s is read while the Cortexfile loads, so it can't be overridden", key, path[len(path)-1])
+		}
+		if err := setPath(reflect.ValueOf(config).Elem(), path, 0, value); err != nil {
+			return fmt.Errorf("invalid --set %s: %w", key, err)
+		}
+	}
+	return nil
+}
+
+// setPath sets the value at path[i:] within v, an addressable value
+// reached by path[:i].
+func setPath(v reflect.Value, path []string, i int, value string) error {
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		v = v.Elem()
+	}
+	if i == len(path) {
+		return setValue(v, value)
+	}
+	name, at := path[i], strings.Join(path[:i], ".")
+
+	switch v.Kind() {
+	case reflect.Struct:
+		if task, ok := v.Addr().Interface().(*TaskConfig); ok && i == len(path)-1 && (name == "write" || slices.Contains(argumentListKeys, name)) {
+			return setTaskField(task, name, value)
+		}
+		var keys []string
+		for j := range v.NumField() {
+			key, _, _ := strings.Cut(v.Type().Field(j).Tag.Get("yaml"), ",")
+			if key == name {
+				return setPath(v.Field(j), path, i+1, value)
+			}
+			if key != "" && key != "-" {
+				keys = append(keys, key)
+			}
+		}
+		if at == "" {
+			at = "the Cortexfile"
+		}
+		err := fmt.Errorf("%s has no field %q", at, name)
+		if s := SuggestClosestMatch(name, keys); s != "" {
+			err = fmt.Errorf("%w (did you mean %q?)", err, s)
+		}
+		return err
+
+	case reflect.Map:
+		if v.IsNil() {
+			v.Set(reflect.MakeMap(v.Type()))
+		}
+		key := reflect.ValueOf(name)
+		elem := reflect.New(v.Type().Elem()).Elem()
+		if existing := v.MapIndex(key); existing.IsValid() {
+			elem.Set(existing)
+		} else if k := elem.Kind(); k == reflect.Struct || k == reflect.Pointer && elem.Type().Elem().Kind() == reflect.Struct {
+			err := fmt.Errorf("%s has no %q", at, name)
+			if s := SuggestClosestMatch(name, sortedMapKeys(v)); s != "" {
+				err = fmt.Errorf("%w (did you mean %q?)", err, s)
+			}
+			return err
+		}
+		if err := setPath(elem, path, i+1, value); err != nil {
+			return err
+		}
+		v.SetMapIndex(key, elem)
+		return nil
+
+	case reflect.Slice:
+		return fmt.Errorf("%s is a list; set all of it, e.g. %s=[a, b]", at, at)
+	default:
+		return fmt.Errorf("%s is a single value and has no fields", at)
+	}
+}
+
+// setValue sets v to value: as written for text, otherwise decoded as
+// YAML into v's type.
+func setValue(v reflect.Value, value string) error {
+	if v.Kind() == reflect.String {
+		v.SetString(value)
+		return nil
+	}
+	decoded := reflect.New(v.Type())
+	if err := yaml.Unmarshal([]byte(value), decoded.Interface()); err != nil {
+		return fmt.Errorf("%q isn't a valid %s", value, describeKind(v.Type()))
+	}
+	v.Set(decoded.Elem())
+	return nil
+}
+
+// setTaskField sets a task's write, command, or verify, which
+// UnmarshalYAML decodes specially: 'write: patch' and argument lists.
+func setTaskField(task *TaskConfig, key, value string) error {
+	node := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: value}
+	var doc yaml.Node
+	if err := yaml.Unmarshal([]byte(value), &doc); err == nil && len(doc.Content) == 1 {
+		if parsed := doc.Content[0]; parsed.Kind == yaml.SequenceNode || key == "write" {
+			node = parsed
+		}
+	}
+	var scratch TaskConfig
+	mapping := &yaml.Node{Kind: yaml.MappingNode, Content: []*yaml.Node{{Kind: yaml.ScalarNode, Value: key}, node}}
+	if err := scratch.UnmarshalYAML(mapping); err != nil {
+		return fmt.Errorf("%q isn't a valid value for %s", value, key)
+	}
+	switch key {
+	case "write":
+		task.Write, task.WritePatch = scratch.Write, scratch.WritePatch
+	case "command":
+		task.Command, task.CommandArgs = scratch.Command, scratch.CommandArgs
+	case "verify":
+		task.Verify, task.VerifyArgs = scratch.Verify, scratch.VerifyArgs
+	}
+	return nil
+}
+
+// describeKind names a value type for errors.
+func describeKind(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.Bool:
+		return "boolean (true or false)"
+	case reflect.Int, reflect.Int64, reflect.Float64:
+		return "number"
+	case reflect.Slice:
+		return "list"
+	default:
+		return "value"
+	}
+}
+
+// sortedMapKeys returns the keys of a map with string keys, sorted.
+func sortedMapKeys(m reflect.Value) []string {
+	keys := make([]string, 0, m.Len())
+	for _, k := range m.MapKeys() {
+		keys = append(keys, k.String())
+	}
+	slices.Sort(keys)
+	return keys
+}
@@ -0,0 +1,62 @@
+package config
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestApplySet(t *testing.T) {
+	cfg, err := ParseConfig([]byte(`
+agents:
+  coder: {tool: claude-code, model: sonnet}
+tasks:
+  review: {agent: coder, prompt: "Review", write: true}
+  test: {agent: coder, command: [go, test, ./...]}
+`), ".")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = ApplySet(cfg, []string{
+		"agents.coder.model=opus",
+		"tasks.review.write=patch",
+		"tasks.review.prompt=Review: the diff = {{vars.scope}}",
+		"tasks.review.tags=[api, web]",
+		"tasks.test.command=go vet ./...",
+		"settings.max_parallel=2",
+		"variables.scope=api",
+	})
+	if err != nil {
+		t.Fatalf("ApplySet() error = %v", err)
+	}
+	if got := cfg.Agents["coder"].Model; got != "opus" {
+		t.Errorf("model = %q, want opus", got)
+	}
+	review := cfg.Tasks["review"]
+	if !review.Write || !review.WritePatch || review.Prompt != "Review: the diff = {{vars.scope}}" || !slices.Equal(review.Tags, []string{"api", "web"}) {
+		t.Errorf("review = %+v", review)
+	}
+	if test := cfg.Tasks["test"]; test.Command != "go vet ./..." || test.CommandArgs != nil {
+		t.Errorf("test command = %q %q, want the string only", test.Command, test.CommandArgs)
+	}
+	if cfg.Settings == nil || cfg.Settings.MaxParallel != 2 || cfg.Variables["scope"] != "api" {
+		t.Errorf("settings = %+v, variables = %v", cfg.Settings, cfg.Variables)
+	}
+
+	for override, want := range map[string]string{
+		"agents.coder":                "use path=value",
+		"agents.coder.model.name=x":   "agents.coder.model is a single value",
+		"agents.coder.modle=opus":     `agents.coder has no field "modle" (did you mean "model"?)`,
+		"agents.codr.model=opus":      `agents has no "codr" (did you mean "coder"?)`,
+		"tasks.review.retries=often":  "isn't a valid number",
+		"tasks.review.tags.0=api":     "tasks.review.tags is a list",
+		"tasks.review.prompt_file=x":  "read while the Cortexfile loads",
+		"settings.parallel=sometimes": "isn't a valid boolean",
+	} {
+		err := ApplySet(cfg, []string{override})
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("ApplySet(%q) error = %v, want %q", override, err, want)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ExtensionPrefix starts keys Cortex ignores wherever they appear, so
+// files can hold their own data, like the anchors other entries alias:
+//
+//	x-defaults: &defaults {agent: sh, timeout: 5m}
+const ExtensionPrefix = "x-"
+
+// checkKnownFields returns an error for the first key in doc that t, the
+// type it decodes into, doesn't read, so a misspelled field like promt: or
+// need: fails instead of being silently ignored.
+//
+// yaml.v3's KnownFields only applies to Decoder, not to the nodes custom
+// unmarshalers like TaskConfig's decode, so keys are checked here against
+// the yaml tags, as Schema reads them.
+func checkKnownFields(doc *yaml.Node, t reflect.Type) *ConfigError {
+	return unknownField(doc, t, "")
+}
+
+// unknownField checks n, found at path (e.g. "tasks.review"), against t.
+func unknownField(n *yaml.Node, t reflect.Type, path string) *ConfigError {
+	switch n.Kind {
+	case yaml.DocumentNode:
+		if len(n.Content) == 0 {
+			return nil
+		}
+		return unknownField(n.Content[0], t, path)
+	case yaml.AliasNode:
+		return unknownField(n.Alias, t, path)
+	}
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch {
+	case t.Kind() == reflect.Struct && n.Kind == yaml.MappingNode:
+		fields := yamlFields(t)
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			key, value := n.Content[i], n.Content[i+1]
+			if strings.HasPrefix(key.Value, ExtensionPrefix) {
+				continue // Free for the user, e.g. to hold anchors
+			}
+			if key.Value == "<<" {
+				// Merge key: the merged mappings hold fields of t too
+				if err := unknownMergedField(value, t, path); err != nil {
+					return err
+				}
+				continue
+			}
+			field, ok := fields[key.Value]
+			if !ok {
+				return ErrUnknownField("", key.Line, key.Value, path).WithSuggestion(key.Value, sortedNames(fields))
+			}
+			if err := unknownField(value, field, joinPath(path, key.Value)); err != nil {
+				return err
+			}
+		}
+	case t.Kind() == reflect.Map && n.Kind == yaml.MappingNode:
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			if err := unknownField(n.Content[i+1], t.Elem(), joinPath(path, n.Content[i].Value)); err != nil {
+				return err
+			}
+		}
+	case t.Kind() == reflect.Slice && n.Kind == yaml.SequenceNode:
+		for i, item := range n.Content {
+			if err := unknownField(item, t.Elem(), fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+	}
+	// Anything else is a scalar, or a type mismatch the decoder reports
+	return nil
+}
+
+// unknownMergedField checks the value of a "<<" merge key: a mapping, an
+// alias of one, or a list of them.
+func unknownMergedField(value *yaml.Node, t reflect.Type, path string) *ConfigError {
+	if value.Kind == yaml.SequenceNode {
+		for _, item := range value.Content {
+			if err := unknownField(item, t, path); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return unknownField(value, t, path)
+}
+
+// yamlFields maps the YAML keys of struct type t to their field types.
+func yamlFields(t reflect.Type) map[string]reflect.Type {
+	fields := make(map[string]reflect.Type)
+	for i := range t.NumField() {
+		f := t.Field(i)
+		key, _, _ := strings.Cut(f.Tag.Get("yaml"), ",")
+		if key == "-" || !f.IsExported() {
+			continue
+		}
+		if key == "" {
+			key = strings.ToLower(f.Name)
+		}
+		fields[key] = f.Type
+	}
+	return fields
+}
+
+// joinPath appends key to a dotted field path.
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
@@ -1,12 +1,17 @@
 package config
 
 import (
+	"encoding/json"
 	"fmt"
+	"regexp"
+	"strconv"
 	"strings"
 )
 
 // ExpandPrompt replaces {{outputs.<task-name>}} placeholders in a prompt
-// with actual output values from completed tasks.
+// with actual output values from completed tasks. An optional reference,
+// {{outputs.<task-name> | default "text"}}, expands to its default when the
+// task has no output.
 //
 // Example:
 //
@@ -22,8 +27,14 @@ func ExpandPrompt(prompt string, outputs map[string]string) string {
 		placeholder := match[0] // Full match: {{outputs.taskname}}
 		taskName := match[1]    // Captured group: taskname
 
-		if output, exists := outputs[taskName]; exists {
+		output, exists := outputs[taskName]
+		if exists && match[2] != "" {
+			output, exists = OutputField(output, match[2][1:])
+		}
+		if exists {
 			result = strings.Replace(result, placeholder, output, -1)
+		} else if isOptional(placeholder) {
+			result = strings.Replace(result, placeholder, unquoteDefault(match[3]), -1)
 		}
 		// If output doesn't exist, leave placeholder as-is (validation should catch this)
 	}
@@ -31,6 +42,97 @@ func ExpandPrompt(prompt string, outputs map[string]string) string {
 	return result
 }
 
+// OutputField returns the value at path, dot-separated object keys and
+// array indexes, e.g. "files.0.path", in output, the JSON output of an
+// 'expect: json' task. Strings are returned as they are and other values
+// as JSON. It reports false if output isn't JSON or has no such value.
+func OutputField(output, path string) (string, bool) {
+	dec := json.NewDecoder(strings.NewReader(output))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return "", false
+	}
+	for _, key := range strings.Split(path, ".") {
+		switch node := v.(type) {
+		case map[string]any:
+			var ok bool
+			if v, ok = node[key]; !ok {
+				return "", false
+			}
+		case []any:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(node) {
+				return "", false
+			}
+			v = node[i]
+		default:
+			return "", false
+		}
+	}
+	if s, ok := v.(string); ok {
+		return s, true
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return "", false
+	}
+	return string(data), true
+}
+
+// ExpandDefaults replaces optional {{outputs.X | default "text"}} references
+// to the given tasks, such as ones that were skipped, with their defaults.
+// Required references are left for ExpandPrompt.
+func ExpandDefaults(prompt string, tasks map[string]bool) string {
+	return templateVarRegex.ReplaceAllStringFunc(prompt, func(placeholder string) string {
+		match := templateVarRegex.FindStringSubmatch(placeholder)
+		if !tasks[match[1]] || !isOptional(placeholder) {
+			return placeholder
+		}
+		return unquoteDefault(match[3])
+	})
+}
+
+// RenameOutputs rewrites {{outputs.X}} references (with or without a
+// default) to tasks in names, which maps old task names to new ones.
+func RenameOutputs(prompt string, names map[string]string) string {
+	return templateVarRegex.ReplaceAllStringFunc(prompt, func(placeholder string) string {
+		match := templateVarRegex.FindStringSubmatch(placeholder)
+		renamed, ok := names[match[1]]
+		if !ok {
+			return placeholder
+		}
+		return strings.Replace(placeholder, "outputs."+match[1], "outputs."+renamed, 1)
+	})
+}
+
+// ExtractRequiredTemplateVars returns the task names referenced in
+// {{outputs.X}} patterns that have no default.
+func ExtractRequiredTemplateVars(prompt string) []string {
+	var tasks []string
+	seen := make(map[string]bool)
+	for _, match := range templateVarRegex.FindAllStringSubmatch(prompt, -1) {
+		if !isOptional(match[0]) && !seen[match[1]] {
+			tasks = append(tasks, match[1])
+			seen[match[1]] = true
+		}
+	}
+	return tasks
+}
+
+// isOptional reports whether an {{outputs.X}} placeholder has a default.
+func isOptional(placeholder string) bool {
+	return strings.Contains(placeholder, "|")
+}
+
+// unquoteDefault decodes the escapes in a default's quoted text.
+func unquoteDefault(text string) string {
+	if s, err := strconv.Unquote(`"` + text + `"`); err == nil {
+		return s
+	}
+	return text
+}
+
 // ExtractTemplateVars returns all task names referenced in {{outputs.X}} patterns.
 func ExtractTemplateVars(prompt string) []string {
 	matches := templateVarRegex.FindAllStringSubmatch(prompt, -1)
@@ -49,9 +151,9 @@ func ExtractTemplateVars(prompt string) []string {
 }
 
 // ValidateTemplateOutputs checks that all required outputs are available.
-// Returns an error if any referenced output is missing.
+// Returns an error if any referenced output without a default is missing.
 func ValidateTemplateOutputs(prompt string, outputs map[string]string) error {
-	required := ExtractTemplateVars(prompt)
+	required := ExtractRequiredTemplateVars(prompt)
 	var missing []string
 
 	for _, taskName := range required {
@@ -65,3 +167,272 @@ func ValidateTemplateOutputs(prompt string, outputs map[string]string) error {
 	}
 	return nil
 }
+
+// Literal braces are written \{{ or {{"{{"}}. ProtectEscapes swaps them for
+// escapedBraces, which no placeholder pattern matches, before expansion and
+// validation; RestoreEscapes turns them into {{ once the prompt is final.
+const escapedBraces = "\x00{\x00"
+
+var escapeReplacer = strings.NewReplacer(`{{"{{"}}`, escapedBraces, `\{{`, escapedBraces)
+
+// ProtectEscapes hides escaped braces from placeholder expansion.
+func ProtectEscapes(s string) string {
+	return escapeReplacer.Replace(s)
+}
+
+// RestoreEscapes renders escaped braces hidden by ProtectEscapes as {{.
+func RestoreEscapes(s string) string {
+	return strings.ReplaceAll(s, escapedBraces, "{{")
+}
+
+// MemoryVar is replaced with the project memory in tasks that can read it.
+const MemoryVar = "{{memory}}"
+
+// ContextRepo names the repository summary available as {{context.repo}}.
+const ContextRepo = "repo"
+
+// contextVarRegex matches {{context.<name>}} placeholders.
+var contextVarRegex = regexp.MustCompile(`\{\{context\.([a-zA-Z0-9_-]+)\}\}`)
+
+// ExpandContext replaces {{context.<name>}} placeholders with the run's
+// shared context values. Unknown names are left as-is.
+func ExpandContext(prompt string, values map[string]string) string {
+	if len(values) == 0 {
+		return prompt
+	}
+	return expandVars(contextVarRegex, prompt, values)
+}
+
+// expandVars replaces matches of re with values keyed by the first capture
+// group, leaving placeholders without a value as-is.
+func expandVars(re *regexp.Regexp, prompt string, values map[string]string) string {
+	return re.ReplaceAllStringFunc(prompt, func(placeholder string) string {
+		if value, ok := values[re.FindStringSubmatch(placeholder)[1]]; ok {
+			return value
+		}
+		return placeholder
+	})
+}
+
+// ExtractContextVars returns the names referenced in {{context.X}} patterns.
+func ExtractContextVars(prompt string) []string {
+	var names []string
+	seen := make(map[string]bool)
+	for _, match := range contextVarRegex.FindAllStringSubmatch(prompt, -1) {
+		if !seen[match[1]] {
+			names = append(names, match[1])
+			seen[match[1]] = true
+		}
+	}
+	return names
+}
+
+// variableRegex matches {{vars.<name>}} placeholders.
+var variableRegex = regexp.MustCompile(`\{\{vars\.([a-zA-Z0-9_-]+)\}\}`)
+
+// ExpandVariables replaces {{vars.<name>}} placeholders with the
+// Cortexfile's variables. Unknown names are left as-is.
+func ExpandVariables(prompt string, values map[string]string) string {
+	if len(values) == 0 {
+		return prompt
+	}
+	return expandVars(variableRegex, prompt, values)
+}
+
+// ExtractVariables returns the names referenced in {{vars.X}} patterns.
+func ExtractVariables(prompt string) []string {
+	var names []string
+	seen := make(map[string]bool)
+	for _, match := range variableRegex.FindAllStringSubmatch(prompt, -1) {
+		if !seen[match[1]] {
+			names = append(names, match[1])
+			seen[match[1]] = true
+		}
+	}
+	return names
+}
+
+// DefaultRetrieveK is how many snippets {{retrieve}} returns without k=.
+const DefaultRetrieveK = 5
+
+// retrieveRegex matches {{retrieve "query"}} and {{retrieve "query" k=N}}.
+var retrieveRegex = regexp.MustCompile(`\{\{retrieve\s+"((?:[^"\\]|\\.)*)"(?:\s+k=(\d+))?\s*\}\}`)
+
+// RetrieveCall is one {{retrieve}} placeholder in a prompt.
+type RetrieveCall struct {
+	Placeholder string // Full placeholder text, used as its key
+	Query       string
+	K           int
+}
+
+// ExtractRetrieveCalls returns the distinct {{retrieve}} placeholders in prompt.
+func ExtractRetrieveCalls(prompt string) []RetrieveCall {
+	var calls []RetrieveCall
+	seen := make(map[string]bool)
+	for _, match := range retrieveRegex.FindAllStringSubmatch(prompt, -1) {
+		if seen[match[0]] {
+			continue
+		}
+		seen[match[0]] = true
+
+		k := DefaultRetrieveK
+		if match[2] != "" {
+			k, _ = strconv.Atoi(match[2])
+		}
+		query := strings.ReplaceAll(match[1], `\"`, `"`)
+		calls = append(calls, RetrieveCall{Placeholder: match[0], Query: query, K: k})
+	}
+	return calls
+}
+
+// ExpandRetrieve replaces {{retrieve}} placeholders with their results,
+// keyed by placeholder text. Placeholders without a result are left as-is.
+func ExpandRetrieve(prompt string, results map[string]string) string {
+	if len(results) == 0 {
+		return prompt
+	}
+	return retrieveRegex.ReplaceAllStringFunc(prompt, func(placeholder string) string {
+		if result, ok := results[placeholder]; ok {
+			return result
+		}
+		return placeholder
+	})
+}
+
+// priorOutputRegex matches {{runs.last_success.outputs.<task-name>}}.
+var priorOutputRegex = regexp.MustCompile(`\{\{runs\.last_success\.outputs\.([a-zA-Z0-9_-]+)\}\}`)
+
+// ExtractPriorOutputVars returns the task names referenced in
+// {{runs.last_success.outputs.X}} patterns.
+func ExtractPriorOutputVars(prompt string) []string {
+	var tasks []string
+	seen := make(map[string]bool)
+	for _, match := range priorOutputRegex.FindAllStringSubmatch(prompt, -1) {
+		if !seen[match[1]] {
+			tasks = append(tasks, match[1])
+			seen[match[1]] = true
+		}
+	}
+	return tasks
+}
+
+// ExpandPriorOutputs replaces {{runs.last_success.outputs.X}} placeholders
+// with outputs of the last successful run. Unknown tasks are left as-is.
+func ExpandPriorOutputs(prompt string, outputs map[string]string) string {
+	if len(outputs) == 0 {
+		return prompt
+	}
+	return expandVars(priorOutputRegex, prompt, outputs)
+}
+
+// Values available as {{diff.<name>}}, computed from the base ref.
+const (
+	DiffFiles    = "files"    // Changed files, space-separated
+	DiffPackages = "packages" // Directories containing changed files, space-separated
+	DiffBase     = "base"     // The base ref
+)
+
+// diffVarRegex matches {{diff.<name>}} placeholders.
+var diffVarRegex = regexp.MustCompile(`\{\{diff\.([a-zA-Z0-9_-]+)\}\}`)
+
+// ExpandDiff replaces {{diff.<name>}} placeholders with values describing
+// the changes since the base ref. Unknown names are left as-is.
+func ExpandDiff(prompt string, values map[string]string) string {
+	if len(values) == 0 {
+		return prompt
+	}
+	return expandVars(diffVarRegex, prompt, values)
+}
+
+// ExtractDiffVars returns the names referenced in {{diff.X}} patterns.
+func ExtractDiffVars(prompt string) []string {
+	var names []string
+	seen := make(map[string]bool)
+	for _, match := range diffVarRegex.FindAllStringSubmatch(prompt, -1) {
+		if !seen[match[1]] {
+			names = append(names, match[1])
+			seen[match[1]] = true
+		}
+	}
+	return names
+}
+
+// Execution metadata available in every prompt and command, besides
+// {{env.NAME}} for environment variables.
+const (
+	MetaGitBranch    = "git.branch"
+	MetaGitCommit    = "git.commit"
+	MetaRunID        = "run.id"
+	MetaRunStartTime = "run.start_time"
+	MetaTaskName     = "task.name"
+	MetaTaskAgent    = "task.agent"
+)
+
+// MetaSecretsPrefix starts the names of {{secrets.NAME}} placeholders, for
+// the secrets the Cortexfile defines.
+const MetaSecretsPrefix = "secrets."
+
+// MetaVars lists the metadata names other than env.NAME and secrets.NAME.
+var MetaVars = []string{MetaGitBranch, MetaGitCommit, MetaRunID, MetaRunStartTime, MetaTaskName, MetaTaskAgent}
+
+// metaVarRegex matches {{env.X}}, {{git.X}}, {{run.X}}, {{task.X}}, and
+// {{secrets.X}} patterns.
+var metaVarRegex = regexp.MustCompile(`\{\{((?:env|git|run|task|secrets)\.[a-zA-Z0-9_]+)\}\}`)
+
+// ExpandMeta replaces metadata placeholders with values, keyed by name
+// without braces (e.g. "git.branch", "env.HOME").
+func ExpandMeta(prompt string, values map[string]string) string {
+	return expandVars(metaVarRegex, prompt, values)
+}
+
+// ExtractMetaVars returns the names referenced in metadata placeholders.
+func ExtractMetaVars(prompt string) []string {
+	var names []string
+	seen := make(map[string]bool)
+	for _, match := range metaVarRegex.FindAllStringSubmatch(prompt, -1) {
+		if !seen[match[1]] {
+			names = append(names, match[1])
+			seen[match[1]] = true
+		}
+	}
+	return names
+}
+
+// placeholderRegex matches any simple {{name}} placeholder, such as
+// {{outputs.analyze}}, {{memory}}, or {{task.name}}.
+var placeholderRegex = regexp.MustCompile(`\{\{([a-zA-Z][a-zA-Z0-9_.-]*)\}\}`)
+
+// TemplateVars holds placeholder values keyed by name without braces,
+// e.g. "outputs.analyze", "context.repo", "memory", or "task.name".
+type TemplateVars map[string]string
+
+// RenderTemplate replaces each {{name}} placeholder in tpl that has a value
+// in vars, expands optional output references to their value or default,
+// and renders escaped braces as {{. Placeholders without a value are
+// left as-is; see Placeholders.
+func RenderTemplate(tpl string, vars TemplateVars) string {
+	tpl = expandVars(placeholderRegex, ProtectEscapes(tpl), vars)
+
+	// Optional output references take their value or their default
+	outputs := make(map[string]string)
+	for name, value := range vars {
+		if task, ok := strings.CutPrefix(name, "outputs."); ok {
+			outputs[task] = value
+		}
+	}
+	return RestoreEscapes(ExpandPrompt(tpl, outputs))
+}
+
+// Placeholders returns the distinct names of the {{name}} placeholders in tpl,
+// ignoring escaped ones.
+func Placeholders(tpl string) []string {
+	var names []string
+	seen := make(map[string]bool)
+	for _, match := range placeholderRegex.FindAllStringSubmatch(ProtectEscapes(tpl), -1) {
+		if !seen[match[1]] {
+			names = append(names, match[1])
+			seen[match[1]] = true
+		}
+	}
+	return names
+}
@@ -28,6 +28,14 @@ func TestExpandPrompt(t *testing.T) {
 			},
 			want: "Based on: result from task1",
 		},
+		{
+			name:   "fields of JSON output",
+			prompt: `{{outputs.plan.files}}, {{outputs.plan.files.0.path}}, {{outputs.plan.count}}, {{outputs.plan.owner | default "none"}}, {{outputs.plan.files.9}}`,
+			outputs: map[string]string{
+				"plan": `{"files": [{"path": "a.go"}], "count": 10000000000000001}`,
+			},
+			want: `[{"path":"a.go"}], a.go, 10000000000000001, none, {{outputs.plan.files.9}}`,
+		},
 		{
 			name:   "multiple template variables",
 			prompt: "Combine {{outputs.task1}} and {{outputs.task2}}",
@@ -352,6 +360,21 @@ func TestTemplateVarRegex(t *testing.T) {
 			input:       "{{output.task1}}",
 			wantMatches: nil,
 		},
+		{
+			name:  "with default",
+			input: `{{outputs.scan | default "no \"findings\""}}`,
+			wantMatches: []struct {
+				full     string
+				taskName string
+			}{
+				{`{{outputs.scan | default "no \"findings\""}}`, "scan"},
+			},
+		},
+		{
+			name:        "invalid - unknown filter",
+			input:       `{{outputs.scan | upper}}`,
+			wantMatches: nil,
+		},
 	}
 
 	for _, tt := range tests {
@@ -433,3 +456,116 @@ func TestExpandPrompt_EdgeCases(t *testing.T) {
 		})
 	}
 }
+
+func TestExpandContext(t *testing.T) {
+	values := map[string]string{"repo": "# Repository: demo"}
+	tests := []struct {
+		prompt string
+		want   string
+	}{
+		{"Overview:\n{{context.repo}}", "Overview:\n# Repository: demo"},
+		{"{{context.repo}} and {{context.repo}}", "# Repository: demo and # Repository: demo"},
+		{"Unknown {{context.other}}", "Unknown {{context.other}}"},
+		{"Output {{outputs.repo}}", "Output {{outputs.repo}}"},
+	}
+	for _, tt := range tests {
+		if got := ExpandContext(tt.prompt, values); got != tt.want {
+			t.Errorf("ExpandContext(%q) = %q, want %q", tt.prompt, got, tt.want)
+		}
+	}
+}
+
+func TestExtractRetrieveCalls(t *testing.T) {
+	prompt := `Context: {{retrieve "auth \"token\" refresh" k=3}}
+Also {{retrieve "billing"}} and {{retrieve "billing"}}.
+Not a call: {{ retrieve "spaces" }}`
+
+	calls := ExtractRetrieveCalls(prompt)
+	if len(calls) != 2 {
+		t.Fatalf("ExtractRetrieveCalls() = %+v, want 2 distinct calls", calls)
+	}
+	if calls[0].Query != `auth "token" refresh` || calls[0].K != 3 {
+		t.Errorf("calls[0] = %+v", calls[0])
+	}
+	if calls[1].Query != "billing" || calls[1].K != DefaultRetrieveK {
+		t.Errorf("calls[1] = %+v", calls[1])
+	}
+
+	got := ExpandRetrieve(prompt, map[string]string{calls[1].Placeholder: "SNIPPETS"})
+	if strings.Count(got, "SNIPPETS") != 2 || !strings.Contains(got, calls[0].Placeholder) {
+		t.Errorf("ExpandRetrieve() = %q", got)
+	}
+}
+
+func TestRenderTemplate(t *testing.T) {
+	tpl := "{{task.name}}: {{outputs.analyze}} {{memory}} {{ outputs.analyze }} {{outputs.analyze}}"
+	vars := TemplateVars{"task.name": "fix", "outputs.analyze": "A"}
+
+	want := "fix: A {{memory}} {{ outputs.analyze }} A"
+	if got := RenderTemplate(tpl, vars); got != want {
+		t.Errorf("RenderTemplate() = %q, want %q", got, want)
+	}
+
+	names := Placeholders(tpl)
+	if strings.Join(names, ",") != "task.name,outputs.analyze,memory" {
+		t.Errorf("Placeholders() = %v", names)
+	}
+}
+
+func TestEscapedBraces(t *testing.T) {
+	tests := []struct {
+		tpl  string
+		want string
+	}{
+		{`\{{outputs.analyze}}`, "{{outputs.analyze}}"},
+		{`{{"{{"}} .Values.image }}`, "{{ .Values.image }}"},
+		{`{{"{{"}}name}} = {{name}}`, "{{name}} = v"},
+		{`\{{ {{name}}`, "{{ v"},
+	}
+	for _, tt := range tests {
+		if got := RenderTemplate(tt.tpl, TemplateVars{"name": "v", "outputs.analyze": "A"}); got != tt.want {
+			t.Errorf("RenderTemplate(%q) = %q, want %q", tt.tpl, got, tt.want)
+		}
+	}
+
+	prompt := ProtectEscapes(`\{{outputs.a}} {{outputs.b}}`)
+	if got := ExtractTemplateVars(prompt); len(got) != 1 || got[0] != "b" {
+		t.Errorf("ExtractTemplateVars() = %v, want [b]", got)
+	}
+	if got := RestoreEscapes(ExpandPrompt(prompt, map[string]string{"a": "A", "b": "B"})); got != "{{outputs.a}} B" {
+		t.Errorf("expanded = %q", got)
+	}
+	if got := Placeholders(`\{{memory}}`); len(got) != 0 {
+		t.Errorf("Placeholders() = %v, want none", got)
+	}
+}
+
+func TestOptionalOutputs(t *testing.T) {
+	prompt := `Scan: {{outputs.scan | default "no findings"}}; lint: {{outputs.lint|default "a\nb"}}; {{outputs.build}}`
+
+	if got := ExtractTemplateVars(prompt); strings.Join(got, ",") != "scan,lint,build" {
+		t.Errorf("ExtractTemplateVars() = %v", got)
+	}
+	if got := ExtractRequiredTemplateVars(prompt); strings.Join(got, ",") != "build" {
+		t.Errorf("ExtractRequiredTemplateVars() = %v", got)
+	}
+	if err := ValidateTemplateOutputs(prompt, map[string]string{"build": "ok"}); err != nil {
+		t.Errorf("ValidateTemplateOutputs() error = %v, want nil for missing optional outputs", err)
+	}
+
+	got := ExpandPrompt(prompt, map[string]string{"scan": "2 issues", "build": "ok"})
+	if want := "Scan: 2 issues; lint: a\nb; ok"; got != want {
+		t.Errorf("ExpandPrompt() = %q, want %q", got, want)
+	}
+
+	// A skipped task's output is replaced by the default, whatever it holds
+	skipped := ExpandDefaults(prompt, map[string]bool{"scan": true, "build": true})
+	got = ExpandPrompt(skipped, map[string]string{"scan": "skipped: no changes", "build": "skipped"})
+	if want := "Scan: no findings; lint: a\nb; skipped"; got != want {
+		t.Errorf("ExpandPrompt(ExpandDefaults()) = %q, want %q", got, want)
+	}
+
+	if got := RenderTemplate(prompt, TemplateVars{"outputs.lint": "clean"}); got != "Scan: no findings; lint: clean; {{outputs.build}}" {
+		t.Errorf("RenderTemplate() = %q", got)
+	}
+}
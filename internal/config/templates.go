@@ -10,6 +10,15 @@ const CortexfileTemplate = `# Cortexfile.yml - Cortex Workflow Configuration
 # Set working directory for all agents. Can be absolute or relative path.
 # workdir: /path/to/project
 
+# ============================================================================
+# PREAMBLE (Optional)
+# ============================================================================
+# Text prepended to every AI task's prompt, e.g. repo conventions.
+# Supports {{task.name}}, {{task.agent}}, and {{run.id}}.
+# preamble: |
+#   Follow the conventions in CONTRIBUTING.md. Do not touch /vendor.
+# preamble_file: prompts/preamble.md
+
 # ============================================================================
 # AGENTS
 # ============================================================================
@@ -62,9 +71,27 @@ agents:
 #   - command    : (shell agents) Shell command to execute
 #   - needs      : Dependencies - single task or array of tasks
 #   - write      : Allow file writes (default: false)
+#   - tags       : Labels; tasks tagged with an 'interchangeable' group may run on any agent in it
+#   - verify     : (write tasks) Command run after the agent, e.g. "go test ./..."
+#   - fix_attempts: Re-run the agent with verify output when it fails (default: 0)
+#   - retries    : Re-run the agent when the task fails (default: 0); retry_backoff sets the
+#                  first pause (default: 10s, doubling), retry_on limits it to exit codes or patterns
+#   - max_changed_files / max_changed_lines: Revert and fail write tasks that change too much
+#   - on_context_overflow: truncate (default), summarize, or fail when the prompt is too long
+#   - max_tokens: Stop the agent once it uses this many input + output tokens
+#   - memory     : read or write - use the project memory ({{memory}}) kept across runs;
+#                  write also appends the task's output to it
+#   - scope      : diff - work only on files changed since settings.base (--base);
+#                  skipped when nothing changed. {{diff.files}} / {{diff.packages}} list them
+#   - paths      : Globs like ["api/**"]; skip the task unless a changed file matches
+#   - commit     : (write tasks) {message_template, pr} - commit changes on the run branch, open a PR
 #
 # Template variables:
 #   Use {{outputs.task_name}} to reference output from a dependency task
+#   Use {{runs.last_success.outputs.task_name}} for a task's output in the last successful run
+#   Use {{context.repo}} for a summary of the repository (generated once per run)
+#   Use {{retrieve "query" k=5}} for the repository snippets most relevant to a query
+#   (see the top-level 'retrieval' section to choose an embedding provider)
 
 tasks:
   # -------------------------------------------------------------------------
@@ -159,6 +186,18 @@ settings:
 
   # Stream real-time output from agents (default: true)
   stream: true
+
+  # Uncommitted changes before write tasks: refuse, stash, or proceed (default: proceed)
+  # dirty_tree: stash
+
+  # Snapshot the workdir before write tasks; undo with 'cortex rollback <run-id>'
+  # snapshot: true
+
+  # Seconds before a running task reports progress, and between reports (default: 30, -1 = off)
+  # heartbeat: 60
+
+  # Token budget for a whole run; running tasks are stopped once it is exceeded (default: no limit)
+  # max_tokens: 1000000
 `
 
 // MasterCortexTemplate is the default template for a new MasterCortex.yml
@@ -313,7 +352,8 @@ settings:
 #   - run_complete : When a workflow run completes
 #   - task_start   : When a task starts
 #   - task_complete: When a task completes
-#   - *            : All events
+#   - task_progress: Periodically while a long task runs (only when listed explicitly)
+#   - *            : All events except task_progress
 
 # webhooks:
 #   # Slack notification
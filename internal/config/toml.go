@@ -0,0 +1,636 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"gopkg.in/yaml.v3"
+)
+
+// parseTOML parses a TOML document into the node tree yaml.v3 would build
+// for the equivalent YAML, so TOML Cortexfiles share the YAML checks and
+// decoding. It covers TOML 1.0: tables, arrays of tables, dotted and
+// quoted keys, inline tables, the four kinds of strings, and numbers.
+// Dates and times are kept as strings.
+func parseTOML(data []byte) (*yaml.Node, error) {
+	if !utf8.Valid(data) {
+		return nil, fmt.Errorf("TOML files must be UTF-8")
+	}
+	p := &tomlParser{
+		src:     strings.TrimPrefix(string(data), "\uFEFF"),
+		defined: make(map[*yaml.Node]bool),
+		closed:  make(map[*yaml.Node]bool),
+		arrays:  make(map[*yaml.Node]bool),
+	}
+	for i, c := range p.src {
+		if c == '\n' {
+			p.newlines = append(p.newlines, i)
+		}
+	}
+	root := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map", Line: 1, Column: 1}
+	if err := p.parse(root); err != nil {
+		return nil, fmt.Errorf("line %d: %w", p.line(), err)
+	}
+	return &yaml.Node{Kind: yaml.DocumentNode, Line: 1, Column: 1, Content: []*yaml.Node{root}}, nil
+}
+
+// tomlParser reads a TOML document from src.
+type tomlParser struct {
+	src      string
+	pos      int
+	newlines []int // Offsets of the newlines in src, for line numbers
+
+	defined map[*yaml.Node]bool // Tables declared by a [header]
+	closed  map[*yaml.Node]bool // Inline tables and arrays, which can't be extended
+	arrays  map[*yaml.Node]bool // Arrays of tables declared by [[headers]]
+}
+
+// line is the 1-based line of the current position.
+func (p *tomlParser) line() int {
+	return sort.SearchInts(p.newlines, p.pos) + 1
+}
+
+// peek returns the byte at the current position, or 0 at the end.
+func (p *tomlParser) peek() byte {
+	if p.pos < len(p.src) {
+		return p.src[p.pos]
+	}
+	return 0
+}
+
+// skipSpace skips spaces and tabs.
+func (p *tomlParser) skipSpace() {
+	for p.peek() == ' ' || p.peek() == '\t' {
+		p.pos++
+	}
+}
+
+// skipBlank skips whitespace, newlines, and comments, as found between the
+// values of an array.
+func (p *tomlParser) skipBlank() {
+	for {
+		p.skipSpace()
+		switch {
+		case p.peek() == '#':
+			p.skipComment()
+		case p.peek() == '\n':
+			p.pos++
+		case strings.HasPrefix(p.src[p.pos:], "\r\n"):
+			p.pos += 2
+		default:
+			return
+		}
+	}
+}
+
+// skipComment skips a comment up to the end of its line.
+func (p *tomlParser) skipComment() {
+	if i := strings.IndexByte(p.src[p.pos:], '\n'); i >= 0 {
+		p.pos += i
+	} else {
+		p.pos = len(p.src)
+	}
+}
+
+// endLine expects the rest of the line to be blank or a comment.
+func (p *tomlParser) endLine() error {
+	p.skipSpace()
+	if p.peek() == '#' {
+		p.skipComment()
+	}
+	switch {
+	case p.pos == len(p.src):
+	case p.peek() == '\n':
+		p.pos++
+	case strings.HasPrefix(p.src[p.pos:], "\r\n"):
+		p.pos += 2
+	default:
+		return fmt.Errorf("unexpected %q; expected the end of the line", p.rest())
+	}
+	return nil
+}
+
+// rest returns the text from the current position to the end of its line,
+// for errors.
+func (p *tomlParser) rest() string {
+	rest := p.src[p.pos:]
+	if i := strings.IndexAny(rest, "\r\n"); i >= 0 {
+		rest = rest[:i]
+	}
+	return rest
+}
+
+// parse reads the document into root.
+func (p *tomlParser) parse(root *yaml.Node) error {
+	table := root
+	for {
+		p.skipBlank()
+		if p.pos == len(p.src) {
+			return nil
+		}
+		var err error
+		if p.peek() == '[' {
+			table, err = p.parseHeader(root)
+		} else {
+			err = p.parseKeyValue(table)
+		}
+		if err != nil {
+			return err
+		}
+		if err := p.endLine(); err != nil {
+			return err
+		}
+	}
+}
+
+// parseHeader reads a [table] or [[array of tables]] header, returning the
+// table the lines below it fill.
+func (p *tomlParser) parseHeader(root *yaml.Node) (*yaml.Node, error) {
+	line := p.line()
+	array := strings.HasPrefix(p.src[p.pos:], "[[")
+	if array {
+		p.pos += 2
+	} else {
+		p.pos++
+	}
+	p.skipSpace()
+	keys, err := p.parseKey()
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpace()
+	closing := "]"
+	if array {
+		closing = "]]"
+	}
+	if !strings.HasPrefix(p.src[p.pos:], closing) {
+		return nil, fmt.Errorf("unexpected %q; expected %s to end the table header", p.rest(), closing)
+	}
+	p.pos += len(closing)
+
+	parent := root
+	for _, key := range keys[:len(keys)-1] {
+		if parent, err = p.descend(parent, key, line); err != nil {
+			return nil, err
+		}
+	}
+	last := keys[len(keys)-1]
+	value := mappingValue(parent, last)
+
+	if array {
+		switch {
+		case value == nil:
+			value = &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq", Line: line}
+			p.arrays[value] = true
+			addPair(parent, last, value, line)
+		case !p.arrays[value]:
+			return nil, fmt.Errorf("%s is already defined and isn't an array of tables", strings.Join(keys, "."))
+		}
+		table := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map", Line: line}
+		value.Content = append(value.Content, table)
+		return table, nil
+	}
+
+	switch {
+	case value == nil:
+		value = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map", Line: line}
+		addPair(parent, last, value, line)
+	case value.Kind != yaml.MappingNode || p.closed[value]:
+		return nil, fmt.Errorf("%s is already defined and isn't a table", strings.Join(keys, "."))
+	case p.defined[value]:
+		return nil, fmt.Errorf("table [%s] is defined twice", strings.Join(keys, "."))
+	}
+	p.defined[value] = true
+	return value, nil
+}
+
+// descend returns the table key names in parent, creating it if missing,
+// or the last table of an array of tables.
+func (p *tomlParser) descend(parent *yaml.Node, key string, line int) (*yaml.Node, error) {
+	value := mappingValue(parent, key)
+	switch {
+	case value == nil:
+		value = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map", Line: line}
+		addPair(parent, key, value, line)
+	case p.arrays[value]:
+		value = value.Content[len(value.Content)-1]
+	case value.Kind != yaml.MappingNode || p.closed[value]:
+		return nil, fmt.Errorf("%s is already defined and isn't a table", key)
+	}
+	return value, nil
+}
+
+// parseKeyValue reads a "key = value" line into table.
+func (p *tomlParser) parseKeyValue(table *yaml.Node) error {
+	line := p.line()
+	keys, err := p.parseKey()
+	if err != nil {
+		return err
+	}
+	p.skipSpace()
+	if p.peek() != '=' {
+		return fmt.Errorf("unexpected %q; expected = after the key", p.rest())
+	}
+	p.pos++
+	p.skipSpace()
+	value, err := p.parseValue()
+	if err != nil {
+		return err
+	}
+
+	for _, key := range keys[:len(keys)-1] {
+		if table, err = p.descend(table, key, line); err != nil {
+			return err
+		}
+	}
+	last := keys[len(keys)-1]
+	if mappingValue(table, last) != nil {
+		return fmt.Errorf("%s is defined twice", strings.Join(keys, "."))
+	}
+	addPair(table, last, value, line)
+	return nil
+}
+
+// parseKey reads a key of one or more dot-separated parts, each bare,
+// "quoted", or 'literal'.
+func (p *tomlParser) parseKey() ([]string, error) {
+	var keys []string
+	for {
+		var key string
+		switch c := p.peek(); {
+		case c == '"':
+			s, err := p.parseBasicString()
+			if err != nil {
+				return nil, err
+			}
+			key = s
+		case c == '\'':
+			s, err := p.parseLiteralString()
+			if err != nil {
+				return nil, err
+			}
+			key = s
+		default:
+			start := p.pos
+			for isBareKeyChar(p.peek()) {
+				p.pos++
+			}
+			if p.pos == start {
+				return nil, fmt.Errorf("unexpected %q; expected a key", p.rest())
+			}
+			key = p.src[start:p.pos]
+		}
+		keys = append(keys, key)
+
+		p.skipSpace()
+		if p.peek() != '.' {
+			return keys, nil
+		}
+		p.pos++
+		p.skipSpace()
+	}
+}
+
+// isBareKeyChar reports whether c may appear in a bare key.
+func isBareKeyChar(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_' || c == '-'
+}
+
+// parseValue reads a value: a string, number, boolean, date, array, or
+// inline table.
+func (p *tomlParser) parseValue() (*yaml.Node, error) {
+	line, column := p.line(), p.pos+1
+	if line > 1 {
+		column = p.pos - p.newlines[line-2]
+	}
+	scalar := func(tag, value string) *yaml.Node {
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: tag, Value: value, Line: line, Column: column}
+	}
+
+	switch c := p.peek(); c {
+	case '"':
+		var s string
+		var err error
+		if strings.HasPrefix(p.src[p.pos:], `"""`) {
+			s, err = p.parseMultilineString(`"""`)
+		} else {
+			s, err = p.parseBasicString()
+		}
+		return scalar("!!str", s), err
+	case '\'':
+		var s string
+		var err error
+		if strings.HasPrefix(p.src[p.pos:], "'''") {
+			s, err = p.parseMultilineString("'''")
+		} else {
+			s, err = p.parseLiteralString()
+		}
+		return scalar("!!str", s), err
+	case '[':
+		return p.parseArray(line, column)
+	case '{':
+		return p.parseInlineTable(line, column)
+	}
+
+	start := p.pos
+	for p.pos < len(p.src) && strings.IndexByte("0123456789abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ_+-.:", p.peek()) >= 0 {
+		p.pos++
+		// A space may separate a date from its time
+		if p.pos-start == 10 && p.peek() == ' ' && p.pos+1 < len(p.src) && isDigit(p.src[p.pos+1]) && isDate(p.src[start:p.pos]) {
+			p.pos++
+		}
+	}
+	token := p.src[start:p.pos]
+	switch {
+	case token == "true" || token == "false":
+		return scalar("!!bool", token), nil
+	case token == "":
+		return nil, fmt.Errorf("unexpected %q; expected a value", p.rest())
+	case isDate(token) || len(token) >= 8 && token[2] == ':' && token[5] == ':':
+		return scalar("!!str", token), nil // Dates and times
+	}
+	if n, ok := parseTOMLInt(token); ok {
+		return scalar("!!int", n), nil
+	}
+	if f, ok := parseTOMLFloat(token); ok {
+		return scalar("!!float", f), nil
+	}
+	p.pos = start
+	return nil, fmt.Errorf("invalid value %q (strings must be quoted)", token)
+}
+
+// isDigit reports whether c is a decimal digit.
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// isDate reports whether s starts with a date, as in 1979-05-27.
+func isDate(s string) bool {
+	return len(s) >= 10 && isDigit(s[0]) && isDigit(s[3]) && s[4] == '-' && s[7] == '-' && isDigit(s[9])
+}
+
+// parseTOMLInt parses a TOML integer (decimal with optional underscores,
+// or 0x, 0o, or 0b), returning it in decimal.
+func parseTOMLInt(token string) (string, bool) {
+	if strings.HasPrefix(token, "_") || strings.HasSuffix(token, "_") || strings.Contains(token, "__") {
+		return "", false
+	}
+	s := strings.ReplaceAll(token, "_", "")
+	base := 10
+	if len(s) > 2 && s[0] == '0' {
+		switch s[1] {
+		case 'x':
+			base = 16
+		case 'o':
+			base = 8
+		case 'b':
+			base = 2
+		}
+		if base != 10 {
+			s = s[2:]
+		}
+	}
+	if base == 10 {
+		digits := strings.TrimLeft(s, "+-")
+		if len(digits) > 1 && digits[0] == '0' {
+			return "", false // Leading zeros aren't allowed
+		}
+	} else if strings.HasPrefix(s, "+") || strings.HasPrefix(s, "-") {
+		return "", false
+	}
+	n, err := strconv.ParseInt(s, base, 64)
+	if err != nil {
+		return "", false
+	}
+	return strconv.FormatInt(n, 10), true
+}
+
+// parseTOMLFloat parses a TOML float, returning it as YAML writes it.
+func parseTOMLFloat(token string) (string, bool) {
+	switch strings.TrimLeft(token, "+") {
+	case "inf":
+		return ".inf", true
+	case "-inf":
+		return "-.inf", true
+	case "nan", "-nan":
+		return ".nan", true
+	}
+	if strings.HasPrefix(token, "_") || strings.HasSuffix(token, "_") || strings.Contains(token, "__") {
+		return "", false
+	}
+	s := strings.ReplaceAll(token, "_", "")
+	if !strings.ContainsAny(s, ".eE") || strings.HasPrefix(strings.TrimLeft(s, "+-"), ".") || strings.HasSuffix(s, ".") {
+		return "", false
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return "", false
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64), true
+}
+
+// parseArray reads an array, whose values may span lines.
+func (p *tomlParser) parseArray(line, column int) (*yaml.Node, error) {
+	array := &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq", Style: yaml.FlowStyle, Line: line, Column: column}
+	p.closed[array] = true
+	p.pos++ // [
+	for {
+		p.skipBlank()
+		if p.peek() == ']' {
+			p.pos++
+			return array, nil
+		}
+		value, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		array.Content = append(array.Content, value)
+		p.skipBlank()
+		switch p.peek() {
+		case ',':
+			p.pos++
+		case ']':
+		default:
+			return nil, fmt.Errorf("unexpected %q; expected , or ] in the array", p.rest())
+		}
+	}
+}
+
+// parseInlineTable reads an inline table, as in {tool = "shell"}.
+func (p *tomlParser) parseInlineTable(line, column int) (*yaml.Node, error) {
+	table := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map", Style: yaml.FlowStyle, Line: line, Column: column}
+	p.pos++ // {
+	p.skipSpace()
+	if p.peek() == '}' {
+		p.pos++
+		p.closed[table] = true
+		return table, nil
+	}
+	for {
+		p.skipSpace()
+		if err := p.parseKeyValue(table); err != nil {
+			return nil, err
+		}
+		p.skipSpace()
+		switch p.peek() {
+		case ',':
+			p.pos++
+		case '}':
+			p.pos++
+			p.closeAll(table)
+			return table, nil
+		default:
+			return nil, fmt.Errorf("unexpected %q; expected , or } in the inline table", p.rest())
+		}
+	}
+}
+
+// closeAll marks an inline table and the tables its dotted keys created
+// as closed.
+func (p *tomlParser) closeAll(table *yaml.Node) {
+	p.closed[table] = true
+	for i := 1; i < len(table.Content); i += 2 {
+		if v := table.Content[i]; v.Kind == yaml.MappingNode {
+			p.closeAll(v)
+		}
+	}
+}
+
+// parseBasicString reads a "string" with escapes.
+func (p *tomlParser) parseBasicString() (string, error) {
+	p.pos++ // "
+	var b strings.Builder
+	for {
+		if p.pos == len(p.src) {
+			return "", fmt.Errorf("unterminated string")
+		}
+		switch c := p.peek(); c {
+		case '\n', '\r':
+			return "", fmt.Errorf("unterminated string")
+		case '"':
+			p.pos++
+			return b.String(), nil
+		case '\\':
+			if err := p.parseEscape(&b); err != nil {
+				return "", err
+			}
+		default:
+			b.WriteByte(c)
+			p.pos++
+		}
+	}
+}
+
+// parseEscape reads a backslash escape into b.
+func (p *tomlParser) parseEscape(b *strings.Builder) error {
+	p.pos++ // \
+	c := p.peek()
+	p.pos++
+	switch c {
+	case 'b':
+		b.WriteByte('\b')
+	case 't':
+		b.WriteByte('\t')
+	case 'n':
+		b.WriteByte('\n')
+	case 'f':
+		b.WriteByte('\f')
+	case 'r':
+		b.WriteByte('\r')
+	case 'e':
+		b.WriteByte('\x1b')
+	case '"', '\\':
+		b.WriteByte(c)
+	case 'u', 'U':
+		n := 4
+		if c == 'U' {
+			n = 8
+		}
+		if p.pos+n > len(p.src) {
+			return fmt.Errorf("incomplete \\%c escape", c)
+		}
+		r, err := strconv.ParseUint(p.src[p.pos:p.pos+n], 16, 32)
+		if err != nil || !utf8.ValidRune(rune(r)) {
+			return fmt.Errorf("invalid escape \\%c%s", c, p.src[p.pos:p.pos+n])
+		}
+		b.WriteRune(rune(r))
+		p.pos += n
+	default:
+		return fmt.Errorf("invalid escape \\%c", c)
+	}
+	return nil
+}
+
+// parseLiteralString reads a 'string' without escapes.
+func (p *tomlParser) parseLiteralString() (string, error) {
+	p.pos++ // '
+	end := strings.IndexAny(p.src[p.pos:], "'\n")
+	if end < 0 || p.src[p.pos+end] != '\'' {
+		return "", fmt.Errorf("unterminated string")
+	}
+	s := p.src[p.pos : p.pos+end]
+	p.pos += end + 1
+	return s, nil
+}
+
+// parseMultilineString reads a multi-line string opened by delim: three
+// double quotes, with escapes, or three single quotes, without. A newline
+// right after the opening quotes isn't part of the string, and with
+// escapes a backslash at the end of a line joins it with the next
+// non-blank text.
+func (p *tomlParser) parseMultilineString(delim string) (string, error) {
+	p.pos += 3
+	if strings.HasPrefix(p.src[p.pos:], "\r\n") {
+		p.pos += 2
+	} else if p.peek() == '\n' {
+		p.pos++
+	}
+	var b strings.Builder
+	for {
+		if p.pos == len(p.src) {
+			return "", fmt.Errorf("unterminated multi-line string")
+		}
+		if strings.HasPrefix(p.src[p.pos:], delim) {
+			// Up to two quotes may come right before the closing ones
+			n := 3
+			for n < 5 && p.pos+n < len(p.src) && p.src[p.pos+n] == delim[0] {
+				n++
+			}
+			b.WriteString(p.src[p.pos : p.pos+n-3])
+			p.pos += n
+			return b.String(), nil
+		}
+		c := p.peek()
+		if c == '\\' && delim == `"""` {
+			rest := strings.TrimLeft(p.src[p.pos+1:], " \t")
+			if strings.HasPrefix(rest, "\n") || strings.HasPrefix(rest, "\r\n") {
+				p.pos = len(p.src) - len(strings.TrimLeft(rest, " \t\r\n"))
+				continue
+			}
+			if err := p.parseEscape(&b); err != nil {
+				return "", err
+			}
+			continue
+		}
+		b.WriteByte(c)
+		p.pos++
+	}
+}
+
+// mappingValue returns the value of key in a mapping node, or nil.
+func mappingValue(mapping *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			return mapping.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// addPair adds key and value to a mapping node.
+func addPair(mapping *yaml.Node, key string, value *yaml.Node, line int) {
+	mapping.Content = append(mapping.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key, Line: line, Column: 1}, value)
+}
@@ -1,7 +1,16 @@
 package config
 
 import (
+	"os"
+	"path/filepath"
 	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/adityaraj/agentflow/internal/glob"
+	"github.com/adityaraj/agentflow/internal/jsonschema"
 )
 
 // ValidateWithFile checks the configuration for errors, including file path info.
@@ -27,26 +36,128 @@ func ValidateWithFile(config *AgentflowConfig, filePath string) error {
 		availableTasks = append(availableTasks, name)
 	}
 
+	// Names end up in file names, placeholders, and graph node IDs
+	for _, name := range sortedNames(config.Agents) {
+		if !IsValidName(name) {
+			errs.Add(ErrInvalidName(config.SourceFile("agents", name, filePath), 0, "agent", name))
+		}
+	}
+	for _, name := range sortedNames(config.Tasks) {
+		if !IsValidName(name) {
+			errs.Add(ErrInvalidName(config.SourceFile("tasks", name, filePath), 0, "task", name))
+		}
+	}
+
 	// Validate agents
+	for _, name := range sortedNames(config.Agents) {
+		agent := config.Agents[name]
+		file := config.SourceFile("agents", name, filePath)
+		if agent.Extends == "" {
+			continue
+		}
+		if _, exists := config.Agents[agent.Extends]; !exists {
+			errs.Add(NewCodedError(CodeUndefinedAgent, file, 0,
+				"agent \""+name+"\" extends undefined agent \""+agent.Extends+"\"",
+				undefinedAgentHint(availableAgents)).WithSuggestion(agent.Extends, availableAgents))
+		} else if cycle := extendsCycle(config.Agents, name); cycle != nil && name == slices.Min(cycle) {
+			errs.Add(NewCodedError(CodeExtendsCycle, file, 0,
+				"agents extend each other in a cycle: "+formatChain(cycle),
+				"Make one of them set its own fields instead of extending"))
+		}
+	}
 	for name, agent := range config.Agents {
+		file := config.SourceFile("agents", name, filePath)
 		if agent.Tool == "" {
-			errs.Add(NewConfigErrorWithHint(filePath, 0,
+			errs.Add(NewCodedError(CodeMissingTool, file, 0,
 				"agent \""+name+"\": tool is required",
 				"Add 'tool: claude-code', 'tool: opencode', or 'tool: shell'"))
 		} else if !IsSupportedTool(agent.Tool) {
-			errs.Add(ErrUnsupportedTool(filePath, 0, name, agent.Tool))
+			errs.Add(ErrUnsupportedTool(file, 0, name, agent.Tool))
+		}
+	}
+
+	for name, agent := range config.Agents {
+		file := config.SourceFile("agents", name, filePath)
+		if len(agent.Unresolved) > 0 {
+			errs.Add(ErrUnresolvedVariables(file, "agent \""+name+"\" model", agent.Unresolved))
+		}
+		if err := validateModel(file, name, agent); err != nil {
+			errs.Add(err)
+		}
+		if agent.MaxConcurrent < 0 {
+			errs.Add(NewCodedError(CodeNegativeValue, file, 0,
+				"agent \""+name+"\": 'max_concurrent' cannot be negative",
+				"Use 0 for no limit"))
+		}
+		if agent.Tool == "shell" && (agent.SystemPrompt != "" || agent.Instructions != "") {
+			errs.Add(NewCodedError(CodeConflictingFields, file, 0,
+				"agent \""+name+"\": shell agents take no 'system_prompt' or 'instructions'",
+				"Give them to the AI agents whose tasks need them"))
+		}
+	}
+
+	if len(config.Unresolved) > 0 {
+		errs.Add(ErrUnresolvedVariables(filePath, "workdir", config.Unresolved))
+	}
+
+	secretNames := sortedNames(config.Secrets)
+	for _, name := range secretNames {
+		if err := validateSecret(filePath, name, config.Secrets[name]); err != nil {
+			errs.Add(err)
+		}
+	}
+	variableNames := sortedNames(config.Variables)
+	for _, name := range variableNames {
+		if !variableNameRegex.MatchString(name) {
+			errs.Add(NewCodedError(CodeInvalidValue, filePath, 0,
+				"variable \""+name+"\": name can't be used as {{vars."+name+"}}",
+				"Use letters, digits, '_', and '-'"))
+		}
+	}
+
+	// Validate interchangeable agent groups
+	for tag, group := range config.Interchangeable {
+		if len(group) < 2 {
+			errs.Add(NewCodedError(CodeInvalidInterchangeable, filePath, 0,
+				"interchangeable \""+tag+"\": needs at least two agents",
+				"List the agents that can run tasks tagged '"+tag+"'"))
+		}
+		for _, agentName := range group {
+			agent, exists := config.Agents[agentName]
+			if !exists {
+				errs.Add(NewCodedError(CodeUndefinedAgent, filePath, 0,
+					"interchangeable \""+tag+"\": undefined agent \""+agentName+"\"",
+					undefinedAgentHint(availableAgents)).WithSuggestion(agentName, availableAgents))
+			} else if agent.Tool == "shell" {
+				errs.Add(NewCodedError(CodeInvalidInterchangeable, filePath, 0,
+					"interchangeable \""+tag+"\": shell agent \""+agentName+"\" cannot stand in for AI agents",
+					"Only group agents that take prompts"))
+			}
 		}
 	}
 
 	// Validate tasks
 	for name, task := range config.Tasks {
-		// Check agent reference
-		if task.Agent == "" {
-			errs.Add(NewConfigErrorWithHint(filePath, 0,
+		file := config.SourceFile("tasks", name, filePath)
+		// Escaped braces are literal text, not placeholders
+		task.Prompt = ProtectEscapes(task.Prompt)
+		task.Command = ProtectEscapes(task.Command)
+		args := make([]string, len(task.CommandArgs))
+		for i, arg := range task.CommandArgs {
+			args[i] = ProtectEscapes(arg)
+		}
+
+		if len(task.Unresolved) > 0 {
+			errs.Add(ErrUnresolvedVariables(file, "task \""+name+"\" prompt", task.Unresolved))
+		}
+
+		// Check agent reference; compress steps only take one to summarize
+		if task.Agent == "" && task.Compress == nil {
+			errs.Add(NewCodedError(CodeMissingAgent, file, 0,
 				"task \""+name+"\": agent is required",
 				"Add 'agent: <agent_name>' to specify which agent runs this task"))
-		} else if _, exists := config.Agents[task.Agent]; !exists {
-			errs.Add(ErrUndefinedAgent(filePath, 0, name, task.Agent, availableAgents))
+		} else if _, exists := config.Agents[task.Agent]; !exists && task.Agent != "" {
+			errs.Add(ErrUndefinedAgent(file, 0, name, task.Agent, availableAgents))
 		}
 
 		// Get agent tool type to determine validation rules
@@ -55,55 +166,399 @@ func ValidateWithFile(config *AgentflowConfig, filePath string) error {
 			agentTool = agent.Tool
 		}
 
-		// Check prompt/command based on agent type
-		hasPrompt := task.Prompt != ""
+		// Check prompt/command based on agent type. Prompts loaded from
+		// prompt_file (those with a PromptPath) aren't inline ones.
+		hasPrompt := task.Prompt != "" && task.PromptPath == ""
 		hasPromptFile := task.PromptFile != ""
-		hasCommand := task.Command != ""
+		hasCommand := task.HasCommand()
+		if task.PromptPath != "" && task.Prompt == "" {
+			if _, err := os.Stat(task.PromptPath); err != nil {
+				errs.Add(ErrPromptFileNotFound(file, 0, name, task.PromptPath))
+			}
+		}
 
-		if agentTool == "shell" {
+		if task.Compress != nil {
+			for _, e := range validateCompress(file, name, task, agentTool) {
+				errs.Add(e)
+			}
+			// Its input is checked like a prompt below
+			task.Prompt = ProtectEscapes(task.Compress.Input)
+		} else if agentTool == "shell" {
 			// Shell agents require 'command' field
 			if !hasCommand {
-				errs.Add(NewConfigErrorWithHint(filePath, 0,
+				errs.Add(NewCodedError(CodeMissingCommand, file, 0,
 					"task \""+name+"\": shell agent requires 'command' field",
 					"Add 'command: <shell_command>' to specify the command to run"))
 			}
 			if hasPrompt || hasPromptFile {
-				errs.Add(NewConfigErrorWithHint(filePath, 0,
+				errs.Add(NewCodedError(CodeConflictingFields, file, 0,
 					"task \""+name+"\": shell agent should use 'command', not 'prompt' or 'prompt_file'",
 					"Replace 'prompt' or 'prompt_file' with 'command: <shell_command>'"))
 			}
 		} else {
 			// AI agents require prompt or prompt_file
 			if !hasPrompt && !hasPromptFile {
-				errs.Add(ErrNoPrompt(filePath, 0, name))
+				errs.Add(ErrNoPrompt(file, 0, name))
 			}
 			if hasPrompt && hasPromptFile {
-				errs.Add(NewConfigErrorWithHint(filePath, 0,
+				errs.Add(NewCodedError(CodeConflictingFields, file, 0,
 					"task \""+name+"\": cannot have both 'prompt' and 'prompt_file'",
 					"Use either inline 'prompt:' or external 'prompt_file:', not both"))
 			}
 			if hasCommand {
-				errs.Add(NewConfigErrorWithHint(filePath, 0,
+				errs.Add(NewCodedError(CodeConflictingFields, file, 0,
 					"task \""+name+"\": 'command' field is only for shell agents",
 					"Use 'prompt' or 'prompt_file' for AI agents, or change agent tool to 'shell'"))
 			}
 		}
 
+		// Check argument lists and the shell
+		if task.CommandArgs != nil && len(task.CommandArgs) == 0 {
+			errs.Add(NewCodedError(CodeMissingCommand, file, 0,
+				"task \""+name+"\": 'command' list is empty",
+				"List the program and its arguments, e.g. [go, test, ./...]"))
+		}
+		if task.VerifyArgs != nil && len(task.VerifyArgs) == 0 {
+			errs.Add(NewCodedError(CodeMissingVerify, file, 0,
+				"task \""+name+"\": 'verify' list is empty",
+				"List the program and its arguments, e.g. [go, test, ./...]"))
+		}
+		for _, arg := range args {
+			for _, v := range Placeholders(arg) {
+				if !strings.HasPrefix(v, "outputs.") && !strings.HasPrefix(v, "vars.") && !slices.Contains(ExtractMetaVars(arg), v) {
+					errs.Add(NewCodedError(CodeInvalidTemplate, file, 0,
+						"task \""+name+"\": 'command' list cannot use {{"+v+"}}",
+						"Arguments may use {{outputs.X}}, {{vars.X}}, {{task.X}}, {{run.X}}, {{git.X}}, {{env.X}}, and {{secrets.X}}"))
+				}
+			}
+		}
+		if task.Shell != "" && task.Command == "" && task.Verify == "" {
+			errs.Add(NewCodedError(CodeMissingCommand, file, 0,
+				"task \""+name+"\": 'shell' has no command to run",
+				"Argument lists run without a shell; write 'command' or 'verify' as a string to use one"))
+		}
+		if task.VerifyWorkdir != "" && !task.HasVerify() {
+			errs.Add(NewCodedError(CodeMissingVerify, file, 0,
+				"task \""+name+"\": 'verify_workdir' requires a 'verify' command",
+				"Add 'verify: <command>' or remove 'verify_workdir'"))
+		}
+
+		// Check verification settings
+		if task.HasVerify() && !task.Write {
+			errs.Add(NewCodedError(CodeWriteOnly, file, 0,
+				"task \""+name+"\": 'verify' is only supported on write tasks",
+				"Add 'write: true' or remove the 'verify' command"))
+		}
+		if task.FixAttempts < 0 {
+			errs.Add(NewCodedError(CodeNegativeValue, file, 0,
+				"task \""+name+"\": 'fix_attempts' cannot be negative",
+				"Use 0 to disable the fix loop"))
+		} else if task.FixAttempts > 0 && !task.HasVerify() {
+			errs.Add(NewCodedError(CodeMissingVerify, file, 0,
+				"task \""+name+"\": 'fix_attempts' requires a 'verify' command",
+				"Add 'verify: <command>' to decide when a fix attempt is needed"))
+		}
+
+		// Check the retry policy
+		if task.Retries < 0 {
+			errs.Add(NewCodedError(CodeNegativeValue, file, 0,
+				"task \""+name+"\": 'retries' cannot be negative",
+				"Use 0 to disable retries"))
+		} else if task.Retries == 0 && (task.RetryBackoff != "" || len(task.RetryOn) > 0) {
+			errs.Add(NewCodedError(CodeInvalidValue, file, 0,
+				"task \""+name+"\": 'retry_backoff' and 'retry_on' require 'retries'",
+				"Add 'retries: <n>' to re-run the task when it fails"))
+		}
+		if task.RetryBackoff != "" {
+			if d, err := time.ParseDuration(task.RetryBackoff); err != nil || d < 0 {
+				errs.Add(NewCodedError(CodeInvalidValue, file, 0,
+					"task \""+name+"\": invalid retry_backoff \""+task.RetryBackoff+"\"",
+					"Use a duration like '30s' or '2m'"))
+			}
+		}
+		if _, _, err := ParseRetryOn(task.RetryOn); err != nil {
+			errs.Add(NewCodedError(CodeInvalidValue, file, 0,
+				"task \""+name+"\": retry_on: "+err.Error(),
+				"Use exit codes like '137' or regular expressions matched against the output"))
+		}
+
+		if task.Timeout != "" {
+			if d, err := time.ParseDuration(task.Timeout); err != nil || d <= 0 {
+				errs.Add(NewCodedError(CodeInvalidValue, file, 0,
+					"task \""+name+"\": invalid timeout \""+task.Timeout+"\"",
+					"Use a positive duration like '90s', '10m', or '1h30m'"))
+			}
+		}
+
+		// Check change limits
+		if task.MaxChangedFiles < 0 || task.MaxChangedLines < 0 {
+			errs.Add(NewCodedError(CodeNegativeValue, file, 0,
+				"task \""+name+"\": 'max_changed_files' and 'max_changed_lines' cannot be negative",
+				"Use 0 to disable the limit"))
+		}
+		if (task.MaxChangedFiles > 0 || task.MaxChangedLines > 0) && !task.Write {
+			errs.Add(NewCodedError(CodeWriteOnly, file, 0,
+				"task \""+name+"\": change limits are only supported on write tasks",
+				"Add 'write: true' or remove 'max_changed_files'/'max_changed_lines'"))
+		}
+		if !IsValidOverflowStrategy(task.OnContextOverflow) {
+			errs.Add(NewCodedError(CodeInvalidValue, file, 0,
+				"task \""+name+"\": invalid on_context_overflow \""+task.OnContextOverflow+"\"",
+				"Use 'truncate', 'summarize', or 'fail'"))
+		}
+		if task.MaxTokens < 0 {
+			errs.Add(NewCodedError(CodeNegativeValue, file, 0,
+				"task \""+name+"\": 'max_tokens' cannot be negative",
+				"Use 0 for no limit"))
+		}
+		if !IsValidScope(task.Scope) {
+			errs.Add(NewCodedError(CodeInvalidValue, file, 0,
+				"task \""+name+"\": invalid scope \""+task.Scope+"\"",
+				"Use 'full' or 'diff'"))
+		}
+		for _, pattern := range task.Paths {
+			if err := glob.Validate(pattern); err != nil {
+				errs.Add(NewCodedError(CodeInvalidValue, file, 0,
+					"task \""+name+"\": paths: "+err.Error(),
+					"Use globs like 'api/**' or '*.go'; '**' must be a whole path segment"))
+			}
+		}
+		for _, pattern := range task.Writes {
+			if err := glob.Validate(pattern); err != nil {
+				errs.Add(NewCodedError(CodeInvalidValue, file, 0,
+					"task \""+name+"\": writes: "+err.Error(),
+					"Use globs like 'api/**' or '*.go'; '**' must be a whole path segment"))
+			}
+		}
+		if len(task.Writes) > 0 && !task.Write {
+			errs.Add(NewCodedError(CodeConflictingFields, file, 0,
+				"task \""+name+"\": 'writes' requires 'write: true'",
+				"Add 'write: true', or remove 'writes' from the read-only task"))
+		}
+		if c := task.Context; c != nil {
+			if agentTool == "shell" {
+				errs.Add(NewCodedError(CodeConflictingFields, file, 0,
+					"task \""+name+"\": shell tasks take no 'context'",
+					"Remove 'context', or run the task on an AI agent"))
+			}
+			if len(c.Files) == 0 {
+				errs.Add(NewCodedError(CodeInvalidValue, file, 0,
+					"task \""+name+"\": 'context' has no 'files'",
+					"Add globs of the files to choose from, e.g. files: [\"**/*.go\", \"!vendor/**\"]"))
+			}
+			for _, pattern := range c.Files {
+				if err := glob.Validate(strings.TrimPrefix(pattern, "!")); err != nil {
+					errs.Add(NewCodedError(CodeInvalidValue, file, 0,
+						"task \""+name+"\": context files: "+err.Error(),
+						"Use globs like '**/*.go', or '!vendor/**' to exclude; '**' must be a whole path segment"))
+				}
+			}
+			if c.MaxTokens < 0 {
+				errs.Add(NewCodedError(CodeNegativeValue, file, 0,
+					"task \""+name+"\": context 'max_tokens' cannot be negative",
+					"Use 0 for the default budget of 8000 tokens"))
+			}
+		}
+		for _, v := range ExtractDiffVars(task.Prompt + "\n" + task.Command + "\n" + task.When) {
+			if v != DiffFiles && v != DiffPackages && v != DiffBase {
+				errs.Add(NewCodedError(CodeUndefinedReference, file, 0,
+					"task \""+name+"\": template references unknown diff value \""+v+"\"",
+					"Available: {{diff.files}}, {{diff.packages}}, {{diff.base}}").
+					WithSuggestion(v, []string{DiffFiles, DiffPackages, DiffBase}))
+			}
+		}
+		for _, e := range validateNormalize(file, name, task.Normalize) {
+			errs.Add(e)
+		}
+		for _, e := range validateExpect(file, name, task) {
+			errs.Add(e)
+		}
+		if task.Workdir != "" {
+			for _, e := range validateWorkdir(file, name, config.TaskWorkdir(task)) {
+				errs.Add(e)
+			}
+		}
+		if !IsValidMemoryMode(task.Memory) {
+			errs.Add(NewCodedError(CodeInvalidValue, file, 0,
+				"task \""+name+"\": invalid memory \""+task.Memory+"\"",
+				"Use 'read' or 'write'"))
+		}
+		if task.Memory == "" && strings.Contains(task.Prompt, MemoryVar) {
+			errs.Add(NewCodedError(CodeInvalidTemplate, file, 0,
+				"task \""+name+"\": prompt uses "+MemoryVar+" but the task has no memory access",
+				"Add 'memory: read' (or 'memory: write' to also record its output)"))
+		}
+		if task.Commit != nil && !task.Write {
+			errs.Add(NewCodedError(CodeWriteOnly, file, 0,
+				"task \""+name+"\": 'commit' is only supported on write tasks",
+				"Add 'write: true' or remove 'commit'"))
+		}
+		if task.Commit != nil && task.WritePatch {
+			errs.Add(NewCodedError(CodeConflictingFields, file, 0,
+				"task \""+name+"\": cannot have both 'write: patch' and 'commit'",
+				"Patches are applied with 'cortex apply' after review; use 'write: true' to commit the changes"))
+		}
+
 		// Check dependency references
 		for _, dep := range task.Needs {
 			if _, exists := config.Tasks[dep]; !exists {
-				errs.Add(ErrUndefinedDependency(filePath, 0, name, dep, availableTasks))
+				errs.Add(ErrUndefinedDependency(file, 0, name, dep, availableTasks))
 			}
 			if dep == name {
-				errs.Add(ErrSelfDependency(filePath, 0, name))
+				errs.Add(ErrSelfDependency(file, 0, name))
 			}
 		}
 
 		// Validate template variables reference valid dependencies
-		templateErrs := validateTemplateVarsStructured(filePath, name, task.Prompt, task.Needs, config.Tasks)
+		templateErrs := validateTemplateVarsStructured(file, name, task.Prompt+"\n"+strings.Join(args, "\n")+"\n"+task.When, task.Needs, config.Tasks)
 		for _, e := range templateErrs {
 			errs.Add(e)
 		}
+		for _, e := range validateContextVars(file, "task \""+name+"\"", task.Prompt+"\n"+task.When) {
+			errs.Add(e)
+		}
+		for _, e := range validateMetaVars(file, "task \""+name+"\"", task.Prompt+"\n"+task.Command+"\n"+strings.Join(args, "\n")+"\n"+task.When, secretNames) {
+			errs.Add(e)
+		}
+		for _, e := range validateVariables(file, "task \""+name+"\"", task.Prompt+"\n"+task.Command+"\n"+strings.Join(args, "\n")+"\n"+task.When, variableNames) {
+			errs.Add(e)
+		}
+		if task.OutputFile != "" {
+			for _, e := range validateMetaVars(file, "task \""+name+"\": output_file", task.OutputFile, nil) {
+				errs.Add(e)
+			}
+			for _, v := range Placeholders(task.OutputFile) {
+				if !slices.Contains(ExtractMetaVars(task.OutputFile), v) {
+					errs.Add(NewCodedError(CodeInvalidTemplate, file, 0,
+						"task \""+name+"\": output_file cannot use {{"+v+"}}",
+						"Use {{task.name}}, {{run.id}}, {{run.start_time}}, {{git.X}}, or {{env.X}}"))
+				}
+			}
+		}
+		if task.When != "" {
+			for _, e := range validateCondition(file, name, task.When) {
+				errs.Add(e)
+			}
+		}
+		for _, ref := range ExtractPriorOutputVars(task.Prompt + "\n" + task.When) {
+			if _, exists := config.Tasks[ref]; !exists {
+				errs.Add(NewCodedError(CodeUndefinedReference, file, 0,
+					"task \""+name+"\": template references undefined task \""+ref+"\" in a previous run",
+					"{{runs.last_success.outputs.X}} must name a task in this workflow").
+					WithSuggestion(ref, availableTasks))
+			}
+		}
+		for _, call := range ExtractRetrieveCalls(task.Prompt) {
+			if call.K < 1 {
+				errs.Add(NewCodedError(CodeInvalidTemplate, file, 0,
+					"task \""+name+"\": "+call.Placeholder+" must retrieve at least one snippet",
+					"Use k=1 or more, or omit k for the default of 5"))
+			}
+		}
+	}
+
+	for _, e := range validateOutputFileCollisions(filePath, config.Tasks) {
+		errs.Add(e)
+	}
+
+	for _, e := range validateSharedPrompt(filePath, "preamble", config.Preamble, secretNames, variableNames) {
+		errs.Add(e)
+	}
+	for _, name := range sortedNames(config.Agents) {
+		file := config.SourceFile("agents", name, filePath)
+		for _, e := range validateSharedPrompt(file, "agent \""+name+"\" instructions", config.Agents[name].Instructions, secretNames, variableNames) {
+			errs.Add(e)
+		}
+	}
+
+	if r := config.Retrieval; r != nil {
+		if !IsValidEmbedProvider(r.Provider) {
+			errs.Add(NewCodedError(CodeInvalidValue, filePath, 0,
+				"retrieval: invalid provider \""+r.Provider+"\"",
+				"Use 'local', 'openai', or 'ollama'"))
+		}
+		if r.MaxTokens < 0 {
+			errs.Add(NewCodedError(CodeNegativeValue, filePath, 0,
+				"retrieval: 'max_tokens' cannot be negative",
+				"Use 0 for the default budget"))
+		}
+	}
+
+	if d := config.Digest; d != nil {
+		if d.Path == "" {
+			errs.Add(NewCodedError(CodeInvalidValue, filePath, 0,
+				"digest: 'path' is required",
+				"Add e.g. 'path: reports/{{run.id}}.md', relative to the workdir"))
+		}
+		for _, e := range validateMetaVars(filePath, "digest: path", d.Path, nil) {
+			errs.Add(e)
+		}
+		for _, v := range Placeholders(d.Path) {
+			if !slices.Contains(ExtractMetaVars(d.Path), v) || strings.HasPrefix(v, "task.") {
+				errs.Add(NewCodedError(CodeInvalidTemplate, filePath, 0,
+					"digest: path cannot use {{"+v+"}}",
+					"Use {{run.id}}, {{run.start_time}}, {{git.X}}, or {{env.X}}"))
+			}
+		}
+		for _, task := range d.Tasks {
+			if _, exists := config.Tasks[task]; !exists {
+				errs.Add(ErrUndefinedDigestTask(filePath, task, availableTasks))
+			}
+		}
+	}
+
+	// Validate settings
+	if config.Settings != nil && !IsValidDirtyTreePolicy(config.Settings.DirtyTree) {
+		errs.Add(NewCodedError(CodeInvalidValue, filePath, 0,
+			"settings: invalid dirty_tree policy \""+config.Settings.DirtyTree+"\"",
+			"Use 'refuse', 'stash', or 'proceed'"))
+	}
+	if config.Settings != nil {
+		if err := config.Settings.Retention.Validate(); err != nil {
+			errs.Add(NewCodedError(CodeInvalidValue, filePath, 0, err.Error(),
+				"Keep prompts with 'full', 'hash', or 'none'; redact stdout, stderr, transcript, or verify_output"))
+		}
+	}
+	if config.Settings != nil && config.Settings.Store != "" {
+		errs.Add(NewCodedError(CodeInvalidValue, filePath, 0,
+			"settings: 'store' can only be set in the global config",
+			"Set it in ~/.cortex/config.yml or CORTEX_STORE, so every command reads runs from the same store"))
+	}
+	if config.Settings != nil {
+		if err := config.Settings.Schedule.Validate(); err != nil {
+			errs.Add(NewCodedError(CodeInvalidValue, filePath, 0, "settings."+err.Error(),
+				"Write the window as HH:MM-HH:MM in local time, e.g. 22:00-06:00"))
+		}
+	}
+	if config.Settings != nil && config.Settings.MaxTokens < 0 {
+		errs.Add(NewCodedError(CodeNegativeValue, filePath, 0,
+			"settings: 'max_tokens' cannot be negative",
+			"Use 0 for no limit"))
+	}
+	if config.Settings != nil && (config.Settings.MaxCPU < 0 || config.Settings.MaxCPU > 100) {
+		errs.Add(NewCodedError(CodeInvalidValue, filePath, 0,
+			"settings: 'max_cpu' must be a percentage",
+			"Use a value from 1 to 100, or 0 for no limit"))
+	}
+	if config.Settings != nil && (config.Settings.MaxMemory < 0 || config.Settings.MaxMemory > 100) {
+		errs.Add(NewCodedError(CodeInvalidValue, filePath, 0,
+			"settings: 'max_memory' must be a percentage",
+			"Use a value from 1 to 100, or 0 for no limit"))
+	}
+
+	// Validate task groups
+	for _, group := range sortedNames(config.Groups) {
+		file := config.SourceFile("groups", group, filePath)
+		if len(config.Groups[group]) == 0 {
+			errs.Add(NewCodedError(CodeEmptyGroup, file, 0,
+				"group \""+group+"\": lists no tasks",
+				"List the tasks to run with --group "+group))
+		}
+		for _, task := range config.Groups[group] {
+			if _, exists := config.Tasks[task]; !exists {
+				errs.Add(ErrUndefinedGroupTask(file, 0, group, task, availableTasks))
+			}
+		}
 	}
 
 	// Check for circular dependencies
@@ -123,13 +578,28 @@ func Validate(config *AgentflowConfig) error {
 	return ValidateWithFile(config, "Cortexfile.yml")
 }
 
-// templateVarRegex matches {{outputs.taskname}} patterns.
-var templateVarRegex = regexp.MustCompile(`\{\{outputs\.([a-zA-Z0-9_-]+)\}\}`)
+// templateVarRegex matches {{outputs.taskname}} patterns, optionally with a
+// path into the output of an 'expect: json' task and a default:
+// {{outputs.taskname.field.0 | default "text"}}. The groups are the task,
+// the path with its leading dot, and the default.
+var templateVarRegex = regexp.MustCompile(`\{\{outputs\.([a-zA-Z0-9_-]+)((?:\.[a-zA-Z0-9_-]+)*)(?:\s*\|\s*default\s+"((?:[^"\\]|\\.)*)")?\}\}`)
+
+// outputRefRegex loosely matches anything shaped like an {{outputs.X ...}} reference.
+var outputRefRegex = regexp.MustCompile(`\{\{outputs\.[^{}]*\}\}`)
 
 // validateTemplateVarsStructured checks that all {{outputs.X}} references are valid dependencies.
 func validateTemplateVarsStructured(filePath, taskName, prompt string, needs []string, tasks map[string]TaskConfig) []*ConfigError {
 	var errs []*ConfigError
 
+	// A reference the pattern doesn't recognize would reach the agent verbatim
+	for _, ref := range outputRefRegex.FindAllString(prompt, -1) {
+		if !templateVarRegex.MatchString(ref) {
+			errs = append(errs, NewCodedError(CodeInvalidTemplate, filePath, 0,
+				"task \""+taskName+"\": malformed output reference "+ref,
+				`Use {{outputs.task}}, {{outputs.task.field}}, or {{outputs.task | default "text"}}`))
+		}
+	}
+
 	matches := templateVarRegex.FindAllStringSubmatch(prompt, -1)
 	needsSet := make(map[string]bool)
 	for _, n := range needs {
@@ -141,23 +611,324 @@ func validateTemplateVarsStructured(filePath, taskName, prompt string, needs []s
 
 		// Check if referenced task exists
 		if _, exists := tasks[refTask]; !exists {
-			errs = append(errs, NewConfigErrorWithHint(filePath, 0,
+			errs = append(errs, NewCodedError(CodeUndefinedReference, filePath, 0,
 				"task \""+taskName+"\": template references undefined task \""+refTask+"\"",
-				"Define the task or fix the template variable name"))
+				"Define the task or fix the template variable name").
+				WithSuggestion(refTask, sortedNames(tasks)))
 			continue
 		}
 
 		// Check if referenced task is in needs
 		if !needsSet[refTask] {
-			errs = append(errs, NewConfigErrorWithHint(filePath, 0,
+			errs = append(errs, NewCodedError(CodeMissingNeeds, filePath, 0,
 				"task \""+taskName+"\": template references \""+refTask+"\" which is not in 'needs'",
 				"Add '"+refTask+"' to the 'needs' list to ensure it runs first"))
 		}
+
+		// Fields can only be read from JSON output
+		if match[2] != "" && tasks[refTask].Expect != ExpectJSON {
+			errs = append(errs, NewCodedError(CodeInvalidTemplate, filePath, 0,
+				"task \""+taskName+"\": template reads field \""+match[2][1:]+"\" of \""+refTask+"\", whose output isn't JSON",
+				"Add 'expect: json' to '"+refTask+"', or use {{outputs."+refTask+"}}"))
+		}
 	}
 
 	return errs
 }
 
+// conditionPrefixes are the placeholders a 'when' condition can use.
+var conditionPrefixes = []string{"outputs.", "vars.", "context.", "env.", "git.", "run.", "task.", "diff.", "runs.last_success.outputs."}
+
+// validateCondition checks that a task's 'when' parses and only uses
+// placeholders the executor expands in conditions.
+func validateCondition(filePath, taskName, when string) []*ConfigError {
+	if _, err := ParseCondition(when); err != nil {
+		return []*ConfigError{NewCodedError(CodeInvalidCondition, filePath, 0,
+			"task \""+taskName+"\": invalid when: "+err.Error(),
+			`Write a condition like "{{outputs.scan}} contains 'CRITICAL'" or "{{env.DEPLOY}} == yes"`)}
+	}
+	var errs []*ConfigError
+	for _, name := range Placeholders(when) {
+		known := false
+		for _, prefix := range conditionPrefixes {
+			known = known || strings.HasPrefix(name, prefix)
+		}
+		if !known {
+			errs = append(errs, NewCodedError(CodeInvalidTemplate, filePath, 0,
+				"task \""+taskName+"\": when cannot use {{"+name+"}}",
+				"Conditions can use {{outputs.X}}, {{vars.X}}, {{env.X}}, {{git.X}}, {{run.X}}, {{task.X}}, {{context.X}}, {{diff.X}}, and prior run outputs"))
+		}
+	}
+	return errs
+}
+
+// validateCompress checks a compress step: what it shortens, and that it
+// has none of the fields of tasks that prompt an agent.
+func validateCompress(filePath, taskName string, task TaskConfig, agentTool string) []*ConfigError {
+	var errs []*ConfigError
+	c := task.Compress
+	if agentTool == "shell" {
+		errs = append(errs, NewCodedError(CodeConflictingFields, filePath, 0,
+			"task \""+taskName+"\": shell agent \""+task.Agent+"\" cannot write a compress step's summary",
+			"Use an AI agent, or remove 'agent' to keep the most telling lines instead"))
+	}
+
+	var conflicts []string
+	for _, f := range []struct {
+		name string
+		set  bool
+	}{
+		{"prompt", task.Prompt != "" || task.PromptFile != ""},
+		{"command", task.HasCommand()},
+		{"write", task.Write},
+		{"verify", task.HasVerify()},
+		{"context", task.Context != nil},
+		{"memory", task.Memory != ""},
+		{"scope", task.Scope != ""},
+	} {
+		if f.set {
+			conflicts = append(conflicts, "'"+f.name+"'")
+		}
+	}
+	if len(conflicts) > 0 {
+		errs = append(errs, NewCodedError(CodeConflictingFields, filePath, 0,
+			"task \""+taskName+"\": compress steps take no "+strings.Join(conflicts, ", "),
+			"Put the text to shorten in compress 'input' or 'files'"))
+	}
+
+	if strings.TrimSpace(c.Input) == "" && len(c.Files) == 0 {
+		errs = append(errs, NewCodedError(CodeNoPrompt, filePath, 0,
+			"task \""+taskName+"\": 'compress' has no 'input' or 'files'",
+			"Add input: \"{{outputs.<task>}}\", or files: [\"docs/**/*.md\"]"))
+	}
+	for _, pattern := range c.Files {
+		if err := glob.Validate(strings.TrimPrefix(pattern, "!")); err != nil {
+			errs = append(errs, NewCodedError(CodeInvalidValue, filePath, 0,
+				"task \""+taskName+"\": compress files: "+err.Error(),
+				"Use globs like 'docs/**/*.md', or '!vendor/**' to exclude; '**' must be a whole path segment"))
+		}
+	}
+	if c.MaxTokens < 0 {
+		errs = append(errs, NewCodedError(CodeNegativeValue, filePath, 0,
+			"task \""+taskName+"\": compress 'max_tokens' cannot be negative",
+			"Use 0 for the default of 2000 tokens"))
+	}
+	return errs
+}
+
+// validateNormalize checks a task's output normalization steps.
+func validateNormalize(filePath, taskName string, steps StringList) []*ConfigError {
+	var errs []*ConfigError
+	for _, step := range steps {
+		switch {
+		case step == NormalizeNone && len(steps) > 1:
+			errs = append(errs, NewCodedError(CodeConflictingFields, filePath, 0,
+				"task \""+taskName+"\": normalize 'none' cannot be combined with other steps",
+				"Use 'none' alone to keep the output as printed"))
+		case step != NormalizeNone && !slices.Contains(NormalizeSteps, step):
+			errs = append(errs, NewCodedError(CodeInvalidValue, filePath, 0,
+				"task \""+taskName+"\": invalid normalize step \""+step+"\"",
+				"Use 'ansi', 'utf8', 'newlines', 'progress', or 'none'").
+				WithSuggestion(step, NormalizeSteps))
+		}
+	}
+	return errs
+}
+
+// validateExpect checks a task's expected output format and schema.
+func validateExpect(filePath, taskName string, task TaskConfig) []*ConfigError {
+	var errs []*ConfigError
+	if task.Expect != "" && task.Expect != ExpectJSON {
+		errs = append(errs, NewCodedError(CodeInvalidValue, filePath, 0,
+			"task \""+taskName+"\": invalid expect \""+task.Expect+"\"",
+			"Use 'json'").WithSuggestion(task.Expect, []string{ExpectJSON}))
+	}
+	if task.Expect == "" && (len(task.Schema) > 0 || task.SchemaFile != "" || task.ExpectRetries != 0) {
+		errs = append(errs, NewCodedError(CodeInvalidValue, filePath, 0,
+			"task \""+taskName+"\": 'schema', 'schema_file', and 'expect_retries' require 'expect: json'",
+			"Add 'expect: json' to check the task's output"))
+	}
+	if task.ExpectRetries < 0 {
+		errs = append(errs, NewCodedError(CodeNegativeValue, filePath, 0,
+			"task \""+taskName+"\": 'expect_retries' cannot be negative",
+			"Use 0 to fail the task on the first invalid output"))
+	}
+	if len(task.Schema) > 0 {
+		if _, err := jsonschema.Compile(task.Schema); err != nil {
+			where := "schema"
+			if task.SchemaFile != "" {
+				where = "schema_file " + task.SchemaFile
+			}
+			errs = append(errs, NewCodedError(CodeInvalidValue, filePath, 0,
+				"task \""+taskName+"\": invalid "+where+": "+err.Error(),
+				"Use the JSON Schema keywords listed under Expected Output in the README"))
+		}
+	}
+	return errs
+}
+
+// validateWorkdir checks that a task's workdir is a directory, or can be
+// created as one: the nearest of its parents that exists is a directory.
+func validateWorkdir(filePath, taskName, dir string) []*ConfigError {
+	if info, err := os.Stat(dir); err == nil {
+		if info.IsDir() {
+			return nil
+		}
+		return []*ConfigError{NewCodedError(CodeInvalidWorkdir, filePath, 0,
+			"task \""+taskName+"\": workdir "+dir+" is not a directory",
+			"Point 'workdir' at a directory, or remove the file in its way")}
+	}
+	for parent := filepath.Dir(dir); ; parent = filepath.Dir(parent) {
+		info, err := os.Stat(parent)
+		if err != nil && parent != filepath.Dir(parent) {
+			continue
+		}
+		if err == nil && info.IsDir() {
+			return nil
+		}
+		return []*ConfigError{NewCodedError(CodeInvalidWorkdir, filePath, 0,
+			"task \""+taskName+"\": workdir "+dir+" cannot be created: "+parent+" is not a directory",
+			"Point 'workdir' at a directory, or at one inside an existing directory")}
+	}
+}
+
+// validateSharedPrompt checks text shared by many tasks' prompts, like the
+// preamble or an agent's instructions, which can't depend on any one
+// task's outputs.
+func validateSharedPrompt(filePath, where, text string, secrets, variables []string) []*ConfigError {
+	var errs []*ConfigError
+	text = ProtectEscapes(text)
+	if len(ExtractTemplateVars(text)) > 0 {
+		errs = append(errs, NewCodedError(CodeInvalidTemplate, filePath, 0,
+			where+": cannot reference task outputs",
+			"Use {{task.name}}, {{task.agent}}, or {{run.id}}; reference outputs in each task's prompt"))
+	}
+	if strings.Contains(text, MemoryVar) {
+		errs = append(errs, NewCodedError(CodeInvalidTemplate, filePath, 0,
+			where+": cannot reference "+MemoryVar,
+			"Use "+MemoryVar+" in the prompts of tasks with 'memory: read' or 'memory: write'"))
+	}
+	errs = append(errs, validateContextVars(filePath, where, text)...)
+	errs = append(errs, validateVariables(filePath, where, text, variables)...)
+	return append(errs, validateMetaVars(filePath, where, text, secrets)...)
+}
+
+// variableNameRegex matches the variable names {{vars.X}} can reference.
+var variableNameRegex = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
+
+// validateVariables checks that {{vars.X}} placeholders name a variable
+// the Cortexfile defines.
+func validateVariables(filePath, where, text string, variables []string) []*ConfigError {
+	var errs []*ConfigError
+	for _, name := range ExtractVariables(ProtectEscapes(text)) {
+		if !slices.Contains(variables, name) {
+			errs = append(errs, NewCodedError(CodeUndefinedReference, filePath, 0,
+				where+": template references undefined variable \""+name+"\"",
+				"Define it under 'variables:', e.g. '"+name+": value'").
+				WithSuggestion(name, variables))
+		}
+	}
+	return errs
+}
+
+// validateContextVars checks that {{context.X}} placeholders name a known context value.
+func validateContextVars(filePath, where, prompt string) []*ConfigError {
+	var errs []*ConfigError
+	for _, name := range ExtractContextVars(prompt) {
+		if name != ContextRepo {
+			errs = append(errs, NewCodedError(CodeUndefinedReference, filePath, 0,
+				where+": template references unknown context \""+name+"\"",
+				"Available: {{context."+ContextRepo+"}}"))
+		}
+	}
+	return errs
+}
+
+// validateMetaVars checks that {{git.X}}, {{run.X}}, and {{task.X}}
+// placeholders name known metadata, and {{secrets.X}} ones one of secrets
+// (nil where secrets can't be used, like file paths). Any {{env.X}} is
+// allowed.
+func validateMetaVars(filePath, where, prompt string, secrets []string) []*ConfigError {
+	var errs []*ConfigError
+	for _, name := range ExtractMetaVars(prompt) {
+		if strings.HasPrefix(name, "env.") || slices.Contains(MetaVars, name) {
+			continue
+		}
+		if secret, ok := strings.CutPrefix(name, MetaSecretsPrefix); ok {
+			switch {
+			case secrets == nil:
+				errs = append(errs, NewCodedError(CodeInvalidTemplate, filePath, 0,
+					where+": cannot use {{"+name+"}}",
+					"Secrets are only expanded in prompts, commands, and the preamble, so they never end up in file names"))
+			case !slices.Contains(secrets, secret):
+				errs = append(errs, NewCodedError(CodeUndefinedReference, filePath, 0,
+					where+": template references undefined secret \""+secret+"\"",
+					"Define it under 'secrets:', e.g. '"+secret+": env:"+secret+"'").
+					WithSuggestion(secret, secrets))
+			}
+			continue
+		}
+		errs = append(errs, NewCodedError(CodeUndefinedReference, filePath, 0,
+			where+": template references unknown value \""+name+"\"",
+			"Available: {{"+strings.Join(MetaVars, "}}, {{")+"}}, {{env.NAME}}").
+			WithSuggestion(name, MetaVars))
+	}
+	return errs
+}
+
+// secretNameRegex matches names usable as environment variables, which is
+// how tasks get secrets besides {{secrets.NAME}}.
+var secretNameRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// validateSecret checks a secret's name and its "provider:ref" source.
+// Which providers exist is up to the runtime (see the secrets package), so
+// only the form is checked here.
+func validateSecret(filePath, name, source string) *ConfigError {
+	if !secretNameRegex.MatchString(name) {
+		return NewCodedError(CodeInvalidSecret, filePath, 0,
+			"secret name \""+name+"\" may only contain letters, digits, and '_'",
+			"Tasks get secrets as environment variables, so names must start with a letter or '_'")
+	}
+	provider, ref, ok := strings.Cut(source, ":")
+	if !ok || provider == "" || strings.TrimSpace(ref) == "" {
+		return NewCodedError(CodeInvalidSecret, filePath, 0,
+			"secret \""+name+"\": source \""+source+"\" isn't of the form provider:ref",
+			"Use e.g. 'env:"+name+"', 'file:~/.config/"+strings.ToLower(name)+"', or 'command:op read op://vault/item'")
+	}
+	return nil
+}
+
+// validateOutputFileCollisions checks that no two tasks save their output
+// to the same path in a run.
+func validateOutputFileCollisions(filePath string, tasks map[string]TaskConfig) []*ConfigError {
+	var errs []*ConfigError
+	names := make([]string, 0, len(tasks))
+	for name := range tasks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	owners := make(map[string]string)
+	for _, name := range names {
+		task := tasks[name]
+		if task.OutputFile == "" {
+			continue
+		}
+		// Run-wide values are the same for every task, so only the task's
+		// own values can tell two paths apart
+		path := ExpandMeta(task.OutputFile, map[string]string{MetaTaskName: name, MetaTaskAgent: task.Agent})
+		path = filepath.Clean(path)
+		if owner, ok := owners[path]; ok {
+			errs = append(errs, NewCodedError(CodeOutputConflict, filePath, 0,
+				"task \""+name+"\": output_file "+task.OutputFile+" is also written by task \""+owner+"\"",
+				"Include {{task.name}} in the path, e.g. reports/{{run.id}}/{{task.name}}.md"))
+			continue
+		}
+		owners[path] = name
+	}
+	return errs
+}
+
 // detectCycleSlice uses DFS to find circular dependencies and returns the cycle.
 func detectCycleSlice(tasks map[string]TaskConfig) []string {
 	// States: 0 = unvisited, 1 = visiting (in current path), 2 = visited
@@ -1,6 +1,10 @@
 package config
 
 import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
 	"strings"
 	"testing"
 )
@@ -193,6 +197,54 @@ func TestValidate_TaskValidation(t *testing.T) {
 			},
 			wantErrContains: []string{`cannot have both 'prompt' and 'prompt_file'`},
 		},
+		{
+			name: "writes on a read-only task, and a malformed writes glob",
+			tasks: map[string]TaskConfig{
+				"task1": {Agent: "agent1", Prompt: "test", Writes: StringList{"api/**"}},
+				"task2": {Agent: "agent1", Prompt: "test", Write: true, Writes: StringList{"web/**.ts"}},
+			},
+			wantErrContains: []string{`task "task1": 'writes' requires 'write: true'`, `task "task2": writes: invalid pattern`},
+		},
+		{
+			name: "context without files, with a malformed glob, and a negative budget",
+			tasks: map[string]TaskConfig{
+				"task1": {Agent: "agent1", Prompt: "test", Context: &ContextConfig{}},
+				"task2": {Agent: "agent1", Prompt: "test", Context: &ContextConfig{Files: StringList{"!vendor/**.go"}, MaxTokens: -1}},
+			},
+			wantErrContains: []string{`task "task1": 'context' has no 'files'`, `task "task2": context files: invalid pattern`, `task "task2": context 'max_tokens' cannot be negative`},
+		},
+		{
+			name: "compress steps with agent task fields, nothing to shorten, and an unknown output",
+			tasks: map[string]TaskConfig{
+				"task1": {Compress: &CompressConfig{Input: "{{outputs.task2}}"}, Prompt: "test", Write: true},
+				"task2": {Agent: "agent1", Compress: &CompressConfig{MaxTokens: -1}},
+			},
+			wantErrContains: []string{`task "task1": compress steps take no 'prompt', 'write'`, `task "task1": template references "task2" which is not in 'needs'`, `task "task2": 'compress' has no 'input' or 'files'`, `task "task2": compress 'max_tokens' cannot be negative`},
+		},
+		{
+			name: "invalid normalize steps",
+			tasks: map[string]TaskConfig{
+				"task1": {Agent: "agent1", Prompt: "test", Normalize: []string{"ansii"}},
+				"task2": {Agent: "agent1", Prompt: "test", Normalize: []string{"none", "utf8"}},
+			},
+			wantErrContains: []string{`task "task1": invalid normalize step "ansii" (did you mean "ansi"?)`, `task "task2": normalize 'none' cannot be combined with other steps`},
+		},
+		{
+			name: "invalid expected output",
+			tasks: map[string]TaskConfig{
+				"task1": {Agent: "agent1", Prompt: "test", Expect: "jsn"},
+				"task2": {Agent: "agent1", Prompt: "test", Schema: map[string]any{"type": "object"}, ExpectRetries: -1},
+				"task3": {Agent: "agent1", Prompt: "test", Expect: "json", Schema: map[string]any{"type": "obj"}},
+				"task4": {Agent: "agent1", Prompt: "{{outputs.task1.files}}", Needs: []string{"task1"}},
+			},
+			wantErrContains: []string{
+				`task "task1": invalid expect "jsn" (did you mean "json"?)`,
+				`task "task2": 'schema', 'schema_file', and 'expect_retries' require 'expect: json'`,
+				`task "task2": 'expect_retries' cannot be negative`,
+				`task "task3": invalid schema: #/type: unknown type "obj"`,
+				`task "task4": template reads field "files" of "task1", whose output isn't JSON`,
+			},
+		},
 		{
 			name: "undefined dependency",
 			tasks: map[string]TaskConfig{
@@ -381,6 +433,108 @@ func TestValidate_TemplateVariables(t *testing.T) {
 			},
 			wantErr: false,
 		},
+		{
+			name: "repo context reference",
+			tasks: map[string]TaskConfig{
+				"task1": {Agent: "agent1", Prompt: "Repo: {{context.repo}}"},
+			},
+			wantErr: false,
+		},
+		{
+			name: "unknown context reference",
+			tasks: map[string]TaskConfig{
+				"task1": {Agent: "agent1", Prompt: "Repo: {{context.files}}"},
+			},
+			wantErr:         true,
+			wantErrContains: `template references unknown context "files"`,
+		},
+		{
+			name: "memory with read access",
+			tasks: map[string]TaskConfig{
+				"task1": {Agent: "agent1", Prompt: "Last time: {{memory}}", Memory: "read"},
+			},
+			wantErr: false,
+		},
+		{
+			name: "memory without access",
+			tasks: map[string]TaskConfig{
+				"task1": {Agent: "agent1", Prompt: "Last time: {{memory}}"},
+			},
+			wantErr:         true,
+			wantErrContains: `prompt uses {{memory}} but the task has no memory access`,
+		},
+		{
+			name: "invalid memory mode",
+			tasks: map[string]TaskConfig{
+				"task1": {Agent: "agent1", Prompt: "report", Memory: "append"},
+			},
+			wantErr:         true,
+			wantErrContains: `invalid memory "append"`,
+		},
+		{
+			name: "optional output reference",
+			tasks: map[string]TaskConfig{
+				"scan":   {Agent: "agent1", Prompt: "scan"},
+				"report": {Agent: "agent1", Prompt: `Findings: {{outputs.scan | default "none"}}`, Needs: []string{"scan"}},
+			},
+			wantErr: false,
+		},
+		{
+			name: "malformed output reference",
+			tasks: map[string]TaskConfig{
+				"scan":   {Agent: "agent1", Prompt: "scan"},
+				"report": {Agent: "agent1", Prompt: `Findings: {{outputs.scan | default none}}`, Needs: []string{"scan"}},
+			},
+			wantErr:         true,
+			wantErrContains: `malformed output reference {{outputs.scan | default none}}`,
+		},
+		{
+			name: "metadata references",
+			tasks: map[string]TaskConfig{
+				"task1": {Agent: "agent1", Prompt: "On {{git.branch}} at {{run.start_time}} as {{env.USER}}"},
+			},
+			wantErr: false,
+		},
+		{
+			name: "unknown metadata reference",
+			tasks: map[string]TaskConfig{
+				"task1": {Agent: "agent1", Prompt: "Commit {{git.sha}}"},
+			},
+			wantErr:         true,
+			wantErrContains: `template references unknown value "git.sha"`,
+		},
+		{
+			name: "output files per task",
+			tasks: map[string]TaskConfig{
+				"scan":   {Agent: "agent1", Prompt: "scan", OutputFile: "reports/{{run.id}}/{{task.name}}.md"},
+				"report": {Agent: "agent1", Prompt: "report", OutputFile: "reports/{{run.id}}/{{task.name}}.md"},
+			},
+			wantErr: false,
+		},
+		{
+			name: "output files collide",
+			tasks: map[string]TaskConfig{
+				"scan":   {Agent: "agent1", Prompt: "scan", OutputFile: "reports/{{run.id}}.md"},
+				"report": {Agent: "agent1", Prompt: "report", OutputFile: "reports/./{{run.id}}.md"},
+			},
+			wantErr:         true,
+			wantErrContains: `task "scan": output_file reports/{{run.id}}.md is also written by task "report"`,
+		},
+		{
+			name: "output file uses an output",
+			tasks: map[string]TaskConfig{
+				"scan": {Agent: "agent1", Prompt: "scan", OutputFile: "reports/{{outputs.scan}}.md"},
+			},
+			wantErr:         true,
+			wantErrContains: `output_file cannot use {{outputs.scan}}`,
+		},
+		{
+			name: "escaped braces are literal",
+			tasks: map[string]TaskConfig{
+				"task1": {Agent: "agent1", Prompt: `Write {{"{{"}} .Values.outputs.x }} and \{{outputs.missing}} and \{{memory}}`},
+			},
+			wantErr: false,
+		},
 	}
 
 	for _, tt := range tests {
@@ -574,6 +728,44 @@ func TestConfigErrors_Error(t *testing.T) {
 	}
 }
 
+// TestErrorCodes tests that validation errors carry stable codes.
+func TestErrorCodes(t *testing.T) {
+	cfg := &AgentflowConfig{
+		Agents: map[string]AgentConfig{"dev": {Tool: "claude-code"}},
+		Tasks: map[string]TaskConfig{
+			"build": {Agent: "qa", Prompt: "Build"},
+			"test":  {Agent: "dev", Prompt: "Test", Needs: []string{"lint"}},
+		},
+	}
+	err := ValidateWithFile(cfg, "Cortexfile.yml")
+	errs, ok := err.(*ConfigErrors)
+	if !ok {
+		t.Fatalf("ValidateWithFile() = %v, want ConfigErrors", err)
+	}
+
+	var codes []string
+	for _, e := range errs.Errors {
+		if e.Code == "" {
+			t.Errorf("error %q has no code", e.Message)
+		}
+		codes = append(codes, e.Code)
+	}
+	for _, want := range []string{CodeUndefinedAgent, CodeUndefinedDependency} {
+		if !slices.Contains(codes, want) {
+			t.Errorf("codes = %v, want %s", codes, want)
+		}
+	}
+	if got := ErrorCode(err); got != codes[0] {
+		t.Errorf("ErrorCode() = %q, want %q", got, codes[0])
+	}
+	if !strings.Contains(errs.Errors[0].Error(), "["+codes[0]+"]") {
+		t.Errorf("Error() = %q, want the code", errs.Errors[0].Error())
+	}
+	if got := ErrorCode(ErrYAMLParse("", 0, "bad")); got != CodeYAMLParse {
+		t.Errorf("ErrorCode(ErrYAMLParse) = %q, want %q", got, CodeYAMLParse)
+	}
+}
+
 // TestIsSupportedTool tests the tool support check function.
 func TestIsSupportedTool(t *testing.T) {
 	tests := []struct {
@@ -596,3 +788,590 @@ func TestIsSupportedTool(t *testing.T) {
 		})
 	}
 }
+
+// TestValidate_Verify tests validation of verify and fix_attempts settings.
+func TestValidate_Verify(t *testing.T) {
+	agents := map[string]AgentConfig{
+		"coder": {Tool: "claude-code"},
+	}
+
+	tests := []struct {
+		name            string
+		task            TaskConfig
+		wantErrContains string
+	}{
+		{
+			name: "verify on write task",
+			task: TaskConfig{Agent: "coder", Prompt: "fix it", Write: true, Verify: "go test ./..."},
+		},
+		{
+			name: "verify with fix attempts",
+			task: TaskConfig{Agent: "coder", Prompt: "fix it", Write: true, Verify: "go test ./...", FixAttempts: 2},
+		},
+		{
+			name:            "verify on read-only task",
+			task:            TaskConfig{Agent: "coder", Prompt: "fix it", Verify: "go test ./..."},
+			wantErrContains: "'verify' is only supported on write tasks",
+		},
+		{
+			name:            "fix attempts without verify",
+			task:            TaskConfig{Agent: "coder", Prompt: "fix it", Write: true, FixAttempts: 1},
+			wantErrContains: "'fix_attempts' requires a 'verify' command",
+		},
+		{
+			name:            "negative fix attempts",
+			task:            TaskConfig{Agent: "coder", Prompt: "fix it", Write: true, Verify: "make", FixAttempts: -1},
+			wantErrContains: "'fix_attempts' cannot be negative",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Validate(&AgentflowConfig{
+				Agents: agents,
+				Tasks:  map[string]TaskConfig{"implement": tt.task},
+			})
+
+			if tt.wantErrContains == "" {
+				if err != nil {
+					t.Errorf("expected no error, got: %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatal("expected validation error, got nil")
+			}
+			if !strings.Contains(err.Error(), tt.wantErrContains) {
+				t.Errorf("expected error containing %q, got: %v", tt.wantErrContains, err)
+			}
+		})
+	}
+}
+
+// TestValidate_When tests validation of task conditions.
+func TestValidate_When(t *testing.T) {
+	tests := []struct {
+		name            string
+		when            string
+		wantErrContains string
+	}{
+		{name: "output of a dependency", when: "{{outputs.scan}} contains 'SECURITY'"},
+		{name: "env and metadata", when: "{{env.DEPLOY}} == yes and {{git.branch}} == main"},
+		{name: "syntax error", when: "{{outputs.scan}} contains", wantErrContains: "invalid when: unexpected end"},
+		{name: "not in needs", when: "{{outputs.lint}} == ok", wantErrContains: `"lint" which is not in 'needs'`},
+		{name: "unknown placeholder", when: "{{memory}} contains x", wantErrContains: "when cannot use {{memory}}"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Validate(&AgentflowConfig{
+				Agents: map[string]AgentConfig{"dev": {Tool: "claude-code"}},
+				Tasks: map[string]TaskConfig{
+					"scan": {Agent: "dev", Prompt: "Scan"},
+					"lint": {Agent: "dev", Prompt: "Lint"},
+					"fix":  {Agent: "dev", Prompt: "Fix", Needs: []string{"scan"}, When: tt.when},
+				},
+			})
+
+			if tt.wantErrContains == "" {
+				if err != nil {
+					t.Errorf("expected no error, got: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErrContains) {
+				t.Errorf("expected error containing %q, got: %v", tt.wantErrContains, err)
+			}
+		})
+	}
+}
+
+func TestValidate_RetriesAndTimeout(t *testing.T) {
+	tests := []struct {
+		name            string
+		task            TaskConfig
+		wantErrContains string
+	}{
+		{name: "retries only", task: TaskConfig{Retries: 2}},
+		{name: "full policy", task: TaskConfig{Retries: 3, RetryBackoff: "30s", RetryOn: StringList{"137", "(?i)connection reset"}}},
+		{name: "negative", task: TaskConfig{Retries: -1}, wantErrContains: "'retries' cannot be negative"},
+		{name: "backoff without retries", task: TaskConfig{RetryBackoff: "1m"}, wantErrContains: "require 'retries'"},
+		{name: "invalid backoff", task: TaskConfig{Retries: 1, RetryBackoff: "soon"}, wantErrContains: `invalid retry_backoff "soon"`},
+		{name: "invalid pattern", task: TaskConfig{Retries: 1, RetryOn: StringList{"timeout("}}, wantErrContains: `retry_on: invalid pattern "timeout("`},
+		{name: "timeout", task: TaskConfig{Timeout: "1h30m"}},
+		{name: "invalid timeout", task: TaskConfig{Timeout: "10 minutes"}, wantErrContains: `invalid timeout "10 minutes"`},
+		{name: "zero timeout", task: TaskConfig{Timeout: "0s"}, wantErrContains: `invalid timeout "0s"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			task := tt.task
+			task.Agent, task.Prompt = "dev", "Deploy"
+			err := Validate(&AgentflowConfig{
+				Agents: map[string]AgentConfig{"dev": {Tool: "claude-code"}},
+				Tasks:  map[string]TaskConfig{"deploy": task},
+			})
+
+			if tt.wantErrContains == "" {
+				if err != nil {
+					t.Errorf("expected no error, got: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErrContains) {
+				t.Errorf("expected error containing %q, got: %v", tt.wantErrContains, err)
+			}
+		})
+	}
+}
+
+// TestValidate_DirtyTreePolicy tests validation of the dirty_tree setting.
+func TestValidate_DirtyTreePolicy(t *testing.T) {
+	for _, policy := range []string{"", "refuse", "stash", "proceed", "ignore"} {
+		t.Run(policy, func(t *testing.T) {
+			err := Validate(&AgentflowConfig{
+				Agents:   map[string]AgentConfig{"coder": {Tool: "claude-code"}},
+				Tasks:    map[string]TaskConfig{"implement": {Agent: "coder", Prompt: "go"}},
+				Settings: &SettingsConfig{DirtyTree: policy},
+			})
+
+			wantErr := policy == "ignore"
+			if (err != nil) != wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, wantErr)
+			}
+		})
+	}
+}
+
+func TestValidate_Retention(t *testing.T) {
+	tests := []struct {
+		retention       *RetentionConfig
+		wantErrContains string
+	}{
+		{retention: nil},
+		{retention: &RetentionConfig{Prompts: RetainHash, Redact: StringList{RedactStdout, RedactTranscript}}},
+		{retention: &RetentionConfig{Prompts: "encrypted"}, wantErrContains: `invalid prompts "encrypted"`},
+		{retention: &RetentionConfig{Redact: StringList{"prompt"}}, wantErrContains: `cannot redact "prompt"`},
+	}
+
+	for _, tt := range tests {
+		err := Validate(&AgentflowConfig{
+			Agents:   map[string]AgentConfig{"dev": {Tool: "claude-code"}},
+			Tasks:    map[string]TaskConfig{"review": {Agent: "dev", Prompt: "Review"}},
+			Settings: &SettingsConfig{Retention: tt.retention},
+		})
+		if (tt.wantErrContains == "") != (err == nil) || (err != nil && !strings.Contains(err.Error(), tt.wantErrContains)) {
+			t.Errorf("Validate(%+v) error = %v, want %q", tt.retention, err, tt.wantErrContains)
+		}
+	}
+}
+
+func TestValidate_Secrets(t *testing.T) {
+	tests := []struct {
+		secrets         map[string]string
+		prompt          string
+		outputFile      string
+		wantErrContains string
+	}{
+		{secrets: map[string]string{"GH_TOKEN": "env:GITHUB_TOKEN", "DB": "command:op read op://dev/db"}, prompt: "Use {{secrets.GH_TOKEN}}"},
+		{secrets: map[string]string{"GH_TOKEN": "env:GITHUB_TOKEN"}, prompt: "Use {{secrets.GH_TOKN}}", wantErrContains: `undefined secret "GH_TOKN" (did you mean "GH_TOKEN"?)`},
+		{prompt: "Use {{secrets.TOKEN}}", wantErrContains: `undefined secret "TOKEN"`},
+		{secrets: map[string]string{"gh-token": "env:GITHUB_TOKEN"}, wantErrContains: `secret name "gh-token" may only contain`},
+		{secrets: map[string]string{"TOKEN": "GITHUB_TOKEN"}, wantErrContains: `source "GITHUB_TOKEN" isn't of the form provider:ref`},
+		{secrets: map[string]string{"TOKEN": "env:X"}, outputFile: "out/{{secrets.TOKEN}}.md", wantErrContains: `output_file: cannot use {{secrets.TOKEN}}`},
+	}
+
+	for _, tt := range tests {
+		err := Validate(&AgentflowConfig{
+			Agents:  map[string]AgentConfig{"dev": {Tool: "claude-code"}},
+			Tasks:   map[string]TaskConfig{"review": {Agent: "dev", Prompt: "Review " + tt.prompt, OutputFile: tt.outputFile}},
+			Secrets: tt.secrets,
+		})
+		if (tt.wantErrContains == "") != (err == nil) || (err != nil && !strings.Contains(err.Error(), tt.wantErrContains)) {
+			t.Errorf("Validate(%v, %q) error = %v, want %q", tt.secrets, tt.prompt, err, tt.wantErrContains)
+		}
+	}
+}
+
+func TestValidate_Variables(t *testing.T) {
+	tests := []struct {
+		variables       map[string]string
+		prompt          string
+		preamble        string
+		wantErrContains string
+	}{
+		{variables: map[string]string{"service": "billing"}, prompt: "{{vars.service}}", preamble: "Working on {{vars.service}}."},
+		{variables: map[string]string{"service": "billing"}, prompt: "{{vars.servce}}", wantErrContains: `task "review": template references undefined variable "servce" (did you mean "service"?)`},
+		{prompt: `\{{vars.service}}`},
+		{preamble: "{{vars.service}}", wantErrContains: `preamble: template references undefined variable "service"`},
+		{variables: map[string]string{"the service": "billing"}, wantErrContains: `variable "the service": name can't be used`},
+	}
+
+	for _, tt := range tests {
+		err := Validate(&AgentflowConfig{
+			Agents:    map[string]AgentConfig{"dev": {Tool: "claude-code"}},
+			Tasks:     map[string]TaskConfig{"review": {Agent: "dev", Prompt: "Review " + tt.prompt}},
+			Preamble:  tt.preamble,
+			Variables: tt.variables,
+		})
+		if (tt.wantErrContains == "") != (err == nil) || (err != nil && !strings.Contains(err.Error(), tt.wantErrContains)) {
+			t.Errorf("Validate(%v, %q) error = %v, want %q", tt.variables, tt.prompt, err, tt.wantErrContains)
+		}
+	}
+}
+
+// TestValidate_Suggestions tests that errors for misspelled names suggest
+// the closest defined one.
+func TestValidate_Suggestions(t *testing.T) {
+	err := Validate(&AgentflowConfig{
+		Agents: map[string]AgentConfig{
+			"analyzer": {Tool: "claude-code"},
+			"writer":   {Tool: "claud-code"},
+		},
+		Tasks: map[string]TaskConfig{
+			"scan":   {Agent: "analizer", Prompt: "Scan"},
+			"report": {Agent: "writer", Prompt: "Summarize {{outputs.scna}} on {{git.brnach}}", Needs: []string{"scann"}},
+			"unique": {Agent: "analyzer", Prompt: "Use {{outputs.zzzzzz}}"},
+		},
+	})
+	var errs *ConfigErrors
+	if !errors.As(err, &errs) {
+		t.Fatalf("Validate() error = %v, want ConfigErrors", err)
+	}
+	for _, want := range []string{
+		`task "scan" references undefined agent "analizer" (did you mean "analyzer"?)`,
+		`agent "writer" uses unsupported tool "claud-code" (did you mean "claude-code"?)`,
+		`task "report" depends on undefined task "scann" (did you mean "scan"?)`,
+		`template references undefined task "scna" (did you mean "scan"?)`,
+		`template references unknown value "git.brnach" (did you mean "git.branch"?)`,
+	} {
+		if !errorsContain(errs, want) {
+			t.Errorf("errors missing %q:\n%v", want, errs)
+		}
+	}
+	for _, e := range errs.Errors {
+		if strings.Contains(e.Message, "zzzzzz") && e.Suggestion != "" {
+			t.Errorf("unrelated name got suggestion %q", e.Suggestion)
+		}
+	}
+}
+
+// TestValidateOffline tests which workflows can run without network access.
+func TestValidateOffline(t *testing.T) {
+	tests := []struct {
+		name            string
+		config          *AgentflowConfig
+		wantErrContains []string
+	}{
+		{
+			name: "local agents",
+			config: &AgentflowConfig{
+				Agents: map[string]AgentConfig{
+					"sh":    {Tool: "shell"},
+					"local": {Tool: "opencode", Model: "ollama/llama3.1"},
+				},
+				Tasks:     map[string]TaskConfig{"build": {Agent: "sh", Command: "make"}},
+				Retrieval: &RetrievalConfig{Provider: EmbedOpenAI, URL: "http://127.0.0.1:8080/v1"},
+			},
+		},
+		{
+			name: "hosted agents",
+			config: &AgentflowConfig{
+				Agents: map[string]AgentConfig{
+					"coder":  {Tool: "claude-code"},
+					"hosted": {Tool: "opencode", Model: "anthropic/claude-sonnet-4"},
+				},
+			},
+			wantErrContains: []string{`agent "coder"`, `agent "hosted"`},
+		},
+		{
+			name: "remote resources",
+			config: &AgentflowConfig{
+				Agents: map[string]AgentConfig{"sh": {Tool: "shell"}},
+				Tasks: map[string]TaskConfig{
+					"fix": {Agent: "sh", Command: "make fix", Write: true, Commit: &CommitConfig{PR: true}},
+				},
+				Retrieval: &RetrievalConfig{Provider: EmbedOllama, URL: "http://gpu.example.com:11434"},
+			},
+			wantErrContains: []string{"gpu.example.com", `task "fix"`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateOffline(tt.config, "Cortexfile.yml")
+			if len(tt.wantErrContains) == 0 {
+				if err != nil {
+					t.Errorf("ValidateOffline() error = %v, want nil", err)
+				}
+				return
+			}
+			errs, ok := err.(*ConfigErrors)
+			if !ok {
+				t.Fatalf("ValidateOffline() error = %v, want *ConfigErrors", err)
+			}
+			if len(errs.Errors) != len(tt.wantErrContains) {
+				t.Errorf("ValidateOffline() found %d errors, want %d: %v", len(errs.Errors), len(tt.wantErrContains), err)
+			}
+			for _, want := range tt.wantErrContains {
+				if !errorsContain(errs, want) {
+					t.Errorf("ValidateOffline() error = %v, want one containing %q", err, want)
+				}
+			}
+		})
+	}
+}
+
+func TestValidateNames(t *testing.T) {
+	cfg := &AgentflowConfig{
+		Agents: map[string]AgentConfig{
+			"sh":       {Tool: "shell"},
+			"my agent": {Tool: "shell"},
+		},
+		Tasks: map[string]TaskConfig{
+			"Analyze Backend": {Agent: "sh", Command: "true"},
+			"build_2":         {Agent: "sh", Command: "true"},
+			"-lint":           {Agent: "sh", Command: "true"},
+		},
+	}
+
+	err := ValidateWithFile(cfg, "Cortexfile.yml")
+	if err == nil {
+		t.Fatal("expected errors for invalid names")
+	}
+	msg := err.Error()
+	for _, want := range []string{
+		`agent name "my agent" may only contain`,
+		`Rename it to "my-agent"`,
+		`task name "Analyze Backend" may only contain`,
+		`Rename it to "analyze-backend"`,
+		`task name "-lint"`,
+	} {
+		if !strings.Contains(msg, want) {
+			t.Errorf("error missing %q:\n%s", want, msg)
+		}
+	}
+	if strings.Contains(msg, "build_2") {
+		t.Errorf("valid name build_2 reported:\n%s", msg)
+	}
+}
+
+func TestNormalizeName(t *testing.T) {
+	tests := map[string]string{
+		"Analyze Backend":    "analyze-backend",
+		"  résumé -- draft ": "resume-draft",
+		"lint.go":            "lint-go",
+		"_private":           "private",
+		"日本":                 "",
+	}
+	for in, want := range tests {
+		if got := NormalizeName(in); got != want {
+			t.Errorf("NormalizeName(%q) = %q, want %q", in, got, want)
+		}
+		if got := NormalizeName(in); got != "" && !IsValidName(got) {
+			t.Errorf("NormalizeName(%q) = %q, which isn't valid", in, got)
+		}
+	}
+}
+
+func TestWarningsDisconnected(t *testing.T) {
+	cfg := &AgentflowConfig{Tasks: map[string]TaskConfig{
+		"analyze": {},
+		"build":   {Needs: []string{"analyze"}},
+		"deploy":  {},
+		"notify":  {Needs: []string{"deploy"}},
+		"lone":    {},
+	}}
+	warnings := Warnings(cfg, SettingsConfig{}, "Cortexfile.yml")
+	if len(warnings) != 1 {
+		t.Fatalf("got %d warnings, want 1: %v", len(warnings), warnings)
+	}
+	want := "tasks form 3 unconnected groups: [analyze, build], [deploy, notify], [lone]"
+	if warnings[0].Message != want {
+		t.Errorf("message = %q, want %q", warnings[0].Message, want)
+	}
+
+	// Independent tasks are intentional
+	cfg.Tasks = map[string]TaskConfig{"lint": {}, "test": {}, "vet": {}}
+	if warnings := Warnings(cfg, SettingsConfig{}, "Cortexfile.yml"); len(warnings) != 0 {
+		t.Errorf("independent tasks: got %v", warnings)
+	}
+
+	// One connected graph
+	cfg.Tasks = map[string]TaskConfig{"a": {}, "b": {Needs: []string{"a"}}, "c": {Needs: []string{"a"}}}
+	if warnings := Warnings(cfg, SettingsConfig{}, "Cortexfile.yml"); len(warnings) != 0 {
+		t.Errorf("connected tasks: got %v", warnings)
+	}
+}
+
+func TestWarningsDepthAndFanIn(t *testing.T) {
+	cfg := &AgentflowConfig{Tasks: map[string]TaskConfig{
+		"a":      {},
+		"b":      {Needs: []string{"a"}},
+		"c":      {Needs: []string{"b"}},
+		"d":      {Needs: []string{"c", "a"}},
+		"report": {Needs: []string{"a", "b", "c"}},
+	}}
+
+	warnings := Warnings(cfg, SettingsConfig{MaxDepth: 3, MaxNeeds: 2}, "Cortexfile.yml")
+	var messages []string
+	for _, w := range warnings {
+		messages = append(messages, w.Message)
+	}
+	want := []string{
+		`task "d" ends a chain of 4 dependent tasks (max_depth 3): a -> b -> c -> d`,
+		`task "report" needs 3 tasks (max_needs 2)`,
+	}
+	if !slices.Equal(messages, want) {
+		t.Errorf("warnings = %q, want %q", messages, want)
+	}
+
+	// Defaults are high enough for this workflow, and negative turns them off
+	if warnings := Warnings(cfg, SettingsConfig{}, "Cortexfile.yml"); len(warnings) != 0 {
+		t.Errorf("default thresholds: got %v", warnings)
+	}
+	if warnings := Warnings(cfg, SettingsConfig{MaxDepth: -1, MaxNeeds: -1}, "Cortexfile.yml"); len(warnings) != 0 {
+		t.Errorf("thresholds off: got %v", warnings)
+	}
+}
+
+// TestValidate_PromptFile tests that loaded prompt files validate like
+// inline prompts, and that missing ones are reported with their path.
+func TestValidate_PromptFile(t *testing.T) {
+	dir := t.TempDir()
+	workdir := filepath.Join(dir, "app")
+	for path, content := range map[string]string{
+		filepath.Join(dir, "review.md"):     "Review {{outputs.scan}}",
+		filepath.Join(workdir, "triage.md"): "Triage {{outputs.scan}}",
+	} {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	load := func(tasks string) *AgentflowConfig {
+		t.Helper()
+		cfg, err := ParseConfig([]byte("workdir: "+workdir+"\nagents:\n  ai: {tool: claude-code}\ntasks:\n  scan: {agent: ai, prompt: Scan}\n"+tasks), dir)
+		if err != nil {
+			t.Fatalf("ParseConfig() error = %v", err)
+		}
+		return cfg
+	}
+
+	cfg := load("  review: {agent: ai, prompt_file: review.md, needs: [scan]}\n  triage: {agent: ai, prompt_file: triage.md, needs: [scan]}\n")
+	if err := ValidateWithFile(cfg, filepath.Join(dir, "Cortexfile.yml")); err != nil {
+		t.Fatalf("ValidateWithFile() error = %v", err)
+	}
+	if got := cfg.Tasks["triage"].Prompt; got != "Triage {{outputs.scan}}" {
+		t.Errorf("prompt_file in the workdir loaded %q", got)
+	}
+
+	// Placeholders in prompt files are checked like inline ones
+	cfg = load("  review: {agent: ai, prompt_file: review.md}\n")
+	if err := ValidateWithFile(cfg, ""); err == nil || !strings.Contains(err.Error(), "scan") {
+		t.Errorf("ValidateWithFile() with an output not needed: %v", err)
+	}
+
+	cfg = load("  review: {agent: ai, prompt_file: prompts/missing.md}\n")
+	err := ValidateWithFile(cfg, "")
+	var errs *ConfigErrors
+	if !errors.As(err, &errs) || len(errs.Errors) != 1 || errs.Errors[0].Code != CodePromptFileNotFound ||
+		!strings.Contains(err.Error(), filepath.Join(dir, "prompts", "missing.md")) {
+		t.Errorf("ValidateWithFile() with a missing prompt_file: %v", err)
+	}
+
+	cfg = load("  review: {agent: ai, prompt: Review, prompt_file: review.md}\n")
+	if err := ValidateWithFile(cfg, ""); err == nil || !strings.Contains(err.Error(), "cannot have both 'prompt' and 'prompt_file'") {
+		t.Errorf("ValidateWithFile() with prompt and prompt_file: %v", err)
+	}
+}
+
+// TestValidate_Workdir tests that a task's workdir must be a directory or
+// creatable as one.
+func TestValidate_Workdir(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "notes.txt"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		workdir string
+		wantErr string
+	}{
+		{"existing", ".", ""},
+		{"missing", "services/api", ""},
+		{"absolute", filepath.Join(dir, "abs"), ""},
+		{"file", "notes.txt", "is not a directory"},
+		{"under a file", "notes.txt/api", "cannot be created"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &AgentflowConfig{
+				Workdir: dir,
+				Agents:  map[string]AgentConfig{"ai": {Tool: "claude-code"}},
+				Tasks:   map[string]TaskConfig{"build": {Agent: "ai", Prompt: "Build", Workdir: tt.workdir}},
+			}
+			err := ValidateWithFile(cfg, "")
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("ValidateWithFile() error = %v", err)
+				}
+				return
+			}
+			var errs *ConfigErrors
+			if !errors.As(err, &errs) || errs.Errors[0].Code != CodeInvalidWorkdir || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("ValidateWithFile() error = %v, want %s", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// TestValidate_AgentInstructions tests that agents' instructions are
+// checked like the preamble, and that shell agents can't have them.
+func TestValidate_AgentInstructions(t *testing.T) {
+	tests := []struct {
+		name            string
+		agent           AgentConfig
+		wantErrContains string
+	}{
+		{
+			name:  "instructions and system prompt",
+			agent: AgentConfig{Tool: "claude-code", SystemPrompt: "You are terse.", Instructions: "You are {{task.agent}}; the branch is {{git.branch}}."},
+		},
+		{
+			name:            "output reference",
+			agent:           AgentConfig{Tool: "claude-code", Instructions: "Build said {{outputs.build}}"},
+			wantErrContains: `agent "coder" instructions: cannot reference task outputs`,
+		},
+		{
+			name:            "shell agent",
+			agent:           AgentConfig{Tool: "shell", SystemPrompt: "You are terse."},
+			wantErrContains: `agent "coder": shell agents take no 'system_prompt' or 'instructions'`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			task := TaskConfig{Agent: "coder", Prompt: "Fix it"}
+			if tt.agent.Tool == "shell" {
+				task = TaskConfig{Agent: "coder", Command: "make"}
+			}
+			err := Validate(&AgentflowConfig{
+				Agents: map[string]AgentConfig{"coder": tt.agent},
+				Tasks:  map[string]TaskConfig{"fix": task},
+			})
+			if tt.wantErrContains == "" {
+				if err != nil {
+					t.Errorf("Validate() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErrContains) {
+				t.Errorf("Validate() error = %v, want %q", err, tt.wantErrContains)
+			}
+		})
+	}
+}
@@ -0,0 +1,92 @@
+package config
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// CurrentVersion is the Cortexfile layout this build reads, declared with
+// version: at the top of a file. Files without one are read as version 1,
+// the layout every Cortexfile had before the field existed.
+const CurrentVersion = 1
+
+// migration updates a Cortexfile from layout From to From+1.
+type migration struct {
+	From int
+
+	// Description says what changed, as documented in the README.
+	Description string
+
+	// Apply rewrites the document's root mapping where that's safe, and
+	// returns a deprecation for each change it can't make, e.g. because
+	// the meaning of the old form depends on how the file is used. Those
+	// are left for the user to make, and the file loads as it was.
+	Apply func(root *yaml.Node) []*ConfigError
+}
+
+// migrations are applied in order to files older than CurrentVersion,
+// each to files of its From version or older. A layout change adds an
+// entry here, with a section in the README, and bumps CurrentVersion.
+var migrations []migration
+
+// migrate reads the version of doc, rejecting versions this build doesn't
+// know, and brings older layouts up to CurrentVersion. It returns a
+// deprecation for each migration applied, saying what to change so the
+// file no longer needs it, and those its migrations couldn't apply.
+func migrate(doc *yaml.Node) ([]*ConfigError, error) {
+	root := doc
+	if root.Kind == yaml.DocumentNode && len(root.Content) > 0 {
+		root = root.Content[0]
+	}
+	if root.Kind != yaml.MappingNode {
+		return nil, nil // Empty, or left for decoding to report
+	}
+
+	version, line := 1, 0
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		key, value := root.Content[i], root.Content[i+1]
+		if key.Value != "version" {
+			continue
+		}
+		n, err := strconv.Atoi(value.Value)
+		if value.Kind != yaml.ScalarNode || err != nil || n < 1 {
+			return nil, NewCodedError(CodeUnsupportedVersion, "", value.Line,
+				fmt.Sprintf("invalid version %q: must be a whole number from 1 to %d", value.Value, CurrentVersion),
+				fmt.Sprintf("Use version: %d, or leave it out", CurrentVersion))
+		}
+		if n > CurrentVersion {
+			return nil, NewCodedError(CodeUnsupportedVersion, "", value.Line,
+				fmt.Sprintf("version %d is newer than this cortex reads (up to %d)", n, CurrentVersion),
+				"Upgrade cortex to run this Cortexfile")
+		}
+		version, line = n, value.Line
+	}
+
+	var deprecations []*ConfigError
+	for _, m := range migrations {
+		if m.From < version {
+			continue
+		}
+		deprecations = append(deprecations, m.Apply(root)...)
+		deprecations = append(deprecations, NewCodedError(CodeDeprecated, "", line,
+			fmt.Sprintf("version %d layout migrated on load: %s", m.From, m.Description),
+			fmt.Sprintf("Update the file and set version: %d", m.From+1)))
+	}
+	return deprecations, nil
+}
+
+// legacyName returns a deprecation if path has the Agentfile name
+// Cortexfiles had before the rename, or nil.
+func legacyName(path string) *ConfigError {
+	name := filepath.Base(path)
+	if !strings.HasPrefix(name, "Agentfile.") {
+		return nil
+	}
+	return NewCodedError(CodeDeprecated, path, 0,
+		fmt.Sprintf("%s is the old name of the Cortexfile", name),
+		"Rename it to Cortex"+strings.TrimPrefix(name, "Agent"))
+}
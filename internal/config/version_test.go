@@ -0,0 +1,101 @@
+package config
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestVersion(t *testing.T) {
+	for _, tt := range []struct {
+		version string
+		wantErr string
+	}{
+		{"", ""},
+		{"version: 1\n", ""},
+		{"version: 2\n", "newer than this cortex reads"},
+		{"version: 0\n", "invalid version"},
+		{"version: one\n", "invalid version"},
+	} {
+		_, err := ParseConfig([]byte(tt.version+"agents:\n  sh: {tool: shell}\n"), ".")
+		switch {
+		case tt.wantErr == "" && err != nil:
+			t.Errorf("%q: unexpected error: %v", tt.version, err)
+		case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+			t.Errorf("%q: error = %v, want %q", tt.version, err, tt.wantErr)
+		case tt.wantErr != "" && ErrorCode(err) != CodeUnsupportedVersion:
+			t.Errorf("%q: code = %q, want %s", tt.version, ErrorCode(err), CodeUnsupportedVersion)
+		}
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	// A layout change renaming tasks' run: to command:
+	saved := migrations
+	t.Cleanup(func() { migrations = saved })
+	migrations = []migration{{
+		From:        1,
+		Description: "tasks' run is now command",
+		Apply: func(root *yaml.Node) []*ConfigError {
+			for i := 0; i+1 < len(root.Content); i += 2 {
+				if root.Content[i].Value != "tasks" {
+					continue
+				}
+				tasks := root.Content[i+1]
+				for j := 1; j < len(tasks.Content); j += 2 {
+					task := tasks.Content[j]
+					for k := 0; k < len(task.Content); k += 2 {
+						if task.Content[k].Value == "run" {
+							task.Content[k].Value = "command"
+						}
+					}
+				}
+			}
+			return nil
+		},
+	}}
+
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"Agentfile.yml": `
+include: lint.yml
+agents:
+  sh: {tool: shell}
+tasks:
+  build: {agent: sh, run: make}
+`,
+		"lint.yml": `
+tasks:
+  lint: {agent: sh, run: make lint}
+`,
+	})
+	cfg, err := LoadConfig(filepath.Join(dir, "Agentfile.yml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := cfg.Tasks["build"].Command; got != "make" {
+		t.Errorf("build command = %q, want the migrated run", got)
+	}
+	if got := cfg.Tasks["lint"].Command; got != "make lint" {
+		t.Errorf("lint command = %q, want the migrated run", got)
+	}
+
+	// One for each file migrated, and one for the old file name
+	var got []string
+	for _, d := range cfg.Deprecations {
+		if d.Code != CodeDeprecated {
+			t.Errorf("deprecation code = %q, want %s", d.Code, CodeDeprecated)
+		}
+		got = append(got, filepath.Base(d.File)+": "+d.Message)
+	}
+	want := []string{
+		"lint.yml: version 1 layout migrated on load: tasks' run is now command",
+		"Agentfile.yml: version 1 layout migrated on load: tasks' run is now command",
+		"Agentfile.yml: Agentfile.yml is the old name of the Cortexfile",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("deprecations:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
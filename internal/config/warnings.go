@@ -0,0 +1,168 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Default thresholds for the depth and fan-in warnings.
+const (
+	DefaultMaxDepth = 10
+	DefaultMaxNeeds = 8
+)
+
+// Warnings reports likely mistakes in a valid configuration: things that
+// run, but usually not as intended. They are shown by 'cortex validate'
+// and don't fail it. settings supplies the thresholds (MaxDepth, MaxNeeds).
+func Warnings(config *AgentflowConfig, settings SettingsConfig, filePath string) []*ConfigError {
+	var warnings []*ConfigError
+	warnings = append(warnings, warnModels(config, filePath)...)
+	if w := warnDisconnected(config.Tasks, filePath); w != nil {
+		warnings = append(warnings, w)
+	}
+	if limit := threshold(settings.MaxDepth, DefaultMaxDepth); limit > 0 {
+		if w := warnDepth(config.Tasks, limit, filePath); w != nil {
+			warnings = append(warnings, w)
+		}
+	}
+	if limit := threshold(settings.MaxNeeds, DefaultMaxNeeds); limit > 0 {
+		warnings = append(warnings, warnFanIn(config.Tasks, limit, filePath)...)
+	}
+	return warnings
+}
+
+// threshold returns value, def if it's 0, or 0 (off) if it's negative.
+func threshold(value, def int) int {
+	switch {
+	case value < 0:
+		return 0
+	case value == 0:
+		return def
+	}
+	return value
+}
+
+// warnDepth warns about the longest dependency chain if it has more than
+// limit tasks: each waits for the one before, so long chains are slow, and
+// a failure far down one is hard to trace back.
+func warnDepth(tasks map[string]TaskConfig, limit int, filePath string) *ConfigError {
+	chain := longestChain(tasks)
+	if len(chain) <= limit {
+		return nil
+	}
+	return NewCodedError(CodeLongChain, filePath, 0,
+		fmt.Sprintf("task %q ends a chain of %d dependent tasks (max_depth %d): %s",
+			chain[len(chain)-1], len(chain), limit, strings.Join(chain, " -> ")),
+		"Let steps that don't need each other run in parallel, or raise settings.max_depth")
+}
+
+// longestChain returns the longest run of tasks each needing the one
+// before, first task first. Ties go to the chain ending in the first name.
+func longestChain(tasks map[string]TaskConfig) []string {
+	chains := make(map[string][]string)
+	var chainTo func(name string) []string
+	chainTo = func(name string) []string {
+		if chain, ok := chains[name]; ok {
+			return chain
+		}
+		chains[name] = []string{name} // Guards against cycles
+		var longest []string
+		for _, dep := range tasks[name].Needs {
+			if _, ok := tasks[dep]; !ok {
+				continue
+			}
+			if chain := chainTo(dep); len(chain) > len(longest) {
+				longest = chain
+			}
+		}
+		chain := append(append([]string{}, longest...), name)
+		chains[name] = chain
+		return chain
+	}
+
+	var longest []string
+	for _, name := range sortedNames(tasks) {
+		if chain := chainTo(name); len(chain) > len(longest) {
+			longest = chain
+		}
+	}
+	return longest
+}
+
+// warnFanIn warns about tasks that need more than limit others, whose
+// prompts pull in many outputs and whose failures have many suspects.
+func warnFanIn(tasks map[string]TaskConfig, limit int, filePath string) []*ConfigError {
+	var warnings []*ConfigError
+	for _, name := range sortedNames(tasks) {
+		if needs := len(tasks[name].Needs); needs > limit {
+			warnings = append(warnings, NewCodedError(CodeHighFanIn, filePath, 0,
+				fmt.Sprintf("task %q needs %d tasks (max_needs %d)", name, needs, limit),
+				"Combine related inputs in an intermediate task, or raise settings.max_needs"))
+		}
+	}
+	return warnings
+}
+
+// warnDisconnected warns when tasks split into groups with no dependency
+// between them, which often means a forgotten 'needs'. Workflows where no
+// task needs another are left alone, as their tasks are meant to be
+// independent.
+func warnDisconnected(tasks map[string]TaskConfig, filePath string) *ConfigError {
+	components := taskComponents(tasks)
+	if len(components) < 2 || len(components[0]) < 2 {
+		return nil
+	}
+
+	listed := make([]string, len(components))
+	for i, c := range components {
+		listed[i] = "[" + strings.Join(c, ", ") + "]"
+	}
+	return NewCodedError(CodeDisconnected, filePath, 0,
+		fmt.Sprintf("tasks form %d unconnected groups: %s", len(components), strings.Join(listed, ", ")),
+		"Tasks in different groups never wait for each other; add 'needs' if one should run after another")
+}
+
+// taskComponents splits tasks into groups connected by 'needs' in either
+// direction, largest first. Each group is sorted by name. Needs on tasks
+// that don't exist are ignored, as validation reports them.
+func taskComponents(tasks map[string]TaskConfig) [][]string {
+	neighbors := make(map[string][]string)
+	for name, task := range tasks {
+		for _, dep := range task.Needs {
+			if _, ok := tasks[dep]; ok {
+				neighbors[name] = append(neighbors[name], dep)
+				neighbors[dep] = append(neighbors[dep], name)
+			}
+		}
+	}
+
+	seen := make(map[string]bool)
+	var components [][]string
+	for _, name := range sortedNames(tasks) {
+		if seen[name] {
+			continue
+		}
+		var component []string
+		stack := []string{name}
+		seen[name] = true
+		for len(stack) > 0 {
+			current := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			component = append(component, current)
+			for _, next := range neighbors[current] {
+				if !seen[next] {
+					seen[next] = true
+					stack = append(stack, next)
+				}
+			}
+		}
+		sort.Strings(component)
+		components = append(components, component)
+	}
+
+	sort.SliceStable(components, func(i, j int) bool {
+		return len(components[i]) > len(components[j])
+	})
+	return components
+}
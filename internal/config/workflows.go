@@ -0,0 +1,157 @@
+package config
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// WorkflowNames returns the names of the workflows cfg defines, in order.
+func WorkflowNames(cfg *AgentflowConfig) []string {
+	return sortedNames(cfg.Workflows)
+}
+
+// SelectWorkflow makes the tasks of the named workflow cfg's tasks, so the
+// rest of Cortex sees a Cortexfile with just that workflow. With no name,
+// the top-level tasks are kept; if there are none, a Cortexfile with a
+// single workflow selects it.
+func SelectWorkflow(cfg *AgentflowConfig, name string) error {
+	names := WorkflowNames(cfg)
+	if name == "" {
+		if len(cfg.Tasks) > 0 || len(names) == 0 {
+			cfg.Workflows = nil
+			return nil
+		}
+		if len(names) > 1 {
+			return fmt.Errorf("no workflow selected; run one of: %s", strings.Join(names, ", "))
+		}
+		name = names[0]
+	}
+
+	workflow, ok := cfg.Workflows[name]
+	if !ok {
+		return errUnknownName("workflow", name, names)
+	}
+	unselected := make(map[string]bool)
+	for task := range cfg.Tasks {
+		unselected[task] = true
+	}
+	for _, w := range cfg.Workflows {
+		for task := range w.Tasks {
+			unselected[task] = true
+		}
+	}
+	for task := range workflow.Tasks {
+		delete(unselected, task)
+	}
+	cfg.Tasks = workflow.Tasks
+	if cfg.Tasks == nil {
+		cfg.Tasks = make(map[string]TaskConfig)
+	}
+	// The workflow's tasks come from wherever the workflow was defined
+	origin, included := cfg.Sources["workflows."+name]
+	for task := range cfg.Sources {
+		if strings.HasPrefix(task, "tasks.") || strings.HasPrefix(task, "groups.") {
+			delete(cfg.Sources, task)
+		}
+	}
+	if included {
+		for task := range cfg.Tasks {
+			cfg.Sources["tasks."+task] = origin
+		}
+		for group := range workflow.Groups {
+			cfg.Sources["groups."+group] = origin
+		}
+	}
+	cfg.Groups = workflow.Groups
+	if workflow.Schedule != nil {
+		settings := SettingsConfig{}
+		if cfg.Settings != nil {
+			settings = *cfg.Settings
+		}
+		settings.Schedule = workflow.Schedule
+		cfg.Settings = &settings
+	}
+	cfg.Workflows = nil
+	pruneDigest(cfg, unselected)
+	return nil
+}
+
+// SelectGroup keeps only the tasks of the named group and the tasks they
+// need, directly or indirectly, so a group can list just its entrypoints.
+func SelectGroup(cfg *AgentflowConfig, name string) error {
+	group, ok := cfg.Groups[name]
+	if !ok {
+		return errUnknownName("group", name, sortedNames(cfg.Groups))
+	}
+
+	keep := make(map[string]bool)
+	var visit func(task string) error
+	visit = func(task string) error {
+		if keep[task] {
+			return nil
+		}
+		t, ok := cfg.Tasks[task]
+		if !ok {
+			return ErrUndefinedGroupTask("", 0, name, task, sortedNames(cfg.Tasks))
+		}
+		keep[task] = true
+		for _, dep := range t.Needs {
+			if _, exists := cfg.Tasks[dep]; exists {
+				if err := visit(dep); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+	for _, task := range group {
+		if err := visit(task); err != nil {
+			return err
+		}
+	}
+
+	tasks := make(map[string]TaskConfig, len(keep))
+	unselected := make(map[string]bool)
+	for task := range cfg.Tasks {
+		if keep[task] {
+			tasks[task] = cfg.Tasks[task]
+		} else {
+			unselected[task] = true
+		}
+	}
+	cfg.Tasks = tasks
+	cfg.Groups = nil
+	pruneDigest(cfg, unselected)
+	return nil
+}
+
+// pruneDigest drops the unselected tasks from the digest's list, leaving
+// names that aren't defined at all for validation to report. A digest of
+// only unselected tasks isn't written.
+func pruneDigest(cfg *AgentflowConfig, unselected map[string]bool) {
+	if cfg.Digest == nil || len(cfg.Digest.Tasks) == 0 {
+		return
+	}
+	digest := *cfg.Digest
+	digest.Tasks = slices.DeleteFunc(slices.Clone(digest.Tasks), func(task string) bool {
+		return unselected[task]
+	})
+	if len(digest.Tasks) == 0 {
+		cfg.Digest = nil
+		return
+	}
+	cfg.Digest = &digest
+}
+
+// errUnknownName reports a workflow or group name that isn't defined,
+// suggesting the closest of names.
+func errUnknownName(kind, name string, names []string) error {
+	if len(names) == 0 {
+		return fmt.Errorf("unknown %s %q: the Cortexfile defines no %ss", kind, name, kind)
+	}
+	if suggestions := DidYouMean(name, names); suggestions != "" {
+		return fmt.Errorf("unknown %s %q: did you mean %s? Available %ss: %s", kind, name, suggestions, kind, strings.Join(names, ", "))
+	}
+	return fmt.Errorf("unknown %s %q: available %ss: %s", kind, name, kind, strings.Join(names, ", "))
+}
@@ -0,0 +1,124 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSelectWorkflow(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "release.md"), []byte("Cut a release"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	data := []byte(`
+agents:
+  coder: {tool: claude-code}
+workflows:
+  review:
+    tasks:
+      analyze: {agent: coder, prompt: Review the diff}
+  release:
+    description: Tag and publish
+    tasks:
+      tag: {agent: coder, prompt_file: release.md}
+`)
+	load := func() *AgentflowConfig {
+		cfg, err := ParseConfig(data, dir)
+		if err != nil {
+			t.Fatalf("ParseConfig() error = %v", err)
+		}
+		return cfg
+	}
+
+	if got := WorkflowNames(load()); strings.Join(got, ",") != "release,review" {
+		t.Errorf("WorkflowNames() = %v", got)
+	}
+
+	cfg := load()
+	if err := SelectWorkflow(cfg, "release"); err != nil {
+		t.Fatalf("SelectWorkflow(release) error = %v", err)
+	}
+	if len(cfg.Tasks) != 1 || cfg.Tasks["tag"].Prompt != "Cut a release" || cfg.Workflows != nil {
+		t.Errorf("after SelectWorkflow(release), tasks = %+v, workflows = %v", cfg.Tasks, cfg.Workflows)
+	}
+
+	if err := SelectWorkflow(load(), ""); err == nil || !strings.Contains(err.Error(), "release, review") {
+		t.Errorf("SelectWorkflow() with several workflows error = %v, want list of workflows", err)
+	}
+	if err := SelectWorkflow(load(), "reveiw"); err == nil || !strings.Contains(err.Error(), `did you mean "review"`) {
+		t.Errorf("SelectWorkflow(reveiw) error = %v, want suggestion", err)
+	}
+
+	// Top-level tasks run when no workflow is named
+	cfg = load()
+	cfg.Tasks["lint"] = TaskConfig{Agent: "coder", Prompt: "Lint"}
+	if err := SelectWorkflow(cfg, ""); err != nil || len(cfg.Tasks) != 1 || cfg.Tasks["lint"].Prompt == "" {
+		t.Errorf("SelectWorkflow() with top-level tasks = %v, tasks %+v", err, cfg.Tasks)
+	}
+}
+
+func TestSelectGroup(t *testing.T) {
+	load := func() *AgentflowConfig {
+		return &AgentflowConfig{
+			Agents: map[string]AgentConfig{"coder": {Tool: "claude-code"}},
+			Tasks: map[string]TaskConfig{
+				"analyze":     {Agent: "coder", Prompt: "Analyze"},
+				"review":      {Agent: "coder", Prompt: "Review {{outputs.analyze}}", Needs: StringList{"analyze"}},
+				"implement":   {Agent: "coder", Prompt: "Implement", Needs: StringList{"review"}},
+				"lint-review": {Agent: "coder", Prompt: "Lint"},
+			},
+			Groups: map[string]StringList{
+				"quick": {"lint-review"},
+				"full":  {"implement"},
+			},
+		}
+	}
+
+	cfg := load()
+	if err := SelectGroup(cfg, "quick"); err != nil || len(cfg.Tasks) != 1 {
+		t.Errorf("SelectGroup(quick) = %v, tasks %v", err, cfg.Tasks)
+	}
+
+	// Needed tasks come along
+	cfg = load()
+	if err := SelectGroup(cfg, "full"); err != nil {
+		t.Fatalf("SelectGroup(full) error = %v", err)
+	}
+	if len(cfg.Tasks) != 3 || cfg.Groups != nil {
+		t.Errorf("SelectGroup(full) tasks = %v, groups = %v", cfg.Tasks, cfg.Groups)
+	}
+	if err := Validate(cfg); err != nil {
+		t.Errorf("Validate() after SelectGroup(full) error = %v", err)
+	}
+
+	if err := SelectGroup(load(), "qiuck"); err == nil || !strings.Contains(err.Error(), `did you mean "quick"`) {
+		t.Errorf("SelectGroup(qiuck) error = %v, want suggestion", err)
+	}
+
+	cfg = load()
+	cfg.Groups["broken"] = StringList{"deploy"}
+	if err := Validate(cfg); err == nil || !strings.Contains(err.Error(), `group "broken" lists undefined task "deploy"`) {
+		t.Errorf("Validate() with undefined group task error = %v", err)
+	}
+
+	// The digest keeps the selected tasks, and names that aren't defined
+	cfg = load()
+	cfg.Digest = &DigestConfig{Path: "digest.md", Tasks: StringList{"lint-review", "review", "deploy"}}
+	if err := SelectGroup(cfg, "full"); err != nil {
+		t.Fatalf("SelectGroup(full) error = %v", err)
+	}
+	if got := strings.Join(cfg.Digest.Tasks, ","); got != "review,deploy" {
+		t.Errorf("digest tasks = %s, want review,deploy", got)
+	}
+	if err := Validate(cfg); err == nil || !strings.Contains(err.Error(), `digest lists undefined task "deploy"`) {
+		t.Errorf("Validate() with undefined digest task error = %v", err)
+	}
+
+	cfg = load()
+	cfg.Digest = &DigestConfig{Path: "digest.md", Tasks: StringList{"implement"}}
+	if err := SelectGroup(cfg, "quick"); err != nil || cfg.Digest != nil {
+		t.Errorf("SelectGroup(quick) = %v, digest %+v, want no digest", err, cfg.Digest)
+	}
+}
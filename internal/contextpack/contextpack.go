@@ -0,0 +1,340 @@
+// Package contextpack builds a compact summary of a repository (layout,
+// language mix, and key files) that can be shared with every agent in a run,
+// so each one doesn't have to explore the codebase from scratch.
+package contextpack
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/adityaraj/agentflow/internal/git"
+	"github.com/adityaraj/agentflow/internal/textfile"
+)
+
+const (
+	// FileName is the name of the context pack saved in each run directory.
+	FileName = "context-repo.md"
+
+	// maxDepth is how many directory levels the layout shows.
+	maxDepth = 3
+
+	// maxEntries caps the entries listed per directory; the rest are counted.
+	maxEntries = 12
+
+	// maxLanguages caps the languages listed in the stats table.
+	maxLanguages = 10
+
+	// maxKeyFileLines and maxKeyFileBytes cap what is quoted from each
+	// key file.
+	maxKeyFileLines = 40
+	maxKeyFileBytes = 4096
+
+	// maxCountSize skips counting lines in files larger than this.
+	maxCountSize = 1 << 20
+
+	// maxSize caps the whole pack, so it stays cheap to embed in prompts.
+	maxSize = 16 * 1024
+)
+
+// skipDirs are directories never worth summarizing when the tree isn't a git
+// repository (git already leaves ignored directories out).
+var skipDirs = map[string]bool{
+	".git": true, ".hg": true, ".svn": true, ".agentflow": true,
+	"node_modules": true, "vendor": true, "dist": true, "build": true,
+	"target": true, ".venv": true, "venv": true, "__pycache__": true,
+	".idea": true, ".vscode": true,
+}
+
+// keyFiles are the root files quoted in the pack, in order of usefulness.
+var keyFiles = []string{
+	"README.md", "README", "README.rst",
+	"go.mod", "package.json", "Cargo.toml", "pyproject.toml", "requirements.txt",
+	"Gemfile", "pom.xml", "build.gradle", "Makefile", "Dockerfile",
+	"CONTRIBUTING.md",
+}
+
+// languages maps file extensions to language names.
+var languages = map[string]string{
+	".go": "Go", ".py": "Python", ".js": "JavaScript", ".jsx": "JavaScript",
+	".mjs": "JavaScript", ".ts": "TypeScript", ".tsx": "TypeScript",
+	".rs": "Rust", ".java": "Java", ".kt": "Kotlin", ".rb": "Ruby",
+	".php": "PHP", ".cs": "C#", ".c": "C", ".h": "C", ".cpp": "C++",
+	".cc": "C++", ".hpp": "C++", ".swift": "Swift", ".scala": "Scala",
+	".sh": "Shell", ".bash": "Shell", ".sql": "SQL", ".html": "HTML",
+	".css": "CSS", ".scss": "CSS", ".vue": "Vue", ".svelte": "Svelte",
+	".md": "Markdown", ".yaml": "YAML", ".yml": "YAML", ".json": "JSON",
+	".toml": "TOML", ".proto": "Protobuf", ".tf": "Terraform",
+}
+
+// langStat counts the files and lines of one language.
+type langStat struct {
+	name  string
+	files int
+	lines int
+}
+
+// Generate summarizes the repository at dir as Markdown. In a git working
+// tree only tracked and unignored files are considered. Key files that
+// can't be quoted, being binary, are left out and returned as skipped.
+func Generate(dir string) (pack string, skipped []error, err error) {
+	files, err := ListFiles(dir)
+	if err != nil {
+		return "", nil, err
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Repository: %s\n\n", filepath.Base(dir))
+	fmt.Fprintf(&b, "%d files.\n\n", len(files))
+
+	if stats := languageStats(dir, files); len(stats) > 0 {
+		b.WriteString("## Languages\n\n")
+		total := 0
+		for _, s := range stats {
+			total += s.lines
+		}
+		for i, s := range stats {
+			if i == maxLanguages {
+				fmt.Fprintf(&b, "- (%d more)\n", len(stats)-maxLanguages)
+				break
+			}
+			pct := 0
+			if total > 0 {
+				pct = s.lines * 100 / total
+			}
+			fmt.Fprintf(&b, "- %s: %d files, %d lines (%d%%)\n", s.name, s.files, s.lines, pct)
+		}
+		b.WriteString("\n")
+	}
+
+	b.WriteString("## Layout\n\n```\n")
+	writeTree(&b, buildTree(files), "", 1)
+	b.WriteString("```\n")
+
+	ignore, err := LoadIgnore(dir)
+	if err != nil {
+		return "", nil, err
+	}
+	for _, name := range keyFiles {
+		if ignore.Ignored(name) {
+			continue
+		}
+		excerpt, err := readHead(filepath.Join(dir, name), maxKeyFileLines)
+		if errors.Is(err, textfile.ErrBinary) {
+			skipped = append(skipped, err)
+		}
+		if err != nil || excerpt == "" {
+			continue
+		}
+		section := fmt.Sprintf("\n## %s\n\n```\n%s\n```\n", name, excerpt)
+		if b.Len()+len(section) > maxSize {
+			break
+		}
+		b.WriteString(section)
+	}
+
+	return b.String(), skipped, nil
+}
+
+// ListFiles returns the slash-separated paths of the files under dir,
+// leaving out those .cortexignore files ignore. In a git working tree
+// ignored files are left out too; elsewhere common dependency and build
+// directories are skipped.
+func ListFiles(dir string) ([]string, error) {
+	ignore, err := LoadIgnore(dir)
+	if err != nil {
+		return nil, err
+	}
+	if git.IsRepo(dir) {
+		if files, err := git.ListFiles(dir); err == nil {
+			if err := ignore.AddNestedOf(dir, files); err != nil {
+				return nil, err
+			}
+			return ignore.Filter(files), nil
+		}
+	}
+
+	var files []string
+	err = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil // Unreadable entries are left out
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return nil
+		}
+		rel = filepath.ToSlash(rel)
+		if d.IsDir() {
+			if path == dir {
+				return nil
+			}
+			if skipDirs[d.Name()] || ignore.Ignored(rel+"/") {
+				return filepath.SkipDir
+			}
+			return ignore.AddNested(dir, rel)
+		}
+		if !ignore.Ignored(rel) {
+			files = append(files, rel)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list %s: %w", dir, err)
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// languageStats counts files and lines per language, most lines first.
+func languageStats(dir string, files []string) []langStat {
+	byName := make(map[string]*langStat)
+	for _, f := range files {
+		name, ok := languages[strings.ToLower(filepath.Ext(f))]
+		if !ok {
+			continue
+		}
+		s := byName[name]
+		if s == nil {
+			s = &langStat{name: name}
+			byName[name] = s
+		}
+		s.files++
+		s.lines += countLines(filepath.Join(dir, filepath.FromSlash(f)))
+	}
+
+	stats := make([]langStat, 0, len(byName))
+	for _, s := range byName {
+		stats = append(stats, *s)
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].lines != stats[j].lines {
+			return stats[i].lines > stats[j].lines
+		}
+		return stats[i].name < stats[j].name
+	})
+	return stats
+}
+
+// countLines returns the number of lines in a text file, or 0 for large,
+// binary, or unreadable files.
+func countLines(path string) int {
+	info, err := os.Stat(path)
+	if err != nil || info.Size() > maxCountSize {
+		return 0
+	}
+	data, err := os.ReadFile(path)
+	if err != nil || bytes.IndexByte(data, 0) >= 0 {
+		return 0
+	}
+	n := bytes.Count(data, []byte("\n"))
+	if len(data) > 0 && data[len(data)-1] != '\n' {
+		n++
+	}
+	return n
+}
+
+// node is a directory in the layout tree.
+type node struct {
+	dirs  map[string]*node
+	files []string
+	total int // Files in this directory and below
+}
+
+func newNode() *node { return &node{dirs: make(map[string]*node)} }
+
+// buildTree arranges slash-separated paths into a directory tree.
+func buildTree(files []string) *node {
+	root := newNode()
+	for _, f := range files {
+		n := root
+		n.total++
+		parts := strings.Split(f, "/")
+		for _, dir := range parts[:len(parts)-1] {
+			child := n.dirs[dir]
+			if child == nil {
+				child = newNode()
+				n.dirs[dir] = child
+			}
+			n = child
+			n.total++
+		}
+		n.files = append(n.files, parts[len(parts)-1])
+	}
+	return root
+}
+
+// writeTree writes n's entries, directories first, collapsing directories
+// below maxDepth into a file count.
+func writeTree(b *strings.Builder, n *node, indent string, depth int) {
+	names := make([]string, 0, len(n.dirs))
+	for name := range n.dirs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	files := append([]string(nil), n.files...)
+	sort.Strings(files)
+
+	shown := 0
+	for _, name := range names {
+		if shown == maxEntries {
+			break
+		}
+		shown++
+		child := n.dirs[name]
+		if depth >= maxDepth {
+			fmt.Fprintf(b, "%s%s/ (%d files)\n", indent, name, child.total)
+			continue
+		}
+		fmt.Fprintf(b, "%s%s/\n", indent, name)
+		writeTree(b, child, indent+"  ", depth+1)
+	}
+	for _, name := range files {
+		if shown == maxEntries {
+			break
+		}
+		shown++
+		fmt.Fprintf(b, "%s%s\n", indent, name)
+	}
+	if rest := len(names) + len(files) - shown; rest > 0 {
+		fmt.Fprintf(b, "%s… %d more\n", indent, rest)
+	}
+}
+
+// readHead returns up to n lines from the start of a text file, within
+// maxKeyFileBytes. Binary files fail with textfile.ErrBinary.
+func readHead(path string, n int) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	head, err := io.ReadAll(io.LimitReader(f, maxKeyFileBytes+1))
+	if err != nil {
+		return "", err
+	}
+	if textfile.IsBinary(head) {
+		return "", fmt.Errorf("%s: %w", filepath.Base(path), textfile.ErrBinary)
+	}
+	truncated := len(head) > maxKeyFileBytes
+	if truncated {
+		head = head[:maxKeyFileBytes]
+		head = bytes.TrimRightFunc(head, func(r rune) bool { return r == utf8.RuneError })
+	}
+	lines := strings.Split(string(head), "\n")
+	if len(lines) > n {
+		lines, truncated = lines[:n], true
+	}
+	text := strings.TrimSpace(strings.Join(lines, "\n"))
+	if text != "" && truncated {
+		text += "\n…"
+	}
+	return text, nil
+}
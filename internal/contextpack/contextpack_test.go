@@ -0,0 +1,70 @@
+package contextpack
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/adityaraj/agentflow/internal/textfile"
+)
+
+func TestGenerate(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"README.md":                 "# Demo\n\nA demo project.\n",
+		"go.mod":                    "module example.com/demo\n",
+		"main.go":                   "package main\n\nfunc main() {}\n",
+		"internal/app/app.go":       "package app\n",
+		"internal/app/deep/x/y.go":  "package x\n",
+		"node_modules/pkg/index.js": "ignored\n",
+		"web/app.ts":                "export {}\n",
+		"Makefile":                  "\x7fELF\x00\x00",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	pack, skipped, err := Generate(dir)
+	if err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+	if len(skipped) != 1 || !errors.Is(skipped[0], textfile.ErrBinary) {
+		t.Errorf("Generate() skipped %v, want the binary Makefile", skipped)
+	}
+
+	for _, want := range []string{
+		"7 files.",
+		"- Go: 3 files, 5 lines",
+		"- TypeScript: 1 files, 1 lines",
+		"internal/\n  app/\n    deep/ (1 files)\n    app.go\n",
+		"## README.md\n\n```\n# Demo",
+		"## go.mod\n\n```\nmodule example.com/demo\n```",
+	} {
+		if !strings.Contains(pack, want) {
+			t.Errorf("pack missing %q:\n%s", want, pack)
+		}
+	}
+	if strings.Contains(pack, "node_modules") {
+		t.Errorf("pack should skip node_modules:\n%s", pack)
+	}
+}
+
+func TestWriteTree_Truncates(t *testing.T) {
+	var files []string
+	for i := 0; i < maxEntries+3; i++ {
+		files = append(files, "f"+string(rune('a'+i))+".txt")
+	}
+	var b strings.Builder
+	writeTree(&b, buildTree(files), "", 1)
+	if !strings.Contains(b.String(), "… 3 more") {
+		t.Errorf("writeTree() = %q, want overflow marker", b.String())
+	}
+}
@@ -0,0 +1,236 @@
+package contextpack
+
+import (
+	"bufio"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/adityaraj/agentflow/internal/glob"
+)
+
+// IgnoreFileName is the file listing paths never gathered for agents, in
+// .gitignore syntax: one glob per line, "#" comments, "!" re-including,
+// a leading "/" anchoring to the file's directory and a trailing "/"
+// matching only directories.
+const IgnoreFileName = ".cortexignore"
+
+// Ignore matches paths against the .cortexignore files that apply to a
+// directory. The zero Ignore (and nil) ignores nothing.
+type Ignore struct {
+	rules []ignoreRule
+}
+
+// ignoreRule is one line of a .cortexignore file.
+type ignoreRule struct {
+	prefix   string // Directory of a nested file; the rule only matches below it
+	pattern  string // Relative to the directory matched in (or prefix)
+	anchored bool   // pattern matches from there rather than at any depth
+	negate   bool   // "!pattern" re-includes what earlier lines ignored
+	dirOnly  bool   // "pattern/" matches directories only
+}
+
+// LoadIgnore reads the .cortexignore files that apply to dir: its own and
+// those of its parents up to the root of the git repository containing
+// it, the innermost taking precedence. Paths are matched relative to dir.
+// Files in dir's subdirectories are added with AddNested.
+func LoadIgnore(dir string) (*Ignore, error) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	// Directories to read, outermost first, with dir's path inside each
+	dirs := []string{abs}
+	for d := abs; !isRepoRoot(d); {
+		parent := filepath.Dir(d)
+		if parent == d {
+			dirs = []string{abs} // Not in a repository; only dir's own file applies
+			break
+		}
+		d = parent
+		dirs = append([]string{d}, dirs...)
+	}
+
+	ig := &Ignore{}
+	for _, d := range dirs {
+		data, err := os.ReadFile(filepath.Join(d, IgnoreFileName))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		rel, _ := filepath.Rel(d, abs)
+		ig.rules = append(ig.rules, parseIgnore(string(data), filepath.ToSlash(rel), "")...)
+	}
+	return ig, nil
+}
+
+// AddNested reads the .cortexignore file in sub, a slash-separated
+// subdirectory of dir, if it has one: its lines apply below sub, taking
+// precedence over those already read.
+func (ig *Ignore) AddNested(dir, sub string) error {
+	data, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(sub), IgnoreFileName))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	ig.rules = append(ig.rules, parseIgnore(string(data), ".", sub)...)
+	return nil
+}
+
+// isRepoRoot reports whether dir is the top of a git working tree.
+func isRepoRoot(dir string) bool {
+	_, err := os.Stat(filepath.Join(dir, ".git"))
+	return err == nil
+}
+
+// AddNestedOf reads the .cortexignore files of the subdirectories of dir
+// holding files (slash-separated paths relative to dir), outer ones first.
+func (ig *Ignore) AddNestedOf(dir string, files []string) error {
+	seen := make(map[string]bool)
+	var subs []string
+	for _, f := range files {
+		for sub := path.Dir(f); sub != "." && !seen[sub]; sub = path.Dir(sub) {
+			seen[sub] = true
+			subs = append(subs, sub)
+		}
+	}
+	sort.Slice(subs, func(i, j int) bool {
+		if di, dj := strings.Count(subs[i], "/"), strings.Count(subs[j], "/"); di != dj {
+			return di < dj
+		}
+		return subs[i] < subs[j]
+	})
+	for _, sub := range subs {
+		if err := ig.AddNested(dir, sub); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parseIgnore parses a .cortexignore file whose directory is at sub
+// ("." or a slash-separated path) relative to the directory matched in,
+// or, for a nested file, at prefix within it.
+func parseIgnore(text, sub, prefix string) []ignoreRule {
+	var rules []ignoreRule
+	scanner := bufio.NewScanner(strings.NewReader(text))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		r := ignoreRule{prefix: prefix}
+		line, r.negate = strings.CutPrefix(line, "!")
+		line, r.dirOnly = strings.CutSuffix(line, "/")
+		r.anchored = strings.Contains(line, "/")
+		line = strings.TrimPrefix(line, "/")
+		if line == "" {
+			continue
+		}
+		r.pattern = line
+		if sub != "." {
+			if !r.anchored {
+				line = "**/" + line
+			}
+			r.pattern, r.anchored = rebase(line, sub), true
+			if r.pattern == "" {
+				continue // Only matches outside the directory
+			}
+		}
+		rules = append(rules, r)
+	}
+	return rules
+}
+
+// rebase makes pattern, relative to a directory at sub ("." or
+// "sub/dir"), relative to the directory sub names. It returns "" if
+// pattern can't match inside that directory.
+func rebase(pattern, sub string) string {
+	if sub == "." {
+		return pattern
+	}
+	segs := strings.Split(pattern, "/")
+	for _, dir := range strings.Split(sub, "/") {
+		if len(segs) == 0 {
+			return ""
+		}
+		if segs[0] == "**" {
+			return strings.Join(segs, "/") // "**" also matches the rest of sub
+		}
+		if ok, _ := path.Match(segs[0], dir); !ok {
+			return ""
+		}
+		segs = segs[1:]
+	}
+	if len(segs) == 0 {
+		return "**" // The pattern names the directory itself
+	}
+	return strings.Join(segs, "/")
+}
+
+// Ignored reports whether the slash-separated path name is ignored,
+// itself or through one of its directories. Directories end in "/".
+func (ig *Ignore) Ignored(name string) bool {
+	if ig == nil || len(ig.rules) == 0 {
+		return false
+	}
+	name, isDir := strings.CutSuffix(strings.TrimPrefix(name, "./"), "/")
+	segs := strings.Split(name, "/")
+	for i := 1; i <= len(segs); i++ {
+		if ig.match(strings.Join(segs[:i], "/"), i < len(segs) || isDir) {
+			return true // An ignored directory can't be re-included from, as in git
+		}
+	}
+	return false
+}
+
+// match applies the rules to one path, the last that matches deciding.
+func (ig *Ignore) match(name string, isDir bool) bool {
+	ignored := false
+	for _, r := range ig.rules {
+		if r.dirOnly && !isDir {
+			continue
+		}
+		rel := name
+		if r.prefix != "" {
+			var ok bool
+			if rel, ok = strings.CutPrefix(name, r.prefix+"/"); !ok {
+				continue
+			}
+		}
+		if r.matches(rel) {
+			ignored = !r.negate
+		}
+	}
+	return ignored
+}
+
+// matches reports whether the rule's pattern matches name.
+func (r ignoreRule) matches(name string) bool {
+	if r.anchored && !strings.Contains(r.pattern, "/") && r.pattern != "**" {
+		// glob.Match would match a single segment at any depth
+		ok, _ := path.Match(r.pattern, name)
+		return ok
+	}
+	return glob.Match(r.pattern, name)
+}
+
+// Filter returns the paths in names that aren't ignored.
+func (ig *Ignore) Filter(names []string) []string {
+	if ig == nil || len(ig.rules) == 0 {
+		return names
+	}
+	var kept []string
+	for _, name := range names {
+		if !ig.Ignored(name) {
+			kept = append(kept, name)
+		}
+	}
+	return kept
+}
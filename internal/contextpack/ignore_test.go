@@ -0,0 +1,82 @@
+package contextpack
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestIgnore(t *testing.T) {
+	root := t.TempDir()
+	for name, content := range map[string]string{
+		".git/HEAD":                 "ref: refs/heads/main\n",
+		IgnoreFileName:              "# Generated and vendored code\nvendor/\n*.pb.go\n/dist\n!keep.pb.go\nservice/gen/\n",
+		"service/" + IgnoreFileName: "fixtures/**/*.json\n",
+		"service/fixtures/a/b.json": "{}\n",
+		"main.go":                   "package main\n",
+	} {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ig, err := LoadIgnore(root)
+	if err == nil {
+		err = ig.AddNested(root, "service")
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, want := range map[string]bool{
+		"main.go":                   false,
+		"vendor/lib/lib.go":         true,
+		"api/vendor/x.go":           true,
+		"vendor":                    false, // Directories only
+		"api/user.pb.go":            true,
+		"api/keep.pb.go":            false,
+		"dist/app.js":               true,
+		"web/dist/app.js":           false, // Anchored
+		"service/gen/api.go":        true,
+		"service/fixtures/a/b.json": true,
+		"fixtures/a/b.json":         false, // Only under service/
+		"service/fixtures/a/b.go":   false,
+	} {
+		if got := ig.Ignored(name); got != want {
+			t.Errorf("Ignored(%q) = %v, want %v", name, got, want)
+		}
+	}
+
+	// From a subdirectory, the files above still apply, relative to it
+	sub, err := LoadIgnore(filepath.Join(root, "service"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, want := range map[string]bool{
+		"gen/api.go":         true,
+		"fixtures/x.json":    true,
+		"handler.go":         false,
+		"vendor/lib.go":      true,
+		"internal/api.pb.go": true,
+		"dist/app.js":        false, // /dist is the root's
+	} {
+		if got := sub.Ignored(name); got != want {
+			t.Errorf("from service/, Ignored(%q) = %v, want %v", name, got, want)
+		}
+	}
+
+	files, err := ListFiles(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(files, " "); strings.Contains(got, "fixtures") || !strings.Contains(got, "main.go") {
+		t.Errorf("ListFiles() = %q", got)
+	}
+	if (*Ignore)(nil).Ignored("vendor/x.go") {
+		t.Error("nil Ignored() = true")
+	}
+}
@@ -0,0 +1,229 @@
+// Package crypt encrypts persisted run state at rest with AES-256-GCM, for
+// environments where run files must not be readable without a key.
+//
+// Encrypted files start with a magic header, so readers can tell them from
+// plaintext and decrypt them transparently. Log files, which are appended
+// to a line at a time, are encrypted line by line instead (see LineWriter).
+package crypt
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// KeyEnv holds the base64- or hex-encoded 256-bit key.
+const KeyEnv = "CORTEX_STATE_KEY"
+
+// KeySize is the size of a key in bytes.
+const KeySize = 32
+
+const (
+	// magic starts encrypted files, followed by the nonce and ciphertext.
+	magic = "CORTEXENC1\n"
+
+	// linePrefix starts encrypted log lines, followed by base64 of the
+	// nonce and ciphertext.
+	linePrefix = "cortexenc1:"
+)
+
+// ErrNoKey is returned when encrypted data is read without a key.
+var ErrNoKey = errors.New("data is encrypted; set " + KeyEnv + " to the key it was written with")
+
+// ParseKey decodes a key given as base64 (standard or URL alphabet, with or
+// without padding) or hex.
+func ParseKey(s string) ([]byte, error) {
+	s = strings.TrimSpace(s)
+	for _, decode := range []func(string) ([]byte, error){
+		base64.StdEncoding.DecodeString,
+		base64.RawStdEncoding.DecodeString,
+		base64.URLEncoding.DecodeString,
+		base64.RawURLEncoding.DecodeString,
+		hex.DecodeString,
+	} {
+		if key, err := decode(s); err == nil && len(key) == KeySize {
+			return key, nil
+		}
+	}
+	return nil, fmt.Errorf("invalid key: want %d bytes, base64 or hex encoded (e.g. from 'openssl rand -base64 32')", KeySize)
+}
+
+// EnvKey returns the key in KeyEnv, or nil if it isn't set.
+func EnvKey() ([]byte, error) {
+	s := os.Getenv(KeyEnv)
+	if strings.TrimSpace(s) == "" {
+		return nil, nil
+	}
+	key, err := ParseKey(s)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", KeyEnv, err)
+	}
+	return key, nil
+}
+
+// GenerateKey returns a new random key, base64 encoded.
+func GenerateKey() (string, error) {
+	key := make([]byte, KeySize)
+	if _, err := rand.Read(key); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(key), nil
+}
+
+// IsEncrypted reports whether data was written by Encrypt.
+func IsEncrypted(data []byte) bool {
+	return bytes.HasPrefix(data, []byte(magic))
+}
+
+// Encrypt encrypts plaintext with key.
+func Encrypt(key, plaintext []byte) ([]byte, error) {
+	sealed, err := seal(key, plaintext)
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(magic), sealed...), nil
+}
+
+// Decrypt decrypts data written by Encrypt. Data without the header is
+// returned as is, so files written before encryption was turned on can
+// still be read.
+func Decrypt(key, data []byte) ([]byte, error) {
+	if !IsEncrypted(data) {
+		return data, nil
+	}
+	if key == nil {
+		return nil, ErrNoKey
+	}
+	return open(key, data[len(magic):])
+}
+
+// ReadFile reads a file, decrypting it with the key in KeyEnv if it is
+// encrypted.
+func ReadFile(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil || !IsEncrypted(data) {
+		return data, err
+	}
+	key, err := EnvKey()
+	if err != nil {
+		return nil, err
+	}
+	data, err = Decrypt(key, data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return data, nil
+}
+
+// seal encrypts plaintext with a random nonce, returning nonce || ciphertext.
+func seal(key, plaintext []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+// open decrypts nonce || ciphertext.
+func open(key, sealed []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < gcm.NonceSize() {
+		return nil, errors.New("encrypted data is truncated")
+	}
+	nonce, ciphertext := sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():]
+	plaintext, err := gcm.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return nil, errors.New("cannot decrypt: wrong key or corrupted data")
+	}
+	return plaintext, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// LineWriter encrypts each line written to it into a line of its own, so
+// appending to an encrypted log keeps every earlier line readable.
+type LineWriter struct {
+	w   io.Writer
+	key []byte
+	buf []byte // Incomplete line
+}
+
+// NewLineWriter returns a LineWriter writing to w.
+func NewLineWriter(w io.Writer, key []byte) *LineWriter {
+	return &LineWriter{w: w, key: key}
+}
+
+// Write encrypts the complete lines in p, buffering the rest until its
+// newline is written.
+func (lw *LineWriter) Write(p []byte) (int, error) {
+	lw.buf = append(lw.buf, p...)
+	for {
+		i := bytes.IndexByte(lw.buf, '\n')
+		if i < 0 {
+			return len(p), nil
+		}
+		sealed, err := seal(lw.key, lw.buf[:i])
+		if err != nil {
+			return 0, err
+		}
+		lw.buf = lw.buf[i+1:]
+		if _, err := io.WriteString(lw.w, linePrefix+base64.StdEncoding.EncodeToString(sealed)+"\n"); err != nil {
+			return 0, err
+		}
+	}
+}
+
+// DecryptLines copies r to w, decrypting lines written by a LineWriter.
+// Plaintext lines are copied as they are.
+func DecryptLines(w io.Writer, r io.Reader, key []byte) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 16<<20)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if encoded, ok := strings.CutPrefix(line, linePrefix); ok {
+			if key == nil {
+				return ErrNoKey
+			}
+			sealed, err := base64.StdEncoding.DecodeString(encoded)
+			if err != nil {
+				return fmt.Errorf("invalid encrypted line: %w", err)
+			}
+			plaintext, err := open(key, sealed)
+			if err != nil {
+				return err
+			}
+			line = string(plaintext)
+		}
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+// IsEncryptedLog reports whether data has lines written by a LineWriter.
+func IsEncryptedLog(data []byte) bool {
+	return bytes.HasPrefix(data, []byte(linePrefix)) || bytes.Contains(data, []byte("\n"+linePrefix))
+}
@@ -0,0 +1,79 @@
+package crypt
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func testKey(t *testing.T) []byte {
+	t.Helper()
+	encoded, err := GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := ParseKey(encoded)
+	if err != nil {
+		t.Fatalf("ParseKey(GenerateKey()) error = %v", err)
+	}
+	return key
+}
+
+func TestEncryptDecrypt(t *testing.T) {
+	key := testKey(t)
+	plaintext := []byte(`{"prompt": "proprietary code"}`)
+
+	encrypted, err := Encrypt(key, plaintext)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !IsEncrypted(encrypted) || bytes.Contains(encrypted, []byte("proprietary")) {
+		t.Fatalf("Encrypt() = %q", encrypted)
+	}
+	if got, err := Decrypt(key, encrypted); err != nil || !bytes.Equal(got, plaintext) {
+		t.Errorf("Decrypt() = %q, %v", got, err)
+	}
+
+	if _, err := Decrypt(testKey(t), encrypted); err == nil || !strings.Contains(err.Error(), "wrong key") {
+		t.Errorf("Decrypt() with another key error = %v", err)
+	}
+	if _, err := Decrypt(nil, encrypted); !errors.Is(err, ErrNoKey) {
+		t.Errorf("Decrypt() without a key error = %v, want ErrNoKey", err)
+	}
+	if got, err := Decrypt(nil, plaintext); err != nil || !bytes.Equal(got, plaintext) {
+		t.Errorf("Decrypt() of plaintext = %q, %v, want it unchanged", got, err)
+	}
+}
+
+func TestParseKey(t *testing.T) {
+	hexKey := strings.Repeat("ab", KeySize)
+	if key, err := ParseKey(hexKey); err != nil || len(key) != KeySize {
+		t.Errorf("ParseKey(hex) = %x, %v", key, err)
+	}
+	for _, s := range []string{"", "short", strings.Repeat("ab", 16)} {
+		if _, err := ParseKey(s); err == nil {
+			t.Errorf("ParseKey(%q) succeeded", s)
+		}
+	}
+}
+
+func TestLineWriter(t *testing.T) {
+	key := testKey(t)
+	var log bytes.Buffer
+	log.WriteString("plain line\n")
+	w := NewLineWriter(&log, key)
+	w.Write([]byte("first secret\nsecond "))
+	w.Write([]byte("secret\n"))
+
+	if strings.Contains(log.String(), "secret") || !IsEncryptedLog(log.Bytes()) {
+		t.Fatalf("log = %q", log.String())
+	}
+	var out bytes.Buffer
+	if err := DecryptLines(&out, &log, key); err != nil {
+		t.Fatal(err)
+	}
+	if want := "plain line\nfirst secret\nsecond secret\n"; out.String() != want {
+		t.Errorf("DecryptLines() = %q, want %q", out.String(), want)
+	}
+}
@@ -0,0 +1,325 @@
+// Package email sends run notifications over SMTP, for teams that want the
+// results of scheduled workflows in an inbox.
+package email
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/adityaraj/agentflow/internal/config"
+	"github.com/adityaraj/agentflow/internal/network"
+	"github.com/adityaraj/agentflow/internal/state"
+)
+
+// DefaultPasswordEnv holds the SMTP password when password_env isn't set.
+const DefaultPasswordEnv = "CORTEX_SMTP_PASSWORD"
+
+// Default templates for the subject and body.
+const (
+	DefaultSubject = `[Cortex] {{.Project}}: run {{.RunID}} {{.Status}}`
+	DefaultBody    = `Run {{.RunID}} of {{.Project}} {{.Status}} in {{.Duration}}.
+
+Tasks: {{.Tasks}} ({{len .Failed}} failed)
+{{- if .Tokens}}
+Tokens: {{.Tokens}}{{end}}
+{{- if .Cost}}
+Cost: {{.Cost}}{{end}}
+{{- if .Failed}}
+
+Failed tasks:
+{{- range .Failed}}
+  - {{.Name}} ({{.Agent}}): {{.Reason}}
+{{- end}}{{end}}
+
+Results: {{.RunDir}}
+`
+)
+
+// timeout bounds connecting to the server and sending a message.
+const timeout = 30 * time.Second
+
+// Run is what the subject and body templates describe.
+type Run struct {
+	Project  string
+	RunID    string
+	Success  bool
+	Status   string // "succeeded" or "failed"
+	Duration string
+	Tasks    int    // Tasks that ran
+	Tokens   int    // Total tokens used
+	Cost     string // Total cost, e.g. "$0.1234" ("" if unknown)
+	Failed   []FailedTask
+	RunDir   string // Directory of the run's results
+	Report   string // Path of the HTML report ("" if not written)
+}
+
+// FailedTask is a task that failed in the run.
+type FailedTask struct {
+	Name   string
+	Agent  string
+	Reason string // e.g. "exit code 1" or "rate_limit [CORTEX-RUN-003]"
+}
+
+// NewRun describes result for the templates.
+func NewRun(project, runDir, reportPath string, result *state.RunResult) Run {
+	result.CalculateTotalTokens()
+	run := Run{
+		Project:  project,
+		RunID:    result.RunID,
+		Success:  result.Success,
+		Status:   "succeeded",
+		Duration: state.FormatDuration(result.EndTime.Sub(result.StartTime)),
+		Tasks:    len(result.Tasks),
+		Tokens:   result.TokenUsage.TotalTokens,
+		RunDir:   runDir,
+		Report:   reportPath,
+	}
+	if !result.Success {
+		run.Status = "failed"
+	}
+	if result.TokenUsage.CostUSD > 0 {
+		run.Cost = fmt.Sprintf("$%.4f", result.TokenUsage.CostUSD)
+	}
+	for _, t := range result.Tasks {
+		if t.Success || t.Skipped != "" {
+			continue
+		}
+		reason := string(t.ErrorCategory)
+		if t.ErrorCategory == state.ErrorFailed || reason == "" {
+			reason = fmt.Sprintf("exit code %d", t.ExitCode)
+		}
+		if code := t.ErrorCategory.Code(); code != "" {
+			reason += " [" + code + "]"
+		}
+		run.Failed = append(run.Failed, FailedTask{Name: t.TaskName, Agent: t.Agent, Reason: reason})
+	}
+	return run
+}
+
+// Validate checks that cfg can send mail, so mistakes show up before a run
+// rather than after it.
+func Validate(cfg config.EmailConfig) error {
+	if _, _, err := net.SplitHostPort(cfg.SMTP); err != nil {
+		return fmt.Errorf("email: invalid smtp %q: use host:port, e.g. smtp.example.com:587", cfg.SMTP)
+	}
+	if _, err := mail.ParseAddress(cfg.From); err != nil {
+		return fmt.Errorf("email: invalid from %q: %w", cfg.From, err)
+	}
+	if len(cfg.To) == 0 {
+		return fmt.Errorf("email: 'to' lists no recipients")
+	}
+	for _, to := range cfg.To {
+		if _, err := mail.ParseAddress(to); err != nil {
+			return fmt.Errorf("email: invalid recipient %q: %w", to, err)
+		}
+	}
+	switch cfg.On {
+	case "", config.EmailAlways, config.EmailFailure, config.EmailSuccess:
+	default:
+		return fmt.Errorf("email: invalid on %q: use always, failure, or success", cfg.On)
+	}
+	if _, err := parseTemplates(cfg); err != nil {
+		return err
+	}
+	return nil
+}
+
+// parseTemplates parses cfg's subject and body, or the defaults.
+func parseTemplates(cfg config.EmailConfig) (*template.Template, error) {
+	subject, body := cfg.Subject, cfg.Body
+	if subject == "" {
+		subject = DefaultSubject
+	}
+	if body == "" {
+		body = DefaultBody
+	}
+	t, err := template.New("subject").Parse(subject)
+	if err != nil {
+		return nil, fmt.Errorf("email: invalid subject template: %w", err)
+	}
+	if _, err := t.New("body").Parse(body); err != nil {
+		return nil, fmt.Errorf("email: invalid body template: %w", err)
+	}
+	return t, nil
+}
+
+// Send emails run to cfg's recipients, reading the password from the
+// environment through lookup.
+func Send(cfg config.EmailConfig, run Run, lookup func(string) (string, bool)) error {
+	msg, err := Message(cfg, run, time.Now())
+	if err != nil {
+		return err
+	}
+
+	host, port, err := net.SplitHostPort(cfg.SMTP)
+	if err != nil {
+		return fmt.Errorf("invalid smtp %q: %w", cfg.SMTP, err)
+	}
+	var password string
+	if cfg.Username != "" {
+		name := cfg.PasswordEnv
+		if name == "" {
+			name = DefaultPasswordEnv
+		}
+		if password, _ = lookup(name); password == "" {
+			return fmt.Errorf("no SMTP password: set %s", name)
+		}
+	}
+
+	var conn net.Conn
+	dialer := &net.Dialer{Timeout: timeout}
+	if port == "465" {
+		conn, err = tls.DialWithDialer(dialer, "tcp", cfg.SMTP, network.TLSConfig(host))
+	} else {
+		conn, err = dialer.Dial("tcp", cfg.SMTP)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to connect to %s: %w", cfg.SMTP, err)
+	}
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Now().Add(timeout))
+
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		return fmt.Errorf("failed to connect to %s: %w", cfg.SMTP, err)
+	}
+	defer c.Close()
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(network.TLSConfig(host)); err != nil {
+			return fmt.Errorf("STARTTLS with %s failed: %w", cfg.SMTP, err)
+		}
+	}
+	if cfg.Username != "" {
+		if err := c.Auth(smtp.PlainAuth("", cfg.Username, password, host)); err != nil {
+			return fmt.Errorf("SMTP login failed: %w", err)
+		}
+	}
+
+	from, _ := mail.ParseAddress(cfg.From)
+	if err := c.Mail(from.Address); err != nil {
+		return err
+	}
+	for _, to := range cfg.To {
+		addr, err := mail.ParseAddress(to)
+		if err != nil {
+			return fmt.Errorf("invalid recipient %q: %w", to, err)
+		}
+		if err := c.Rcpt(addr.Address); err != nil {
+			return fmt.Errorf("recipient %s rejected: %w", addr.Address, err)
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+// Message renders the email for run, sent at date: a plain text body, with
+// the HTML report attached if cfg asks for it and run has one.
+func Message(cfg config.EmailConfig, run Run, date time.Time) ([]byte, error) {
+	t, err := parseTemplates(cfg)
+	if err != nil {
+		return nil, err
+	}
+	var subject, body bytes.Buffer
+	if err := t.ExecuteTemplate(&subject, "subject", run); err != nil {
+		return nil, fmt.Errorf("email: subject template: %w", err)
+	}
+	if err := t.ExecuteTemplate(&body, "body", run); err != nil {
+		return nil, fmt.Errorf("email: body template: %w", err)
+	}
+
+	var attachment []byte
+	if cfg.AttachReport && run.Report != "" {
+		if attachment, err = os.ReadFile(run.Report); err != nil {
+			return nil, fmt.Errorf("failed to attach report: %w", err)
+		}
+	}
+
+	var msg bytes.Buffer
+	header := func(key, value string) {
+		fmt.Fprintf(&msg, "%s: %s\r\n", key, value)
+	}
+	header("From", cfg.From)
+	header("To", strings.Join(cfg.To, ", "))
+	header("Subject", mime.QEncoding.Encode("utf-8", strings.TrimSpace(subject.String())))
+	header("Date", date.Format(time.RFC1123Z))
+	header("Message-ID", messageID(cfg.From))
+	header("MIME-Version", "1.0")
+
+	text := crlf(body.String())
+	if attachment == nil {
+		header("Content-Type", "text/plain; charset=utf-8")
+		msg.WriteString("\r\n")
+		msg.WriteString(text)
+		return msg.Bytes(), nil
+	}
+
+	parts := multipart.NewWriter(&msg)
+	header("Content-Type", "multipart/mixed; boundary="+parts.Boundary())
+	msg.WriteString("\r\n")
+	part, err := parts.CreatePart(textproto.MIMEHeader{"Content-Type": {"text/plain; charset=utf-8"}})
+	if err != nil {
+		return nil, err
+	}
+	part.Write([]byte(text))
+
+	name := filepath.Base(run.Report)
+	part, err = parts.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {"text/html; charset=utf-8; name=" + name},
+		"Content-Disposition":       {"attachment; filename=" + name},
+		"Content-Transfer-Encoding": {"base64"},
+	})
+	if err != nil {
+		return nil, err
+	}
+	encoded := base64.StdEncoding.EncodeToString(attachment)
+	for len(encoded) > 76 {
+		part.Write([]byte(encoded[:76] + "\r\n"))
+		encoded = encoded[76:]
+	}
+	part.Write([]byte(encoded + "\r\n"))
+	if err := parts.Close(); err != nil {
+		return nil, err
+	}
+	return msg.Bytes(), nil
+}
+
+// crlf converts line endings to the CRLF that SMTP requires.
+func crlf(s string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(s, "\r\n", "\n"), "\n", "\r\n")
+}
+
+// messageID returns a unique Message-ID in the domain of from.
+func messageID(from string) string {
+	domain := "cortex.local"
+	if addr, err := mail.ParseAddress(from); err == nil {
+		if _, d, ok := strings.Cut(addr.Address, "@"); ok {
+			domain = d
+		}
+	}
+	b := make([]byte, 12)
+	_, _ = rand.Read(b)
+	return fmt.Sprintf("<%x@%s>", b, domain)
+}
@@ -0,0 +1,191 @@
+package email
+
+import (
+	"bufio"
+	"net"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/adityaraj/agentflow/internal/config"
+	"github.com/adityaraj/agentflow/internal/state"
+)
+
+func testRun() Run {
+	start := time.Date(2024, 1, 15, 14, 30, 0, 0, time.UTC)
+	return NewRun("demo", "/runs/run-20240115-143000", "", &state.RunResult{
+		RunID:     "20240115-143000",
+		StartTime: start,
+		EndTime:   start.Add(90 * time.Second),
+		Tasks: []state.TaskResult{
+			{TaskName: "scan", Agent: "auditor", Success: true, TokenUsage: state.TokenUsage{TotalTokens: 1500, CostUSD: 0.25}},
+			{TaskName: "fix", Agent: "coder", ExitCode: 1, ErrorCategory: state.ErrorRateLimit},
+		},
+	})
+}
+
+func TestMessage(t *testing.T) {
+	cfg := config.EmailConfig{From: "Cortex <cortex@example.com>", To: []string{"team@example.com"}}
+	msg, err := Message(cfg, testRun(), time.Now())
+	if err != nil {
+		t.Fatalf("Message() error = %v", err)
+	}
+
+	parsed, err := mail.ReadMessage(strings.NewReader(string(msg)))
+	if err != nil {
+		t.Fatalf("invalid message: %v", err)
+	}
+	if got := parsed.Header.Get("Subject"); got != "[Cortex] demo: run 20240115-143000 failed" {
+		t.Errorf("Subject = %q", got)
+	}
+	body := string(msg)
+	for _, want := range []string{
+		"Run 20240115-143000 of demo failed in 1m30s.",
+		"Tasks: 2 (1 failed)",
+		"Tokens: 1500",
+		"Cost: $0.2500",
+		"  - fix (coder): rate_limit [CORTEX-RUN-003]",
+		"Results: /runs/run-20240115-143000\r\n",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("body missing %q:\n%s", want, body)
+		}
+	}
+
+	// Custom templates and an attached report
+	report := filepath.Join(t.TempDir(), "report.html")
+	if err := os.WriteFile(report, []byte("<html>report</html>"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	run := testRun()
+	run.Report = report
+	cfg.Subject = "{{.Status}}: {{len .Failed}} failures"
+	cfg.Body = "See the attached report."
+	cfg.AttachReport = true
+	msg, err = Message(cfg, run, time.Now())
+	if err != nil {
+		t.Fatalf("Message() error = %v", err)
+	}
+	for _, want := range []string{
+		"Subject: failed: 1 failures\r\n",
+		"Content-Type: multipart/mixed; boundary=",
+		"See the attached report.",
+		"Content-Disposition: attachment; filename=report.html",
+		"PGh0bWw+cmVwb3J0PC9odG1sPg==", // base64 of the report
+	} {
+		if !strings.Contains(string(msg), want) {
+			t.Errorf("message missing %q:\n%s", want, msg)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	valid := config.EmailConfig{SMTP: "smtp.example.com:587", From: "cortex@example.com", To: []string{"team@example.com"}}
+	if err := Validate(valid); err != nil {
+		t.Errorf("Validate() error = %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		modify func(*config.EmailConfig)
+		want   string
+	}{
+		{"no port", func(c *config.EmailConfig) { c.SMTP = "smtp.example.com" }, "invalid smtp"},
+		{"no recipients", func(c *config.EmailConfig) { c.To = nil }, "no recipients"},
+		{"bad recipient", func(c *config.EmailConfig) { c.To = []string{"team"} }, `invalid recipient "team"`},
+		{"bad on", func(c *config.EmailConfig) { c.On = "sometimes" }, `invalid on "sometimes"`},
+		{"bad template", func(c *config.EmailConfig) { c.Subject = "{{.RunID" }, "invalid subject template"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := valid
+			tt.modify(&cfg)
+			if err := Validate(cfg); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Validate() error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestSend(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	// A minimal SMTP server that records the commands and message it gets
+	received := make(chan []string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		reply := func(s string) { conn.Write([]byte(s + "\r\n")) }
+		var lines []string
+		reply("220 localhost ready")
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				received <- lines
+				return
+			}
+			line = strings.TrimRight(line, "\r\n")
+			lines = append(lines, line)
+			switch cmd := strings.ToUpper(strings.SplitN(line, " ", 2)[0]); cmd {
+			case "EHLO", "HELO":
+				reply("250 localhost")
+			case "DATA":
+				reply("354 go ahead")
+				for {
+					data, err := r.ReadString('\n')
+					if err != nil || data == ".\r\n" {
+						break
+					}
+					lines = append(lines, strings.TrimRight(data, "\r\n"))
+				}
+				reply("250 queued")
+			case "QUIT":
+				reply("221 bye")
+				received <- lines
+				return
+			default:
+				reply("250 ok")
+			}
+		}
+	}()
+
+	cfg := config.EmailConfig{
+		SMTP: ln.Addr().String(),
+		From: "Cortex <cortex@example.com>",
+		To:   []string{"team@example.com", "Ops <ops@example.com>"},
+	}
+	if err := Send(cfg, testRun(), func(string) (string, bool) { return "", false }); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+
+	lines := strings.Join(<-received, "\n")
+	for _, want := range []string{
+		"MAIL FROM:<cortex@example.com>",
+		"RCPT TO:<team@example.com>",
+		"RCPT TO:<ops@example.com>",
+		"Subject: [Cortex] demo: run 20240115-143000 failed",
+		"Tasks: 2 (1 failed)",
+	} {
+		if !strings.Contains(lines, want) {
+			t.Errorf("server didn't receive %q:\n%s", want, lines)
+		}
+	}
+
+	// Logging in needs the password
+	cfg.Username = "cortex"
+	err = Send(cfg, testRun(), func(string) (string, bool) { return "", false })
+	if err == nil || !strings.Contains(err.Error(), "set CORTEX_SMTP_PASSWORD") {
+		t.Errorf("Send() without password error = %v", err)
+	}
+}
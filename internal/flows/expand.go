@@ -0,0 +1,196 @@
+package flows
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+
+	"github.com/adityaraj/agentflow/internal/config"
+)
+
+// Expand replaces each task that uses a published workflow with the
+// workflow's tasks, named "<task>-<flow task>". The workflow's first tasks
+// inherit the using task's needs, and tasks that needed it wait for the
+// workflow's last tasks instead; {{outputs.<task>}} refers to the last task
+// when there is exactly one.
+//
+// The workflow's agents are added as "<task>-<agent>", unless cfg defines an
+// agent of the same name, which then runs those tasks instead. References
+// missing from lock are resolved and added to it; entries no longer used are
+// dropped. The caller saves lock if it changed.
+func Expand(cfg *config.AgentflowConfig, lock *Lock) error {
+	used := make(map[string]bool)
+	for _, name := range sortedKeys(cfg.Tasks) {
+		task := cfg.Tasks[name]
+		if task.Uses == "" {
+			continue
+		}
+		used[task.Uses] = true
+		if task.Agent != "" || task.Prompt != "" || task.PromptFile != "" || task.HasCommand() {
+			return fmt.Errorf("task %q: 'uses' cannot be combined with agent, prompt, prompt_file, or command", name)
+		}
+
+		flow, err := load(task.Uses, lock)
+		if err != nil {
+			return fmt.Errorf("task %q: %w", name, err)
+		}
+		if err := splice(cfg, name, task, flow); err != nil {
+			return fmt.Errorf("task %q: %w", name, err)
+		}
+	}
+	return lock.prune(used)
+}
+
+// load returns the workflow a reference points at, resolving it into lock
+// if it isn't pinned yet.
+func load(uses string, lock *Lock) (*config.AgentflowConfig, error) {
+	ref, err := ParseRef(uses)
+	if err != nil {
+		return nil, err
+	}
+	pinned, ok := lock.Flows[uses]
+	if !ok {
+		if lock.Frozen {
+			return nil, fmt.Errorf("%s is not pinned in %s (run without --frozen to pin it)", uses, LockFile)
+		}
+		if lock.Offline {
+			return nil, fmt.Errorf("%s is not pinned in %s (run without --offline to pin it)", uses, LockFile)
+		}
+		if pinned, err = resolve(ref); err != nil {
+			return nil, err
+		}
+	}
+
+	dir, err := fetch(ref, pinned.Commit, lock.Offline)
+	if err != nil {
+		return nil, err
+	}
+	dir = filepath.Join(dir, filepath.FromSlash(ref.Path))
+
+	// The checksum catches a cached checkout altered since it was pinned
+	sum, err := checksum(dir)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", uses, err)
+	}
+	if pinned.Checksum != "" && pinned.Checksum != sum {
+		return nil, fmt.Errorf("%s: files do not match the checksum in %s; delete %s to fetch them again", uses, LockFile, dir)
+	}
+	pinned.Checksum = sum
+	if err := lock.set(uses, pinned); err != nil {
+		return nil, err
+	}
+
+	path, err := config.FindCortexfile(dir)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", uses, err)
+	}
+	flow, err := config.LoadConfig(path)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", uses, err)
+	}
+	return flow, nil
+}
+
+// splice replaces task name in cfg with the tasks of flow.
+func splice(cfg *config.AgentflowConfig, name string, task config.TaskConfig, flow *config.AgentflowConfig) error {
+	if len(flow.Tasks) == 0 {
+		return fmt.Errorf("%s defines no tasks", task.Uses)
+	}
+
+	agents := make(map[string]string)
+	for _, agent := range sortedKeys(flow.Agents) {
+		if _, ok := cfg.Agents[agent]; ok {
+			agents[agent] = agent
+			continue
+		}
+		agents[agent] = name + "-" + agent
+		cfg.Agents[agents[agent]] = flow.Agents[agent]
+	}
+
+	renamed := make(map[string]string)
+	needed := make(map[string]bool)
+	for sub, subTask := range flow.Tasks {
+		if subTask.Uses != "" {
+			return fmt.Errorf("%s: task %q: nested 'uses' is not supported", task.Uses, sub)
+		}
+		renamed[sub] = name + "-" + sub
+		for _, dep := range subTask.Needs {
+			needed[dep] = true
+		}
+	}
+
+	var last []string
+	for _, sub := range sortedKeys(flow.Tasks) {
+		subTask := flow.Tasks[sub]
+		newName := renamed[sub]
+		if _, exists := cfg.Tasks[newName]; exists {
+			return fmt.Errorf("%s: task %q would replace existing task %q", task.Uses, sub, newName)
+		}
+
+		if a, ok := agents[subTask.Agent]; ok {
+			subTask.Agent = a
+		}
+		var needs config.StringList
+		for _, dep := range subTask.Needs {
+			if n, ok := renamed[dep]; ok {
+				dep = n
+			}
+			needs = append(needs, dep)
+		}
+		if len(needs) == 0 {
+			needs = append(needs, task.Needs...)
+		}
+		subTask.Needs = needs
+		subTask.Prompt = config.RenameOutputs(subTask.Prompt, renamed)
+		subTask.Command = config.RenameOutputs(subTask.Command, renamed)
+		subTask.CommandArgs = renameArgs(subTask.CommandArgs, renamed)
+		cfg.Tasks[newName] = subTask
+
+		if !needed[sub] {
+			last = append(last, newName)
+		}
+	}
+	delete(cfg.Tasks, name)
+
+	// Groups listing the using task run the whole workflow
+	for group, tasks := range cfg.Groups {
+		if i := slices.Index(tasks, name); i >= 0 {
+			cfg.Groups[group] = slices.Concat(tasks[:i], sortedValues(renamed), tasks[i+1:])
+		}
+	}
+
+	// Tasks that needed the using task now wait for the whole workflow
+	for other, t := range cfg.Tasks {
+		if !slices.Contains(t.Needs, name) {
+			continue
+		}
+		var needs config.StringList
+		for _, dep := range t.Needs {
+			if dep == name {
+				needs = append(needs, last...)
+			} else {
+				needs = append(needs, dep)
+			}
+		}
+		t.Needs = needs
+		if len(last) == 1 {
+			t.Prompt = config.RenameOutputs(t.Prompt, map[string]string{name: last[0]})
+			t.Command = config.RenameOutputs(t.Command, map[string]string{name: last[0]})
+			t.CommandArgs = renameArgs(t.CommandArgs, map[string]string{name: last[0]})
+		}
+		cfg.Tasks[other] = t
+	}
+	return nil
+}
+
+// renameArgs applies config.RenameOutputs to each argument of a command list.
+func renameArgs(args []string, names map[string]string) []string {
+	if args == nil {
+		return nil
+	}
+	renamed := make([]string, len(args))
+	for i, arg := range args {
+		renamed[i] = config.RenameOutputs(arg, names)
+	}
+	return renamed
+}
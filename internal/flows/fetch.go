@@ -0,0 +1,199 @@
+package flows
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/adityaraj/agentflow/internal/git"
+	"github.com/adityaraj/agentflow/internal/ui"
+)
+
+// commitRegex matches a full or abbreviated commit hash.
+var commitRegex = regexp.MustCompile(`^[0-9a-f]{7,40}$`)
+
+// resolve finds the commit a reference's version points at. A version like
+// "v2" or "v2.1" selects the highest tag in that series (v2.3.1); otherwise
+// it names a branch, tag, or commit. No version selects the default branch.
+func resolve(ref Ref) (LockedFlow, error) {
+	refs, err := git.RemoteRefs(ref.URL())
+	if err != nil {
+		return LockedFlow{}, fmt.Errorf("cannot list versions of %s: %w", ref.Raw, err)
+	}
+
+	if ref.Version == "" {
+		for _, branch := range []string{"main", "master"} {
+			if commit, ok := refs["refs/heads/"+branch]; ok {
+				return LockedFlow{Version: branch, Commit: commit}, nil
+			}
+		}
+		return LockedFlow{}, fmt.Errorf("%s: no main or master branch; add @version", ref.Raw)
+	}
+
+	if tag := latestInSeries(refs, ref.Version); tag != "" {
+		return LockedFlow{Version: tag, Commit: refs["refs/tags/"+tag]}, nil
+	}
+	for _, prefix := range []string{"refs/tags/", "refs/heads/"} {
+		if commit, ok := refs[prefix+ref.Version]; ok {
+			return LockedFlow{Version: ref.Version, Commit: commit}, nil
+		}
+	}
+	if commitRegex.MatchString(ref.Version) {
+		return LockedFlow{Commit: ref.Version}, nil
+	}
+	return LockedFlow{}, fmt.Errorf("%s: no tag, branch, or commit %q", ref.Raw, ref.Version)
+}
+
+// latestInSeries returns the highest semver tag equal to or within series
+// (v2 matches v2, v2.0.1, and v2.4.0, but not v20.0.0), or "" if none.
+func latestInSeries(refs map[string]string, series string) string {
+	want, ok := parseVersion(series)
+	if !ok {
+		return ""
+	}
+	var best string
+	var bestVersion []int
+	for name := range refs {
+		tag, ok := strings.CutPrefix(name, "refs/tags/")
+		if !ok || (tag != series && !strings.HasPrefix(tag, series+".")) {
+			continue
+		}
+		v, ok := parseVersion(tag)
+		if !ok || len(v) < len(want) {
+			continue
+		}
+		if best == "" || compareVersions(v, bestVersion) > 0 {
+			best, bestVersion = tag, v
+		}
+	}
+	return best
+}
+
+// parseVersion parses "v1.2.3" (or a prefix like "v1") into its numbers.
+func parseVersion(s string) ([]int, bool) {
+	s = strings.TrimPrefix(s, "v")
+	if s == "" {
+		return nil, false
+	}
+	var v []int
+	for _, part := range strings.Split(s, ".") {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return nil, false
+		}
+		v = append(v, n)
+	}
+	return v, true
+}
+
+// compareVersions compares two parsed versions; a missing part counts as 0.
+func compareVersions(a, b []int) int {
+	for i := 0; i < max(len(a), len(b)); i++ {
+		var x, y int
+		if i < len(a) {
+			x = a[i]
+		}
+		if i < len(b) {
+			y = b[i]
+		}
+		if x != y {
+			return x - y
+		}
+	}
+	return 0
+}
+
+// fetch returns the directory of a checkout of the reference's repository
+// at commit, cloning it into the cache on first use unless offline.
+func fetch(ref Ref, commit string, offline bool) (string, error) {
+	home, err := ui.GetCortexHome()
+	if err != nil {
+		return "", err
+	}
+	dir := filepath.Join(home, "flows", ref.Host, ref.Owner, ref.Repo, commit)
+	if _, err := os.Stat(dir); err == nil {
+		return dir, nil
+	}
+	if offline {
+		return "", fmt.Errorf("%s is not in the flow cache (run without --offline to fetch it)", ref.Raw)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(dir), 0755); err != nil {
+		return "", fmt.Errorf("failed to create flow cache: %w", err)
+	}
+	tmp, err := os.MkdirTemp(filepath.Dir(dir), ".fetch-")
+	if err != nil {
+		return "", fmt.Errorf("failed to create flow cache: %w", err)
+	}
+	defer os.RemoveAll(tmp)
+
+	checkout := filepath.Join(tmp, "repo")
+	if err := git.CloneAt(ref.URL(), commit, checkout); err != nil {
+		return "", fmt.Errorf("cannot fetch %s: %w", ref.Raw, err)
+	}
+	// Another process may have filled the cache meanwhile; either copy will do
+	if err := os.Rename(checkout, dir); err != nil {
+		if _, statErr := os.Stat(dir); statErr != nil {
+			return "", fmt.Errorf("failed to cache %s: %w", ref.Raw, err)
+		}
+	}
+	return dir, nil
+}
+
+// checksum hashes the paths and contents of the files under dir.
+func checksum(dir string) (string, error) {
+	h := sha256.New()
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if d.Name() == ".git" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(h, "%s\x00%d\x00", filepath.ToSlash(rel), len(data))
+		h.Write(data)
+		return nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to checksum workflow: %w", err)
+	}
+	return "sha256:" + hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// sortedKeys returns the keys of m in order.
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// sortedValues returns the values of m in order.
+func sortedValues(m map[string]string) []string {
+	values := make([]string, 0, len(m))
+	for _, v := range m {
+		values = append(values, v)
+	}
+	sort.Strings(values)
+	return values
+}
@@ -0,0 +1,253 @@
+package flows
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/adityaraj/agentflow/internal/config"
+)
+
+func TestParseRef(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Ref
+		wantErr bool
+	}{
+		{in: "github.com/org/cortex-flows/review@v2", want: Ref{Host: "github.com", Owner: "org", Repo: "cortex-flows", Path: "review", Version: "v2"}},
+		{in: "gitlab.com/org/flows", want: Ref{Host: "gitlab.com", Owner: "org", Repo: "flows"}},
+		{in: "github.com/org/flows/a/b@main", want: Ref{Host: "github.com", Owner: "org", Repo: "flows", Path: "a/b", Version: "main"}},
+		{in: "org/flows@v1", wantErr: true},
+		{in: "github.com/org/flows/../x", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseRef(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseRef(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		tt.want.Raw = tt.in
+		if !tt.wantErr && got != tt.want {
+			t.Errorf("ParseRef(%q) = %+v, want %+v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestLatestInSeries(t *testing.T) {
+	refs := map[string]string{
+		"refs/tags/v1.9.0":  "a",
+		"refs/tags/v2":      "b",
+		"refs/tags/v2.0.1":  "c",
+		"refs/tags/v2.10.0": "d",
+		"refs/tags/v2.9.3":  "e",
+		"refs/tags/v20.0.0": "f",
+		"refs/heads/v2.99":  "g",
+	}
+	tests := map[string]string{"v2": "v2.10.0", "v2.9": "v2.9.3", "v1": "v1.9.0", "v3": "", "main": ""}
+	for series, want := range tests {
+		if got := latestInSeries(refs, series); got != want {
+			t.Errorf("latestInSeries(%q) = %q, want %q", series, got, want)
+		}
+	}
+}
+
+// gitRun runs git in dir, failing the test on error.
+func gitRun(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	out, err := exec.Command("git", append([]string{"-C", dir}, args...)...).CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %v: %v\n%s", args, err, out)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+func TestExpandAndUpdate(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available")
+	}
+	t.Setenv("HOME", t.TempDir())
+
+	// A flow repository with a review workflow, tagged v2.0.0
+	repo := t.TempDir()
+	gitRun(t, repo, "init", "-q")
+	gitRun(t, repo, "config", "user.email", "test@example.com")
+	gitRun(t, repo, "config", "user.name", "test")
+	writeFlow := func(prompt string) {
+		if err := os.MkdirAll(filepath.Join(repo, "review"), 0755); err != nil {
+			t.Fatal(err)
+		}
+		flow := `agents:
+  reviewer: {tool: claude-code}
+  writer: {tool: claude-code}
+tasks:
+  analyze: {agent: reviewer, prompt: "` + prompt + `"}
+  summarize:
+    agent: writer
+    needs: [analyze]
+    prompt: "Summarize {{outputs.analyze}}"
+`
+		if err := os.WriteFile(filepath.Join(repo, "review", "Cortexfile.yml"), []byte(flow), 0644); err != nil {
+			t.Fatal(err)
+		}
+		gitRun(t, repo, "add", ".")
+		gitRun(t, repo, "commit", "-qm", prompt)
+	}
+	writeFlow("Review v2.0.0")
+	gitRun(t, repo, "tag", "v2.0.0")
+	v200 := gitRun(t, repo, "rev-parse", "HEAD")
+
+	orig := cloneURL
+	cloneURL = func(Ref) string { return repo }
+	defer func() { cloneURL = orig }()
+
+	load := func() *config.AgentflowConfig {
+		cfg, err := config.ParseConfig([]byte(`agents:
+  coder: {tool: claude-code}
+  writer: {tool: opencode}
+tasks:
+  implement: {agent: coder, prompt: "Implement"}
+  review:
+    uses: github.com/org/flows/review@v2
+    needs: [implement]
+  fix:
+    agent: coder
+    needs: [review]
+    prompt: "Fix {{outputs.review}}"
+groups:
+  check: [review]
+`), t.TempDir())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return cfg
+	}
+
+	lockDir := t.TempDir()
+	lock, _ := LoadLock(lockDir)
+	cfg := load()
+	if err := Expand(cfg, lock); err != nil {
+		t.Fatalf("Expand() error = %v", err)
+	}
+
+	if _, ok := cfg.Tasks["review"]; ok {
+		t.Error("using task was not replaced")
+	}
+	analyze, summarize, fix := cfg.Tasks["review-analyze"], cfg.Tasks["review-summarize"], cfg.Tasks["fix"]
+	if analyze.Agent != "review-reviewer" || analyze.Prompt != "Review v2.0.0" || strings.Join(analyze.Needs, ",") != "implement" {
+		t.Errorf("review-analyze = %+v", analyze)
+	}
+	if summarize.Agent != "writer" || summarize.Prompt != "Summarize {{outputs.review-analyze}}" {
+		t.Errorf("review-summarize = %+v, want the Cortexfile's writer agent and renamed outputs", summarize)
+	}
+	if strings.Join(fix.Needs, ",") != "review-summarize" || fix.Prompt != "Fix {{outputs.review-summarize}}" {
+		t.Errorf("fix = %+v", fix)
+	}
+	if got := strings.Join(cfg.Groups["check"], ","); got != "review-analyze,review-summarize" {
+		t.Errorf("group check = %s, want the workflow's tasks", got)
+	}
+	if err := config.Validate(cfg); err != nil {
+		t.Errorf("expanded config is invalid: %v", err)
+	}
+
+	if got := lock.Flows["github.com/org/flows/review@v2"]; got.Version != "v2.0.0" || got.Commit != v200 || !lock.Changed() {
+		t.Errorf("lock = %+v, changed %v", got, lock.Changed())
+	}
+	if err := lock.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	// A new release is picked up by Update, not by a plain load
+	writeFlow("Review v2.1.0")
+	gitRun(t, repo, "tag", "v2.1.0")
+
+	lock, _ = LoadLock(lockDir)
+	cfg = load()
+	if err := Expand(cfg, lock); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Tasks["review-analyze"].Prompt != "Review v2.0.0" {
+		t.Errorf("locked flow moved to %q without update", cfg.Tasks["review-analyze"].Prompt)
+	}
+
+	changes, err := Update(load(), lock, nil)
+	if err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	if len(changes) != 1 || changes[0].From.Version != "v2.0.0" || changes[0].To.Version != "v2.1.0" {
+		t.Errorf("Update() = %+v", changes)
+	}
+	cfg = load()
+	if err := Expand(cfg, lock); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Tasks["review-analyze"].Prompt != "Review v2.1.0" {
+		t.Errorf("after update, prompt = %q", cfg.Tasks["review-analyze"].Prompt)
+	}
+
+	// Files changed after pinning fail the checksum
+	pinned := lock.Flows["github.com/org/flows/review@v2"]
+	cached := filepath.Join(os.Getenv("HOME"), ".cortex", "flows", "github.com", "org", "flows", pinned.Commit, "review", "Cortexfile.yml")
+	if err := os.WriteFile(cached, []byte("tasks: {}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := Expand(load(), lock); err == nil || !strings.Contains(err.Error(), "checksum") {
+		t.Errorf("Expand() with altered files error = %v, want checksum mismatch", err)
+	}
+
+	// A frozen lock refuses to pin new references
+	frozen, _ := LoadLock(t.TempDir())
+	frozen.Frozen = true
+	if err := Expand(load(), frozen); err == nil || !strings.Contains(err.Error(), "not pinned") {
+		t.Errorf("Expand() with frozen empty lock error = %v, want not pinned", err)
+	}
+
+	// Offline, references must be pinned and cached
+	offline, _ := LoadLock(t.TempDir())
+	offline.Offline = true
+	if err := Expand(load(), offline); err == nil || !strings.Contains(err.Error(), "not pinned") {
+		t.Errorf("Expand() offline with empty lock error = %v, want not pinned", err)
+	}
+	offline.Flows["github.com/org/flows/review@v2"] = LockedFlow{Version: "v2.2.0", Commit: strings.Repeat("0", 40)}
+	if err := Expand(load(), offline); err == nil || !strings.Contains(err.Error(), "not in the flow cache") {
+		t.Errorf("Expand() offline with uncached commit error = %v, want not in the flow cache", err)
+	}
+}
+
+func TestLockCheckTools(t *testing.T) {
+	lock, _ := LoadLock(t.TempDir())
+	if notes, err := lock.CheckTools(map[string]string{"claude-code": "1.0.0"}); err != nil || len(notes) != 0 || !lock.Changed() {
+		t.Fatalf("first CheckTools() = %v, %v; changed %v", notes, err, lock.Changed())
+	}
+	if err := lock.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	notes, err := lock.CheckTools(map[string]string{"claude-code": "1.1.0"})
+	if err != nil || len(notes) != 1 || lock.Tools["claude-code"] != "1.1.0" {
+		t.Errorf("CheckTools() after upgrade = %v, %v; tools %v", notes, err, lock.Tools)
+	}
+
+	lock.Frozen = true
+	if _, err := lock.CheckTools(map[string]string{"claude-code": "1.2.0"}); err == nil {
+		t.Error("frozen CheckTools() with a new version succeeded, want error")
+	}
+	if _, err := lock.CheckTools(map[string]string{"claude-code": "1.1.0"}); err != nil {
+		t.Errorf("frozen CheckTools() with the pinned version error = %v", err)
+	}
+}
+
+func TestLockPruneKeep(t *testing.T) {
+	lock, _ := LoadLock(t.TempDir())
+	lock.Flows["github.com/org/flows/review@v2"] = LockedFlow{Commit: "abc1234"}
+	lock.Flows["github.com/org/flows/release@v1"] = LockedFlow{Commit: "def5678"}
+	lock.Keep = map[string]bool{"github.com/org/flows/release@v1": true}
+
+	if err := lock.prune(map[string]bool{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := lock.Flows["github.com/org/flows/release@v1"]; !ok || len(lock.Flows) != 1 {
+		t.Errorf("after prune, flows = %v; want only the kept reference", lock.Flows)
+	}
+}
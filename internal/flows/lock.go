@@ -0,0 +1,139 @@
+package flows
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+)
+
+// LockFile pins each uses reference to a commit and content checksum, and
+// records the versions of the agent CLIs the workflow ran with. It lives
+// beside the Cortexfile and should be committed, so every run is reproducible.
+const LockFile = "cortex.lock"
+
+const lockHeader = "# Generated by cortex. Run 'cortex update' to move to newer flow versions.\n"
+
+// Lock is the parsed contents of cortex.lock.
+type Lock struct {
+	Flows map[string]LockedFlow `yaml:"flows,omitempty"` // Keyed by uses reference
+	Tools map[string]string     `yaml:"tools,omitempty"` // Tool name to CLI version
+
+	// Frozen forbids any change to the lock (--frozen): unpinned references,
+	// new or changed tool versions, and stale entries become errors.
+	Frozen bool `yaml:"-"`
+
+	// Offline forbids network access (--offline): references must already
+	// be pinned and their commits in the flow cache.
+	Offline bool `yaml:"-"`
+
+	// Keep lists references in use outside the tasks being expanded, such
+	// as those of other workflows in the Cortexfile, so they aren't dropped.
+	Keep map[string]bool `yaml:"-"`
+
+	path    string
+	changed bool
+}
+
+// LockedFlow is the version a uses reference resolved to.
+type LockedFlow struct {
+	Version  string `yaml:"version,omitempty"` // Tag or branch the reference resolved to
+	Commit   string `yaml:"commit"`
+	Checksum string `yaml:"checksum,omitempty"` // sha256 of the workflow's files
+}
+
+// LoadLock reads the lockfile in dir. A missing lockfile yields an empty lock.
+func LoadLock(dir string) (*Lock, error) {
+	lock := &Lock{Flows: make(map[string]LockedFlow), Tools: make(map[string]string), path: filepath.Join(dir, LockFile)}
+	data, err := os.ReadFile(lock.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return lock, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", LockFile, err)
+	}
+	if err := yaml.Unmarshal(data, lock); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", LockFile, err)
+	}
+	if lock.Flows == nil {
+		lock.Flows = make(map[string]LockedFlow)
+	}
+	if lock.Tools == nil {
+		lock.Tools = make(map[string]string)
+	}
+	return lock, nil
+}
+
+// Changed reports whether entries were added or updated since loading.
+func (l *Lock) Changed() bool {
+	return l.changed
+}
+
+// set records the version a reference resolved to.
+func (l *Lock) set(uses string, flow LockedFlow) error {
+	if l.Flows[uses] == flow {
+		return nil
+	}
+	if l.Frozen {
+		return fmt.Errorf("%s would change in %s (run without --frozen to update it)", uses, LockFile)
+	}
+	l.Flows[uses] = flow
+	l.changed = true
+	return nil
+}
+
+// prune drops entries for references no longer in use.
+func (l *Lock) prune(used map[string]bool) error {
+	for _, uses := range sortedKeys(l.Flows) {
+		if used[uses] || l.Keep[uses] {
+			continue
+		}
+		if l.Frozen {
+			return fmt.Errorf("%s pins %s, which no task uses (run without --frozen to drop it)", LockFile, uses)
+		}
+		delete(l.Flows, uses)
+		l.changed = true
+	}
+	return nil
+}
+
+// CheckTools compares the CLI versions found now, by tool, with those in
+// the lock and records new and changed ones. It returns a note for each
+// pinned version that changed; in a frozen lock any difference is an error.
+func (l *Lock) CheckTools(versions map[string]string) ([]string, error) {
+	var notes []string
+	for _, tool := range sortedKeys(versions) {
+		version, pinned := versions[tool], l.Tools[tool]
+		if version == pinned {
+			continue
+		}
+		if l.Frozen {
+			if pinned == "" {
+				return nil, fmt.Errorf("%s version is not recorded in %s (run without --frozen to record it)", tool, LockFile)
+			}
+			return nil, fmt.Errorf("%s is %s, but %s requires %s", tool, version, LockFile, pinned)
+		}
+		if pinned != "" {
+			notes = append(notes, fmt.Sprintf("%s changed from %s to %s", tool, pinned, version))
+		}
+		l.Tools[tool] = version
+		l.changed = true
+	}
+	return notes, nil
+}
+
+// Save writes the lockfile.
+func (l *Lock) Save() error {
+	data, err := yaml.Marshal(l)
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s: %w", LockFile, err)
+	}
+	if err := os.WriteFile(l.path, append([]byte(lockHeader), data...), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", LockFile, err)
+	}
+	l.changed = false
+	return nil
+}
@@ -0,0 +1,52 @@
+// Package flows resolves tasks that use published workflows
+// ("uses: github.com/org/cortex-flows/review@v2"): it pins each reference to
+// a commit in cortex.lock, caches the checkout under ~/.cortex/flows, and
+// splices the workflow's tasks into the Cortexfile. It also fetches whole
+// Cortexfiles run from URLs and git references (see FetchConfig).
+package flows
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Ref is a parsed uses reference: host/owner/repo[/path][@version].
+type Ref struct {
+	Raw     string // As written in the Cortexfile
+	Host    string // e.g. github.com
+	Owner   string
+	Repo    string
+	Path    string // Directory of the workflow within the repository ("" = root)
+	Version string // Tag series (v2), tag, branch, or commit ("" = default branch)
+}
+
+// cloneURL returns the URL a reference's repository is fetched from.
+// Tests point it at local repositories.
+var cloneURL = func(r Ref) string {
+	return "https://" + r.Host + "/" + r.Owner + "/" + r.Repo + ".git"
+}
+
+// ParseRef parses a uses reference.
+func ParseRef(s string) (Ref, error) {
+	ref := Ref{Raw: s}
+	location, version, _ := strings.Cut(s, "@")
+	ref.Version = version
+
+	parts := strings.Split(strings.Trim(location, "/"), "/")
+	if len(parts) < 3 || !strings.Contains(parts[0], ".") {
+		return Ref{}, fmt.Errorf("invalid uses %q: expected host/owner/repo[/path][@version], e.g. github.com/org/cortex-flows/review@v2", s)
+	}
+	for _, p := range parts {
+		if p == "" || p == "." || p == ".." {
+			return Ref{}, fmt.Errorf("invalid uses %q: empty or relative path segment", s)
+		}
+	}
+	ref.Host, ref.Owner, ref.Repo = parts[0], parts[1], parts[2]
+	ref.Path = strings.Join(parts[3:], "/")
+	return ref, nil
+}
+
+// URL returns the repository URL.
+func (r Ref) URL() string {
+	return cloneURL(r)
+}
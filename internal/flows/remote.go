@@ -0,0 +1,220 @@
+package flows
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/adityaraj/agentflow/internal/config"
+	"github.com/adityaraj/agentflow/internal/network"
+	"github.com/adityaraj/agentflow/internal/ui"
+)
+
+// gitPrefix starts a reference to a Cortexfile in a git repository.
+const gitPrefix = "git::"
+
+// httpClient returns the client remote Cortexfiles are downloaded with.
+var httpClient = func() *http.Client {
+	return network.Client(30 * time.Second)
+}
+
+// IsRemote reports whether spec names a remote Cortexfile rather than a
+// local path: an http(s) URL or a git reference (see FetchConfig).
+func IsRemote(spec string) bool {
+	return strings.HasPrefix(spec, "https://") || strings.HasPrefix(spec, "http://") || strings.HasPrefix(spec, gitPrefix)
+}
+
+// FetchConfig fetches the remote Cortexfile spec names and returns the
+// path of its copy in the cache under ~/.cortex/remote, and the sha256
+// checksum of its contents ("sha256:<hex>"). spec is one of:
+//
+//	https://example.com/flows/Cortexfile.yml
+//	git::github.com/org/repo//flows/review.yml@v1
+//
+// A git reference's version resolves as for published workflows (a tag
+// series, tag, branch, or commit), and the repository is checked out in
+// the flow cache, so the Cortexfile's relative includes and prompt files
+// work. Either may end in "#sha256=<hex>" to pin the file's checksum: a
+// pinned file is only used if it matches, and is served from the cache
+// without fetching once it's there. Unpinned files are fetched on every
+// run, unless offline, when the last copy fetched is used.
+func FetchConfig(spec string, offline bool) (path, sum string, err error) {
+	location, pin, err := splitPin(spec)
+	if err != nil {
+		return "", "", err
+	}
+	home, err := ui.GetCortexHome()
+	if err != nil {
+		return "", "", err
+	}
+	key := sha256.Sum256([]byte(location))
+	cache := filepath.Join(home, "remote", hex.EncodeToString(key[:8]))
+
+	if strings.HasPrefix(location, gitPrefix) {
+		path, err = fetchGitConfig(location, cache, offline)
+	} else {
+		path, err = fetchURLConfig(location, cache, pin, offline)
+	}
+	if err != nil {
+		return "", "", err
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", "", fmt.Errorf("%s: %w", location, err)
+	}
+	sum = fileChecksum(data)
+	if pin != "" && pin != sum {
+		return "", "", fmt.Errorf("%s: contents don't match the pinned checksum (got %s, want %s)", location, sum, pin)
+	}
+	return path, sum, nil
+}
+
+// splitPin splits "#sha256=<hex>" off the end of spec, returning the
+// checksum as "sha256:<hex>".
+func splitPin(spec string) (location, pin string, err error) {
+	location, fragment, ok := strings.Cut(spec, "#")
+	if !ok {
+		return spec, "", nil
+	}
+	digest, ok := strings.CutPrefix(fragment, "sha256=")
+	if _, err := hex.DecodeString(digest); !ok || err != nil || len(digest) != sha256.Size*2 {
+		return "", "", fmt.Errorf("invalid checksum in %s: use #sha256= and 64 hex digits", spec)
+	}
+	return location, "sha256:" + strings.ToLower(digest), nil
+}
+
+// fileChecksum returns the sha256 checksum of a file's contents.
+func fileChecksum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// fetchURLConfig downloads a Cortexfile into cache, keeping its file name
+// so its format is recognized. A cached copy matching pin is used as is.
+func fetchURLConfig(location, cache, pin string, offline bool) (string, error) {
+	u, err := url.Parse(location)
+	if err != nil {
+		return "", fmt.Errorf("invalid URL %s: %w", location, err)
+	}
+	if u.Scheme != "https" {
+		return "", fmt.Errorf("%s: remote Cortexfiles must be fetched over https", location)
+	}
+	name := path.Base(u.Path)
+	if name == "." || name == "/" {
+		name = "Cortexfile.yml"
+	}
+	dest := filepath.Join(cache, name)
+
+	if data, err := os.ReadFile(dest); err == nil && (offline || pin != "" && fileChecksum(data) == pin) {
+		return dest, nil
+	}
+	if offline {
+		return "", fmt.Errorf("%s is not in the cache (run without --offline to fetch it)", location)
+	}
+
+	resp, err := httpClient().Get(location)
+	if err != nil {
+		return "", fmt.Errorf("cannot fetch %s: %w", location, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("cannot fetch %s: %s", location, resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, config.MaxConfigBytes+1))
+	if err != nil {
+		return "", fmt.Errorf("cannot fetch %s: %w", location, err)
+	}
+	if len(data) > config.MaxConfigBytes {
+		return "", fmt.Errorf("%s is larger than the %d MiB config limit", location, config.MaxConfigBytes>>20)
+	}
+
+	if err := os.MkdirAll(cache, 0755); err != nil {
+		return "", fmt.Errorf("failed to create remote config cache: %w", err)
+	}
+	tmp, err := os.CreateTemp(cache, ".fetch-")
+	if err != nil {
+		return "", fmt.Errorf("failed to cache %s: %w", location, err)
+	}
+	defer os.Remove(tmp.Name())
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), dest)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to cache %s: %w", location, err)
+	}
+	return dest, nil
+}
+
+// fetchGitConfig checks out the repository of a git:: reference in the
+// flow cache and returns the path of the Cortexfile in it. The commit its
+// version resolved to is recorded in cache for offline runs.
+func fetchGitConfig(location, cache string, offline bool) (string, error) {
+	repo, file, ok := strings.Cut(strings.TrimPrefix(location, gitPrefix), "//")
+	version := ""
+	if i := strings.LastIndex(file, "@"); i >= 0 {
+		file, version = file[:i], file[i+1:]
+	}
+	if !ok || file == "" {
+		return "", fmt.Errorf("invalid %s: expected git::host/owner/repo//path/to/Cortexfile.yml[@version]", location)
+	}
+	for _, p := range strings.Split(file, "/") {
+		if p == "" || p == "." || p == ".." {
+			return "", fmt.Errorf("invalid %s: empty or relative path segment", location)
+		}
+	}
+	if strings.Count(strings.Trim(repo, "/"), "/") != 2 {
+		return "", fmt.Errorf("invalid %s: expected git::host/owner/repo//path/to/Cortexfile.yml[@version]", location)
+	}
+	raw := repo
+	if version != "" {
+		raw += "@" + version
+	}
+	ref, err := ParseRef(raw)
+	if err != nil {
+		return "", fmt.Errorf("invalid %s: %w", location, err)
+	}
+	ref.Raw = location
+
+	commitFile := filepath.Join(cache, "commit")
+	var commit string
+	if offline {
+		data, err := os.ReadFile(commitFile)
+		if err != nil {
+			return "", fmt.Errorf("%s is not in the cache (run without --offline to fetch it)", location)
+		}
+		commit = strings.TrimSpace(string(data))
+	} else {
+		pinned, err := resolve(ref)
+		if err != nil {
+			return "", err
+		}
+		commit = pinned.Commit
+	}
+
+	dir, err := fetch(ref, commit, offline)
+	if err != nil {
+		return "", err
+	}
+	if !offline {
+		if err := os.MkdirAll(cache, 0755); err != nil {
+			return "", fmt.Errorf("failed to create remote config cache: %w", err)
+		}
+		if err := os.WriteFile(commitFile, []byte(commit+"\n"), 0644); err != nil {
+			return "", fmt.Errorf("failed to cache %s: %w", location, err)
+		}
+	}
+	return filepath.Join(dir, filepath.FromSlash(file)), nil
+}
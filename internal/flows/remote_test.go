@@ -0,0 +1,122 @@
+package flows
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFetchConfig_URL(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	body := "agents:\n  a: {tool: shell}\n"
+	requests := 0
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.URL.Path != "/flows/Cortexfile.yml" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(body))
+	}))
+	defer srv.Close()
+	orig := httpClient
+	httpClient = srv.Client
+	defer func() { httpClient = orig }()
+
+	url := srv.URL + "/flows/Cortexfile.yml"
+	path, sum, err := FetchConfig(url, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != body || filepath.Base(path) != "Cortexfile.yml" {
+		t.Errorf("FetchConfig() = %s containing %q", path, data)
+	}
+	if sum != fileChecksum([]byte(body)) {
+		t.Errorf("FetchConfig() sum = %s", sum)
+	}
+
+	// A pinned copy in the cache is used without fetching
+	requests = 0
+	pin := url + "#sha256=" + strings.TrimPrefix(sum, "sha256:")
+	if _, _, err := FetchConfig(pin, false); err != nil || requests != 0 {
+		t.Errorf("FetchConfig(pinned) error = %v after %d requests", err, requests)
+	}
+	if _, _, err := FetchConfig(url, true); err != nil || requests != 0 {
+		t.Errorf("FetchConfig(offline) error = %v after %d requests", err, requests)
+	}
+
+	// A changed file no longer matches its pin
+	body = "agents: {}\n"
+	if _, _, err := FetchConfig(url, false); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := FetchConfig(pin, false); err == nil || !strings.Contains(err.Error(), "don't match the pinned checksum") {
+		t.Errorf("FetchConfig(stale pin) error = %v", err)
+	}
+
+	for spec, want := range map[string]string{
+		srv.URL + "/missing.yml":                                 "404 Not Found",
+		"http://example.com/Cortexfile":                          "over https",
+		url + "#md5=abc":                                         "invalid checksum",
+		srv.URL + "/other.yml#sha256=" + strings.Repeat("0", 64): "404",
+	} {
+		if _, _, err := FetchConfig(spec, false); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("FetchConfig(%s) error = %v, want %q", spec, err, want)
+		}
+	}
+	if _, _, err := FetchConfig(srv.URL+"/uncached.yml", true); err == nil || !strings.Contains(err.Error(), "not in the cache") {
+		t.Errorf("FetchConfig(offline, uncached) error = %v", err)
+	}
+}
+
+func TestFetchConfig_Git(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available")
+	}
+	t.Setenv("HOME", t.TempDir())
+
+	repo := t.TempDir()
+	gitRun(t, repo, "init", "-q")
+	gitRun(t, repo, "config", "user.email", "test@example.com")
+	gitRun(t, repo, "config", "user.name", "test")
+	if err := os.MkdirAll(filepath.Join(repo, "flows"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(repo, "flows", "review.yml"), []byte("agents: {}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	gitRun(t, repo, "add", ".")
+	gitRun(t, repo, "commit", "-qm", "review")
+	gitRun(t, repo, "tag", "v1.0.0")
+	commit := gitRun(t, repo, "rev-parse", "HEAD")
+
+	orig := cloneURL
+	cloneURL = func(Ref) string { return repo }
+	defer func() { cloneURL = orig }()
+
+	spec := "git::github.com/org/flows//flows/review.yml@v1"
+	path, _, err := FetchConfig(spec, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(path, commit) || filepath.Base(path) != "review.yml" {
+		t.Errorf("FetchConfig() = %s, want the review.yml of %s", path, commit)
+	}
+	if offline, _, err := FetchConfig(spec, true); err != nil || offline != path {
+		t.Errorf("FetchConfig(offline) = %s, %v, want %s", offline, err, path)
+	}
+
+	for _, spec := range []string{
+		"git::github.com/org/flows/review.yml@v1",
+		"git::github.com/org/flows//../review.yml",
+		"git::github.com/flows//review.yml",
+	} {
+		if _, _, err := FetchConfig(spec, false); err == nil || !strings.Contains(err.Error(), "invalid") {
+			t.Errorf("FetchConfig(%s) error = %v, want invalid", spec, err)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package flows
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/adityaraj/agentflow/internal/config"
+)
+
+// Change is a reference whose pinned version moved.
+type Change struct {
+	Uses     string
+	From, To LockedFlow
+}
+
+// References returns the uses references of cfg's tasks, including those
+// of its named workflows.
+func References(cfg *config.AgentflowConfig) map[string]bool {
+	refs := make(map[string]bool)
+	for _, task := range cfg.Tasks {
+		if task.Uses != "" {
+			refs[task.Uses] = true
+		}
+	}
+	for _, workflow := range cfg.Workflows {
+		for _, task := range workflow.Tasks {
+			if task.Uses != "" {
+				refs[task.Uses] = true
+			}
+		}
+	}
+	return refs
+}
+
+// Update resolves the uses references in cfg again, or only those listed in
+// only, picking up new tags in their series and new branch commits. New
+// versions are fetched and recorded in lock; the caller saves it.
+func Update(cfg *config.AgentflowConfig, lock *Lock, only []string) ([]Change, error) {
+	refs := References(cfg)
+	targets := sortedKeys(refs)
+	if len(only) > 0 {
+		for _, uses := range only {
+			if !refs[uses] {
+				if suggestions := config.DidYouMean(uses, sortedKeys(refs)); suggestions != "" {
+					return nil, fmt.Errorf("no task uses %q; did you mean %s?", uses, suggestions)
+				}
+				return nil, fmt.Errorf("no task uses %q", uses)
+			}
+		}
+		targets = only
+	}
+
+	var changes []Change
+	for _, uses := range targets {
+		ref, err := ParseRef(uses)
+		if err != nil {
+			return nil, err
+		}
+		latest, err := resolve(ref)
+		if err != nil {
+			return nil, err
+		}
+		dir, err := fetch(ref, latest.Commit, false)
+		if err != nil {
+			return nil, err
+		}
+		if latest.Checksum, err = checksum(filepath.Join(dir, filepath.FromSlash(ref.Path))); err != nil {
+			return nil, fmt.Errorf("%s: %w", uses, err)
+		}
+
+		if current := lock.Flows[uses]; current != latest {
+			if err := lock.set(uses, latest); err != nil {
+				return nil, err
+			}
+			changes = append(changes, Change{Uses: uses, From: current, To: latest})
+		}
+	}
+	return changes, nil
+}
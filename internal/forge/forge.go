@@ -0,0 +1,183 @@
+// Package forge opens pull requests on hosted git services (GitHub, GitLab).
+package forge
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/adityaraj/agentflow/internal/network"
+)
+
+// Supported forge kinds.
+const (
+	GitHub = "github"
+	GitLab = "gitlab"
+)
+
+// Repo identifies a repository on a forge, parsed from a git remote URL.
+type Repo struct {
+	Kind string // GitHub or GitLab
+	Host string // e.g. "github.com"
+	Path string // e.g. "owner/repo" (GitLab paths may include subgroups)
+}
+
+// PullRequest describes a pull (or merge) request to open.
+type PullRequest struct {
+	Title string
+	Body  string
+	Head  string // Source branch
+	Base  string // Target branch
+}
+
+// ParseRemote parses an SSH or HTTPS git remote URL.
+func ParseRemote(remote string) (*Repo, error) {
+	remote = strings.TrimSpace(remote)
+
+	var host, path string
+	switch {
+	case strings.Contains(remote, "://"):
+		u, err := url.Parse(remote)
+		if err != nil {
+			return nil, fmt.Errorf("invalid remote URL %q: %w", remote, err)
+		}
+		host, path = u.Hostname(), u.Path
+	case strings.Contains(remote, ":"):
+		// scp-like syntax: git@github.com:owner/repo.git
+		at := strings.LastIndex(remote, "@")
+		rest := remote[at+1:]
+		colon := strings.Index(rest, ":")
+		host, path = rest[:colon], rest[colon+1:]
+	default:
+		return nil, fmt.Errorf("unrecognized remote URL %q", remote)
+	}
+
+	path = strings.TrimSuffix(strings.Trim(path, "/"), ".git")
+	if host == "" || !strings.Contains(path, "/") {
+		return nil, fmt.Errorf("unrecognized remote URL %q", remote)
+	}
+
+	repo := &Repo{Host: host, Path: path}
+	switch {
+	case strings.Contains(host, "github"):
+		repo.Kind = GitHub
+	case strings.Contains(host, "gitlab"):
+		repo.Kind = GitLab
+	default:
+		return nil, fmt.Errorf("unsupported forge host %q (only GitHub and GitLab are supported)", host)
+	}
+	return repo, nil
+}
+
+// OpenPullRequest creates a pull request (GitHub) or merge request (GitLab)
+// and returns its web URL. Tokens are read from GITHUB_TOKEN/GH_TOKEN or GITLAB_TOKEN.
+func (r *Repo) OpenPullRequest(ctx context.Context, pr PullRequest) (string, error) {
+	switch r.Kind {
+	case GitHub:
+		return r.openGitHub(ctx, pr)
+	case GitLab:
+		return r.openGitLab(ctx, pr)
+	default:
+		return "", fmt.Errorf("unsupported forge %q", r.Kind)
+	}
+}
+
+func (r *Repo) openGitHub(ctx context.Context, pr PullRequest) (string, error) {
+	token := os.Getenv("GITHUB_TOKEN")
+	if token == "" {
+		token = os.Getenv("GH_TOKEN")
+	}
+	if token == "" {
+		return "", fmt.Errorf("GITHUB_TOKEN is not set")
+	}
+
+	api := "https://api.github.com"
+	if r.Host != "github.com" {
+		api = "https://" + r.Host + "/api/v3" // GitHub Enterprise
+	}
+
+	payload := map[string]string{
+		"title": pr.Title,
+		"body":  pr.Body,
+		"head":  pr.Head,
+		"base":  pr.Base,
+	}
+	headers := map[string]string{
+		"Authorization": "Bearer " + token,
+		"Accept":        "application/vnd.github+json",
+	}
+
+	var resp struct {
+		HTMLURL string `json:"html_url"`
+	}
+	if err := post(ctx, api+"/repos/"+r.Path+"/pulls", headers, payload, &resp); err != nil {
+		return "", err
+	}
+	return resp.HTMLURL, nil
+}
+
+func (r *Repo) openGitLab(ctx context.Context, pr PullRequest) (string, error) {
+	token := os.Getenv("GITLAB_TOKEN")
+	if token == "" {
+		return "", fmt.Errorf("GITLAB_TOKEN is not set")
+	}
+
+	endpoint := fmt.Sprintf("https://%s/api/v4/projects/%s/merge_requests", r.Host, url.PathEscape(r.Path))
+	payload := map[string]string{
+		"title":         pr.Title,
+		"description":   pr.Body,
+		"source_branch": pr.Head,
+		"target_branch": pr.Base,
+	}
+	headers := map[string]string{"PRIVATE-TOKEN": token}
+
+	var resp struct {
+		WebURL string `json:"web_url"`
+	}
+	if err := post(ctx, endpoint, headers, payload, &resp); err != nil {
+		return "", err
+	}
+	return resp.WebURL, nil
+}
+
+// post sends a JSON request and decodes a JSON response into out.
+func post(ctx context.Context, endpoint string, headers map[string]string, payload, out any) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := network.Client(0).Do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("%s returned status %d: %s", endpoint, resp.StatusCode, strings.TrimSpace(string(data)))
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,38 @@
+package forge
+
+import "testing"
+
+func TestParseRemote(t *testing.T) {
+	tests := []struct {
+		remote   string
+		wantKind string
+		wantHost string
+		wantPath string
+		wantErr  bool
+	}{
+		{"git@github.com:owner/repo.git", GitHub, "github.com", "owner/repo", false},
+		{"https://github.com/owner/repo", GitHub, "github.com", "owner/repo", false},
+		{"ssh://git@github.example.com/owner/repo.git", GitHub, "github.example.com", "owner/repo", false},
+		{"https://gitlab.com/group/sub/repo.git", GitLab, "gitlab.com", "group/sub/repo", false},
+		{"https://bitbucket.org/owner/repo.git", "", "", "", true},
+		{"/local/path/repo", "", "", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.remote, func(t *testing.T) {
+			repo, err := ParseRemote(tt.remote)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got %+v", repo)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if repo.Kind != tt.wantKind || repo.Host != tt.wantHost || repo.Path != tt.wantPath {
+				t.Errorf("got %+v, want kind=%s host=%s path=%s", repo, tt.wantKind, tt.wantHost, tt.wantPath)
+			}
+		})
+	}
+}
@@ -0,0 +1,495 @@
+// Package git wraps the git CLI for inspecting and restoring working trees.
+package git
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Baseline captures the state of a working tree before an agent runs,
+// so its changes can be measured and reverted without touching prior work.
+type Baseline struct {
+	Dir       string          // Working tree root the baseline was taken in
+	Commit    string          // Commit holding the tree state (HEAD or a stash commit)
+	Untracked map[string]bool // Untracked files that already existed
+}
+
+// FileChange describes how a single file differs from a baseline.
+type FileChange struct {
+	Path    string `json:"path"`
+	Added   int    `json:"added"`
+	Deleted int    `json:"deleted"`
+	New     bool   `json:"new,omitempty"` // File did not exist at the baseline
+}
+
+// run executes a git command in dir and returns its trimmed stdout.
+func run(dir string, args ...string) (string, error) {
+	return runEnv(dir, nil, args...)
+}
+
+// runEnv is run with env added to git's environment.
+func runEnv(dir string, env []string, args ...string) (string, error) {
+	out, err := output(dir, env, nil, args...)
+	return strings.TrimRight(string(out), "\n"), err
+}
+
+// output executes a git command in dir, with env added to its environment
+// and stdin as its input, and returns its stdout as is.
+func output(dir string, env []string, stdin io.Reader, args ...string) ([]byte, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	if env != nil {
+		cmd.Env = append(os.Environ(), env...)
+	}
+	cmd.Stdin = stdin
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" {
+			msg = err.Error()
+		}
+		return nil, fmt.Errorf("git %s: %s", args[0], msg)
+	}
+	return stdout.Bytes(), nil
+}
+
+// IsRepo reports whether dir is inside a git working tree.
+func IsRepo(dir string) bool {
+	out, err := run(dir, "rev-parse", "--is-inside-work-tree")
+	return err == nil && out == "true"
+}
+
+// Root returns the top-level directory of the working tree containing dir.
+func Root(dir string) (string, error) {
+	return run(dir, "rev-parse", "--show-toplevel")
+}
+
+// TakeBaseline records the current working tree state without modifying it.
+// Uncommitted changes to tracked files are captured via `git stash create`.
+func TakeBaseline(dir string) (*Baseline, error) {
+	root, err := Root(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	commit, err := run(root, "stash", "create")
+	if err != nil {
+		return nil, err
+	}
+	if commit == "" {
+		// Clean tree: HEAD is the baseline
+		if commit, err = run(root, "rev-parse", "HEAD"); err != nil {
+			return nil, err
+		}
+	}
+
+	untracked, err := untrackedFiles(root)
+	if err != nil {
+		return nil, err
+	}
+
+	set := make(map[string]bool, len(untracked))
+	for _, path := range untracked {
+		set[path] = true
+	}
+
+	return &Baseline{Dir: root, Commit: commit, Untracked: set}, nil
+}
+
+// Changes lists files that differ from the baseline, including new untracked files.
+func (b *Baseline) Changes() ([]FileChange, error) {
+	// With -z, paths aren't quoted; without renames, each change is one
+	// "added\tdeleted\tpath" record, and a rename a deletion and an addition
+	out, err := run(b.Dir, "diff", "--numstat", "-z", "--no-renames", b.Commit)
+	if err != nil {
+		return nil, err
+	}
+
+	var changes []FileChange
+	for _, record := range strings.Split(out, "\x00") {
+		fields := strings.SplitN(record, "\t", 3)
+		if len(fields) != 3 {
+			continue
+		}
+		// Binary files report "-" for line counts
+		added, _ := strconv.Atoi(fields[0])
+		deleted, _ := strconv.Atoi(fields[1])
+		changes = append(changes, FileChange{Path: fields[2], Added: added, Deleted: deleted})
+	}
+
+	untracked, err := untrackedFiles(b.Dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range untracked {
+		if b.Untracked[path] {
+			continue
+		}
+		changes = append(changes, FileChange{
+			Path:  path,
+			Added: countLines(filepath.Join(b.Dir, path)),
+			New:   true,
+		})
+	}
+
+	return changes, nil
+}
+
+// Restore reverts the given changes so the working tree matches the baseline.
+// New files are removed; modified or deleted files are restored from the baseline commit.
+func (b *Baseline) Restore(changes []FileChange) error {
+	var tracked []string
+	for _, c := range changes {
+		if c.New {
+			if err := os.Remove(filepath.Join(b.Dir, c.Path)); err != nil && !os.IsNotExist(err) {
+				return fmt.Errorf("failed to remove %s: %w", c.Path, err)
+			}
+			continue
+		}
+		tracked = append(tracked, c.Path)
+	}
+
+	if len(tracked) == 0 {
+		return nil
+	}
+
+	args := append([]string{"restore", "--source=" + b.Commit, "--worktree", "--"}, tracked...)
+	_, err := run(b.Dir, args...)
+	return err
+}
+
+// untrackedFiles lists untracked, non-ignored files relative to the repo root.
+func untrackedFiles(root string) ([]string, error) {
+	out, err := run(root, "ls-files", "-z", "--others", "--exclude-standard")
+	if err != nil {
+		return nil, err
+	}
+	out = strings.TrimRight(out, "\x00")
+	if out == "" {
+		return nil, nil
+	}
+	return strings.Split(out, "\x00"), nil
+}
+
+// ListFiles lists tracked and untracked, non-ignored files under dir,
+// relative to dir and sorted.
+func ListFiles(dir string) ([]string, error) {
+	out, err := run(dir, "ls-files", "-z", "--cached", "--others", "--exclude-standard")
+	if err != nil {
+		return nil, err
+	}
+	out = strings.TrimRight(out, "\x00")
+	if out == "" {
+		return nil, nil
+	}
+	files := strings.Split(out, "\x00")
+	sort.Strings(files)
+	return files, nil
+}
+
+// ChangedFiles lists files under dir that differ from the merge base of
+// base and HEAD: committed and uncommitted changes, deletions, and new
+// untracked files. Paths are relative to dir and sorted.
+func ChangedFiles(dir, base string) ([]string, error) {
+	mb, err := run(dir, "merge-base", base, "HEAD")
+	if err != nil {
+		return nil, fmt.Errorf("no common ancestor with %s: %w", base, err)
+	}
+	out, err := run(dir, "diff", "--name-only", "-z", "--relative", mb)
+	if err != nil {
+		return nil, err
+	}
+	untracked, err := run(dir, "ls-files", "-z", "--others", "--exclude-standard")
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool)
+	var files []string
+	for _, f := range strings.Split(out+"\x00"+untracked, "\x00") {
+		if f != "" && !seen[f] {
+			seen[f] = true
+			files = append(files, f)
+		}
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// DiffSince returns the unified diff of tracked files under dir from the
+// merge base of base and HEAD to the working tree.
+func DiffSince(dir, base string) (string, error) {
+	mb, err := run(dir, "merge-base", base, "HEAD")
+	if err != nil {
+		return "", fmt.Errorf("no common ancestor with %s: %w", base, err)
+	}
+	return run(dir, "diff", "--relative", mb)
+}
+
+// RefExists reports whether ref names a commit.
+func RefExists(dir, ref string) bool {
+	_, err := run(dir, "rev-parse", "--verify", "--quiet", ref+"^{commit}")
+	return err == nil
+}
+
+// ResolveCommit returns the full hash of the commit ref names.
+func ResolveCommit(dir, ref string) (string, error) {
+	return run(dir, "rev-parse", "--verify", "--quiet", ref+"^{commit}")
+}
+
+// RemoteRefs lists the branches and tags of the repository at url, mapping
+// full ref names (refs/heads/main, refs/tags/v1.2.0) to commit hashes.
+// Annotated tags map to the commit they point at.
+func RemoteRefs(url string) (map[string]string, error) {
+	out, err := run("", "ls-remote", "--heads", "--tags", url)
+	if err != nil {
+		return nil, err
+	}
+	refs := make(map[string]string)
+	for _, line := range strings.Split(out, "\n") {
+		hash, name, ok := strings.Cut(line, "\t")
+		if !ok {
+			continue
+		}
+		if peeled, ok := strings.CutSuffix(name, "^{}"); ok {
+			refs[peeled] = hash
+		} else if _, seen := refs[name]; !seen {
+			refs[name] = hash
+		}
+	}
+	return refs, nil
+}
+
+// CloneAt clones the repository at url into dir and checks out commit,
+// leaving HEAD detached.
+func CloneAt(url, commit, dir string) error {
+	if _, err := run("", "clone", "--quiet", "--no-checkout", url, dir); err != nil {
+		return err
+	}
+	_, err := run(dir, "checkout", "--quiet", "--detach", commit)
+	return err
+}
+
+// countLines returns the number of lines in a file (0 if unreadable).
+func countLines(path string) int {
+	data, err := os.ReadFile(path)
+	if err != nil || len(data) == 0 {
+		return 0
+	}
+	n := bytes.Count(data, []byte("\n"))
+	if data[len(data)-1] != '\n' {
+		n++
+	}
+	return n
+}
+
+// SummarizeChanges returns the number of files and total changed lines.
+func SummarizeChanges(changes []FileChange) (files, lines int) {
+	for _, c := range changes {
+		lines += c.Added + c.Deleted
+	}
+	return len(changes), lines
+}
+
+// CurrentBranch returns the checked-out branch name.
+func CurrentBranch(dir string) (string, error) {
+	return run(dir, "rev-parse", "--abbrev-ref", "HEAD")
+}
+
+// HeadCommit returns the full hash of the checked-out commit.
+func HeadCommit(dir string) (string, error) {
+	return run(dir, "rev-parse", "HEAD")
+}
+
+// CommitToBranch commits the given paths, as they are in the working tree,
+// onto branch, creating it from HEAD if it doesn't exist. The checked-out
+// branch, the index, and the working tree are left as they are, so the
+// developer stays on their branch. Returns the new commit SHA.
+func CommitToBranch(dir, branch, message string, paths []string) (string, error) {
+	if len(paths) == 0 {
+		return "", fmt.Errorf("nothing to commit")
+	}
+	ref := "refs/heads/" + branch
+	parent, err := run(dir, "rev-parse", "--verify", "--quiet", ref+"^{commit}")
+	old := parent
+	if err != nil {
+		if parent, err = run(dir, "rev-parse", "--verify", "--quiet", "HEAD^{commit}"); err != nil {
+			return "", fmt.Errorf("repository has no commits")
+		}
+	}
+
+	// Stage the paths in a scratch index over the branch, leaving the real one alone
+	index, err := os.CreateTemp("", "cortex-index-*")
+	if err != nil {
+		return "", err
+	}
+	index.Close()
+	defer os.Remove(index.Name())
+	env := []string{"GIT_INDEX_FILE=" + index.Name()}
+	if _, err := runEnv(dir, env, "read-tree", parent); err != nil {
+		return "", err
+	}
+	if _, err := runEnv(dir, env, append([]string{"update-index", "--add", "--remove", "--"}, paths...)...); err != nil {
+		return "", err
+	}
+	tree, err := runEnv(dir, env, "write-tree")
+	if err != nil {
+		return "", err
+	}
+	sha, err := run(dir, "commit-tree", tree, "-p", parent, "-m", message)
+	if err != nil {
+		return "", err
+	}
+	// old is "" for a new branch, which update-ref takes as "must not exist"
+	if _, err := run(dir, "update-ref", "-m", "cortex: "+message, ref, sha, old); err != nil {
+		return "", err
+	}
+	return sha, nil
+}
+
+// Push pushes branch to the remote and sets it as upstream.
+func Push(dir, remote, branch string) error {
+	_, err := run(dir, "push", "-q", "-u", remote, branch)
+	return err
+}
+
+// RemoteURL returns the fetch URL of the named remote.
+func RemoteURL(dir, remote string) (string, error) {
+	return run(dir, "remote", "get-url", remote)
+}
+
+// DefaultBranch returns the remote's default branch, falling back to "main".
+func DefaultBranch(dir, remote string) string {
+	ref, err := run(dir, "symbolic-ref", "--short", "refs/remotes/"+remote+"/HEAD")
+	if err != nil || ref == "" {
+		return "main"
+	}
+	return strings.TrimPrefix(ref, remote+"/")
+}
+
+// IsDirty reports whether the working tree has uncommitted changes,
+// including untracked files.
+func IsDirty(dir string) (bool, error) {
+	out, err := run(dir, "status", "--porcelain")
+	if err != nil {
+		return false, err
+	}
+	return out != "", nil
+}
+
+// Stash saves uncommitted changes, including untracked files, and cleans
+// the working tree. Returns the stash commit so it can be restored exactly.
+func Stash(dir, message string) (string, error) {
+	if _, err := run(dir, "stash", "push", "--include-untracked", "-m", message); err != nil {
+		return "", err
+	}
+	return run(dir, "rev-parse", "stash@{0}")
+}
+
+// StashPop restores a stash created by Stash and drops it.
+// On conflict the stash is kept so no work is lost.
+func StashPop(dir, commit string) error {
+	ref, err := stashRef(dir, commit)
+	if err != nil {
+		return err
+	}
+	_, err = run(dir, "stash", "pop", "--index", ref)
+	return err
+}
+
+// stashRef finds the stash@{n} entry pointing at commit; the stack may have
+// shifted if other stashes were pushed meanwhile.
+func stashRef(dir, commit string) (string, error) {
+	out, err := run(dir, "stash", "list", "--format=%gd %H")
+	if err != nil {
+		return "", err
+	}
+	for _, line := range strings.Split(out, "\n") {
+		ref, sha, ok := strings.Cut(line, " ")
+		if ok && sha == commit {
+			return ref, nil
+		}
+	}
+	return "", fmt.Errorf("stash %s not found", commit)
+}
+
+// SnapshotCommit commits the working tree of the repository containing
+// dir as it is, uncommitted changes and untracked files included, without
+// touching the tree, index, or any branch. Returns the commit, whose
+// parent is HEAD.
+func SnapshotCommit(dir, message string) (string, error) {
+	root, err := Root(dir)
+	if err != nil {
+		return "", err
+	}
+	head, err := run(root, "rev-parse", "--verify", "--quiet", "HEAD^{commit}")
+	if err != nil {
+		return "", fmt.Errorf("repository has no commits")
+	}
+
+	// Stage everything in a scratch index, leaving the real one alone
+	index, err := os.CreateTemp("", "cortex-index-*")
+	if err != nil {
+		return "", err
+	}
+	index.Close()
+	defer os.Remove(index.Name())
+	env := []string{"GIT_INDEX_FILE=" + index.Name()}
+	if _, err := runEnv(root, env, "read-tree", head); err != nil {
+		return "", err
+	}
+	if _, err := runEnv(root, env, "add", "--all"); err != nil {
+		return "", err
+	}
+	tree, err := runEnv(root, env, "write-tree")
+	if err != nil {
+		return "", err
+	}
+	// The commit is never pushed, so it needn't be the user's identity
+	env = append(env, "GIT_AUTHOR_NAME=cortex", "GIT_AUTHOR_EMAIL=cortex@localhost",
+		"GIT_COMMITTER_NAME=cortex", "GIT_COMMITTER_EMAIL=cortex@localhost")
+	return runEnv(root, env, "commit-tree", tree, "-p", head, "-m", message)
+}
+
+// AddWorktree checks commit out, detached, in a new worktree at path of
+// the repository containing dir.
+func AddWorktree(dir, path, commit string) error {
+	_, err := run(dir, "worktree", "add", "--quiet", "--detach", path, commit)
+	return err
+}
+
+// RemoveWorktree deletes the worktree at path, changes and all.
+func RemoveWorktree(path string) error {
+	_, err := run(path, "worktree", "remove", "--force", path)
+	return err
+}
+
+// Patch returns a binary patch of how the working tree at dir, new files
+// included, differs from commit, with paths relative to the repository
+// root ("" if it doesn't). It stages everything in dir's index.
+func Patch(dir, commit string) (string, error) {
+	if _, err := run(dir, "add", "--all"); err != nil {
+		return "", err
+	}
+	// Binary hunks end with a blank line, so the output isn't trimmed
+	out, err := output(dir, nil, nil, "diff", "--cached", "--binary", commit)
+	return string(out), err
+}
+
+// ApplyPatch applies patch, as made by Patch, to the working tree at dir,
+// the root of a repository. Nothing is changed if any of it doesn't apply.
+func ApplyPatch(dir, patch string) error {
+	_, err := output(dir, nil, strings.NewReader(patch), "apply", "--whitespace=nowarn", "-")
+	return err
+}
@@ -0,0 +1,357 @@
+package git
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// initRepo creates a repository with one committed file and returns its path.
+func initRepo(t *testing.T) string {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available")
+	}
+
+	dir := t.TempDir()
+	for _, args := range [][]string{
+		{"init", "-q"},
+		{"config", "user.email", "test@example.com"},
+		{"config", "user.name", "test"},
+	} {
+		if _, err := run(dir, args...); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(dir, "main.go"), []byte("package main\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := run(dir, "add", "."); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := run(dir, "commit", "-qm", "init"); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+// TestBaseline_ChangesAndRestore tests measuring and reverting changes made after a baseline.
+func TestBaseline_ChangesAndRestore(t *testing.T) {
+	dir := initRepo(t)
+
+	// Pre-existing uncommitted work must survive a restore
+	if err := os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("mine\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	baseline, err := TakeBaseline(dir)
+	if err != nil {
+		t.Fatalf("TakeBaseline() error = %v", err)
+	}
+
+	// Simulate agent edits
+	if err := os.WriteFile(filepath.Join(dir, "main.go"), []byte("package main\n\nfunc main() {}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "extra.go"), []byte("package main\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	changes, err := baseline.Changes()
+	if err != nil {
+		t.Fatalf("Changes() error = %v", err)
+	}
+	files, lines := SummarizeChanges(changes)
+	if files != 2 || lines != 3 {
+		t.Errorf("SummarizeChanges() = %d files, %d lines, want 2 files, 3 lines (%+v)", files, lines, changes)
+	}
+
+	if err := baseline.Restore(changes); err != nil {
+		t.Fatalf("Restore() error = %v", err)
+	}
+
+	data, _ := os.ReadFile(filepath.Join(dir, "main.go"))
+	if string(data) != "package main\n" {
+		t.Errorf("main.go not restored, got %q", data)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "extra.go")); !os.IsNotExist(err) {
+		t.Error("expected new file extra.go to be removed")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "notes.txt")); err != nil {
+		t.Error("expected pre-existing untracked file notes.txt to be kept")
+	}
+}
+
+// TestBaseline_ChangesPaths tests that paths with spaces and non-ASCII
+// characters are listed as they are, and a rename as the file it removed
+// and the one it added.
+func TestBaseline_ChangesPaths(t *testing.T) {
+	dir := initRepo(t)
+	if err := os.WriteFile(filepath.Join(dir, "old name.txt"), []byte("a\nb\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := run(dir, "add", "."); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := run(dir, "commit", "-qm", "add"); err != nil {
+		t.Fatal(err)
+	}
+
+	baseline, err := TakeBaseline(dir)
+	if err != nil {
+		t.Fatalf("TakeBaseline() error = %v", err)
+	}
+	if _, err := run(dir, "mv", "old name.txt", "new name.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "main.go"), []byte("package main\n\n// café\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "héllo wörld.go"), []byte("package main\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	changes, err := baseline.Changes()
+	if err != nil {
+		t.Fatalf("Changes() error = %v", err)
+	}
+	want := []FileChange{
+		{Path: "main.go", Added: 2},
+		{Path: "new name.txt", Added: 2},
+		{Path: "old name.txt", Deleted: 2},
+		{Path: "héllo wörld.go", Added: 1, New: true},
+	}
+	if len(changes) != len(want) {
+		t.Fatalf("Changes() = %+v, want %+v", changes, want)
+	}
+	for i := range want {
+		if changes[i] != want[i] {
+			t.Errorf("Changes()[%d] = %+v, want %+v", i, changes[i], want[i])
+		}
+	}
+}
+
+// TestCommitToBranch tests that only the given paths are committed, onto
+// the branch rather than the one checked out, and that the checkout, its
+// index, and its working tree are left alone.
+func TestCommitToBranch(t *testing.T) {
+	dir := initRepo(t)
+	branch, _ := CurrentBranch(dir)
+
+	for name, content := range map[string]string{"agent.go": "package main\n", "mine.txt": "keep\n", "main.go": "package main // edited\n"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	first, err := CommitToBranch(dir, "cortex/run-1", "cortex: implement", []string{"agent.go", "main.go"})
+	if err != nil {
+		t.Fatalf("CommitToBranch() error = %v", err)
+	}
+
+	if current, _ := CurrentBranch(dir); current != branch {
+		t.Errorf("CurrentBranch() = %q, want %q still checked out", current, branch)
+	}
+	if status, _ := run(dir, "status", "--porcelain"); status != " M main.go\n?? agent.go\n?? mine.txt" {
+		t.Errorf("status after the commit = %q, want the checkout untouched", status)
+	}
+	if files, _ := run(dir, "show", "--name-only", "--format=", "cortex/run-1"); files != "agent.go\nmain.go" {
+		t.Errorf("committed files = %q, want agent.go and main.go", files)
+	}
+
+	// A second commit goes on top of the first, and can delete files
+	if err := os.Remove(filepath.Join(dir, "agent.go")); err != nil {
+		t.Fatal(err)
+	}
+	second, err := CommitToBranch(dir, "cortex/run-1", "cortex: clean up", []string{"agent.go"})
+	if err != nil {
+		t.Fatalf("CommitToBranch() error = %v", err)
+	}
+	if parent, _ := run(dir, "rev-parse", second+"^"); parent != first {
+		t.Errorf("second commit's parent = %s, want %s", parent, first)
+	}
+	if files, _ := run(dir, "ls-tree", "--name-only", "cortex/run-1"); files != "main.go" {
+		t.Errorf("branch files = %q, want only main.go", files)
+	}
+	if _, err := CommitToBranch(dir, "cortex/run-1", "empty", nil); err == nil {
+		t.Error("CommitToBranch() with no paths succeeded")
+	}
+}
+
+// TestStash tests that stashed work, including untracked files, is restored.
+func TestStash(t *testing.T) {
+	dir := initRepo(t)
+
+	if err := os.WriteFile(filepath.Join(dir, "main.go"), []byte("package main // edited\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("mine\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if dirty, err := IsDirty(dir); err != nil || !dirty {
+		t.Fatalf("IsDirty() = %v, %v; want true", dirty, err)
+	}
+
+	commit, err := Stash(dir, "cortex: test")
+	if err != nil {
+		t.Fatalf("Stash() error = %v", err)
+	}
+	if dirty, _ := IsDirty(dir); dirty {
+		t.Error("tree still dirty after Stash()")
+	}
+
+	if err := StashPop(dir, commit); err != nil {
+		t.Fatalf("StashPop() error = %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "main.go"))
+	if err != nil || string(data) != "package main // edited\n" {
+		t.Errorf("main.go = %q, %v; want edited content", data, err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "notes.txt")); err != nil {
+		t.Errorf("untracked file not restored: %v", err)
+	}
+}
+
+// TestChangedFiles tests listing committed, uncommitted, and new files since a base ref.
+func TestChangedFiles(t *testing.T) {
+	dir := initRepo(t)
+	if _, err := run(dir, "branch", "base"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.MkdirAll(filepath.Join(dir, "api"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "api", "handler.go"), []byte("package api\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := run(dir, "add", "api"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := run(dir, "commit", "-qm", "add api"); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "main.go"), []byte("package main\n\nfunc main() {}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "new.txt"), []byte("new\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := ChangedFiles(dir, "base")
+	if err != nil {
+		t.Fatalf("ChangedFiles() error = %v", err)
+	}
+	want := []string{"api/handler.go", "main.go", "new.txt"}
+	if len(files) != len(want) {
+		t.Fatalf("ChangedFiles() = %v, want %v", files, want)
+	}
+	for i := range want {
+		if files[i] != want[i] {
+			t.Errorf("ChangedFiles()[%d] = %q, want %q", i, files[i], want[i])
+		}
+	}
+
+	patch, err := DiffSince(dir, "base")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(patch, "+func main() {}") {
+		t.Errorf("DiffSince() missing working tree change:\n%s", patch)
+	}
+	if RefExists(dir, "no-such-ref") {
+		t.Error("RefExists() = true for a missing ref")
+	}
+}
+
+func TestRemoteRefsAndCloneAt(t *testing.T) {
+	src := initRepo(t)
+	first, _ := run(src, "rev-parse", "HEAD")
+	if _, err := run(src, "tag", "-a", "v1.0.0", "-m", "v1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(src, "main.go"), []byte("package main // v2\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := run(src, "commit", "-qam", "v2"); err != nil {
+		t.Fatal(err)
+	}
+
+	refs, err := RemoteRefs(src)
+	if err != nil {
+		t.Fatalf("RemoteRefs() error = %v", err)
+	}
+	if refs["refs/tags/v1.0.0"] != first {
+		t.Errorf("refs/tags/v1.0.0 = %s, want the tagged commit %s", refs["refs/tags/v1.0.0"], first)
+	}
+
+	dir := filepath.Join(t.TempDir(), "clone")
+	if err := CloneAt(src, first, dir); err != nil {
+		t.Fatalf("CloneAt() error = %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "main.go"))
+	if err != nil || strings.Contains(string(data), "v2") {
+		t.Errorf("main.go = %q, %v; want the v1 content", data, err)
+	}
+}
+
+// TestWorktreePatch tests running in a worktree of the working tree as it
+// is and carrying its changes back as a patch.
+func TestWorktreePatch(t *testing.T) {
+	dir := initRepo(t)
+	if err := os.WriteFile(filepath.Join(dir, "main.go"), []byte("package main // edited\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("mine\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	base, err := SnapshotCommit(dir, "snapshot")
+	if err != nil {
+		t.Fatalf("SnapshotCommit() error = %v", err)
+	}
+	if dirty, _ := run(dir, "status", "--porcelain"); dirty != " M main.go\n?? notes.txt" {
+		t.Errorf("status after SnapshotCommit() = %q, want the tree untouched", dirty)
+	}
+
+	ws := filepath.Join(t.TempDir(), "ws")
+	if err := AddWorktree(dir, ws, base); err != nil {
+		t.Fatalf("AddWorktree() error = %v", err)
+	}
+	// Uncommitted work is there too
+	if data, _ := os.ReadFile(filepath.Join(ws, "notes.txt")); string(data) != "mine\n" {
+		t.Errorf("notes.txt in worktree = %q", data)
+	}
+
+	if patch, err := Patch(ws, base); err != nil || patch != "" {
+		t.Errorf("Patch() of an unchanged worktree = %q, %v", patch, err)
+	}
+	if err := os.WriteFile(filepath.Join(ws, "main.go"), []byte("package main\n\nfunc main() {}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(ws, "logo.bin"), []byte{0, 1, 2, 0, 255}, 0644); err != nil {
+		t.Fatal(err)
+	}
+	patch, err := Patch(ws, base)
+	if err != nil {
+		t.Fatalf("Patch() error = %v", err)
+	}
+	if err := RemoveWorktree(ws); err != nil {
+		t.Fatalf("RemoveWorktree() error = %v", err)
+	}
+	if _, err := os.Stat(ws); !os.IsNotExist(err) {
+		t.Error("worktree not removed")
+	}
+
+	if err := ApplyPatch(dir, patch); err != nil {
+		t.Fatalf("ApplyPatch() error = %v", err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "main.go")); string(data) != "package main\n\nfunc main() {}\n" {
+		t.Errorf("main.go = %q", data)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "logo.bin")); string(data) != "\x00\x01\x02\x00\xff" {
+		t.Errorf("logo.bin = %q", data)
+	}
+}
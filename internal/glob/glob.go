@@ -0,0 +1,131 @@
+// Package glob matches slash-separated paths against patterns in the style
+// of CI path filters: "*", "?", and "[...]" match within a path segment
+// (as in path.Match), and "**" matches any number of segments.
+package glob
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// Match reports whether name matches pattern. A pattern without a slash
+// that isn't "**" matches the base name at any depth, like .gitignore, so
+// "*.go" matches "api/handler.go". Malformed patterns match nothing.
+func Match(pattern, name string) bool {
+	name = strings.TrimPrefix(name, "./")
+	return matchSegments(split(pattern), strings.Split(name, "/"))
+}
+
+// MatchAny reports whether any of names matches any of patterns.
+func MatchAny(patterns, names []string) bool {
+	for _, name := range names {
+		for _, pattern := range patterns {
+			if Match(pattern, name) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Overlap reports whether some path could match both a and b. It errs
+// towards true: patterns are compared segment by segment up to the first
+// "**", and two segments that both have wildcards are taken to overlap.
+func Overlap(a, b string) bool {
+	return overlapSegments(split(a), split(b))
+}
+
+// split returns the segments of pattern, anchored like in Match.
+func split(pattern string) []string {
+	pattern = strings.TrimPrefix(pattern, "./")
+	if !strings.Contains(pattern, "/") && pattern != "**" {
+		pattern = "**/" + pattern
+	}
+	return strings.Split(pattern, "/")
+}
+
+func overlapSegments(a, b []string) bool {
+	for len(a) > 0 && len(b) > 0 {
+		if a[0] == "**" || b[0] == "**" {
+			return true
+		}
+		if !overlapSegment(a[0], b[0]) {
+			return false
+		}
+		a, b = a[1:], b[1:]
+	}
+	// "**" also matches no segments, as in "api/**" and "api"
+	switch {
+	case len(a) > 0:
+		return a[0] == "**"
+	case len(b) > 0:
+		return b[0] == "**"
+	}
+	return true
+}
+
+// overlapSegment reports whether some name could match both segments.
+func overlapSegment(a, b string) bool {
+	const meta = "*?[\\"
+	switch aWild, bWild := strings.ContainsAny(a, meta), strings.ContainsAny(b, meta); {
+	case aWild && bWild:
+		return true
+	case aWild:
+		ok, _ := path.Match(a, b)
+		return ok
+	case bWild:
+		ok, _ := path.Match(b, a)
+		return ok
+	}
+	return a == b
+}
+
+// Validate returns an error if pattern is malformed.
+func Validate(pattern string) error {
+	if pattern == "" {
+		return fmt.Errorf("empty pattern")
+	}
+	for _, seg := range strings.Split(pattern, "/") {
+		if seg == "**" {
+			continue
+		}
+		if strings.Contains(seg, "**") {
+			return fmt.Errorf("invalid pattern %q: '**' must be a whole path segment", pattern)
+		}
+		if _, err := path.Match(seg, ""); err != nil {
+			return fmt.Errorf("invalid pattern %q: %w", pattern, err)
+		}
+	}
+	return nil
+}
+
+// matchSegments matches pattern segments against name segments.
+func matchSegments(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			// Collapse repeated "**" and try every split point
+			rest := pattern[1:]
+			for len(rest) > 0 && rest[0] == "**" {
+				rest = rest[1:]
+			}
+			if len(rest) == 0 {
+				return true
+			}
+			for i := 0; i <= len(name); i++ {
+				if matchSegments(rest, name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		if ok, err := path.Match(pattern[0], name[0]); err != nil || !ok {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0
+}
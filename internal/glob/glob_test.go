@@ -0,0 +1,75 @@
+package glob
+
+import "testing"
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		pattern string
+		name    string
+		want    bool
+	}{
+		{"api/**", "api/handler.go", true},
+		{"api/**", "api/v1/routes/user.go", true},
+		{"api/**", "apix/handler.go", false},
+		{"api/*.go", "api/handler.go", true},
+		{"api/*.go", "api/v1/handler.go", false},
+		{"**/*_test.go", "internal/db/db_test.go", true},
+		{"**/*_test.go", "db_test.go", true},
+		{"*.md", "docs/guide/intro.md", true},
+		{"*.md", "README.md", true},
+		{"docs/**/*.md", "docs/intro.md", true},
+		{"docs/**/*.md", "docs/a/b/intro.md", true},
+		{"./cmd/**", "cmd/cortex/main.go", true},
+		{"Makefile", "Makefile", true},
+		{"Makefile", "build/Makefile", true},
+		{"go.[ms]*", "go.mod", true},
+		{"**", "anything/at/all", true},
+		{"web/[", "web/[", false},
+	}
+	for _, tt := range tests {
+		if got := Match(tt.pattern, tt.name); got != tt.want {
+			t.Errorf("Match(%q, %q) = %v, want %v", tt.pattern, tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	for _, pattern := range []string{"api/**", "**/*.go", "go.[ms]*"} {
+		if err := Validate(pattern); err != nil {
+			t.Errorf("Validate(%q) = %v, want nil", pattern, err)
+		}
+	}
+	for _, pattern := range []string{"", "api/**.go", "web/["} {
+		if err := Validate(pattern); err == nil {
+			t.Errorf("Validate(%q) = nil, want error", pattern)
+		}
+	}
+}
+
+func TestOverlap(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{"api/**", "api/handler.go", true},
+		{"api/**", "web/**", false},
+		{"api/**", "apix/**", false},
+		{"api/*.go", "api/handler.go", true},
+		{"api/*.go", "api/*_test.go", true},
+		{"api/*.go", "api/README.md", false},
+		{"api/*.go", "api/v1/handler.go", false},
+		{"*.go", "web/**", true},
+		{"docs/*.md", "docs/guide/*.md", false},
+		{"api/**", "api", true},
+		{"Makefile", "build/Makefile", true},
+		{"./cmd/main.go", "cmd/main.go", true},
+	}
+	for _, tt := range tests {
+		if got := Overlap(tt.a, tt.b); got != tt.want {
+			t.Errorf("Overlap(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+		if got := Overlap(tt.b, tt.a); got != tt.want {
+			t.Errorf("Overlap(%q, %q) = %v, want %v", tt.b, tt.a, got, tt.want)
+		}
+	}
+}
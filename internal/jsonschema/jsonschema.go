@@ -0,0 +1,584 @@
+// Package jsonschema validates JSON values against the subset of JSON
+// Schema that describes agent output: types, enums, object properties,
+// arrays, string and number bounds, combinators, and local $refs. Keywords
+// outside the subset are rejected when a schema is compiled, so a schema
+// never silently checks less than it says.
+package jsonschema
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Keywords are the validation keywords Compile accepts.
+var Keywords = []string{
+	"type", "enum", "const",
+	"properties", "required", "additionalProperties", "minProperties", "maxProperties",
+	"items", "minItems", "maxItems", "uniqueItems",
+	"minLength", "maxLength", "pattern",
+	"minimum", "maximum", "exclusiveMinimum", "exclusiveMaximum", "multipleOf",
+	"allOf", "anyOf", "oneOf", "not",
+	"$ref", "$defs", "definitions",
+}
+
+// annotations are keywords that describe values without constraining them.
+var annotations = map[string]bool{
+	"$schema": true, "$id": true, "$comment": true, "title": true, "description": true,
+	"default": true, "examples": true, "format": true, "readOnly": true, "writeOnly": true,
+	"deprecated": true,
+}
+
+// types are the values of "type".
+var types = []string{"object", "array", "string", "number", "integer", "boolean", "null"}
+
+// Schema is a compiled schema.
+type Schema struct {
+	types    []string
+	enum     []any
+	constant any
+	hasConst bool
+
+	properties    map[string]*Schema
+	required      []string
+	additional    *Schema // Schema of properties not in properties (nil = any)
+	noAdditional  bool    // additionalProperties: false
+	minProperties *int
+	maxProperties *int
+
+	items       *Schema
+	minItems    *int
+	maxItems    *int
+	uniqueItems bool
+
+	minLength *int
+	maxLength *int
+	pattern   *regexp.Regexp
+
+	minimum          *float64
+	maximum          *float64
+	exclusiveMinimum *float64
+	exclusiveMaximum *float64
+	multipleOf       *float64
+
+	allOf, anyOf, oneOf []*Schema
+	not                 *Schema
+	never               bool // The false schema, which nothing matches
+
+	ref  string
+	root *Schema
+	defs map[string]*Schema // On the root: $defs and definitions, by $ref
+}
+
+// Violation is one way a value doesn't match a schema.
+type Violation struct {
+	Path    string // Where in the value, e.g. "$.files[2].path"
+	Message string
+}
+
+func (v Violation) String() string {
+	return v.Path + ": " + v.Message
+}
+
+// Compile compiles a schema decoded from JSON or YAML.
+func Compile(schema map[string]any) (*Schema, error) {
+	root := &Schema{defs: make(map[string]*Schema)}
+	if err := compileInto(root, schema, root, "#"); err != nil {
+		return nil, err
+	}
+	return root, root.checkRefs(make(map[*Schema]bool))
+}
+
+// compile compiles the subschema at path.
+func compile(v any, root *Schema, path string) (*Schema, error) {
+	switch v := v.(type) {
+	case map[string]any:
+		s := &Schema{}
+		return s, compileInto(s, v, root, path)
+	case bool:
+		if v {
+			return &Schema{root: root}, nil
+		}
+		return &Schema{root: root, never: true}, nil
+	}
+	return nil, fmt.Errorf("%s: a schema must be an object or a boolean", path)
+}
+
+// compileInto compiles m into s.
+func compileInto(s *Schema, m map[string]any, root *Schema, path string) error {
+	s.root = root
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		v := m[k]
+		at := path + "/" + k
+		var err error
+		switch k {
+		case "type":
+			s.types, err = typeList(v, at)
+		case "enum":
+			list, ok := v.([]any)
+			if !ok || len(list) == 0 {
+				return fmt.Errorf("%s: must be a non-empty list", at)
+			}
+			s.enum = list
+		case "const":
+			s.constant, s.hasConst = v, true
+		case "properties":
+			props, ok := v.(map[string]any)
+			if !ok {
+				return fmt.Errorf("%s: must be an object of schemas", at)
+			}
+			s.properties = make(map[string]*Schema, len(props))
+			for name, p := range props {
+				if s.properties[name], err = compile(p, root, at+"/"+name); err != nil {
+					return err
+				}
+			}
+		case "required":
+			s.required, err = stringList(v, at)
+		case "additionalProperties":
+			if b, ok := v.(bool); ok {
+				s.noAdditional = !b
+			} else {
+				s.additional, err = compile(v, root, at)
+			}
+		case "minProperties":
+			s.minProperties, err = count(v, at)
+		case "maxProperties":
+			s.maxProperties, err = count(v, at)
+		case "items":
+			s.items, err = compile(v, root, at)
+		case "minItems":
+			s.minItems, err = count(v, at)
+		case "maxItems":
+			s.maxItems, err = count(v, at)
+		case "uniqueItems":
+			b, ok := v.(bool)
+			if !ok {
+				return fmt.Errorf("%s: must be true or false", at)
+			}
+			s.uniqueItems = b
+		case "minLength":
+			s.minLength, err = count(v, at)
+		case "maxLength":
+			s.maxLength, err = count(v, at)
+		case "pattern":
+			p, ok := v.(string)
+			if !ok {
+				return fmt.Errorf("%s: must be a regular expression", at)
+			}
+			if s.pattern, err = regexp.Compile(p); err != nil {
+				return fmt.Errorf("%s: %w", at, err)
+			}
+		case "minimum":
+			s.minimum, err = number(v, at)
+		case "maximum":
+			s.maximum, err = number(v, at)
+		case "exclusiveMinimum":
+			s.exclusiveMinimum, err = number(v, at)
+		case "exclusiveMaximum":
+			s.exclusiveMaximum, err = number(v, at)
+		case "multipleOf":
+			if s.multipleOf, err = number(v, at); err == nil && *s.multipleOf <= 0 {
+				err = fmt.Errorf("%s: must be greater than 0", at)
+			}
+		case "allOf":
+			s.allOf, err = schemaList(v, root, at)
+		case "anyOf":
+			s.anyOf, err = schemaList(v, root, at)
+		case "oneOf":
+			s.oneOf, err = schemaList(v, root, at)
+		case "not":
+			s.not, err = compile(v, root, at)
+		case "$ref":
+			ref, ok := v.(string)
+			if !ok || !strings.HasPrefix(ref, "#") {
+				return fmt.Errorf("%s: only local references, like \"#/$defs/item\", are supported", at)
+			}
+			s.ref = ref
+		case "$defs", "definitions":
+			defs, ok := v.(map[string]any)
+			if !ok {
+				return fmt.Errorf("%s: must be an object of schemas", at)
+			}
+			if s != root {
+				return fmt.Errorf("%s: definitions are only supported at the top of the schema", at)
+			}
+			for name, d := range defs {
+				if root.defs["#/"+k+"/"+name], err = compile(d, root, at+"/"+name); err != nil {
+					return err
+				}
+			}
+		default:
+			if !annotations[k] {
+				return fmt.Errorf("%s: unsupported keyword %q (supported: %s)", at, k, strings.Join(Keywords, ", "))
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkRefs reports $refs that don't name a definition.
+func (s *Schema) checkRefs(seen map[*Schema]bool) error {
+	if s == nil || seen[s] {
+		return nil
+	}
+	seen[s] = true
+	// Each reference must lead to a schema that isn't only references
+	for t, hops := s, 0; t.ref != ""; hops++ {
+		if hops > len(s.root.defs) {
+			return fmt.Errorf("$ref %q: refers to itself", s.ref)
+		}
+		if t = t.resolve(); t == nil {
+			return fmt.Errorf("$ref %q: no such definition", s.ref)
+		}
+	}
+	children := []*Schema{s.additional, s.items, s.not}
+	children = append(children, s.allOf...)
+	children = append(children, s.anyOf...)
+	children = append(children, s.oneOf...)
+	for _, p := range s.properties {
+		children = append(children, p)
+	}
+	for _, d := range s.defs {
+		children = append(children, d)
+	}
+	for _, c := range children {
+		if err := c.checkRefs(seen); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Validate returns the ways v, as decoded by encoding/json, doesn't match
+// the schema, or nil if it does.
+func (s *Schema) Validate(v any) []Violation {
+	var out []Violation
+	s.validate(v, "$", &out)
+	return out
+}
+
+func (s *Schema) validate(v any, path string, out *[]Violation) {
+	fail := func(format string, args ...any) {
+		*out = append(*out, Violation{Path: path, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if s.never {
+		fail("no value is allowed here")
+		return
+	}
+	if s.ref != "" {
+		s.resolve().validate(v, path, out)
+	}
+	if len(s.types) > 0 && !hasType(v, s.types) {
+		fail("expected %s, got %s", strings.Join(s.types, " or "), typeOf(v))
+		return
+	}
+	if s.enum != nil && !containsValue(s.enum, v) {
+		fail("must be one of %s", formatValues(s.enum))
+	}
+	if s.hasConst && !equal(s.constant, v) {
+		fail("must be %s", formatValue(s.constant))
+	}
+
+	switch v := v.(type) {
+	case map[string]any:
+		for _, name := range s.required {
+			if _, ok := v[name]; !ok {
+				fail("missing required property %q", name)
+			}
+		}
+		if s.minProperties != nil && len(v) < *s.minProperties {
+			fail("must have at least %d properties", *s.minProperties)
+		}
+		if s.maxProperties != nil && len(v) > *s.maxProperties {
+			fail("must have at most %d properties", *s.maxProperties)
+		}
+		names := make([]string, 0, len(v))
+		for name := range v {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			at := path + "." + name
+			if p, ok := s.properties[name]; ok {
+				p.validate(v[name], at, out)
+			} else if s.noAdditional {
+				fail("unexpected property %q", name)
+			} else if s.additional != nil {
+				s.additional.validate(v[name], at, out)
+			}
+		}
+	case []any:
+		if s.minItems != nil && len(v) < *s.minItems {
+			fail("must have at least %d items", *s.minItems)
+		}
+		if s.maxItems != nil && len(v) > *s.maxItems {
+			fail("must have at most %d items", *s.maxItems)
+		}
+		if s.uniqueItems {
+			for i := range v {
+				for j := 0; j < i; j++ {
+					if equal(v[i], v[j]) {
+						fail("items %d and %d are the same", j, i)
+					}
+				}
+			}
+		}
+		if s.items != nil {
+			for i, item := range v {
+				s.items.validate(item, path+"["+strconv.Itoa(i)+"]", out)
+			}
+		}
+	case string:
+		n := len([]rune(v))
+		if s.minLength != nil && n < *s.minLength {
+			fail("must be at least %d characters", *s.minLength)
+		}
+		if s.maxLength != nil && n > *s.maxLength {
+			fail("must be at most %d characters", *s.maxLength)
+		}
+		if s.pattern != nil && !s.pattern.MatchString(v) {
+			fail("must match %s", s.pattern)
+		}
+	case json.Number, float64:
+		f, _ := toFloat(v)
+		if s.minimum != nil && f < *s.minimum {
+			fail("must be at least %s", formatFloat(*s.minimum))
+		}
+		if s.maximum != nil && f > *s.maximum {
+			fail("must be at most %s", formatFloat(*s.maximum))
+		}
+		if s.exclusiveMinimum != nil && f <= *s.exclusiveMinimum {
+			fail("must be greater than %s", formatFloat(*s.exclusiveMinimum))
+		}
+		if s.exclusiveMaximum != nil && f >= *s.exclusiveMaximum {
+			fail("must be less than %s", formatFloat(*s.exclusiveMaximum))
+		}
+		if s.multipleOf != nil {
+			if q := f / *s.multipleOf; math.Abs(q-math.Round(q)) > 1e-9 {
+				fail("must be a multiple of %s", formatFloat(*s.multipleOf))
+			}
+		}
+	}
+
+	for _, sub := range s.allOf {
+		sub.validate(v, path, out)
+	}
+	if len(s.anyOf) > 0 && s.matching(s.anyOf, v) == 0 {
+		fail("must match at least one schema of anyOf")
+	}
+	if len(s.oneOf) > 0 {
+		if n := s.matching(s.oneOf, v); n != 1 {
+			fail("must match exactly one schema of oneOf, matches %d", n)
+		}
+	}
+	if s.not != nil && len(s.not.Validate(v)) == 0 {
+		fail("must not match the schema of not")
+	}
+}
+
+// resolve returns the schema s.ref refers to, or nil if there is none.
+func (s *Schema) resolve() *Schema {
+	if s.ref == "#" {
+		return s.root
+	}
+	return s.root.defs[s.ref]
+}
+
+// matching counts the schemas v matches.
+func (s *Schema) matching(schemas []*Schema, v any) int {
+	n := 0
+	for _, sub := range schemas {
+		if len(sub.Validate(v)) == 0 {
+			n++
+		}
+	}
+	return n
+}
+
+// typeOf returns the JSON type of v.
+func typeOf(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	case json.Number, float64:
+		if f, ok := toFloat(v); ok && f == math.Trunc(f) {
+			return "integer"
+		}
+		return "number"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+// hasType reports whether v is one of types. Integers are numbers too.
+func hasType(v any, types []string) bool {
+	t := typeOf(v)
+	for _, want := range types {
+		if want == t || want == "number" && t == "integer" {
+			return true
+		}
+	}
+	return false
+}
+
+func typeList(v any, at string) ([]string, error) {
+	names, err := stringList(v, at)
+	if s, ok := v.(string); ok {
+		names, err = []string{s}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range names {
+		if !slices.Contains(types, name) {
+			return nil, fmt.Errorf("%s: unknown type %q (use %s)", at, name, strings.Join(types, ", "))
+		}
+	}
+	return names, nil
+}
+
+func stringList(v any, at string) ([]string, error) {
+	list, ok := v.([]any)
+	if !ok {
+		return nil, fmt.Errorf("%s: must be a list of strings", at)
+	}
+	out := make([]string, len(list))
+	for i, item := range list {
+		s, ok := item.(string)
+		if !ok {
+			return nil, fmt.Errorf("%s: must be a list of strings", at)
+		}
+		out[i] = s
+	}
+	return out, nil
+}
+
+func schemaList(v any, root *Schema, at string) ([]*Schema, error) {
+	list, ok := v.([]any)
+	if !ok || len(list) == 0 {
+		return nil, fmt.Errorf("%s: must be a non-empty list of schemas", at)
+	}
+	out := make([]*Schema, len(list))
+	for i, item := range list {
+		var err error
+		if out[i], err = compile(item, root, at+"/"+strconv.Itoa(i)); err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+func count(v any, at string) (*int, error) {
+	f, ok := toFloat(v)
+	if !ok || f < 0 || f != math.Trunc(f) {
+		return nil, fmt.Errorf("%s: must be a whole number of 0 or more", at)
+	}
+	n := int(f)
+	return &n, nil
+}
+
+func number(v any, at string) (*float64, error) {
+	f, ok := toFloat(v)
+	if !ok {
+		return nil, fmt.Errorf("%s: must be a number", at)
+	}
+	return &f, nil
+}
+
+// toFloat converts the numbers encoding/json and YAML decode to.
+func toFloat(v any) (float64, bool) {
+	switch v := v.(type) {
+	case json.Number:
+		f, err := v.Float64()
+		return f, err == nil
+	case float64:
+		return v, true
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	}
+	return 0, false
+}
+
+// equal compares JSON values, numbers by value whatever they decoded to.
+func equal(a, b any) bool {
+	return reflect.DeepEqual(canonical(a), canonical(b))
+}
+
+// canonical returns v with numbers as float64.
+func canonical(v any) any {
+	if f, ok := toFloat(v); ok {
+		return f
+	}
+	switch v := v.(type) {
+	case []any:
+		out := make([]any, len(v))
+		for i, item := range v {
+			out[i] = canonical(item)
+		}
+		return out
+	case map[string]any:
+		out := make(map[string]any, len(v))
+		for k, item := range v {
+			out[k] = canonical(item)
+		}
+		return out
+	}
+	return v
+}
+
+func containsValue(list []any, v any) bool {
+	for _, item := range list {
+		if equal(item, v) {
+			return true
+		}
+	}
+	return false
+}
+
+func formatValue(v any) string {
+	data, err := json.Marshal(canonical(v))
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(data)
+}
+
+func formatValues(list []any) string {
+	parts := make([]string, len(list))
+	for i, v := range list {
+		parts[i] = formatValue(v)
+	}
+	return strings.Join(parts, ", ")
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
@@ -0,0 +1,120 @@
+package jsonschema
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+const planSchema = `
+type: object
+required: [summary, files]
+additionalProperties: false
+properties:
+  summary: {type: string, minLength: 1}
+  risk: {enum: [low, medium, high]}
+  score: {type: number, minimum: 0, maximum: 1}
+  files:
+    type: array
+    minItems: 1
+    items: {$ref: "#/$defs/file"}
+$defs:
+  file:
+    type: object
+    required: [path]
+    properties:
+      path: {type: string, pattern: "^[^/]"}
+      lines: {type: integer, exclusiveMinimum: 0}
+`
+
+func compileYAML(t *testing.T, text string) *Schema {
+	t.Helper()
+	var m map[string]any
+	if err := yaml.Unmarshal([]byte(text), &m); err != nil {
+		t.Fatal(err)
+	}
+	s, err := Compile(m)
+	if err != nil {
+		t.Fatalf("Compile: %v", err)
+	}
+	return s
+}
+
+func decode(t *testing.T, text string) any {
+	t.Helper()
+	dec := json.NewDecoder(strings.NewReader(text))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+func TestValidate(t *testing.T) {
+	s := compileYAML(t, planSchema)
+	for _, tt := range []struct {
+		name, value string
+		want        []string
+	}{
+		{"valid", `{"summary": "ok", "risk": "low", "score": 0.5, "files": [{"path": "a.go", "lines": 3}]}`, nil},
+		{"wrong type", `[]`, []string{"$: expected object, got array"}},
+		{"missing and extra", `{"files": [{"path": "a.go"}], "notes": ""}`, []string{
+			`$: missing required property "summary"`,
+			`$: unexpected property "notes"`,
+		}},
+		{"nested", `{"summary": "", "risk": "none", "score": 2, "files": [{"path": "/abs", "lines": 1.5}]}`, []string{
+			"$.files[0].lines: expected integer, got number",
+			"$.files[0].path: must match ^[^/]",
+			`$.risk: must be one of "low", "medium", "high"`,
+			"$.score: must be at most 1",
+			"$.summary: must be at least 1 characters",
+		}},
+		{"empty list", `{"summary": "ok", "files": []}`, []string{"$.files: must have at least 1 items"}},
+	} {
+		var got []string
+		for _, v := range s.Validate(decode(t, tt.value)) {
+			got = append(got, v.String())
+		}
+		if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+			t.Errorf("%s: got\n%s\nwant\n%s", tt.name, strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+		}
+	}
+}
+
+func TestValidateCombinators(t *testing.T) {
+	s := compileYAML(t, `
+oneOf:
+  - {type: string}
+  - {type: integer}
+  - {type: number, maximum: 10}
+not: {const: "skip"}
+`)
+	for value, want := range map[string]int{`"a"`: 0, `20`: 0, `5`: 1, `"skip"`: 1, `true`: 1} {
+		if got := len(s.Validate(decode(t, value))); got != want {
+			t.Errorf("Validate(%s): %d violations, want %d", value, got, want)
+		}
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	for schema, want := range map[string]string{
+		`{type: objekt}`:                          `unknown type "objekt"`,
+		`{patternProperties: {}}`:                 `unsupported keyword "patternProperties"`,
+		`{$ref: "other.json#/a"}`:                 "only local references",
+		`{$ref: "#/$defs/missing"}`:               "no such definition",
+		`{$defs: {a: {$ref: "#/$defs/a"}}}`:       "refers to itself",
+		`{properties: {a: {pattern: "("}}}`:       "#/properties/a/pattern",
+		`{items: {properties: {a: {$defs: {}}}}}`: "only supported at the top",
+	} {
+		var m map[string]any
+		if err := yaml.Unmarshal([]byte(schema), &m); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := Compile(m); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("Compile(%s) = %v, want an error containing %q", schema, err, want)
+		}
+	}
+}
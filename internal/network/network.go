@@ -0,0 +1,198 @@
+// Package network configures outbound connections for networks that
+// require a proxy or trust a private certificate authority.
+//
+// Requests Cortex makes itself (webhooks, embeddings, forge APIs) go
+// through Transport, which honors HTTPS_PROXY, HTTP_PROXY, and NO_PROXY
+// unless a proxy is configured explicitly; emails use the CA bundle through
+// TLSConfig. Configure also exports the settings to the environment of the
+// tools Cortex runs: agent CLIs and git read the proxy variables
+// themselves, NODE_EXTRA_CA_CERTS adds the CA bundle to Node-based agents,
+// and GIT_SSL_CAINFO points git at the system certificates plus the bundle.
+package network
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"golang.org/x/net/http/httpproxy"
+)
+
+// CombinedBundleFile is the name of the bundle of system and extra CA
+// certificates written for git.
+const CombinedBundleFile = "ca-bundle.pem"
+
+// systemBundles are the usual locations of the system's CA bundle.
+var systemBundles = []string{
+	"/etc/ssl/certs/ca-certificates.crt",                // Debian, Ubuntu, Arch
+	"/etc/pki/tls/certs/ca-bundle.crt",                  // Fedora, RHEL
+	"/etc/pki/ca-trust/extracted/pem/tls-ca-bundle.pem", // RHEL 7+
+	"/etc/ssl/ca-bundle.pem",                            // openSUSE
+	"/etc/ssl/cert.pem",                                 // macOS, Alpine
+}
+
+// Options configure outbound connections.
+type Options struct {
+	Proxy    string // Proxy URL for HTTP and HTTPS ("" = from HTTPS_PROXY / HTTP_PROXY)
+	NoProxy  string // Hosts to reach directly, in NO_PROXY syntax ("" = from NO_PROXY)
+	CABundle string // PEM file of CA certificates to trust besides the system's
+	Dir      string // Where to write the combined bundle for git ("" = don't)
+}
+
+var (
+	mu      sync.RWMutex
+	current = newTransport(httpproxy.FromEnvironment(), nil)
+)
+
+// Configure applies opts to Transport and to the environment of
+// subprocesses started afterwards.
+func Configure(opts Options) error {
+	proxy := httpproxy.FromEnvironment()
+	if opts.Proxy != "" {
+		u, err := url.Parse(opts.Proxy)
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			return fmt.Errorf("invalid proxy %q: use a URL like http://proxy.example.com:3128", opts.Proxy)
+		}
+		proxy.HTTPProxy, proxy.HTTPSProxy = opts.Proxy, opts.Proxy
+	}
+	if opts.NoProxy != "" {
+		proxy.NoProxy = opts.NoProxy
+	}
+
+	var roots *x509.CertPool
+	if opts.CABundle != "" {
+		pem, err := os.ReadFile(opts.CABundle)
+		if err != nil {
+			return fmt.Errorf("failed to read CA bundle: %w", err)
+		}
+		roots, err = x509.SystemCertPool()
+		if err != nil {
+			roots = x509.NewCertPool()
+		}
+		if !roots.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("no PEM certificates found in CA bundle %s", opts.CABundle)
+		}
+		if err := exportBundle(opts.CABundle, pem, opts.Dir); err != nil {
+			return err
+		}
+	}
+	exportProxy(opts)
+
+	mu.Lock()
+	current = newTransport(proxy, roots)
+	mu.Unlock()
+	return nil
+}
+
+// newTransport returns a transport like http.DefaultTransport that uses
+// proxy and, if set, trusts roots instead of the system certificates.
+func newTransport(proxy *httpproxy.Config, roots *x509.CertPool) *http.Transport {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	proxyFor := proxy.ProxyFunc()
+	t.Proxy = func(req *http.Request) (*url.URL, error) {
+		return proxyFor(req.URL)
+	}
+	if roots != nil {
+		t.TLSClientConfig = &tls.Config{RootCAs: roots}
+	}
+	return t
+}
+
+// roundTripper sends requests with the transport of the latest Configure.
+type roundTripper struct{}
+
+func (roundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	mu.RLock()
+	t := current
+	mu.RUnlock()
+	return t.RoundTrip(req)
+}
+
+// Transport returns a RoundTripper that follows the proxy and CA settings,
+// including ones configured after it was created.
+func Transport() http.RoundTripper {
+	return roundTripper{}
+}
+
+// Client returns an HTTP client that uses Transport, with the given timeout
+// (0 = none).
+func Client(timeout time.Duration) *http.Client {
+	return &http.Client{Transport: Transport(), Timeout: timeout}
+}
+
+// TLSConfig returns the TLS configuration for connecting to host without
+// HTTP, e.g. to an SMTP server, trusting the configured CA bundle.
+func TLSConfig(host string) *tls.Config {
+	mu.RLock()
+	defer mu.RUnlock()
+	cfg := &tls.Config{ServerName: host}
+	if current.TLSClientConfig != nil {
+		cfg.RootCAs = current.TLSClientConfig.RootCAs
+	}
+	return cfg
+}
+
+// exportProxy passes an explicitly configured proxy to subprocesses. Both
+// cases are set, as tools disagree on which they read.
+func exportProxy(opts Options) {
+	if opts.Proxy != "" {
+		for _, name := range []string{"HTTPS_PROXY", "https_proxy", "HTTP_PROXY", "http_proxy"} {
+			os.Setenv(name, opts.Proxy)
+		}
+	}
+	if opts.NoProxy != "" {
+		os.Setenv("NO_PROXY", opts.NoProxy)
+		os.Setenv("no_proxy", opts.NoProxy)
+	}
+}
+
+// exportBundle passes the CA bundle to subprocesses. Node adds
+// NODE_EXTRA_CA_CERTS to its built-in roots; git replaces its roots with
+// GIT_SSL_CAINFO, so git gets a copy of the system bundle with the extra
+// certificates appended, when the system bundle can be found.
+func exportBundle(path string, pem []byte, dir string) error {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+	os.Setenv("NODE_EXTRA_CA_CERTS", abs)
+
+	system := systemBundle()
+	if dir == "" || system == "" {
+		return nil
+	}
+	data, err := os.ReadFile(system)
+	if err != nil {
+		return nil // Leave git with its own roots
+	}
+	data = append(data, '\n')
+	data = append(data, pem...)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to write CA bundle for git: %w", err)
+	}
+	combined := filepath.Join(dir, CombinedBundleFile)
+	if err := os.WriteFile(combined, data, 0644); err != nil {
+		return fmt.Errorf("failed to write CA bundle for git: %w", err)
+	}
+	os.Setenv("GIT_SSL_CAINFO", combined)
+	return nil
+}
+
+// systemBundle returns the path of the system's CA bundle, or "".
+func systemBundle() string {
+	if file := os.Getenv("SSL_CERT_FILE"); file != "" {
+		return file
+	}
+	for _, path := range systemBundles {
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	return ""
+}
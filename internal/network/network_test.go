@@ -0,0 +1,90 @@
+package network
+
+import (
+	"encoding/pem"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// isolateEnv restores the variables Configure exports, and the default
+// transport, after the test.
+func isolateEnv(t *testing.T) {
+	for _, name := range []string{
+		"HTTPS_PROXY", "https_proxy", "HTTP_PROXY", "http_proxy", "NO_PROXY", "no_proxy",
+		"NODE_EXTRA_CA_CERTS", "GIT_SSL_CAINFO",
+	} {
+		t.Setenv(name, "")
+	}
+	t.Cleanup(func() { _ = Configure(Options{}) })
+}
+
+func TestConfigure_Proxy(t *testing.T) {
+	isolateEnv(t)
+	var proxied string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied = r.URL.String()
+		io.WriteString(w, "via proxy")
+	}))
+	defer proxy.Close()
+
+	if err := Configure(Options{Proxy: proxy.URL, NoProxy: "direct.invalid"}); err != nil {
+		t.Fatal(err)
+	}
+	resp, err := Client(0).Get("http://hooks.example.invalid/notify")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if proxied != "http://hooks.example.invalid/notify" {
+		t.Errorf("proxy saw %q, want the absolute request URL", proxied)
+	}
+	if os.Getenv("HTTPS_PROXY") != proxy.URL || os.Getenv("no_proxy") != "direct.invalid" {
+		t.Error("proxy settings weren't exported to subprocesses")
+	}
+
+	if err := Configure(Options{Proxy: "proxy.example.com"}); err == nil {
+		t.Error("expected an error for a proxy without a scheme")
+	}
+}
+
+func TestConfigure_CABundle(t *testing.T) {
+	isolateEnv(t)
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "ok")
+	}))
+	defer server.Close()
+
+	// Untrusted until its certificate is configured
+	if resp, err := Client(0).Get(server.URL); err == nil {
+		resp.Body.Close()
+		t.Fatal("request to a server with an unknown CA succeeded")
+	}
+
+	dir := t.TempDir()
+	bundle := filepath.Join(dir, "corp.pem")
+	cert := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	if err := os.WriteFile(bundle, cert, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := Configure(Options{CABundle: bundle, Dir: filepath.Join(dir, "network")}); err != nil {
+		t.Fatal(err)
+	}
+	resp, err := Client(0).Get(server.URL)
+	if err != nil {
+		t.Fatalf("request with the CA bundle configured: %v", err)
+	}
+	resp.Body.Close()
+	if os.Getenv("NODE_EXTRA_CA_CERTS") != bundle {
+		t.Errorf("NODE_EXTRA_CA_CERTS = %q, want %q", os.Getenv("NODE_EXTRA_CA_CERTS"), bundle)
+	}
+
+	empty := filepath.Join(dir, "empty.pem")
+	os.WriteFile(empty, []byte("not a certificate"), 0644)
+	if err := Configure(Options{CABundle: empty}); err == nil {
+		t.Error("expected an error for a bundle without certificates")
+	}
+}
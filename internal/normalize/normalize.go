@@ -0,0 +1,99 @@
+// Package normalize cleans up the output of agents and commands before
+// it's stored and passed to other tasks: terminal colors and cursor
+// movement, invalid UTF-8, Windows line endings, and progress bars that
+// redraw a line with carriage returns all read as noise in logs, reports,
+// and prompts.
+package normalize
+
+import (
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// Steps selects what Text cleans up.
+type Steps struct {
+	ANSI     bool // Strip terminal escape sequences and control characters
+	UTF8     bool // Replace invalid UTF-8 with U+FFFD
+	Newlines bool // Turn \r\n line endings into \n
+	Progress bool // Keep only the final state of lines redrawn with \r
+}
+
+// All is every step, the default.
+var All = Steps{ANSI: true, UTF8: true, Newlines: true, Progress: true}
+
+// escapeRegex matches terminal escape sequences: CSI sequences such as
+// colors and cursor movement, OSC sequences such as window titles and
+// hyperlinks, and the two-character escapes.
+var escapeRegex = regexp.MustCompile(`\x1b\[[0-?]*[ -/]*[@-~]|\x1b\][^\x07\x1b]*(?:\x07|\x1b\\)|\x1b[@-Z\\-_]`)
+
+// Text returns s with the steps applied. Invalid UTF-8 is fixed first, so
+// the other steps see whole characters, and redraws are collapsed before
+// escape sequences are stripped, so those that erase a line still count.
+func Text(s string, steps Steps) string {
+	if steps.UTF8 && !utf8.ValidString(s) {
+		s = strings.ToValidUTF8(s, "\uFFFD")
+	}
+	if steps.Newlines {
+		s = strings.ReplaceAll(s, "\r\n", "\n")
+	}
+	if steps.Progress && strings.Contains(s, "\r") {
+		s = collapseRedraws(s)
+	}
+	if steps.ANSI {
+		s = stripControls(s)
+	}
+	return s
+}
+
+// stripControls removes escape sequences and the control characters
+// other than tab, newline, and carriage return (which collapseRedraws
+// reads), such as backspaces and bells.
+func stripControls(s string) string {
+	if strings.IndexFunc(s, isControl) < 0 {
+		return s
+	}
+	s = escapeRegex.ReplaceAllString(s, "")
+	return strings.Map(func(r rune) rune {
+		if isControl(r) {
+			return -1
+		}
+		return r
+	}, s)
+}
+
+// isControl reports whether r is a control character stripControls
+// removes.
+func isControl(r rune) bool {
+	return r < 0x20 && r != '\t' && r != '\n' && r != '\r' || r == 0x7f
+}
+
+// collapseRedraws replaces each line a terminal would have redrawn with
+// \r, such as a progress bar, with what was left on screen: each redraw
+// overwrites the start of the line, or all of it if it erases the line or
+// has escape sequences, whose width can't be told. A \r before a newline
+// is a line ending, not a redraw.
+func collapseRedraws(s string) string {
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		ending := ""
+		if i < len(lines)-1 && strings.HasSuffix(line, "\r") {
+			line, ending = line[:len(line)-1], "\r"
+		}
+		if !strings.Contains(line, "\r") {
+			continue
+		}
+		redraws := strings.Split(line, "\r")
+		screen := []rune(redraws[0])
+		for _, redraw := range redraws[1:] {
+			r := []rune(redraw)
+			if len(r) >= len(screen) || strings.Contains(redraw, "\x1b") {
+				screen = r
+			} else {
+				copy(screen, r)
+			}
+		}
+		lines[i] = string(screen) + ending
+	}
+	return strings.Join(lines, "\n")
+}
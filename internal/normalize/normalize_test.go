@@ -0,0 +1,36 @@
+package normalize
+
+import "testing"
+
+func TestText(t *testing.T) {
+	for _, tt := range []struct {
+		name, in, want string
+	}{
+		{"plain", "ok\n", "ok\n"},
+		{"colors", "\x1b[1;31mFAIL\x1b[0m main_test.go:12\n", "FAIL main_test.go:12\n"},
+		{"hyperlink", "see \x1b]8;;https://example.com\x07docs\x1b]8;;\x07\n", "see docs\n"},
+		{"controls", "spin\b\b\bdone\a\ttab\n", "spindone\ttab\n"},
+		{"invalid utf-8", "caf\xe9 ok", "caf� ok"},
+		{"crlf", "a\r\nb\r\n", "a\nb\n"},
+		{"progress", "Downloading  10%\rDownloading  55%\rDownloading 100%\nDone\n", "Downloading 100%\nDone\n"},
+		{"shorter redraw", "abcdef\rxy\n", "xycdef\n"},
+		{"erase line", "building pkg/very/long/name\r\x1b[2Kok\n", "ok\n"},
+		{"trailing redraw", "50%\r100%\r", "100%"},
+	} {
+		if got := Text(tt.in, All); got != tt.want {
+			t.Errorf("%s: Text(%q) = %q, want %q", tt.name, tt.in, got, tt.want)
+		}
+	}
+
+	// Steps not selected are left alone
+	in := "\x1b[32mok\x1b[0m\r\n"
+	if got := Text(in, Steps{Newlines: true}); got != "\x1b[32mok\x1b[0m\n" {
+		t.Errorf("Text(newlines only) = %q", got)
+	}
+	if got := Text(in, Steps{}); got != in {
+		t.Errorf("Text(no steps) = %q, want it unchanged", got)
+	}
+	if got := Text("a\r\n", Steps{Progress: true}); got != "a\r\n" {
+		t.Errorf("Text(progress only) = %q, want the line ending kept", got)
+	}
+}
@@ -278,6 +278,11 @@ const (
 	EventTaskComplete = "task_complete"
 	EventTaskFailed   = "task_failed"
 	EventWebhookSent  = "webhook_sent"
+	EventRateLimited  = "rate_limited"
+	EventTaskProgress = "task_progress"
+
+	EventBudgetExceeded = "budget_exceeded"
+	EventAgentAction    = "agent_action"
 )
 
 // TaskData represents task-related data for logging
@@ -301,6 +306,39 @@ type RunData struct {
 	ConfigFile string `json:"config_file,omitempty"`
 }
 
+// BackoffData represents a shared rate-limit cooldown for logging
+type BackoffData struct {
+	Tool     string    `json:"tool"`
+	Model    string    `json:"model,omitempty"`
+	Cooldown string    `json:"cooldown"`
+	Until    time.Time `json:"until"`
+}
+
+// ProgressData represents a heartbeat from a long-running task for logging
+type ProgressData struct {
+	Elapsed  string `json:"elapsed"`
+	Bytes    int64  `json:"bytes"`
+	Tokens   int    `json:"tokens,omitempty"`
+	Activity string `json:"activity,omitempty"`
+	Edits    int    `json:"edits,omitempty"`
+	LastLine string `json:"last_line,omitempty"`
+}
+
+// AgentActionData represents a tool call or file edit made by an agent for logging
+type AgentActionData struct {
+	Type    string `json:"type"`
+	Tool    string `json:"tool"`
+	Summary string `json:"summary,omitempty"`
+	Path    string `json:"path,omitempty"`
+}
+
+// BudgetData represents an exceeded token budget for logging
+type BudgetData struct {
+	Scope string `json:"scope"` // "task" or "run"
+	Limit int    `json:"limit"`
+	Used  int    `json:"used"`
+}
+
 // Global logger instance (can be replaced)
 var globalLogger = DefaultLogger()
 
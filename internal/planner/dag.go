@@ -2,6 +2,11 @@
 package planner
 
 import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
 	"github.com/adityaraj/agentflow/internal/config"
 )
 
@@ -79,3 +84,113 @@ func (d *DAG) GetDependents(taskName string) []string {
 func (d *DAG) Size() int {
 	return len(d.Nodes)
 }
+
+// The methods below update a DAG in place, for plans that change after they
+// are built (generated tasks, edits to a run in progress). Each keeps Edges,
+// ReverseEdges, and InDegree consistent, so BuildExecutionLevels and
+// TopologicalSort reflect the change, and rejects changes that would leave
+// a dangling dependency or a cycle, leaving the DAG as it was. A DAG is not
+// safe for concurrent use; callers sharing one must lock around changes.
+
+// AddTask adds a task whose dependencies, task.Needs, are already in the
+// DAG. A new task can't close a cycle, as nothing depends on it yet.
+func (d *DAG) AddTask(name string, task config.TaskConfig) error {
+	if _, exists := d.Nodes[name]; exists {
+		return fmt.Errorf("task %q is already in the plan", name)
+	}
+	for _, dep := range task.Needs {
+		if dep == name {
+			return fmt.Errorf("task %q cannot depend on itself", name)
+		}
+		if _, exists := d.Nodes[dep]; !exists {
+			return fmt.Errorf("task %q needs %q, which is not in the plan", name, dep)
+		}
+	}
+
+	d.Nodes[name] = task
+	d.Edges[name] = []string{}
+	d.ReverseEdges[name] = []string{}
+	d.InDegree[name] = 0
+	for _, dep := range task.Needs {
+		d.link(name, dep)
+	}
+	return nil
+}
+
+// RemoveTask removes a task that no other task depends on; remove its
+// dependents first.
+func (d *DAG) RemoveTask(name string) error {
+	if _, exists := d.Nodes[name]; !exists {
+		return fmt.Errorf("task %q is not in the plan", name)
+	}
+	if dependents := d.ReverseEdges[name]; len(dependents) > 0 {
+		sorted := slices.Clone(dependents)
+		sort.Strings(sorted)
+		return fmt.Errorf("cannot remove task %q: needed by %s", name, strings.Join(sorted, ", "))
+	}
+
+	for _, dep := range d.Edges[name] {
+		d.ReverseEdges[dep] = slices.DeleteFunc(d.ReverseEdges[dep], func(s string) bool { return s == name })
+	}
+	delete(d.Nodes, name)
+	delete(d.Edges, name)
+	delete(d.ReverseEdges, name)
+	delete(d.InDegree, name)
+	return nil
+}
+
+// AddEdge makes task depend on dep, and adds dep to the task's Needs.
+// Adding an edge that exists is a no-op.
+func (d *DAG) AddEdge(task, dep string) error {
+	for _, name := range []string{task, dep} {
+		if _, exists := d.Nodes[name]; !exists {
+			return fmt.Errorf("task %q is not in the plan", name)
+		}
+	}
+	if task == dep {
+		return fmt.Errorf("task %q cannot depend on itself", task)
+	}
+	if slices.Contains(d.Edges[task], dep) {
+		return nil
+	}
+	if path := d.dependencyPath(dep, task); path != nil {
+		cycle := append([]string{task}, path...)
+		return fmt.Errorf("%q needing %q would create a cycle: %s", task, dep, strings.Join(cycle, " -> "))
+	}
+
+	d.link(task, dep)
+	cfg := d.Nodes[task]
+	cfg.Needs = append(slices.Clone(cfg.Needs), dep)
+	d.Nodes[task] = cfg
+	return nil
+}
+
+// link records that task depends on dep.
+func (d *DAG) link(task, dep string) {
+	d.Edges[task] = append(d.Edges[task], dep)
+	d.ReverseEdges[dep] = append(d.ReverseEdges[dep], task)
+	d.InDegree[task]++
+}
+
+// dependencyPath returns a chain of dependencies leading from one task to
+// another (from, ..., to), or nil if from doesn't depend on to.
+func (d *DAG) dependencyPath(from, to string) []string {
+	visited := make(map[string]bool)
+	var walk func(name string) []string
+	walk = func(name string) []string {
+		if name == to {
+			return []string{name}
+		}
+		if visited[name] {
+			return nil
+		}
+		visited[name] = true
+		for _, dep := range d.Edges[name] {
+			if path := walk(dep); path != nil {
+				return append([]string{name}, path...)
+			}
+		}
+		return nil
+	}
+	return walk(from)
+}
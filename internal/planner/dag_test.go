@@ -0,0 +1,85 @@
+package planner
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/adityaraj/agentflow/internal/config"
+)
+
+func TestDAGUpdates(t *testing.T) {
+	dag := BuildDAG(map[string]config.TaskConfig{
+		"analyze": {},
+		"build":   {Needs: []string{"analyze"}},
+	})
+
+	if err := dag.AddTask("test", config.TaskConfig{Needs: []string{"build"}}); err != nil {
+		t.Fatalf("AddTask: %v", err)
+	}
+	if err := dag.AddTask("lint", config.TaskConfig{}); err != nil {
+		t.Fatalf("AddTask: %v", err)
+	}
+	if err := dag.AddEdge("test", "lint"); err != nil {
+		t.Fatalf("AddEdge: %v", err)
+	}
+	if err := dag.AddEdge("test", "lint"); err != nil {
+		t.Fatalf("AddEdge of an existing edge: %v", err)
+	}
+	if got := dag.Nodes["test"].Needs; !slices.Equal(got, []string{"build", "lint"}) {
+		t.Errorf("test needs %v, want [build lint]", got)
+	}
+
+	order, err := TopologicalSort(dag)
+	if err != nil {
+		t.Fatalf("TopologicalSort: %v", err)
+	}
+	if want := []string{"analyze", "lint", "build", "test"}; !slices.Equal(order, want) {
+		t.Errorf("order = %v, want %v", order, want)
+	}
+	if levels := BuildExecutionLevels(dag); len(levels) != 3 {
+		t.Errorf("got %d levels, want 3", len(levels))
+	}
+
+	if err := dag.RemoveTask("test"); err != nil {
+		t.Fatalf("RemoveTask: %v", err)
+	}
+	if got := dag.GetDependents("lint"); len(got) != 0 {
+		t.Errorf("lint dependents after removing test = %v", got)
+	}
+	if _, err := TopologicalSort(dag); err != nil || dag.Size() != 3 {
+		t.Errorf("after RemoveTask: size %d, sort error %v", dag.Size(), err)
+	}
+}
+
+func TestDAGUpdateErrors(t *testing.T) {
+	dag := BuildDAG(map[string]config.TaskConfig{
+		"a": {},
+		"b": {Needs: []string{"a"}},
+		"c": {Needs: []string{"b"}},
+	})
+
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{"duplicate", dag.AddTask("a", config.TaskConfig{}), `"a" is already in the plan`},
+		{"missing need", dag.AddTask("d", config.TaskConfig{Needs: []string{"x"}}), `needs "x", which is not in the plan`},
+		{"self need", dag.AddTask("d", config.TaskConfig{Needs: []string{"d"}}), "cannot depend on itself"},
+		{"cycle", dag.AddEdge("a", "c"), `would create a cycle: a -> c -> b -> a`},
+		{"unknown edge", dag.AddEdge("a", "x"), `"x" is not in the plan`},
+		{"needed", dag.RemoveTask("b"), `needed by c`},
+		{"unknown remove", dag.RemoveTask("x"), `"x" is not in the plan`},
+	}
+	for _, tt := range tests {
+		if tt.err == nil || !strings.Contains(tt.err.Error(), tt.want) {
+			t.Errorf("%s: error = %v, want %q", tt.name, tt.err, tt.want)
+		}
+	}
+
+	// Rejected changes leave the DAG as it was
+	if dag.Size() != 3 || dag.InDegree["a"] != 0 || len(dag.GetDependents("c")) != 0 {
+		t.Errorf("DAG changed by rejected updates: %+v", dag)
+	}
+}
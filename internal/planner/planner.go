@@ -2,8 +2,10 @@ package planner
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/adityaraj/agentflow/internal/config"
+	"github.com/adityaraj/agentflow/internal/proc"
 )
 
 // ExecutionTask represents a task ready for execution with resolved agent info.
@@ -12,16 +14,99 @@ type ExecutionTask struct {
 	AgentName    string   // Agent reference name
 	Tool         string   // CLI tool (claude-code, opencode)
 	Model        string   // Model identifier
+	SystemPrompt string   // The agent's system prompt ("" = the tool's default)
+	Instructions string   // The agent's instructions, prepended to the prompt
 	Prompt       string   // Prompt text (resolved from prompt_file if needed)
 	Write        bool     // Allow file writes
+	WritePatch   bool     // Write in a copy of the repository, keeping the changes as a patch
 	Dependencies []string // Names of tasks this depends on
 	Workdir      string   // Working directory for agent execution
+	Args         []string // Program and arguments of a shell task given as a list (run without a shell)
+	Shell        string   // Shell for the command and verify ("" = the executor's default)
+	Verify       string   // Command run after the agent to verify its changes
+	VerifyArgs   []string // Verify command given as a list (run without a shell)
+	VerifyDir    string   // Directory verify runs in, relative to Workdir
+	FixAttempts  int      // Fix-loop retries when verification fails
+	Retries      int      // Times the agent is re-run when the task fails
+	RetryOn      []string // Exit codes or output patterns to retry on (empty = any failure)
+	MaxFiles     int      // Max files a write task may change (0 = no limit)
+	MaxLines     int      // Max lines a write task may change (0 = no limit)
+	MaxTokens    int      // Max input+output tokens the task may use (0 = no limit)
+	Memory       string   // Project memory access: "", "read", or "write"
+	Scope        string   // "diff" limits the task to files changed since the base ref
+	Paths        []string // Globs; the task is skipped if no changed file matches
+	Writes       []string // Globs of the files the task changes; overlapping tasks don't run at once
+	OutputFile   string   // Path template the task's output is saved to ("" = none)
+	When         string   // Condition the task runs on ("" = always); see config.Condition
+	Normalize    []string // Output normalization steps (nil = all; see config.NormalizeSteps)
+	Expect       string   // Output format the task must produce: "" or "json"
+
+	Schema        map[string]any // JSON Schema the output must match, with Expect (nil = any JSON)
+	ExpectRetries int            // Times the agent is re-run when its output doesn't match
+
+	RetryBackoff    time.Duration          // Pause before the first retry, doubled before each later one
+	Timeout         time.Duration          // How long the task may run before it is stopped (0 = no limit)
+	ContextOverflow string                 // Recovery strategy when the prompt overflows the context window
+	Commit          *config.CommitConfig   // Commit settings for the task's changes (nil = don't commit)
+	Context         *config.ContextConfig  // Repository files added to the prompt (nil = none)
+	Compress        *config.CompressConfig // Text the task shortens, in Prompt and Files (nil = an agent task)
+
+	// Alternates are agents interchangeable with AgentName for this task's
+	// tags, tried when AgentName has no free concurrency.
+	Alternates []AgentRef
+}
+
+// AgentRef identifies an agent and the tool/model it runs.
+type AgentRef struct {
+	Name         string
+	Tool         string
+	Model        string
+	SystemPrompt string
+	Instructions string
 }
 
 // ExecutionPlan represents an ordered list of tasks to execute.
 type ExecutionPlan struct {
-	Tasks []ExecutionTask
-	DAG   *DAG // The dependency graph for parallel execution
+	Tasks        []ExecutionTask
+	DAG          *DAG                 // The dependency graph for parallel execution
+	AgentLimits  map[string]int       // Per-agent max_concurrent (absent = no limit)
+	Preamble     string               // Text prepended to every AI task's prompt
+	Variables    map[string]string    // Values for {{vars.X}} placeholders
+	Context      map[string]string    // Shared values for {{context.X}} placeholders, filled before execution
+	Retrieved    map[string]string    // Results of {{retrieve}} placeholders, keyed by placeholder text
+	PriorRunID   string               // Last successful run, if prompts reference its outputs
+	PriorOutputs map[string]string    // Outputs of PriorRunID for {{runs.last_success.outputs.X}}
+	Diff         *DiffScope           // Changes since the base ref, if any task is diff-scoped
+	Digest       *config.DigestConfig // Markdown digest written after the run (nil = none)
+	Workflow     string               // Named workflow the plan runs ("" = the top-level tasks), recorded in the run result
+}
+
+// SharedPrompts returns the texts prepended to many tasks' prompts: the
+// preamble, then the instructions of each agent a task may run on.
+func (p *ExecutionPlan) SharedPrompts() []string {
+	texts := []string{p.Preamble}
+	seen := make(map[string]bool)
+	add := func(agent, instructions string) {
+		if instructions != "" && !seen[agent] {
+			seen[agent] = true
+			texts = append(texts, instructions)
+		}
+	}
+	for _, task := range p.Tasks {
+		add(task.AgentName, task.Instructions)
+		for _, alt := range task.Alternates {
+			add(alt.Name, alt.Instructions)
+		}
+	}
+	return texts
+}
+
+// DiffScope describes the changes diff-scoped tasks work on.
+type DiffScope struct {
+	Base     string
+	Files    []string // Changed files, relative to the working directory
+	Packages []string // Directories containing changed files ("./dir", or "." for the root)
+	Patch    string   // Unified diff of tracked files, possibly truncated
 }
 
 // BuildPlan creates an execution plan from the configuration.
@@ -47,20 +132,128 @@ func BuildPlan(cfg *config.AgentflowConfig) (*ExecutionPlan, error) {
 		if agentCfg.Tool == "shell" && taskCfg.Command != "" {
 			prompt = taskCfg.Command
 		}
+		if agentCfg.Tool == "shell" && taskCfg.CommandArgs != nil {
+			prompt = proc.FormatArgs(taskCfg.CommandArgs)
+		}
+
+		// Compress steps take their input as the prompt, so it's expanded
+		// like one
+		tool := agentCfg.Tool
+		if taskCfg.Compress != nil {
+			prompt = taskCfg.Compress.Input
+			if taskCfg.Agent == "" {
+				tool = config.CompressTool
+			}
+		}
 
 		tasks = append(tasks, ExecutionTask{
 			Name:         name,
 			AgentName:    taskCfg.Agent,
-			Tool:         agentCfg.Tool,
+			Tool:         tool,
 			Model:        agentCfg.Model,
+			SystemPrompt: agentCfg.SystemPrompt,
+			Instructions: agentCfg.Instructions,
 			Prompt:       prompt,
 			Write:        taskCfg.Write,
+			WritePatch:   taskCfg.WritePatch,
 			Dependencies: taskCfg.Needs,
-			Workdir:      cfg.Workdir,
+			Workdir:      cfg.TaskWorkdir(taskCfg),
+			Args:         taskCfg.CommandArgs,
+			Shell:        taskCfg.Shell,
+			Verify:       taskCfg.Verify,
+			VerifyArgs:   taskCfg.VerifyArgs,
+			VerifyDir:    taskCfg.VerifyWorkdir,
+			FixAttempts:  taskCfg.FixAttempts,
+			Retries:      taskCfg.Retries,
+			RetryOn:      taskCfg.RetryOn,
+			MaxFiles:     taskCfg.MaxChangedFiles,
+			MaxLines:     taskCfg.MaxChangedLines,
+			MaxTokens:    taskCfg.MaxTokens,
+			Memory:       taskCfg.Memory,
+			Scope:        taskCfg.Scope,
+			Paths:        taskCfg.Paths,
+			Writes:       taskCfg.Writes,
+			OutputFile:   taskCfg.OutputFile,
+			When:         taskCfg.When,
+			Normalize:    taskCfg.Normalize,
+			Expect:       taskCfg.Expect,
+			Commit:       taskCfg.Commit,
+
+			RetryBackoff:    retryBackoff(taskCfg),
+			Timeout:         timeout(taskCfg),
+			ContextOverflow: taskCfg.OnContextOverflow,
+			Context:         taskCfg.Context,
+			Compress:        taskCfg.Compress,
+			Alternates:      alternateAgents(cfg, taskCfg),
+			Schema:          taskCfg.Schema,
+			ExpectRetries:   taskCfg.ExpectRetries,
 		})
 	}
 
-	return &ExecutionPlan{Tasks: tasks, DAG: dag}, nil
+	limits := make(map[string]int)
+	for name, agentCfg := range cfg.Agents {
+		if agentCfg.MaxConcurrent > 0 {
+			limits[name] = agentCfg.MaxConcurrent
+		}
+	}
+
+	return &ExecutionPlan{Tasks: tasks, DAG: dag, AgentLimits: limits, Preamble: cfg.Preamble, Variables: cfg.Variables, Digest: cfg.Digest}, nil
+}
+
+// retryBackoff returns the pause before a task's first retry. The
+// validator rejects invalid durations.
+func retryBackoff(taskCfg config.TaskConfig) time.Duration {
+	if d, err := time.ParseDuration(taskCfg.RetryBackoff); err == nil {
+		return d
+	}
+	return config.DefaultRetryBackoff
+}
+
+// timeout returns how long a task may run (0 = no limit). The validator
+// rejects invalid durations.
+func timeout(taskCfg config.TaskConfig) time.Duration {
+	d, _ := time.ParseDuration(taskCfg.Timeout)
+	return d
+}
+
+// alternateAgents returns the agents a task may be routed to instead of its
+// own: members of any interchangeable group, matching one of the task's tags,
+// that also contains the task's agent.
+func alternateAgents(cfg *config.AgentflowConfig, taskCfg config.TaskConfig) []AgentRef {
+	var refs []AgentRef
+	seen := map[string]bool{taskCfg.Agent: true}
+
+	for _, tag := range taskCfg.Tags {
+		group := cfg.Interchangeable[tag]
+		if !containsString(group, taskCfg.Agent) {
+			continue
+		}
+		for _, name := range group {
+			if seen[name] {
+				continue
+			}
+			seen[name] = true
+			agentCfg := cfg.Agents[name]
+			refs = append(refs, AgentRef{
+				Name:         name,
+				Tool:         agentCfg.Tool,
+				Model:        agentCfg.Model,
+				SystemPrompt: agentCfg.SystemPrompt,
+				Instructions: agentCfg.Instructions,
+			})
+		}
+	}
+	return refs
+}
+
+// containsString reports whether list contains s.
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
 }
 
 // String returns a human-readable representation of the execution plan.
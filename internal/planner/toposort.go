@@ -54,3 +54,37 @@ func TopologicalSort(dag *DAG) ([]string, error) {
 
 	return result, nil
 }
+
+// StableOrder returns tasks in dependency order, taking the alphabetically
+// first of the tasks that are ready at each step. The order depends only on
+// the graph, so it can be diffed and scripted against.
+func StableOrder(dag *DAG) ([]string, error) {
+	inDegree := make(map[string]int)
+	var ready []string
+	for name, degree := range dag.InDegree {
+		inDegree[name] = degree
+		if degree == 0 {
+			ready = append(ready, name)
+		}
+	}
+
+	var result []string
+	for len(ready) > 0 {
+		sort.Strings(ready)
+		current := ready[0]
+		ready = ready[1:]
+		result = append(result, current)
+
+		for _, dependent := range dag.ReverseEdges[current] {
+			inDegree[dependent]--
+			if inDegree[dependent] == 0 {
+				ready = append(ready, dependent)
+			}
+		}
+	}
+
+	if len(result) != dag.Size() {
+		return nil, fmt.Errorf("cycle detected: only processed %d of %d tasks", len(result), dag.Size())
+	}
+	return result, nil
+}
@@ -0,0 +1,36 @@
+package planner
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/adityaraj/agentflow/internal/config"
+)
+
+func TestStableOrder(t *testing.T) {
+	dag := BuildDAG(map[string]config.TaskConfig{
+		"zeta":  {},
+		"alpha": {},
+		"beta":  {Needs: []string{"alpha"}},
+		"gamma": {Needs: []string{"zeta", "beta"}},
+	})
+
+	// beta is ready once alpha is done, and comes before zeta
+	want := []string{"alpha", "beta", "zeta", "gamma"}
+	for range 5 { // Map order must not matter
+		got, err := StableOrder(dag)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !slices.Equal(got, want) {
+			t.Fatalf("StableOrder = %v, want %v", got, want)
+		}
+	}
+
+	dag.Edges["alpha"] = []string{"gamma"}
+	dag.ReverseEdges["gamma"] = []string{"alpha"}
+	dag.InDegree["alpha"] = 1
+	if _, err := StableOrder(dag); err == nil {
+		t.Error("expected an error for a cycle")
+	}
+}
@@ -4,21 +4,67 @@ import (
 	"fmt"
 	"sort"
 	"strings"
+	"time"
 )
 
 // GraphFormat specifies the output format for graph rendering
 type GraphFormat string
 
 const (
-	FormatASCII GraphFormat = "ascii"
-	FormatDOT   GraphFormat = "dot"
+	FormatASCII   GraphFormat = "ascii"
+	FormatDOT     GraphFormat = "dot"
+	FormatMermaid GraphFormat = "mermaid"
 )
 
-// RenderGraph renders the DAG in the specified format
-func RenderGraph(dag *DAG, tasks []ExecutionTask, format GraphFormat) string {
+// GraphMeta describes where an exported graph came from, so a diagram
+// shared on its own still says what it shows. Empty fields are left out.
+type GraphMeta struct {
+	ConfigFile string    // Cortexfile the graph was built from
+	Workflow   string    // Named workflow, if one was selected
+	RunID      string    // Run the graph documents
+	Generated  time.Time // When the graph was rendered
+	Version    string    // Cortex version
+}
+
+// lines returns the metadata as "key: value" lines.
+func (m GraphMeta) lines() []string {
+	var lines []string
+	add := func(key, value string) {
+		if value != "" {
+			lines = append(lines, key+": "+value)
+		}
+	}
+	add("Cortexfile", m.ConfigFile)
+	add("Workflow", m.Workflow)
+	add("Run", m.RunID)
+	if !m.Generated.IsZero() {
+		add("Generated", m.Generated.UTC().Format(time.RFC3339))
+	}
+	if m.Version != "" {
+		add("Cortex", "v"+strings.TrimPrefix(m.Version, "v"))
+	}
+	return lines
+}
+
+// graphLegend explains the DOT and Mermaid drawings.
+var graphLegend = []string{
+	"Box: task (tool/model)",
+	"Arrow: runs before",
+	"Dashed group: level (tasks run in parallel)",
+}
+
+// dotLegendID is the DOT legend's node ID. Task names can't hold a space,
+// so no task's node can take it.
+const dotLegendID = "cortex legend"
+
+// RenderGraph renders the DAG in the specified format. meta labels the DOT
+// and Mermaid exports.
+func RenderGraph(dag *DAG, tasks []ExecutionTask, format GraphFormat, meta GraphMeta) string {
 	switch format {
 	case FormatDOT:
-		return RenderDOT(dag, tasks)
+		return RenderDOT(dag, tasks, meta)
+	case FormatMermaid:
+		return RenderMermaid(dag, tasks, meta)
 	default:
 		return RenderASCII(dag, tasks)
 	}
@@ -190,12 +236,21 @@ func renderConnections(currentLevel, nextLevel ExecutionLevel, dag *DAG) string
 	return sb.String()
 }
 
-// RenderDOT renders the DAG in Graphviz DOT format
-func RenderDOT(dag *DAG, tasks []ExecutionTask) string {
+// RenderDOT renders the DAG in Graphviz DOT format, with meta as comments
+// and as the graph's label, and a legend
+func RenderDOT(dag *DAG, tasks []ExecutionTask, meta GraphMeta) string {
 	var sb strings.Builder
 
+	info := meta.lines()
+	for _, line := range info {
+		sb.WriteString("// " + line + "\n")
+	}
 	sb.WriteString("digraph ExecutionGraph {\n")
 	sb.WriteString("    rankdir=TB;\n")
+	if len(info) > 0 {
+		sb.WriteString(fmt.Sprintf("    label=%s;\n", dotQuote(strings.Join(info, "\n"))))
+		sb.WriteString("    labelloc=b;\n    labeljust=l;\n    fontsize=10;\n")
+	}
 	sb.WriteString("    node [shape=box, style=rounded, fontname=\"Arial\"];\n")
 	sb.WriteString("    edge [arrowhead=vee];\n\n")
 
@@ -216,31 +271,135 @@ func RenderDOT(dag *DAG, tasks []ExecutionTask) string {
 		sb.WriteString("        color=gray;\n")
 
 		for _, taskName := range level.Tasks {
-			label := taskName
-			if t, ok := taskInfo[taskName]; ok {
-				label = fmt.Sprintf("%s\\n(%s)", taskName, t.Tool)
-				if t.Model != "" {
-					label = fmt.Sprintf("%s\\n(%s/%s)", taskName, t.Tool, t.Model)
-				}
-			}
-			sb.WriteString(fmt.Sprintf("        \"%s\" [label=\"%s\"];\n", taskName, label))
+			sb.WriteString(fmt.Sprintf("        %s [label=%s];\n", dotQuote(taskName), dotQuote(nodeLabel(taskName, taskInfo))))
 		}
 		sb.WriteString("    }\n\n")
 	}
 
 	// Add edges (dependencies)
 	sb.WriteString("    // Dependencies\n")
-	for taskName, deps := range dag.Edges {
-		for _, dep := range deps {
-			sb.WriteString(fmt.Sprintf("    \"%s\" -> \"%s\";\n", dep, taskName))
+	for _, taskName := range sortedKeys(dag.Edges) {
+		for _, dep := range dag.Edges[taskName] {
+			sb.WriteString(fmt.Sprintf("    %s -> %s;\n", dotQuote(dep), dotQuote(taskName)))
 		}
 	}
 
+	sb.WriteString("\n    subgraph cluster_legend {\n")
+	sb.WriteString("        label=\"Legend\";\n")
+	sb.WriteString("        style=solid;\n")
+	sb.WriteString("        color=lightgray;\n")
+	sb.WriteString(fmt.Sprintf("        %s [shape=plaintext, label=%s];\n", dotQuote(dotLegendID), dotQuote(strings.Join(graphLegend, "\n"))))
+	sb.WriteString("    }\n")
+
 	sb.WriteString("}\n")
 
 	return sb.String()
 }
 
+// nodeLabel is a task's name over its tool and model, for graph nodes.
+func nodeLabel(taskName string, taskInfo map[string]ExecutionTask) string {
+	t, ok := taskInfo[taskName]
+	if !ok {
+		return taskName
+	}
+	if t.Model != "" {
+		return fmt.Sprintf("%s\n(%s/%s)", taskName, t.Tool, t.Model)
+	}
+	return fmt.Sprintf("%s\n(%s)", taskName, t.Tool)
+}
+
+// dotQuote returns s as a quoted DOT ID. Newlines become \n line breaks.
+func dotQuote(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `"`, `\"`)
+	s = strings.ReplaceAll(s, "\n", `\n`)
+	return `"` + s + `"`
+}
+
+// RenderMermaid renders the DAG as a Mermaid flowchart, for Markdown that
+// GitHub and GitLab render as a diagram. meta is written as comments and,
+// with the legend, as a note below the graph.
+func RenderMermaid(dag *DAG, tasks []ExecutionTask, meta GraphMeta) string {
+	var sb strings.Builder
+	info := meta.lines()
+	for _, line := range info {
+		sb.WriteString("%% " + line + "\n")
+	}
+	sb.WriteString("flowchart TB\n")
+
+	taskInfo := make(map[string]ExecutionTask)
+	for _, t := range tasks {
+		taskInfo[t.Name] = t
+	}
+
+	// Node IDs are generated, since Mermaid IDs can't hold every character
+	// a name might, and some words (end) are reserved
+	ids := make(map[string]string)
+	levels := BuildExecutionLevels(dag)
+	for levelIdx, level := range levels {
+		sb.WriteString(fmt.Sprintf("    subgraph level%d [\"Level %d\"]\n", levelIdx, levelIdx))
+		for _, taskName := range level.Tasks {
+			id := fmt.Sprintf("t%d", len(ids))
+			ids[taskName] = id
+			sb.WriteString(fmt.Sprintf("        %s(%s)\n", id, mermaidQuote(nodeLabel(taskName, taskInfo))))
+		}
+		sb.WriteString("    end\n")
+	}
+
+	for _, taskName := range sortedKeys(dag.Edges) {
+		for _, dep := range dag.Edges[taskName] {
+			sb.WriteString(fmt.Sprintf("    %s --> %s\n", ids[dep], ids[taskName]))
+		}
+	}
+
+	// The legend is a separate box, so it isn't drawn as part of the flow.
+	// Its IDs come next in the generated sequences, so none is a task's
+	note := strings.Join(append(append([]string{}, graphLegend...), info...), "\n")
+	legendID := fmt.Sprintf("level%d", len(levels))
+	sb.WriteString(fmt.Sprintf("    subgraph %s [\"Legend\"]\n", legendID))
+	sb.WriteString(fmt.Sprintf("        t%d[%s]\n", len(ids), mermaidQuote(note)))
+	sb.WriteString("    end\n")
+	sb.WriteString(fmt.Sprintf("    style %s fill:none,stroke-dasharray:3\n", legendID))
+
+	return sb.String()
+}
+
+// mermaidQuote returns s as a quoted Mermaid label. Quotes and the
+// characters Mermaid reads as markup are written as entity codes, and
+// newlines as line breaks.
+func mermaidQuote(s string) string {
+	var sb strings.Builder
+	sb.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			sb.WriteString("#quot;")
+		case '#':
+			sb.WriteString("#35;")
+		case '<':
+			sb.WriteString("#lt;")
+		case '>':
+			sb.WriteString("#gt;")
+		case '\n':
+			sb.WriteString("<br/>")
+		default:
+			sb.WriteRune(r)
+		}
+	}
+	sb.WriteByte('"')
+	return sb.String()
+}
+
+// sortedKeys returns the keys of m in order, so output is stable.
+func sortedKeys(m map[string][]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
 // RenderCompact renders a compact single-line representation of the DAG
 func RenderCompact(dag *DAG) string {
 	levels := BuildExecutionLevels(dag)
@@ -0,0 +1,126 @@
+package planner
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/adityaraj/agentflow/internal/config"
+)
+
+// legendGraph is a two-task graph whose first task is named "legend", so
+// tests catch a legend node that takes its ID.
+func legendGraph() (*DAG, []ExecutionTask) {
+	dag := BuildDAG(map[string]config.TaskConfig{
+		"legend": {},
+		"review": {Needs: []string{"legend"}},
+	})
+	tasks := []ExecutionTask{
+		{Name: "legend", Tool: "claude-code"},
+		{Name: "review", Tool: "codex", Model: "o3"},
+	}
+	return dag, tasks
+}
+
+var testMeta = GraphMeta{
+	ConfigFile: "Cortexfile",
+	Workflow:   "ci",
+	RunID:      "r1",
+	Generated:  time.Date(2026, 3, 10, 9, 0, 0, 0, time.UTC),
+	Version:    "1.2.0",
+}
+
+func TestRenderDOT(t *testing.T) {
+	dag, tasks := legendGraph()
+	want := `// Cortexfile: Cortexfile
+// Workflow: ci
+// Run: r1
+// Generated: 2026-03-10T09:00:00Z
+// Cortex: v1.2.0
+digraph ExecutionGraph {
+    rankdir=TB;
+    label="Cortexfile: Cortexfile\nWorkflow: ci\nRun: r1\nGenerated: 2026-03-10T09:00:00Z\nCortex: v1.2.0";
+    labelloc=b;
+    labeljust=l;
+    fontsize=10;
+    node [shape=box, style=rounded, fontname="Arial"];
+    edge [arrowhead=vee];
+
+    subgraph cluster_level0 {
+        label="Level 0";
+        style=dashed;
+        color=gray;
+        "legend" [label="legend\n(claude-code)"];
+    }
+
+    subgraph cluster_level1 {
+        label="Level 1";
+        style=dashed;
+        color=gray;
+        "review" [label="review\n(codex/o3)"];
+    }
+
+    // Dependencies
+    "legend" -> "review";
+
+    subgraph cluster_legend {
+        label="Legend";
+        style=solid;
+        color=lightgray;
+        "cortex legend" [shape=plaintext, label="Box: task (tool/model)\nArrow: runs before\nDashed group: level (tasks run in parallel)"];
+    }
+}
+`
+	if got := RenderDOT(dag, tasks, testMeta); got != want {
+		t.Errorf("RenderDOT() =\n%s\nwant\n%s", got, want)
+	}
+
+	// Without metadata there are no comments and no graph label
+	got := RenderDOT(dag, tasks, GraphMeta{})
+	if !strings.HasPrefix(got, "digraph ExecutionGraph {\n    rankdir=TB;\n    node ") {
+		t.Errorf("RenderDOT() without metadata starts\n%s", got[:min(len(got), 80)])
+	}
+	if !strings.Contains(got, `"cortex legend" [shape=plaintext`) {
+		t.Errorf("RenderDOT() without metadata has no legend:\n%s", got)
+	}
+}
+
+func TestRenderMermaid(t *testing.T) {
+	dag, tasks := legendGraph()
+	want := `%% Cortexfile: Cortexfile
+%% Workflow: ci
+%% Run: r1
+%% Generated: 2026-03-10T09:00:00Z
+%% Cortex: v1.2.0
+flowchart TB
+    subgraph level0 ["Level 0"]
+        t0("legend<br/>(claude-code)")
+    end
+    subgraph level1 ["Level 1"]
+        t1("review<br/>(codex/o3)")
+    end
+    t0 --> t1
+    subgraph level2 ["Legend"]
+        t2["Box: task (tool/model)<br/>Arrow: runs before<br/>Dashed group: level (tasks run in parallel)<br/>Cortexfile: Cortexfile<br/>Workflow: ci<br/>Run: r1<br/>Generated: 2026-03-10T09:00:00Z<br/>Cortex: v1.2.0"]
+    end
+    style level2 fill:none,stroke-dasharray:3
+`
+	if got := RenderMermaid(dag, tasks, testMeta); got != want {
+		t.Errorf("RenderMermaid() =\n%s\nwant\n%s", got, want)
+	}
+
+	// Without metadata the note is only the legend
+	got := RenderMermaid(dag, tasks, GraphMeta{})
+	if !strings.HasPrefix(got, "flowchart TB\n") {
+		t.Errorf("RenderMermaid() without metadata starts\n%s", got[:min(len(got), 80)])
+	}
+	if !strings.Contains(got, `t2["Box: task (tool/model)<br/>Arrow: runs before<br/>Dashed group: level (tasks run in parallel)"]`) {
+		t.Errorf("RenderMermaid() without metadata has no legend:\n%s", got)
+	}
+}
+
+func TestMermaidQuote(t *testing.T) {
+	if got, want := mermaidQuote("a \"b\" <c> #d\ne"), `"a #quot;b#quot; #lt;c#gt; #35;d<br/>e"`; got != want {
+		t.Errorf("mermaidQuote() = %s, want %s", got, want)
+	}
+}
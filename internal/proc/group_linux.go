@@ -0,0 +1,60 @@
+package proc
+
+import (
+	"bytes"
+	"os"
+	"strconv"
+)
+
+// sampleGroup lists the processes in process group pgid and their combined
+// resident memory in bytes, from /proc.
+func sampleGroup(pgid int) ([]int, int64, bool) {
+	entries, err := os.ReadDir("/proc")
+	if err != nil {
+		return nil, 0, false
+	}
+	var pids []int
+	var rss int64
+	for _, e := range entries {
+		pid, err := strconv.Atoi(e.Name())
+		if err != nil {
+			continue
+		}
+		data, err := os.ReadFile("/proc/" + e.Name() + "/stat")
+		if err != nil {
+			continue // Exited since the directory was read
+		}
+		group, pages, ok := parseStat(data)
+		if !ok || group != pgid {
+			continue
+		}
+		pids = append(pids, pid)
+		rss += pages * int64(os.Getpagesize())
+	}
+	return pids, rss, true
+}
+
+// parseStat returns the process group and resident pages from the contents
+// of /proc/<pid>/stat.
+func parseStat(data []byte) (pgrp int, rss int64, ok bool) {
+	// The command name is in parentheses and may contain spaces; the
+	// fields that matter come after it
+	end := bytes.LastIndexByte(data, ')')
+	if end < 0 {
+		return 0, 0, false
+	}
+	fields := bytes.Fields(data[end+1:])
+	// state ppid pgrp ... rss is the 24th field overall, the 22nd here
+	if len(fields) < 22 {
+		return 0, 0, false
+	}
+	pgrp, err := strconv.Atoi(string(fields[2]))
+	if err != nil {
+		return 0, 0, false
+	}
+	rss, err = strconv.ParseInt(string(fields[21]), 10, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	return pgrp, rss, true
+}
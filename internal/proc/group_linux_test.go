@@ -0,0 +1,43 @@
+package proc
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestParseStat(t *testing.T) {
+	stat := []byte("1234 (my (odd) cmd) S 1 1230 1230 0 -1 4194560 100 0 0 0 5 3 0 0 20 0 1 0 99 12345678 321 18446744073709551615")
+	pgrp, rss, ok := parseStat(stat)
+	if !ok || pgrp != 1230 || rss != 321 {
+		t.Errorf("parseStat = %d, %d, %v; want 1230, 321, true", pgrp, rss, ok)
+	}
+	if _, _, ok := parseStat([]byte("1234 (cmd")); ok {
+		t.Error("parseStat accepted a truncated stat")
+	}
+}
+
+// TestMonitor_CountsProcesses tests that a monitored command's usage counts
+// the processes it started and records their memory.
+func TestMonitor_CountsProcesses(t *testing.T) {
+	old := SampleInterval
+	SampleInterval = 20 * time.Millisecond
+	defer func() { SampleInterval = old }()
+
+	cmd := Shell(context.Background(), "sleep 0.3 & sleep 0.3; wait")
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	m := Watch(cmd)
+	if err := cmd.Wait(); err != nil {
+		t.Fatal(err)
+	}
+	u := m.Usage(cmd.ProcessState)
+
+	if u.Processes < 3 {
+		t.Errorf("Processes = %d, want at least 3 (shell and two sleeps)", u.Processes)
+	}
+	if u.PeakMemory <= 0 {
+		t.Errorf("PeakMemory = %d, want > 0", u.PeakMemory)
+	}
+}
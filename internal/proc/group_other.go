@@ -0,0 +1,8 @@
+//go:build !linux
+
+package proc
+
+// sampleGroup is unsupported on this platform.
+func sampleGroup(pgid int) ([]int, int64, bool) {
+	return nil, 0, false
+}
@@ -0,0 +1,85 @@
+// Package proc starts external commands so that cancelling them stops the
+// whole process tree they spawn, not just the direct child.
+//
+// Agents run builds and tests in subprocesses of their own; killing only the
+// agent would leave those running. On Unix each command gets its own process
+// group, which is sent SIGTERM and, after GracePeriod, SIGKILL. On Windows
+// each command gets its own console process group, which is sent
+// CTRL_BREAK_EVENT and, after GracePeriod, terminated with its descendants.
+package proc
+
+import (
+	"context"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// GracePeriod is how long a cancelled command has to exit after being asked
+// to stop before it and its children are killed.
+var GracePeriod = 5 * time.Second
+
+// Command is like exec.CommandContext, except that when ctx is done the
+// command and every process it started are asked to stop, then killed after
+// GracePeriod.
+func Command(ctx context.Context, name string, args ...string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, name, args...)
+	configure(cmd)
+	// Don't wait forever on output pipes held open by stray grandchildren
+	cmd.WaitDelay = GracePeriod + time.Second
+	return cmd
+}
+
+// Shell returns a Command that runs command with the default shell.
+func Shell(ctx context.Context, command string) *exec.Cmd {
+	return ShellWith(ctx, DefaultShell(), command)
+}
+
+// ShellWith returns a Command that runs command with the given shell,
+// passing it the way that shell expects: "/C" for cmd.exe, "-Command" for
+// PowerShell, and "-c" for POSIX shells. An empty shell selects
+// DefaultShell.
+func ShellWith(ctx context.Context, shell, command string) *exec.Cmd {
+	if shell == "" {
+		shell = DefaultShell()
+	}
+	return Command(ctx, shell, ShellFlag(shell), command)
+}
+
+// ShellFlag returns the flag that makes shell run a command string.
+func ShellFlag(shell string) string {
+	// Accept Windows paths on any platform, for shells named in config
+	name := strings.ToLower(shell[strings.LastIndexAny(shell, `/\`)+1:])
+	name = strings.TrimSuffix(name, ".exe")
+	switch name {
+	case "cmd":
+		return "/C"
+	case "powershell", "pwsh":
+		return "-Command"
+	}
+	return "-c"
+}
+
+// IsBatchScript reports whether path is a Windows batch script (.cmd or
+// .bat), such as the launchers npm installs for CLI tools. Arguments to
+// batch scripts pass through cmd.exe, which mangles newlines and special
+// characters, so long text like prompts must be sent on stdin instead.
+func IsBatchScript(path string) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+	return ext == ".cmd" || ext == ".bat"
+}
+
+// FormatArgs renders an argument list for display, quoting arguments that
+// contain spaces or quotes.
+func FormatArgs(args []string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		if arg == "" || strings.ContainsAny(arg, " \t\n\"'") {
+			arg = strconv.Quote(arg)
+		}
+		quoted[i] = arg
+	}
+	return strings.Join(quoted, " ")
+}
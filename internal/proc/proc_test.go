@@ -0,0 +1,18 @@
+package proc
+
+import "testing"
+
+func TestShellFlag(t *testing.T) {
+	tests := map[string]string{
+		"/bin/sh":                     "-c",
+		"bash":                        "-c",
+		`C:\Windows\System32\cmd.exe`: "/C",
+		"pwsh":                        "-Command",
+		"powershell.exe":              "-Command",
+	}
+	for shell, want := range tests {
+		if got := ShellFlag(shell); got != want {
+			t.Errorf("ShellFlag(%q) = %q, want %q", shell, got, want)
+		}
+	}
+}
@@ -0,0 +1,46 @@
+//go:build !windows
+
+package proc
+
+import (
+	"os"
+	"os/exec"
+	"runtime"
+	"syscall"
+	"time"
+)
+
+// DefaultShell returns the shell commands run with: /bin/sh.
+func DefaultShell() string {
+	return "/bin/sh"
+}
+
+// configure starts cmd in a new process group and makes cancellation signal
+// the whole group.
+func configure(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		pgid := cmd.Process.Pid
+		if err := syscall.Kill(-pgid, syscall.SIGTERM); err != nil {
+			return cmd.Process.Kill()
+		}
+		time.AfterFunc(GracePeriod, func() {
+			_ = syscall.Kill(-pgid, syscall.SIGKILL)
+		})
+		return nil
+	}
+}
+
+// maxRSS returns the peak resident memory in bytes of the largest process
+// among a command and the descendants it waited for.
+func maxRSS(state *os.ProcessState) int64 {
+	ru, ok := state.SysUsage().(*syscall.Rusage)
+	if !ok {
+		return 0
+	}
+	// macOS reports bytes, other systems kilobytes
+	if runtime.GOOS == "darwin" || runtime.GOOS == "ios" {
+		return int64(ru.Maxrss)
+	}
+	return int64(ru.Maxrss) * 1024
+}
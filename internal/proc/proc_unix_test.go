@@ -0,0 +1,51 @@
+//go:build !windows
+
+package proc
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// TestCommand_CancelStopsChildren tests that cancelling a command also stops
+// the processes it started.
+func TestCommand_CancelStopsChildren(t *testing.T) {
+	pidFile := filepath.Join(t.TempDir(), "child.pid")
+	ctx, cancel := context.WithCancel(context.Background())
+	cmd := Shell(ctx, "sleep 30 & echo $! > "+pidFile+"; wait")
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	var child int
+	for deadline := time.Now().Add(5 * time.Second); child == 0 && time.Now().Before(deadline); {
+		data, _ := os.ReadFile(pidFile)
+		child, _ = strconv.Atoi(strings.TrimSpace(string(data)))
+		time.Sleep(10 * time.Millisecond)
+	}
+	if child == 0 {
+		t.Fatal("child process didn't start")
+	}
+
+	start := time.Now()
+	cancel()
+	_ = cmd.Wait()
+	if elapsed := time.Since(start); elapsed > GracePeriod {
+		t.Errorf("Wait() took %s after cancel, want under %s", elapsed, GracePeriod)
+	}
+
+	// The child may linger briefly as a zombie of the exited shell
+	for deadline := time.Now().Add(2 * time.Second); time.Now().Before(deadline); {
+		if syscall.Kill(child, 0) != nil {
+			return
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Errorf("child process %d still running after cancel", child)
+}
@@ -0,0 +1,61 @@
+//go:build windows
+
+package proc
+
+import (
+	"os"
+	"os/exec"
+	"strconv"
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/windows"
+)
+
+// DefaultShell returns the shell commands run with: sh when a POSIX shell
+// (such as the one in Git for Windows) is on PATH, since Cortexfile commands
+// are usually written for one, and cmd.exe otherwise.
+func DefaultShell() string {
+	if sh, err := exec.LookPath("sh"); err == nil {
+		return sh
+	}
+	if comspec := os.Getenv("ComSpec"); comspec != "" {
+		return comspec
+	}
+	return "cmd.exe"
+}
+
+// configure starts cmd in a new console process group and makes
+// cancellation stop the whole process tree.
+func configure(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{CreationFlags: windows.CREATE_NEW_PROCESS_GROUP}
+	cmd.Cancel = func() error {
+		pid := cmd.Process.Pid
+		// Processes without a console ignore Ctrl+Break; kill them outright
+		if err := windows.GenerateConsoleCtrlEvent(windows.CTRL_BREAK_EVENT, uint32(pid)); err != nil {
+			return killTree(pid)
+		}
+		// Holding a handle keeps the PID from being reused until we check it
+		handle, err := windows.OpenProcess(windows.SYNCHRONIZE, false, uint32(pid))
+		if err != nil {
+			return nil // Already gone
+		}
+		time.AfterFunc(GracePeriod, func() {
+			defer windows.CloseHandle(handle)
+			if ev, _ := windows.WaitForSingleObject(handle, 0); ev != windows.WAIT_OBJECT_0 {
+				_ = killTree(pid)
+			}
+		})
+		return nil
+	}
+}
+
+// killTree forcibly terminates pid and its descendants.
+func killTree(pid int) error {
+	return exec.Command("taskkill", "/T", "/F", "/PID", strconv.Itoa(pid)).Run()
+}
+
+// maxRSS is unknown on Windows: the exit status doesn't record memory.
+func maxRSS(state *os.ProcessState) int64 {
+	return 0
+}
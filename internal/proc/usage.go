@@ -0,0 +1,131 @@
+package proc
+
+import (
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+)
+
+// SampleInterval is how often a Monitor samples the process group of the
+// command it watches.
+var SampleInterval = 500 * time.Millisecond
+
+// Usage is the resources a command and the processes it started used.
+type Usage struct {
+	UserCPU    time.Duration // CPU time in user mode
+	SystemCPU  time.Duration // CPU time in the kernel
+	PeakMemory int64         // Peak resident memory in bytes (0 = unknown)
+	Processes  int           // Processes seen, including the command (0 = unknown)
+}
+
+// CPU returns the total CPU time.
+func (u Usage) CPU() time.Duration {
+	return u.UserCPU + u.SystemCPU
+}
+
+// Add returns the usage of running u and o one after the other: CPU times
+// and process counts add up, peak memory is the larger of the two.
+func (u Usage) Add(o Usage) Usage {
+	u.UserCPU += o.UserCPU
+	u.SystemCPU += o.SystemCPU
+	u.PeakMemory = max(u.PeakMemory, o.PeakMemory)
+	u.Processes += o.Processes
+	return u
+}
+
+// Monitor records the resource usage of a started command and the process
+// group it runs in.
+//
+// CPU times come from the command's exit status, which on Unix includes the
+// descendants it waited for. Where the platform allows (Linux), the process
+// group is also sampled while the command runs, for the peak memory of all
+// its processes combined and how many processes it started; processes that
+// live shorter than SampleInterval may be missed. Elsewhere peak memory is
+// that of the largest single process (Unix) or unknown (Windows), and the
+// process count is unknown.
+type Monitor struct {
+	pid  int
+	stop chan struct{}
+	done chan struct{}
+
+	mu   sync.Mutex
+	pids map[int]bool
+	peak int64
+}
+
+// Watch starts monitoring cmd, which must have been started.
+func Watch(cmd *exec.Cmd) *Monitor {
+	m := &Monitor{
+		pid:  cmd.Process.Pid,
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+		pids: make(map[int]bool),
+	}
+	if !m.sample() {
+		close(m.done)
+		return m
+	}
+	go func() {
+		defer close(m.done)
+		ticker := time.NewTicker(SampleInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-m.stop:
+				return
+			case <-ticker.C:
+				m.sample()
+			}
+		}
+	}()
+	return m
+}
+
+// Run is like cmd.Run, and also returns the resources the command used.
+func Run(cmd *exec.Cmd) (Usage, error) {
+	if err := cmd.Start(); err != nil {
+		return Usage{}, err
+	}
+	m := Watch(cmd)
+	err := cmd.Wait()
+	return m.Usage(cmd.ProcessState), err
+}
+
+// sample records the processes in the command's group and their combined
+// memory. Returns false if the platform doesn't support sampling.
+func (m *Monitor) sample() bool {
+	pids, rss, ok := sampleGroup(m.pid)
+	if !ok {
+		return false
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, pid := range pids {
+		m.pids[pid] = true
+	}
+	m.peak = max(m.peak, rss)
+	return true
+}
+
+// Usage stops monitoring and returns the command's usage. state is the
+// command's ProcessState after Wait; it may be nil if the command couldn't
+// be waited for.
+func (m *Monitor) Usage(state *os.ProcessState) Usage {
+	select {
+	case <-m.stop:
+	default:
+		close(m.stop)
+	}
+	<-m.done
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	u := Usage{PeakMemory: m.peak, Processes: len(m.pids)}
+	if state != nil {
+		u.UserCPU = state.UserTime()
+		u.SystemCPU = state.SystemTime()
+		u.PeakMemory = max(u.PeakMemory, maxRSS(state))
+	}
+	return u
+}
@@ -0,0 +1,243 @@
+// Package provenance records which agents, models, and prompts produced the
+// file changes of a run, as a signed in-toto statement with a SLSA
+// provenance predicate.
+//
+// The statement is wrapped in a DSSE envelope signed with an ed25519 key
+// kept under ~/.cortex/provenance, so the record can be checked later with
+// the matching public key.
+package provenance
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/adityaraj/agentflow/internal/state"
+)
+
+// File is the name of the signed provenance saved in a run directory.
+const File = "provenance.intoto.json"
+
+// Type identifiers.
+const (
+	StatementType = "https://in-toto.io/Statement/v1"
+	PredicateType = "https://slsa.dev/provenance/v1"
+	BuildType     = "https://github.com/adityaraj-09/cortex/run/v1"
+	BuilderID     = "https://github.com/adityaraj-09/cortex"
+)
+
+// Statement is an in-toto statement about the files a run changed.
+type Statement struct {
+	Type          string    `json:"_type"`
+	Subject       []Subject `json:"subject"`
+	PredicateType string    `json:"predicateType"`
+	Predicate     Predicate `json:"predicate"`
+}
+
+// Subject is a file the run left changed, with its content digest.
+type Subject struct {
+	Name   string            `json:"name"` // Path relative to the repository root
+	Digest map[string]string `json:"digest"`
+}
+
+// Predicate is a SLSA v1 provenance predicate.
+type Predicate struct {
+	BuildDefinition BuildDefinition `json:"buildDefinition"`
+	RunDetails      RunDetails      `json:"runDetails"`
+}
+
+// BuildDefinition describes what the run was asked to do.
+type BuildDefinition struct {
+	BuildType            string               `json:"buildType"`
+	ExternalParameters   ExternalParameters   `json:"externalParameters"`
+	InternalParameters   InternalParameters   `json:"internalParameters"`
+	ResolvedDependencies []ResourceDescriptor `json:"resolvedDependencies,omitempty"`
+}
+
+// ExternalParameters are the inputs the user controls.
+type ExternalParameters struct {
+	Workflow   string `json:"workflow"`             // Cortexfile path
+	ConfigHash string `json:"configHash,omitempty"` // Hash of the Cortexfile
+}
+
+// InternalParameters attribute each changed file to the tasks that changed it.
+type InternalParameters struct {
+	Tasks []TaskRecord `json:"tasks"`
+}
+
+// TaskRecord records a write task and the files it changed.
+type TaskRecord struct {
+	Name       string   `json:"name"`
+	Agent      string   `json:"agent"`
+	Tool       string   `json:"tool"`
+	Model      string   `json:"model,omitempty"`
+	PromptHash string   `json:"promptHash"` // Hash of the final prompt the agent received
+	Files      []string `json:"files"`
+	Commit     string   `json:"commit,omitempty"` // Commit the changes were recorded in, if any
+}
+
+// ResourceDescriptor identifies an input of the run, such as the commit it
+// started from.
+type ResourceDescriptor struct {
+	URI    string            `json:"uri"`
+	Digest map[string]string `json:"digest,omitempty"`
+}
+
+// RunDetails describes the run itself.
+type RunDetails struct {
+	Builder  Builder  `json:"builder"`
+	Metadata Metadata `json:"metadata"`
+}
+
+// Builder identifies what produced the changes.
+type Builder struct {
+	ID      string            `json:"id"`
+	Version map[string]string `json:"version,omitempty"` // Cortex and tool CLI versions
+}
+
+// Metadata records when the run happened.
+type Metadata struct {
+	InvocationID string    `json:"invocationId"`
+	StartedOn    time.Time `json:"startedOn"`
+	FinishedOn   time.Time `json:"finishedOn"`
+}
+
+// Options are the run details that aren't part of its result.
+type Options struct {
+	Root       string            // Repository root the changed paths are relative to
+	Workflow   string            // Cortexfile path
+	ConfigHash string            // Hash of the Cortexfile
+	Commit     string            // Commit checked out when the run started
+	Versions   map[string]string // Cortex and tool versions
+}
+
+// Build creates the statement for a run's write tasks. Files are attributed
+// to every successful task whose changes included them; write tasks that
+// run concurrently share the working tree, so a file may be attributed to
+// more than one. Deleted files are listed under their tasks but aren't
+// subjects, as they have no content to digest. Returns nil if no write task
+// changed anything.
+func Build(run *state.RunResult, opts Options) (*Statement, error) {
+	var tasks []TaskRecord
+	files := make(map[string]bool)
+	for _, t := range run.Tasks {
+		if !t.Success || t.Changes == nil || t.Changes.Reverted || len(t.Changes.Files) == 0 {
+			continue
+		}
+		record := TaskRecord{
+			Name:       t.TaskName,
+			Agent:      t.Agent,
+			Tool:       t.Tool,
+			Model:      t.Model,
+			PromptHash: state.Hash(t.Prompt),
+			Files:      t.Changes.Files,
+		}
+		if t.Commit != nil {
+			record.Commit = t.Commit.SHA
+		}
+		tasks = append(tasks, record)
+		for _, f := range t.Changes.Files {
+			files[f] = true
+		}
+	}
+	if len(tasks) == 0 {
+		return nil, nil
+	}
+
+	names := make([]string, 0, len(files))
+	for f := range files {
+		names = append(names, f)
+	}
+	sort.Strings(names)
+
+	var subjects []Subject
+	for _, name := range names {
+		digest, err := FileDigest(filepath.Join(opts.Root, filepath.FromSlash(name)))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		subjects = append(subjects, Subject{Name: name, Digest: map[string]string{"sha256": digest}})
+	}
+
+	stmt := &Statement{
+		Type:          StatementType,
+		Subject:       subjects,
+		PredicateType: PredicateType,
+		Predicate: Predicate{
+			BuildDefinition: BuildDefinition{
+				BuildType: BuildType,
+				ExternalParameters: ExternalParameters{
+					Workflow:   opts.Workflow,
+					ConfigHash: opts.ConfigHash,
+				},
+				InternalParameters: InternalParameters{Tasks: tasks},
+			},
+			RunDetails: RunDetails{
+				Builder: Builder{ID: BuilderID, Version: opts.Versions},
+				Metadata: Metadata{
+					InvocationID: run.RunID,
+					StartedOn:    run.StartTime,
+					FinishedOn:   run.EndTime,
+				},
+			},
+		},
+	}
+	if opts.Commit != "" {
+		stmt.Predicate.BuildDefinition.ResolvedDependencies = []ResourceDescriptor{
+			{URI: "git+file://" + filepath.ToSlash(opts.Root), Digest: map[string]string{"gitCommit": opts.Commit}},
+		}
+	}
+	return stmt, nil
+}
+
+// FileDigest returns the hex sha256 of a file's content.
+func FileDigest(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// Write signs stmt and saves the envelope in runDir.
+func Write(runDir string, stmt *Statement, key *Key) (string, error) {
+	env, err := Sign(stmt, key)
+	if err != nil {
+		return "", err
+	}
+	data, err := json.MarshalIndent(env, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	path := filepath.Join(runDir, File)
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return "", fmt.Errorf("failed to write provenance: %w", err)
+	}
+	return path, nil
+}
+
+// Load reads the envelope saved in runDir.
+func Load(runDir string) (*Envelope, error) {
+	data, err := os.ReadFile(filepath.Join(runDir, File))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("no provenance was recorded for this run")
+		}
+		return nil, err
+	}
+
+	var env Envelope
+	if err := json.Unmarshal(data, &env); err != nil {
+		return nil, fmt.Errorf("invalid provenance: %w", err)
+	}
+	return &env, nil
+}
@@ -0,0 +1,111 @@
+package provenance
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/adityaraj/agentflow/internal/state"
+)
+
+func TestBuild(t *testing.T) {
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "main.go"), []byte("package main\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	run := &state.RunResult{
+		RunID: "20250101-120000",
+		Tasks: []state.TaskResult{
+			{TaskName: "analyze", Agent: "reviewer", Tool: "claude-code", Success: true},
+			{TaskName: "implement", Agent: "coder", Tool: "claude-code", Model: "sonnet", Prompt: "write it", Success: true,
+				Changes: &state.ChangeSummary{Files: []string{"main.go", "old.go"}}},
+			{TaskName: "reverted", Agent: "coder", Tool: "claude-code", Success: false,
+				Changes: &state.ChangeSummary{Files: []string{"huge.go"}, Reverted: true}},
+		},
+	}
+
+	stmt, err := Build(run, Options{Root: root, Commit: "abc123"})
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+	if len(stmt.Subject) != 1 || stmt.Subject[0].Name != "main.go" {
+		t.Fatalf("subjects = %+v, want only main.go (old.go was deleted)", stmt.Subject)
+	}
+	if got := stmt.Subject[0].Digest["sha256"]; len(got) != 64 {
+		t.Errorf("digest = %q, want a hex sha256", got)
+	}
+
+	tasks := stmt.Predicate.BuildDefinition.InternalParameters.Tasks
+	if len(tasks) != 1 || tasks[0].Name != "implement" || tasks[0].Model != "sonnet" {
+		t.Fatalf("tasks = %+v, want only implement", tasks)
+	}
+	if tasks[0].PromptHash != state.Hash("write it") {
+		t.Errorf("prompt hash = %q", tasks[0].PromptHash)
+	}
+	if deps := stmt.Predicate.BuildDefinition.ResolvedDependencies; len(deps) != 1 || deps[0].Digest["gitCommit"] != "abc123" {
+		t.Errorf("dependencies = %+v", deps)
+	}
+
+	// Runs without write changes have no provenance
+	run.Tasks = run.Tasks[:1]
+	if stmt, err := Build(run, Options{Root: root}); err != nil || stmt != nil {
+		t.Errorf("Build() = %v, %v, want nil, nil", stmt, err)
+	}
+}
+
+func TestSignAndVerify(t *testing.T) {
+	keyDir := t.TempDir()
+	key, err := LoadOrCreateKey(keyDir)
+	if err != nil {
+		t.Fatalf("LoadOrCreateKey() error = %v", err)
+	}
+	again, err := LoadOrCreateKey(keyDir)
+	if err != nil || again.ID() != key.ID() {
+		t.Fatalf("reloaded key = %v, %v; want the same key", again, err)
+	}
+	pub, err := LoadPublicKey(filepath.Join(keyDir, PublicKeyFile))
+	if err != nil {
+		t.Fatalf("LoadPublicKey() error = %v", err)
+	}
+
+	stmt := &Statement{Type: StatementType, PredicateType: PredicateType,
+		Subject: []Subject{{Name: "main.go", Digest: map[string]string{"sha256": "00"}}}}
+	runDir := t.TempDir()
+	if _, err := Write(runDir, stmt, key); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	env, err := Load(runDir)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	got, err := Verify(env, pub)
+	if err != nil {
+		t.Fatalf("Verify() error = %v", err)
+	}
+	if got.Subject[0].Name != "main.go" {
+		t.Errorf("subject = %+v", got.Subject)
+	}
+
+	// A tampered payload fails verification
+	tampered := *stmt
+	tampered.Subject = []Subject{{Name: "evil.go", Digest: map[string]string{"sha256": "00"}}}
+	forged, err := Sign(&tampered, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	env.Payload = forged.Payload
+	if _, err := Verify(env, pub); err == nil {
+		t.Error("Verify() accepted a payload that doesn't match its signature")
+	}
+
+	// So does a different key
+	other, err := LoadOrCreateKey(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Verify(forged, other.Public); err == nil {
+		t.Error("Verify() accepted a signature by another key")
+	}
+}
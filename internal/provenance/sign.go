@@ -0,0 +1,179 @@
+package provenance
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// PayloadType is the DSSE payload type of an in-toto statement.
+const PayloadType = "application/vnd.in-toto+json"
+
+// Key file names in the key directory.
+const (
+	PrivateKeyFile = "key.pem"
+	PublicKeyFile  = "key.pub"
+)
+
+// Envelope is a DSSE envelope holding a signed statement.
+type Envelope struct {
+	PayloadType string      `json:"payloadType"`
+	Payload     string      `json:"payload"` // Base64 of the statement JSON
+	Signatures  []Signature `json:"signatures"`
+}
+
+// Signature is a DSSE signature.
+type Signature struct {
+	KeyID string `json:"keyid"`
+	Sig   string `json:"sig"` // Base64 ed25519 signature of the PAE
+}
+
+// Key is an ed25519 signing key.
+type Key struct {
+	Private ed25519.PrivateKey
+	Public  ed25519.PublicKey
+}
+
+// ID identifies the key: the hex sha256 of its public key.
+func (k *Key) ID() string {
+	return keyID(k.Public)
+}
+
+func keyID(pub ed25519.PublicKey) string {
+	sum := sha256.Sum256(pub)
+	return hex.EncodeToString(sum[:])
+}
+
+// LoadOrCreateKey loads the signing key from dir, generating one (and
+// saving its public half beside it for distribution) on first use.
+func LoadOrCreateKey(dir string) (*Key, error) {
+	path := filepath.Join(dir, PrivateKeyFile)
+	data, err := os.ReadFile(path)
+	if err == nil {
+		return parsePrivateKey(data)
+	}
+	if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		return nil, err
+	}
+	pubDER, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create key directory: %w", err)
+	}
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0600); err != nil {
+		return nil, fmt.Errorf("failed to save signing key: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, PublicKeyFile), pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pubDER}), 0644); err != nil {
+		return nil, fmt.Errorf("failed to save public key: %w", err)
+	}
+	return &Key{Private: priv, Public: pub}, nil
+}
+
+func parsePrivateKey(data []byte) (*Key, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("signing key is not PEM encoded")
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("invalid signing key: %w", err)
+	}
+	priv, ok := parsed.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("signing key is not an ed25519 key")
+	}
+	return &Key{Private: priv, Public: priv.Public().(ed25519.PublicKey)}, nil
+}
+
+// LoadPublicKey reads a PEM-encoded ed25519 public key.
+func LoadPublicKey(path string) (ed25519.PublicKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%s is not PEM encoded", path)
+	}
+	parsed, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("invalid public key %s: %w", path, err)
+	}
+	pub, ok := parsed.(ed25519.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("%s is not an ed25519 key", path)
+	}
+	return pub, nil
+}
+
+// Sign wraps stmt in a DSSE envelope signed with key.
+func Sign(stmt *Statement, key *Key) (*Envelope, error) {
+	payload, err := json.Marshal(stmt)
+	if err != nil {
+		return nil, err
+	}
+	sig := ed25519.Sign(key.Private, pae(PayloadType, payload))
+	return &Envelope{
+		PayloadType: PayloadType,
+		Payload:     base64.StdEncoding.EncodeToString(payload),
+		Signatures:  []Signature{{KeyID: key.ID(), Sig: base64.StdEncoding.EncodeToString(sig)}},
+	}, nil
+}
+
+// Verify checks that env carries a valid signature by pub and returns the
+// statement it holds.
+func Verify(env *Envelope, pub ed25519.PublicKey) (*Statement, error) {
+	if env.PayloadType != PayloadType {
+		return nil, fmt.Errorf("unexpected payload type %q", env.PayloadType)
+	}
+	payload, err := base64.StdEncoding.DecodeString(env.Payload)
+	if err != nil {
+		return nil, fmt.Errorf("invalid payload: %w", err)
+	}
+
+	id := keyID(pub)
+	verified := false
+	for _, s := range env.Signatures {
+		if s.KeyID != "" && s.KeyID != id {
+			continue
+		}
+		sig, err := base64.StdEncoding.DecodeString(s.Sig)
+		if err == nil && ed25519.Verify(pub, pae(env.PayloadType, payload), sig) {
+			verified = true
+			break
+		}
+	}
+	if !verified {
+		return nil, fmt.Errorf("no valid signature by key %s", id[:12])
+	}
+
+	var stmt Statement
+	if err := json.Unmarshal(payload, &stmt); err != nil {
+		return nil, fmt.Errorf("invalid statement: %w", err)
+	}
+	return &stmt, nil
+}
+
+// pae is the DSSE pre-authentication encoding of a payload.
+func pae(payloadType string, payload []byte) []byte {
+	return fmt.Appendf(nil, "DSSEv1 %d %s %d %s", len(payloadType), payloadType, len(payload), payload)
+}
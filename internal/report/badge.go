@@ -0,0 +1,65 @@
+package report
+
+import (
+	"fmt"
+	"html"
+	"io"
+
+	"github.com/adityaraj/agentflow/internal/state"
+)
+
+// Badge colors, as on shields.io.
+const (
+	BadgePassing = "#4c1"
+	BadgeFailing = "#e05d44"
+	BadgeUnknown = "#9f9f9f"
+)
+
+// Badge is a status badge: a label on grey beside a colored message.
+type Badge struct {
+	Label   string // e.g. "cortex" or the workflow name
+	Message string // e.g. "passing · 1m30s"
+	Color   string
+}
+
+// RunBadge describes the status of run, or "no runs" if it is nil.
+func RunBadge(label string, run *state.SessionInfo) Badge {
+	switch {
+	case run == nil:
+		return Badge{Label: label, Message: "no runs", Color: BadgeUnknown}
+	case run.Success:
+		return Badge{Label: label, Message: "passing · " + state.FormatDuration(run.Duration), Color: BadgePassing}
+	}
+	return Badge{Label: label, Message: "failing · " + state.FormatDuration(run.Duration), Color: BadgeFailing}
+}
+
+// badgeCharWidth approximates the width of a character of 11px Verdana,
+// which badges use; exact widths would need font metrics.
+const badgeCharWidth = 7
+
+// RenderBadge writes b as a flat SVG badge.
+func RenderBadge(w io.Writer, b Badge) error {
+	labelWidth := len([]rune(b.Label))*badgeCharWidth + 10
+	messageWidth := len([]rune(b.Message))*badgeCharWidth + 10
+	width := labelWidth + messageWidth
+	label, message := html.EscapeString(b.Label), html.EscapeString(b.Message)
+
+	_, err := fmt.Fprintf(w, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="20" role="img" aria-label="%s: %s">
+<title>%s: %s</title>
+<linearGradient id="s" x2="0" y2="100%%"><stop offset="0" stop-color="#bbb" stop-opacity=".1"/><stop offset="1" stop-opacity=".1"/></linearGradient>
+<clipPath id="r"><rect width="%d" height="20" rx="3" fill="#fff"/></clipPath>
+<g clip-path="url(#r)"><rect width="%d" height="20" fill="#555"/><rect x="%d" width="%d" height="20" fill="%s"/><rect width="%d" height="20" fill="url(#s)"/></g>
+<g fill="#fff" text-anchor="middle" font-family="Verdana,Geneva,DejaVu Sans,sans-serif" font-size="11">
+<text x="%d" y="15" fill="#010101" fill-opacity=".3">%s</text><text x="%d" y="14">%s</text>
+<text x="%d" y="15" fill="#010101" fill-opacity=".3">%s</text><text x="%d" y="14">%s</text>
+</g>
+</svg>
+`,
+		width, label, message,
+		label, message,
+		width,
+		labelWidth, labelWidth, messageWidth, b.Color, width,
+		labelWidth/2, label, labelWidth/2, label,
+		labelWidth+messageWidth/2, message, labelWidth+messageWidth/2, message)
+	return err
+}
@@ -0,0 +1,96 @@
+package report
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/adityaraj/agentflow/internal/state"
+)
+
+// Digest describes the Markdown digest of a run.
+type Digest struct {
+	Title string   // Heading (default: "Cortex run <id>")
+	Tasks []string // Tasks to include, in the order their sections appear
+}
+
+// digestFrontMatter is the YAML front matter of a digest.
+type digestFrontMatter struct {
+	Title    string    `yaml:"title"`
+	RunID    string    `yaml:"run_id"`
+	Status   string    `yaml:"status"`
+	Started  time.Time `yaml:"started"`
+	Duration string    `yaml:"duration"`
+	Tasks    []string  `yaml:"tasks"`
+	Tokens   int       `yaml:"tokens,omitempty"`
+	CostUSD  float64   `yaml:"cost_usd,omitempty"`
+}
+
+// RenderDigest writes run as a Markdown document: YAML front matter with
+// the run's status and totals, then a section per task of d with its
+// output. Tasks that didn't run get a section saying so.
+func RenderDigest(w io.Writer, run *state.RunResult, d Digest) error {
+	if d.Title == "" {
+		d.Title = "Cortex run " + run.RunID
+	}
+	results := make(map[string]state.TaskResult, len(run.Tasks))
+	for _, t := range run.Tasks {
+		results[t.TaskName] = t
+	}
+
+	run.CalculateTotalTokens()
+	status := "success"
+	if !run.Success {
+		status = "failed"
+	}
+	var front bytes.Buffer
+	enc := yaml.NewEncoder(&front)
+	enc.SetIndent(2)
+	err := enc.Encode(digestFrontMatter{
+		Title:    d.Title,
+		RunID:    run.RunID,
+		Status:   status,
+		Started:  run.StartTime,
+		Duration: state.FormatDuration(run.EndTime.Sub(run.StartTime)),
+		Tasks:    d.Tasks,
+		Tokens:   run.TokenUsage.TotalTokens,
+		CostUSD:  run.TokenUsage.CostUSD,
+	})
+	if err != nil {
+		return err
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "---\n%s---\n\n# %s\n", front.String(), d.Title)
+	for _, name := range d.Tasks {
+		fmt.Fprintf(&b, "\n## %s\n\n", name)
+		t, ok := results[name]
+		if !ok {
+			b.WriteString("_Not run._\n")
+			continue
+		}
+		fmt.Fprintf(&b, "_Agent `%s` · %s · %s_\n", t.Agent, digestStatus(t), t.Duration)
+		if output := strings.TrimSpace(t.Stdout); output != "" {
+			fmt.Fprintf(&b, "\n%s\n", output)
+		}
+	}
+	_, err = io.WriteString(w, b.String())
+	return err
+}
+
+// digestStatus describes how a task ended.
+func digestStatus(t state.TaskResult) string {
+	switch {
+	case t.Skipped != "":
+		return "skipped: " + t.Skipped
+	case t.Success:
+		return "succeeded"
+	case t.ErrorCategory != "" && t.ErrorCategory != state.ErrorFailed:
+		return "failed (" + string(t.ErrorCategory) + ")"
+	}
+	return fmt.Sprintf("failed with exit code %d", t.ExitCode)
+}
@@ -0,0 +1,55 @@
+package report
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/adityaraj/agentflow/internal/state"
+)
+
+func TestRenderDigest(t *testing.T) {
+	start := time.Date(2024, 1, 15, 14, 30, 0, 0, time.UTC)
+	run := &state.RunResult{
+		RunID:     "20240115-143000",
+		StartTime: start,
+		EndTime:   start.Add(90 * time.Second),
+		Tasks: []state.TaskResult{
+			{TaskName: "review", Agent: "reviewer", Success: true, Duration: "40s", Stdout: "No issues.\n",
+				TokenUsage: state.TokenUsage{TotalTokens: 1200, CostUSD: 0.02}},
+			{TaskName: "analyze", Agent: "analyst", Success: true, Duration: "30s", Stdout: "## Findings\n\nAll good.",
+				TokenUsage: state.TokenUsage{TotalTokens: 800}},
+			{TaskName: "lint", Agent: "ops", ExitCode: 2, Duration: "1s", ErrorCategory: state.ErrorFailed},
+		},
+		Success: false,
+	}
+
+	var buf bytes.Buffer
+	err := RenderDigest(&buf, run, Digest{Title: "Weekly audit", Tasks: []string{"analyze", "review", "lint", "deploy"}})
+	if err != nil {
+		t.Fatalf("RenderDigest() error = %v", err)
+	}
+	md := buf.String()
+
+	if !strings.HasPrefix(md, "---\ntitle: Weekly audit\nrun_id: 20240115-143000\nstatus: failed\n") {
+		t.Errorf("digest front matter:\n%s", md)
+	}
+	for _, want := range []string{
+		"duration: 1m30s",
+		"tokens: 2000",
+		"cost_usd: 0.02",
+		"---\n\n# Weekly audit\n",
+		"## analyze\n\n_Agent `analyst` · succeeded · 30s_\n\n## Findings\n\nAll good.\n",
+		"## lint\n\n_Agent `ops` · failed with exit code 2 · 1s_\n",
+		"## deploy\n\n_Not run._\n",
+	} {
+		if !strings.Contains(md, want) {
+			t.Errorf("digest missing %q:\n%s", want, md)
+		}
+	}
+	// Sections follow the order given, not the order tasks finished
+	if strings.Index(md, "## analyze") > strings.Index(md, "## review") {
+		t.Errorf("analyze should come before review:\n%s", md)
+	}
+}
@@ -0,0 +1,74 @@
+// Package report renders run results: a self-contained HTML page, a SARIF
+// log of task findings, a Markdown digest of task outputs, and status
+// badges.
+package report
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/adityaraj/agentflow/internal/state"
+	"github.com/adityaraj/agentflow/internal/ui"
+)
+
+// FileName is the name of the report written into each run directory.
+const FileName = "report.html"
+
+// page is the data passed to the report template.
+type page struct {
+	Project   string
+	Run       *state.RunResult
+	Duration  string
+	Generated time.Time
+}
+
+var funcs = template.FuncMap{
+	// since formats t as an offset from start, e.g. "1m5s"
+	"since": func(start, t time.Time) string {
+		if start.IsZero() || t.IsZero() {
+			return ""
+		}
+		return t.Sub(start).Round(time.Second).String()
+	},
+	"edits": func(calls []state.ToolCall) int {
+		n := 0
+		for _, c := range calls {
+			if c.Edit {
+				n++
+			}
+		}
+		return n
+	},
+	"bytes": ui.FormatBytes,
+}
+
+var tmpl = template.Must(template.New("report").Funcs(funcs).Parse(pageTemplate))
+
+// Render writes an HTML report for run to w.
+func Render(w io.Writer, run *state.RunResult, project string) error {
+	return tmpl.Execute(w, page{
+		Project:   project,
+		Run:       run,
+		Duration:  state.FormatDuration(run.EndTime.Sub(run.StartTime)),
+		Generated: time.Now(),
+	})
+}
+
+// WriteFile renders the report into runDir and returns its path.
+func WriteFile(runDir string, run *state.RunResult, project string) (string, error) {
+	path := filepath.Join(runDir, FileName)
+	f, err := os.Create(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to create report: %w", err)
+	}
+	defer f.Close()
+
+	if err := Render(f, run, project); err != nil {
+		return "", fmt.Errorf("failed to render report: %w", err)
+	}
+	return path, nil
+}
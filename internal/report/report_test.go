@@ -0,0 +1,56 @@
+package report
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/adityaraj/agentflow/internal/state"
+)
+
+func TestRender(t *testing.T) {
+	start := time.Date(2024, 1, 15, 14, 30, 0, 0, time.UTC)
+	run := &state.RunResult{
+		RunID:     "20240115-143000",
+		StartTime: start,
+		EndTime:   start.Add(90 * time.Second),
+		Tasks: []state.TaskResult{{
+			TaskName:      "implement",
+			Agent:         "coder",
+			Tool:          "claude-code",
+			Prompt:        "Fix the <script> handling",
+			StartTime:     start,
+			ErrorCategory: state.ErrorFailed,
+			Resources:     &state.ResourceUsage{UserCPUMs: 2000, SystemCPUMs: 500, PeakMemory: 300 << 20, Processes: 4},
+			Transcript: []state.ToolCall{
+				{Time: start.Add(5 * time.Second), Tool: "Bash", Summary: "go test ./...", Output: "FAIL", IsError: true},
+				{Time: start.Add(65 * time.Second), Tool: "Edit", Path: "main.go", Edit: true},
+			},
+		}},
+	}
+
+	var buf bytes.Buffer
+	if err := Render(&buf, run, "demo"); err != nil {
+		t.Fatalf("Render() error = %v", err)
+	}
+	html := buf.String()
+
+	for _, want := range []string{
+		"demo · run 20240115-143000",
+		`href="#task-implement"`,
+		"2 (1 edits)",
+		"+5s",
+		"+1m5s",
+		"go test ./...",
+		"Fix the &lt;script&gt; handling",
+		"2.5s CPU · 300.0 MiB peak · 4 processes",
+	} {
+		if !strings.Contains(html, want) {
+			t.Errorf("report missing %q", want)
+		}
+	}
+	if strings.Contains(html, "<script>") {
+		t.Error("task content must be escaped")
+	}
+}
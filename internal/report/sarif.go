@@ -0,0 +1,216 @@
+package report
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/adityaraj/agentflow/internal/state"
+)
+
+// SARIFFileName is the name of the SARIF log written into a run directory.
+const SARIFFileName = "results.sarif"
+
+// Finding is an issue a review or security task reports. Tasks report
+// findings by printing them as JSON: an array of findings, or an object
+// with a "findings" array, optionally in a ```json fence.
+type Finding struct {
+	File     string `json:"file"`
+	Line     int    `json:"line,omitempty"`
+	EndLine  int    `json:"end_line,omitempty"`
+	Column   int    `json:"column,omitempty"`
+	Severity string `json:"severity,omitempty"` // error, warning, or note; also critical, high, medium, low, info
+	Rule     string `json:"rule,omitempty"`     // Identifier of the kind of issue
+	Message  string `json:"message"`
+}
+
+// jsonFence matches a fenced JSON block in Markdown output.
+var jsonFence = regexp.MustCompile("(?s)```json\\s*\\n(.*?)```")
+
+// ParseFindings extracts the findings from a task's output, returning false
+// if the output doesn't report any in the format Finding describes.
+func ParseFindings(output string) ([]Finding, bool) {
+	candidates := []string{strings.TrimSpace(output)}
+	for _, m := range jsonFence.FindAllStringSubmatch(output, -1) {
+		candidates = append(candidates, m[1])
+	}
+	for _, c := range candidates {
+		var list []Finding
+		if err := json.Unmarshal([]byte(c), &list); err != nil {
+			var wrapped struct {
+				Findings []Finding `json:"findings"`
+			}
+			if err := json.Unmarshal([]byte(c), &wrapped); err != nil || wrapped.Findings == nil {
+				continue
+			}
+			list = wrapped.Findings
+		}
+		if validFindings(list) {
+			return list, true
+		}
+	}
+	return nil, false
+}
+
+// validFindings reports whether every finding has a file and a message, so
+// unrelated JSON isn't mistaken for findings.
+func validFindings(list []Finding) bool {
+	for _, f := range list {
+		if f.File == "" || f.Message == "" {
+			return false
+		}
+	}
+	return true
+}
+
+// sarifLevel maps a finding's severity to a SARIF result level.
+func sarifLevel(severity string) string {
+	switch strings.ToLower(severity) {
+	case "error", "critical", "high":
+		return "error"
+	case "note", "low", "info":
+		return "note"
+	case "none":
+		return "none"
+	}
+	return "warning"
+}
+
+// The subset of SARIF 2.1.0 Cortex writes.
+type (
+	sarifLog struct {
+		Schema  string     `json:"$schema"`
+		Version string     `json:"version"`
+		Runs    []sarifRun `json:"runs"`
+	}
+	sarifRun struct {
+		Tool    sarifTool     `json:"tool"`
+		Results []sarifResult `json:"results"`
+	}
+	sarifTool struct {
+		Driver sarifDriver `json:"driver"`
+	}
+	sarifDriver struct {
+		Name           string      `json:"name"`
+		Version        string      `json:"version,omitempty"`
+		InformationURI string      `json:"informationUri"`
+		Rules          []sarifRule `json:"rules"`
+	}
+	sarifRule struct {
+		ID string `json:"id"`
+	}
+	sarifResult struct {
+		RuleID     string            `json:"ruleId"`
+		Level      string            `json:"level"`
+		Message    sarifMessage      `json:"message"`
+		Locations  []sarifLocation   `json:"locations"`
+		Properties map[string]string `json:"properties"`
+	}
+	sarifMessage struct {
+		Text string `json:"text"`
+	}
+	sarifLocation struct {
+		PhysicalLocation sarifPhysical `json:"physicalLocation"`
+	}
+	sarifPhysical struct {
+		ArtifactLocation sarifArtifact `json:"artifactLocation"`
+		Region           *sarifRegion  `json:"region,omitempty"`
+	}
+	sarifArtifact struct {
+		URI string `json:"uri"`
+	}
+	sarifRegion struct {
+		StartLine   int `json:"startLine"`
+		EndLine     int `json:"endLine,omitempty"`
+		StartColumn int `json:"startColumn,omitempty"`
+	}
+)
+
+// RenderSARIF writes the findings reported by the tasks of run as a SARIF
+// 2.1.0 log, for GitHub code scanning and other SARIF consumers. Findings
+// without a rule are attributed to their task ("cortex/<task>"). version is
+// Cortex's version. Returns the number of findings written.
+func RenderSARIF(w io.Writer, run *state.RunResult, version string) (int, error) {
+	out := sarifRun{Results: []sarifResult{}}
+	rules := make(map[string]bool)
+	for _, t := range run.Tasks {
+		findings, ok := ParseFindings(t.Stdout)
+		if !ok {
+			continue
+		}
+		for _, f := range findings {
+			rule := f.Rule
+			if rule == "" {
+				rule = "cortex/" + t.TaskName
+			}
+			rules[rule] = true
+
+			location := sarifLocation{PhysicalLocation: sarifPhysical{
+				ArtifactLocation: sarifArtifact{URI: artifactURI(f.File)},
+			}}
+			if f.Line > 0 {
+				location.PhysicalLocation.Region = &sarifRegion{StartLine: f.Line, StartColumn: f.Column}
+				if f.EndLine >= f.Line {
+					location.PhysicalLocation.Region.EndLine = f.EndLine
+				}
+			}
+			out.Results = append(out.Results, sarifResult{
+				RuleID:     rule,
+				Level:      sarifLevel(f.Severity),
+				Message:    sarifMessage{Text: f.Message},
+				Locations:  []sarifLocation{location},
+				Properties: map[string]string{"task": t.TaskName, "agent": t.Agent, "run": run.RunID},
+			})
+		}
+	}
+
+	ids := make([]string, 0, len(rules))
+	for id := range rules {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	out.Tool.Driver = sarifDriver{
+		Name:           "Cortex",
+		Version:        version,
+		InformationURI: "https://github.com/adityaraj-09/cortex",
+		Rules:          make([]sarifRule, len(ids)),
+	}
+	for i, id := range ids {
+		out.Tool.Driver.Rules[i] = sarifRule{ID: id}
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	err := enc.Encode(sarifLog{
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Version: "2.1.0",
+		Runs:    []sarifRun{out},
+	})
+	return len(out.Results), err
+}
+
+// artifactURI turns a finding's path into a relative SARIF URI.
+func artifactURI(path string) string {
+	return strings.TrimPrefix(filepath.ToSlash(filepath.Clean(path)), "./")
+}
+
+// WriteSARIF writes the SARIF log for run to path and returns the number
+// of findings written.
+func WriteSARIF(path string, run *state.RunResult, version string) (int, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create SARIF log: %w", err)
+	}
+	defer f.Close()
+
+	n, err := RenderSARIF(f, run, version)
+	if err != nil {
+		return 0, fmt.Errorf("failed to write SARIF log: %w", err)
+	}
+	return n, nil
+}
@@ -0,0 +1,93 @@
+package report
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/adityaraj/agentflow/internal/state"
+)
+
+func TestParseFindings(t *testing.T) {
+	tests := []struct {
+		name   string
+		output string
+		want   int
+	}{
+		{"array", `[{"file": "a.go", "line": 3, "message": "unchecked error"}]`, 1},
+		{"object", `{"findings": [{"file": "a.go", "message": "x"}, {"file": "b.go", "message": "y"}]}`, 2},
+		{"fenced", "Two issues:\n\n```json\n[{\"file\": \"a.go\", \"message\": \"x\"}]\n```\n", 1},
+		{"empty", `{"findings": []}`, 0},
+		{"prose", "Looks good to me", -1},
+		{"unrelated JSON", `[{"name": "a"}]`, -1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			findings, ok := ParseFindings(tt.output)
+			if tt.want < 0 {
+				if ok {
+					t.Errorf("ParseFindings() = %v, want none", findings)
+				}
+				return
+			}
+			if !ok || len(findings) != tt.want {
+				t.Errorf("ParseFindings() = %v, %v, want %d findings", findings, ok, tt.want)
+			}
+		})
+	}
+}
+
+func TestRenderSARIF(t *testing.T) {
+	run := &state.RunResult{
+		RunID: "20240115-143000",
+		Tasks: []state.TaskResult{
+			{
+				TaskName: "security",
+				Agent:    "reviewer",
+				Stdout: `{"findings": [
+					{"file": "./internal/db/query.go", "line": 42, "end_line": 44, "severity": "high", "rule": "sql-injection", "message": "Query built from user input"},
+					{"file": "cmd/main.go", "severity": "low", "message": "Debug flag left on"}
+				]}`,
+			},
+			{TaskName: "summary", Agent: "writer", Stdout: "All done"},
+		},
+	}
+
+	var buf bytes.Buffer
+	n, err := RenderSARIF(&buf, run, "1.0.0")
+	if err != nil {
+		t.Fatalf("RenderSARIF() error = %v", err)
+	}
+	if n != 2 {
+		t.Errorf("RenderSARIF() = %d findings, want 2", n)
+	}
+
+	var log sarifLog
+	if err := json.Unmarshal(buf.Bytes(), &log); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if log.Version != "2.1.0" || len(log.Runs) != 1 {
+		t.Fatalf("log = %+v, want one SARIF 2.1.0 run", log)
+	}
+	out := log.Runs[0]
+	if rules := out.Tool.Driver.Rules; len(rules) != 2 || rules[0].ID != "cortex/security" || rules[1].ID != "sql-injection" {
+		t.Errorf("rules = %v, want cortex/security and sql-injection", rules)
+	}
+
+	first := out.Results[0]
+	if first.RuleID != "sql-injection" || first.Level != "error" || first.Properties["task"] != "security" {
+		t.Errorf("first result = %+v", first)
+	}
+	loc := first.Locations[0].PhysicalLocation
+	if loc.ArtifactLocation.URI != "internal/db/query.go" {
+		t.Errorf("uri = %q, want it relative without ./", loc.ArtifactLocation.URI)
+	}
+	if loc.Region == nil || loc.Region.StartLine != 42 || loc.Region.EndLine != 44 {
+		t.Errorf("region = %+v, want lines 42-44", loc.Region)
+	}
+
+	second := out.Results[1]
+	if second.RuleID != "cortex/security" || second.Level != "note" || second.Locations[0].PhysicalLocation.Region != nil {
+		t.Errorf("second result = %+v, want the task's rule, note level, no region", second)
+	}
+}
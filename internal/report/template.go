@@ -0,0 +1,100 @@
+package report
+
+// pageTemplate is the HTML report layout. It has no external assets so the
+// file can be archived or attached as-is.
+const pageTemplate = `<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Cortex run {{.Run.RunID}}{{if .Project}} · {{.Project}}{{end}}</title>
+<style>
+  body { font: 14px/1.5 -apple-system, BlinkMacSystemFont, "Segoe UI", sans-serif; margin: 2rem auto; max-width: 1100px; padding: 0 1rem; color: #222; }
+  h1 { font-size: 1.4rem; margin-bottom: .2rem; }
+  h2 { font-size: 1.15rem; margin: 2rem 0 .4rem; border-bottom: 1px solid #ddd; padding-bottom: .3rem; }
+  h3 { font-size: 1rem; margin: 1rem 0 .3rem; }
+  table { border-collapse: collapse; width: 100%; }
+  th, td { text-align: left; padding: .25rem .5rem; border-bottom: 1px solid #eee; vertical-align: top; }
+  th { color: #666; font-weight: 600; }
+  pre { background: #f6f6f6; padding: .6rem; overflow-x: auto; white-space: pre-wrap; word-break: break-word; margin: .3rem 0; }
+  code { font-family: ui-monospace, SFMono-Regular, Menlo, monospace; font-size: 12.5px; }
+  .meta { color: #666; }
+  .ok { color: #1a7f37; }
+  .fail { color: #cf222e; }
+  .dim { color: #888; }
+  details > summary { cursor: pointer; }
+</style>
+</head>
+<body>
+<h1>{{if .Project}}{{.Project}} · {{end}}run {{.Run.RunID}}</h1>
+<p class="meta">
+  {{if .Run.Success}}<span class="ok">✓ succeeded</span>{{else}}<span class="fail">✗ failed</span>{{end}}
+  · started {{.Run.StartTime.Format "2006-01-02 15:04:05"}} · {{.Duration}}
+  {{with .Run.TokenUsage}}{{if .TotalTokens}} · {{.InputTokens}} in / {{.OutputTokens}} out tokens{{if .Estimated}} (partly estimated){{end}}{{end}}{{end}}
+</p>
+
+<table>
+  <tr><th>Task</th><th>Agent</th><th>Status</th><th>Duration</th><th>Tokens</th><th>CPU</th><th>Peak memory</th><th>Tool calls</th></tr>
+  {{range .Run.Tasks}}
+  <tr>
+    <td><a href="#task-{{.TaskName}}">{{.TaskName}}</a></td>
+    <td>{{.Agent}}{{if .RoutedFrom}} <span class="dim">(for {{.RoutedFrom}})</span>{{end}}</td>
+    <td>{{if .Skipped}}<span class="dim">– skipped</span>{{else if .Success}}<span class="ok">✓</span>{{else}}<span class="fail">✗ {{.ErrorCategory}}</span>{{end}}</td>
+    <td>{{.Duration}}</td>
+    <td>{{if .TokenUsage.TotalTokens}}{{if .TokenUsage.Estimated}}<span title="estimated">~</span>{{end}}{{.TokenUsage.TotalTokens}}{{end}}</td>
+    <td>{{with .Resources}}{{.CPU}}{{end}}</td>
+    <td>{{with .Resources}}{{with .PeakMemory}}{{bytes .}}{{end}}{{end}}</td>
+    <td>{{with .Transcript}}{{len .}}{{with edits .}} ({{.}} edits){{end}}{{end}}</td>
+  </tr>
+  {{end}}
+</table>
+
+{{range .Run.Tasks}}
+<h2 id="task-{{.TaskName}}">{{.TaskName}} {{if .Success}}<span class="ok">✓</span>{{else}}<span class="fail">✗</span>{{end}}</h2>
+<p class="meta">
+  {{.Agent}} · {{.Tool}}{{if .Model}} · {{.Model}}{{end}} · {{.Duration}} · exit {{.ExitCode}}
+  {{if .ErrorCategory}} · <span class="fail">{{.ErrorCategory}}</span>{{end}}
+  {{if .Skipped}} · <span class="dim">skipped: {{.Skipped}}</span>{{end}}
+  {{if .TokenUsage.TotalTokens}} · {{.TokenUsage.InputTokens}} in / {{.TokenUsage.OutputTokens}} out tokens{{if .TokenUsage.Estimated}} (estimated){{end}}{{end}}
+  {{with .Resources}} · {{.CPU}} CPU{{with .PeakMemory}} · {{bytes .}} peak{{end}}{{with .Processes}} · {{.}} processes{{end}}{{end}}
+</p>
+
+{{with .Degradation}}<p class="meta">Ran with a {{.Strategy}}d prompt after {{.Reason}} ({{.OriginalLength}} → {{.FinalLength}} chars, {{.Attempts}} attempts).</p>{{end}}
+{{with .Verification}}<p class="meta">Verify <code>{{.Command}}</code>: {{if .Success}}<span class="ok">passed</span>{{else}}<span class="fail">exit {{.ExitCode}}</span>{{end}} after {{.Attempts}} run(s).</p>{{end}}
+{{with .Changes}}
+<details><summary>{{len .Files}} files changed, {{.Lines}} lines{{if .Reverted}} <span class="fail">(reverted)</span>{{end}}</summary>
+<pre><code>{{range .Files}}{{.}}
+{{end}}</code></pre></details>
+{{end}}
+{{with .OutputFile}}<p class="meta">Output saved to <code>{{.}}</code></p>{{end}}
+{{with .Commit}}<p class="meta">Committed <code>{{.SHA}}</code> on {{.Branch}}{{if .PullRequestURL}} · <a href="{{.PullRequestURL}}">pull request</a>{{end}}{{if .Error}} · <span class="fail">{{.Error}}</span>{{end}}</p>{{end}}
+
+{{if .Transcript}}
+<h3>Tool calls</h3>
+<table>
+  <tr><th>Time</th><th>Tool</th><th>Details</th><th></th></tr>
+  {{$start := .StartTime}}
+  {{range .Transcript}}
+  <tr>
+    <td class="dim">{{with since $start .Time}}+{{.}}{{end}}</td>
+    <td><code>{{.Tool}}</code>{{if .Edit}} <span class="dim">edit</span>{{end}}</td>
+    <td>
+      <details><summary>{{if .Summary}}{{.Summary}}{{else if .Path}}{{.Path}}{{else}}<span class="dim">(no details)</span>{{end}}</summary>
+      {{if .Input}}<pre><code>{{.Input}}</code></pre>{{end}}
+      {{if .Output}}<pre><code>{{.Output}}</code></pre>{{end}}
+      </details>
+    </td>
+    <td>{{if .IsError}}<span class="fail">error</span>{{end}}</td>
+  </tr>
+  {{end}}
+</table>
+{{end}}
+
+<details><summary>Prompt</summary><pre><code>{{.Prompt}}</code></pre></details>
+{{if .Stdout}}<details><summary>Output</summary><pre><code>{{.Stdout}}</code></pre></details>{{end}}
+{{if .Stderr}}<details><summary>Stderr</summary><pre><code>{{.Stderr}}</code></pre></details>{{end}}
+{{end}}
+
+<p class="dim">Generated by cortex at {{.Generated.Format "2006-01-02 15:04:05"}}.</p>
+</body>
+</html>
+`
@@ -0,0 +1,274 @@
+package retrieval
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"math"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/adityaraj/agentflow/internal/config"
+	"github.com/adityaraj/agentflow/internal/network"
+)
+
+const (
+	// localDims is the vector size of the local embedder.
+	localDims = 512
+
+	// batchSize caps the texts sent in one embedding request.
+	batchSize = 64
+
+	// requestTimeout bounds a single embedding request.
+	requestTimeout = 60 * time.Second
+)
+
+// Embedder turns texts into vectors whose cosine similarity reflects how
+// related the texts are.
+type Embedder interface {
+	// Name identifies the provider and model; indexes built with a
+	// different name are not reused.
+	Name() string
+	Embed(ctx context.Context, texts []string) ([][]float32, error)
+}
+
+// NewEmbedder creates the embedder selected by cfg (the local one if cfg is nil).
+func NewEmbedder(cfg *config.RetrievalConfig) (Embedder, error) {
+	if cfg == nil {
+		cfg = &config.RetrievalConfig{}
+	}
+
+	switch cfg.Provider {
+	case "", config.EmbedLocal:
+		return localEmbedder{}, nil
+
+	case config.EmbedOpenAI:
+		keyEnv := cfg.APIKeyEnv
+		if keyEnv == "" {
+			keyEnv = "OPENAI_API_KEY"
+		}
+		key := os.Getenv(keyEnv)
+		if key == "" {
+			return nil, fmt.Errorf("%s is not set", keyEnv)
+		}
+		return &openAIEmbedder{
+			url:   withDefault(cfg.URL, "https://api.openai.com/v1"),
+			model: withDefault(cfg.Model, "text-embedding-3-small"),
+			key:   key,
+		}, nil
+
+	case config.EmbedOllama:
+		return &ollamaEmbedder{
+			url:   withDefault(cfg.URL, "http://localhost:11434"),
+			model: withDefault(cfg.Model, "nomic-embed-text"),
+		}, nil
+
+	default:
+		return nil, fmt.Errorf("unsupported embedding provider %q", cfg.Provider)
+	}
+}
+
+func withDefault(value, def string) string {
+	if value == "" {
+		return def
+	}
+	return strings.TrimRight(value, "/")
+}
+
+// localEmbedder hashes identifiers and words into a fixed-size term vector.
+// It needs no model or network, and matches on shared vocabulary rather
+// than meaning.
+type localEmbedder struct{}
+
+func (localEmbedder) Name() string { return config.EmbedLocal }
+
+func (localEmbedder) Embed(_ context.Context, texts []string) ([][]float32, error) {
+	vectors := make([][]float32, len(texts))
+	for i, text := range texts {
+		counts := make(map[string]int)
+		for _, term := range terms(text) {
+			counts[term]++
+		}
+
+		v := make([]float32, localDims)
+		for term, n := range counts {
+			h := fnv.New32a()
+			h.Write([]byte(term))
+			sum := h.Sum32()
+			weight := float32(1 + math.Log(float64(n)))
+			if sum&(1<<31) != 0 {
+				weight = -weight // Signed hashing keeps collisions from adding up
+			}
+			v[sum%localDims] += weight
+		}
+		vectors[i] = normalize(v)
+	}
+	return vectors, nil
+}
+
+// terms splits text into lowercase words, also splitting identifiers at
+// camelCase and snake_case boundaries.
+func terms(text string) []string {
+	var out []string
+	words := strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_'
+	})
+	for _, word := range words {
+		parts := splitIdentifier(word)
+		if len(parts) > 1 {
+			out = append(out, strings.ToLower(strings.ReplaceAll(word, "_", "")))
+		}
+		for _, part := range parts {
+			if len(part) > 1 {
+				out = append(out, strings.ToLower(part))
+			}
+		}
+	}
+	return out
+}
+
+// splitIdentifier splits "parseHTTPRequest_body" into parse, HTTP, Request, body.
+func splitIdentifier(word string) []string {
+	var parts []string
+	runes := []rune(word)
+	start := 0
+	for i := 1; i <= len(runes); i++ {
+		boundary := i == len(runes) || runes[i] == '_' ||
+			(unicode.IsUpper(runes[i]) && unicode.IsLower(runes[i-1])) ||
+			(unicode.IsUpper(runes[i]) && i+1 < len(runes) && unicode.IsLower(runes[i+1]) && unicode.IsUpper(runes[i-1]))
+		if !boundary {
+			continue
+		}
+		if part := strings.Trim(string(runes[start:i]), "_"); part != "" {
+			parts = append(parts, part)
+		}
+		start = i
+	}
+	return parts
+}
+
+// normalize scales v to unit length.
+func normalize(v []float32) []float32 {
+	var sum float64
+	for _, x := range v {
+		sum += float64(x) * float64(x)
+	}
+	if sum == 0 {
+		return v
+	}
+	norm := float32(math.Sqrt(sum))
+	for i := range v {
+		v[i] /= norm
+	}
+	return v
+}
+
+// openAIEmbedder calls an OpenAI-compatible embeddings endpoint.
+type openAIEmbedder struct {
+	url   string
+	model string
+	key   string
+}
+
+func (e *openAIEmbedder) Name() string { return config.EmbedOpenAI + "/" + e.model }
+
+func (e *openAIEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	vectors := make([][]float32, 0, len(texts))
+	for start := 0; start < len(texts); start += batchSize {
+		batch := texts[start:min(start+batchSize, len(texts))]
+
+		var resp struct {
+			Data []struct {
+				Index     int       `json:"index"`
+				Embedding []float32 `json:"embedding"`
+			} `json:"data"`
+		}
+		payload := map[string]any{"model": e.model, "input": batch}
+		headers := map[string]string{"Authorization": "Bearer " + e.key}
+		if err := post(ctx, e.url+"/embeddings", headers, payload, &resp); err != nil {
+			return nil, err
+		}
+		if len(resp.Data) != len(batch) {
+			return nil, fmt.Errorf("embeddings: got %d vectors for %d inputs", len(resp.Data), len(batch))
+		}
+
+		out := make([][]float32, len(batch))
+		for _, d := range resp.Data {
+			if d.Index < 0 || d.Index >= len(out) {
+				return nil, fmt.Errorf("embeddings: unexpected index %d", d.Index)
+			}
+			out[d.Index] = d.Embedding
+		}
+		vectors = append(vectors, out...)
+	}
+	return vectors, nil
+}
+
+// ollamaEmbedder calls a local Ollama server.
+type ollamaEmbedder struct {
+	url   string
+	model string
+}
+
+func (e *ollamaEmbedder) Name() string { return config.EmbedOllama + "/" + e.model }
+
+func (e *ollamaEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	vectors := make([][]float32, 0, len(texts))
+	for start := 0; start < len(texts); start += batchSize {
+		batch := texts[start:min(start+batchSize, len(texts))]
+
+		var resp struct {
+			Embeddings [][]float32 `json:"embeddings"`
+		}
+		payload := map[string]any{"model": e.model, "input": batch}
+		if err := post(ctx, e.url+"/api/embed", nil, payload, &resp); err != nil {
+			return nil, err
+		}
+		if len(resp.Embeddings) != len(batch) {
+			return nil, fmt.Errorf("ollama: got %d vectors for %d inputs", len(resp.Embeddings), len(batch))
+		}
+		vectors = append(vectors, resp.Embeddings...)
+	}
+	return vectors, nil
+}
+
+// post sends a JSON request and decodes a JSON response into out.
+func post(ctx context.Context, endpoint string, headers map[string]string, payload, out any) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := network.Client(0).Do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<20))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("%s returned status %d: %s", endpoint, resp.StatusCode, strings.TrimSpace(string(data)))
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,190 @@
+package retrieval
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/adityaraj/agentflow/internal/contextpack"
+	"github.com/adityaraj/agentflow/internal/glob"
+	"github.com/adityaraj/agentflow/internal/textfile"
+	"github.com/adityaraj/agentflow/internal/tokens"
+)
+
+// DefaultFilesMaxTokens is the token budget of a task's context files.
+const DefaultFilesMaxTokens = 8000
+
+// mentionBoost is added to the score of files the query names, so they
+// come before files that only share vocabulary with it.
+const mentionBoost = 1.0
+
+// contextFile is a file matching a task's context globs and how relevant
+// it is to the task's prompt.
+type contextFile struct {
+	path     string
+	text     string
+	score    float64
+	included bool
+}
+
+// Files gathers the text files under dir that patterns match (ignored
+// files left out, "!pattern" excluding) and renders them for a prompt,
+// the most relevant to query first, within maxTokens (estimated): whole
+// files while they fit, then a listing of the rest. Relevance is the
+// shared vocabulary of file and query, identifiers split at camelCase and
+// snake_case boundaries, with files the query names by path or base name
+// first. Matching files that are binary or too large to quote are left
+// out and returned as skipped.
+func Files(dir string, patterns []string, query string, maxTokens int) (text string, skipped []error, err error) {
+	if maxTokens <= 0 {
+		maxTokens = DefaultFilesMaxTokens
+	}
+	files, skipped, err := gatherFiles(dir, patterns)
+	if err != nil {
+		return "", nil, err
+	}
+	if len(files) == 0 {
+		return "(No files match " + strings.Join(patterns, ", ") + ".)", skipped, nil
+	}
+
+	texts := make([]string, len(files))
+	for i, f := range files {
+		texts[i] = f.path + "\n" + f.text
+	}
+	var embedder localEmbedder
+	vectors, _ := embedder.Embed(context.Background(), append(texts, query))
+	q := vectors[len(files)]
+	for i := range files {
+		files[i].score = cosine(q, vectors[i])
+		if mentions(query, files[i].path) {
+			files[i].score += mentionBoost
+		}
+	}
+	sort.SliceStable(files, func(i, j int) bool {
+		return files[i].score > files[j].score
+	})
+	return formatFiles(files, maxTokens), skipped, nil
+}
+
+// ReadFiles renders all the text files under dir that patterns match, as
+// Files does but in path order and without a budget, for text that is
+// shortened afterwards. Matching files that are binary or too large to
+// quote are left out and returned as skipped.
+func ReadFiles(dir string, patterns []string) (text string, skipped []error, err error) {
+	files, skipped, err := gatherFiles(dir, patterns)
+	if err != nil {
+		return "", nil, err
+	}
+	var b strings.Builder
+	for _, f := range files {
+		b.WriteString(fileBlock(f))
+	}
+	return strings.TrimRight(b.String(), "\n"), skipped, nil
+}
+
+// gatherFiles reads the text files under dir that patterns match, in path
+// order, returning the binary and oversized ones as skipped.
+func gatherFiles(dir string, patterns []string) (files []contextFile, skipped []error, err error) {
+	paths, err := contextpack.ListFiles(dir)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, p := range paths {
+		if !matchFiles(patterns, p) {
+			continue
+		}
+		data, err := readText(filepath.Join(dir, filepath.FromSlash(p)))
+		if errors.Is(err, textfile.ErrBinary) || errors.Is(err, textfile.ErrTooLarge) {
+			skipped = append(skipped, fmt.Errorf("%s: %w", p, errors.Unwrap(err)))
+		}
+		if err != nil || len(data) == 0 {
+			continue
+		}
+		files = append(files, contextFile{path: p, text: string(data)})
+	}
+	return files, skipped, nil
+}
+
+// fileBlock renders a file as a fenced block tagged with its extension,
+// after its path.
+func fileBlock(f contextFile) string {
+	return fmt.Sprintf("%s\n```%s\n%s\n```\n\n", f.path, strings.TrimPrefix(path.Ext(f.path), "."), strings.TrimRight(f.text, "\n"))
+}
+
+// matchFiles reports whether name matches one of patterns and none of
+// those starting with "!". Only exclusions means everything else matches.
+func matchFiles(patterns []string, name string) bool {
+	included, includes := false, false
+	for _, pattern := range patterns {
+		if exclude, ok := strings.CutPrefix(pattern, "!"); ok {
+			if glob.Match(exclude, name) {
+				return false
+			}
+			continue
+		}
+		includes = true
+		included = included || glob.Match(pattern, name)
+	}
+	return included || !includes
+}
+
+// mentions reports whether query names file by its path or base name.
+func mentions(query, file string) bool {
+	if strings.Contains(query, file) {
+		return true
+	}
+	base := path.Base(file)
+	for _, word := range strings.FieldsFunc(query, func(r rune) bool {
+		return strings.ContainsRune(" \t\n`'\"()[],:;", r)
+	}) {
+		if strings.TrimRight(word, ".") == base {
+			return true
+		}
+	}
+	return false
+}
+
+// formatFiles renders files, in order, as fenced blocks tagged with their
+// extension while they fit in maxTokens, then lists the rest by path.
+func formatFiles(files []contextFile, maxTokens int) string {
+	var b strings.Builder
+	included, used := 0, 0
+	for i, f := range files {
+		block := fileBlock(f)
+		n := tokens.Count(block)
+		if used+n > maxTokens {
+			continue // A smaller, less relevant file may still fit
+		}
+		b.WriteString(block)
+		used += n
+		files[i].included = true
+		included++
+	}
+	if included == len(files) {
+		return strings.TrimRight(b.String(), "\n")
+	}
+
+	b.WriteString("Other matching files, left out for space:\n")
+	listed := 0
+	for _, f := range files {
+		if f.included {
+			continue
+		}
+		line := "- " + f.path + "\n"
+		n := tokens.Count(line)
+		if used+n > maxTokens {
+			break
+		}
+		b.WriteString(line)
+		used += n
+		listed++
+	}
+	if rest := len(files) - included - listed; rest > 0 {
+		fmt.Fprintf(&b, "- … and %d more\n", rest)
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
@@ -0,0 +1,282 @@
+// Package retrieval indexes repository files as embedded chunks and finds
+// the snippets most relevant to a query, for {{retrieve "query"}} prompts.
+package retrieval
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/adityaraj/agentflow/internal/contextpack"
+	"github.com/adityaraj/agentflow/internal/textfile"
+	"github.com/adityaraj/agentflow/internal/tokens"
+)
+
+const (
+	// IndexFile is the embedding cache kept beside a project's runs.
+	IndexFile = "index.json"
+
+	// DefaultMaxTokens is the token budget of one {{retrieve}} result.
+	DefaultMaxTokens = 2000
+
+	// chunkLines is how many lines each indexed chunk spans.
+	chunkLines = 40
+
+	// maxFileSize skips files larger than this (generated or data files).
+	maxFileSize = 256 * 1024
+
+	// maxChunks caps the index size for very large repositories.
+	maxChunks = 50_000
+)
+
+// Chunk is a span of lines from one file and its embedding.
+type Chunk struct {
+	Path   string    `json:"path"`
+	Start  int       `json:"start"` // First line, 1-based
+	End    int       `json:"end"`   // Last line, inclusive
+	Text   string    `json:"text"`
+	Vector []float32 `json:"vector"`
+}
+
+// fileEntry holds a file's chunks and the content hash they were built from.
+type fileEntry struct {
+	Hash   string  `json:"hash"`
+	Chunks []Chunk `json:"chunks"`
+}
+
+// Index holds the embedded chunks of a directory's files.
+type Index struct {
+	Embedder string               `json:"embedder"`
+	Files    map[string]fileEntry `json:"files"`
+
+	embedder Embedder
+}
+
+// Build indexes the text files under dir. Chunks of files unchanged since
+// the index cached at cachePath are reused, so only new or edited files are
+// embedded; the updated index is written back to cachePath.
+func Build(ctx context.Context, dir string, embedder Embedder, cachePath string) (*Index, error) {
+	cached := loadCache(cachePath, embedder.Name())
+
+	files, err := contextpack.ListFiles(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	idx := &Index{Embedder: embedder.Name(), Files: make(map[string]fileEntry), embedder: embedder}
+	var pending []Chunk
+	total := 0
+	for _, path := range files {
+		if total >= maxChunks {
+			break
+		}
+		data, err := readText(filepath.Join(dir, filepath.FromSlash(path)))
+		if err != nil || len(data) == 0 {
+			continue
+		}
+		sum := sha256.Sum256(data)
+		hash := hex.EncodeToString(sum[:])
+
+		if entry, ok := cached.Files[path]; ok && entry.Hash == hash {
+			idx.Files[path] = entry
+			total += len(entry.Chunks)
+			continue
+		}
+		chunks := split(path, string(data))
+		idx.Files[path] = fileEntry{Hash: hash}
+		pending = append(pending, chunks...)
+		total += len(chunks)
+	}
+
+	if len(pending) > 0 {
+		texts := make([]string, len(pending))
+		for i, c := range pending {
+			texts[i] = c.Path + "\n" + c.Text
+		}
+		vectors, err := embedder.Embed(ctx, texts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to embed files: %w", err)
+		}
+		for i := range pending {
+			pending[i].Vector = vectors[i]
+			entry := idx.Files[pending[i].Path]
+			entry.Chunks = append(entry.Chunks, pending[i])
+			idx.Files[pending[i].Path] = entry
+		}
+	}
+
+	if cachePath != "" {
+		if err := idx.save(cachePath); err != nil {
+			return nil, err
+		}
+	}
+	return idx, nil
+}
+
+// Search returns up to k chunks most similar to query, best first.
+func (idx *Index) Search(ctx context.Context, query string, k int) ([]Chunk, error) {
+	vectors, err := idx.embedder.Embed(ctx, []string{query})
+	if err != nil {
+		return nil, fmt.Errorf("failed to embed query: %w", err)
+	}
+	q := vectors[0]
+
+	type scored struct {
+		chunk Chunk
+		score float64
+	}
+	var results []scored
+	for _, entry := range idx.Files {
+		for _, c := range entry.Chunks {
+			if score := cosine(q, c.Vector); score > 0 {
+				results = append(results, scored{c, score})
+			}
+		}
+	}
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].score != results[j].score {
+			return results[i].score > results[j].score
+		}
+		if results[i].chunk.Path != results[j].chunk.Path {
+			return results[i].chunk.Path < results[j].chunk.Path
+		}
+		return results[i].chunk.Start < results[j].chunk.Start
+	})
+
+	if len(results) > k {
+		results = results[:k]
+	}
+	chunks := make([]Chunk, len(results))
+	for i, r := range results {
+		chunks[i] = r.chunk
+	}
+	return chunks, nil
+}
+
+// Format renders chunks as prompt snippets, stopping before maxTokens
+// (estimated with tokens.Count) is exceeded. The first snippet is
+// shortened to fit if needed.
+func Format(chunks []Chunk, maxTokens int) string {
+	if len(chunks) == 0 {
+		return "(No relevant snippets found.)"
+	}
+	if maxTokens <= 0 {
+		maxTokens = DefaultMaxTokens
+	}
+
+	var b strings.Builder
+	used := 0
+	for i, c := range chunks {
+		snippet := fmt.Sprintf("%s:%d-%d\n```\n%s\n```\n", c.Path, c.Start, c.End, strings.TrimRight(c.Text, "\n"))
+		n := tokens.Count(snippet)
+		if used+n > maxTokens {
+			if i > 0 {
+				break
+			}
+			snippet = cutToTokens(snippet, maxTokens-tokens.Count(cutMarker)) + cutMarker
+		}
+		used += n
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		b.WriteString(snippet)
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// cutMarker ends a snippet shortened to fit the budget.
+const cutMarker = "\n…\n```\n"
+
+// cutToTokens returns the longest start of s that's at most n tokens.
+func cutToTokens(s string, n int) string {
+	cut := sort.Search(len(s)+1, func(i int) bool {
+		return i == len(s) || tokens.Count(s[:i+1]) > n
+	})
+	for cut > 0 && cut < len(s) && !utf8.RuneStart(s[cut]) {
+		cut--
+	}
+	return s[:cut]
+}
+
+// split cuts a file into chunks of chunkLines lines.
+func split(path, text string) []Chunk {
+	lines := strings.Split(strings.TrimRight(text, "\n"), "\n")
+	var chunks []Chunk
+	for start := 0; start < len(lines); start += chunkLines {
+		end := min(start+chunkLines, len(lines))
+		body := strings.Join(lines[start:end], "\n")
+		if strings.TrimSpace(body) == "" {
+			continue
+		}
+		chunks = append(chunks, Chunk{Path: path, Start: start + 1, End: end, Text: body})
+	}
+	return chunks
+}
+
+// readText returns a file's contents, failing if it is large (see
+// textfile.ErrTooLarge), binary (textfile.ErrBinary), or unreadable.
+func readText(path string) ([]byte, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !info.Mode().IsRegular() {
+		return nil, fmt.Errorf("%s isn't a regular file", path)
+	}
+	return textfile.Read(path, maxFileSize)
+}
+
+// cosine returns the cosine similarity of a and b (0 if their sizes differ).
+func cosine(a, b []float32) float64 {
+	if len(a) != len(b) || len(a) == 0 {
+		return 0
+	}
+	var dot, na, nb float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		na += float64(a[i]) * float64(a[i])
+		nb += float64(b[i]) * float64(b[i])
+	}
+	if na == 0 || nb == 0 {
+		return 0
+	}
+	return dot / math.Sqrt(na*nb)
+}
+
+// loadCache reads a cached index built by the named embedder.
+// A missing, unreadable, or mismatched cache yields an empty index.
+func loadCache(path, embedder string) *Index {
+	idx := &Index{Files: make(map[string]fileEntry)}
+	if path == "" {
+		return idx
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return idx
+	}
+	var cached Index
+	if json.Unmarshal(data, &cached) != nil || cached.Embedder != embedder || cached.Files == nil {
+		return idx
+	}
+	return &cached
+}
+
+// save writes the index to path.
+func (idx *Index) save(path string) error {
+	data, err := json.Marshal(idx)
+	if err != nil {
+		return fmt.Errorf("failed to marshal index: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write index: %w", err)
+	}
+	return nil
+}
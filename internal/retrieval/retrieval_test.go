@@ -0,0 +1,154 @@
+package retrieval
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/adityaraj/agentflow/internal/textfile"
+	"github.com/adityaraj/agentflow/internal/tokens"
+)
+
+// countingEmbedder wraps the local embedder and counts embedded texts.
+type countingEmbedder struct {
+	localEmbedder
+	texts int
+}
+
+func (e *countingEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	e.texts += len(texts)
+	return e.localEmbedder.Embed(ctx, texts)
+}
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestBuildAndSearch(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"auth/token.go":   "package auth\n\n// RefreshToken renews an expired session token.\nfunc RefreshToken(session string) string { return session }\n",
+		"billing/bill.go": "package billing\n\n// ChargeInvoice bills the customer for an invoice.\nfunc ChargeInvoice(id int) error { return nil }\n",
+		"logo.png":        "\x89PNG\x00\x00binary",
+	})
+	cache := filepath.Join(t.TempDir(), IndexFile)
+
+	embedder := &countingEmbedder{}
+	idx, err := Build(context.Background(), dir, embedder, cache)
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+	if embedder.texts != 2 {
+		t.Errorf("embedded %d chunks, want 2 (binary files skipped)", embedder.texts)
+	}
+
+	chunks, err := idx.Search(context.Background(), "refresh the session token", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(chunks) != 1 || chunks[0].Path != "auth/token.go" || chunks[0].Start != 1 {
+		t.Errorf("Search() = %+v, want auth/token.go first", chunks)
+	}
+
+	// Unchanged files are reused from the cache; edited ones are re-embedded
+	writeFiles(t, dir, map[string]string{"billing/bill.go": "package billing\n\nfunc Refund() {}\n"})
+	embedder.texts = 0
+	if _, err := Build(context.Background(), dir, embedder, cache); err != nil {
+		t.Fatal(err)
+	}
+	if embedder.texts != 1 {
+		t.Errorf("rebuild embedded %d chunks, want only the edited file", embedder.texts)
+	}
+}
+
+func TestFormat_Budget(t *testing.T) {
+	chunks := []Chunk{
+		{Path: "a.go", Start: 1, End: 2, Text: strings.Repeat("a", 30)},
+		{Path: "b.go", Start: 5, End: 9, Text: strings.Repeat("b", 30)},
+	}
+	if got := Format(chunks, 100); !strings.Contains(got, "a.go:1-2") || !strings.Contains(got, "b.go:5-9") {
+		t.Errorf("Format() = %q, want both snippets", got)
+	}
+	got := Format(chunks, 20)
+	if !strings.Contains(got, "a.go:1-2") || strings.Contains(got, "b.go") {
+		t.Errorf("Format() = %q, want only the first snippet within budget", got)
+	}
+	if n := tokens.Count(got); n > 20 {
+		t.Errorf("Format() is %d tokens, want at most 20", n)
+	}
+	if got := Format(nil, 100); got != "(No relevant snippets found.)" {
+		t.Errorf("Format(nil) = %q", got)
+	}
+}
+
+func TestSplitIdentifier(t *testing.T) {
+	got := strings.Join(splitIdentifier("parseHTTPRequest_body"), " ")
+	if got != "parse HTTP Request body" {
+		t.Errorf("splitIdentifier() = %q", got)
+	}
+}
+
+func TestFiles(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		".gitignore":         "gen/\n",
+		"auth/token.go":      "package auth\n\n// RefreshToken renews an expired session token.\nfunc RefreshToken(session string) string { return session }\n",
+		"billing/bill.go":    "package billing\n\n// ChargeInvoice bills the customer for an invoice.\nfunc ChargeInvoice(id int) error { return nil }\n",
+		"billing/README.md":  "# Billing\n",
+		"vendor/lib/lib.go":  "package lib\n",
+		"gen/session.go":     "package gen\n",
+		"auth/token_test.go": "package auth\n",
+		"auth/blob.go":       "package auth\x00\x01",
+	})
+	ignored := "gen/session.go"
+	if err := exec.Command("git", "-C", dir, "init", "-q").Run(); err != nil {
+		ignored = "" // .gitignore is only read in a git repository
+	}
+	patterns := []string{"**/*.go", "!vendor/**", "!**/*_test.go"}
+
+	got, skipped, err := Files(dir, patterns, "Refresh the session token", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(skipped) != 1 || !errors.Is(skipped[0], textfile.ErrBinary) || !strings.HasPrefix(skipped[0].Error(), "auth/blob.go: ") {
+		t.Errorf("Files() skipped %v, want auth/blob.go as binary", skipped)
+	}
+	token, bill := strings.Index(got, "auth/token.go\n```go\n"), strings.Index(got, "billing/bill.go\n```go\n")
+	if token < 0 || bill < 0 || token > bill {
+		t.Errorf("Files() = %q, want auth/token.go then billing/bill.go", got)
+	}
+	for _, name := range []string{"vendor/lib", ignored, "token_test.go", "README.md", "blob.go"} {
+		if name != "" && strings.Contains(got, name) {
+			t.Errorf("Files() includes %s", name)
+		}
+	}
+
+	if got, _, _ := Files(dir, patterns, "Charge the invoice in bill.go", 0); !strings.HasPrefix(got, "billing/bill.go\n") {
+		t.Errorf("Files() = %q, want billing/bill.go first", got)
+	}
+
+	got, _, err = Files(dir, []string{"auth/token.go", "billing/*.go"}, "Refresh the session token", 55)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(got, "auth/token.go\n") || !strings.Contains(got, "left out for space:\n- billing/bill.go") {
+		t.Errorf("Files() over budget = %q, want bill.go listed", got)
+	}
+
+	if got, _, _ := Files(dir, []string{"**/*.rs"}, "", 0); !strings.HasPrefix(got, "(No files match") {
+		t.Errorf("Files() with no matches = %q", got)
+	}
+}
@@ -10,8 +10,10 @@ import (
 	"io"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"strings"
 
+	"github.com/adityaraj/agentflow/internal/proc"
 	"github.com/adityaraj/agentflow/internal/runtime"
 	"github.com/adityaraj/agentflow/internal/ui"
 )
@@ -77,70 +79,66 @@ func (a *Adapter) SetWorkdir(dir string) {
 }
 
 // Run executes a task using the claude-code CLI.
+// Output is always read as stream-json so tool calls, file edits, and token
+// usage are reported while the task runs; streamLogs only controls whether
+// the agent's reply is echoed to the terminal.
 func (a *Adapter) Run(ctx context.Context, task runtime.Task) (runtime.Result, error) {
 	args := a.buildArgs(task)
-	cmd := exec.CommandContext(ctx, a.executable, args...)
-
-	// Streaming mode: use stream-json format and parse NDJSON in real-time
-	if a.streamLogs {
-		stdout, err := cmd.StdoutPipe()
-		if err != nil {
-			return runtime.Result{}, fmt.Errorf("failed to create stdout pipe: %w", err)
+	// On Windows, npm installs claude as a .cmd launcher whose arguments pass
+	// through cmd.exe, which cuts them at newlines: send the prompt on stdin
+	var stdin io.Reader
+	if path, err := exec.LookPath(a.executable); err == nil && proc.IsBatchScript(path) {
+		stdin = strings.NewReader(args[len(args)-1])
+		args = args[:len(args)-1]
+		for i, arg := range args {
+			args[i] = strings.ReplaceAll(arg, "\n", " ")
 		}
+	}
+	cmd := proc.Command(ctx, a.executable, args...)
+	cmd.Stdin = stdin
+	cmd.Env = task.Environ()
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return runtime.Result{}, fmt.Errorf("failed to create stdout pipe: %w", err)
+	}
 
-		var stderr bytes.Buffer
-		cmd.Stderr = &stderr
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
 
-		if err := cmd.Start(); err != nil {
-			return runtime.Result{}, fmt.Errorf("failed to start claude: %w", err)
-		}
+	if err := cmd.Start(); err != nil {
+		return runtime.Result{}, fmt.Errorf("failed to start claude: %w", err)
+	}
+	monitor := proc.Watch(cmd)
 
+	var out io.Writer = io.Discard
+	if a.streamLogs {
 		ui.PrintStreamStart()
+		out = os.Stdout
+	}
+	term := task.Mask(out)
 
-		// Parse NDJSON and stream text content in real-time
-		parsed := a.parseAndStreamNDJSON(stdout, os.Stdout)
+	// Parse NDJSON, streaming text content and reporting events in real-time
+	parsed := a.parseAndStreamNDJSON(stdout, task.TeeProgress(term), task)
+	term.Close()
 
+	if a.streamLogs {
 		ui.PrintStreamEnd()
-
-		err = cmd.Wait()
-
-		result := runtime.Result{
-			Stdout:       ui.StripMarkdown(parsed.Output),
-			Stderr:       stderr.String(),
-			ExitCode:     0,
-			Success:      true,
-			InputTokens:  parsed.InputTokens,
-			OutputTokens: parsed.OutputTokens,
-			CacheRead:    parsed.CacheRead,
-			CacheWrite:   parsed.CacheWrite,
-		}
-
-		if err != nil {
-			if exitErr, ok := err.(*exec.ExitError); ok {
-				result.ExitCode = exitErr.ExitCode()
-				result.Success = false
-			} else {
-				return result, fmt.Errorf("claude execution failed: %w", err)
-			}
-		}
-
-		return result, nil
 	}
 
-	// Non-streaming mode: use buffered text output
-	var stdout, stderr bytes.Buffer
-	cmd.Stdout = &stdout
-	cmd.Stderr = &stderr
-
-	err := cmd.Run()
-
-	cleanStdout := ui.StripMarkdown(stdout.String())
+	err = cmd.Wait()
 
 	result := runtime.Result{
-		Stdout:   cleanStdout,
-		Stderr:   stderr.String(),
-		ExitCode: 0,
-		Success:  true,
+		Stdout:       ui.StripMarkdown(parsed.Output),
+		Stderr:       stderr.String(),
+		ExitCode:     0,
+		Success:      true,
+		InputTokens:  parsed.InputTokens,
+		OutputTokens: parsed.OutputTokens,
+		CacheRead:    parsed.CacheRead,
+		CacheWrite:   parsed.CacheWrite,
+		CostUSD:      parsed.CostUSD,
+		Resources:    monitor.Usage(cmd.ProcessState),
 	}
 
 	if err != nil {
@@ -148,7 +146,7 @@ func (a *Adapter) Run(ctx context.Context, task runtime.Task) (runtime.Result, e
 			result.ExitCode = exitErr.ExitCode()
 			result.Success = false
 		} else {
-			return result, fmt.Errorf("failed to execute claude: %w", err)
+			return result, fmt.Errorf("claude execution failed: %w", err)
 		}
 	}
 
@@ -161,20 +159,19 @@ func (a *Adapter) buildArgs(task runtime.Task) []string {
 		"-p", // SDK/headless mode
 	}
 
-	// Use stream-json for real-time streaming, text for buffered output
+	// Use stream-json so tool calls and usage can be parsed as they happen
 	// Note: stream-json requires --verbose flag
 	// --include-partial-messages enables real-time character-by-character streaming
-	if a.streamLogs {
-		args = append(args, "--output-format", "stream-json", "--verbose", "--include-partial-messages")
-	} else {
-		args = append(args, "--output-format", "text")
-	}
+	args = append(args, "--output-format", "stream-json", "--verbose", "--include-partial-messages")
 
 	// Add system prompt (use default if not overridden)
 	systemPrompt := a.systemPrompt
 	if systemPrompt == "" {
 		systemPrompt = defaultSystemPrompt
 	}
+	if task.SystemPrompt != "" {
+		systemPrompt += "\n\n" + task.SystemPrompt
+	}
 	args = append(args, "--system-prompt", systemPrompt)
 
 	// Add working directory if specified (from task or adapter)
@@ -207,6 +204,8 @@ type streamMessage struct {
 	Type    string `json:"type"`
 	Subtype string `json:"subtype"`
 	Result  string `json:"result"`
+	// Total cost of the session, on the result message
+	TotalCostUSD float64 `json:"total_cost_usd"`
 	// For stream_event messages (real-time streaming with --include-partial-messages)
 	Event *struct {
 		Type  string `json:"type"`
@@ -223,12 +222,22 @@ type streamMessage struct {
 			Text        string `json:"text"`
 			PartialJSON string `json:"partial_json"`
 		} `json:"delta"`
+		// For message_start (initial usage of each API call)
+		Message *struct {
+			Usage *usageInfo `json:"usage"`
+		} `json:"message"`
+		// For message_delta (cumulative output tokens of the current call)
+		Usage *usageInfo `json:"usage"`
 	} `json:"event"`
-	// For assistant messages (final complete message)
+	// For assistant messages (final complete message) and user messages
+	// (tool results fed back to the model)
 	Message *struct {
 		Content []struct {
-			Type string `json:"type"`
-			Text string `json:"text"`
+			Type      string          `json:"type"`
+			Text      string          `json:"text"`
+			ToolUseID string          `json:"tool_use_id"`
+			Content   json.RawMessage `json:"content"` // tool_result: string or content blocks
+			IsError   bool            `json:"is_error"`
 		} `json:"content"`
 		Usage *usageInfo `json:"usage"`
 	} `json:"message"`
@@ -247,6 +256,7 @@ type usageInfo struct {
 // toolInput represents common tool input parameters
 type toolInput struct {
 	FilePath    string `json:"file_path"`
+	NotebookPath string `json:"notebook_path"`
 	Path        string `json:"path"`
 	Pattern     string `json:"pattern"`
 	Command     string `json:"command"`
@@ -265,11 +275,13 @@ type parseResult struct {
 	OutputTokens int
 	CacheRead    int
 	CacheWrite   int
+	CostUSD      float64 // As reported by claude, 0 if not
 }
 
 // parseAndStreamNDJSON reads NDJSON from reader, streams text content to writer,
 // and returns the full accumulated output with token usage.
-func (a *Adapter) parseAndStreamNDJSON(r io.Reader, w io.Writer) parseResult {
+// Usage increments and tool-call events are reported to task as they stream in.
+func (a *Adapter) parseAndStreamNDJSON(r io.Reader, w io.Writer, task runtime.Task) parseResult {
 	scanner := bufio.NewScanner(r)
 	// Increase scanner buffer for large JSON lines
 	buf := make([]byte, 0, 64*1024)
@@ -277,9 +289,11 @@ func (a *Adapter) parseAndStreamNDJSON(r io.Reader, w io.Writer) parseResult {
 
 	var result parseResult
 	var fullOutput strings.Builder
-	var currentTool string
+	var currentTool, currentToolID string
 	var toolInputJSON strings.Builder
 	var toolDisplayed bool
+	var callOutputTokens int   // Output tokens reported so far for the current API call
+	var finalUsage *usageInfo  // Session totals from the result message, if any
 
 	for scanner.Scan() {
 		line := scanner.Text()
@@ -296,11 +310,11 @@ func (a *Adapter) parseAndStreamNDJSON(r io.Reader, w io.Writer) parseResult {
 		}
 
 		// Capture usage info from result or message
-		if msg.Usage != nil {
-			result.InputTokens += msg.Usage.InputTokens
-			result.OutputTokens += msg.Usage.OutputTokens
-			result.CacheRead += msg.Usage.CacheReadTokens
-			result.CacheWrite += msg.Usage.CacheCreationTokens
+		if msg.Type == "result" && msg.Usage != nil {
+			finalUsage = msg.Usage
+		}
+		if msg.Type == "result" {
+			result.CostUSD = msg.TotalCostUSD
 		}
 		if msg.Message != nil && msg.Message.Usage != nil {
 			result.InputTokens += msg.Message.Usage.InputTokens
@@ -309,12 +323,33 @@ func (a *Adapter) parseAndStreamNDJSON(r io.Reader, w io.Writer) parseResult {
 			result.CacheWrite += msg.Message.Usage.CacheCreationTokens
 		}
 
+		// Report usage as it streams in so budgets can act mid-task
+		if msg.Type == "stream_event" && msg.Event != nil {
+			if msg.Event.Type == "message_start" && msg.Event.Message != nil && msg.Event.Message.Usage != nil {
+				u := msg.Event.Message.Usage
+				callOutputTokens = u.OutputTokens
+				task.ReportUsage(runtime.Usage{
+					InputTokens:  u.InputTokens,
+					OutputTokens: u.OutputTokens,
+					CacheRead:    u.CacheReadTokens,
+					CacheWrite:   u.CacheCreationTokens,
+				})
+			}
+			if msg.Event.Type == "message_delta" && msg.Event.Usage != nil {
+				if delta := msg.Event.Usage.OutputTokens - callOutputTokens; delta > 0 {
+					callOutputTokens = msg.Event.Usage.OutputTokens
+					task.ReportUsage(runtime.Usage{OutputTokens: delta})
+				}
+			}
+		}
+
 		// Handle stream_event messages
 		if msg.Type == "stream_event" && msg.Event != nil {
 			// Tool use started
 			if msg.Event.Type == "content_block_start" && msg.Event.ContentBlock != nil {
 				if msg.Event.ContentBlock.Type == "tool_use" {
 					currentTool = msg.Event.ContentBlock.Name
+					currentToolID = msg.Event.ContentBlock.ID
 					toolInputJSON.Reset()
 					toolDisplayed = false
 				}
@@ -346,6 +381,7 @@ func (a *Adapter) parseAndStreamNDJSON(r io.Reader, w io.Writer) parseResult {
 				if msg.Event.Delta.Type == "text_delta" && msg.Event.Delta.Text != "" {
 					_, _ = w.Write([]byte(msg.Event.Delta.Text))
 					fullOutput.WriteString(msg.Event.Delta.Text)
+					task.ReportEvent(runtime.AgentEvent{Type: runtime.AgentEventText, Text: msg.Event.Delta.Text})
 				}
 			}
 
@@ -356,25 +392,118 @@ func (a *Adapter) parseAndStreamNDJSON(r io.Reader, w io.Writer) parseResult {
 					toolMsg := fmt.Sprintf("\n%s  ⚡ %s%s %s%s%s\n", ui.Orange, currentTool, ui.Reset, ui.Dim, info, ui.Reset)
 					_, _ = w.Write([]byte(toolMsg))
 				}
+				for _, ev := range toolEvents(currentTool, currentToolID, toolInputJSON.String()) {
+					task.ReportEvent(ev)
+				}
 				currentTool = ""
+				currentToolID = ""
 				toolDisplayed = false
 			}
 		}
 
+		// Tool results come back in user messages
+		if msg.Type == "user" && msg.Message != nil {
+			for _, block := range msg.Message.Content {
+				if block.Type == "tool_result" {
+					task.ReportEvent(runtime.AgentEvent{
+						Type:    runtime.AgentEventToolResult,
+						ID:      block.ToolUseID,
+						Text:    toolResultText(block.Content),
+						IsError: block.IsError,
+					})
+				}
+			}
+		}
+
 		// Handle final result (fallback if no streaming events received)
 		if msg.Type == "result" && msg.Result != "" {
 			// Only use result if we haven't accumulated content from stream events
 			if fullOutput.Len() == 0 {
 				_, _ = w.Write([]byte(msg.Result))
 				fullOutput.WriteString(msg.Result)
+				task.ReportEvent(runtime.AgentEvent{Type: runtime.AgentEventText, Text: msg.Result})
 			}
 		}
 	}
 
+	// The result message carries session totals; per-message usage repeats
+	// across the content blocks of a message, so it is only a fallback.
+	if finalUsage != nil {
+		result.InputTokens = finalUsage.InputTokens
+		result.OutputTokens = finalUsage.OutputTokens
+		result.CacheRead = finalUsage.CacheReadTokens
+		result.CacheWrite = finalUsage.CacheCreationTokens
+	}
+
 	result.Output = fullOutput.String()
 	return result
 }
 
+// fileEditTools are the claude-code tools that change files.
+var fileEditTools = map[string]bool{
+	"Edit":         true,
+	"MultiEdit":    true,
+	"Write":        true,
+	"NotebookEdit": true,
+}
+
+// toolEvents converts a completed tool call into agent events: a tool_use
+// event, plus a file_edit event for tools that change files.
+func toolEvents(tool, id, inputJSON string) []runtime.AgentEvent {
+	var input toolInput
+	_ = json.Unmarshal([]byte(inputJSON), &input)
+
+	path := input.FilePath
+	if path == "" {
+		path = input.NotebookPath
+	}
+	if path == "" {
+		path = input.Path
+	}
+
+	events := []runtime.AgentEvent{{
+		Type:    runtime.AgentEventToolUse,
+		Tool:    tool,
+		ID:      id,
+		Summary: extractToolInfo(tool, inputJSON),
+		Path:    path,
+		Input:   inputJSON,
+	}}
+	if fileEditTools[tool] && path != "" {
+		events = append(events, runtime.AgentEvent{
+			Type:  runtime.AgentEventFileEdit,
+			Tool:  tool,
+			ID:    id,
+			Path:  path,
+			Input: inputJSON,
+		})
+	}
+	return events
+}
+
+// toolResultText extracts the text of a tool_result, whose content is either
+// a string or a list of content blocks.
+func toolResultText(raw json.RawMessage) string {
+	var text string
+	if err := json.Unmarshal(raw, &text); err == nil {
+		return text
+	}
+	var blocks []struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	}
+	if err := json.Unmarshal(raw, &blocks); err != nil {
+		return ""
+	}
+	var parts []string
+	for _, b := range blocks {
+		if b.Type == "text" {
+			parts = append(parts, b.Text)
+		}
+	}
+	return strings.Join(parts, "\n")
+}
+
 // extractToolInfo extracts display info from tool input JSON
 func extractToolInfo(toolName, jsonStr string) string {
 	var input toolInput
@@ -388,7 +517,7 @@ func extractToolInfo(toolName, jsonStr string) string {
 		if input.FilePath != "" {
 			return shortenPath(input.FilePath)
 		}
-	case "Edit":
+	case "Edit", "MultiEdit":
 		if input.FilePath != "" {
 			return shortenPath(input.FilePath)
 		}
@@ -470,7 +599,7 @@ func shortenPath(path string) string {
 	}
 	// If still too long, show just the filename
 	if len(path) > 60 {
-		parts := strings.Split(path, "/")
+		parts := strings.Split(filepath.ToSlash(path), "/")
 		if len(parts) > 2 {
 			path = ".../" + parts[len(parts)-2] + "/" + parts[len(parts)-1]
 		}
@@ -486,3 +615,13 @@ func (a *Adapter) Check() error {
 	}
 	return nil
 }
+
+// Version returns the version the claude CLI reports.
+func (a *Adapter) Version() (string, error) {
+	out, err := exec.Command(a.executable, "--version").Output()
+	if err != nil {
+		return "", fmt.Errorf("claude CLI not found or not executable: %w", err)
+	}
+	version, _, _ := strings.Cut(strings.TrimSpace(string(out)), "\n")
+	return version, nil
+}
@@ -0,0 +1,83 @@
+package claude
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/adityaraj/agentflow/internal/runtime"
+)
+
+// streamFixture is a trimmed claude-code stream-json session: one Edit call
+// and its result, a text reply, and the final result.
+const streamFixture = `{"type":"stream_event","event":{"type":"message_start","message":{"usage":{"input_tokens":100,"output_tokens":1}}}}
+{"type":"stream_event","event":{"type":"content_block_start","index":0,"content_block":{"type":"tool_use","name":"Edit","id":"toolu_1"}}}
+{"type":"stream_event","event":{"type":"content_block_delta","index":0,"delta":{"type":"input_json_delta","partial_json":"{\"file_path\":\"main.go\","}}}
+{"type":"stream_event","event":{"type":"content_block_delta","index":0,"delta":{"type":"input_json_delta","partial_json":"\"old_string\":\"a\",\"new_string\":\"b\"}"}}}
+{"type":"stream_event","event":{"type":"content_block_stop","index":0}}
+{"type":"user","message":{"content":[{"type":"tool_result","tool_use_id":"toolu_1","content":[{"type":"text","text":"File updated"}]}]}}
+{"type":"stream_event","event":{"type":"content_block_delta","index":1,"delta":{"type":"text_delta","text":"Fixed it."}}}
+{"type":"stream_event","event":{"type":"message_delta","usage":{"output_tokens":40}}}
+{"type":"assistant","message":{"content":[{"type":"text","text":"Fixed it."}],"usage":{"input_tokens":100,"output_tokens":40}}}
+{"type":"assistant","message":{"content":[{"type":"text","text":"Fixed it."}],"usage":{"input_tokens":100,"output_tokens":40}}}
+{"type":"result","subtype":"success","result":"Fixed it.","total_cost_usd":0.0123,"usage":{"input_tokens":100,"output_tokens":40}}
+`
+
+func TestParseAndStreamNDJSON(t *testing.T) {
+	var events []runtime.AgentEvent
+	var usage runtime.Usage
+	task := runtime.Task{
+		OnEvent: func(ev runtime.AgentEvent) { events = append(events, ev) },
+		OnUsage: func(u runtime.Usage) {
+			usage.InputTokens += u.InputTokens
+			usage.OutputTokens += u.OutputTokens
+		},
+	}
+
+	parsed := New().parseAndStreamNDJSON(strings.NewReader(streamFixture), io.Discard, task)
+
+	if parsed.Output != "Fixed it." {
+		t.Errorf("Output = %q, want %q", parsed.Output, "Fixed it.")
+	}
+	if parsed.InputTokens != 100 || parsed.OutputTokens != 40 {
+		t.Errorf("final usage = %d in / %d out, want result totals 100 / 40", parsed.InputTokens, parsed.OutputTokens)
+	}
+	if parsed.CostUSD != 0.0123 {
+		t.Errorf("CostUSD = %v, want 0.0123", parsed.CostUSD)
+	}
+	if usage.InputTokens != 100 || usage.OutputTokens != 40 {
+		t.Errorf("streamed usage = %d in / %d out, want 100 / 40", usage.InputTokens, usage.OutputTokens)
+	}
+
+	var types []string
+	for _, ev := range events {
+		types = append(types, string(ev.Type))
+	}
+	if got := strings.Join(types, ","); got != "tool_use,file_edit,tool_result,text" {
+		t.Fatalf("event types = %s, want tool_use,file_edit,tool_result,text", got)
+	}
+	if ev := events[0]; ev.Tool != "Edit" || ev.ID != "toolu_1" || ev.Path != "main.go" || ev.Summary != "main.go" {
+		t.Errorf("tool_use event = %+v", ev)
+	}
+	if ev := events[1]; ev.Path != "main.go" {
+		t.Errorf("file_edit event = %+v", ev)
+	}
+	if ev := events[2]; ev.ID != "toolu_1" || ev.Text != "File updated" {
+		t.Errorf("tool_result event = %+v", ev)
+	}
+}
+
+// TestBuildArgsSystemPrompt tests that an agent's system prompt follows the
+// default output rules.
+func TestBuildArgsSystemPrompt(t *testing.T) {
+	args := New().buildArgs(runtime.Task{Prompt: "Review", SystemPrompt: "You are a security reviewer."})
+	for i, arg := range args {
+		if arg == "--system-prompt" {
+			if want := defaultSystemPrompt + "\n\nYou are a security reviewer."; args[i+1] != want {
+				t.Errorf("--system-prompt = %q, want %q", args[i+1], want)
+			}
+			return
+		}
+	}
+	t.Errorf("no --system-prompt in %q", args)
+}
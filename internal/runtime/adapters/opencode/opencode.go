@@ -8,7 +8,9 @@ import (
 	"io"
 	"os"
 	"os/exec"
+	"strings"
 
+	"github.com/adityaraj/agentflow/internal/proc"
 	"github.com/adityaraj/agentflow/internal/runtime"
 	"github.com/adityaraj/agentflow/internal/ui"
 )
@@ -54,7 +56,7 @@ func (a *Adapter) SetWorkdir(dir string) {
 func (a *Adapter) Run(ctx context.Context, task runtime.Task) (runtime.Result, error) {
 	args := a.buildArgs(task)
 
-	cmd := exec.CommandContext(ctx, a.executable, args...)
+	cmd := proc.Command(ctx, a.executable, args...)
 
 	// Set working directory if specified
 	workdir := task.Workdir
@@ -64,29 +66,33 @@ func (a *Adapter) Run(ctx context.Context, task runtime.Task) (runtime.Result, e
 	if workdir != "" {
 		cmd.Dir = workdir
 	}
+	cmd.Env = task.Environ()
 
 	var stdout, stderr bytes.Buffer
 	var stripper *ui.MarkdownStripWriter
+	stdoutTerm, stderrTerm := task.Mask(os.Stdout), task.Mask(os.Stderr)
 
 	if a.streamLogs {
 		// Print visual separator before streaming
 		ui.PrintStreamStart()
 		// Use MarkdownStripWriter to strip markdown in real-time as output streams
-		stripper = ui.NewMarkdownStripWriter(os.Stdout)
-		cmd.Stdout = io.MultiWriter(stripper, &stdout)
-		cmd.Stderr = io.MultiWriter(os.Stderr, &stderr)
+		stripper = ui.NewMarkdownStripWriter(stdoutTerm)
+		cmd.Stdout = task.TeeProgress(io.MultiWriter(stripper, &stdout))
+		cmd.Stderr = io.MultiWriter(stderrTerm, &stderr)
 	} else {
-		cmd.Stdout = &stdout
+		cmd.Stdout = task.TeeProgress(&stdout)
 		cmd.Stderr = &stderr
 	}
 
-	err := cmd.Run()
+	resources, err := proc.Run(cmd)
 
 	if a.streamLogs {
 		// Flush any remaining buffered content
 		if stripper != nil {
 			stripper.Flush()
 		}
+		stdoutTerm.Close()
+		stderrTerm.Close()
 		// Print visual separator after streaming
 		ui.PrintStreamEnd()
 	}
@@ -95,10 +101,11 @@ func (a *Adapter) Run(ctx context.Context, task runtime.Task) (runtime.Result, e
 	cleanStdout := ui.StripMarkdown(stdout.String())
 
 	result := runtime.Result{
-		Stdout:   cleanStdout,
-		Stderr:   stderr.String(),
-		ExitCode: 0,
-		Success:  true,
+		Stdout:    cleanStdout,
+		Stderr:    stderr.String(),
+		ExitCode:  0,
+		Success:   true,
+		Resources: resources,
 	}
 
 	if err != nil {
@@ -117,8 +124,13 @@ func (a *Adapter) Run(ctx context.Context, task runtime.Task) (runtime.Result, e
 // buildArgs constructs the command-line arguments for opencode.
 // Note: OpenCode CLI flags may vary - adjust as needed.
 func (a *Adapter) buildArgs(task runtime.Task) []string {
+	// OpenCode takes no system prompt, so the agent's leads the prompt
+	prompt := task.Prompt
+	if task.SystemPrompt != "" {
+		prompt = task.SystemPrompt + "\n\n" + prompt
+	}
 	args := []string{
-		"-p", task.Prompt, // Prompt flag (assumes similar to claude)
+		"-p", prompt, // Prompt flag (assumes similar to claude)
 	}
 
 	// Add model if specified
@@ -143,3 +155,13 @@ func (a *Adapter) Check() error {
 	}
 	return nil
 }
+
+// Version returns the version the opencode CLI reports.
+func (a *Adapter) Version() (string, error) {
+	out, err := exec.Command(a.executable, "--version").Output()
+	if err != nil {
+		return "", fmt.Errorf("opencode CLI not found or not executable: %w", err)
+	}
+	version, _, _ := strings.Cut(strings.TrimSpace(string(out)), "\n")
+	return version, nil
+}
@@ -11,13 +11,14 @@ import (
 	"os/exec"
 	"strings"
 
+	"github.com/adityaraj/agentflow/internal/proc"
 	"github.com/adityaraj/agentflow/internal/runtime"
 	"github.com/adityaraj/agentflow/internal/ui"
 )
 
 // Adapter implements the Agent interface for shell command execution.
 type Adapter struct {
-	// shell is the shell to use (default: /bin/sh)
+	// shell is the shell to use (default: proc.DefaultShell)
 	shell string
 	// streamLogs enables real-time output streaming
 	streamLogs bool
@@ -28,7 +29,7 @@ type Adapter struct {
 // New creates a new Shell adapter with default settings.
 func New() *Adapter {
 	return &Adapter{
-		shell:      "/bin/sh",
+		shell:      proc.DefaultShell(),
 		streamLogs: false,
 	}
 }
@@ -59,8 +60,17 @@ func (a *Adapter) Run(ctx context.Context, task runtime.Task) (runtime.Result, e
 		return runtime.Result{}, fmt.Errorf("no command specified for shell task")
 	}
 
-	// Build command with shell
-	cmd := exec.CommandContext(ctx, a.shell, "-c", command)
+	// Run argument lists directly; anything else with the shell
+	var cmd *exec.Cmd
+	if len(task.Args) > 0 {
+		cmd = proc.Command(ctx, task.Args[0], task.Args[1:]...)
+	} else {
+		shell := task.Shell
+		if shell == "" {
+			shell = a.shell
+		}
+		cmd = proc.ShellWith(ctx, shell, command)
+	}
 
 	// Set working directory
 	workdir := task.Workdir
@@ -70,18 +80,19 @@ func (a *Adapter) Run(ctx context.Context, task runtime.Task) (runtime.Result, e
 	if workdir != "" {
 		cmd.Dir = workdir
 	}
+	cmd.Env = task.Environ()
 
 	// Streaming mode: show output in real-time
 	if a.streamLogs {
-		return a.runStreaming(cmd, command)
+		return a.runStreaming(cmd, command, task)
 	}
 
 	// Non-streaming mode: capture output
-	return a.runBuffered(cmd)
+	return a.runBuffered(cmd, task)
 }
 
 // runStreaming executes the command with real-time output streaming.
-func (a *Adapter) runStreaming(cmd *exec.Cmd, command string) (runtime.Result, error) {
+func (a *Adapter) runStreaming(cmd *exec.Cmd, command string, task runtime.Task) (runtime.Result, error) {
 	stdout, err := cmd.StdoutPipe()
 	if err != nil {
 		return runtime.Result{}, fmt.Errorf("failed to create stdout pipe: %w", err)
@@ -95,10 +106,11 @@ func (a *Adapter) runStreaming(cmd *exec.Cmd, command string) (runtime.Result, e
 	if err := cmd.Start(); err != nil {
 		return runtime.Result{}, fmt.Errorf("failed to start command: %w", err)
 	}
+	monitor := proc.Watch(cmd)
 
 	// Print command being executed
 	ui.PrintStreamStart()
-	displayCmd := command
+	displayCmd := task.Secrets.Redact(command)
 	if len(displayCmd) > 80 {
 		displayCmd = displayCmd[:80] + "..."
 	}
@@ -107,30 +119,34 @@ func (a *Adapter) runStreaming(cmd *exec.Cmd, command string) (runtime.Result, e
 	// Stream stdout and stderr concurrently
 	var stdoutBuf, stderrBuf strings.Builder
 	done := make(chan struct{}, 2)
+	stdoutTerm, stderrTerm := task.Mask(os.Stdout), task.Mask(os.Stderr)
 
 	go func() {
-		a.streamOutput(stdout, os.Stdout, &stdoutBuf)
+		a.streamOutput(stdout, task.TeeProgress(stdoutTerm), &stdoutBuf)
 		done <- struct{}{}
 	}()
 
 	go func() {
-		a.streamOutput(stderr, os.Stderr, &stderrBuf)
+		a.streamOutput(stderr, task.TeeProgress(stderrTerm), &stderrBuf)
 		done <- struct{}{}
 	}()
 
 	// Wait for both streams to finish
 	<-done
 	<-done
+	stdoutTerm.Close()
+	stderrTerm.Close()
 
 	ui.PrintStreamEnd()
 
 	err = cmd.Wait()
 
 	result := runtime.Result{
-		Stdout:   stdoutBuf.String(),
-		Stderr:   stderrBuf.String(),
-		ExitCode: 0,
-		Success:  true,
+		Stdout:    stdoutBuf.String(),
+		Stderr:    stderrBuf.String(),
+		ExitCode:  0,
+		Success:   true,
+		Resources: monitor.Usage(cmd.ProcessState),
 	}
 
 	if err != nil {
@@ -146,18 +162,19 @@ func (a *Adapter) runStreaming(cmd *exec.Cmd, command string) (runtime.Result, e
 }
 
 // runBuffered executes the command and captures all output.
-func (a *Adapter) runBuffered(cmd *exec.Cmd) (runtime.Result, error) {
+func (a *Adapter) runBuffered(cmd *exec.Cmd, task runtime.Task) (runtime.Result, error) {
 	var stdout, stderr bytes.Buffer
-	cmd.Stdout = &stdout
-	cmd.Stderr = &stderr
+	cmd.Stdout = task.TeeProgress(&stdout)
+	cmd.Stderr = task.TeeProgress(&stderr)
 
-	err := cmd.Run()
+	resources, err := proc.Run(cmd)
 
 	result := runtime.Result{
-		Stdout:   stdout.String(),
-		Stderr:   stderr.String(),
-		ExitCode: 0,
-		Success:  true,
+		Stdout:    stdout.String(),
+		Stderr:    stderr.String(),
+		ExitCode:  0,
+		Success:   true,
+		Resources: resources,
 	}
 
 	if err != nil {
@@ -189,7 +206,7 @@ func (a *Adapter) streamOutput(r io.Reader, w io.Writer, buf *strings.Builder) {
 
 // Check verifies that the shell is available.
 func (a *Adapter) Check() error {
-	cmd := exec.Command(a.shell, "-c", "echo ok")
+	cmd := exec.Command(a.shell, proc.ShellFlag(a.shell), "echo ok")
 	if err := cmd.Run(); err != nil {
 		return fmt.Errorf("shell %s not available: %w", a.shell, err)
 	}
@@ -3,6 +3,12 @@ package runtime
 
 import (
 	"context"
+	"io"
+	"os"
+	"time"
+
+	"github.com/adityaraj/agentflow/internal/proc"
+	"github.com/adityaraj/agentflow/internal/secrets"
 )
 
 // Task represents a task to be executed by an agent.
@@ -14,18 +20,131 @@ type Task struct {
 	Prompt  string // Prompt text (already expanded with template variables)
 	Write   bool   // Allow file writes
 	Workdir string // Working directory for the agent (optional)
+
+	// SystemPrompt is the agent's system prompt, which adapters give the
+	// tool after their own ("" = none).
+	SystemPrompt string
+
+	// Args, for shell tasks given as an argument list, is the program and
+	// its arguments (already expanded), run directly instead of Prompt.
+	Args []string
+	// Shell runs Prompt for shell tasks ("" = the adapter's default).
+	Shell string
+
+	// Env holds variables ("NAME=value") the agent gets on top of
+	// Cortex's own environment, such as the run's secrets.
+	Env []string
+
+	// Secrets masks the run's secrets in what adapters show on the
+	// terminal (nil = none); see Mask.
+	Secrets *secrets.Redactor
+
+	// Progress receives a copy of the agent's output as it is produced,
+	// for heartbeats on long-running tasks and the task's output log
+	// (optional).
+	Progress io.Writer
+
+	// OnUsage receives token usage increments while the task runs, for tools
+	// that report usage incrementally (optional).
+	OnUsage func(Usage)
+
+	// OnEvent receives structured events (tool calls, file edits, message
+	// chunks) from tools that expose them (optional).
+	OnEvent func(AgentEvent)
+}
+
+// Usage is an increment of token usage reported mid-task.
+type Usage struct {
+	InputTokens  int
+	OutputTokens int
+	CacheRead    int
+	CacheWrite   int
+}
+
+// AgentEventType identifies what an agent event describes.
+type AgentEventType string
+
+// Agent event types.
+const (
+	AgentEventToolUse    AgentEventType = "tool_use"    // Agent called a tool (Bash, Read, Grep, ...)
+	AgentEventToolResult AgentEventType = "tool_result" // Result of a tool call, matched by ID
+	AgentEventFileEdit   AgentEventType = "file_edit"   // Agent changed a file
+	AgentEventText       AgentEventType = "text"        // Chunk of the agent's reply
+)
+
+// AgentEvent is a structured report of what an agent is doing.
+type AgentEvent struct {
+	Type    AgentEventType
+	Time    time.Time
+	Tool    string // Tool called (tool_use, file_edit)
+	ID      string // Tool call ID
+	Summary string // Short description, e.g. the command run or file read
+	Path    string // File affected, if any
+	Input   string // Raw JSON input of the tool call
+	Text    string // Message chunk (text) or tool output (tool_result)
+	IsError bool   // Tool call failed (tool_result)
+}
+
+// ReportEvent passes an event to the task's OnEvent callback, if any.
+func (t Task) ReportEvent(ev AgentEvent) {
+	if t.OnEvent != nil {
+		if ev.Time.IsZero() {
+			ev.Time = time.Now()
+		}
+		t.OnEvent(ev)
+	}
+}
+
+// ReportUsage passes a usage increment to the task's OnUsage callback, if any.
+func (t Task) ReportUsage(u Usage) {
+	if t.OnUsage != nil {
+		t.OnUsage(u)
+	}
+}
+
+// TeeProgress returns a writer that writes to w and to the task's progress
+// writer, if any. Adapters wrap their output destinations with it.
+func (t Task) TeeProgress(w io.Writer) io.Writer {
+	if t.Progress == nil {
+		return w
+	}
+	return io.MultiWriter(w, t.Progress)
+}
+
+// Mask returns a writer that writes to w with the task's secrets masked.
+// Adapters wrap the terminal with it, and close it once the agent exits to
+// write what it held back in case it was the start of a secret. (Progress
+// is masked already.)
+func (t Task) Mask(w io.Writer) io.WriteCloser {
+	return t.Secrets.Writer(w)
+}
+
+// Environ returns the environment for the agent's command: Cortex's own
+// with Env added, or nil, meaning Cortex's own, if Env is empty.
+func (t Task) Environ() []string {
+	if len(t.Env) == 0 {
+		return nil
+	}
+	return append(os.Environ(), t.Env...)
 }
 
 // Result represents the result of executing a task.
 type Result struct {
-	Stdout       string // Standard output from the agent
-	Stderr       string // Standard error from the agent
-	ExitCode     int    // Exit code (0 = success)
-	Success      bool   // Whether the task succeeded
-	InputTokens  int    // Input tokens used (for AI agents)
-	OutputTokens int    // Output tokens used (for AI agents)
-	CacheRead    int    // Cache read tokens (for AI agents)
-	CacheWrite   int    // Cache write tokens (for AI agents)
+	Stdout       string  // Standard output from the agent
+	Stderr       string  // Standard error from the agent
+	ExitCode     int     // Exit code (0 = success)
+	Success      bool    // Whether the task succeeded
+	InputTokens  int     // Input tokens used (for AI agents)
+	OutputTokens int     // Output tokens used (for AI agents)
+	CacheRead    int     // Cache read tokens (for AI agents)
+	CacheWrite   int     // Cache write tokens (for AI agents)
+	CostUSD      float64 // Cost in US dollars, if the tool reports it
+
+	// TokensEstimated is set when the tool didn't report its token usage
+	// and the counts above were estimated from the prompt and output.
+	TokensEstimated bool
+
+	Resources proc.Usage // CPU, memory, and processes used by the agent
 }
 
 // Agent is the interface that all agent adapters must implement.
@@ -35,6 +154,11 @@ type Agent interface {
 	Run(ctx context.Context, task Task) (Result, error)
 }
 
+// Versioned is implemented by adapters whose CLI reports its version.
+type Versioned interface {
+	Version() (string, error)
+}
+
 // AgentRegistry holds available agent adapters by tool name.
 type AgentRegistry struct {
 	adapters map[string]Agent
@@ -58,6 +182,22 @@ func (r *AgentRegistry) Get(tool string) Agent {
 	return r.adapters[tool]
 }
 
+// ToolVersions returns the CLI versions of the given tools, skipping tools
+// whose adapter doesn't report one or whose CLI can't be run.
+func (r *AgentRegistry) ToolVersions(tools []string) map[string]string {
+	versions := make(map[string]string)
+	for _, tool := range tools {
+		v, ok := r.adapters[tool].(Versioned)
+		if !ok {
+			continue
+		}
+		if version, err := v.Version(); err == nil && version != "" {
+			versions[tool] = version
+		}
+	}
+	return versions
+}
+
 // Has checks if an adapter is registered for the given tool.
 func (r *AgentRegistry) Has(tool string) bool {
 	_, ok := r.adapters[tool]
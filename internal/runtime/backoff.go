@@ -0,0 +1,154 @@
+package runtime
+
+import (
+	"context"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/adityaraj/agentflow/internal/observability"
+	"github.com/adityaraj/agentflow/internal/state"
+	"github.com/adityaraj/agentflow/internal/ui"
+)
+
+const (
+	// baseCooldown is the first pause after a rate limit; it doubles on each
+	// consecutive rate limit for the same tool and model.
+	baseCooldown = 15 * time.Second
+
+	// maxCooldown caps the shared pause.
+	maxCooldown = 5 * time.Minute
+
+	// maxRateLimitRetries is how many times a rate-limited task is re-run.
+	maxRateLimitRetries = 3
+)
+
+// retryAfterRegex extracts a server-suggested delay from tool output.
+var retryAfterRegex = regexp.MustCompile(`(?i)(?:retry[- ]after|try again in)[:\s]+(\d+)\s*(?:s\b|sec|seconds)?`)
+
+// backoffKey identifies a provider quota shared by tasks.
+type backoffKey struct {
+	tool  string
+	model string
+}
+
+// Backoff tracks rate-limit cooldowns shared across concurrently running tasks,
+// so one task hitting a limit pauses its siblings instead of letting them all fail.
+type Backoff struct {
+	mu      sync.Mutex
+	until   map[backoffKey]time.Time
+	strikes map[backoffKey]int
+	now     func() time.Time
+	after   func(time.Duration) <-chan time.Time
+}
+
+// useClock makes b tell time and wait with clock.
+func (b *Backoff) useClock(clock Clock) {
+	b.now, b.after = clock.Now, clock.After
+}
+
+// NewBackoff creates an empty Backoff.
+func NewBackoff() *Backoff {
+	return &Backoff{
+		until:   make(map[backoffKey]time.Time),
+		strikes: make(map[backoffKey]int),
+		now:     time.Now,
+		after:   time.After,
+	}
+}
+
+// Remaining returns how long tasks using tool/model must still wait.
+func (b *Backoff) Remaining(tool, model string) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if d := b.until[backoffKey{tool, model}].Sub(b.now()); d > 0 {
+		return d
+	}
+	return 0
+}
+
+// Wait blocks until any cooldown for tool/model has passed or ctx is done.
+func (b *Backoff) Wait(ctx context.Context, tool, model string) error {
+	for {
+		d := b.Remaining(tool, model)
+		if d <= 0 {
+			return nil
+		}
+		ui.PrintBackoff(tool, model, d)
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-b.after(d):
+			// Re-check: another task may have extended the cooldown
+		}
+	}
+}
+
+// Trip starts (or extends) the cooldown for tool/model after a rate limit.
+// A delay suggested by the provider in output takes precedence over the
+// exponential default. Returns the cooldown applied.
+func (b *Backoff) Trip(tool, model, output string) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	key := backoffKey{tool, model}
+	cooldown := baseCooldown << b.strikes[key]
+	if m := retryAfterRegex.FindStringSubmatch(output); m != nil {
+		if secs, err := strconv.Atoi(m[1]); err == nil && secs > 0 {
+			cooldown = time.Duration(secs) * time.Second
+		}
+	}
+	if cooldown > maxCooldown || cooldown <= 0 {
+		cooldown = maxCooldown
+	}
+	b.strikes[key]++
+
+	until := b.now().Add(cooldown)
+	if until.After(b.until[key]) {
+		b.until[key] = until
+	}
+
+	observability.Warn("Rate limited; pausing tasks for this tool/model",
+		observability.WithEvent(observability.EventRateLimited),
+		observability.WithData(observability.BackoffData{
+			Tool:     tool,
+			Model:    model,
+			Cooldown: cooldown.String(),
+			Until:    b.until[key],
+		}),
+	)
+	return cooldown
+}
+
+// Reset clears the strike count for tool/model after a successful call.
+func (b *Backoff) Reset(tool, model string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.strikes, backoffKey{tool, model})
+}
+
+// runAgent runs the agent once the shared cooldown for its tool/model has
+// passed, re-running it (after a cooldown) when it is rate limited.
+func (e *Executor) runAgent(ctx context.Context, agent Agent, task Task) (Result, error) {
+	var total Result
+	for attempt := 0; ; attempt++ {
+		if err := e.backoff.Wait(ctx, task.Tool, task.Model); err != nil {
+			return total, err
+		}
+
+		result, err := agent.Run(ctx, task)
+		result = addUsage(result, total)
+		if err == nil && result.Success {
+			e.backoff.Reset(task.Tool, task.Model)
+			return result, nil
+		}
+		if attempt >= maxRateLimitRetries || ClassifyError(ctx, task.Tool, result, err) != state.ErrorRateLimit {
+			return result, err
+		}
+
+		e.backoff.Trip(task.Tool, task.Model, result.Stderr+"\n"+result.Stdout)
+		total = result
+	}
+}
@@ -0,0 +1,37 @@
+package runtime
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBackoff_Trip(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	b := NewBackoff()
+	b.now = func() time.Time { return now }
+
+	if d := b.Trip("claude-code", "sonnet", "429 rate_limit_error"); d != baseCooldown {
+		t.Errorf("first Trip() = %s, want %s", d, baseCooldown)
+	}
+	if d := b.Trip("claude-code", "sonnet", "429 rate_limit_error"); d != 2*baseCooldown {
+		t.Errorf("second Trip() = %s, want %s", d, 2*baseCooldown)
+	}
+	if d := b.Remaining("claude-code", "sonnet"); d != 2*baseCooldown {
+		t.Errorf("Remaining() = %s, want %s", d, 2*baseCooldown)
+	}
+
+	// Other models have their own quota
+	if d := b.Remaining("claude-code", "opus"); d != 0 {
+		t.Errorf("Remaining(opus) = %s, want 0", d)
+	}
+
+	// Provider hints win over the exponential default
+	if d := b.Trip("opencode", "", "Too many requests, retry after 7 seconds"); d != 7*time.Second {
+		t.Errorf("Trip() with retry-after = %s, want 7s", d)
+	}
+
+	b.Reset("claude-code", "sonnet")
+	if d := b.Trip("claude-code", "sonnet", ""); d != baseCooldown {
+		t.Errorf("Trip() after Reset = %s, want %s", d, baseCooldown)
+	}
+}
@@ -0,0 +1,135 @@
+package runtime
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/adityaraj/agentflow/internal/config"
+	"github.com/adityaraj/agentflow/internal/observability"
+	"github.com/adityaraj/agentflow/internal/planner"
+	"github.com/adityaraj/agentflow/internal/tokens"
+	"github.com/adityaraj/agentflow/internal/ui"
+)
+
+// ErrBudgetExceeded is the cancellation cause of a task stopped for using
+// more tokens than its own or the run's budget allows.
+var ErrBudgetExceeded = errors.New("token budget exceeded")
+
+// Budget tracks the input and output tokens used by a run against an
+// optional limit. It is updated while tasks run, not only when they finish.
+type Budget struct {
+	mu    sync.Mutex
+	limit int // 0 = no limit
+	used  int
+}
+
+// NewBudget creates a Budget with the given token limit (0 = no limit).
+func NewBudget(limit int) *Budget {
+	return &Budget{limit: limit}
+}
+
+// Add records n tokens and reports whether the limit is now exceeded.
+// n may be negative to correct an earlier estimate.
+func (b *Budget) Add(n int) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.used += n
+	return b.limit > 0 && b.used > b.limit
+}
+
+// Exhausted reports whether no tokens are left for new tasks.
+func (b *Budget) Exhausted() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.limit > 0 && b.used >= b.limit
+}
+
+// Used returns the tokens recorded so far.
+func (b *Budget) Used() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.used
+}
+
+// Limit returns the token limit (0 = no limit).
+func (b *Budget) Limit() int {
+	return b.limit
+}
+
+// usageMeter counts a task's streamed token usage against the task's
+// max_tokens and the run budget, stopping the task once either is exceeded.
+type usageMeter struct {
+	budget   *Budget
+	task     string
+	limit    int // Task max_tokens (0 = no limit)
+	cancel   context.CancelCauseFunc
+	progress *progressWriter
+
+	mu      sync.Mutex
+	live    int  // Tokens reported mid-task so far
+	tripped bool // Budget exceeded and the task cancelled
+}
+
+// report records a usage increment from the agent.
+func (m *usageMeter) report(u Usage) {
+	n := u.InputTokens + u.OutputTokens
+	if n == 0 {
+		return
+	}
+	m.progress.addTokens(n)
+	runOver := m.budget.Add(n)
+
+	m.mu.Lock()
+	m.live += n
+	taskOver := m.limit > 0 && m.live > m.limit
+	trip := (runOver || taskOver) && !m.tripped
+	if trip {
+		m.tripped = true
+	}
+	used := m.live
+	m.mu.Unlock()
+
+	if !trip {
+		return
+	}
+	scope, limit := "task", m.limit
+	if !taskOver {
+		scope, limit, used = "run", m.budget.Limit(), m.budget.Used()
+	}
+	ui.PrintBudgetExceeded(scope, used, limit)
+	observability.Warn("Token budget exceeded; stopping task",
+		observability.WithTask(m.task),
+		observability.WithEvent(observability.EventBudgetExceeded),
+		observability.WithData(observability.BudgetData{Scope: scope, Limit: limit, Used: used}),
+	)
+	m.cancel(ErrBudgetExceeded)
+}
+
+// settle replaces the streamed estimate in the run budget with the task's
+// final reported usage, which is authoritative.
+func (m *usageMeter) settle(r Result) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.budget.Add(r.InputTokens + r.OutputTokens - m.live)
+	m.live = r.InputTokens + r.OutputTokens
+}
+
+// estimateUsage fills in the token usage of an AI task whose tool didn't
+// report any, counting its prompt and output with the model's tokenizer,
+// so budgets and usage totals still include it.
+func estimateUsage(execTask planner.ExecutionTask, prompt string, r Result) Result {
+	if execTask.Tool == "shell" || execTask.Tool == config.CompressTool || r.InputTokens > 0 || r.OutputTokens > 0 || !r.Success && r.Stdout == "" {
+		return r
+	}
+	tokenizer := tokens.ForModel(execTask.Model)
+	r.InputTokens = tokenizer.Count(prompt)
+	r.OutputTokens = tokenizer.Count(r.Stdout)
+	r.TokensEstimated = true
+	return r
+}
+
+// budgetExceeded reports whether ctx was cancelled by a usage meter.
+func budgetExceeded(ctx context.Context) bool {
+	return errors.Is(context.Cause(ctx), ErrBudgetExceeded)
+}
@@ -0,0 +1,87 @@
+package runtime
+
+import (
+	"context"
+	"testing"
+
+	"github.com/adityaraj/agentflow/internal/planner"
+)
+
+func TestUsageMeter(t *testing.T) {
+	tests := []struct {
+		name      string
+		runLimit  int
+		taskLimit int
+		usage     []Usage
+		wantStop  bool
+	}{
+		{"no limits", 0, 0, []Usage{{InputTokens: 5000, OutputTokens: 5000}}, false},
+		{"under task limit", 0, 1000, []Usage{{InputTokens: 400}, {OutputTokens: 500}}, false},
+		{"task limit crossed mid-stream", 0, 1000, []Usage{{InputTokens: 400}, {OutputTokens: 500}, {OutputTokens: 200}}, true},
+		{"run limit crossed", 800, 0, []Usage{{InputTokens: 500}, {OutputTokens: 400}}, true},
+		{"cache tokens not counted", 0, 100, []Usage{{InputTokens: 50, CacheRead: 10000}}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancelCause(context.Background())
+			defer cancel(nil)
+			m := &usageMeter{
+				budget:   NewBudget(tt.runLimit),
+				task:     "implement",
+				limit:    tt.taskLimit,
+				cancel:   cancel,
+				progress: &progressWriter{},
+			}
+			for _, u := range tt.usage {
+				m.report(u)
+			}
+
+			if budgetExceeded(ctx) != tt.wantStop {
+				t.Errorf("stopped = %v, want %v", budgetExceeded(ctx), tt.wantStop)
+			}
+		})
+	}
+}
+
+func TestUsageMeter_Settle(t *testing.T) {
+	budget := NewBudget(1000)
+	m := &usageMeter{budget: budget, cancel: func(error) {}, progress: &progressWriter{}}
+	m.report(Usage{InputTokens: 300, OutputTokens: 100})
+
+	// Final usage from the tool replaces the streamed estimate
+	m.settle(Result{InputTokens: 300, OutputTokens: 250})
+	if got := budget.Used(); got != 550 {
+		t.Errorf("Used() = %d, want 550", got)
+	}
+	if budget.Exhausted() {
+		t.Error("budget should not be exhausted")
+	}
+	budget.Add(450)
+	if !budget.Exhausted() {
+		t.Error("budget should be exhausted at its limit")
+	}
+}
+
+func TestEstimateUsage(t *testing.T) {
+	ai := planner.ExecutionTask{Tool: "opencode", Model: "openai/gpt-4o"}
+	r := estimateUsage(ai, "Review the code in ./src", Result{Stdout: "Looks good.", Success: true})
+	if !r.TokensEstimated || r.InputTokens == 0 || r.OutputTokens == 0 {
+		t.Errorf("estimateUsage() = %+v, want estimated usage", r)
+	}
+
+	// Reported usage, shell tasks, and agents that never ran are left alone
+	reported := Result{Stdout: "ok", Success: true, InputTokens: 10}
+	for _, tt := range []struct {
+		task planner.ExecutionTask
+		r    Result
+	}{
+		{ai, reported},
+		{planner.ExecutionTask{Tool: "shell"}, Result{Stdout: "ok", Success: true}},
+		{ai, Result{Stderr: "command not found", ExitCode: 127}},
+	} {
+		if got := estimateUsage(tt.task, "prompt", tt.r); got != tt.r {
+			t.Errorf("estimateUsage(%s, %+v) = %+v, want it unchanged", tt.task.Tool, tt.r, got)
+		}
+	}
+}
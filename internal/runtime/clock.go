@@ -0,0 +1,20 @@
+package runtime
+
+import "time"
+
+// Clock tells the executor the time and waits for it to pass. The executor
+// reads run start and end times and waits out rate-limit cooldowns through
+// its Clock, so tests can substitute a fake one that they move forward
+// themselves (see runtimetest.Clock).
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+// SystemClock is the real Clock.
+var SystemClock Clock = systemClock{}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time                         { return time.Now() }
+func (systemClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
@@ -0,0 +1,32 @@
+package runtime
+
+import (
+	"github.com/adityaraj/agentflow/internal/config"
+	"github.com/adityaraj/agentflow/internal/planner"
+)
+
+// shellFor returns the shell that runs task's command and verify.
+func (e *Executor) shellFor(task planner.ExecutionTask) string {
+	if task.Shell != "" {
+		return task.Shell
+	}
+	return e.shell
+}
+
+// expandArgs fills the placeholders in each argument of a shell task given
+// as an argument list. Each argument is expanded on its own, so a filled-in
+// value stays a single argument whatever it contains. Returns nil for tasks
+// without one. The caller must hold outputsMu.
+func (e *Executor) expandArgs(task planner.ExecutionTask) []string {
+	if task.Args == nil {
+		return nil
+	}
+	args := make([]string, len(task.Args))
+	for i, arg := range task.Args {
+		arg = config.ExpandVariables(config.ProtectEscapes(arg), e.variables)
+		arg = e.expandMeta(task, arg)
+		arg = config.ExpandDefaults(arg, e.skipped)
+		args[i] = config.RestoreEscapes(config.ExpandPrompt(arg, e.outputs))
+	}
+	return args
+}
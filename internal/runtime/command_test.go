@@ -0,0 +1,39 @@
+package runtime
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/adityaraj/agentflow/internal/planner"
+)
+
+func TestExpandArgs(t *testing.T) {
+	e := &Executor{
+		meta:    map[string]string{"git.branch": "main"},
+		outputs: map[string]string{"scan": "file.txt; rm -rf /"},
+		skipped: map[string]bool{"lint": true},
+	}
+
+	task := planner.ExecutionTask{
+		Name: "report",
+		Args: []string{"upload", "--branch={{git.branch}}", "{{outputs.scan}}", `{{outputs.lint | default "none"}}`, `{{"{{"}}x}}`},
+	}
+	want := []string{"upload", "--branch=main", "file.txt; rm -rf /", "none", "{{x}}"}
+	if got := e.expandArgs(task); !slices.Equal(got, want) {
+		t.Errorf("expandArgs() = %q, want %q", got, want)
+	}
+
+	if got := e.expandArgs(planner.ExecutionTask{Prompt: "echo hi"}); got != nil {
+		t.Errorf("expandArgs() = %q for a command string, want nil", got)
+	}
+}
+
+func TestShellFor(t *testing.T) {
+	e := &Executor{shell: "bash"}
+	if got := e.shellFor(planner.ExecutionTask{}); got != "bash" {
+		t.Errorf("shellFor() = %q, want the default bash", got)
+	}
+	if got := e.shellFor(planner.ExecutionTask{Shell: "pwsh"}); got != "pwsh" {
+		t.Errorf("shellFor() = %q, want the task's pwsh", got)
+	}
+}
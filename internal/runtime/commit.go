@@ -0,0 +1,149 @@
+package runtime
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/adityaraj/agentflow/internal/forge"
+	"github.com/adityaraj/agentflow/internal/git"
+	"github.com/adityaraj/agentflow/internal/glob"
+	"github.com/adityaraj/agentflow/internal/planner"
+	"github.com/adityaraj/agentflow/internal/state"
+	"github.com/adityaraj/agentflow/internal/ui"
+)
+
+const (
+	// runBranchPrefix names the branch that collects a run's commits.
+	runBranchPrefix = "cortex/run-"
+
+	// defaultCommitMessage is used when a task sets no message_template.
+	defaultCommitMessage = "cortex: {{task.name}}"
+
+	// commitRemote is the remote run branches are pushed to.
+	commitRemote = "origin"
+)
+
+// needsBaseline reports whether a task's changes must be tracked against a baseline.
+func needsBaseline(task planner.ExecutionTask) bool {
+	return hasChangeLimits(task) || (task.Write && task.Commit != nil)
+}
+
+// renderCommitMessage expands the placeholders supported in message_template.
+func renderCommitMessage(tmpl string, task planner.ExecutionTask, runID string) string {
+	if strings.TrimSpace(tmpl) == "" {
+		tmpl = defaultCommitMessage
+	}
+	return strings.TrimSpace(taskVars(task, runID).Replace(tmpl))
+}
+
+// commitChanges commits the files the task changed onto the run branch and,
+// if configured, pushes the branch and opens a pull request. The checkout
+// stays on its branch. A failed commit fails the task; push and PR
+// failures are recorded as warnings.
+func (e *Executor) commitChanges(ctx context.Context, task planner.ExecutionTask, baseline *git.Baseline, taskResult *state.TaskResult) error {
+	// Parallel tasks share the working tree, so diff and commit one at a time
+	e.gitMu.Lock()
+	defer e.gitMu.Unlock()
+
+	changes, err := baseline.Changes()
+	if err != nil {
+		return fmt.Errorf("failed to measure changes: %w", err)
+	}
+	paths := commitPaths(task, changes)
+	if len(paths) == 0 {
+		return nil
+	}
+
+	runID := e.store.RunID()
+	branch := runBranchPrefix + runID
+	message := renderCommitMessage(task.Commit.MessageTemplate, task, runID)
+	sha, err := git.CommitToBranch(baseline.Dir, branch, message, paths)
+	if err != nil {
+		return err
+	}
+
+	commit := &state.CommitResult{Branch: branch, SHA: sha}
+	taskResult.Commit = commit
+
+	if task.Commit.PR {
+		if err := e.openPullRequest(ctx, task, baseline.Dir, branch, message, taskResult); err != nil {
+			commit.Error = err.Error()
+			ui.Warning("Pull request for %q not created: %s", task.Name, err)
+		}
+	}
+
+	ui.PrintCommitStatus(branch, sha, commit.PullRequestURL)
+	return nil
+}
+
+// commitPaths returns the changed paths that are task's to commit: those
+// its writes globs match, so edits of write tasks running alongside it
+// aren't committed under its name. Without writes, every change is.
+func commitPaths(task planner.ExecutionTask, changes []git.FileChange) []string {
+	var paths []string
+	for _, c := range changes {
+		if len(task.Writes) == 0 || glob.MatchAny(task.Writes, []string{c.Path}) {
+			paths = append(paths, c.Path)
+		}
+	}
+	return paths
+}
+
+// openPullRequest pushes the run branch and opens a pull request for it.
+// Later tasks in the same run reuse the first pull request. Caller holds gitMu.
+func (e *Executor) openPullRequest(ctx context.Context, task planner.ExecutionTask, dir, branch, message string, taskResult *state.TaskResult) error {
+	if err := git.Push(dir, commitRemote, branch); err != nil {
+		return err
+	}
+	if e.pullRequestURL != "" {
+		taskResult.Commit.PullRequestURL = e.pullRequestURL
+		return nil
+	}
+
+	remote, err := git.RemoteURL(dir, commitRemote)
+	if err != nil {
+		return err
+	}
+	repo, err := forge.ParseRemote(remote)
+	if err != nil {
+		return err
+	}
+
+	base := task.Commit.Base
+	if base == "" {
+		base = git.DefaultBranch(dir, commitRemote)
+	}
+
+	title, _, _ := strings.Cut(message, "\n")
+	url, err := repo.OpenPullRequest(ctx, forge.PullRequest{
+		Title: title,
+		Body:  buildPullRequestBody(e.store, taskResult),
+		Head:  branch,
+		Base:  base,
+	})
+	if err != nil {
+		return err
+	}
+
+	e.pullRequestURL = url
+	taskResult.Commit.PullRequestURL = url
+	return nil
+}
+
+// buildPullRequestBody describes the run and links its report.
+func buildPullRequestBody(store *state.RunStore, taskResult *state.TaskResult) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Changes made by cortex task `%s` (agent `%s`) in run `%s`.\n\n",
+		taskResult.TaskName, taskResult.Agent, store.RunID())
+
+	if v := taskResult.Verification; v != nil && v.Success {
+		fmt.Fprintf(&b, "Verified with `%s` (attempt %d).\n\n", v.Command, v.Attempts)
+	}
+	if c := taskResult.Changes; c != nil {
+		fmt.Fprintf(&b, "Changed %d files, %d lines.\n\n", len(c.Files), c.Lines)
+	}
+
+	fmt.Fprintf(&b, "Run report: `%s`\n", store.RunDir())
+	return b.String()
+}
@@ -0,0 +1,75 @@
+package runtime_test
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/adityaraj/agentflow/internal/config"
+	"github.com/adityaraj/agentflow/internal/runtime/runtimetest"
+)
+
+// gitIn runs git in dir, failing the test if it fails.
+func gitIn(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	out, err := exec.Command("git", append([]string{"-C", dir}, args...)...).CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %s: %v\n%s", strings.Join(args, " "), err, out)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// TestCommitChanges tests that write tasks running at once each commit
+// only the files their writes globs match onto the run branch, and that
+// the checkout stays on its branch.
+func TestCommitChanges(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available")
+	}
+	h := runtimetest.New(t)
+	h.Options.Parallel = true
+	gitIn(t, h.Dir, "init", "-q")
+	gitIn(t, h.Dir, "config", "user.email", "test@example.com")
+	gitIn(t, h.Dir, "config", "user.name", "test")
+	if err := os.WriteFile(filepath.Join(h.Dir, "README.md"), []byte("demo\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	gitIn(t, h.Dir, "add", ".")
+	gitIn(t, h.Dir, "commit", "-qm", "init")
+	branch := gitIn(t, h.Dir, "rev-parse", "--abbrev-ref", "HEAD")
+
+	h.Agent.On("api", runtimetest.Response{Files: map[string]string{"api/handler.go": "package api\n"}})
+	h.Agent.On("web", runtimetest.Response{Files: map[string]string{"web/app.ts": "export {}\n"}})
+	commit := &config.CommitConfig{MessageTemplate: "cortex: {{task.name}}"}
+	cfg := &config.AgentflowConfig{
+		Agents: map[string]config.AgentConfig{"ai": {Tool: "claude-code"}},
+		Tasks: map[string]config.TaskConfig{
+			"api": {Agent: "ai", Prompt: "API", Write: true, Writes: []string{"api/**"}, Commit: commit},
+			"web": {Agent: "ai", Prompt: "Web", Write: true, Writes: []string{"web/**"}, Commit: commit},
+		},
+	}
+	run, err := h.Run(context.Background(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if current := gitIn(t, h.Dir, "rev-parse", "--abbrev-ref", "HEAD"); current != branch {
+		t.Errorf("checkout is on %s after the run, want %s", current, branch)
+	}
+	runBranch := "cortex/run-" + h.Store.RunID()
+	want := map[string]string{"api": "api/handler.go", "web": "web/app.ts"}
+	for _, r := range run.Tasks {
+		if r.Commit == nil || r.Commit.Branch != runBranch {
+			t.Fatalf("%s commit = %+v, want one on %s", r.TaskName, r.Commit, runBranch)
+		}
+		if files := gitIn(t, h.Dir, "show", "--name-only", "--format=", r.Commit.SHA); files != want[r.TaskName] {
+			t.Errorf("%s committed %q, want only %q", r.TaskName, files, want[r.TaskName])
+		}
+	}
+	if files := gitIn(t, h.Dir, "ls-tree", "-r", "--name-only", runBranch); files != "README.md\napi/handler.go\nweb/app.ts" {
+		t.Errorf("run branch has %q, want both tasks' files", files)
+	}
+}
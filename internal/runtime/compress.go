@@ -0,0 +1,91 @@
+package runtime
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/adityaraj/agentflow/internal/compress"
+	"github.com/adityaraj/agentflow/internal/planner"
+	"github.com/adityaraj/agentflow/internal/retrieval"
+	"github.com/adityaraj/agentflow/internal/tokens"
+	"github.com/adityaraj/agentflow/internal/ui"
+)
+
+// maxCompressInput caps the text a compress step hands its agent, in
+// tokens, so the summarization call itself doesn't overflow; longer text
+// is shortened extractively first.
+const maxCompressInput = 100_000
+
+// compressor runs compress steps in place of an agent adapter. Its output
+// is the step's input (the task's expanded prompt, then its files)
+// shortened to the step's budget: written by model, the step's agent, if
+// it has one, or else the most telling lines (see compress.Extract).
+type compressor struct {
+	e     *Executor
+	step  planner.ExecutionTask
+	model Agent // nil = extractive
+}
+
+// Run shortens task.Prompt and the step's files. Text already within the
+// budget is passed through. A model that fails, or whose summary is still
+// too long, is backed up by extraction.
+func (c *compressor) Run(ctx context.Context, task Task) (Result, error) {
+	text := task.Prompt
+	if files := c.step.Compress.Files; len(files) > 0 {
+		dir := task.Workdir
+		if dir == "" {
+			dir = "."
+		}
+		read, skipped, err := retrieval.ReadFiles(dir, files)
+		if err != nil {
+			return Result{}, fmt.Errorf("failed to read the files to compress: %w", err)
+		}
+		if len(skipped) > 0 {
+			ui.Warning("Task %s: left out of the text to compress: %s", task.Name, joinSkipped(skipped))
+		}
+		if text != "" && read != "" {
+			text += "\n\n"
+		}
+		text += read
+	}
+
+	limit := c.step.Compress.MaxTokens
+	if limit <= 0 {
+		limit = compress.DefaultMaxTokens
+	}
+	size := tokens.Count(text)
+	if size <= limit || c.model == nil {
+		out := compress.Extract(text, limit)
+		reportCompression(task, size, out)
+		return Result{Stdout: out, Success: true}, nil
+	}
+
+	summarize := task
+	summarize.Write = false
+	summarize.Prompt = fmt.Sprintf("Condense the following text to at most %d tokens (about %d words). "+
+		"Keep file paths, identifiers, numbers, error messages, and conclusions; drop repetition and filler. "+
+		"Reply with the condensed text only.\n\n%s",
+		limit, limit*3/4, compress.Extract(text, maxCompressInput))
+	result, err := c.e.runAgent(ctx, c.model, summarize)
+	result = estimateUsage(c.step, summarize.Prompt, result)
+	if ctx.Err() != nil {
+		return result, err
+	}
+	if err != nil || !result.Success || result.Stdout == "" {
+		ui.Warning("Task %s: %s couldn't summarize the text; keeping its most telling lines instead", task.Name, task.Agent)
+		result.Stdout = compress.Extract(text, limit)
+	} else {
+		result.Stdout = compress.Extract(result.Stdout, limit)
+	}
+	result.Stderr, result.ExitCode, result.Success = "", 0, true
+	reportCompression(task, size, result.Stdout)
+	return result, nil
+}
+
+// reportCompression writes how much a compress step shortened its text to
+// the task's progress output.
+func reportCompression(task Task, size int, out string) {
+	if task.Progress != nil {
+		fmt.Fprintf(task.Progress, "Compressed ~%d tokens to ~%d (estimated)\n", size, tokens.Count(out))
+	}
+}
@@ -0,0 +1,65 @@
+package runtime_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/adityaraj/agentflow/internal/config"
+	"github.com/adityaraj/agentflow/internal/runtime/runtimetest"
+	"github.com/adityaraj/agentflow/internal/tokens"
+)
+
+// TestCompress tests that a compress step shortens the output of the task
+// it needs, and that its dependents get the shorter text.
+func TestCompress(t *testing.T) {
+	h := runtimetest.New(t)
+	var log strings.Builder
+	for i := 0; i < 400; i++ {
+		fmt.Fprintf(&log, "ok   example.com/module%d\t0.%03ds\n", i, i)
+	}
+	log.WriteString("--- FAIL: TestParse\n    parser_test.go:42: want 4\n")
+	h.Agent.On("test", runtimetest.OK(log.String()))
+	h.Agent.On("fix", runtimetest.OK("fixed"))
+	h.Agent.On("brief", runtimetest.OK("Parsing fails: parser_test.go:42 wants 4."))
+	if err := os.WriteFile(filepath.Join(h.Dir, "notes.md"), []byte("Parser notes"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &config.AgentflowConfig{
+		Agents: map[string]config.AgentConfig{"ai": {Tool: "claude-code"}, "sh": {Tool: "shell"}},
+		Tasks: map[string]config.TaskConfig{
+			"test":  {Agent: "sh", Command: "go test ./..."},
+			"short": {Needs: []string{"test"}, Compress: &config.CompressConfig{Input: "{{outputs.test}}", MaxTokens: 100}},
+			"fix":   {Agent: "ai", Needs: []string{"short"}, Prompt: "Fix: {{outputs.short}}"},
+			"brief": {Agent: "ai", Needs: []string{"test"}, Compress: &config.CompressConfig{Input: "{{outputs.test}}", Files: []string{"*.md"}, MaxTokens: 100}},
+		},
+	}
+	run, err := h.Run(context.Background(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Extractive, without an agent
+	if calls := h.Agent.Calls("short"); len(calls) != 0 {
+		t.Errorf("short ran on an agent: %+v", calls)
+	}
+	prompt := h.Agent.Calls("fix")[0].Prompt
+	if n := tokens.Count(prompt); n > 110 || !strings.Contains(prompt, "parser_test.go:42") || !strings.Contains(prompt, "lines omitted") {
+		t.Errorf("fix prompt = %q (%d tokens), want the failure, shortened", prompt, n)
+	}
+
+	// Written by the step's agent, given the files too
+	calls := h.Agent.Calls("brief")
+	if len(calls) != 1 || !strings.Contains(calls[0].Prompt, "at most 100 tokens") || !strings.Contains(calls[0].Prompt, "notes.md\n```md\nParser notes") {
+		t.Fatalf("brief calls = %+v", calls)
+	}
+	for _, r := range run.Tasks {
+		if r.TaskName == "brief" && r.Stdout != "Parsing fails: parser_test.go:42 wants 4." {
+			t.Errorf("brief output = %q", r.Stdout)
+		}
+	}
+}
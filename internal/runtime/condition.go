@@ -0,0 +1,28 @@
+package runtime
+
+import (
+	"github.com/adityaraj/agentflow/internal/config"
+	"github.com/adityaraj/agentflow/internal/planner"
+)
+
+// evalCondition decides whether task runs, evaluating its 'when' with the
+// placeholders a prompt can use: metadata, the diff, context values,
+// outputs of the last successful run, and outputs of earlier tasks, where
+// optional references to skipped tasks take their defaults.
+func (e *Executor) evalCondition(task planner.ExecutionTask) (bool, error) {
+	cond, err := config.ParseCondition(task.When)
+	if err != nil {
+		return false, err
+	}
+	return cond.Eval(func(text string) string {
+		text = config.ExpandVariables(config.ProtectEscapes(text), e.variables)
+		text = e.expandMeta(task, text)
+		text = config.ExpandDiff(text, diffVars(e.diff))
+		text = config.ExpandContext(text, e.context)
+		text = config.ExpandPriorOutputs(text, e.prior)
+		e.outputsMu.RLock()
+		defer e.outputsMu.RUnlock()
+		text = config.ExpandDefaults(text, e.skipped)
+		return config.RestoreEscapes(config.ExpandPrompt(text, e.outputs))
+	})
+}
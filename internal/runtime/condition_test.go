@@ -0,0 +1,44 @@
+package runtime_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/adityaraj/agentflow/internal/config"
+	"github.com/adityaraj/agentflow/internal/runtime/runtimetest"
+)
+
+// TestSkippedDependencyOutput tests that a task skipped by its condition
+// leaves no output for its dependents, only the reason in its result.
+func TestSkippedDependencyOutput(t *testing.T) {
+	h := runtimetest.New(t)
+	h.Agent.On("report", runtimetest.OK("reported"))
+
+	cfg := &config.AgentflowConfig{
+		Agents: map[string]config.AgentConfig{"ai": {Tool: "claude-code"}},
+		Tasks: map[string]config.TaskConfig{
+			"fix": {Agent: "ai", Prompt: "Fix", When: "{{env.CORTEX_TEST_UNSET}}"},
+			"report": {Agent: "ai", Needs: []string{"fix"},
+				Prompt: `Fixed: [{{outputs.fix}}] or {{outputs.fix | default "nothing"}}`},
+		},
+	}
+	run, err := h.Run(context.Background(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if calls := h.Agent.Calls("fix"); len(calls) != 0 {
+		t.Errorf("fix ran %d times, want it skipped", len(calls))
+	}
+	if prompt := h.Agent.Calls("report")[0].Prompt; prompt != "Fixed: [] or nothing" {
+		t.Errorf("report prompt = %q, want no output from fix", prompt)
+	}
+	for _, r := range run.Tasks {
+		if r.TaskName != "fix" {
+			continue
+		}
+		if !r.Success || r.Stdout != "" || r.Skipped != "condition is false: {{env.CORTEX_TEST_UNSET}}" {
+			t.Errorf("fix result: success %v, stdout %q, skipped %q; want only the reason", r.Success, r.Stdout, r.Skipped)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package runtime
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/adityaraj/agentflow/internal/config"
+	"github.com/adityaraj/agentflow/internal/contextpack"
+	"github.com/adityaraj/agentflow/internal/planner"
+	"github.com/adityaraj/agentflow/internal/state"
+	"github.com/adityaraj/agentflow/internal/ui"
+)
+
+// PrepareContext generates the shared context values the plan's prompts
+// reference, once for the whole run, and saves them in the run directory.
+// Does nothing if no prompt uses {{context.X}}.
+func PrepareContext(plan *planner.ExecutionPlan, store *state.RunStore) error {
+	if !usesContext(plan, config.ContextRepo) {
+		return nil
+	}
+
+	dir, err := os.Getwd()
+	if err != nil {
+		return fmt.Errorf("failed to get working directory: %w", err)
+	}
+	pack, skipped, err := contextpack.Generate(dir)
+	if err != nil {
+		return fmt.Errorf("failed to generate repo context: %w", err)
+	}
+	if len(skipped) > 0 {
+		ui.Warning("Left out of the repo context: %s", joinSkipped(skipped))
+	}
+	if err := store.WriteRunFile(contextpack.FileName, []byte(pack)); err != nil {
+		return fmt.Errorf("failed to save repo context: %w", err)
+	}
+
+	if plan.Context == nil {
+		plan.Context = make(map[string]string)
+	}
+	plan.Context[config.ContextRepo] = pack
+	return nil
+}
+
+// usesContext reports whether the preamble, an agent's instructions, or any
+// task references {{context.<name>}}.
+func usesContext(plan *planner.ExecutionPlan, name string) bool {
+	prompts := plan.SharedPrompts()
+	for _, task := range plan.Tasks {
+		prompts = append(prompts, task.Prompt)
+	}
+	for _, prompt := range prompts {
+		for _, ref := range config.ExtractContextVars(prompt) {
+			if ref == name {
+				return true
+			}
+		}
+	}
+	return false
+}
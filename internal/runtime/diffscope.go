@@ -0,0 +1,179 @@
+package runtime
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/adityaraj/agentflow/internal/config"
+	"github.com/adityaraj/agentflow/internal/contextpack"
+	"github.com/adityaraj/agentflow/internal/git"
+	"github.com/adityaraj/agentflow/internal/planner"
+	"github.com/adityaraj/agentflow/internal/state"
+	"github.com/adityaraj/agentflow/internal/ui"
+)
+
+// maxDiffPatch caps the diff embedded in a diff-scoped task's prompt.
+const maxDiffPatch = 64 * 1024
+
+// PrepareDiff computes the changes since base for diff-scoped tasks and
+// {{diff.X}} placeholders. An empty base selects the origin remote's default
+// branch. Does nothing if no task uses the diff.
+func PrepareDiff(plan *planner.ExecutionPlan, base string) error {
+	if !usesDiff(plan) {
+		return nil
+	}
+
+	dir, err := os.Getwd()
+	if err != nil {
+		return fmt.Errorf("failed to get working directory: %w", err)
+	}
+	if !git.IsRepo(dir) {
+		return fmt.Errorf("scope: diff and paths need a git repository")
+	}
+	if base == "" {
+		base = "origin/" + git.DefaultBranch(dir, "origin")
+	}
+	if !git.RefExists(dir, base) {
+		return fmt.Errorf("base ref %q not found; set it with --base or settings.base", base)
+	}
+
+	files, err := git.ChangedFiles(dir, base)
+	if err != nil {
+		return fmt.Errorf("failed to list changes since %s: %w", base, err)
+	}
+	patch, err := git.DiffSince(dir, base)
+	if err != nil {
+		return fmt.Errorf("failed to diff against %s: %w", base, err)
+	}
+
+	// Leave out what .cortexignore keeps from agents
+	ignore, err := contextpack.LoadIgnore(dir)
+	if err == nil {
+		err = ignore.AddNestedOf(dir, files)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", contextpack.IgnoreFileName, err)
+	}
+	files = ignore.Filter(files)
+	patch = filterPatch(patch, ignore)
+
+	plan.Diff = &planner.DiffScope{
+		Base:     base,
+		Files:    files,
+		Packages: packagesOf(files),
+		Patch:    truncateMiddle(patch, maxDiffPatch),
+	}
+	return nil
+}
+
+// filterPatch drops the files ignore ignores from a git diff.
+func filterPatch(patch string, ignore *contextpack.Ignore) string {
+	var b strings.Builder
+	keep := true
+	for _, line := range strings.SplitAfter(patch, "\n") {
+		if header, ok := strings.CutPrefix(line, "diff --git "); ok {
+			name := strings.TrimRight(header, "\n")
+			if i := strings.LastIndex(name, " b/"); i >= 0 {
+				name = name[i+len(" b/"):]
+			}
+			keep = !ignore.Ignored(name)
+		}
+		if keep {
+			b.WriteString(line)
+		}
+	}
+	return b.String()
+}
+
+// usesDiff reports whether any task is diff-scoped, filters on changed
+// paths, or references {{diff.X}}.
+func usesDiff(plan *planner.ExecutionPlan) bool {
+	for _, text := range plan.SharedPrompts() {
+		if len(config.ExtractDiffVars(text)) > 0 {
+			return true
+		}
+	}
+	for _, task := range plan.Tasks {
+		if task.Scope == config.ScopeDiff || len(task.Paths) > 0 || len(config.ExtractDiffVars(task.Prompt)) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// packagesOf returns the sorted, distinct directories of files, written
+// "./dir" so they can be passed to tools like `go vet` as package paths.
+func packagesOf(files []string) []string {
+	seen := make(map[string]bool)
+	var dirs []string
+	for _, f := range files {
+		dir := path.Dir(f)
+		if dir != "." {
+			dir = "./" + dir
+		}
+		if !seen[dir] {
+			seen[dir] = true
+			dirs = append(dirs, dir)
+		}
+	}
+	sort.Strings(dirs)
+	return dirs
+}
+
+// diffVars returns the values of the {{diff.X}} placeholders.
+func diffVars(d *planner.DiffScope) map[string]string {
+	if d == nil {
+		return nil
+	}
+	return map[string]string{
+		config.DiffFiles:    strings.Join(d.Files, " "),
+		config.DiffPackages: strings.Join(d.Packages, " "),
+		config.DiffBase:     d.Base,
+	}
+}
+
+// withDiffScope prefixes an AI task's prompt with the files changed since
+// the base ref and their diff. Shell commands are returned unchanged.
+func withDiffScope(d *planner.DiffScope, task planner.ExecutionTask) string {
+	if task.Tool == "shell" {
+		return task.Prompt
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Scope: only the %d files changed since %s. Focus on these changes; don't review or edit unrelated code.\n\n", len(d.Files), d.Base)
+	for _, f := range d.Files {
+		fmt.Fprintf(&b, "- %s\n", f)
+	}
+	if d.Patch != "" {
+		fmt.Fprintf(&b, "\n```diff\n%s\n```\n", d.Patch)
+	}
+	b.WriteString("\n")
+	b.WriteString(task.Prompt)
+	return b.String()
+}
+
+// skipTask records a task as done without running it, e.g. because none of
+// the changes since the base ref concern it. Its output is empty; reason
+// is recorded only as why it was skipped.
+func (e *Executor) skipTask(execTask planner.ExecutionTask, reason string) *state.TaskResult {
+	taskResult := state.NewTaskResult(execTask.Name, execTask.AgentName, execTask.Tool, execTask.Model, "")
+	taskResult.Complete("", "", 0, true)
+	taskResult.Skipped = reason
+	if err := e.store.SaveTaskResult(taskResult); err != nil {
+		ui.Warning("Failed to save result: %s", err)
+	}
+
+	e.outputsMu.Lock()
+	e.outputs[execTask.Name] = ""
+	if e.skipped == nil {
+		e.skipped = make(map[string]bool)
+	}
+	e.skipped[execTask.Name] = true
+	e.outputsMu.Unlock()
+
+	ui.PrintTaskStatus("Skipped: "+reason, true, "0s")
+	return taskResult
+}
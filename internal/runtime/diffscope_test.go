@@ -0,0 +1,159 @@
+package runtime
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/adityaraj/agentflow/internal/contextpack"
+	"github.com/adityaraj/agentflow/internal/planner"
+	"github.com/adityaraj/agentflow/internal/state"
+)
+
+func TestWithDiffScope(t *testing.T) {
+	files := []string{"README.md", "api/handler.go", "api/routes.go", "internal/db/db.go"}
+	d := &planner.DiffScope{Base: "origin/main", Files: files, Packages: packagesOf(files), Patch: "+added"}
+
+	if got := strings.Join(d.Packages, " "); got != ". ./api ./internal/db" {
+		t.Errorf("packagesOf() = %q", got)
+	}
+
+	prompt := withDiffScope(d, planner.ExecutionTask{Tool: "claude-code", Prompt: "Review the changes."})
+	for _, want := range []string{"4 files changed since origin/main", "- api/routes.go\n", "```diff\n+added\n```", "Review the changes."} {
+		if !strings.Contains(prompt, want) {
+			t.Errorf("withDiffScope() missing %q:\n%s", want, prompt)
+		}
+	}
+
+	shell := planner.ExecutionTask{Tool: "shell", Prompt: "go vet {{diff.packages}}"}
+	if got := withDiffScope(d, shell); got != shell.Prompt {
+		t.Errorf("withDiffScope() changed a shell command: %q", got)
+	}
+	if got := diffVars(d)["files"]; got != strings.Join(files, " ") {
+		t.Errorf("diffVars()[files] = %q", got)
+	}
+}
+
+func TestExecuteTask_PathsFilter(t *testing.T) {
+	store, err := state.NewRunStoreWithPath(t.TempDir(), "/work/demo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := &Executor{
+		registry: NewAgentRegistry(),
+		store:    store,
+		outputs:  make(map[string]string),
+		router:   NewRouter(nil),
+		budget:   NewBudget(0),
+		diff:     &planner.DiffScope{Base: "origin/main", Files: []string{"web/app.ts"}},
+	}
+	e.registry.Register("shell", &overflowAgent{limit: 1000})
+
+	task := planner.ExecutionTask{Name: "api-tests", AgentName: "sh", Tool: "shell", Prompt: "go test ./api/...", Paths: []string{"api/**"}}
+	result, err := e.executeTask(context.Background(), task)
+	if err != nil {
+		t.Fatalf("executeTask() error = %v", err)
+	}
+	if !result.Success || result.Skipped == "" {
+		t.Errorf("result = %+v, want a successful skip", result)
+	}
+	if result.Stdout != "" || e.outputs["api-tests"] != "" {
+		t.Errorf("output = %q, %q; want none for a skipped task", result.Stdout, e.outputs["api-tests"])
+	}
+}
+
+func TestExecuteTask_DefaultForSkippedTask(t *testing.T) {
+	store, err := state.NewRunStoreWithPath(t.TempDir(), "/work/demo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	agent := &overflowAgent{limit: 1000}
+	e := &Executor{
+		registry: NewAgentRegistry(),
+		store:    store,
+		outputs:  make(map[string]string),
+		router:   NewRouter(nil),
+		budget:   NewBudget(0),
+		backoff:  NewBackoff(),
+		diff:     &planner.DiffScope{Base: "origin/main", Files: []string{"web/app.ts"}},
+	}
+	e.registry.Register("claude-code", agent)
+
+	scan := planner.ExecutionTask{Name: "scan", AgentName: "a", Tool: "claude-code", Prompt: "scan", Paths: []string{"api/**"}}
+	report := planner.ExecutionTask{Name: "report", AgentName: "a", Tool: "claude-code",
+		Prompt: `Findings: {{outputs.scan | default "none"}}. Raw: {{outputs.scan}}`}
+	for _, task := range []planner.ExecutionTask{scan, report} {
+		if _, err := e.executeTask(context.Background(), task); err != nil {
+			t.Fatalf("executeTask(%s) error = %v", task.Name, err)
+		}
+	}
+
+	if len(agent.prompts) != 1 {
+		t.Fatalf("agent ran %d times, want 1 (scan is skipped)", len(agent.prompts))
+	}
+	if want := "Findings: none. Raw: "; agent.prompts[0] != want {
+		t.Errorf("prompt = %q, want %q", agent.prompts[0], want)
+	}
+}
+
+func TestExecuteTask_When(t *testing.T) {
+	store, err := state.NewRunStoreWithPath(t.TempDir(), "/work/demo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	agent := &overflowAgent{limit: 1000}
+	e := &Executor{
+		registry: NewAgentRegistry(),
+		store:    store,
+		outputs:  map[string]string{"scan": "2 SECURITY findings"},
+		router:   NewRouter(nil),
+		budget:   NewBudget(0),
+		backoff:  NewBackoff(),
+	}
+	e.registry.Register("claude-code", agent)
+
+	fix := planner.ExecutionTask{Name: "fix", AgentName: "a", Tool: "claude-code", Prompt: "fix",
+		When: "{{outputs.scan}} contains SECURITY"}
+	notify := planner.ExecutionTask{Name: "notify", AgentName: "a", Tool: "claude-code", Prompt: "notify",
+		When: "{{outputs.scan}} contains CRITICAL or {{env.CORTEX_TEST_UNSET}}"}
+	for _, task := range []planner.ExecutionTask{fix, notify} {
+		if _, err := e.executeTask(context.Background(), task); err != nil {
+			t.Fatalf("executeTask(%s) error = %v", task.Name, err)
+		}
+	}
+	if len(agent.prompts) != 1 || agent.prompts[0] != "fix" {
+		t.Errorf("agent ran %q, want only fix", agent.prompts)
+	}
+	result, err := store.LoadTaskResult("notify")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Success || !strings.HasPrefix(result.Skipped, "condition is false") {
+		t.Errorf("notify = %+v, want a successful skip", result)
+	}
+
+	// Conditions that can't be evaluated fail the task
+	bad := planner.ExecutionTask{Name: "bad", AgentName: "a", Tool: "claude-code", Prompt: "x",
+		When: "{{outputs.scan}} matches '{{outputs.scan}}('"}
+	if result, err := e.executeTask(context.Background(), bad); err == nil || result.Success {
+		t.Errorf("executeTask(bad) = %+v, %v, want a failure", result, err)
+	}
+}
+
+func TestFilterPatch(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, contextpack.IgnoreFileName), []byte("gen/\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	ignore, err := contextpack.LoadIgnore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	patch := "diff --git a/api.go b/api.go\n+api\ndiff --git a/gen/api.pb.go b/gen/api.pb.go\n+generated\ndiff --git a/main.go b/main.go\n+main\n"
+	want := "diff --git a/api.go b/api.go\n+api\ndiff --git a/main.go b/main.go\n+main\n"
+	if got := filterPatch(patch, ignore); got != want {
+		t.Errorf("filterPatch() = %q, want %q", got, want)
+	}
+}
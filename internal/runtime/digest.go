@@ -0,0 +1,35 @@
+package runtime
+
+import (
+	"bytes"
+	"path/filepath"
+	"slices"
+
+	"github.com/adityaraj/agentflow/internal/planner"
+	"github.com/adityaraj/agentflow/internal/report"
+	"github.com/adityaraj/agentflow/internal/state"
+)
+
+// writeDigest writes the Markdown digest of a finished run to the path
+// plan.Digest configures, relative to the workdir, and returns where it was
+// written. Like output files, an existing digest is never overwritten.
+func (e *Executor) writeDigest(plan *planner.ExecutionPlan, run *state.RunResult) (string, error) {
+	d := report.Digest{Title: plan.Digest.Title}
+	for _, t := range plan.Tasks {
+		if len(plan.Digest.Tasks) == 0 || slices.Contains(plan.Digest.Tasks, t.Name) {
+			d.Tasks = append(d.Tasks, t.Name)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := report.RenderDigest(&buf, run, d); err != nil {
+		return "", err
+	}
+
+	// The path can't use {{task.X}}, so there's no task to expand it for
+	path := e.expandMeta(planner.ExecutionTask{}, plan.Digest.Path)
+	if !filepath.IsAbs(path) && len(plan.Tasks) > 0 && plan.Tasks[0].Workdir != "" {
+		path = filepath.Join(plan.Tasks[0].Workdir, path)
+	}
+	return writeNewFile(path, buf.String())
+}
@@ -0,0 +1,160 @@
+package runtime
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/adityaraj/agentflow/internal/sysload"
+	"github.com/adityaraj/agentflow/internal/ui"
+)
+
+// ErrDiskSpace is the cause of tasks stopped or not started because a disk
+// the run uses is nearly full.
+var ErrDiskSpace = errors.New("not enough disk space")
+
+// DefaultMinDiskMB is the free space, in MiB, runs need by default.
+const DefaultMinDiskMB = 512
+
+// Disk guard timing.
+const (
+	DefaultDiskPoll = 5 * time.Second // How often space is rechecked
+	DefaultDiskWait = 2 * time.Minute // How long a task waits for space before failing
+)
+
+// DiskSpaceError reports a filesystem with less free space than required.
+type DiskSpaceError struct {
+	Path string
+	Free uint64
+	Min  uint64
+}
+
+func (e *DiskSpaceError) Error() string {
+	return fmt.Sprintf("only %s free on the disk holding %s (minimum %s)",
+		ui.FormatBytes(int64(e.Free)), e.Path, ui.FormatBytes(int64(e.Min)))
+}
+
+// Unwrap makes DiskSpaceError match ErrDiskSpace.
+func (e *DiskSpaceError) Unwrap() error {
+	return ErrDiskSpace
+}
+
+// DiskGuard keeps a run from filling the disks it writes to: session logs,
+// the working directories agents edit and build in, and the temp directory.
+// Tasks wait to start while any of them has less than the minimum free, and
+// running tasks are stopped if one falls below half of it.
+type DiskGuard struct {
+	paths []string
+	min   uint64
+	poll  time.Duration
+	wait  time.Duration
+	free  func(path string) (uint64, error)
+}
+
+// NewDiskGuard creates a guard requiring minMB MiB free on the disks
+// holding paths (0 = DefaultMinDiskMB). Returns nil (no guard) if minMB is
+// negative.
+func NewDiskGuard(minMB int, paths ...string) *DiskGuard {
+	if minMB < 0 {
+		return nil
+	}
+	if minMB == 0 {
+		minMB = DefaultMinDiskMB
+	}
+	seen := make(map[string]bool)
+	var unique []string
+	for _, p := range paths {
+		if p != "" && !seen[p] {
+			seen[p] = true
+			unique = append(unique, p)
+		}
+	}
+	return &DiskGuard{
+		paths: unique,
+		min:   uint64(minMB) << 20,
+		poll:  DefaultDiskPoll,
+		wait:  DefaultDiskWait,
+		free:  sysload.DiskFree,
+	}
+}
+
+// Check returns a *DiskSpaceError for the fullest disk with less than the
+// minimum free, or nil. Paths whose free space can't be read are skipped.
+func (g *DiskGuard) Check() error {
+	if g == nil {
+		return nil
+	}
+	return g.below(g.min)
+}
+
+// below returns a *DiskSpaceError for the fullest disk with less than min
+// bytes free.
+func (g *DiskGuard) below(min uint64) error {
+	var worst *DiskSpaceError
+	for _, p := range g.paths {
+		free, err := g.free(p)
+		if err != nil || free >= min {
+			continue
+		}
+		if worst == nil || free < worst.Free {
+			worst = &DiskSpaceError{Path: p, Free: free, Min: g.min}
+		}
+	}
+	if worst == nil {
+		return nil
+	}
+	return worst
+}
+
+// WaitForSpace returns once there is enough free space for task to start,
+// pausing it while there isn't. It gives up with a *DiskSpaceError after
+// the guard's wait, or with the context's error if ctx is done first.
+func (g *DiskGuard) WaitForSpace(ctx context.Context, task string) error {
+	err := g.Check()
+	if err == nil {
+		return nil
+	}
+	ui.PrintDiskLow(task, err.Error(), g.wait)
+	deadline := time.Now().Add(g.wait)
+	for err != nil && time.Now().Before(deadline) {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(g.poll):
+		}
+		err = g.Check()
+	}
+	return err
+}
+
+// Watch stops a running task, with its *DiskSpaceError as the cause, if
+// free space falls below half the minimum. The returned function stops
+// watching.
+func (g *DiskGuard) Watch(stop context.CancelCauseFunc) func() {
+	if g == nil {
+		return func() {}
+	}
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(g.poll)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if err := g.below(g.min / 2); err != nil {
+					stop(err)
+					return
+				}
+			}
+		}
+	}()
+	return func() { close(done) }
+}
+
+// diskFull reports whether ctx was cancelled by a disk guard.
+func diskFull(ctx context.Context) bool {
+	return errors.Is(context.Cause(ctx), ErrDiskSpace)
+}
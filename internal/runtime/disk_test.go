@@ -0,0 +1,100 @@
+package runtime
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestNewDiskGuard(t *testing.T) {
+	if g := NewDiskGuard(-1, "."); g != nil {
+		t.Error("a negative minimum should disable the guard")
+	}
+	g := NewDiskGuard(0, "a", "b", "a", "")
+	if g.min != DefaultMinDiskMB<<20 {
+		t.Errorf("min = %d, want the default", g.min)
+	}
+	if len(g.paths) != 2 {
+		t.Errorf("paths = %v, want a and b once each", g.paths)
+	}
+}
+
+func TestDiskGuard_Check(t *testing.T) {
+	g := NewDiskGuard(100, "/work", "/tmp", "/gone")
+	g.free = func(path string) (uint64, error) {
+		switch path {
+		case "/work":
+			return 80 << 20, nil
+		case "/tmp":
+			return 20 << 20, nil
+		}
+		return 0, errors.New("no such file or directory")
+	}
+
+	err := g.Check()
+	var diskErr *DiskSpaceError
+	if !errors.As(err, &diskErr) || !errors.Is(err, ErrDiskSpace) {
+		t.Fatalf("Check() = %v, want a DiskSpaceError", err)
+	}
+	if diskErr.Path != "/tmp" {
+		t.Errorf("Path = %q, want the fullest disk", diskErr.Path)
+	}
+	if got := err.Error(); got != "only 20.0 MiB free on the disk holding /tmp (minimum 100.0 MiB)" {
+		t.Errorf("Error() = %q", got)
+	}
+
+	var nilGuard *DiskGuard
+	if err := nilGuard.Check(); err != nil {
+		t.Errorf("nil guard Check() = %v", err)
+	}
+}
+
+func TestDiskGuard_WaitForSpace(t *testing.T) {
+	var free atomic.Uint64
+	free.Store(10 << 20)
+	g := NewDiskGuard(100, "/work")
+	g.poll = 10 * time.Millisecond
+	g.free = func(string) (uint64, error) { return free.Load(), nil }
+
+	time.AfterFunc(30*time.Millisecond, func() { free.Store(500 << 20) })
+	if err := g.WaitForSpace(context.Background(), "build"); err != nil {
+		t.Errorf("WaitForSpace() = %v, want nil once space was freed", err)
+	}
+
+	free.Store(10 << 20)
+	g.wait = 30 * time.Millisecond
+	if err := g.WaitForSpace(context.Background(), "build"); !errors.Is(err, ErrDiskSpace) {
+		t.Errorf("WaitForSpace() = %v, want ErrDiskSpace after the wait", err)
+	}
+}
+
+func TestDiskGuard_WatchStopsTask(t *testing.T) {
+	var free atomic.Uint64
+	free.Store(80 << 20)
+	g := NewDiskGuard(100, "/work")
+	g.poll = 10 * time.Millisecond
+	g.free = func(string) (uint64, error) { return free.Load(), nil }
+
+	ctx, cancel := context.WithCancelCause(context.Background())
+	defer cancel(nil)
+	stop := g.Watch(cancel)
+	defer stop()
+
+	// Below the minimum but above half of it: running tasks continue
+	time.Sleep(40 * time.Millisecond)
+	if ctx.Err() != nil {
+		t.Fatal("task stopped while above half the minimum")
+	}
+
+	free.Store(30 << 20)
+	select {
+	case <-ctx.Done():
+	case <-time.After(time.Second):
+		t.Fatal("task not stopped below half the minimum")
+	}
+	if !diskFull(ctx) {
+		t.Errorf("cause = %v, want ErrDiskSpace", context.Cause(ctx))
+	}
+}
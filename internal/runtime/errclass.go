@@ -0,0 +1,165 @@
+package runtime
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/adityaraj/agentflow/internal/state"
+)
+
+// classifyStdoutTail is how much trailing stdout is searched for error messages.
+const classifyStdoutTail = 2048
+
+// errorPattern maps a lowercase substring of a tool's output to a category.
+type errorPattern struct {
+	substr   string
+	category state.ErrorCategory
+}
+
+// providerPatterns match failures reported by AI CLIs talking to a model API.
+// Order matters: the first match wins.
+var providerPatterns = []errorPattern{
+	// Context overflow
+	{"prompt is too long", state.ErrorContextOverflow},
+	{"context window", state.ErrorContextOverflow},
+	{"context length", state.ErrorContextOverflow},
+	{"context_length_exceeded", state.ErrorContextOverflow},
+	{"maximum context", state.ErrorContextOverflow},
+	{"too many tokens", state.ErrorContextOverflow},
+
+	// Authentication
+	{"invalid api key", state.ErrorAuth},
+	{"invalid x-api-key", state.ErrorAuth},
+	{"authentication_error", state.ErrorAuth},
+	{"please run /login", state.ErrorAuth},
+	{"unauthorized", state.ErrorAuth},
+	{"api key not found", state.ErrorAuth},
+	{"oauth token has expired", state.ErrorAuth},
+	{"permission_error", state.ErrorAuth},
+
+	// Rate limits and overload
+	{"rate_limit_error", state.ErrorRateLimit},
+	{"rate limit", state.ErrorRateLimit},
+	{"too many requests", state.ErrorRateLimit},
+	{"overloaded_error", state.ErrorRateLimit},
+	{"overloaded", state.ErrorRateLimit},
+	{"usage limit", state.ErrorRateLimit},
+	{"status 429", state.ErrorRateLimit},
+	{" 429 ", state.ErrorRateLimit},
+
+	// Network
+	{"econnrefused", state.ErrorNetwork},
+	{"econnreset", state.ErrorNetwork},
+	{"enotfound", state.ErrorNetwork},
+	{"etimedout", state.ErrorNetwork},
+	{"getaddrinfo", state.ErrorNetwork},
+	{"connection refused", state.ErrorNetwork},
+	{"connection reset", state.ErrorNetwork},
+	{"network error", state.ErrorNetwork},
+	{"no such host", state.ErrorNetwork},
+	{"tls handshake", state.ErrorNetwork},
+	{"fetch failed", state.ErrorNetwork},
+}
+
+// crashPatterns match a process dying rather than reporting an error.
+var crashPatterns = []errorPattern{
+	{"panic:", state.ErrorCrash},
+	{"segmentation fault", state.ErrorCrash},
+	{"sigsegv", state.ErrorCrash},
+	{"fatal error: runtime", state.ErrorCrash},
+	{"uncaught exception", state.ErrorCrash},
+	{"unhandled promise rejection", state.ErrorCrash},
+	{"javascript heap out of memory", state.ErrorCrash},
+}
+
+// toolPatterns selects the patterns that apply to each tool. Shell commands
+// only get crash detection: their output is the user's own program, so a
+// mention of "rate limit" there says nothing about the provider.
+var toolPatterns = map[string][][]errorPattern{
+	"claude-code": {providerPatterns, crashPatterns},
+	"opencode":    {providerPatterns, crashPatterns},
+	"shell":       {crashPatterns},
+}
+
+// ClassifyError determines why a task failed from the tool's result and any
+// error returned by the adapter. ctx is checked first so interrupted runs
+// and tasks stopped by a token budget aren't misreported as crashes.
+func ClassifyError(ctx context.Context, tool string, result Result, err error) state.ErrorCategory {
+	if budgetExceeded(ctx) {
+		return state.ErrorBudget
+	}
+	if diskFull(ctx) {
+		return state.ErrorDiskSpace
+	}
+	if timedOut(ctx) {
+		return state.ErrorTimeout
+	}
+	if ctx.Err() != nil || errors.Is(err, context.Canceled) {
+		return state.ErrorCancelled
+	}
+
+	// CLIs print fatal errors last; only the tail of stdout is considered so
+	// agent output that merely discusses e.g. rate limiting isn't misread.
+	stdout := result.Stdout
+	if len(stdout) > classifyStdoutTail {
+		stdout = stdout[len(stdout)-classifyStdoutTail:]
+	}
+	text := strings.ToLower(result.Stderr + "\n" + stdout)
+	if err != nil {
+		text += "\n" + strings.ToLower(err.Error())
+	}
+
+	patterns, ok := toolPatterns[tool]
+	if !ok {
+		patterns = [][]errorPattern{providerPatterns, crashPatterns}
+	}
+	for _, group := range patterns {
+		for _, p := range group {
+			if strings.Contains(text, p.substr) {
+				return p.category
+			}
+		}
+	}
+
+	// Killed by a signal (-1), or a shell reporting 128+signal for SIGABRT/SIGKILL/SIGSEGV
+	switch result.ExitCode {
+	case -1, 134, 137, 139:
+		return state.ErrorCrash
+	}
+
+	return state.ErrorFailed
+}
+
+// IsRetryable reports whether a failure category is transient, so
+// re-running the task unchanged may succeed.
+func IsRetryable(category state.ErrorCategory) bool {
+	switch category {
+	case state.ErrorRateLimit, state.ErrorNetwork:
+		return true
+	}
+	return false
+}
+
+// ErrorHint returns a short suggestion for a failure category.
+func ErrorHint(category state.ErrorCategory) string {
+	switch category {
+	case state.ErrorAuth:
+		return "check the tool's API key or log in again"
+	case state.ErrorRateLimit:
+		return "rate limited by the provider; retry later or lower max_parallel"
+	case state.ErrorContextOverflow:
+		return "prompt exceeded the model's context window; trim the inputs"
+	case state.ErrorNetwork:
+		return "could not reach the provider; check your connection"
+	case state.ErrorCrash:
+		return "the tool crashed; see stderr in the task result"
+	case state.ErrorBudget:
+		return "token budget ran out; raise max_tokens or split the task"
+	case state.ErrorDiskSpace:
+		return "a disk was nearly full; free up space or adjust settings.min_disk_mb"
+	case state.ErrorTimeout:
+		return "the task ran longer than its timeout; raise timeout or split the task"
+	}
+	return ""
+}
@@ -0,0 +1,104 @@
+package runtime
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/adityaraj/agentflow/internal/state"
+)
+
+func TestClassifyError(t *testing.T) {
+	tests := []struct {
+		name   string
+		tool   string
+		result Result
+		err    error
+		want   state.ErrorCategory
+	}{
+		{
+			name:   "claude auth",
+			tool:   "claude-code",
+			result: Result{Stdout: "Invalid API key · Please run /login", ExitCode: 1},
+			want:   state.ErrorAuth,
+		},
+		{
+			name:   "claude rate limit",
+			tool:   "claude-code",
+			result: Result{Stderr: `API Error: 429 {"type":"error","error":{"type":"rate_limit_error"}}`, ExitCode: 1},
+			want:   state.ErrorRateLimit,
+		},
+		{
+			name:   "context overflow",
+			tool:   "claude-code",
+			result: Result{Stderr: "Prompt is too long", ExitCode: 1},
+			want:   state.ErrorContextOverflow,
+		},
+		{
+			name:   "network",
+			tool:   "opencode",
+			result: Result{Stderr: "Error: connect ECONNREFUSED 127.0.0.1:443", ExitCode: 1},
+			want:   state.ErrorNetwork,
+		},
+		{
+			name:   "killed by signal",
+			tool:   "claude-code",
+			result: Result{ExitCode: -1},
+			want:   state.ErrorCrash,
+		},
+		{
+			name:   "shell output mentioning rate limit",
+			tool:   "shell",
+			result: Result{Stdout: "FAIL TestRateLimit: rate limit not applied", ExitCode: 1},
+			want:   state.ErrorFailed,
+		},
+		{
+			name:   "shell panic",
+			tool:   "shell",
+			result: Result{Stderr: "panic: runtime error: index out of range", ExitCode: 2},
+			want:   state.ErrorCrash,
+		},
+		{
+			name:   "agent output discussing errors early on",
+			tool:   "claude-code",
+			result: Result{Stdout: "Added rate limit handling.\n" + strings.Repeat("x", 4096), ExitCode: 1},
+			want:   state.ErrorFailed,
+		},
+		{
+			name: "adapter error",
+			tool: "claude-code",
+			err:  errors.New("dial tcp: lookup api.anthropic.com: no such host"),
+			want: state.ErrorNetwork,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ClassifyError(context.Background(), tt.tool, tt.result, tt.err)
+			if got != tt.want {
+				t.Errorf("ClassifyError() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClassifyError_Cancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	got := ClassifyError(ctx, "claude-code", Result{ExitCode: -1}, nil)
+	if got != state.ErrorCancelled {
+		t.Errorf("ClassifyError() = %q, want %q", got, state.ErrorCancelled)
+	}
+}
+
+func TestClassifyError_Budget(t *testing.T) {
+	ctx, cancel := context.WithCancelCause(context.Background())
+	cancel(ErrBudgetExceeded)
+
+	got := ClassifyError(ctx, "claude-code", Result{ExitCode: -1}, nil)
+	if got != state.ErrorBudget {
+		t.Errorf("ClassifyError() = %q, want %q", got, state.ErrorBudget)
+	}
+}
@@ -1,15 +1,24 @@
 package runtime
 
 import (
+	"cmp"
 	"context"
+	"errors"
 	"fmt"
 	"io"
+	"os"
+	"path/filepath"
 	"sync"
 	"sync/atomic"
 	"time"
 
 	"github.com/adityaraj/agentflow/internal/config"
+	"github.com/adityaraj/agentflow/internal/git"
+	"github.com/adityaraj/agentflow/internal/glob"
+	"github.com/adityaraj/agentflow/internal/normalize"
 	"github.com/adityaraj/agentflow/internal/planner"
+	"github.com/adityaraj/agentflow/internal/proc"
+	"github.com/adityaraj/agentflow/internal/secrets"
 	"github.com/adityaraj/agentflow/internal/state"
 	"github.com/adityaraj/agentflow/internal/ui"
 )
@@ -17,58 +26,170 @@ import (
 // Executor runs tasks according to an execution plan.
 type Executor struct {
 	registry    *AgentRegistry
-	store       *state.Store
+	store       *state.RunStore
 	outputs     map[string]string // Task outputs for template expansion
 	outputsMu   sync.RWMutex      // Protects outputs map
+	skipped     map[string]bool   // Tasks skipped rather than run; guarded by outputsMu
 	verbose     bool
 	writer      io.Writer // Output writer for logs
 	parallel    bool      // Enable parallel execution
 	maxParallel int       // Max concurrent tasks (0 = unlimited)
+
+	backoff        *Backoff   // Rate-limit cooldowns shared by all tasks
+	router         *Router    // Per-agent concurrency and interchangeable-agent routing
+	gitMu          sync.Mutex // Serializes commits to the run branch
+	pullRequestURL string     // Pull request opened for the run branch, if any
+
+	budget     *Budget             // Run-wide token budget, updated as usage streams in
+	load       *LoadGate           // Defers parallel tasks while the system is busy (nil = off)
+	disk       *DiskGuard          // Pauses or stops tasks while disks are nearly full (nil = off)
+	preamble   string              // Prepended to every AI task's prompt
+	variables  map[string]string   // Values for {{vars.X}} placeholders
+	context    map[string]string   // Values for {{context.X}} placeholders
+	retrieved  map[string]string   // Results of {{retrieve}} placeholders
+	prior      map[string]string   // Outputs of the last successful run
+	diff       *planner.DiffScope  // Changes since the base ref, for diff-scoped tasks
+	meta       map[string]string   // Run-wide {{run.X}} and {{git.X}} values
+	secrets    map[string]string   // Values of {{secrets.X}}, by name
+	redactor   *secrets.Redactor   // Masks the secrets in output (nil = no secrets)
+	shell      string              // Default shell for shell tasks and verify ("" = proc.DefaultShell)
+	heartbeat  time.Duration       // Interval between progress events (<= 0 = off)
+	onProgress func(ProgressEvent) // Called for each progress event (optional)
+	clock      Clock               // Run times and rate-limit cooldowns
 }
 
 // ExecutorConfig holds configuration for creating an Executor.
 type ExecutorConfig struct {
 	Registry    *AgentRegistry
-	Store       *state.Store
+	Store       *state.RunStore
 	Writer      io.Writer
 	Verbose     bool
 	Parallel    bool
 	MaxParallel int
+
+	// Heartbeat is the interval between progress events for running tasks
+	// (0 = DefaultHeartbeat, negative = disabled).
+	Heartbeat time.Duration
+
+	// OnProgress is called for each progress event, e.g. to forward it to webhooks.
+	OnProgress func(ProgressEvent)
+
+	// MaxTokens is the run's token budget (0 = no limit).
+	MaxTokens int
+
+	// Shell runs shell tasks and verify commands, unless a task names its
+	// own ("" = proc.DefaultShell).
+	Shell string
+
+	// MaxCPU and MaxMemory defer starting parallel tasks while system CPU
+	// or memory use is above these percentages (0 = no limit).
+	MaxCPU    int
+	MaxMemory int
+
+	// Disk pauses tasks while the disks the run writes to are nearly full
+	// and stops them before the disks fill up (nil = no guard).
+	Disk *DiskGuard
+
+	// Clock is used for the run's start and end times and to wait out
+	// rate-limit cooldowns (nil = SystemClock).
+	Clock Clock
+
+	// Secrets are the values of the Cortexfile's secrets, by name, for
+	// {{secrets.X}} and the agents' environment. They are masked in task
+	// output, logs, and saved results.
+	Secrets map[string]string
 }
 
 // NewExecutor creates a new Executor with the given registry and store.
-func NewExecutor(registry *AgentRegistry, store *state.Store, writer io.Writer, verbose bool) *Executor {
+func NewExecutor(registry *AgentRegistry, store *state.RunStore, writer io.Writer, verbose bool) *Executor {
 	return &Executor{
 		registry:    registry,
 		store:       store,
 		outputs:     make(map[string]string),
+		backoff:     NewBackoff(),
+		budget:      NewBudget(0),
+		heartbeat:   DefaultHeartbeat,
 		verbose:     verbose,
 		writer:      writer,
 		parallel:    false,
 		maxParallel: 0,
+		clock:       SystemClock,
 	}
 }
 
 // NewExecutorWithConfig creates a new Executor with full configuration.
 func NewExecutorWithConfig(cfg ExecutorConfig) *Executor {
+	heartbeat := cfg.Heartbeat
+	if heartbeat == 0 {
+		heartbeat = DefaultHeartbeat
+	}
+	clock := cfg.Clock
+	if clock == nil {
+		clock = SystemClock
+	}
+	backoff := NewBackoff()
+	backoff.useClock(clock)
 	return &Executor{
 		registry:    cfg.Registry,
 		store:       cfg.Store,
 		outputs:     make(map[string]string),
+		backoff:     backoff,
+		budget:      NewBudget(cfg.MaxTokens),
+		load:        NewLoadGate(cfg.MaxCPU, cfg.MaxMemory),
+		disk:        cfg.Disk,
+		heartbeat:   heartbeat,
+		onProgress:  cfg.OnProgress,
 		verbose:     cfg.Verbose,
 		writer:      cfg.Writer,
 		parallel:    cfg.Parallel,
 		maxParallel: cfg.MaxParallel,
+		shell:       cfg.Shell,
+		clock:       clock,
+		secrets:     cfg.Secrets,
+		redactor:    secrets.NewRedactor(cfg.Secrets),
 	}
 }
 
+// TokensUsed returns the input and output tokens used by the run so far.
+func (e *Executor) TokensUsed() int {
+	return e.budget.Used()
+}
+
 // Execute runs all tasks in the execution plan.
 // Uses parallel execution if enabled, otherwise sequential.
 func (e *Executor) Execute(ctx context.Context, plan *planner.ExecutionPlan) (*state.RunResult, error) {
+	e.router = NewRouter(plan.AgentLimits)
+	e.preamble = plan.Preamble
+	e.variables = plan.Variables
+	e.context = plan.Context
+	e.retrieved = plan.Retrieved
+	e.prior = plan.PriorOutputs
+	e.diff = plan.Diff
+	workdir := ""
+	if len(plan.Tasks) > 0 {
+		workdir = plan.Tasks[0].Workdir
+	}
+	e.meta = runMeta(e.store.RunID(), workdir, e.clock.Now())
+
+	var result *state.RunResult
+	var err error
 	if e.parallel {
-		return e.executeParallel(ctx, plan)
+		result, err = e.executeParallel(ctx, plan)
+	} else {
+		result, err = e.executeSequential(ctx, plan)
+	}
+
+	// The digest covers failed runs too, showing how far they got
+	if plan.Digest != nil && result != nil {
+		if path, digestErr := e.writeDigest(plan, result); digestErr != nil {
+			ui.Warning("Failed to write digest: %s", digestErr)
+		} else {
+			result.DigestFile = path
+			_ = e.store.SaveRunResult(result)
+			ui.Info("Digest written to %s", path)
+		}
 	}
-	return e.executeSequential(ctx, plan)
+	return result, err
 }
 
 // executeSequential runs all tasks in the execution plan sequentially.
@@ -76,7 +197,8 @@ func (e *Executor) Execute(ctx context.Context, plan *planner.ExecutionPlan) (*s
 func (e *Executor) executeSequential(ctx context.Context, plan *planner.ExecutionPlan) (*state.RunResult, error) {
 	runResult := &state.RunResult{
 		RunID:     e.store.RunID(),
-		StartTime: time.Now(),
+		Workflow:  plan.Workflow,
+		StartTime: e.clock.Now(),
 		Tasks:     make([]state.TaskResult, 0, len(plan.Tasks)),
 		Success:   true,
 	}
@@ -91,7 +213,7 @@ func (e *Executor) executeSequential(ctx context.Context, plan *planner.Executio
 		if err != nil {
 			runResult.Tasks = append(runResult.Tasks, *taskResult)
 			runResult.Success = false
-			runResult.EndTime = time.Now()
+			runResult.EndTime = e.clock.Now()
 			_ = e.store.SaveRunResult(runResult)
 			return runResult, err
 		}
@@ -99,7 +221,7 @@ func (e *Executor) executeSequential(ctx context.Context, plan *planner.Executio
 		runResult.Tasks = append(runResult.Tasks, *taskResult)
 	}
 
-	runResult.EndTime = time.Now()
+	runResult.EndTime = e.clock.Now()
 	_ = e.store.SaveRunResult(runResult)
 
 	return runResult, nil
@@ -110,7 +232,8 @@ func (e *Executor) executeSequential(ctx context.Context, plan *planner.Executio
 func (e *Executor) executeParallel(ctx context.Context, plan *planner.ExecutionPlan) (*state.RunResult, error) {
 	runResult := &state.RunResult{
 		RunID:     e.store.RunID(),
-		StartTime: time.Now(),
+		Workflow:  plan.Workflow,
+		StartTime: e.clock.Now(),
 		Tasks:     make([]state.TaskResult, 0, len(plan.Tasks)),
 		Success:   true,
 	}
@@ -142,12 +265,18 @@ func (e *Executor) executeParallel(ctx context.Context, plan *planner.ExecutionP
 		// Channel to collect errors
 		errChan := make(chan error, len(level.Tasks))
 
-		for _, taskName := range level.Tasks {
-			execTask := taskMap[taskName]
+		// Tasks writing overlapping paths take turns
+		levelTasks := make([]planner.ExecutionTask, len(level.Tasks))
+		for i, taskName := range level.Tasks {
+			levelTasks[i] = taskMap[taskName]
+		}
+		locks := writeLocks(levelTasks)
 
+		for _, execTask := range levelTasks {
 			wg.Add(1)
 			go func(task planner.ExecutionTask) {
 				defer wg.Done()
+				defer lockWrites(locks[task.Name])()
 
 				// Acquire semaphore
 				sem <- struct{}{}
@@ -159,6 +288,13 @@ func (e *Executor) executeParallel(ctx context.Context, plan *planner.ExecutionP
 					return
 				}
 
+				// Hold back while the system is overloaded
+				if err := e.load.Acquire(ctx, task.Name); err != nil {
+					errChan <- err
+					return
+				}
+				defer e.load.Release()
+
 				// Get current task number for display (increment happens after execution)
 				taskNum := int(completedTasks.Load()) + 1
 				// Print task start
@@ -195,13 +331,13 @@ func (e *Executor) executeParallel(ctx context.Context, plan *planner.ExecutionP
 		}
 
 		if firstErr != nil {
-			runResult.EndTime = time.Now()
+			runResult.EndTime = e.clock.Now()
 			_ = e.store.SaveRunResult(runResult)
 			return runResult, firstErr
 		}
 	}
 
-	runResult.EndTime = time.Now()
+	runResult.EndTime = e.clock.Now()
 	_ = e.store.SaveRunResult(runResult)
 
 	return runResult, nil
@@ -209,32 +345,161 @@ func (e *Executor) executeParallel(ctx context.Context, plan *planner.ExecutionP
 
 // executeTask executes a single task and returns its result.
 func (e *Executor) executeTask(ctx context.Context, execTask planner.ExecutionTask) (*state.TaskResult, error) {
-	// Get the agent adapter
+	// Pick an agent with free concurrency, possibly an interchangeable one
+	declaredAgent := execTask.AgentName
+	execTask, release, err := e.route(ctx, execTask)
+	if err != nil {
+		taskResult := state.NewTaskResult(execTask.Name, execTask.AgentName, execTask.Tool, execTask.Model, "")
+		taskResult.Complete("", err.Error(), 1, false)
+		taskResult.ErrorCategory = state.ErrorCancelled
+		_ = e.store.SaveTaskResult(taskResult)
+		ui.PrintTaskStatus("Cancelled", false, "0s")
+		return taskResult, fmt.Errorf("task %q: %w", execTask.Name, err)
+	}
+	defer release()
+	if execTask.AgentName != declaredAgent {
+		ui.PrintRouted(declaredAgent, execTask.AgentName, execTask.Tool, execTask.Model)
+	}
+
+	// Get the agent adapter; compress steps run in its place, with it
+	// writing their summary
 	agent := e.registry.Get(execTask.Tool)
+	if execTask.Compress != nil && (agent != nil || execTask.Tool == config.CompressTool) {
+		agent = &compressor{e: e, step: execTask, model: agent}
+	}
 	if agent == nil {
 		taskResult := state.NewTaskResult(execTask.Name, execTask.AgentName, execTask.Tool, execTask.Model, "")
 		taskResult.Complete("", fmt.Sprintf("no adapter for tool %q", execTask.Tool), 1, false)
+		taskResult.ErrorCategory = state.ErrorFailed
 		_ = e.store.SaveTaskResult(taskResult)
 		ui.PrintTaskStatus("Failed", false, "0s")
 		return taskResult, fmt.Errorf("no adapter registered for tool %q", execTask.Tool)
 	}
 
-	// Expand template variables in prompt
+	// Don't start new work once the run's token budget is spent
+	if e.budget.Exhausted() {
+		taskResult := state.NewTaskResult(execTask.Name, execTask.AgentName, execTask.Tool, execTask.Model, "")
+		taskResult.Complete("", ErrBudgetExceeded.Error(), 1, false)
+		taskResult.ErrorCategory = state.ErrorBudget
+		_ = e.store.SaveTaskResult(taskResult)
+		printErrorCategory(taskResult.ErrorCategory)
+		ui.PrintTaskStatus("Skipped", false, "0s")
+		return taskResult, fmt.Errorf("task %q not started: run %w", execTask.Name, ErrBudgetExceeded)
+	}
+
+	// Wait for space while a disk the run writes to is nearly full
+	if err := e.disk.WaitForSpace(ctx, execTask.Name); err != nil {
+		taskResult := state.NewTaskResult(execTask.Name, execTask.AgentName, execTask.Tool, execTask.Model, "")
+		taskResult.Complete("", err.Error(), 1, false)
+		taskResult.ErrorCategory = state.ErrorCancelled
+		if errors.Is(err, ErrDiskSpace) {
+			taskResult.ErrorCategory = state.ErrorDiskSpace
+		}
+		_ = e.store.SaveTaskResult(taskResult)
+		printErrorCategory(taskResult.ErrorCategory)
+		ui.PrintTaskStatus("Skipped", false, "0s")
+		return taskResult, fmt.Errorf("task %q not started: %w", execTask.Name, err)
+	}
+
+	// Hide escaped braces from expansion until the prompt is final
+	execTask.Prompt = config.ProtectEscapes(execTask.Prompt)
+
+	// Skip tasks the changes since the base ref don't concern
+	if e.diff != nil {
+		if len(execTask.Paths) > 0 && !glob.MatchAny(execTask.Paths, e.diff.Files) {
+			return e.skipTask(execTask, "no changed files match paths"), nil
+		}
+		if execTask.Scope == config.ScopeDiff {
+			if len(e.diff.Files) == 0 {
+				return e.skipTask(execTask, "no changes since "+e.diff.Base), nil
+			}
+			execTask.Prompt = withDiffScope(e.diff, execTask)
+		}
+	}
+
+	// Skip tasks whose condition is false
+	if execTask.When != "" {
+		run, err := e.evalCondition(execTask)
+		if err != nil {
+			taskResult := state.NewTaskResult(execTask.Name, execTask.AgentName, execTask.Tool, execTask.Model, "")
+			taskResult.Complete("", "when: "+err.Error(), 1, false)
+			taskResult.ErrorCategory = state.ErrorFailed
+			_ = e.store.SaveTaskResult(taskResult)
+			ui.PrintTaskStatus("Failed", false, "0s")
+			return taskResult, fmt.Errorf("task %q: when: %w", execTask.Name, err)
+		}
+		if !run {
+			return e.skipTask(execTask, "condition is false: "+execTask.When), nil
+		}
+	}
+
+	// Prepend the run preamble and agent's instructions; later expansion
+	// and retries build on them. A compress step's prompt is the text it
+	// shortens, so it gets neither.
+	if execTask.Compress == nil {
+		execTask.Prompt = withInstructions(execTask, e.store.RunID())
+		execTask.Prompt = withPreamble(e.preamble, execTask, e.store.RunID())
+	}
+	execTask.Prompt = config.ExpandVariables(execTask.Prompt, e.variables)
+	execTask.Prompt = e.expandMeta(execTask, execTask.Prompt)
+	execTask.Prompt = config.ExpandDiff(execTask.Prompt, diffVars(e.diff))
+	execTask.Prompt = config.ExpandContext(execTask.Prompt, e.context)
+	execTask.Prompt = config.ExpandRetrieve(execTask.Prompt, e.retrieved)
+	execTask.Prompt = config.ExpandPriorOutputs(execTask.Prompt, e.prior)
+	if execTask.Memory != "" {
+		execTask.Prompt = e.withMemory(execTask.Prompt)
+	}
+
+	// Expand template variables in prompt; optional references to skipped
+	// tasks take their defaults
 	e.outputsMu.RLock()
-	expandedPrompt := config.ExpandPrompt(execTask.Prompt, e.outputs)
+	execTask.Prompt = config.ExpandDefaults(execTask.Prompt, e.skipped)
+	expandedPrompt := config.RestoreEscapes(config.ExpandPrompt(execTask.Prompt, e.outputs))
+	args := e.expandArgs(execTask)
 	e.outputsMu.RUnlock()
+	if args != nil {
+		expandedPrompt = proc.FormatArgs(args)
+	}
+	if execTask.Context != nil && execTask.Tool != "shell" {
+		expandedPrompt = withContextFiles(execTask, expandedPrompt)
+	}
 
 	// Create task for execution
+	progress := &progressWriter{}
+	output, closeLog := e.openTaskLog(execTask.Name, progress)
+	defer closeLog()
 	task := Task{
-		Name:    execTask.Name,
-		Agent:   execTask.AgentName,
-		Tool:    execTask.Tool,
-		Model:   execTask.Model,
-		Prompt:  expandedPrompt,
-		Write:   execTask.Write,
-		Workdir: execTask.Workdir,
+		Name:         execTask.Name,
+		Agent:        execTask.AgentName,
+		Tool:         execTask.Tool,
+		Model:        execTask.Model,
+		Prompt:       expandedPrompt,
+		SystemPrompt: execTask.SystemPrompt,
+		Write:        execTask.Write,
+		Workdir:      execTask.Workdir,
+		Args:         args,
+		Shell:        e.shellFor(execTask),
+		Env:          e.secretEnv(),
+		Secrets:      e.redactor,
+		Progress:     output,
 	}
 
+	// Count streamed token usage, stopping the task if a budget runs out
+	ctx, stopTask := context.WithCancelCause(ctx)
+	defer stopTask(nil)
+	defer e.disk.Watch(stopTask)()
+	defer e.watchTimeout(execTask.Timeout, stopTask)()
+	meter := &usageMeter{
+		budget:   e.budget,
+		task:     execTask.Name,
+		limit:    execTask.MaxTokens,
+		cancel:   stopTask,
+		progress: progress,
+	}
+	task.OnUsage = meter.report
+	calls := &transcript{}
+	task.OnEvent = observeAgentEvent(execTask.Name, progress, calls)
+
 	// Create result tracker
 	taskResult := state.NewTaskResult(
 		execTask.Name,
@@ -244,11 +509,73 @@ func (e *Executor) executeTask(ctx context.Context, execTask planner.ExecutionTa
 		expandedPrompt,
 	)
 
-	// Execute the task
-	result, err := agent.Run(ctx, task)
+	if execTask.AgentName != declaredAgent {
+		taskResult.RoutedFrom = declaredAgent
+	}
+	taskResult.Workdir, _ = filepath.Abs(cmp.Or(execTask.Workdir, "."))
+
+	// Let 'write: patch' tasks change a copy of the repository, keeping
+	// their changes for review
+	var patchWorkspace *Workspace
+	if execTask.WritePatch {
+		w, err := e.openPatchWorkspace(&execTask)
+		if err != nil {
+			taskResult.Complete("", err.Error(), 1, false)
+			taskResult.ErrorCategory = state.ErrorFailed
+			_ = e.store.SaveTaskResult(taskResult)
+			ui.PrintTaskStatus("Failed", false, taskResult.Duration)
+			return taskResult, fmt.Errorf("task %q: %w", execTask.Name, err)
+		}
+		defer func() {
+			if err := e.closePatchWorkspace(w); err != nil {
+				ui.Warning("Failed to remove workspace %s: %s", w.Dir, err)
+			}
+		}()
+		patchWorkspace = w
+		task.Workdir = execTask.Workdir
+	}
+
+	// Create the workdir the first time a task runs in it
+	if execTask.Workdir != "" {
+		if err := os.MkdirAll(execTask.Workdir, 0o755); err != nil {
+			taskResult.Complete("", err.Error(), 1, false)
+			taskResult.ErrorCategory = state.ErrorFailed
+			_ = e.store.SaveTaskResult(taskResult)
+			ui.PrintTaskStatus("Failed", false, taskResult.Duration)
+			return taskResult, fmt.Errorf("task %q: cannot create workdir: %w", execTask.Name, err)
+		}
+	}
+
+	// Record the working tree so the agent's changes can be measured and reverted
+	var baseline *git.Baseline
+	if needsBaseline(execTask) {
+		b, err := git.TakeBaseline(execTask.Workdir)
+		if err != nil {
+			taskResult.Complete("", err.Error(), 1, false)
+			taskResult.ErrorCategory = state.ErrorFailed
+			_ = e.store.SaveTaskResult(taskResult)
+			ui.PrintTaskStatus("Failed", false, taskResult.Duration)
+			return taskResult, fmt.Errorf("task %q: change tracking requires a git working tree: %w", execTask.Name, err)
+		}
+		baseline = b
+	} else if execTask.Write && git.IsRepo(execTask.Workdir) {
+		// Only used to attribute the task's changes in the run's provenance
+		baseline, _ = git.TakeBaseline(execTask.Workdir)
+	}
+
+	// Execute the task, reporting progress while it runs
+	stopHeartbeat := e.startHeartbeat(task, progress)
+	defer stopHeartbeat()
+
+	result, err := e.runWithRetries(ctx, agent, task, execTask, taskResult)
 	if err != nil {
+		stopHeartbeat()
+		meter.settle(result)
+		taskResult.Transcript = calls.entries()
 		taskResult.Complete("", err.Error(), 1, false)
+		taskResult.ErrorCategory = ClassifyError(ctx, execTask.Tool, result, err)
 		_ = e.store.SaveTaskResult(taskResult)
+		printErrorCategory(taskResult.ErrorCategory)
 		ui.PrintTaskStatus("Failed", false, taskResult.Duration)
 		if e.verbose {
 			fmt.Fprintf(e.writer, "  %sError:%s %s\n", ui.Dim, ui.Reset, err)
@@ -256,12 +583,115 @@ func (e *Executor) executeTask(ctx context.Context, execTask planner.ExecutionTa
 		return taskResult, fmt.Errorf("task %q failed: %w", execTask.Name, err)
 	}
 
+	// Recover from a context overflow by retrying with a reduced prompt
+	if !result.Success && execTask.ContextOverflow != config.OverflowFail &&
+		ClassifyError(ctx, execTask.Tool, result, nil) == state.ErrorContextOverflow {
+		task, result = e.recoverContextOverflow(ctx, agent, task, execTask, taskResult, result)
+	}
+
+	// Verify the agent's changes, letting it fix failures if configured
+	if result.Success && execTask.Verify != "" {
+		result = e.verifyTask(ctx, agent, task, execTask, taskResult, result)
+	}
+
+	// Check the output has the expected format, letting the agent fix it if configured
+	var expected any
+	if result.Success && execTask.Expect != "" {
+		result, expected = e.expectOutput(ctx, agent, task, execTask, taskResult, result)
+	}
+
+	stopHeartbeat()
+	result = estimateUsage(execTask, task.Prompt, result)
+	meter.settle(result)
+
+	// Clean up terminal noise, then mask secrets the agent printed, before
+	// its output goes anywhere, dependent tasks' prompts included
+	steps := normalizeSteps(execTask.Normalize)
+	result.Stdout, result.Stderr = normalize.Text(result.Stdout, steps), normalize.Text(result.Stderr, steps)
+	result.Stdout, result.Stderr = e.redactor.Redact(result.Stdout), e.redactor.Redact(result.Stderr)
+
+	// Dependent tasks see the parsed output of 'expect: json' tasks
+	stored := result.Stdout
+	if taskResult.Expectation != nil && taskResult.Expectation.Valid {
+		if structured, err := encodeStructured(expected, e.redactor); err != nil {
+			ui.Warning("Failed to encode the task's JSON output: %s", err)
+		} else {
+			taskResult.StructuredOutput = structured
+			stored = string(structured)
+		}
+	}
+
+	// Enforce change limits, reverting runaway edits
+	var limitErr error
+	if baseline != nil && hasChangeLimits(execTask) {
+		if limitErr = enforceChangeLimits(execTask, baseline, taskResult); limitErr != nil {
+			result.Success = false
+			if result.ExitCode == 0 {
+				result.ExitCode = 1
+			}
+			result.Stderr += "\n" + limitErr.Error()
+		}
+	}
+
+	// Record what the task changed for the run's provenance
+	if result.Success && baseline != nil && taskResult.Changes == nil {
+		if err := recordChanges(baseline, taskResult); err != nil {
+			ui.Warning("Failed to record changes: %s", err)
+		}
+	}
+
+	// Commit the verified changes on the run branch
+	var commitErr error
+	if result.Success && baseline != nil && execTask.Commit != nil {
+		if commitErr = e.commitChanges(ctx, execTask, baseline, taskResult); commitErr != nil {
+			result.Success = false
+			if result.ExitCode == 0 {
+				result.ExitCode = 1
+			}
+			result.Stderr += "\n" + commitErr.Error()
+		}
+	}
+
+	// Save the changes of a 'write: patch' task, failed ones too, for review
+	if patchWorkspace != nil {
+		if name, err := e.savePatch(patchWorkspace, execTask.Name); err != nil {
+			ui.Warning("Failed to save the task's changes: %s", err)
+		} else if name != "" {
+			taskResult.Patch = name
+			ui.Info("Changes saved to %s; apply them with: cortex apply %s %s",
+				filepath.Join(e.store.RunDir(), name), e.store.RunID(), execTask.Name)
+		}
+	}
+
 	// Complete the task result
+	taskResult.Transcript = calls.entries()
 	taskResult.Complete(result.Stdout, result.Stderr, result.ExitCode, result.Success)
+	if !result.Success {
+		if limitErr != nil || commitErr != nil || (taskResult.Verification != nil && !taskResult.Verification.Success) ||
+			(taskResult.Expectation != nil && !taskResult.Expectation.Valid) {
+			taskResult.ErrorCategory = state.ErrorFailed
+		} else {
+			taskResult.ErrorCategory = ClassifyError(ctx, execTask.Tool, result, nil)
+		}
+	}
 
 	// Set token usage if available
 	if result.InputTokens > 0 || result.OutputTokens > 0 {
 		taskResult.SetTokenUsage(result.InputTokens, result.OutputTokens, result.CacheRead, result.CacheWrite)
+		taskResult.TokenUsage.Estimated = result.TokensEstimated
+	}
+	taskResult.TokenUsage.CostUSD = result.CostUSD
+	if r := result.Resources; r != (proc.Usage{}) {
+		taskResult.SetResources(r.UserCPU, r.SystemCPU, r.PeakMemory, r.Processes)
+	}
+
+	// Keep a copy of the output where the task asked for one
+	if result.Success && execTask.OutputFile != "" {
+		if path, err := e.writeOutputFile(execTask, stored); err != nil {
+			ui.Warning("Failed to write output file: %s", err)
+		} else {
+			taskResult.OutputFile = path
+		}
 	}
 
 	// Save task result
@@ -271,9 +701,16 @@ func (e *Executor) executeTask(ctx context.Context, execTask planner.ExecutionTa
 
 	// Store output for template expansion in dependent tasks
 	e.outputsMu.Lock()
-	e.outputs[execTask.Name] = result.Stdout
+	e.outputs[execTask.Name] = stored
 	e.outputsMu.Unlock()
 
+	// Record what the task learned for future runs
+	if result.Success && execTask.Memory == config.MemoryWrite {
+		if err := e.store.AppendMemory(execTask.Name, result.Stdout); err != nil {
+			ui.Warning("Failed to update memory: %s", err)
+		}
+	}
+
 	if result.Success {
 		if result.InputTokens > 0 || result.OutputTokens > 0 {
 			ui.PrintTaskStatusWithTokens("Success", true, taskResult.Duration, result.InputTokens, result.OutputTokens)
@@ -281,11 +718,31 @@ func (e *Executor) executeTask(ctx context.Context, execTask planner.ExecutionTa
 			ui.PrintTaskStatus("Success", true, taskResult.Duration)
 		}
 	} else {
+		printErrorCategory(taskResult.ErrorCategory)
 		if result.InputTokens > 0 || result.OutputTokens > 0 {
 			ui.PrintTaskStatusWithTokens("Failed", false, taskResult.Duration, result.InputTokens, result.OutputTokens)
 		} else {
 			ui.PrintTaskStatus("Failed", false, taskResult.Duration)
 		}
+		if taskResult.ErrorCategory == state.ErrorBudget {
+			return taskResult, fmt.Errorf("task %q stopped: %w", execTask.Name, ErrBudgetExceeded)
+		}
+		if taskResult.ErrorCategory == state.ErrorDiskSpace || taskResult.ErrorCategory == state.ErrorTimeout {
+			return taskResult, fmt.Errorf("task %q stopped: %w", execTask.Name, context.Cause(ctx))
+		}
+		if limitErr != nil {
+			return taskResult, fmt.Errorf("task %q exceeded change limits: %w", execTask.Name, limitErr)
+		}
+		if commitErr != nil {
+			return taskResult, fmt.Errorf("task %q: failed to commit changes: %w", execTask.Name, commitErr)
+		}
+		if taskResult.Verification != nil && !taskResult.Verification.Success {
+			return taskResult, fmt.Errorf("task %q failed verification: %s exited with code %d",
+				execTask.Name, taskResult.Verification.Command, taskResult.Verification.ExitCode)
+		}
+		if x := taskResult.Expectation; x != nil && !x.Valid && len(x.Errors) > 0 {
+			return taskResult, fmt.Errorf("task %q output didn't match 'expect: %s': %s", execTask.Name, x.Format, x.Errors[0])
+		}
 		return taskResult, fmt.Errorf("task %q failed with exit code %d", execTask.Name, result.ExitCode)
 	}
 
@@ -301,6 +758,53 @@ func (e *Executor) executeTask(ctx context.Context, execTask planner.ExecutionTa
 	return taskResult, nil
 }
 
+// verifyTask runs the task's verify command and, while it fails, re-runs the
+// agent with the failure report up to FixAttempts times.
+// Returns the final agent result, marked unsuccessful if verification never passed.
+func (e *Executor) verifyTask(ctx context.Context, agent Agent, task Task, execTask planner.ExecutionTask, taskResult *state.TaskResult, result Result) Result {
+	for attempt := 0; ; attempt++ {
+		verify := runVerify(ctx, execTask, e.shellFor(execTask), task.Env)
+		verify.Attempts = attempt + 1
+		taskResult.Verification = &verify
+		ui.PrintVerifyStatus(verify.Command, verify.Success, verify.ExitCode)
+
+		if verify.Success || attempt >= execTask.FixAttempts || ctx.Err() != nil {
+			break
+		}
+
+		// Fix loop: hand the failure back to the agent
+		fixTask := task
+		fixTask.Prompt = buildFixPrompt(task.Prompt, verify)
+		fixResult, err := e.runAgent(ctx, agent, fixTask)
+		fixResult = addUsage(fixResult, result)
+		if err != nil {
+			fixResult.Stderr = err.Error()
+			fixResult.ExitCode = 1
+			fixResult.Success = false
+		}
+		result = fixResult
+		if !result.Success {
+			return result
+		}
+	}
+
+	if !taskResult.Verification.Success {
+		result.Success = false
+		if result.ExitCode == 0 {
+			result.ExitCode = taskResult.Verification.ExitCode
+		}
+	}
+	return result
+}
+
+// printErrorCategory shows why a task failed when the category is more
+// specific than a plain non-zero exit.
+func printErrorCategory(category state.ErrorCategory) {
+	if hint := ErrorHint(category); hint != "" {
+		ui.PrintErrorCategory(string(category), category.Code(), hint)
+	}
+}
+
 // truncateLines returns the first n lines of text.
 func truncateLines(text string, n int) []string {
 	var lines []string
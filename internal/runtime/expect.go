@@ -0,0 +1,192 @@
+package runtime
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/adityaraj/agentflow/internal/jsonschema"
+	"github.com/adityaraj/agentflow/internal/normalize"
+	"github.com/adityaraj/agentflow/internal/planner"
+	"github.com/adityaraj/agentflow/internal/secrets"
+	"github.com/adityaraj/agentflow/internal/state"
+	"github.com/adityaraj/agentflow/internal/ui"
+)
+
+// maxExpectProblems caps the problems listed in a retry prompt.
+const maxExpectProblems = 20
+
+// expectOutput checks the agent's output against the task's expected
+// format and schema and, while it doesn't match, re-runs the agent with
+// what's wrong up to ExpectRetries times. Returns the final agent result,
+// marked unsuccessful if its output never matched, and the parsed output.
+func (e *Executor) expectOutput(ctx context.Context, agent Agent, task Task, execTask planner.ExecutionTask, taskResult *state.TaskResult, result Result) (Result, any) {
+	var schema *jsonschema.Schema
+	if len(execTask.Schema) > 0 {
+		s, err := jsonschema.Compile(execTask.Schema)
+		if err != nil { // Caught by validation
+			taskResult.Expectation = &state.ExpectResult{Format: execTask.Expect, Errors: []string{"schema: " + err.Error()}}
+			return failExpect(result), nil
+		}
+		schema = s
+	}
+
+	steps := normalizeSteps(execTask.Normalize)
+	for attempt := 0; ; attempt++ {
+		value, problems := checkJSON(normalize.Text(result.Stdout, steps), schema)
+		taskResult.Expectation = &state.ExpectResult{
+			Format:   execTask.Expect,
+			Valid:    len(problems) == 0,
+			Attempts: attempt + 1,
+			Errors:   problems,
+		}
+		first := ""
+		if len(problems) > 0 {
+			first = problems[0]
+		}
+		ui.PrintExpectStatus(execTask.Expect, len(problems) == 0, first)
+
+		if len(problems) == 0 {
+			return result, value
+		}
+		if attempt >= execTask.ExpectRetries || ctx.Err() != nil {
+			return failExpect(result), nil
+		}
+
+		// Hand the problems back to the agent
+		retryTask := task
+		retryTask.Prompt = buildExpectPrompt(task.Prompt, problems, execTask.Schema)
+		retryResult, err := e.runAgent(ctx, agent, retryTask)
+		retryResult = addUsage(retryResult, result)
+		if err != nil {
+			retryResult.Stderr = err.Error()
+			retryResult.ExitCode = 1
+			retryResult.Success = false
+		}
+		result = retryResult
+		if !result.Success {
+			return result, nil
+		}
+	}
+}
+
+// failExpect marks result unsuccessful for output that didn't match.
+func failExpect(result Result) Result {
+	result.Success = false
+	if result.ExitCode == 0 {
+		result.ExitCode = 1
+	}
+	return result
+}
+
+// checkJSON parses the JSON value in output and checks it against schema,
+// if any. It returns the value, or what's wrong with the output.
+func checkJSON(output string, schema *jsonschema.Schema) (any, []string) {
+	value, err := parseJSONOutput(output)
+	if err != nil {
+		return nil, []string{err.Error()}
+	}
+	if schema == nil {
+		return value, nil
+	}
+	var problems []string
+	for _, v := range schema.Validate(value) {
+		problems = append(problems, v.String())
+	}
+	return value, problems
+}
+
+// parseJSONOutput returns the JSON value in an agent's output: all of it,
+// the first fenced code block, or the text from the first { or [ to the
+// last } or ], since agents often wrap JSON in prose or markdown.
+func parseJSONOutput(output string) (any, error) {
+	text := strings.TrimSpace(output)
+	if text == "" {
+		return nil, errors.New("output is empty, expected a JSON value")
+	}
+	candidates := []string{text}
+	if _, rest, ok := strings.Cut(text, "```"); ok {
+		// Skip the fence's language, e.g. ```json
+		if _, body, ok := strings.Cut(rest, "\n"); ok {
+			if block, _, ok := strings.Cut(body, "```"); ok {
+				candidates = append(candidates, block)
+			}
+		}
+	}
+	if start := strings.IndexAny(text, "{["); start >= 0 {
+		closing := "}"
+		if text[start] == '[' {
+			closing = "]"
+		}
+		if end := strings.LastIndex(text, closing); end > start {
+			candidates = append(candidates, text[start:end+1])
+		}
+	}
+
+	var firstErr error
+	for _, c := range candidates {
+		value, err := decodeJSON(c)
+		if err == nil {
+			return value, nil
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	return nil, fmt.Errorf("output is not valid JSON: %w", firstErr)
+}
+
+// decodeJSON decodes text as exactly one JSON value, keeping numbers as
+// written.
+func decodeJSON(text string) (any, error) {
+	dec := json.NewDecoder(strings.NewReader(text))
+	dec.UseNumber()
+	var value any
+	if err := dec.Decode(&value); err != nil {
+		return nil, err
+	}
+	if _, err := dec.Token(); !errors.Is(err, io.EOF) {
+		return nil, errors.New("unexpected text after the JSON value")
+	}
+	return value, nil
+}
+
+// encodeStructured encodes the parsed output of an 'expect: json' task as
+// it's stored and passed to dependent tasks, with secrets masked.
+func encodeStructured(value any, redactor *secrets.Redactor) (json.RawMessage, error) {
+	var b strings.Builder
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(value); err != nil {
+		return nil, err
+	}
+	data := []byte(redactor.Redact(strings.TrimSuffix(b.String(), "\n")))
+	if !json.Valid(data) {
+		return nil, errors.New("masking secrets made it invalid")
+	}
+	return data, nil
+}
+
+// buildExpectPrompt appends the problems with the previous output, and
+// the schema it must match, to the task's prompt.
+func buildExpectPrompt(prompt string, problems []string, schema map[string]any) string {
+	if len(problems) > maxExpectProblems {
+		problems = append(problems[:maxExpectProblems:maxExpectProblems], fmt.Sprintf("and %d more", len(problems)-maxExpectProblems))
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s\n\n---\nYour previous output was not the JSON expected:\n", prompt)
+	for _, p := range problems {
+		fmt.Fprintf(&b, "- %s\n", p)
+	}
+	b.WriteString("\nReply with only the JSON value")
+	if data, err := json.MarshalIndent(schema, "", "  "); err == nil && schema != nil {
+		fmt.Fprintf(&b, ", matching this JSON Schema:\n%s", data)
+	} else {
+		b.WriteString(".")
+	}
+	return b.String()
+}
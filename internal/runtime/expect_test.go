@@ -0,0 +1,91 @@
+package runtime_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/adityaraj/agentflow/internal/config"
+	"github.com/adityaraj/agentflow/internal/runtime/runtimetest"
+)
+
+// TestExpectJSON tests that invalid JSON output is handed back to the
+// agent, and that dependents see the parsed output and its fields.
+func TestExpectJSON(t *testing.T) {
+	h := runtimetest.New(t)
+	h.Agent.On("plan",
+		runtimetest.OK("Sure!\n```json\n{\"files\": \"a.go\"}\n```"),
+		runtimetest.OK("Here you go: {\"files\": [\"a.go\", \"b.go\"], \"risk\": \"<low>\"}"))
+	h.Agent.On("review", runtimetest.OK("ok"))
+
+	cfg := &config.AgentflowConfig{
+		Agents: map[string]config.AgentConfig{"ai": {Tool: "claude-code"}},
+		Tasks: map[string]config.TaskConfig{
+			"plan": {
+				Agent:  "ai",
+				Prompt: "Plan",
+				Expect: config.ExpectJSON,
+				Schema: map[string]any{
+					"type":       "object",
+					"required":   []any{"files"},
+					"properties": map[string]any{"files": map[string]any{"type": "array", "items": map[string]any{"type": "string"}}},
+				},
+				ExpectRetries: 1,
+			},
+			"review": {
+				Agent:  "ai",
+				Needs:  []string{"plan"},
+				Prompt: `{{outputs.plan.files}} {{outputs.plan.files.1}} {{outputs.plan.risk}} {{outputs.plan.owner | default "nobody"}}`,
+			},
+		},
+	}
+	run, err := h.Run(context.Background(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	calls := h.Agent.Calls("plan")
+	if len(calls) != 2 {
+		t.Fatalf("plan ran %d times, want a retry after the invalid output", len(calls))
+	}
+	if !strings.Contains(calls[1].Prompt, "$.files: expected array, got string") ||
+		!strings.Contains(calls[1].Prompt, `"required": [`) {
+		t.Errorf("retry prompt = %q, want the problem and the schema", calls[1].Prompt)
+	}
+	if prompt := h.Agent.Calls("review")[0].Prompt; prompt != `["a.go","b.go"] b.go <low> nobody` {
+		t.Errorf("review prompt = %q", prompt)
+	}
+	for _, r := range run.Tasks {
+		if r.TaskName != "plan" {
+			continue
+		}
+		if r.Expectation == nil || !r.Expectation.Valid || r.Expectation.Attempts != 2 {
+			t.Errorf("expectation = %+v, want valid after 2 attempts", r.Expectation)
+		}
+		want := "{\n  \"files\": [\n    \"a.go\",\n    \"b.go\"\n  ],\n  \"risk\": \"<low>\"\n}"
+		if string(r.StructuredOutput) != want {
+			t.Errorf("structured output = %s, want %s", r.StructuredOutput, want)
+		}
+	}
+}
+
+// TestExpectJSONInvalid tests that a task fails when its output never
+// matches.
+func TestExpectJSONInvalid(t *testing.T) {
+	h := runtimetest.New(t)
+	h.Agent.On("plan", runtimetest.OK("I couldn't find any files."))
+
+	cfg := &config.AgentflowConfig{
+		Agents: map[string]config.AgentConfig{"ai": {Tool: "claude-code"}},
+		Tasks: map[string]config.TaskConfig{
+			"plan": {Agent: "ai", Prompt: "Plan", Expect: config.ExpectJSON},
+		},
+	}
+	run, err := h.Run(context.Background(), cfg)
+	if err == nil || !strings.Contains(err.Error(), "output is not valid JSON") {
+		t.Fatalf("err = %v, want the output rejected", err)
+	}
+	if r := run.Tasks[0]; r.Success || r.Expectation == nil || r.Expectation.Valid || r.StructuredOutput != nil {
+		t.Errorf("plan = %+v, want it failed with an invalid expectation", r)
+	}
+}
@@ -0,0 +1,215 @@
+package runtime
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/adityaraj/agentflow/internal/observability"
+	"github.com/adityaraj/agentflow/internal/ui"
+)
+
+const (
+	// DefaultHeartbeat is how long a task runs before its first progress
+	// event, and the interval between later ones.
+	DefaultHeartbeat = 30 * time.Second
+
+	// maxLastLine caps the length of the last output line in progress events.
+	maxLastLine = 120
+
+	// maxPartialLine caps how much of an unterminated line is buffered.
+	maxPartialLine = 4096
+)
+
+// ansiRegex matches terminal color and cursor escape sequences.
+var ansiRegex = regexp.MustCompile(`\x1b\[[0-9;?]*[A-Za-z]`)
+
+// ProgressEvent reports that a task is still running.
+type ProgressEvent struct {
+	Task     string
+	Agent    string
+	Tool     string
+	Model    string
+	Elapsed  time.Duration
+	Bytes    int64  // Output bytes produced so far
+	Tokens   int    // Input and output tokens reported so far (streaming tools only)
+	Activity string // Most recent tool call, e.g. "Edit main.go" (structured tools only)
+	Edits    int    // File edits made so far (structured tools only)
+	LastLine string // Last non-empty output line, if any
+}
+
+// progressWriter receives a copy of agent output, counting bytes and
+// remembering the last non-empty line. Streamed token usage is added to it
+// by the task's usage meter.
+type progressWriter struct {
+	mu       sync.Mutex
+	bytes    int64
+	tokens   int
+	activity string
+	edits    int
+	partial  []byte // Output after the last newline
+	lastLine string
+}
+
+// Write records output; it never fails so it can't disturb the agent.
+func (p *progressWriter) Write(b []byte) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.bytes += int64(len(b))
+	p.partial = append(p.partial, b...)
+
+	if i := bytes.LastIndexByte(p.partial, '\n'); i >= 0 {
+		lines := strings.Split(string(p.partial[:i]), "\n")
+		for j := len(lines) - 1; j >= 0; j-- {
+			if line := cleanLine(lines[j]); line != "" {
+				p.lastLine = line
+				break
+			}
+		}
+		p.partial = append(p.partial[:0], p.partial[i+1:]...)
+	}
+	if len(p.partial) > maxPartialLine {
+		p.partial = append(p.partial[:0], p.partial[len(p.partial)-maxPartialLine:]...)
+	}
+	return len(b), nil
+}
+
+// addTokens records streamed token usage for progress reports.
+func (p *progressWriter) addTokens(n int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.tokens += n
+}
+
+// observe records what the agent is doing from a structured event.
+func (p *progressWriter) observe(ev AgentEvent) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	switch ev.Type {
+	case AgentEventToolUse:
+		p.activity = cleanLine(strings.TrimSpace(ev.Tool + " " + ev.Summary))
+	case AgentEventFileEdit:
+		p.edits++
+	}
+}
+
+// snapshot fills in the progress fields of ev: bytes written, tokens used,
+// current activity, and the most recent non-empty line (including a line
+// still being written).
+func (p *progressWriter) snapshot(ev *ProgressEvent) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	ev.Bytes, ev.Tokens = p.bytes, p.tokens
+	ev.Activity, ev.Edits = p.activity, p.edits
+	ev.LastLine = p.lastLine
+	if line := cleanLine(string(p.partial)); line != "" {
+		ev.LastLine = line
+	}
+}
+
+// cleanLine strips escape sequences and whitespace and shortens the line.
+func cleanLine(line string) string {
+	line = strings.TrimSpace(ansiRegex.ReplaceAllString(line, ""))
+	if utf8.RuneCountInString(line) > maxLastLine {
+		line = string([]rune(line)[:maxLastLine]) + "…"
+	}
+	return line
+}
+
+// startHeartbeat emits a progress event for task every heartbeat interval
+// until the returned stop function is called. Stop is safe to call twice.
+func (e *Executor) startHeartbeat(task Task, progress *progressWriter) func() {
+	if e.heartbeat <= 0 {
+		return func() {}
+	}
+
+	start := time.Now()
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(e.heartbeat)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				ev := ProgressEvent{
+					Task:    task.Name,
+					Agent:   task.Agent,
+					Tool:    task.Tool,
+					Model:   task.Model,
+					Elapsed: time.Since(start),
+				}
+				progress.snapshot(&ev)
+				e.emitProgress(ev)
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			close(done)
+			<-stopped
+		})
+	}
+}
+
+// emitProgress surfaces a progress event in the terminal, the structured
+// log, and the executor's progress callback.
+func (e *Executor) emitProgress(ev ProgressEvent) {
+	ui.PrintHeartbeat(ui.Heartbeat{
+		Task:     ev.Task,
+		Elapsed:  ev.Elapsed,
+		Bytes:    ev.Bytes,
+		Tokens:   ev.Tokens,
+		Activity: ev.Activity,
+		Edits:    ev.Edits,
+		LastLine: ev.LastLine,
+	})
+
+	observability.Info("Task still running",
+		observability.WithTask(ev.Task),
+		observability.WithEvent(observability.EventTaskProgress),
+		observability.WithData(observability.ProgressData{
+			Elapsed:  ev.Elapsed.Round(time.Second).String(),
+			Bytes:    ev.Bytes,
+			Tokens:   ev.Tokens,
+			Activity: ev.Activity,
+			Edits:    ev.Edits,
+			LastLine: ev.LastLine,
+		}),
+	)
+
+	if e.onProgress != nil {
+		e.onProgress(ev)
+	}
+}
+
+// observeAgentEvent returns the OnEvent callback for a task: structured
+// events update its progress and transcript and are written to the debug log.
+func observeAgentEvent(taskName string, progress *progressWriter, calls *transcript) func(AgentEvent) {
+	return func(ev AgentEvent) {
+		progress.observe(ev)
+		calls.record(ev)
+		if ev.Type == AgentEventText || ev.Type == AgentEventToolResult {
+			return
+		}
+		observability.Debug("Agent "+string(ev.Type),
+			observability.WithTask(taskName),
+			observability.WithEvent(observability.EventAgentAction),
+			observability.WithData(observability.AgentActionData{
+				Type:    string(ev.Type),
+				Tool:    ev.Tool,
+				Summary: ev.Summary,
+				Path:    ev.Path,
+			}),
+		)
+	}
+}
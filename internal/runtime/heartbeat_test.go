@@ -0,0 +1,89 @@
+package runtime
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestProgressWriter(t *testing.T) {
+	p := &progressWriter{}
+	p.Write([]byte("first line\nsecond "))
+	p.Write([]byte("line\n\n  \n"))
+
+	var ev ProgressEvent
+	p.snapshot(&ev)
+	if ev.Bytes != 27 {
+		t.Errorf("bytes = %d, want 27", ev.Bytes)
+	}
+	if ev.LastLine != "second line" {
+		t.Errorf("last line = %q, want %q", ev.LastLine, "second line")
+	}
+
+	// A line still being written is reported, without escape codes
+	p.Write([]byte("\x1b[33m  ⚡ Edit\x1b[0m main.go"))
+	if p.snapshot(&ev); ev.LastLine != "⚡ Edit main.go" {
+		t.Errorf("last line = %q, want partial line", ev.LastLine)
+	}
+
+	p.Write([]byte(strings.Repeat("x", 2*maxPartialLine)))
+	if len(p.partial) > maxPartialLine {
+		t.Errorf("partial line grew to %d bytes", len(p.partial))
+	}
+	if p.snapshot(&ev); len([]rune(ev.LastLine)) != maxLastLine+1 {
+		t.Errorf("last line not shortened: %d runes", len([]rune(ev.LastLine)))
+	}
+}
+
+func TestProgressWriter_Observe(t *testing.T) {
+	p := &progressWriter{}
+	p.observe(AgentEvent{Type: AgentEventToolUse, Tool: "Bash", Summary: "go test ./..."})
+	p.observe(AgentEvent{Type: AgentEventToolUse, Tool: "Edit", Summary: "main.go"})
+	p.observe(AgentEvent{Type: AgentEventFileEdit, Tool: "Edit", Path: "main.go"})
+	p.observe(AgentEvent{Type: AgentEventText, Text: "Done."})
+
+	var ev ProgressEvent
+	p.snapshot(&ev)
+	if ev.Activity != "Edit main.go" || ev.Edits != 1 {
+		t.Errorf("activity = %q, edits = %d; want \"Edit main.go\", 1", ev.Activity, ev.Edits)
+	}
+}
+
+func TestStartHeartbeat(t *testing.T) {
+	var mu sync.Mutex
+	var events []ProgressEvent
+	e := &Executor{
+		heartbeat: 10 * time.Millisecond,
+		onProgress: func(ev ProgressEvent) {
+			mu.Lock()
+			events = append(events, ev)
+			mu.Unlock()
+		},
+	}
+	progress := &progressWriter{}
+	progress.Write([]byte("working\n"))
+
+	stop := e.startHeartbeat(Task{Name: "slow", Agent: "coder"}, progress)
+	time.Sleep(35 * time.Millisecond)
+	stop()
+	stop()
+
+	mu.Lock()
+	count := len(events)
+	mu.Unlock()
+	if count == 0 {
+		t.Fatal("expected progress events")
+	}
+	ev := events[0]
+	if ev.Task != "slow" || ev.Bytes != 8 || ev.LastLine != "working" || ev.Elapsed <= 0 {
+		t.Errorf("event = %+v", ev)
+	}
+
+	time.Sleep(25 * time.Millisecond)
+	mu.Lock()
+	defer mu.Unlock()
+	if len(events) != count {
+		t.Error("events emitted after stop")
+	}
+}
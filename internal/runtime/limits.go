@@ -0,0 +1,63 @@
+package runtime
+
+import (
+	"fmt"
+
+	"github.com/adityaraj/agentflow/internal/git"
+	"github.com/adityaraj/agentflow/internal/planner"
+	"github.com/adityaraj/agentflow/internal/state"
+)
+
+// hasChangeLimits reports whether a task's changes must be measured against a baseline.
+func hasChangeLimits(task planner.ExecutionTask) bool {
+	return task.Write && (task.MaxFiles > 0 || task.MaxLines > 0)
+}
+
+// recordChanges sets taskResult.Changes to the files changed since baseline.
+func recordChanges(baseline *git.Baseline, taskResult *state.TaskResult) error {
+	changes, err := baseline.Changes()
+	if err != nil {
+		return err
+	}
+	_, lines := git.SummarizeChanges(changes)
+	summary := &state.ChangeSummary{Lines: lines}
+	for _, c := range changes {
+		summary.Files = append(summary.Files, c.Path)
+	}
+	taskResult.Changes = summary
+	return nil
+}
+
+// enforceChangeLimits measures the agent's changes against the baseline and
+// reverts them if they exceed the task's limits.
+// Returns a non-nil error describing the violation when changes were reverted.
+func enforceChangeLimits(task planner.ExecutionTask, baseline *git.Baseline, taskResult *state.TaskResult) error {
+	changes, err := baseline.Changes()
+	if err != nil {
+		return fmt.Errorf("failed to measure changes: %w", err)
+	}
+
+	files, lines := git.SummarizeChanges(changes)
+	summary := &state.ChangeSummary{Lines: lines}
+	for _, c := range changes {
+		summary.Files = append(summary.Files, c.Path)
+	}
+	taskResult.Changes = summary
+
+	var violation string
+	if task.MaxFiles > 0 && files > task.MaxFiles {
+		violation = fmt.Sprintf("changed %d files (limit %d)", files, task.MaxFiles)
+	} else if task.MaxLines > 0 && lines > task.MaxLines {
+		violation = fmt.Sprintf("changed %d lines (limit %d)", lines, task.MaxLines)
+	}
+	if violation == "" {
+		return nil
+	}
+
+	if err := baseline.Restore(changes); err != nil {
+		return fmt.Errorf("%s and reverting failed: %w", violation, err)
+	}
+	summary.Reverted = true
+
+	return fmt.Errorf("%s; changes were reverted", violation)
+}
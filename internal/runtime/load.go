@@ -0,0 +1,103 @@
+package runtime
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/adityaraj/agentflow/internal/sysload"
+	"github.com/adityaraj/agentflow/internal/ui"
+)
+
+// DefaultLoadPoll is how often a deferred task rechecks the system load.
+const DefaultLoadPoll = 2 * time.Second
+
+// LoadGate defers starting parallel tasks while system CPU or memory use is
+// above a threshold. A task is never deferred while no other task is
+// running, so a machine that is busy for other reasons slows the run to one
+// task at a time instead of stalling it.
+type LoadGate struct {
+	maxCPU    float64 // Percent (0 = no limit)
+	maxMemory float64 // Percent (0 = no limit)
+	poll      time.Duration
+	sample    func() (sysload.Load, error)
+
+	admit   sync.Mutex // Admits one task at a time, so each sees the load of the last
+	off     bool       // Load can't be measured; guarded by admit
+	mu      sync.Mutex
+	running int
+}
+
+// NewLoadGate creates a LoadGate with CPU and memory thresholds in percent.
+// Returns nil (no gating) if both are 0.
+func NewLoadGate(maxCPU, maxMemory int) *LoadGate {
+	if maxCPU <= 0 && maxMemory <= 0 {
+		return nil
+	}
+	return &LoadGate{
+		maxCPU:    float64(maxCPU),
+		maxMemory: float64(maxMemory),
+		poll:      DefaultLoadPoll,
+		sample:    sysload.NewSampler().Sample,
+	}
+}
+
+// Acquire waits until task may start, then counts it as running until
+// Release. It returns early with the context's error if ctx is done. If the
+// load can't be measured, tasks start without waiting.
+func (g *LoadGate) Acquire(ctx context.Context, task string) error {
+	if g == nil {
+		return nil
+	}
+	g.admit.Lock()
+	defer g.admit.Unlock()
+
+	announced := false
+	for !g.off && g.busy() {
+		load, err := g.sample()
+		if err != nil {
+			ui.Warning("Can't measure system load, so max_cpu and max_memory are ignored: %s", err)
+			g.off = true
+			break
+		}
+		if !g.overloaded(load) {
+			break
+		}
+		if !announced {
+			ui.PrintLoadDeferred(task, load.CPU, load.Memory)
+			announced = true
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(g.poll):
+		}
+	}
+
+	g.mu.Lock()
+	g.running++
+	g.mu.Unlock()
+	return nil
+}
+
+// Release marks a task acquired with Acquire as finished.
+func (g *LoadGate) Release() {
+	if g == nil {
+		return
+	}
+	g.mu.Lock()
+	g.running--
+	g.mu.Unlock()
+}
+
+// busy reports whether any task is running.
+func (g *LoadGate) busy() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.running > 0
+}
+
+// overloaded reports whether load is above either threshold.
+func (g *LoadGate) overloaded(load sysload.Load) bool {
+	return (g.maxCPU > 0 && load.CPU > g.maxCPU) || (g.maxMemory > 0 && load.Memory > g.maxMemory)
+}
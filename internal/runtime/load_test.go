@@ -0,0 +1,113 @@
+package runtime
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/adityaraj/agentflow/internal/sysload"
+)
+
+func TestNewLoadGate_Disabled(t *testing.T) {
+	if g := NewLoadGate(0, 0); g != nil {
+		t.Fatal("NewLoadGate(0, 0) should disable gating")
+	}
+	// A nil gate never blocks
+	var g *LoadGate
+	if err := g.Acquire(context.Background(), "a"); err != nil {
+		t.Fatal(err)
+	}
+	g.Release()
+}
+
+func TestLoadGate_DefersWhileOverloaded(t *testing.T) {
+	var cpu atomic.Int64
+	cpu.Store(95)
+	g := NewLoadGate(80, 0)
+	g.poll = 10 * time.Millisecond
+	g.sample = func() (sysload.Load, error) {
+		return sysload.Load{CPU: float64(cpu.Load()), Memory: 50}, nil
+	}
+	ctx := context.Background()
+
+	// The first task starts even on a busy system
+	if err := g.Acquire(ctx, "a"); err != nil {
+		t.Fatal(err)
+	}
+
+	started := make(chan struct{})
+	go func() {
+		_ = g.Acquire(ctx, "b")
+		close(started)
+	}()
+	select {
+	case <-started:
+		t.Fatal("second task started while CPU was over the limit")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	cpu.Store(40)
+	select {
+	case <-started:
+	case <-time.After(time.Second):
+		t.Fatal("second task didn't start once the load dropped")
+	}
+}
+
+func TestLoadGate_StartsWhenOthersFinish(t *testing.T) {
+	g := NewLoadGate(0, 50)
+	g.poll = 10 * time.Millisecond
+	g.sample = func() (sysload.Load, error) {
+		return sysload.Load{Memory: 90}, nil
+	}
+	ctx := context.Background()
+
+	if err := g.Acquire(ctx, "a"); err != nil {
+		t.Fatal(err)
+	}
+	started := make(chan struct{})
+	go func() {
+		_ = g.Acquire(ctx, "b")
+		close(started)
+	}()
+	time.Sleep(30 * time.Millisecond)
+	g.Release()
+
+	select {
+	case <-started:
+	case <-time.After(time.Second):
+		t.Fatal("deferred task didn't start once no other task was running")
+	}
+}
+
+func TestLoadGate_Cancelled(t *testing.T) {
+	g := NewLoadGate(80, 0)
+	g.poll = 10 * time.Millisecond
+	g.sample = func() (sysload.Load, error) {
+		return sysload.Load{CPU: 100}, nil
+	}
+	if err := g.Acquire(context.Background(), "a"); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(30*time.Millisecond, cancel)
+	if err := g.Acquire(ctx, "b"); !errors.Is(err, context.Canceled) {
+		t.Errorf("Acquire() = %v, want context.Canceled", err)
+	}
+}
+
+func TestLoadGate_UnmeasurableLoad(t *testing.T) {
+	g := NewLoadGate(80, 80)
+	g.sample = func() (sysload.Load, error) {
+		return sysload.Load{}, sysload.ErrUnsupported
+	}
+	ctx := context.Background()
+	for _, task := range []string{"a", "b"} {
+		if err := g.Acquire(ctx, task); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,24 @@
+package runtime
+
+import (
+	"strings"
+
+	"github.com/adityaraj/agentflow/internal/config"
+	"github.com/adityaraj/agentflow/internal/ui"
+)
+
+// noMemory stands in for {{memory}} before anything has been recorded.
+const noMemory = "(No entries yet: this is the first run that records memory.)"
+
+// withMemory replaces {{memory}} in prompt with the project memory.
+// A memory that can't be read is treated as empty.
+func (e *Executor) withMemory(prompt string) string {
+	memory, err := e.store.ReadMemory()
+	if err != nil {
+		ui.Warning("%s", err)
+	}
+	if memory == "" {
+		memory = noMemory
+	}
+	return strings.ReplaceAll(prompt, config.MemoryVar, memory)
+}
@@ -0,0 +1,40 @@
+package runtime
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/adityaraj/agentflow/internal/state"
+)
+
+func TestWithMemory(t *testing.T) {
+	store, err := state.NewRunStoreWithPath(t.TempDir(), "/work/demo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := &Executor{store: store}
+
+	if got := e.withMemory("Prior: {{memory}}"); got != "Prior: "+noMemory {
+		t.Errorf("withMemory() without entries = %q", got)
+	}
+
+	if err := store.AppendMemory("health", "  3 flaky tests in ./api  \n"); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.AppendMemory("health", ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.AppendMemory("health", "Flaky tests fixed"); err != nil {
+		t.Fatal(err)
+	}
+
+	got := e.withMemory("Prior: {{memory}}")
+	first := strings.Index(got, "3 flaky tests in ./api\n")
+	second := strings.Index(got, "Flaky tests fixed")
+	if first < 0 || second < first {
+		t.Errorf("withMemory() = %q, want both entries oldest first", got)
+	}
+	if n := strings.Count(got, "· health"); n != 2 {
+		t.Errorf("withMemory() has %d entries, want 2 (blank output is skipped)", n)
+	}
+}
@@ -0,0 +1,63 @@
+package runtime
+
+import (
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/adityaraj/agentflow/internal/config"
+	"github.com/adityaraj/agentflow/internal/git"
+	"github.com/adityaraj/agentflow/internal/planner"
+)
+
+// runMeta returns the run-wide metadata placeholders: the run's ID and start
+// time, and the branch and commit checked out in dir (empty outside a repo).
+func runMeta(runID, dir string, start time.Time) map[string]string {
+	if dir == "" {
+		dir = "."
+	}
+	branch, _ := git.CurrentBranch(dir)
+	commit, _ := git.HeadCommit(dir)
+	return map[string]string{
+		config.MetaRunID:        runID,
+		config.MetaRunStartTime: start.Format(time.RFC3339),
+		config.MetaGitBranch:    branch,
+		config.MetaGitCommit:    commit,
+	}
+}
+
+// expandMeta fills the metadata placeholders in text, for task. Unset
+// environment variables expand to empty text, as in a shell.
+// {{secrets.X}} expands to the secret's value; the texts it's used in are
+// masked wherever they are shown or saved.
+func (e *Executor) expandMeta(task planner.ExecutionTask, text string) string {
+	values := map[string]string{
+		config.MetaTaskName:  task.Name,
+		config.MetaTaskAgent: task.AgentName,
+	}
+	for k, v := range e.meta {
+		values[k] = v
+	}
+	for _, name := range config.ExtractMetaVars(text) {
+		if env, ok := strings.CutPrefix(name, "env."); ok {
+			values[name] = os.Getenv(env)
+		} else if secret, ok := strings.CutPrefix(name, config.MetaSecretsPrefix); ok {
+			values[name] = e.secrets[secret]
+		}
+	}
+	return config.ExpandMeta(text, values)
+}
+
+// secretEnv returns the secrets as environment variables for agents.
+func (e *Executor) secretEnv() []string {
+	if len(e.secrets) == 0 {
+		return nil
+	}
+	env := make([]string, 0, len(e.secrets))
+	for name, value := range e.secrets {
+		env = append(env, name+"="+value)
+	}
+	sort.Strings(env)
+	return env
+}
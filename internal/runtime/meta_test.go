@@ -0,0 +1,25 @@
+package runtime
+
+import (
+	"testing"
+
+	"github.com/adityaraj/agentflow/internal/planner"
+)
+
+func TestExpandMeta(t *testing.T) {
+	t.Setenv("CORTEX_TEST_REGION", "eu-west-1")
+	e := &Executor{
+		meta:    map[string]string{"run.id": "20240115-143022", "git.branch": "main", "git.commit": "abc123", "run.start_time": "2024-01-15T14:30:22Z"},
+		secrets: map[string]string{"TOKEN": "tok-123"},
+	}
+
+	task := planner.ExecutionTask{
+		Name:      "deploy",
+		AgentName: "ops",
+		Prompt:    "{{task.name}}/{{task.agent}} on {{git.branch}}@{{git.commit}} in {{env.CORTEX_TEST_REGION}}{{env.CORTEX_TEST_UNSET}} for {{run.id}} {{outputs.plan}} with {{secrets.TOKEN}}",
+	}
+	want := "deploy/ops on main@abc123 in eu-west-1 for 20240115-143022 {{outputs.plan}} with tok-123"
+	if got := e.expandMeta(task, task.Prompt); got != want {
+		t.Errorf("expandMeta() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,22 @@
+package runtime
+
+import (
+	"slices"
+
+	"github.com/adityaraj/agentflow/internal/config"
+	"github.com/adityaraj/agentflow/internal/normalize"
+)
+
+// normalizeSteps returns the output normalization steps a task selected
+// with its normalize field: all of them if it has none.
+func normalizeSteps(names []string) normalize.Steps {
+	if len(names) == 0 {
+		return normalize.All
+	}
+	return normalize.Steps{
+		ANSI:     slices.Contains(names, config.NormalizeANSI),
+		UTF8:     slices.Contains(names, config.NormalizeUTF8),
+		Newlines: slices.Contains(names, config.NormalizeNewlines),
+		Progress: slices.Contains(names, config.NormalizeProgress),
+	}
+}
@@ -0,0 +1,49 @@
+package runtime_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/adityaraj/agentflow/internal/config"
+	"github.com/adityaraj/agentflow/internal/runtime/runtimetest"
+)
+
+// TestNormalize tests that task output is cleaned up before it's saved
+// and passed on, unless the task opts out.
+func TestNormalize(t *testing.T) {
+	h := runtimetest.New(t)
+	out := "\x1b[32mPASS\x1b[0m\r\nfetching  40%\rfetching 100%\r\n"
+	h.Agent.On("build", runtimetest.OK(out))
+	h.Agent.On("raw", runtimetest.OK(out))
+	h.Agent.On("review", runtimetest.OK("ok"))
+
+	cfg := &config.AgentflowConfig{
+		Agents: map[string]config.AgentConfig{"ai": {Tool: "claude-code"}},
+		Tasks: map[string]config.TaskConfig{
+			"build":  {Agent: "ai", Prompt: "Build"},
+			"raw":    {Agent: "ai", Prompt: "Build", Normalize: []string{config.NormalizeNone}},
+			"review": {Agent: "ai", Needs: []string{"build", "raw"}, Prompt: "[{{outputs.build}}]"},
+		},
+	}
+	run, err := h.Run(context.Background(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := "PASS\nfetching 100%\n"
+	if prompt := h.Agent.Calls("review")[0].Prompt; prompt != "["+want+"]" {
+		t.Errorf("review prompt = %q, want the normalized output", prompt)
+	}
+	for _, r := range run.Tasks {
+		switch r.TaskName {
+		case "build":
+			if r.Stdout != want {
+				t.Errorf("saved build output = %q, want %q", r.Stdout, want)
+			}
+		case "raw":
+			if r.Stdout != out {
+				t.Errorf("saved raw output = %q, want it as printed", r.Stdout)
+			}
+		}
+	}
+}
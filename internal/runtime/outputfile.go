@@ -0,0 +1,67 @@
+package runtime
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/adityaraj/agentflow/internal/planner"
+	"github.com/adityaraj/agentflow/internal/ui"
+)
+
+// maxOutputFileVariants bounds the numbered names tried for an output file.
+const maxOutputFileVariants = 1000
+
+// writeOutputFile saves a task's output to its output_file path and returns
+// where it was written. An existing file is never overwritten: if the path
+// is taken, by an earlier run or another task, the first free numbered
+// variant is used instead (report.md, report-2.md, report-3.md, ...).
+func (e *Executor) writeOutputFile(task planner.ExecutionTask, output string) (string, error) {
+	path := e.expandMeta(task, task.OutputFile)
+	if !filepath.IsAbs(path) && task.Workdir != "" {
+		path = filepath.Join(task.Workdir, path)
+	}
+	return writeNewFile(path, output)
+}
+
+// writeNewFile writes content to path, or to the first free numbered
+// variant of it, creating its directory, and returns where it was written.
+func writeNewFile(path, content string) (string, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	ext := filepath.Ext(path)
+	stem := strings.TrimSuffix(path, ext)
+	for n := 1; n <= maxOutputFileVariants; n++ {
+		candidate := path
+		if n > 1 {
+			candidate = fmt.Sprintf("%s-%d%s", stem, n, ext)
+		}
+
+		// O_EXCL claims the name atomically, so parallel tasks can't collide
+		f, err := os.OpenFile(candidate, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if errors.Is(err, fs.ErrExist) {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		_, err = f.WriteString(content)
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return "", fmt.Errorf("failed to write %s: %w", candidate, err)
+		}
+
+		if n > 1 {
+			ui.Warning("%s already exists; saved output to %s", path, candidate)
+		}
+		return candidate, nil
+	}
+	return "", fmt.Errorf("%s and its numbered variants already exist", path)
+}
@@ -0,0 +1,32 @@
+package runtime
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/adityaraj/agentflow/internal/planner"
+)
+
+func TestWriteOutputFile(t *testing.T) {
+	dir := t.TempDir()
+	e := &Executor{meta: map[string]string{"run.id": "20240115-143022"}}
+	task := planner.ExecutionTask{Name: "review", Workdir: dir, OutputFile: "reports/{{run.id}}/{{task.name}}.md"}
+
+	want := []string{"review.md", "review-2.md", "review-3.md"}
+	for i, name := range want {
+		path, err := e.writeOutputFile(task, "output "+name)
+		if err != nil {
+			t.Fatalf("writeOutputFile() #%d error = %v", i+1, err)
+		}
+		if wantPath := filepath.Join(dir, "reports", "20240115-143022", name); path != wantPath {
+			t.Errorf("writeOutputFile() #%d = %s, want %s", i+1, path, wantPath)
+		}
+	}
+
+	// Earlier files are left untouched
+	data, err := os.ReadFile(filepath.Join(dir, "reports", "20240115-143022", "review.md"))
+	if err != nil || string(data) != "output review.md" {
+		t.Errorf("review.md = %q, %v", data, err)
+	}
+}
@@ -0,0 +1,171 @@
+package runtime
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/adityaraj/agentflow/internal/config"
+	"github.com/adityaraj/agentflow/internal/planner"
+	"github.com/adityaraj/agentflow/internal/state"
+	"github.com/adityaraj/agentflow/internal/ui"
+)
+
+const (
+	// maxOverflowRetries caps how many times a prompt is reduced and retried.
+	maxOverflowRetries = 3
+
+	// maxSummarizeInput caps the text handed to the agent for summarization,
+	// so the summarization call itself doesn't overflow.
+	maxSummarizeInput = 200_000
+)
+
+// recoverContextOverflow retries a task whose prompt overflowed the model's
+// context window, shrinking the dependency outputs it embeds (or the prompt
+// itself) on each attempt. What was added to the prompt after expansion,
+// such as context files, is kept. Returns the task as last run and its
+// result.
+func (e *Executor) recoverContextOverflow(ctx context.Context, agent Agent, task Task, execTask planner.ExecutionTask, taskResult *state.TaskResult, result Result) (Task, Result) {
+	strategy := execTask.ContextOverflow
+	if strategy == "" {
+		strategy = config.OverflowTruncate
+	}
+
+	// Work on a private copy of the referenced outputs
+	outputs := make(map[string]string)
+	e.outputsMu.RLock()
+	for _, name := range config.ExtractTemplateVars(execTask.Prompt) {
+		if out, ok := e.outputs[name]; ok {
+			outputs[name] = out
+		}
+	}
+	e.outputsMu.RUnlock()
+
+	degradation := &state.Degradation{
+		Reason:         state.ErrorContextOverflow,
+		Strategy:       strategy,
+		OriginalLength: len(task.Prompt),
+	}
+	taskResult.Degradation = degradation
+
+	// The task's prompt is execTask's expanded, then added to; the outputs
+	// can only be reduced if it still starts with the expansion (a task
+	// given args runs them instead)
+	suffix, expanded := strings.CutPrefix(task.Prompt, config.RestoreEscapes(config.ExpandPrompt(execTask.Prompt, outputs)))
+
+	prompt := task.Prompt
+	for attempt := 1; attempt <= maxOverflowRetries && ctx.Err() == nil; attempt++ {
+		var reduced string
+		if expanded {
+			// Each attempt halves the budget for the largest embedded output
+			limit := largestOutput(outputs) / 2
+
+			if strategy == config.OverflowSummarize && attempt == 1 {
+				result = e.summarizeOutputs(ctx, agent, task, outputs, limit, result)
+			} else {
+				for name, out := range outputs {
+					outputs[name] = truncateMiddle(out, limit)
+				}
+			}
+			reduced = config.RestoreEscapes(config.ExpandPrompt(execTask.Prompt, outputs)) + suffix
+		}
+		if !expanded || len(reduced) >= len(prompt)*9/10 {
+			// Outputs aren't what's large; cut the prompt itself
+			reduced = truncateMiddle(prompt, len(prompt)/2)
+		}
+		prompt = reduced
+
+		degradation.Attempts = attempt
+		degradation.FinalLength = len(prompt)
+		ui.PrintDegraded(degradation.Strategy, degradation.OriginalLength, degradation.FinalLength)
+
+		retry := task
+		retry.Prompt = prompt
+		next, err := e.runAgent(ctx, agent, retry)
+		next = addUsage(next, result)
+		if err != nil {
+			next.Stderr = err.Error()
+			next.ExitCode = 1
+			next.Success = false
+		}
+		task, result = retry, next
+
+		if result.Success || ClassifyError(ctx, execTask.Tool, result, err) != state.ErrorContextOverflow {
+			break
+		}
+	}
+
+	return task, result
+}
+
+// summarizeOutputs replaces outputs larger than limit with an agent-written
+// summary. Outputs that fail to summarize are truncated instead.
+// Token usage of the summarization calls is added to result.
+func (e *Executor) summarizeOutputs(ctx context.Context, agent Agent, task Task, outputs map[string]string, limit int, result Result) Result {
+	for name, out := range outputs {
+		if len(out) <= limit {
+			continue
+		}
+
+		summaryTask := task
+		summaryTask.Write = false
+		summaryTask.Prompt = fmt.Sprintf("Summarize the following output of task %q in at most %d characters. "+
+			"Keep file paths, identifiers, error messages, and conclusions; drop repetition.\n\n%s",
+			name, limit, truncateMiddle(out, maxSummarizeInput))
+
+		summary, err := e.runAgent(ctx, agent, summaryTask)
+		result = addUsage(result, summary)
+		if err != nil || !summary.Success || summary.Stdout == "" {
+			outputs[name] = truncateMiddle(out, limit)
+			continue
+		}
+		outputs[name] = summary.Stdout
+	}
+	return result
+}
+
+// addUsage returns r with the token and resource usage of prev added to it.
+func addUsage(r, prev Result) Result {
+	r.InputTokens += prev.InputTokens
+	r.OutputTokens += prev.OutputTokens
+	r.CacheRead += prev.CacheRead
+	r.CacheWrite += prev.CacheWrite
+	r.Resources = r.Resources.Add(prev.Resources)
+	return r
+}
+
+// largestOutput returns the length of the longest output.
+func largestOutput(outputs map[string]string) int {
+	max := 0
+	for _, out := range outputs {
+		if len(out) > max {
+			max = len(out)
+		}
+	}
+	return max
+}
+
+// truncateMiddle shortens s to about limit bytes, keeping its head and tail
+// (where instructions and conclusions tend to be) and marking the cut.
+func truncateMiddle(s string, limit int) string {
+	if len(s) <= limit {
+		return s
+	}
+	if limit < 0 {
+		limit = 0
+	}
+	head := limit / 2
+	tailStart := len(s) - (limit - head)
+
+	// Don't split multi-byte characters
+	for head > 0 && !utf8.RuneStart(s[head]) {
+		head--
+	}
+	for tailStart < len(s) && !utf8.RuneStart(s[tailStart]) {
+		tailStart++
+	}
+
+	return fmt.Sprintf("%s\n\n[... %d characters truncated ...]\n\n%s",
+		s[:head], tailStart-head, s[tailStart:])
+}
@@ -0,0 +1,102 @@
+package runtime
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/adityaraj/agentflow/internal/planner"
+	"github.com/adityaraj/agentflow/internal/state"
+)
+
+// overflowAgent fails with a context-length error while prompts exceed limit.
+type overflowAgent struct {
+	limit   int
+	prompts []string
+}
+
+func (a *overflowAgent) Run(ctx context.Context, task Task) (Result, error) {
+	a.prompts = append(a.prompts, task.Prompt)
+	if len(task.Prompt) > a.limit {
+		return Result{Stderr: "Prompt is too long", ExitCode: 1, InputTokens: 10}, nil
+	}
+	return Result{Stdout: "done", Success: true, InputTokens: 10}, nil
+}
+
+func TestRecoverContextOverflow_Truncate(t *testing.T) {
+	e := &Executor{
+		outputs: map[string]string{"analyze": strings.Repeat("a", 10000)},
+		backoff: NewBackoff(),
+	}
+	execTask := planner.ExecutionTask{Name: "implement", Tool: "claude-code", Prompt: "Fix: {{outputs.analyze}}"}
+	agent := &overflowAgent{limit: 3000}
+
+	task := Task{Name: "implement", Prompt: "Fix: " + e.outputs["analyze"]}
+	first, _ := agent.Run(context.Background(), task)
+	taskResult := &state.TaskResult{}
+
+	task, result := e.recoverContextOverflow(context.Background(), agent, task, execTask, taskResult, first)
+
+	if !result.Success {
+		t.Fatalf("expected recovery to succeed, got %+v", result)
+	}
+	if !strings.HasPrefix(task.Prompt, "Fix: ") {
+		t.Errorf("instructions were lost: %q", task.Prompt[:20])
+	}
+	if len(task.Prompt) > agent.limit {
+		t.Errorf("final prompt is %d chars, want <= %d", len(task.Prompt), agent.limit)
+	}
+	if result.InputTokens != 10*len(agent.prompts) {
+		t.Errorf("InputTokens = %d, want usage of all %d attempts", result.InputTokens, len(agent.prompts))
+	}
+
+	d := taskResult.Degradation
+	if d == nil || d.Strategy != "truncate" || d.Attempts != 2 || d.FinalLength != len(task.Prompt) {
+		t.Errorf("Degradation = %+v, want truncate after 2 attempts", d)
+	}
+	if e.outputs["analyze"] != strings.Repeat("a", 10000) {
+		t.Error("shared outputs must not be modified")
+	}
+}
+
+// TestRecoverContextOverflow_KeepsSuffix tests that what was appended to
+// the expanded prompt, such as context files, is in each retry's prompt.
+func TestRecoverContextOverflow_KeepsSuffix(t *testing.T) {
+	e := &Executor{
+		outputs: map[string]string{"analyze": strings.Repeat("a", 10000)},
+		backoff: NewBackoff(),
+	}
+	execTask := planner.ExecutionTask{Name: "implement", Tool: "claude-code", Prompt: "Fix: {{outputs.analyze}}"}
+	agent := &overflowAgent{limit: 3000}
+	files := "\n\nFiles from the repository that may be relevant, most relevant first:\n\n--- main.go ---\npackage main\n"
+
+	task := Task{Name: "implement", Prompt: "Fix: " + e.outputs["analyze"] + files}
+	first, _ := agent.Run(context.Background(), task)
+	task, result := e.recoverContextOverflow(context.Background(), agent, task, execTask, &state.TaskResult{}, first)
+
+	if !result.Success {
+		t.Fatalf("expected recovery to succeed, got %+v", result)
+	}
+	for i, prompt := range agent.prompts {
+		if !strings.HasPrefix(prompt, "Fix: ") || !strings.HasSuffix(prompt, files) {
+			t.Errorf("attempt %d prompt lost its instructions or context files: %q...%q", i+1, prompt[:10], prompt[len(prompt)-20:])
+		}
+	}
+	if len(task.Prompt) > agent.limit {
+		t.Errorf("final prompt is %d chars, want <= %d", len(task.Prompt), agent.limit)
+	}
+}
+
+func TestTruncateMiddle(t *testing.T) {
+	s := "héllo wörld, this is a long string"
+	got := truncateMiddle(s, 10)
+	if !strings.HasPrefix(got, "héll") || !strings.HasSuffix(got, "tring") {
+		t.Errorf("truncateMiddle() = %q, want head and tail kept", got)
+	}
+	if !strings.Contains(got, "characters truncated") {
+		t.Errorf("truncateMiddle() = %q, want truncation marker", got)
+	}
+	if truncateMiddle("short", 10) != "short" {
+		t.Error("strings under the limit must be unchanged")
+	}
+}
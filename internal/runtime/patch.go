@@ -0,0 +1,69 @@
+package runtime
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/adityaraj/agentflow/internal/git"
+	"github.com/adityaraj/agentflow/internal/planner"
+)
+
+// PatchFile returns the name of the run file a 'write: patch' task's
+// changes are saved to.
+func PatchFile(task string) string {
+	return task + ".patch"
+}
+
+// openPatchWorkspace gives a 'write: patch' task a workspace of its own,
+// a copy of the repository it runs in, and moves its workdir into it.
+func (e *Executor) openPatchWorkspace(task *planner.ExecutionTask) (*Workspace, error) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return nil, err
+	}
+	dir := task.Workdir
+	if dir == "" {
+		dir = cwd
+	}
+	if !git.IsRepo(dir) {
+		return nil, fmt.Errorf("'write: patch' needs a git repository; %s isn't in one", dir)
+	}
+	root, err := git.Root(dir)
+	if err != nil {
+		return nil, err
+	}
+	rel, _ := relToRoot(root, cwd, task.Workdir)
+
+	// Creating worktrees at once from parallel tasks races on git's locks
+	e.gitMu.Lock()
+	defer e.gitMu.Unlock()
+	w, err := newWorkspace(root, e.store.RunID()+"-"+task.Name, "cortex: checkout before task "+task.Name)
+	if err != nil {
+		return nil, err
+	}
+	task.Workdir = filepath.Join(w.Dir, rel)
+	return w, nil
+}
+
+// closePatchWorkspace removes a 'write: patch' task's workspace.
+func (e *Executor) closePatchWorkspace(w *Workspace) error {
+	e.gitMu.Lock()
+	defer e.gitMu.Unlock()
+	return w.Remove()
+}
+
+// savePatch saves the changes made in a 'write: patch' task's workspace to
+// the run directory, returning the run file's name ("" if the task
+// changed nothing).
+func (e *Executor) savePatch(w *Workspace, task string) (string, error) {
+	patch, err := w.Patch()
+	if err != nil || patch == "" {
+		return "", err
+	}
+	name := PatchFile(task)
+	if err := e.store.WriteRunFile(name, []byte(patch)); err != nil {
+		return "", err
+	}
+	return name, nil
+}
@@ -0,0 +1,80 @@
+package runtime_test
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/adityaraj/agentflow/internal/config"
+	"github.com/adityaraj/agentflow/internal/crypt"
+	"github.com/adityaraj/agentflow/internal/runtime"
+	"github.com/adityaraj/agentflow/internal/runtime/runtimetest"
+	"github.com/adityaraj/agentflow/internal/state"
+)
+
+// TestWritePatch tests that a 'write: patch' task's edits are saved as a
+// patch instead of being made to the checkout.
+func TestWritePatch(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available")
+	}
+	h := runtimetest.New(t)
+	if err := os.WriteFile(filepath.Join(h.Dir, "main.go"), []byte("package main\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for _, args := range [][]string{
+		{"init", "-q"},
+		{"add", "."},
+		{"-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "-qm", "init"},
+	} {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = h.Dir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %s: %s", args[0], out)
+		}
+	}
+	h.Agent.On("refactor", runtimetest.Response{Files: map[string]string{"main.go": "package app\n"}})
+	h.Agent.On("review", runtimetest.OK("looks good"))
+
+	cfg := &config.AgentflowConfig{
+		Agents: map[string]config.AgentConfig{"ai": {Tool: "claude-code"}},
+		Tasks: map[string]config.TaskConfig{
+			"refactor": {Agent: "ai", Prompt: "Refactor", Write: true, WritePatch: true},
+			"review":   {Agent: "ai", Prompt: "Review", Needs: []string{"refactor"}},
+		},
+	}
+	if _, err := h.Run(context.Background(), cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	if data, _ := os.ReadFile(filepath.Join(h.Dir, "main.go")); string(data) != "package main\n" {
+		t.Errorf("checkout's main.go = %q, want it unchanged", data)
+	}
+	if dir := h.Agent.Calls("refactor")[0].Workdir; dir == h.Dir {
+		t.Errorf("write: patch task ran in the checkout")
+	}
+	if dir := h.Agent.Calls("review")[0].Workdir; dir != h.Dir {
+		t.Errorf("dependent task ran in %s, want the checkout", dir)
+	}
+
+	result, err := state.ReadTaskResult(h.Store.RunDir(), "refactor")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Patch != runtime.PatchFile("refactor") {
+		t.Fatalf("result patch = %q", result.Patch)
+	}
+	patch, err := crypt.ReadFile(filepath.Join(h.Store.RunDir(), result.Patch))
+	if err != nil || !strings.Contains(string(patch), "+package app") {
+		t.Errorf("patch = %q, %v", patch, err)
+	}
+	entries, _ := os.ReadDir(filepath.Join(h.Dir, runtime.WorkspacesDir))
+	for _, entry := range entries {
+		if entry.IsDir() {
+			t.Errorf("workspace %s left behind", entry.Name())
+		}
+	}
+}
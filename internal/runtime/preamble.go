@@ -0,0 +1,38 @@
+package runtime
+
+import (
+	"strings"
+
+	"github.com/adityaraj/agentflow/internal/config"
+	"github.com/adityaraj/agentflow/internal/planner"
+)
+
+// taskVars replaces the task and run placeholders available in preambles
+// and commit messages.
+func taskVars(task planner.ExecutionTask, runID string) *strings.Replacer {
+	return strings.NewReplacer(
+		"{{task.name}}", task.Name,
+		"{{task.agent}}", task.AgentName,
+		"{{run.id}}", runID,
+	)
+}
+
+// withPreamble returns the task's prompt with the run preamble prepended.
+// Shell commands are returned unchanged.
+func withPreamble(preamble string, task planner.ExecutionTask, runID string) string {
+	preamble = strings.TrimSpace(preamble)
+	if preamble == "" || task.Tool == "shell" {
+		return task.Prompt
+	}
+	return taskVars(task, runID).Replace(config.ProtectEscapes(preamble)) + "\n\n" + task.Prompt
+}
+
+// withInstructions returns the task's prompt with its agent's instructions
+// prepended. Shell commands are returned unchanged.
+func withInstructions(task planner.ExecutionTask, runID string) string {
+	instructions := strings.TrimSpace(task.Instructions)
+	if instructions == "" || task.Tool == "shell" {
+		return task.Prompt
+	}
+	return taskVars(task, runID).Replace(config.ProtectEscapes(instructions)) + "\n\n" + task.Prompt
+}
@@ -0,0 +1,73 @@
+package runtime
+
+import (
+	"testing"
+
+	"github.com/adityaraj/agentflow/internal/planner"
+)
+
+func TestWithPreamble(t *testing.T) {
+	tests := []struct {
+		name     string
+		preamble string
+		task     planner.ExecutionTask
+		want     string
+	}{
+		{
+			name:     "expands task and run variables",
+			preamble: "Task {{task.name}} by {{task.agent}} in run {{run.id}}. Do not touch /vendor.\n",
+			task:     planner.ExecutionTask{Name: "fix", AgentName: "coder", Tool: "claude-code", Prompt: "Fix the bug."},
+			want:     "Task fix by coder in run 20240115-143022. Do not touch /vendor.\n\nFix the bug.",
+		},
+		{
+			name:     "keeps output references for later expansion",
+			preamble: "Be brief.",
+			task:     planner.ExecutionTask{Name: "review", Tool: "claude-code", Prompt: "Review {{outputs.fix}}"},
+			want:     "Be brief.\n\nReview {{outputs.fix}}",
+		},
+		{
+			name:     "shell commands unchanged",
+			preamble: "Be brief.",
+			task:     planner.ExecutionTask{Name: "test", Tool: "shell", Prompt: "go test ./..."},
+			want:     "go test ./...",
+		},
+		{
+			name:     "escaped braces stay hidden until the prompt is final",
+			preamble: `Keep \{{task.name}} literal in {{task.name}}.`,
+			task:     planner.ExecutionTask{Name: "fix", Tool: "claude-code", Prompt: "Go."},
+			want:     "Keep \x00{\x00task.name}} literal in fix.\n\nGo.",
+		},
+		{
+			name:     "blank preamble",
+			preamble: "  \n",
+			task:     planner.ExecutionTask{Name: "fix", Tool: "claude-code", Prompt: "Fix the bug."},
+			want:     "Fix the bug.",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := withPreamble(tt.preamble, tt.task, "20240115-143022"); got != tt.want {
+				t.Errorf("withPreamble() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWithInstructions(t *testing.T) {
+	task := planner.ExecutionTask{Name: "review", AgentName: "reviewer", Tool: "claude-code", Prompt: "Review the diff.", Instructions: "You are {{task.agent}}.\n"}
+	if got, want := withInstructions(task, "20240115-143022"), "You are reviewer.\n\nReview the diff."; got != want {
+		t.Errorf("withInstructions() = %q, want %q", got, want)
+	}
+
+	// The preamble goes before the instructions
+	task.Prompt = withInstructions(task, "20240115-143022")
+	if got, want := withPreamble("Be brief.", task, "20240115-143022"), "Be brief.\n\nYou are reviewer.\n\nReview the diff."; got != want {
+		t.Errorf("withPreamble(withInstructions()) = %q, want %q", got, want)
+	}
+
+	task = planner.ExecutionTask{Name: "test", Tool: "shell", Prompt: "go test ./...", Instructions: "Be careful."}
+	if got := withInstructions(task, "20240115-143022"); got != "go test ./..." {
+		t.Errorf("withInstructions() = %q for a shell command", got)
+	}
+}
@@ -0,0 +1,52 @@
+package runtime
+
+import (
+	"fmt"
+
+	"github.com/adityaraj/agentflow/internal/config"
+	"github.com/adityaraj/agentflow/internal/planner"
+	"github.com/adityaraj/agentflow/internal/state"
+)
+
+// PreparePriorOutputs loads the outputs that {{runs.last_success.outputs.X}}
+// placeholders refer to from the project's last successful run. References
+// that can't be resolved expand to a note saying so, so the prompt still
+// reads sensibly on a workflow's first run. Does nothing if no prompt uses them.
+func PreparePriorOutputs(plan *planner.ExecutionPlan, store *state.RunStore) error {
+	var refs []string
+	for _, text := range plan.SharedPrompts() {
+		refs = append(refs, config.ExtractPriorOutputVars(text)...)
+	}
+	for _, task := range plan.Tasks {
+		refs = append(refs, config.ExtractPriorOutputVars(task.Prompt)...)
+	}
+	if len(refs) == 0 {
+		return nil
+	}
+
+	run, err := store.LastSuccessfulRun()
+	if err != nil {
+		return err
+	}
+
+	outputs := make(map[string]string)
+	if run != nil {
+		plan.PriorRunID = run.RunID
+		for _, task := range run.Tasks {
+			outputs[task.TaskName] = task.Stdout
+		}
+	}
+
+	plan.PriorOutputs = make(map[string]string)
+	for _, name := range refs {
+		switch output, ok := outputs[name]; {
+		case run == nil:
+			plan.PriorOutputs[name] = "(No previous successful run.)"
+		case !ok:
+			plan.PriorOutputs[name] = fmt.Sprintf("(Task %q did not run in the previous successful run %s.)", name, run.RunID)
+		default:
+			plan.PriorOutputs[name] = output
+		}
+	}
+	return nil
+}
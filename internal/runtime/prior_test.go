@@ -0,0 +1,57 @@
+package runtime
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/adityaraj/agentflow/internal/planner"
+	"github.com/adityaraj/agentflow/internal/state"
+)
+
+// writeRun saves a finished run for project under base.
+func writeRun(t *testing.T, base, project string, run state.RunResult) {
+	t.Helper()
+	dir := filepath.Join(base, "sessions", project, "run-"+run.RunID)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := json.Marshal(run)
+	if err := os.WriteFile(filepath.Join(dir, "run.json"), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPreparePriorOutputs(t *testing.T) {
+	base := t.TempDir()
+	start := time.Date(2024, 1, 15, 9, 0, 0, 0, time.UTC)
+	writeRun(t, base, "demo", state.RunResult{RunID: "20240115-090000", StartTime: start, Success: true,
+		Tasks: []state.TaskResult{{TaskName: "audit", Stdout: "old findings"}}})
+	writeRun(t, base, "demo", state.RunResult{RunID: "20240116-090000", StartTime: start.Add(24 * time.Hour), Success: true,
+		Tasks: []state.TaskResult{{TaskName: "audit", Stdout: "last findings"}}})
+	writeRun(t, base, "demo", state.RunResult{RunID: "20240117-090000", StartTime: start.Add(48 * time.Hour), Success: false,
+		Tasks: []state.TaskResult{{TaskName: "audit", Stdout: "failed findings"}}})
+
+	store, err := state.NewRunStoreWithPath(base, "/work/demo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	plan := &planner.ExecutionPlan{Tasks: []planner.ExecutionTask{
+		{Name: "audit", Prompt: "Compare with {{runs.last_success.outputs.audit}} and {{runs.last_success.outputs.summary}}"},
+	}}
+
+	if err := PreparePriorOutputs(plan, store); err != nil {
+		t.Fatal(err)
+	}
+	if plan.PriorRunID != "20240116-090000" {
+		t.Errorf("PriorRunID = %q, want the newest successful run", plan.PriorRunID)
+	}
+	if got := plan.PriorOutputs["audit"]; got != "last findings" {
+		t.Errorf("PriorOutputs[audit] = %q", got)
+	}
+	if got := plan.PriorOutputs["summary"]; got == "" {
+		t.Error("missing tasks should expand to a note, not nothing")
+	}
+}
@@ -0,0 +1,98 @@
+package runtime
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/adityaraj/agentflow/internal/config"
+	"github.com/adityaraj/agentflow/internal/planner"
+	"github.com/adityaraj/agentflow/internal/retrieval"
+	"github.com/adityaraj/agentflow/internal/ui"
+)
+
+// PrepareRetrieval resolves the plan's {{retrieve "query"}} placeholders
+// before execution: it indexes the working directory with the configured
+// embedder (reusing the index cached in cacheDir) and stores each query's
+// snippets in the plan. Does nothing if no prompt uses {{retrieve}}.
+func PrepareRetrieval(ctx context.Context, plan *planner.ExecutionPlan, cfg *config.RetrievalConfig, cacheDir string) error {
+	var calls []config.RetrieveCall
+	for _, text := range plan.SharedPrompts() {
+		calls = append(calls, config.ExtractRetrieveCalls(config.ProtectEscapes(text))...)
+	}
+	for _, task := range plan.Tasks {
+		calls = append(calls, config.ExtractRetrieveCalls(config.ProtectEscapes(task.Prompt))...)
+	}
+	if len(calls) == 0 {
+		return nil
+	}
+
+	embedder, err := retrieval.NewEmbedder(cfg)
+	if err != nil {
+		return fmt.Errorf("retrieval: %w", err)
+	}
+	dir, err := os.Getwd()
+	if err != nil {
+		return fmt.Errorf("failed to get working directory: %w", err)
+	}
+	index, err := retrieval.Build(ctx, dir, embedder, filepath.Join(cacheDir, retrieval.IndexFile))
+	if err != nil {
+		return fmt.Errorf("retrieval: %w", err)
+	}
+
+	maxTokens := retrieval.DefaultMaxTokens
+	if cfg != nil && cfg.MaxTokens > 0 {
+		maxTokens = cfg.MaxTokens
+	}
+	if plan.Retrieved == nil {
+		plan.Retrieved = make(map[string]string)
+	}
+	for _, call := range calls {
+		if _, done := plan.Retrieved[call.Placeholder]; done {
+			continue
+		}
+		chunks, err := index.Search(ctx, call.Query, call.K)
+		if err != nil {
+			return fmt.Errorf("retrieval: %w", err)
+		}
+		plan.Retrieved[call.Placeholder] = retrieval.Format(chunks, maxTokens)
+	}
+	return nil
+}
+
+// maxSkippedShown caps the skipped files named in a warning.
+const maxSkippedShown = 5
+
+// joinSkipped lists the files left out of a prompt, and why.
+func joinSkipped(skipped []error) string {
+	var names []string
+	for i, err := range skipped {
+		if i == maxSkippedShown {
+			names = append(names, fmt.Sprintf("and %d more", len(skipped)-i))
+			break
+		}
+		names = append(names, err.Error())
+	}
+	return strings.Join(names, ", ")
+}
+
+// withContextFiles appends the files a task's context selects to its
+// prompt, ranked by relevance to the prompt. Failing to gather them is
+// reported and leaves the prompt as is.
+func withContextFiles(task planner.ExecutionTask, prompt string) string {
+	dir := task.Workdir
+	if dir == "" {
+		dir = "."
+	}
+	files, skipped, err := retrieval.Files(dir, task.Context.Files, prompt, task.Context.MaxTokens)
+	if err != nil {
+		ui.Warning("Task %s: couldn't gather its context files: %s", task.Name, err)
+		return prompt
+	}
+	if len(skipped) > 0 {
+		ui.Warning("Task %s: left out of its context: %s", task.Name, joinSkipped(skipped))
+	}
+	return prompt + "\n\nFiles from the repository that may be relevant, most relevant first:\n\n" + files
+}
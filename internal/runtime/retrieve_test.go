@@ -0,0 +1,51 @@
+package runtime_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/adityaraj/agentflow/internal/config"
+	"github.com/adityaraj/agentflow/internal/runtime/runtimetest"
+)
+
+// TestContextFiles tests that a task's context files are added to its
+// prompt, the most relevant first.
+func TestContextFiles(t *testing.T) {
+	h := runtimetest.New(t)
+	for name, content := range map[string]string{
+		"token.go":         "package auth\n\n// RefreshToken renews an expired session token.\nfunc RefreshToken() {}\n",
+		"invoice.go":       "package billing\n\n// ChargeInvoice bills the customer.\nfunc ChargeInvoice() {}\n",
+		"vendor/vendor.go": "package vendor\n",
+	} {
+		path := filepath.Join(h.Dir, name)
+		os.MkdirAll(filepath.Dir(path), 0755)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	h.Agent.On("fix", runtimetest.OK("done"))
+
+	cfg := &config.AgentflowConfig{
+		Agents: map[string]config.AgentConfig{"ai": {Tool: "claude-code"}},
+		Tasks: map[string]config.TaskConfig{
+			"fix": {Agent: "ai", Prompt: "Fix the session token refresh", Context: &config.ContextConfig{
+				Files: []string{"**/*.go", "!vendor/**"},
+			}},
+		},
+	}
+	if _, err := h.Run(context.Background(), cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	prompt := h.Agent.Calls("fix")[0].Prompt
+	token, invoice := strings.Index(prompt, "token.go\n```go"), strings.Index(prompt, "invoice.go\n```go")
+	if !strings.HasPrefix(prompt, "Fix the session token refresh\n\n") || token < 0 || invoice < token {
+		t.Errorf("prompt = %q, want token.go then invoice.go after the task's prompt", prompt)
+	}
+	if strings.Contains(prompt, "vendor.go") {
+		t.Errorf("prompt includes the excluded vendor.go")
+	}
+}
@@ -0,0 +1,107 @@
+package runtime
+
+import (
+	"context"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/adityaraj/agentflow/internal/config"
+	"github.com/adityaraj/agentflow/internal/planner"
+	"github.com/adityaraj/agentflow/internal/state"
+	"github.com/adityaraj/agentflow/internal/ui"
+)
+
+// maxAttemptError caps the error text recorded for a failed attempt.
+const maxAttemptError = 200
+
+// runWithRetries runs the task's agent, re-running it after a pause while it
+// fails in a way the task's retry policy covers. With retries, each run is
+// recorded in taskResult.Attempts; the result's usage covers all of them.
+func (e *Executor) runWithRetries(ctx context.Context, agent Agent, task Task, execTask planner.ExecutionTask, taskResult *state.TaskResult) (Result, error) {
+	if execTask.Retries <= 0 {
+		return e.runAgent(ctx, agent, task)
+	}
+	codes, patterns, _ := config.ParseRetryOn(execTask.RetryOn) // Validated with the config
+
+	var total Result
+	wait := execTask.RetryBackoff
+	for attempt := 1; ; attempt++ {
+		start := e.clock.Now()
+		result, err := e.runAgent(ctx, agent, task)
+		result = addUsage(result, total)
+
+		record := state.Attempt{
+			Number:    attempt,
+			StartTime: start,
+			Duration:  e.clock.Now().Sub(start).Round(100 * time.Millisecond).String(),
+			ExitCode:  result.ExitCode,
+			Success:   err == nil && result.Success,
+		}
+		if record.Success {
+			taskResult.Attempts = append(taskResult.Attempts, record)
+			return result, nil
+		}
+		category := ClassifyError(ctx, execTask.Tool, result, err)
+		record.ErrorCategory = category
+		record.Error = attemptError(result, err)
+		if err != nil && record.ExitCode == 0 {
+			record.ExitCode = 1
+		}
+		taskResult.Attempts = append(taskResult.Attempts, record)
+
+		if attempt > execTask.Retries || !retryable(category, result, err, codes, patterns) {
+			return result, err
+		}
+		ui.PrintRetry(attempt, execTask.Retries+1, string(category), wait)
+		select {
+		case <-ctx.Done():
+			return result, err
+		case <-e.clock.After(wait):
+		}
+		wait *= 2
+		total = result
+	}
+}
+
+// retryable reports whether a failed attempt may be re-run. Failures a
+// re-run can't fix never are; with retry_on, the failure must also match
+// one of its exit codes or patterns.
+func retryable(category state.ErrorCategory, result Result, err error, codes []int, patterns []*regexp.Regexp) bool {
+	switch category {
+	case state.ErrorCancelled, state.ErrorBudget, state.ErrorDiskSpace, state.ErrorTimeout, state.ErrorAuth, state.ErrorContextOverflow:
+		return false
+	}
+	if len(codes) == 0 && len(patterns) == 0 {
+		return true
+	}
+	if err == nil && slices.Contains(codes, result.ExitCode) {
+		return true
+	}
+	output := result.Stdout + "\n" + result.Stderr
+	if err != nil {
+		output += "\n" + err.Error()
+	}
+	for _, re := range patterns {
+		if re.MatchString(output) {
+			return true
+		}
+	}
+	return false
+}
+
+// attemptError describes why an attempt failed: the run error, or else the
+// last line of stderr.
+func attemptError(result Result, err error) string {
+	msg := strings.TrimSpace(result.Stderr)
+	if err != nil {
+		msg = err.Error()
+	} else if i := strings.LastIndexByte(msg, '\n'); i >= 0 {
+		msg = msg[i+1:]
+	}
+	if len(msg) > maxAttemptError {
+		msg = msg[:maxAttemptError] + "..."
+	}
+	return msg
+}
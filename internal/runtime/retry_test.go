@@ -0,0 +1,103 @@
+package runtime_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/adityaraj/agentflow/internal/config"
+	"github.com/adityaraj/agentflow/internal/runtime/runtimetest"
+	"github.com/adityaraj/agentflow/internal/state"
+)
+
+// retryWorkflow returns a config with one task, deploy, and the given
+// retry policy.
+func retryWorkflow(retries int, backoff string, on ...string) *config.AgentflowConfig {
+	return &config.AgentflowConfig{
+		Agents: map[string]config.AgentConfig{"ai": {Tool: "claude-code"}},
+		Tasks: map[string]config.TaskConfig{
+			"deploy": {Agent: "ai", Prompt: "Deploy", Retries: retries, RetryBackoff: backoff, RetryOn: on},
+		},
+	}
+}
+
+func TestRetries(t *testing.T) {
+	h := runtimetest.New(t)
+	h.Agent.On("deploy", runtimetest.Fail(1, "connection reset"), runtimetest.Fail(1, "connection reset"), runtimetest.OK("deployed"))
+
+	done := make(chan *state.RunResult, 1)
+	go func() {
+		result, _ := h.Run(context.Background(), retryWorkflow(3, "10s"))
+		done <- result
+	}()
+
+	// The backoff doubles: 10s before the second attempt, 20s before the third
+	h.Clock.BlockUntil(1)
+	h.Clock.Advance(10 * time.Second)
+	h.Clock.BlockUntil(1)
+	h.Clock.Advance(20 * time.Second)
+	result := <-done
+
+	if !result.Success {
+		t.Fatalf("run failed: %+v", result.Tasks)
+	}
+	attempts := result.Tasks[0].Attempts
+	if len(attempts) != 3 {
+		t.Fatalf("attempts = %+v, want 3", attempts)
+	}
+	if attempts[0].Success || attempts[0].ExitCode != 1 || attempts[0].Error != "connection reset" || attempts[0].ErrorCategory != state.ErrorNetwork {
+		t.Errorf("first attempt = %+v", attempts[0])
+	}
+	if !attempts[2].Success || attempts[2].Number != 3 || !attempts[2].StartTime.Equal(runtimetest.Epoch.Add(30*time.Second)) {
+		t.Errorf("last attempt = %+v", attempts[2])
+	}
+}
+
+func TestRetries_Exhausted(t *testing.T) {
+	h := runtimetest.New(t)
+	h.Agent.On("deploy", runtimetest.Fail(2, "boom"))
+
+	done := make(chan *state.RunResult, 1)
+	go func() {
+		result, _ := h.Run(context.Background(), retryWorkflow(1, "1s"))
+		done <- result
+	}()
+	h.Clock.BlockUntil(1)
+	h.Clock.Advance(time.Second)
+	result := <-done
+
+	if result.Success || len(result.Tasks[0].Attempts) != 2 {
+		t.Errorf("result = %+v, want a failure after 2 attempts", result.Tasks[0])
+	}
+	if calls := h.Agent.Calls("deploy"); len(calls) != 2 {
+		t.Errorf("deploy ran %d times, want 2", len(calls))
+	}
+}
+
+func TestRetries_RetryOn(t *testing.T) {
+	tests := []struct {
+		name      string
+		retryOn   []string
+		response  runtimetest.Response
+		wantCalls int
+	}{
+		{"exit code matches", []string{"137"}, runtimetest.Fail(137, "killed"), 2},
+		{"exit code differs", []string{"137"}, runtimetest.Fail(1, "killed"), 1},
+		{"pattern matches", []string{"(?i)connection reset"}, runtimetest.Fail(1, "Error: Connection reset by peer"), 2},
+		{"pattern differs", []string{"(?i)connection reset"}, runtimetest.Fail(1, "syntax error"), 1},
+		{"auth is never retried", nil, runtimetest.Fail(1, "Invalid API key"), 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := runtimetest.New(t)
+			h.Agent.On("deploy", tt.response)
+
+			h.Run(context.Background(), retryWorkflow(1, "0s", tt.retryOn...))
+
+			if calls := h.Agent.Calls("deploy"); len(calls) != tt.wantCalls {
+				t.Errorf("deploy ran %d times, want %d", len(calls), tt.wantCalls)
+			}
+		})
+	}
+}
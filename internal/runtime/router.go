@@ -0,0 +1,84 @@
+package runtime
+
+import (
+	"context"
+	"sync"
+
+	"github.com/adityaraj/agentflow/internal/planner"
+)
+
+// Router enforces per-agent concurrency limits and routes tasks to an
+// interchangeable agent when their own agent is busy.
+type Router struct {
+	mu       sync.Mutex
+	limits   map[string]int // Agent name -> max concurrent tasks (absent = no limit)
+	inFlight map[string]int
+	freed    chan struct{} // Closed and replaced whenever a slot is released
+}
+
+// NewRouter creates a Router with the given per-agent limits.
+func NewRouter(limits map[string]int) *Router {
+	return &Router{
+		limits:   limits,
+		inFlight: make(map[string]int),
+		freed:    make(chan struct{}),
+	}
+}
+
+// Acquire reserves a slot on the first candidate with free concurrency,
+// waiting for a slot to free up if all are busy. Candidates are tried in
+// order, so the task's own agent is preferred. Returns the chosen index.
+func (r *Router) Acquire(ctx context.Context, candidates []string) (int, error) {
+	for {
+		r.mu.Lock()
+		for i, name := range candidates {
+			if limit, ok := r.limits[name]; !ok || r.inFlight[name] < limit {
+				r.inFlight[name]++
+				r.mu.Unlock()
+				return i, nil
+			}
+		}
+		freed := r.freed
+		r.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return -1, ctx.Err()
+		case <-freed:
+		}
+	}
+}
+
+// Release frees a slot reserved by Acquire.
+func (r *Router) Release(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.inFlight[name] > 0 {
+		r.inFlight[name]--
+	}
+	close(r.freed)
+	r.freed = make(chan struct{})
+}
+
+// route picks the agent a task runs on and reserves its slot.
+// The returned task has its agent, tool, model, system prompt, and
+// instructions replaced when routed to an alternate; release must be called when the task finishes.
+func (e *Executor) route(ctx context.Context, execTask planner.ExecutionTask) (planner.ExecutionTask, func(), error) {
+	candidates := []string{execTask.AgentName}
+	for _, alt := range execTask.Alternates {
+		candidates = append(candidates, alt.Name)
+	}
+
+	i, err := e.router.Acquire(ctx, candidates)
+	if err != nil {
+		return execTask, func() {}, err
+	}
+
+	routed := execTask
+	if i > 0 {
+		alt := execTask.Alternates[i-1]
+		routed.AgentName, routed.Tool, routed.Model = alt.Name, alt.Tool, alt.Model
+		routed.SystemPrompt, routed.Instructions = alt.SystemPrompt, alt.Instructions
+	}
+	return routed, func() { e.router.Release(routed.AgentName) }, nil
+}
@@ -0,0 +1,56 @@
+package runtime
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestRouter_Acquire(t *testing.T) {
+	r := NewRouter(map[string]int{"claude": 1, "api": 1})
+	ctx := context.Background()
+	candidates := []string{"claude", "api"}
+
+	if i, err := r.Acquire(ctx, candidates); err != nil || i != 0 {
+		t.Fatalf("first Acquire() = %d, %v; want preferred agent", i, err)
+	}
+	if i, err := r.Acquire(ctx, candidates); err != nil || i != 1 {
+		t.Fatalf("second Acquire() = %d, %v; want alternate", i, err)
+	}
+
+	// Both busy: the next task waits for a slot
+	got := make(chan int)
+	go func() {
+		i, _ := r.Acquire(ctx, candidates)
+		got <- i
+	}()
+
+	select {
+	case i := <-got:
+		t.Fatalf("Acquire() = %d while all agents busy, want it to wait", i)
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	r.Release("api")
+	select {
+	case i := <-got:
+		if i != 1 {
+			t.Errorf("Acquire() after release = %d, want 1", i)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Acquire() did not resume after Release()")
+	}
+}
+
+func TestRouter_AcquireCancelled(t *testing.T) {
+	r := NewRouter(map[string]int{"claude": 1})
+	if _, err := r.Acquire(context.Background(), []string{"claude"}); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := r.Acquire(ctx, []string{"claude"}); err == nil {
+		t.Error("Acquire() should fail when the context is done")
+	}
+}
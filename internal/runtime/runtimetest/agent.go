@@ -0,0 +1,168 @@
+package runtimetest
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/adityaraj/agentflow/internal/runtime"
+)
+
+// Response is what an Agent returns for one run of a task.
+type Response struct {
+	Stdout   string
+	Stderr   string
+	ExitCode int   // Non-zero fails the task
+	Err      error // Returned as the run's error, like a tool that couldn't start
+
+	// Delay is how long the run takes on the agent's clock. Hang keeps it
+	// running until its context is done, like a stuck tool; the run then
+	// fails with the context's error.
+	Delay time.Duration
+	Hang  bool
+
+	InputTokens  int
+	OutputTokens int
+
+	// Events are reported to the task's OnEvent callback before the run
+	// returns.
+	Events []runtime.AgentEvent
+
+	// Files are written before the run returns, like the edits of an agent:
+	// paths relative to the task's workdir mapped to their contents.
+	Files map[string]string
+}
+
+// OK returns a successful Response with stdout as the task's output.
+func OK(stdout string) Response {
+	return Response{Stdout: stdout}
+}
+
+// Fail returns a Response that fails with exitCode, writing stderr.
+func Fail(exitCode int, stderr string) Response {
+	return Response{Stderr: stderr, ExitCode: exitCode}
+}
+
+// RateLimited returns a Response an AI tool gives when the provider is rate
+// limiting it, which the executor retries after a cooldown.
+func RateLimited() Response {
+	return Fail(1, "API Error: 429 rate_limit_error: Too many requests")
+}
+
+// Agent is a fake runtime.Agent that plays back scripted Responses instead
+// of running a tool. It is safe for concurrent use.
+type Agent struct {
+	mu       sync.Mutex
+	clock    runtime.Clock
+	scripts  map[string][]Response
+	fallback Response
+	calls    []runtime.Task
+}
+
+// NewAgent returns an Agent that waits out Response delays on clock
+// (nil = runtime.SystemClock). Tasks without a script succeed with no
+// output.
+func NewAgent(clock runtime.Clock) *Agent {
+	if clock == nil {
+		clock = runtime.SystemClock
+	}
+	return &Agent{clock: clock, scripts: make(map[string][]Response)}
+}
+
+// On scripts the Responses for successive runs of task: the first run gets
+// the first Response, a retry the second, and so on. Runs after the last
+// get the last one again.
+func (a *Agent) On(task string, responses ...Response) *Agent {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.scripts[task] = responses
+	return a
+}
+
+// Default sets the Response for tasks without a script.
+func (a *Agent) Default(r Response) *Agent {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.fallback = r
+	return a
+}
+
+// Calls returns the tasks the agent was asked to run, in order. With a
+// name, only that task's runs are returned.
+func (a *Agent) Calls(task string) []runtime.Task {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	var calls []runtime.Task
+	for _, c := range a.calls {
+		if task == "" || c.Name == task {
+			calls = append(calls, c)
+		}
+	}
+	return calls
+}
+
+// Run implements runtime.Agent.
+func (a *Agent) Run(ctx context.Context, task runtime.Task) (runtime.Result, error) {
+	r := a.next(task)
+
+	if r.Delay > 0 || r.Hang {
+		var elapsed <-chan time.Time
+		if !r.Hang {
+			elapsed = a.clock.After(r.Delay)
+		}
+		select {
+		case <-ctx.Done():
+			return runtime.Result{ExitCode: -1, Stderr: r.Stderr}, context.Cause(ctx)
+		case <-elapsed:
+		}
+	}
+
+	for _, ev := range r.Events {
+		task.ReportEvent(ev)
+	}
+	if r.InputTokens > 0 || r.OutputTokens > 0 {
+		task.ReportUsage(runtime.Usage{InputTokens: r.InputTokens, OutputTokens: r.OutputTokens})
+	}
+	for path, content := range r.Files {
+		path = filepath.Join(task.Workdir, path)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return runtime.Result{ExitCode: -1}, err
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			return runtime.Result{ExitCode: -1}, err
+		}
+	}
+	if task.Progress != nil && r.Stdout != "" {
+		task.Progress.Write([]byte(r.Stdout))
+	}
+
+	return runtime.Result{
+		Stdout:       r.Stdout,
+		Stderr:       r.Stderr,
+		ExitCode:     r.ExitCode,
+		Success:      r.ExitCode == 0 && r.Err == nil,
+		InputTokens:  r.InputTokens,
+		OutputTokens: r.OutputTokens,
+	}, r.Err
+}
+
+// next records a run of task and returns its scripted Response.
+func (a *Agent) next(task runtime.Task) Response {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	attempt := 0
+	for _, c := range a.calls {
+		if c.Name == task.Name {
+			attempt++
+		}
+	}
+	a.calls = append(a.calls, task)
+
+	script, ok := a.scripts[task.Name]
+	if !ok || len(script) == 0 {
+		return a.fallback
+	}
+	return script[min(attempt, len(script)-1)]
+}
@@ -0,0 +1,94 @@
+// Package runtimetest provides fakes for testing code that runs workflows
+// with the runtime package, without spawning agent processes or waiting on
+// real time: a Clock the test moves forward itself, an Agent that plays
+// back scripted results, and a Recorder of what the executor did.
+//
+// A Harness puts them together:
+//
+//	h := runtimetest.New(t)
+//	h.Agent.On("review", runtimetest.RateLimited(), runtimetest.OK("lgtm"))
+//	go func() { h.Clock.BlockUntil(1); h.Clock.Advance(time.Minute) }()
+//	result, err := h.Run(ctx, cfg)
+package runtimetest
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock is a fake runtime.Clock. Time stands still until Advance moves it.
+type Clock struct {
+	mu      sync.Mutex
+	changed *sync.Cond // Broadcast when waiters are added
+	now     time.Time
+	waiters []waiter
+}
+
+// waiter is a pending After call.
+type waiter struct {
+	at time.Time
+	ch chan time.Time
+}
+
+// NewClock returns a Clock set to start.
+func NewClock(start time.Time) *Clock {
+	c := &Clock{now: start}
+	c.changed = sync.NewCond(&c.mu)
+	return c
+}
+
+// Now returns the clock's current time.
+func (c *Clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// After returns a channel that receives the time once the clock has been
+// advanced by d. It fires at once if d <= 0.
+func (c *Clock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ch := make(chan time.Time, 1)
+	if d <= 0 {
+		ch <- c.now
+		return ch
+	}
+	c.waiters = append(c.waiters, waiter{at: c.now.Add(d), ch: ch})
+	c.changed.Broadcast()
+	return ch
+}
+
+// Advance moves the clock forward by d, firing the After channels that
+// come due.
+func (c *Clock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+	pending := c.waiters[:0]
+	for _, w := range c.waiters {
+		if w.at.After(c.now) {
+			pending = append(pending, w)
+			continue
+		}
+		w.ch <- c.now
+	}
+	c.waiters = pending
+}
+
+// Waiters returns how many After channels have yet to fire.
+func (c *Clock) Waiters() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.waiters)
+}
+
+// BlockUntil waits until at least n After channels are pending, so a test
+// can advance the clock once the code under test is waiting on it.
+func (c *Clock) BlockUntil(n int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for len(c.waiters) < n {
+		c.changed.Wait()
+	}
+}
@@ -0,0 +1,83 @@
+package runtimetest
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/adityaraj/agentflow/internal/config"
+	"github.com/adityaraj/agentflow/internal/planner"
+	"github.com/adityaraj/agentflow/internal/runtime"
+	"github.com/adityaraj/agentflow/internal/state"
+)
+
+// Epoch is the time a Harness's clock starts at.
+var Epoch = time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+// Harness runs workflows with fakes in place of agent tools and time.
+type Harness struct {
+	Clock    *Clock
+	Agent    *Agent // Runs the tasks of every tool
+	Recorder *Recorder
+	Store    *state.RunStore // Session storage in a temporary directory
+	Dir      string          // Working directory for workflows that don't set one
+
+	// Options configure the executors the harness creates. Registry,
+	// Store, Clock, and OnProgress are filled in; Writer defaults to
+	// io.Discard and Heartbeat to off.
+	Options runtime.ExecutorConfig
+}
+
+// New returns a Harness whose files are removed when tb finishes.
+func New(tb testing.TB) *Harness {
+	tb.Helper()
+	clock := NewClock(Epoch)
+	store, err := state.NewRunStoreWithPath(tb.TempDir(), "project")
+	if err != nil {
+		tb.Fatalf("runtimetest: %v", err)
+	}
+	return &Harness{
+		Clock:    clock,
+		Agent:    NewAgent(clock),
+		Recorder: NewRecorder(clock),
+		Store:    store,
+		Dir:      tb.TempDir(),
+	}
+}
+
+// Executor returns an executor that runs every tool with h.Agent.
+func (h *Harness) Executor() *runtime.Executor {
+	registry := runtime.NewAgentRegistry()
+	agent := h.Recorder.Wrap(h.Agent)
+	for _, tool := range config.SupportedTools {
+		registry.Register(tool, agent)
+	}
+
+	cfg := h.Options
+	cfg.Registry = registry
+	cfg.Store = h.Store
+	cfg.Clock = h.Clock
+	cfg.OnProgress = h.Recorder.OnProgress
+	if cfg.Writer == nil {
+		cfg.Writer = io.Discard
+	}
+	if cfg.Heartbeat == 0 {
+		cfg.Heartbeat = -1
+	}
+	return runtime.NewExecutorWithConfig(cfg)
+}
+
+// Run plans and executes cfg with a new executor. Workflows without a
+// workdir run in h.Dir rather than the test's directory.
+func (h *Harness) Run(ctx context.Context, cfg *config.AgentflowConfig) (*state.RunResult, error) {
+	c := *cfg
+	if c.Workdir == "" {
+		c.Workdir = h.Dir
+	}
+	plan, err := planner.BuildPlan(&c)
+	if err != nil {
+		return nil, err
+	}
+	return h.Executor().Execute(ctx, plan)
+}
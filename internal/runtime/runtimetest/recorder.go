@@ -0,0 +1,95 @@
+package runtimetest
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/adityaraj/agentflow/internal/runtime"
+)
+
+// EventKind identifies a recorded event.
+type EventKind string
+
+// Recorded event kinds.
+const (
+	EventStart    EventKind = "start"    // An agent run began
+	EventFinish   EventKind = "finish"   // An agent run returned
+	EventProgress EventKind = "progress" // The executor reported progress
+)
+
+// Event is something the executor did, stamped with the recorder's clock.
+type Event struct {
+	Kind     EventKind
+	Time     time.Time
+	Task     string
+	Result   runtime.Result        // Finish only
+	Err      error                 // Finish only
+	Progress runtime.ProgressEvent // Progress only
+}
+
+// Recorder keeps a timeline of agent runs and progress events. It is safe
+// for concurrent use.
+type Recorder struct {
+	mu     sync.Mutex
+	clock  runtime.Clock
+	events []Event
+}
+
+// NewRecorder returns a Recorder that stamps events with clock's time
+// (nil = runtime.SystemClock).
+func NewRecorder(clock runtime.Clock) *Recorder {
+	if clock == nil {
+		clock = runtime.SystemClock
+	}
+	return &Recorder{clock: clock}
+}
+
+// Wrap returns agent with its runs recorded as start and finish events.
+func (r *Recorder) Wrap(agent runtime.Agent) runtime.Agent {
+	return recordedAgent{agent: agent, recorder: r}
+}
+
+// OnProgress records a progress event; use it as ExecutorConfig.OnProgress.
+func (r *Recorder) OnProgress(ev runtime.ProgressEvent) {
+	r.add(Event{Kind: EventProgress, Task: ev.Task, Progress: ev})
+}
+
+// Events returns the recorded events in order.
+func (r *Recorder) Events() []Event {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Event(nil), r.events...)
+}
+
+// Tasks returns the task of each event of kind, in order, e.g. the order
+// tasks started in.
+func (r *Recorder) Tasks(kind EventKind) []string {
+	var tasks []string
+	for _, ev := range r.Events() {
+		if ev.Kind == kind {
+			tasks = append(tasks, ev.Task)
+		}
+	}
+	return tasks
+}
+
+func (r *Recorder) add(ev Event) {
+	ev.Time = r.clock.Now()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, ev)
+}
+
+// recordedAgent records the runs of the agent it wraps.
+type recordedAgent struct {
+	agent    runtime.Agent
+	recorder *Recorder
+}
+
+func (a recordedAgent) Run(ctx context.Context, task runtime.Task) (runtime.Result, error) {
+	a.recorder.add(Event{Kind: EventStart, Task: task.Name})
+	result, err := a.agent.Run(ctx, task)
+	a.recorder.add(Event{Kind: EventFinish, Task: task.Name, Result: result, Err: err})
+	return result, err
+}
@@ -0,0 +1,132 @@
+package runtimetest
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/adityaraj/agentflow/internal/config"
+)
+
+// workflow returns a config with a claude-code agent and tasks whose
+// prompts are given as name=prompt, each needing the one before.
+func workflow(tasks ...string) *config.AgentflowConfig {
+	cfg := &config.AgentflowConfig{
+		Agents: map[string]config.AgentConfig{"ai": {Tool: "claude-code"}},
+		Tasks:  make(map[string]config.TaskConfig),
+	}
+	prev := ""
+	for _, t := range tasks {
+		name, prompt, _ := strings.Cut(t, "=")
+		task := config.TaskConfig{Agent: "ai", Prompt: prompt}
+		if prev != "" {
+			task.Needs = []string{prev}
+		}
+		cfg.Tasks[name] = task
+		prev = name
+	}
+	return cfg
+}
+
+func TestHarnessRun(t *testing.T) {
+	h := New(t)
+	h.Agent.On("analyze", OK("two bugs"))
+
+	result, err := h.Run(context.Background(), workflow("analyze=Look", "fix=Fix {{outputs.analyze}}"))
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if !result.Success || len(result.Tasks) != 2 {
+		t.Fatalf("result = %+v", result)
+	}
+	if got := h.Recorder.Tasks(EventStart); !slices.Equal(got, []string{"analyze", "fix"}) {
+		t.Errorf("start order = %v", got)
+	}
+	if calls := h.Agent.Calls("fix"); len(calls) != 1 || calls[0].Prompt != "Fix two bugs" {
+		t.Errorf("fix calls = %+v", calls)
+	}
+	if !result.StartTime.Equal(Epoch) || !result.EndTime.Equal(Epoch) {
+		t.Errorf("run times = %v - %v, want the fake clock's %v", result.StartTime, result.EndTime, Epoch)
+	}
+}
+
+func TestHarnessFailure(t *testing.T) {
+	h := New(t)
+	h.Agent.On("build", Fail(2, "compile error"))
+
+	result, err := h.Run(context.Background(), workflow("build=Build", "deploy=Deploy"))
+	if err == nil || result.Success {
+		t.Fatalf("expected the run to fail, got %+v", result)
+	}
+	if calls := h.Agent.Calls("deploy"); len(calls) != 0 {
+		t.Errorf("deploy ran after build failed")
+	}
+}
+
+func TestHarnessRateLimitRetry(t *testing.T) {
+	h := New(t)
+	h.Agent.On("review", RateLimited(), OK("lgtm"))
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := h.Run(context.Background(), workflow("review=Review"))
+		done <- err
+	}()
+
+	// The retry waits out the cooldown on the fake clock
+	h.Clock.BlockUntil(1)
+	h.Clock.Advance(time.Minute)
+	if err := <-done; err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if calls := h.Agent.Calls("review"); len(calls) != 2 {
+		t.Errorf("review ran %d times, want 2", len(calls))
+	}
+	finishes := 0
+	for _, ev := range h.Recorder.Events() {
+		if ev.Kind == EventFinish {
+			finishes++
+			if finishes == 2 && !ev.Time.Equal(Epoch.Add(time.Minute)) {
+				t.Errorf("retry finished at %v, want a minute after the start", ev.Time)
+			}
+		}
+	}
+}
+
+func TestHarnessTimeout(t *testing.T) {
+	h := New(t)
+	h.Agent.On("stuck", Response{Hang: true})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err := h.Run(ctx, workflow("stuck=Wait"))
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("err = %v, want a deadline error", err)
+	}
+}
+
+func TestClock(t *testing.T) {
+	c := NewClock(Epoch)
+	soon, later := c.After(time.Second), c.After(time.Hour)
+	c.Advance(time.Minute)
+
+	select {
+	case at := <-soon:
+		if !at.Equal(Epoch.Add(time.Minute)) {
+			t.Errorf("fired at %v", at)
+		}
+	default:
+		t.Error("After(1s) didn't fire after a minute")
+	}
+	select {
+	case <-later:
+		t.Error("After(1h) fired after a minute")
+	default:
+	}
+	if c.Waiters() != 1 {
+		t.Errorf("Waiters = %d, want 1", c.Waiters())
+	}
+}
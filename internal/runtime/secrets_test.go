@@ -0,0 +1,61 @@
+package runtime_test
+
+import (
+	"context"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/adityaraj/agentflow/internal/config"
+	"github.com/adityaraj/agentflow/internal/runtime/runtimetest"
+	"github.com/adityaraj/agentflow/internal/secrets"
+	"github.com/adityaraj/agentflow/internal/state"
+)
+
+// TestSecrets tests that agents get the secrets, and that what they print
+// of them is masked in their output, log, and saved result.
+func TestSecrets(t *testing.T) {
+	values := map[string]string{"TOKEN": "tok-123"}
+	h := runtimetest.New(t)
+	h.Options.Secrets = values
+	h.Store.SetSecrets(secrets.NewRedactor(values))
+	h.Agent.On("deploy", runtimetest.OK("using tok-123\n"))
+
+	cfg := &config.AgentflowConfig{
+		Agents: map[string]config.AgentConfig{"ai": {Tool: "claude-code"}},
+		Tasks: map[string]config.TaskConfig{
+			"deploy": {Agent: "ai", Prompt: "Deploy with {{secrets.TOKEN}}"},
+			"notify": {Agent: "ai", Prompt: "Report: {{outputs.deploy}}", Needs: []string{"deploy"}},
+		},
+	}
+	result, err := h.Run(context.Background(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	call := h.Agent.Calls("deploy")[0]
+	if call.Prompt != "Deploy with tok-123" || !slices.Contains(call.Env, "TOKEN=tok-123") {
+		t.Errorf("agent got prompt %q, env %q", call.Prompt, call.Env)
+	}
+	if got := h.Agent.Calls("notify")[0].Prompt; got != "Report: using [secret:TOKEN]\n" {
+		t.Errorf("dependent task's prompt = %q", got)
+	}
+
+	saved, err := state.ReadTaskResult(h.Store.RunDir(), "deploy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if saved.Prompt != "Deploy with [secret:TOKEN]" || saved.Stdout != "using [secret:TOKEN]\n" {
+		t.Errorf("saved prompt %q, stdout %q", saved.Prompt, saved.Stdout)
+	}
+	for _, task := range h.Store.Retained(result).Tasks {
+		if strings.Contains(task.Prompt+task.Stdout, "tok-123") {
+			t.Errorf("retained run has the secret: %+v", task)
+		}
+	}
+	log, err := os.ReadFile(state.TaskLogPath(h.Store.RunDir(), "deploy"))
+	if err != nil || string(log) != "using [secret:TOKEN]\n" {
+		t.Errorf("task log = %q, %v", log, err)
+	}
+}
@@ -0,0 +1,84 @@
+package runtime_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/adityaraj/agentflow/internal/config"
+	"github.com/adityaraj/agentflow/internal/runtime/runtimetest"
+	"github.com/adityaraj/agentflow/internal/state"
+)
+
+// memStore is a state.Store that keeps runs in memory.
+type memStore struct {
+	runs map[string]state.RunResult // By project/run ID
+}
+
+func (m *memStore) Save(project string, run *state.RunResult) error {
+	m.runs[project+"/"+run.RunID] = *run
+	return nil
+}
+
+func (m *memStore) Load(project, runID string) (*state.RunResult, error) {
+	run, ok := m.runs[project+"/"+runID]
+	if !ok {
+		return nil, os.ErrNotExist
+	}
+	return &run, nil
+}
+
+func (m *memStore) List(state.SessionFilter) ([]state.SessionInfo, error) { return nil, nil }
+func (m *memStore) Query(state.Query) ([]state.TaskRecord, error)         { return nil, nil }
+func (m *memStore) GC(state.GCPolicy) ([]state.SessionInfo, error)        { return nil, nil }
+
+func TestStoreBackend(t *testing.T) {
+	h := runtimetest.New(t)
+	h.Agent.On("deploy", runtimetest.OK("deployed"))
+	backend := &memStore{runs: map[string]state.RunResult{}}
+	h.Store.SetBackend(backend)
+
+	if _, err := h.Run(context.Background(), retryWorkflow(0, "")); err != nil {
+		t.Fatal(err)
+	}
+
+	run, err := backend.Load("project", h.Store.RunID())
+	if err != nil {
+		t.Fatalf("run not saved to the backend: %v", err)
+	}
+	if !run.Success || len(run.Tasks) != 1 || run.Tasks[0].Stdout != "deployed" {
+		t.Errorf("saved run = %+v", run)
+	}
+	// The run directory still holds the task results, but not the run record
+	if _, err := os.Stat(filepath.Join(h.Store.RunDir(), "deploy.json")); err != nil {
+		t.Error(err)
+	}
+	if _, err := os.Stat(filepath.Join(h.Store.RunDir(), "run.json")); !os.IsNotExist(err) {
+		t.Errorf("run.json written with a custom backend: %v", err)
+	}
+}
+
+func TestTaskLog(t *testing.T) {
+	h := runtimetest.New(t)
+	h.Agent.On("deploy", runtimetest.OK("deploying\ndeployed\n"))
+
+	if _, err := h.Run(context.Background(), retryWorkflow(0, "")); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(state.TaskLogPath(h.Store.RunDir(), "deploy"))
+	if err != nil || string(data) != "deploying\ndeployed\n" {
+		t.Errorf("task log = %q, %v", data, err)
+	}
+
+	// Logs would keep what retention redacts
+	h = runtimetest.New(t)
+	h.Agent.On("deploy", runtimetest.OK("deployed"))
+	h.Store.SetRetention(&config.RetentionConfig{Redact: config.StringList{config.RedactStdout}})
+	if _, err := h.Run(context.Background(), retryWorkflow(0, "")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(state.TaskLogPath(h.Store.RunDir(), "deploy")); !os.IsNotExist(err) {
+		t.Errorf("task log written with stdout redacted: %v", err)
+	}
+}
@@ -0,0 +1,42 @@
+package runtime
+
+import (
+	"io"
+	"os"
+
+	"github.com/adityaraj/agentflow/internal/ui"
+)
+
+// taskLog copies a task's output to its log file (see
+// state.RunStore.CreateTaskLog) on its way to progress. Readers follow the
+// file at their own pace, so a slow reader never holds up the agent.
+type taskLog struct {
+	progress io.Writer
+	file     *os.File
+}
+
+// Write copies p to the log, ignoring write errors so a full disk can't
+// disturb the agent.
+func (l *taskLog) Write(p []byte) (int, error) {
+	l.file.Write(p)
+	return l.progress.Write(p)
+}
+
+// openTaskLog wraps progress to also copy the task's output to its log,
+// with the run's secrets masked in both. The returned function closes the
+// log.
+func (e *Executor) openTaskLog(name string, progress io.Writer) (io.Writer, func()) {
+	file, err := e.store.CreateTaskLog(name)
+	if err != nil {
+		ui.Warning("Failed to create output log for %s: %s", name, err)
+	}
+	if file == nil {
+		masked := e.redactor.Writer(progress)
+		return masked, func() { masked.Close() }
+	}
+	masked := e.redactor.Writer(&taskLog{progress: progress, file: file})
+	return masked, func() {
+		masked.Close()
+		file.Close()
+	}
+}
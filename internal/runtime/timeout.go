@@ -0,0 +1,36 @@
+package runtime
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrTimeout is the cancellation cause of a task stopped for running longer
+// than its timeout.
+var ErrTimeout = errors.New("task timed out")
+
+// watchTimeout stops a running task, with ErrTimeout as the cause, once it
+// has run for timeout (0 = never). Cancelling the task's context kills its
+// tool's process group (see proc.Command). The returned function stops
+// watching.
+func (e *Executor) watchTimeout(timeout time.Duration, stop context.CancelCauseFunc) func() {
+	if timeout <= 0 {
+		return func() {}
+	}
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-done:
+		case <-e.clock.After(timeout):
+			stop(fmt.Errorf("%w after %s", ErrTimeout, timeout))
+		}
+	}()
+	return func() { close(done) }
+}
+
+// timedOut reports whether ctx was cancelled by a task timeout.
+func timedOut(ctx context.Context) bool {
+	return errors.Is(context.Cause(ctx), ErrTimeout)
+}
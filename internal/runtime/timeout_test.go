@@ -0,0 +1,39 @@
+package runtime_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/adityaraj/agentflow/internal/runtime"
+	"github.com/adityaraj/agentflow/internal/runtime/runtimetest"
+	"github.com/adityaraj/agentflow/internal/state"
+)
+
+func TestTimeout(t *testing.T) {
+	h := runtimetest.New(t)
+	h.Agent.On("deploy", runtimetest.Response{Hang: true})
+	cfg := retryWorkflow(0, "")
+	task := cfg.Tasks["deploy"]
+	task.Timeout = "10m"
+	cfg.Tasks["deploy"] = task
+
+	done := make(chan error, 1)
+	var result *state.RunResult
+	go func() {
+		var err error
+		result, err = h.Run(context.Background(), cfg)
+		done <- err
+	}()
+	h.Clock.BlockUntil(1)
+	h.Clock.Advance(10 * time.Minute)
+
+	err := <-done
+	if !errors.Is(err, runtime.ErrTimeout) {
+		t.Fatalf("Run() error = %v, want ErrTimeout", err)
+	}
+	if got := result.Tasks[0]; got.Success || got.ErrorCategory != state.ErrorTimeout || got.ErrorCode != "CORTEX-RUN-010" {
+		t.Errorf("task = %+v, want a timeout failure", got)
+	}
+}
@@ -0,0 +1,62 @@
+package runtime
+
+import (
+	"sync"
+
+	"github.com/adityaraj/agentflow/internal/state"
+)
+
+// maxToolOutput caps how much of each tool result is kept in a transcript.
+const maxToolOutput = 4096
+
+// transcript collects the tool calls an agent makes during a task from its
+// structured events, pairing each call with its result.
+type transcript struct {
+	mu    sync.Mutex
+	calls []state.ToolCall
+	byID  map[string]int // Tool call ID -> index in calls
+}
+
+// record adds a tool call, or fills in the result or edit flag of an
+// earlier one. Text events are ignored.
+func (t *transcript) record(ev AgentEvent) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	switch ev.Type {
+	case AgentEventToolUse:
+		if t.byID == nil {
+			t.byID = make(map[string]int)
+		}
+		if ev.ID != "" {
+			t.byID[ev.ID] = len(t.calls)
+		}
+		t.calls = append(t.calls, state.ToolCall{
+			Time:    ev.Time,
+			Tool:    ev.Tool,
+			ID:      ev.ID,
+			Summary: ev.Summary,
+			Path:    ev.Path,
+			Input:   ev.Input,
+		})
+	case AgentEventFileEdit:
+		if i, ok := t.byID[ev.ID]; ok {
+			t.calls[i].Edit = true
+		}
+	case AgentEventToolResult:
+		if i, ok := t.byID[ev.ID]; ok {
+			t.calls[i].Output = truncateMiddle(ev.Text, maxToolOutput)
+			t.calls[i].IsError = ev.IsError
+		}
+	}
+}
+
+// entries returns the recorded tool calls in order, or nil if there are none.
+func (t *transcript) entries() []state.ToolCall {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(t.calls) == 0 {
+		return nil
+	}
+	return append([]state.ToolCall(nil), t.calls...)
+}
@@ -0,0 +1,33 @@
+package runtime
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTranscript(t *testing.T) {
+	var tr transcript
+	tr.record(AgentEvent{Type: AgentEventToolUse, Tool: "Bash", ID: "1", Summary: "go test ./..."})
+	tr.record(AgentEvent{Type: AgentEventText, Text: "Tests fail; fixing."})
+	tr.record(AgentEvent{Type: AgentEventToolUse, Tool: "Edit", ID: "2", Path: "main.go"})
+	tr.record(AgentEvent{Type: AgentEventFileEdit, Tool: "Edit", ID: "2", Path: "main.go"})
+	tr.record(AgentEvent{Type: AgentEventToolResult, ID: "1", Text: strings.Repeat("x", 2*maxToolOutput), IsError: true})
+	tr.record(AgentEvent{Type: AgentEventToolResult, ID: "2", Text: "ok"})
+	tr.record(AgentEvent{Type: AgentEventToolResult, ID: "unknown", Text: "ignored"})
+
+	calls := tr.entries()
+	if len(calls) != 2 {
+		t.Fatalf("got %d calls, want 2", len(calls))
+	}
+	if c := calls[0]; c.Tool != "Bash" || !c.IsError || c.Edit || len(c.Output) > maxToolOutput+100 {
+		t.Errorf("bash call = %+v", c)
+	}
+	if c := calls[1]; c.Tool != "Edit" || !c.Edit || c.Output != "ok" || c.Path != "main.go" {
+		t.Errorf("edit call = %+v", c)
+	}
+
+	var empty transcript
+	if empty.entries() != nil {
+		t.Error("empty transcript should have no entries")
+	}
+}
@@ -0,0 +1,36 @@
+package runtime_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/adityaraj/agentflow/internal/config"
+	"github.com/adityaraj/agentflow/internal/runtime/runtimetest"
+)
+
+// TestVariables tests that {{vars.X}} is filled in prompts and
+// conditions.
+func TestVariables(t *testing.T) {
+	h := runtimetest.New(t)
+	h.Agent.On("review", runtimetest.OK("done"))
+	h.Agent.On("skip", runtimetest.OK("done"))
+
+	cfg := &config.AgentflowConfig{
+		Agents: map[string]config.AgentConfig{"ai": {Tool: "claude-code"}},
+		Tasks: map[string]config.TaskConfig{
+			"review": {Agent: "ai", Prompt: "Review {{vars.service}}, not \\{{vars.service}}", When: "{{vars.service}} == billing"},
+			"skip":   {Agent: "ai", Prompt: "Review", When: "{{vars.service}} == auth"},
+		},
+		Variables: map[string]string{"service": "billing"},
+	}
+	if _, err := h.Run(context.Background(), cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := h.Agent.Calls("review")[0].Prompt, "Review billing, not {{vars.service}}"; got != want {
+		t.Errorf("prompt = %q, want %q", got, want)
+	}
+	if calls := h.Agent.Calls("skip"); len(calls) != 0 {
+		t.Errorf("task with a false condition on a variable ran")
+	}
+}
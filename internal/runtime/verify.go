@@ -0,0 +1,72 @@
+package runtime
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/adityaraj/agentflow/internal/planner"
+	"github.com/adityaraj/agentflow/internal/proc"
+	"github.com/adityaraj/agentflow/internal/state"
+)
+
+// maxFixOutput caps how much verify output is fed back to the agent in a fix attempt.
+const maxFixOutput = 8 * 1024
+
+// runVerify executes a task's verify command, with shell unless it is an
+// argument list and with env added to its environment, and captures its
+// combined output.
+func runVerify(ctx context.Context, task planner.ExecutionTask, shell string, env []string) state.VerifyResult {
+	var cmd *exec.Cmd
+	command := task.Verify
+	if task.VerifyArgs != nil {
+		command = proc.FormatArgs(task.VerifyArgs)
+		cmd = proc.Command(ctx, task.VerifyArgs[0], task.VerifyArgs[1:]...)
+	} else {
+		cmd = proc.ShellWith(ctx, shell, command)
+	}
+	if dir := filepath.Join(task.Workdir, task.VerifyDir); dir != "" {
+		cmd.Dir = dir
+	}
+	if len(env) > 0 {
+		cmd.Env = append(os.Environ(), env...)
+	}
+
+	var output bytes.Buffer
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+
+	result := state.VerifyResult{
+		Command: command,
+		Success: true,
+	}
+
+	if err := cmd.Run(); err != nil {
+		result.Success = false
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			result.ExitCode = exitErr.ExitCode()
+		} else {
+			result.ExitCode = -1
+			output.WriteString(fmt.Sprintf("\nfailed to run verify command: %s", err))
+		}
+	}
+
+	result.Output = output.String()
+	return result
+}
+
+// buildFixPrompt appends a failed verification report to the original prompt
+// so the agent can repair its changes.
+func buildFixPrompt(prompt string, verify state.VerifyResult) string {
+	output := verify.Output
+	if len(output) > maxFixOutput {
+		output = "...\n" + output[len(output)-maxFixOutput:]
+	}
+
+	return fmt.Sprintf("%s\n\n---\nYour previous changes failed verification.\n"+
+		"Command: %s (exit code %d)\nOutput:\n%s\n\nFix the problems so the command succeeds.",
+		prompt, verify.Command, verify.ExitCode, output)
+}
@@ -0,0 +1,49 @@
+package runtime_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/adityaraj/agentflow/internal/config"
+	"github.com/adityaraj/agentflow/internal/runtime/runtimetest"
+)
+
+// TestTaskWorkdir tests that a task runs in its own workdir, created if
+// missing, and that results record where each task ran.
+func TestTaskWorkdir(t *testing.T) {
+	h := runtimetest.New(t)
+	h.Agent.On("build", runtimetest.OK("built"))
+	h.Agent.On("lint", runtimetest.OK("linted"))
+
+	cfg := &config.AgentflowConfig{
+		Agents: map[string]config.AgentConfig{"ai": {Tool: "claude-code"}},
+		Tasks: map[string]config.TaskConfig{
+			"build": {Agent: "ai", Prompt: "Build", Workdir: "services/api"},
+			"lint":  {Agent: "ai", Prompt: "Lint"},
+		},
+	}
+	run, err := h.Run(context.Background(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{
+		"build": filepath.Join(h.Dir, "services", "api"),
+		"lint":  h.Dir,
+	}
+	if info, err := os.Stat(want["build"]); err != nil || !info.IsDir() {
+		t.Errorf("workdir not created: %v", err)
+	}
+	for task, dir := range want {
+		if got := h.Agent.Calls(task)[0].Workdir; got != dir {
+			t.Errorf("%s ran in %s, want %s", task, got, dir)
+		}
+	}
+	for _, r := range run.Tasks {
+		if r.Workdir != want[r.TaskName] {
+			t.Errorf("%s result workdir = %s, want %s", r.TaskName, r.Workdir, want[r.TaskName])
+		}
+	}
+}
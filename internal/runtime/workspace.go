@@ -0,0 +1,131 @@
+package runtime
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/adityaraj/agentflow/internal/git"
+	"github.com/adityaraj/agentflow/internal/planner"
+)
+
+// WorkspacesDir is where, under the repository root, runs with
+// settings.workspace get their copy of the repository.
+const WorkspacesDir = ".cortex/workspaces"
+
+// WorkspacePatchFile is the run file a workspace run's changes are saved
+// to, as a patch to apply to the checkout.
+const WorkspacePatchFile = "workspace.patch"
+
+// Workspace is a run's copy of the repository: a worktree of the checkout
+// as it was when the run started, uncommitted work included, so agents
+// can change it without touching the developer's files.
+type Workspace struct {
+	Root string // Root of the checkout the workspace copies
+	Dir  string // The copy, <Root>/.cortex/workspaces/<run-id>
+	Base string // Commit of the checkout's state the copy started from
+}
+
+// PrepareWorkspace creates the run's workspace and moves the plan's tasks
+// into it: each task's workdir becomes the same directory in the copy.
+// The tasks must run inside the repository containing the working
+// directory; read-only tasks outside it stay where they are.
+func PrepareWorkspace(plan *planner.ExecutionPlan, runID string) (*Workspace, error) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return nil, err
+	}
+	if !git.IsRepo(cwd) {
+		return nil, fmt.Errorf("settings.workspace needs a git repository; %s isn't in one", cwd)
+	}
+	root, err := git.Root(cwd)
+	if err != nil {
+		return nil, err
+	}
+
+	// Find each task's directory in the copy before creating it
+	workdirs := make([]string, len(plan.Tasks))
+	for i, task := range plan.Tasks {
+		rel, ok := relToRoot(root, cwd, task.Workdir)
+		if !ok {
+			if task.Write {
+				return nil, fmt.Errorf("task %q: workdir %s is outside the repository, so settings.workspace can't keep it from the checkout", task.Name, task.Workdir)
+			}
+			workdirs[i] = task.Workdir
+			continue
+		}
+		workdirs[i] = rel
+	}
+
+	w, err := newWorkspace(root, runID, "cortex: checkout before run "+runID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range plan.Tasks {
+		if !filepath.IsAbs(workdirs[i]) {
+			plan.Tasks[i].Workdir = filepath.Join(w.Dir, workdirs[i])
+		}
+	}
+	return w, nil
+}
+
+// newWorkspace creates the workspace <root>/.cortex/workspaces/<name>,
+// recording the checkout at root as a commit with msg.
+func newWorkspace(root, name, msg string) (*Workspace, error) {
+	// The workspaces ignore themselves, so they never show up as changes
+	// in the checkout or in each other
+	parent := filepath.Join(root, WorkspacesDir)
+	if err := os.MkdirAll(parent, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create %s: %w", WorkspacesDir, err)
+	}
+	if err := os.WriteFile(filepath.Join(parent, ".gitignore"), []byte("*\n"), 0644); err != nil {
+		return nil, fmt.Errorf("failed to create %s: %w", WorkspacesDir, err)
+	}
+
+	base, err := git.SnapshotCommit(root, msg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to record the checkout: %w", err)
+	}
+	w := &Workspace{Root: root, Dir: filepath.Join(parent, name), Base: base}
+	if err := git.AddWorktree(root, w.Dir, base); err != nil {
+		return nil, fmt.Errorf("failed to create workspace: %w", err)
+	}
+	return w, nil
+}
+
+// relToRoot returns workdir ("" = cwd), relative to the repository root,
+// and whether it's inside the repository.
+func relToRoot(root, cwd, workdir string) (string, bool) {
+	dir := workdir
+	if dir == "" {
+		dir = cwd
+	} else if !filepath.IsAbs(dir) {
+		dir = filepath.Join(cwd, dir)
+	}
+	// git reports the root with symlinks resolved
+	if resolved, err := filepath.EvalSymlinks(dir); err == nil {
+		dir = resolved
+	}
+	rel, err := filepath.Rel(root, dir)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+	return rel, true
+}
+
+// Patch returns the changes the run made in the workspace, as a patch to
+// apply at the checkout's root ("" if it made none).
+func (w *Workspace) Patch() (string, error) {
+	return git.Patch(w.Dir, w.Base)
+}
+
+// Apply applies patch, from Patch, to the checkout.
+func (w *Workspace) Apply(patch string) error {
+	return git.ApplyPatch(w.Root, patch)
+}
+
+// Remove deletes the workspace.
+func (w *Workspace) Remove() error {
+	return git.RemoveWorktree(w.Dir)
+}
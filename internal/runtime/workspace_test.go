@@ -0,0 +1,85 @@
+package runtime
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/adityaraj/agentflow/internal/planner"
+)
+
+func TestWorkspace(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available")
+	}
+	repo, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(repo, "api"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(repo, "api", "main.go"), []byte("package main\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for _, args := range [][]string{
+		{"init", "-q"},
+		{"add", "."},
+		{"-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "-qm", "init"},
+	} {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = repo
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %s: %s", args[0], out)
+		}
+	}
+	t.Chdir(repo)
+
+	outside := t.TempDir()
+	plan := &planner.ExecutionPlan{Tasks: []planner.ExecutionTask{
+		{Name: "fix", Write: true},
+		{Name: "test", Workdir: "api"},
+		{Name: "docs", Workdir: outside},
+	}}
+	ws, err := PrepareWorkspace(plan, "20240115-100000")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ws.Remove()
+
+	wantDir := filepath.Join(repo, WorkspacesDir, "20240115-100000")
+	if ws.Dir != wantDir {
+		t.Errorf("workspace = %s, want %s", ws.Dir, wantDir)
+	}
+	for i, want := range []string{wantDir, filepath.Join(wantDir, "api"), outside} {
+		if got := plan.Tasks[i].Workdir; got != want {
+			t.Errorf("task %s workdir = %s, want %s", plan.Tasks[i].Name, got, want)
+		}
+	}
+
+	// Changes stay in the workspace until the patch is applied
+	if err := os.WriteFile(filepath.Join(wantDir, "api", "main.go"), []byte("package api\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if out, _ := exec.Command("git", "-C", repo, "status", "--porcelain").Output(); len(out) != 0 {
+		t.Errorf("checkout changed: %s", out)
+	}
+	patch, err := ws.Patch()
+	if err != nil || !strings.Contains(patch, "+package api") {
+		t.Fatalf("Patch() = %q, %v", patch, err)
+	}
+	if err := ws.Apply(patch); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(repo, "api", "main.go")); string(data) != "package api\n" {
+		t.Errorf("api/main.go after Apply() = %q", data)
+	}
+
+	// Write tasks can't escape the workspace
+	plan = &planner.ExecutionPlan{Tasks: []planner.ExecutionTask{{Name: "fix", Write: true, Workdir: outside}}}
+	if _, err := PrepareWorkspace(plan, "20240115-110000"); err == nil || !strings.Contains(err.Error(), "outside the repository") {
+		t.Errorf("PrepareWorkspace() with a write task outside the repository: %v", err)
+	}
+}
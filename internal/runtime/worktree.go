@@ -0,0 +1,82 @@
+package runtime
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/adityaraj/agentflow/internal/config"
+	"github.com/adityaraj/agentflow/internal/git"
+	"github.com/adityaraj/agentflow/internal/planner"
+	"github.com/adityaraj/agentflow/internal/snapshot"
+	"github.com/adityaraj/agentflow/internal/ui"
+)
+
+// PrepareWorkingTree applies the dirty-tree policy before a run that contains
+// write tasks, so agent changes don't get entangled with uncommitted work.
+// Returns a function that undoes any preparation after the run; it is never nil.
+func PrepareWorkingTree(plan *planner.ExecutionPlan, policy, runID string) (func(), error) {
+	noop := func() {}
+
+	dir := writeWorkdir(plan)
+	if dir == "" || !git.IsRepo(dir) {
+		return noop, nil
+	}
+
+	dirty, err := git.IsDirty(dir)
+	if err != nil || !dirty {
+		return noop, err
+	}
+
+	switch policy {
+	case config.DirtyTreeRefuse:
+		return noop, fmt.Errorf("working tree has uncommitted changes; commit or stash them first, " +
+			"or set 'dirty_tree: stash' in settings")
+
+	case config.DirtyTreeStash:
+		commit, err := git.Stash(dir, "cortex: before run "+runID)
+		if err != nil {
+			return noop, fmt.Errorf("failed to stash uncommitted changes: %w", err)
+		}
+		ui.Info("Stashed uncommitted changes (restored after the run)")
+		return func() {
+			if err := git.StashPop(dir, commit); err != nil {
+				ui.Warning("Could not restore stashed changes: %s", err)
+				ui.Warning("Your work is kept in the stash as %s; run 'git stash list' to find it", commit[:7])
+			}
+		}, nil
+
+	default:
+		ui.Warning("Working tree has uncommitted changes; agent edits will be mixed with them")
+		return noop, nil
+	}
+}
+
+// SnapshotWorkdir captures the directory write tasks run in, storing the
+// snapshot alongside the run's results. Does nothing if the plan has no write tasks.
+func SnapshotWorkdir(plan *planner.ExecutionPlan, runDir, runID string) (*snapshot.Snapshot, error) {
+	dir := writeWorkdir(plan)
+	if dir == "" {
+		return nil, nil
+	}
+	return snapshot.Create(dir, runDir, runID)
+}
+
+// writeWorkdir returns the directory write tasks run in, or "" if the plan
+// has no write tasks. Tasks with 'write: patch' don't count: they leave the
+// checkout alone.
+func writeWorkdir(plan *planner.ExecutionPlan) string {
+	for _, task := range plan.Tasks {
+		if !task.Write || task.WritePatch {
+			continue
+		}
+		if task.Workdir != "" {
+			return task.Workdir
+		}
+		cwd, err := os.Getwd()
+		if err != nil {
+			return ""
+		}
+		return cwd
+	}
+	return ""
+}
@@ -0,0 +1,57 @@
+package runtime
+
+import (
+	"sync"
+
+	"github.com/adityaraj/agentflow/internal/glob"
+	"github.com/adityaraj/agentflow/internal/planner"
+	"github.com/adityaraj/agentflow/internal/ui"
+)
+
+// writeLocks returns the locks each of tasks, which may run at once, holds
+// while it runs so that tasks whose writes globs overlap run one at a
+// time: one lock per overlapping pair, listed in the same order for every
+// task so that tasks taking several can't deadlock.
+func writeLocks(tasks []planner.ExecutionTask) map[string][]*sync.Mutex {
+	locks := make(map[string][]*sync.Mutex)
+	for i, a := range tasks {
+		for _, b := range tasks[i+1:] {
+			if !writesOverlap(a, b) {
+				continue
+			}
+			ui.Info("%s and %s write overlapping paths, so they run one at a time", a.Name, b.Name)
+			mu := &sync.Mutex{}
+			locks[a.Name] = append(locks[a.Name], mu)
+			locks[b.Name] = append(locks[b.Name], mu)
+		}
+	}
+	return locks
+}
+
+// writesOverlap reports whether a and b could change the same files. Tasks
+// with 'write: patch' change copies of their own, so never do.
+func writesOverlap(a, b planner.ExecutionTask) bool {
+	if a.WritePatch || b.WritePatch {
+		return false
+	}
+	for _, x := range a.Writes {
+		for _, y := range b.Writes {
+			if glob.Overlap(x, y) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// lockWrites takes locks, in order, and returns a function releasing them.
+func lockWrites(locks []*sync.Mutex) func() {
+	for _, mu := range locks {
+		mu.Lock()
+	}
+	return func() {
+		for _, mu := range locks {
+			mu.Unlock()
+		}
+	}
+}
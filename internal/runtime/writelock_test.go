@@ -0,0 +1,45 @@
+package runtime
+
+import (
+	"testing"
+	"time"
+
+	"github.com/adityaraj/agentflow/internal/planner"
+)
+
+func TestWriteLocks(t *testing.T) {
+	locks := writeLocks([]planner.ExecutionTask{
+		{Name: "api", Write: true, Writes: []string{"api/**"}},
+		{Name: "handler", Write: true, Writes: []string{"docs/*.md", "api/handler.go"}},
+		{Name: "web", Write: true, Writes: []string{"web/**"}},
+		{Name: "draft", Write: true, WritePatch: true, Writes: []string{"api/**"}},
+		{Name: "review"},
+	})
+	if len(locks["api"]) != 1 || len(locks["handler"]) != 1 || locks["api"][0] != locks["handler"][0] {
+		t.Fatalf("api and handler locks = %v, %v; want one shared lock", locks["api"], locks["handler"])
+	}
+	for _, name := range []string{"web", "draft", "review"} {
+		if len(locks[name]) != 0 {
+			t.Errorf("%s has locks %v, want none", name, locks[name])
+		}
+	}
+
+	// The second task waits until the first is done
+	unlock := lockWrites(locks["api"])
+	done := make(chan struct{})
+	go func() {
+		lockWrites(locks["handler"])()
+		close(done)
+	}()
+	select {
+	case <-done:
+		t.Fatal("handler ran while api held the lock")
+	case <-time.After(20 * time.Millisecond):
+	}
+	unlock()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("handler didn't run after api released the lock")
+	}
+}
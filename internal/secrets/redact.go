@@ -0,0 +1,155 @@
+package secrets
+
+import (
+	"io"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Mask is what a secret's value is replaced with, e.g. "[secret:API_KEY]".
+func Mask(name string) string {
+	return "[secret:" + name + "]"
+}
+
+// Redactor masks the values of secrets in text. A nil Redactor masks
+// nothing.
+type Redactor struct {
+	secrets []secret  // Longest value first, so it wins over its prefixes
+	first   [256]bool // First bytes of the values, to skip text quickly
+}
+
+type secret struct {
+	name, value string
+}
+
+// NewRedactor returns a Redactor for values, keyed by secret name, or nil
+// if there are none.
+func NewRedactor(values map[string]string) *Redactor {
+	r := &Redactor{}
+	for name, value := range values {
+		if value == "" {
+			continue
+		}
+		r.secrets = append(r.secrets, secret{name, value})
+		r.first[value[0]] = true
+	}
+	if len(r.secrets) == 0 {
+		return nil
+	}
+	sort.Slice(r.secrets, func(i, j int) bool {
+		a, b := r.secrets[i], r.secrets[j]
+		if len(a.value) != len(b.value) {
+			return len(a.value) > len(b.value)
+		}
+		return a.name < b.name
+	})
+	return r
+}
+
+// Redact returns text with every secret's value replaced by its Mask.
+func (r *Redactor) Redact(text string) string {
+	if r == nil {
+		return text
+	}
+	masked, _ := r.redact(text, true)
+	return masked
+}
+
+// redact masks the secrets in text. Unless final, it stops where the rest
+// of text could be the start of a secret, and returns that rest to be
+// masked together with the text that follows it.
+func (r *Redactor) redact(text string, final bool) (masked, rest string) {
+	var b strings.Builder
+	start := 0
+	for i := 0; i < len(text); i++ {
+		if !r.first[text[i]] {
+			continue
+		}
+		if !final && r.partial(text[i:]) {
+			b.WriteString(text[start:i])
+			return b.String(), text[i:]
+		}
+		if s, ok := r.match(text[i:]); ok {
+			b.WriteString(text[start:i])
+			b.WriteString(Mask(s.name))
+			i += len(s.value) - 1
+			start = i + 1
+		}
+	}
+	if start == 0 {
+		return text, ""
+	}
+	b.WriteString(text[start:])
+	return b.String(), ""
+}
+
+// match returns the longest secret text starts with.
+func (r *Redactor) match(text string) (secret, bool) {
+	for _, s := range r.secrets {
+		if strings.HasPrefix(text, s.value) {
+			return s, true
+		}
+	}
+	return secret{}, false
+}
+
+// partial reports whether text is the start of a secret, but not all of it.
+func (r *Redactor) partial(text string) bool {
+	for _, s := range r.secrets {
+		if len(text) < len(s.value) && strings.HasPrefix(s.value, text) {
+			return true
+		}
+	}
+	return false
+}
+
+// Writer returns a writer that writes to w with the secrets masked, even
+// ones split across writes: text that could be the start of a secret is
+// held back until what follows shows whether it is. Close writes what is
+// held back; it doesn't close w. Writes may come from several goroutines.
+func (r *Redactor) Writer(w io.Writer) io.WriteCloser {
+	if r == nil {
+		return nopCloser{w}
+	}
+	return &writer{r: r, w: w}
+}
+
+type writer struct {
+	r *Redactor
+	w io.Writer
+
+	mu      sync.Mutex
+	pending string // Held back, as it may be the start of a secret
+}
+
+func (w *writer) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	masked, rest := w.r.redact(w.pending+string(p), false)
+	w.pending = rest
+	if masked != "" {
+		if _, err := io.WriteString(w.w, masked); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+func (w *writer) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.pending == "" {
+		return nil
+	}
+	masked := w.r.Redact(w.pending)
+	w.pending = ""
+	_, err := io.WriteString(w.w, masked)
+	return err
+}
+
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error { return nil }
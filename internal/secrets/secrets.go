@@ -0,0 +1,145 @@
+// Package secrets resolves the values of a Cortexfile's secrets: block and
+// masks them in text Cortex shows or saves.
+//
+// Each secret names where its value comes from as "provider:ref", e.g.
+// "env:GH_TOKEN", "file:~/.config/db-password", or "command:op read
+// op://dev/api/key". The env, file, and command providers are built in;
+// others, such as a vault client built in with a build tag, register
+// themselves with RegisterProvider.
+package secrets
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/adityaraj/agentflow/internal/proc"
+)
+
+// Provider returns the value ref refers to, e.g. the contents of the file
+// for the file provider.
+type Provider func(ctx context.Context, ref string) (string, error)
+
+// CommandTimeout is how long the command provider waits for a command.
+const CommandTimeout = 30 * time.Second
+
+var (
+	providersMu sync.Mutex
+	providers   = map[string]Provider{
+		"env":     fromEnv,
+		"file":    fromFile,
+		"command": fromCommand,
+	}
+)
+
+// RegisterProvider makes a Provider available to secrets as "name:ref".
+// Providers built in with a build tag register themselves from init.
+func RegisterProvider(name string, p Provider) {
+	providersMu.Lock()
+	defer providersMu.Unlock()
+	providers[name] = p
+}
+
+// ProviderNames returns the names of the registered providers, sorted.
+func ProviderNames() []string {
+	providersMu.Lock()
+	defer providersMu.Unlock()
+	names := make([]string, 0, len(providers))
+	for name := range providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Resolve returns the value of each secret in specs, which maps names to
+// "provider:ref" sources. Secrets can't be empty, as empty values can't be
+// masked.
+func Resolve(ctx context.Context, specs map[string]string) (map[string]string, error) {
+	names := make([]string, 0, len(specs))
+	for name := range specs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	values := make(map[string]string, len(specs))
+	for _, name := range names {
+		value, err := resolve(ctx, specs[name])
+		if err != nil {
+			return nil, fmt.Errorf("secret %q: %w", name, err)
+		}
+		if value == "" {
+			return nil, fmt.Errorf("secret %q: %s is empty", name, specs[name])
+		}
+		values[name] = value
+	}
+	return values, nil
+}
+
+// resolve returns the value of one "provider:ref" source.
+func resolve(ctx context.Context, spec string) (string, error) {
+	name, ref, ok := strings.Cut(spec, ":")
+	if !ok {
+		return "", fmt.Errorf("source %q isn't of the form provider:ref", spec)
+	}
+	providersMu.Lock()
+	p, ok := providers[name]
+	providersMu.Unlock()
+	if !ok {
+		return "", fmt.Errorf("unknown provider %q: this build supports %s", name, strings.Join(ProviderNames(), ", "))
+	}
+	return p(ctx, strings.TrimSpace(ref))
+}
+
+// fromEnv reads an environment variable, which must be set.
+func fromEnv(_ context.Context, name string) (string, error) {
+	value, ok := os.LookupEnv(name)
+	if !ok {
+		return "", fmt.Errorf("environment variable %s is not set", name)
+	}
+	return value, nil
+}
+
+// fromFile reads a file, "~/" meaning the home directory, without its
+// trailing newline.
+func fromFile(_ context.Context, path string) (string, error) {
+	if rest, ok := strings.CutPrefix(path, "~/"); ok {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		path = filepath.Join(home, rest)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
+
+// fromCommand runs a shell command, such as a password manager's CLI, and
+// returns its output without the trailing newline.
+func fromCommand(ctx context.Context, command string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, CommandTimeout)
+	defer cancel()
+	var stdout, stderr bytes.Buffer
+	cmd := proc.Shell(ctx, command)
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return "", fmt.Errorf("command timed out after %s", CommandTimeout)
+		}
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("command failed: %w: %s", err, msg)
+		}
+		return "", fmt.Errorf("command failed: %w", err)
+	}
+	return strings.TrimRight(stdout.String(), "\r\n"), nil
+}
@@ -0,0 +1,89 @@
+package secrets
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestResolve(t *testing.T) {
+	t.Setenv("CORTEX_TEST_TOKEN", "tok-123")
+	path := filepath.Join(t.TempDir(), "password")
+	if err := os.WriteFile(path, []byte("hunter2\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	specs := map[string]string{
+		"TOKEN":    "env:CORTEX_TEST_TOKEN",
+		"PASSWORD": "file:" + path,
+	}
+	if runtime.GOOS != "windows" {
+		specs["KEY"] = "command: printf 'key-456\\n'"
+	}
+
+	values, err := Resolve(context.Background(), specs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"TOKEN": "tok-123", "PASSWORD": "hunter2", "KEY": "key-456"}
+	for name := range specs {
+		if values[name] != want[name] {
+			t.Errorf("%s = %q, want %q", name, values[name], want[name])
+		}
+	}
+
+	for spec, wantErr := range map[string]string{
+		"env:CORTEX_TEST_UNSET":  "CORTEX_TEST_UNSET is not set",
+		"vault:kv/token":         `unknown provider "vault"`,
+		"file:" + path + ".gone": "no such file",
+	} {
+		_, err := Resolve(context.Background(), map[string]string{"X": spec})
+		if err == nil || !strings.Contains(err.Error(), wantErr) {
+			t.Errorf("Resolve(%q) error = %v, want %q", spec, err, wantErr)
+		}
+	}
+
+	RegisterProvider("test", func(_ context.Context, ref string) (string, error) { return "from-" + ref, nil })
+	if values, err := Resolve(context.Background(), map[string]string{"X": "test:ref"}); err != nil || values["X"] != "from-ref" {
+		t.Errorf("registered provider = %q, %v", values["X"], err)
+	}
+}
+
+func TestRedact(t *testing.T) {
+	r := NewRedactor(map[string]string{"SHORT": "abc", "LONG": "abcdef", "EMPTY": ""})
+	for text, want := range map[string]string{
+		"no secrets":     "no secrets",
+		"key=abc!":       "key=[secret:SHORT]!",
+		"key=abcdef abc": "key=[secret:LONG] [secret:SHORT]",
+		"abcabc":         "[secret:SHORT][secret:SHORT]",
+	} {
+		if got := r.Redact(text); got != want {
+			t.Errorf("Redact(%q) = %q, want %q", text, got, want)
+		}
+	}
+	if NewRedactor(map[string]string{"EMPTY": ""}) != nil {
+		t.Error("NewRedactor() with no values != nil")
+	}
+	if got := (*Redactor)(nil).Redact("abc"); got != "abc" {
+		t.Errorf("nil Redact() = %q", got)
+	}
+}
+
+// TestWriter tests that secrets split across writes are masked.
+func TestWriter(t *testing.T) {
+	r := NewRedactor(map[string]string{"SHORT": "abc", "LONG": "abcdef"})
+	var out strings.Builder
+	w := r.Writer(&out)
+	for _, chunk := range []string{"one a", "bc two ab", "cdef three ab", "cd"} {
+		w.Write([]byte(chunk))
+	}
+	if got := out.String(); got != "one [secret:SHORT] two [secret:LONG] three " {
+		t.Errorf("before Close, wrote %q", got)
+	}
+	w.Close()
+	if got, want := out.String(), "one [secret:SHORT] two [secret:LONG] three [secret:SHORT]d"; got != want {
+		t.Errorf("wrote %q, want %q", got, want)
+	}
+}
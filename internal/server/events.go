@@ -0,0 +1,239 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/websocket"
+
+	"github.com/adityaraj/agentflow/internal/config"
+)
+
+// Event types sent over /events.
+const (
+	EventReady        = "ready"         // Subscribed; events from here on will be sent
+	EventRunStarted   = "run_started"   // A run directory was created
+	EventTaskStarted  = "task_started"  // A task started (or finished, if it kept no log)
+	EventOutput       = "output"        // A task wrote complete lines of output
+	EventTaskFinished = "task_finished" // A task saved its result
+	EventRunFinished  = "run_finished"  // The run saved its record
+)
+
+// Event is a change to one of the project's runs, sent to /events
+// subscribers as a JSON text message.
+type Event struct {
+	Type    string `json:"type"`
+	RunID   string `json:"run_id,omitempty"`
+	Task    string `json:"task,omitempty"`
+	Output  string `json:"output,omitempty"`  // EventOutput: the new output
+	Offset  int64  `json:"offset,omitempty"`  // EventOutput: log offset after Output, for /logs?offset=
+	Success *bool  `json:"success,omitempty"` // EventTaskFinished and EventRunFinished, if known
+}
+
+// subscription is a message from an /events client changing the runs it
+// gets events of, e.g. {"subscribe": ["20240115-100000"]}.
+type subscription struct {
+	Subscribe   []string `json:"subscribe"`
+	Unsubscribe []string `json:"unsubscribe"`
+}
+
+// maxQueuedEvents is how many events a subscriber may fall behind by
+// before it is disconnected.
+const maxQueuedEvents = 256
+
+// subscriber is an /events client.
+type subscriber struct {
+	events chan Event // Closed when the subscriber is dropped
+
+	mu   sync.Mutex
+	runs map[string]bool // Runs subscribed to (empty = all)
+}
+
+// wants reports whether the subscriber gets events of runID.
+func (c *subscriber) wants(runID string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.runs) == 0 || runID == "" || c.runs[runID]
+}
+
+// update applies a subscription message.
+func (c *subscriber) update(msg subscription) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, id := range msg.Subscribe {
+		c.runs[id] = true
+	}
+	for _, id := range msg.Unsubscribe {
+		delete(c.runs, id)
+	}
+}
+
+// hub broadcasts the events of a project's runs to its subscribers,
+// watching the runs only while there are any.
+type hub struct {
+	s *Server
+
+	mu   sync.Mutex
+	subs map[*subscriber]bool
+	stop chan struct{} // Closed to stop the running watcher (nil = none)
+}
+
+// join adds sub, starting the watcher if it's the first subscriber. What
+// is already on disk by then isn't reported.
+func (h *hub) join(sub *subscriber) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.subs[sub] = true
+	if h.stop == nil {
+		h.stop = make(chan struct{})
+		w := newRunWatcher(h.s)
+		w.scan(nil)
+		go h.watch(w, h.stop, pollInterval)
+	}
+}
+
+// leave removes sub, closing its events, and stops the watcher once no
+// one subscribes. Safe to call more than once.
+func (h *hub) leave(sub *subscriber) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.drop(sub)
+}
+
+// drop is leave with h.mu held.
+func (h *hub) drop(sub *subscriber) {
+	if !h.subs[sub] {
+		return
+	}
+	delete(h.subs, sub)
+	close(sub.events)
+	if len(h.subs) == 0 {
+		close(h.stop)
+		h.stop = nil
+	}
+}
+
+// watch scans the runs with w every interval until stop is closed.
+func (h *hub) watch(w *runWatcher, stop chan struct{}, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			w.scan(func(event Event) { h.broadcast(stop, event) })
+		}
+	}
+}
+
+// broadcast sends event to the subscribers that want it, dropping any
+// too far behind to take it. Events of a watcher that has been stopped
+// are discarded.
+func (h *hub) broadcast(stop chan struct{}, event Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.stop != stop {
+		return
+	}
+	for sub := range h.subs {
+		if !sub.wants(event.RunID) {
+			continue
+		}
+		select {
+		case sub.events <- event:
+		default:
+			h.drop(sub)
+			if h.stop == nil {
+				return
+			}
+		}
+	}
+}
+
+// events serves a WebSocket of the project's run events, as JSON Event
+// messages. Clients get events of the runs given as ?run= (repeatable),
+// or of all runs, and change that by sending subscription messages.
+func (s *Server) events(w http.ResponseWriter, r *http.Request) {
+	runs := make(map[string]bool)
+	for _, id := range r.URL.Query()["run"] {
+		id = strings.TrimPrefix(id, "run-")
+		if !config.IsValidName(id) {
+			http.Error(w, fmt.Sprintf("invalid run %q", id), http.StatusBadRequest)
+			return
+		}
+		runs[id] = true
+	}
+	ws := websocket.Server{
+		Handshake: func(_ *websocket.Config, r *http.Request) error { return s.checkOrigin(r) },
+		Handler: func(ws *websocket.Conn) {
+			s.streamEvents(ws, r, &subscriber{events: make(chan Event, maxQueuedEvents), runs: runs})
+		},
+	}
+	ws.ServeHTTP(w, r)
+}
+
+// checkOrigin accepts WebSocket connections from clients that aren't
+// browsers (no Origin), from pages served by this server, and from the
+// origins allowed with AllowOrigins, so other sites can't read the runs
+// through a visitor's browser.
+func (s *Server) checkOrigin(r *http.Request) error {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return nil
+	}
+	if u, err := url.Parse(origin); err == nil && u.Host == r.Host {
+		return nil
+	}
+	if slices.Contains(s.origins, "*") || slices.Contains(s.origins, origin) {
+		return nil
+	}
+	return fmt.Errorf("origin %s not allowed", origin)
+}
+
+// streamEvents sends sub's events to ws until either side leaves, reading
+// subscription messages from it meanwhile.
+func (s *Server) streamEvents(ws *websocket.Conn, r *http.Request, sub *subscriber) {
+	sub.events <- Event{Type: EventReady}
+	s.hub.join(sub)
+	defer s.hub.leave(sub)
+	go func() {
+		defer s.hub.leave(sub) // Ends the loop below
+		for {
+			var msg subscription
+			if err := websocket.JSON.Receive(ws, &msg); err != nil {
+				return
+			}
+			sub.update(msg)
+		}
+	}()
+
+	send := func(event Event) bool {
+		ws.SetWriteDeadline(time.Now().Add(logWriteTimeout))
+		return websocket.JSON.Send(ws, event) == nil
+	}
+	keepAlive := time.NewTicker(keepAliveInterval)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case event, ok := <-sub.events:
+			if !ok || !send(event) {
+				return
+			}
+		case <-keepAlive.C:
+			// Pings keep proxies from closing an idle connection
+			ws.SetWriteDeadline(time.Now().Add(logWriteTimeout))
+			ws.PayloadType = websocket.PingFrame
+			if _, err := ws.Write(nil); err != nil {
+				return
+			}
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package server
+
+import (
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/websocket"
+
+	"github.com/adityaraj/agentflow/internal/state"
+)
+
+// subscribe connects to the server's /events with query, returning the
+// connection once it's subscribed.
+func subscribe(t *testing.T, srv *httptest.Server, query string) *websocket.Conn {
+	t.Helper()
+	url := "ws" + strings.TrimPrefix(srv.URL, "http") + "/events" + query
+	ws, err := websocket.Dial(url, "", srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ws.Close() })
+	if event := receive(t, ws); event.Type != EventReady {
+		t.Fatalf("first event = %+v, want ready", event)
+	}
+	return ws
+}
+
+// receive reads the next event from ws.
+func receive(t *testing.T, ws *websocket.Conn) Event {
+	t.Helper()
+	ws.SetReadDeadline(time.Now().Add(5 * time.Second))
+	var event Event
+	if err := websocket.JSON.Receive(ws, &event); err != nil {
+		t.Fatal(err)
+	}
+	return event
+}
+
+// describe summarizes an event for comparisons.
+func describe(e Event) string {
+	fields := []string{e.Type, e.RunID, e.Task, strings.TrimSuffix(e.Output, "\n")}
+	if e.Success != nil && *e.Success {
+		fields = append(fields, "ok")
+	}
+	return strings.Join(strings.Fields(strings.Join(fields, " ")), " ")
+}
+
+func TestEvents(t *testing.T) {
+	defer func(d time.Duration) { pollInterval = d }(pollInterval)
+	pollInterval = 10 * time.Millisecond
+
+	baseDir := t.TempDir()
+	runs := state.NewJSONStore(baseDir, nil)
+	writeLog(t, baseDir, "20240115-100000", "build", "old output\n") // Already there: not reported
+	srv := httptest.NewServer(New(runs, baseDir, "demo").Handler())
+	defer srv.Close()
+
+	all := subscribe(t, srv, "")
+	other := subscribe(t, srv, "?run=20240115-100000")
+
+	runDir := writeLog(t, baseDir, "20240116-100000", "build", "step 1\nstep")
+	for _, want := range []string{
+		"run_started 20240116-100000",
+		"task_started 20240116-100000 build",
+		"output 20240116-100000 build step 1",
+	} {
+		if got := describe(receive(t, all)); got != want {
+			t.Errorf("event = %q, want %q", got, want)
+		}
+	}
+
+	// The rest of a task's output is sent once it finishes
+	result := `{"task_name": "build", "success": true}`
+	if err := os.WriteFile(filepath.Join(runDir, "build.json"), []byte(result), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := runs.Save("demo", &state.RunResult{RunID: "20240116-100000", StartTime: time.Now(), EndTime: time.Now(), Success: true}); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"output 20240116-100000 build step",
+		"task_finished 20240116-100000 build ok",
+		"run_finished 20240116-100000 ok",
+	} {
+		if got := describe(receive(t, all)); got != want {
+			t.Errorf("event = %q, want %q", got, want)
+		}
+	}
+
+	// The client subscribed to another run got none of these; once it
+	// subscribes to the new run too, it gets its events
+	if err := websocket.JSON.Send(other, subscription{Subscribe: []string{"20240117-100000"}}); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(50 * time.Millisecond) // Let the server apply the subscription
+	writeLog(t, baseDir, "20240117-100000", "lint", "")
+	if got, want := describe(receive(t, other)), "run_started 20240117-100000"; got != want {
+		t.Errorf("subscribed client's event = %q, want %q", got, want)
+	}
+}
+
+func TestEvents_Origin(t *testing.T) {
+	srv := httptest.NewServer(New(state.NewJSONStore(t.TempDir(), nil), t.TempDir(), "demo").Handler())
+	defer srv.Close()
+	url := "ws" + strings.TrimPrefix(srv.URL, "http") + "/events"
+
+	if _, err := websocket.Dial(url, "", "https://evil.example.com"); err == nil {
+		t.Error("connection from another origin accepted")
+	}
+	subscribe(t, srv, "")
+}
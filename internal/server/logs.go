@@ -0,0 +1,200 @@
+package server
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/adityaraj/agentflow/internal/config"
+	"github.com/adityaraj/agentflow/internal/state"
+)
+
+const (
+	// maxLogChunk caps how much of a log is read, and held for one client,
+	// at a time.
+	maxLogChunk = 32 << 10
+
+	// logWriteTimeout is how long a follower may take to accept a chunk
+	// before it is disconnected.
+	logWriteTimeout = 30 * time.Second
+
+	// keepAliveInterval is how often an idle follower gets an SSE comment,
+	// so proxies don't close the connection.
+	keepAliveInterval = 15 * time.Second
+)
+
+// pollInterval is how often followed logs are checked for new output.
+var pollInterval = 250 * time.Millisecond
+
+// logs serves a task's output log from ?offset= (default 0). With
+// ?follow=true it streams the log as server-sent events until the task
+// finishes, each event's id being the offset after it, so a reconnecting
+// EventSource resumes where it stopped (Last-Event-ID).
+//
+// Each follower reads the file at its own pace, holding at most
+// maxLogChunk, so slow clients cost neither memory nor the executor's time.
+func (s *Server) logs(w http.ResponseWriter, r *http.Request) {
+	runID, task := strings.TrimPrefix(r.PathValue("id"), "run-"), r.PathValue("task")
+	if !config.IsValidName(runID) || !config.IsValidName(task) {
+		http.NotFound(w, r)
+		return
+	}
+	offset, err := logOffset(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	runDir := state.RunDirPath(s.baseDir, s.project, runID)
+	if _, err := os.Stat(runDir); err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	follow, _ := strconv.ParseBool(r.URL.Query().Get("follow"))
+	if !follow {
+		s.serveLog(w, r, runDir, task, offset)
+		return
+	}
+	s.followLog(w, r, runID, runDir, task, offset)
+}
+
+// logOffset returns where to start reading a log: Last-Event-ID when an
+// EventSource reconnects, else ?offset=.
+func logOffset(r *http.Request) (int64, error) {
+	value := r.Header.Get("Last-Event-ID")
+	if value == "" {
+		value = r.URL.Query().Get("offset")
+	}
+	if value == "" {
+		return 0, nil
+	}
+	offset, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || offset < 0 {
+		return 0, fmt.Errorf("invalid offset %q", value)
+	}
+	return offset, nil
+}
+
+// serveLog writes the log as it is now, from offset.
+func (s *Server) serveLog(w http.ResponseWriter, r *http.Request, runDir, task string, offset int64) {
+	f, err := os.Open(state.TaskLogPath(runDir, task))
+	if err != nil {
+		http.Error(w, "no output log for task "+task, http.StatusNotFound)
+		return
+	}
+	defer f.Close()
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	io.Copy(w, f)
+}
+
+// followLog streams the log as server-sent events until the task is done.
+func (s *Server) followLog(w http.ResponseWriter, r *http.Request, runID, runDir, task string, offset int64) {
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no") // Don't let nginx buffer events
+	rc := http.NewResponseController(w)
+	send := func(event string) bool {
+		rc.SetWriteDeadline(time.Now().Add(logWriteTimeout))
+		if _, err := io.WriteString(w, event); err != nil {
+			return false
+		}
+		return rc.Flush() == nil
+	}
+	if !send(": following " + task + "\n\n") {
+		return
+	}
+
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	buf := make([]byte, maxLogChunk)
+	idle := time.Now()
+	var f *os.File
+	defer func() {
+		if f != nil {
+			f.Close()
+		}
+	}()
+	for {
+		// Check first, so output written before the task finished is sent
+		done := s.taskDone(runID, runDir, task)
+		if f == nil {
+			f, _ = os.Open(state.TaskLogPath(runDir, task)) // Not there until the task starts
+		}
+		n := 0
+		if f != nil {
+			n, _ = f.ReadAt(buf, offset)
+		}
+		chunk := logChunk(buf, n, done)
+		if len(chunk) > 0 {
+			offset += int64(len(chunk))
+			if !send(logEvent(chunk, offset)) {
+				return
+			}
+			idle = time.Now()
+			if n == len(buf) {
+				continue // More to read
+			}
+		}
+		if done && len(chunk) == n {
+			send("event: end\ndata: " + strconv.FormatInt(offset, 10) + "\n\n")
+			return
+		}
+		if time.Since(idle) >= keepAliveInterval {
+			if !send(": keep-alive\n\n") {
+				return
+			}
+			idle = time.Now()
+		}
+		select {
+		case <-r.Context().Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// logChunk returns the part of the n bytes read into buf that can be sent:
+// all of them if the task is done or buf is full, else those up to the end
+// of the last finished line, holding back a partial line.
+func logChunk(buf []byte, n int, done bool) []byte {
+	chunk := buf[:n]
+	if done || n == len(buf) {
+		return chunk
+	}
+	return chunk[:bytes.LastIndexAny(chunk, "\r\n")+1]
+}
+
+// logEvent formats chunk as a server-sent event with offset as its id.
+// Every line becomes a data line; carriage returns, e.g. from progress
+// bars, end lines too, since they would end the field.
+func logEvent(chunk []byte, offset int64) string {
+	text := strings.ReplaceAll(string(chunk), "\r\n", "\n")
+	text = strings.ReplaceAll(text, "\r", "\n")
+	text = strings.TrimSuffix(text, "\n")
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "id: %d\n", offset)
+	for _, line := range strings.Split(text, "\n") {
+		sb.WriteString("data: " + line + "\n")
+	}
+	sb.WriteString("\n")
+	return sb.String()
+}
+
+// taskDone reports whether a task's log is complete: the task saved its
+// result, or the run finished (e.g. before the task started).
+func (s *Server) taskDone(runID, runDir, task string) bool {
+	if _, err := os.Stat(filepath.Join(runDir, task+".json")); err == nil {
+		return true
+	}
+	run, err := s.runs.Load(s.project, runID)
+	return err == nil && !run.EndTime.IsZero()
+}
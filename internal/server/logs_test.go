@@ -0,0 +1,129 @@
+package server
+
+import (
+	"bufio"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/adityaraj/agentflow/internal/state"
+)
+
+// writeLog creates the demo project's run runID with a log for task.
+func writeLog(t *testing.T, baseDir, runID, task, output string) string {
+	t.Helper()
+	runDir := state.RunDirPath(baseDir, "demo", runID)
+	path := state.TaskLogPath(runDir, task)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(output), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return runDir
+}
+
+func TestLogs(t *testing.T) {
+	baseDir := t.TempDir()
+	writeLog(t, baseDir, "20240115-100000", "build", "compiling\ndone\n")
+	srv := httptest.NewServer(New(state.NewJSONStore(baseDir, nil), baseDir, "demo").Handler())
+	defer srv.Close()
+
+	tests := []struct {
+		path   string
+		status int
+		want   string
+	}{
+		{"/runs/20240115-100000/tasks/build/logs", http.StatusOK, "compiling\ndone\n"},
+		{"/runs/run-20240115-100000/tasks/build/logs?offset=10", http.StatusOK, "done\n"},
+		{"/runs/20240115-100000/tasks/build/logs?offset=-1", http.StatusBadRequest, ""},
+		{"/runs/20240115-100000/tasks/test/logs", http.StatusNotFound, ""},
+		{"/runs/20240116-100000/tasks/build/logs", http.StatusNotFound, ""},
+		{"/runs/..%2F..%2Fetc/tasks/build/logs", http.StatusNotFound, ""},
+	}
+	for _, tt := range tests {
+		resp, err := http.Get(srv.URL + tt.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != tt.status {
+			t.Errorf("GET %s = %d, want %d", tt.path, resp.StatusCode, tt.status)
+		}
+		if tt.want != "" && string(body) != tt.want {
+			t.Errorf("GET %s = %q, want %q", tt.path, body, tt.want)
+		}
+	}
+}
+
+func TestLogs_Follow(t *testing.T) {
+	defer func(d time.Duration) { pollInterval = d }(pollInterval)
+	pollInterval = 10 * time.Millisecond
+
+	baseDir := t.TempDir()
+	runDir := writeLog(t, baseDir, "20240115-100000", "build", "step 1\nstep")
+	srv := httptest.NewServer(New(state.NewJSONStore(baseDir, nil), baseDir, "demo").Handler())
+	defer srv.Close()
+
+	req, _ := http.NewRequest("GET", srv.URL+"/runs/20240115-100000/tasks/build/logs?follow=true", nil)
+	req.Header.Set("Last-Event-ID", "2") // Resume partway through the first line
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("Content-Type = %q", ct)
+	}
+
+	events := make(chan string)
+	go func() {
+		defer close(events)
+		scanner := bufio.NewScanner(resp.Body)
+		var event []string
+		for scanner.Scan() {
+			if line := scanner.Text(); line != "" {
+				event = append(event, line)
+				continue
+			}
+			if len(event) > 0 && !strings.HasPrefix(event[0], ":") {
+				events <- strings.Join(event, "|")
+			}
+			event = nil
+		}
+	}()
+
+	// The partial line is held back until it's finished
+	if got, want := <-events, "id: 7|data: ep 1"; got != want {
+		t.Errorf("first event = %q, want %q", got, want)
+	}
+	f, err := os.OpenFile(state.TaskLogPath(runDir, "build"), os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(" 2\r50%\rstep 3")
+	f.Close()
+	if got, want := <-events, "id: 18|data: step 2|data: 50%"; got != want {
+		t.Errorf("second event = %q, want %q", got, want)
+	}
+
+	// Once the task finishes, the rest is sent and the stream ends
+	if err := os.WriteFile(filepath.Join(runDir, "build.json"), []byte("{}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := <-events, "id: 24|data: step 3"; got != want {
+		t.Errorf("third event = %q, want %q", got, want)
+	}
+	if got, want := <-events, "event: end|data: 24"; got != want {
+		t.Errorf("last event = %q, want %q", got, want)
+	}
+	if extra, ok := <-events; ok {
+		t.Errorf("event after end: %q", extra)
+	}
+}
@@ -0,0 +1,100 @@
+// Package server serves a project's run history over HTTP for
+// `cortex serve`, e.g. status badges for READMEs, task logs, and live run
+// events for dashboards.
+package server
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+
+	"github.com/adityaraj/agentflow/internal/config"
+	"github.com/adityaraj/agentflow/internal/report"
+	"github.com/adityaraj/agentflow/internal/state"
+)
+
+// DefaultWorkflow names the Cortexfile's top-level tasks in URLs.
+const DefaultWorkflow = "default"
+
+// Server serves the runs of one project, read from session storage.
+type Server struct {
+	runs    state.Store // Session storage
+	baseDir string      // Cortex home with the run directories, e.g. ~/.cortex
+	project string      // Project whose runs are served
+	origins []string    // Other origins whose pages may subscribe to events ("*" = any)
+	hub     *hub        // Broadcasts run events to /events subscribers
+}
+
+// New creates a Server for project's runs, kept in runs, with their run
+// directories under baseDir.
+func New(runs state.Store, baseDir, project string) *Server {
+	s := &Server{runs: runs, baseDir: baseDir, project: project}
+	s.hub = &hub{s: s, subs: make(map[*subscriber]bool)}
+	return s
+}
+
+// AllowOrigins lets pages from origins, e.g. "https://dash.example.com",
+// subscribe to /events; "*" allows any. Pages served by the server itself
+// always may.
+func (s *Server) AllowOrigins(origins ...string) {
+	s.origins = origins
+}
+
+// Handler returns the server's routes:
+//
+//	GET /badge/<workflow>.svg              status badge of the workflow's latest run
+//	GET /runs/<id>/tasks/<task>/logs       a task's output (?follow=true to stream it)
+//	GET /events                            WebSocket of run and task events (?run=<id> to filter)
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /badge/{file}", s.badge)
+	mux.HandleFunc("GET /runs/{id}/tasks/{task}/logs", s.logs)
+	mux.HandleFunc("GET /events", s.events)
+	return mux
+}
+
+// badge serves the status badge of a workflow's latest finished run.
+func (s *Server) badge(w http.ResponseWriter, r *http.Request) {
+	workflow, ok := strings.CutSuffix(r.PathValue("file"), ".svg")
+	if !ok || !config.IsValidName(workflow) {
+		http.NotFound(w, r)
+		return
+	}
+	run, err := s.latestRun(workflow)
+	if err != nil {
+		http.Error(w, "failed to read runs", http.StatusInternalServerError)
+		return
+	}
+
+	label := "cortex"
+	if workflow != DefaultWorkflow {
+		label = workflow
+	}
+	var buf bytes.Buffer
+	if err := report.RenderBadge(&buf, report.RunBadge(label, run)); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "image/svg+xml")
+	// Image proxies such as GitHub's would otherwise keep a stale status
+	w.Header().Set("Cache-Control", "no-cache, max-age=0")
+	w.Write(buf.Bytes())
+}
+
+// latestRun returns the newest finished run of workflow, or nil if there
+// is none.
+func (s *Server) latestRun(workflow string) (*state.SessionInfo, error) {
+	if workflow == DefaultWorkflow {
+		workflow = ""
+	}
+	sessions, err := s.runs.List(state.SessionFilter{Project: s.project})
+	if err != nil {
+		return nil, err
+	}
+	for i, session := range sessions { // Newest first
+		if session.Workflow == workflow && !session.EndTime.IsZero() {
+			return &sessions[i], nil
+		}
+	}
+	return nil, nil
+}
@@ -0,0 +1,83 @@
+package server
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/adityaraj/agentflow/internal/state"
+)
+
+// saveRun writes a finished run of workflow to the demo project's sessions.
+func saveRun(t *testing.T, baseDir, runID, workflow string, success bool, duration time.Duration) {
+	t.Helper()
+	dir := filepath.Join(baseDir, "sessions", "demo", "run-"+runID)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	start, _ := time.Parse("20060102-150405", runID)
+	data, err := json.Marshal(state.RunResult{
+		RunID: runID, Workflow: workflow, Success: success,
+		StartTime: start, EndTime: start.Add(duration),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "run.json"), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestBadge(t *testing.T) {
+	baseDir := t.TempDir()
+	saveRun(t, baseDir, "20240115-100000", "", false, time.Minute)
+	saveRun(t, baseDir, "20240115-110000", "", true, 90*time.Second)
+	saveRun(t, baseDir, "20240115-120000", "nightly", false, 5*time.Second)
+	srv := httptest.NewServer(New(state.NewJSONStore(baseDir, nil), baseDir, "demo").Handler())
+	defer srv.Close()
+
+	tests := []struct {
+		path   string
+		status int
+		want   string
+	}{
+		{"/badge/default.svg", http.StatusOK, "cortex: passing · 1m30s"},
+		{"/badge/nightly.svg", http.StatusOK, "nightly: failing · 5s"},
+		{"/badge/weekly.svg", http.StatusOK, "weekly: no runs"},
+		{"/badge/nightly.png", http.StatusNotFound, ""},
+		{"/badge/..%2Fx.svg", http.StatusNotFound, ""},
+	}
+	for _, tt := range tests {
+		resp, err := http.Get(srv.URL + tt.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != tt.status {
+			t.Errorf("GET %s = %d, want %d", tt.path, resp.StatusCode, tt.status)
+		}
+		if tt.want == "" {
+			resp.Body.Close()
+			continue
+		}
+		if ct := resp.Header.Get("Content-Type"); ct != "image/svg+xml" {
+			t.Errorf("GET %s Content-Type = %q", tt.path, ct)
+		}
+		var svg struct {
+			Title string `xml:"title"`
+		}
+		err = xml.NewDecoder(resp.Body).Decode(&svg)
+		resp.Body.Close()
+		if err != nil {
+			t.Errorf("GET %s: invalid SVG: %v", tt.path, err)
+		}
+		if !strings.Contains(svg.Title, tt.want) {
+			t.Errorf("GET %s title = %q, want %q", tt.path, svg.Title, tt.want)
+		}
+	}
+}
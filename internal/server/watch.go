@@ -0,0 +1,178 @@
+package server
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/adityaraj/agentflow/internal/state"
+)
+
+// runWatcher turns changes to the run directories of the server's project
+// into events. Runs are written by other processes (`cortex run`), so it
+// polls: a run starts when its directory appears, a task when its log does,
+// output as the log grows, a task finishes when it saves its result, and a
+// run when its record has an end time.
+type runWatcher struct {
+	s    *Server
+	runs map[string]*watchedRun // By run ID
+	buf  []byte
+}
+
+// watchedRun is what has been reported of a run.
+type watchedRun struct {
+	done  bool
+	tasks map[string]*watchedTask
+	other map[string]bool // JSON files in the run directory that aren't task results
+}
+
+// watchedTask is what has been reported of a task.
+type watchedTask struct {
+	offset int64 // Of the output sent so far
+	done   bool
+}
+
+func newRunWatcher(s *Server) *runWatcher {
+	return &runWatcher{s: s, runs: make(map[string]*watchedRun), buf: make([]byte, maxLogChunk)}
+}
+
+// scan reports, to emit, what changed since the last scan. With a nil
+// emit, it only records what is there, without reading logs.
+func (w *runWatcher) scan(emit func(Event)) {
+	entries, _ := os.ReadDir(state.ProjectDirPath(w.s.baseDir, w.s.project))
+	seen := make(map[string]bool)
+	for _, entry := range entries {
+		runID, ok := strings.CutPrefix(entry.Name(), "run-")
+		if !ok || !entry.IsDir() {
+			continue
+		}
+		seen[runID] = true
+		run := w.runs[runID]
+		if run == nil {
+			run = &watchedRun{tasks: make(map[string]*watchedTask), other: make(map[string]bool)}
+			w.runs[runID] = run
+			if emit != nil {
+				emit(Event{Type: EventRunStarted, RunID: runID})
+			}
+		}
+		if !run.done {
+			w.scanRun(runID, run, emit)
+		}
+	}
+	for runID := range w.runs {
+		if !seen[runID] {
+			delete(w.runs, runID) // Removed, e.g. by `cortex sessions gc`
+		}
+	}
+}
+
+// scanRun reports what changed in one run.
+func (w *runWatcher) scanRun(runID string, run *watchedRun, emit func(Event)) {
+	runDir := state.RunDirPath(w.s.baseDir, w.s.project, runID)
+	// Check first, so output written before the run finished is sent
+	record, err := w.s.runs.Load(w.s.project, runID)
+	done := err == nil && !record.EndTime.IsZero()
+	if done && emit == nil {
+		run.done = true
+		return
+	}
+
+	for _, task := range w.taskNames(runDir, run) {
+		t := run.tasks[task]
+		if t == nil {
+			t = &watchedTask{}
+			run.tasks[task] = t
+			if emit != nil {
+				emit(Event{Type: EventTaskStarted, RunID: runID, Task: task})
+			}
+		}
+		if t.done {
+			continue
+		}
+		result, err := state.ReadTaskResult(runDir, task)
+		finished := err == nil
+		if emit == nil {
+			if info, err := os.Stat(state.TaskLogPath(runDir, task)); err == nil {
+				t.offset = info.Size()
+			}
+			t.done = finished
+			continue
+		}
+		w.readOutput(runID, runDir, task, t, finished || done, emit)
+		if finished || done {
+			event := Event{Type: EventTaskFinished, RunID: runID, Task: task}
+			if finished {
+				event.Success = &result.Success
+			}
+			emit(event)
+			t.done = true
+		}
+	}
+
+	if done {
+		emit(Event{Type: EventRunFinished, RunID: runID, Success: &record.Success})
+		run.done = true
+	}
+}
+
+// taskNames returns the tasks of the run in runDir that have a log or a
+// result, sorted.
+func (w *runWatcher) taskNames(runDir string, run *watchedRun) []string {
+	names := make(map[string]bool)
+	for task := range run.tasks {
+		names[task] = true
+	}
+	logs, _ := os.ReadDir(filepath.Join(runDir, state.LogsDir))
+	for _, entry := range logs {
+		if task, ok := strings.CutSuffix(entry.Name(), ".log"); ok {
+			names[task] = true
+		}
+	}
+	files, _ := os.ReadDir(runDir)
+	for _, entry := range files {
+		name, ok := strings.CutSuffix(entry.Name(), ".json")
+		if !ok || names[name] || run.other[name] {
+			continue
+		}
+		// run.json and manifest.json sit beside the task results
+		result, err := state.ReadTaskResult(runDir, name)
+		if err != nil {
+			continue // Maybe still being written
+		}
+		if result.TaskName != name {
+			run.other[name] = true
+			continue
+		}
+		names[name] = true
+	}
+
+	sorted := make([]string, 0, len(names))
+	for task := range names {
+		sorted = append(sorted, task)
+	}
+	sort.Strings(sorted)
+	return sorted
+}
+
+// readOutput reports the output a task wrote since the last scan, all of
+// it if the task is done, else its complete lines.
+func (w *runWatcher) readOutput(runID, runDir, task string, t *watchedTask, done bool, emit func(Event)) {
+	f, err := os.Open(state.TaskLogPath(runDir, task))
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	for {
+		n, _ := f.ReadAt(w.buf, t.offset)
+		chunk := logChunk(w.buf, n, done)
+		if len(chunk) == 0 {
+			return
+		}
+		t.offset += int64(len(chunk))
+		emit(Event{Type: EventOutput, RunID: runID, Task: task, Output: string(chunk), Offset: t.offset})
+		if n < len(w.buf) {
+			return
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package snapshot
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// backends returns the snapshot mechanisms to try on this platform, best first.
+// The tar backend always works and comes last.
+func backends() []backend {
+	var list []backend
+	switch runtime.GOOS {
+	case "linux", "freebsd":
+		list = append(list, zfsBackend{}, btrfsBackend{})
+	case "darwin":
+		list = append(list, apfsBackend{})
+	}
+	return append(list, tarBackend{})
+}
+
+// command runs an external tool and returns its trimmed stdout.
+func command(name string, args ...string) (string, error) {
+	if _, err := exec.LookPath(name); err != nil {
+		return "", fmt.Errorf("%s not available", name)
+	}
+
+	cmd := exec.Command(name, args...)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" {
+			msg = err.Error()
+		}
+		return "", fmt.Errorf("%s: %s", name, msg)
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
+
+// zfsBackend snapshots a ZFS dataset mounted exactly at the workdir.
+type zfsBackend struct{}
+
+func (zfsBackend) method() string { return MethodZFS }
+
+func (zfsBackend) create(dir, storeDir, name string) (string, error) {
+	out, err := command("zfs", "list", "-H", "-o", "name,mountpoint", "-t", "filesystem")
+	if err != nil {
+		return "", err
+	}
+
+	for _, line := range strings.Split(out, "\n") {
+		dataset, mountpoint, ok := strings.Cut(line, "\t")
+		if !ok || mountpoint != dir {
+			continue
+		}
+		snap := dataset + "@cortex-" + name
+		if _, err := command("zfs", "snapshot", snap); err != nil {
+			return "", err
+		}
+		return snap, nil
+	}
+	return "", fmt.Errorf("%s is not a ZFS dataset mountpoint", dir)
+}
+
+func (zfsBackend) restore(location, target string) error {
+	_, err := command("zfs", "rollback", "-r", location)
+	return err
+}
+
+// btrfsBackend takes a read-only snapshot of a btrfs subvolume, stored next
+// to it in .cortex-snapshots/ (snapshots must live on the same filesystem).
+type btrfsBackend struct{}
+
+func (btrfsBackend) method() string { return MethodBtrfs }
+
+func (btrfsBackend) create(dir, storeDir, name string) (string, error) {
+	if _, err := command("btrfs", "subvolume", "show", dir); err != nil {
+		return "", err
+	}
+
+	dest := filepath.Join(filepath.Dir(dir), ".cortex-snapshots", filepath.Base(dir)+"-"+name)
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return "", err
+	}
+	if _, err := command("btrfs", "subvolume", "snapshot", "-r", dir, dest); err != nil {
+		return "", err
+	}
+	return dest, nil
+}
+
+func (btrfsBackend) restore(location, target string) error {
+	_, err := command("cp", "-a", "--reflink=auto", location+"/.", target)
+	return err
+}
+
+// apfsBackend clones the workdir into the run directory with clonefile(2),
+// which is copy-on-write on APFS and fails on other filesystems.
+type apfsBackend struct{}
+
+func (apfsBackend) method() string { return MethodAPFS }
+
+func (apfsBackend) create(dir, storeDir, name string) (string, error) {
+	dest := filepath.Join(storeDir, "workdir")
+	if err := os.MkdirAll(dest, 0755); err != nil {
+		return "", err
+	}
+	if _, err := command("cp", "-c", "-pR", dir+"/.", dest); err != nil {
+		os.RemoveAll(dest)
+		return "", err
+	}
+	return dest, nil
+}
+
+func (apfsBackend) restore(location, target string) error {
+	_, err := command("cp", "-c", "-pR", location+"/.", target)
+	return err
+}
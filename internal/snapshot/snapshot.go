@@ -0,0 +1,152 @@
+// Package snapshot captures a working directory before a run and restores it on rollback.
+//
+// Native copy-on-write snapshots are used where the filesystem supports them
+// (ZFS datasets, btrfs subvolumes, APFS clones); otherwise the directory is
+// archived to a gzipped tarball in the run directory.
+package snapshot
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// MetaFile is the file in a run directory describing its snapshot.
+const MetaFile = "snapshot.json"
+
+// Snapshot methods.
+const (
+	MethodZFS   = "zfs"
+	MethodBtrfs = "btrfs"
+	MethodAPFS  = "apfs"
+	MethodTar   = "tar"
+)
+
+// Snapshot describes a captured working directory.
+type Snapshot struct {
+	Method    string    `json:"method"`
+	Source    string    `json:"source"`   // Directory that was captured
+	Location  string    `json:"location"` // ZFS snapshot name or path to the snapshot data
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// backend is a snapshot mechanism for a particular filesystem.
+type backend interface {
+	method() string
+	// create captures dir, storing any data under storeDir, and returns its location.
+	create(dir, storeDir, name string) (string, error)
+	// restore fills target with the snapshot contents.
+	restore(location, target string) error
+}
+
+// Create snapshots dir using the best available method and records the
+// snapshot in storeDir/snapshot.json.
+func Create(dir, storeDir, name string) (*Snapshot, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var lastErr error
+	for _, b := range backends() {
+		location, err := b.create(dir, storeDir, name)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		snap := &Snapshot{
+			Method:    b.method(),
+			Source:    dir,
+			Location:  location,
+			CreatedAt: time.Now(),
+		}
+		if err := snap.save(storeDir); err != nil {
+			return nil, err
+		}
+		return snap, nil
+	}
+	return nil, fmt.Errorf("failed to snapshot %s: %w", dir, lastErr)
+}
+
+// Load reads the snapshot recorded in storeDir.
+func Load(storeDir string) (*Snapshot, error) {
+	data, err := os.ReadFile(filepath.Join(storeDir, MetaFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("no snapshot was taken for this run")
+		}
+		return nil, err
+	}
+
+	var snap Snapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return nil, fmt.Errorf("invalid snapshot metadata: %w", err)
+	}
+	return &snap, nil
+}
+
+// Restore returns the source directory to its state when the snapshot was taken.
+// Files created since then are removed.
+func (s *Snapshot) Restore() error {
+	switch s.Method {
+	case MethodZFS:
+		// Rolls the dataset back in place
+		return (zfsBackend{}).restore(s.Location, s.Source)
+	case MethodBtrfs:
+		return restoreInto(s.Source, func(tmp string) error { return (btrfsBackend{}).restore(s.Location, tmp) })
+	case MethodAPFS:
+		return restoreInto(s.Source, func(tmp string) error { return (apfsBackend{}).restore(s.Location, tmp) })
+	case MethodTar:
+		return restoreInto(s.Source, func(tmp string) error { return (tarBackend{}).restore(s.Location, tmp) })
+	default:
+		return fmt.Errorf("unknown snapshot method %q", s.Method)
+	}
+}
+
+// save writes the snapshot metadata to storeDir.
+func (s *Snapshot) save(storeDir string) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(storeDir, MetaFile), data, 0644)
+}
+
+// restoreInto fills a temporary sibling of dir via fill, then replaces dir's
+// contents with it. The directory itself is kept so open shells stay valid,
+// and nothing is deleted until the snapshot has been fully extracted.
+func restoreInto(dir string, fill func(tmp string) error) error {
+	tmp, err := os.MkdirTemp(filepath.Dir(dir), ".cortex-restore-")
+	if err != nil {
+		return fmt.Errorf("failed to create restore directory: %w", err)
+	}
+	defer os.RemoveAll(tmp)
+
+	if err := fill(tmp); err != nil {
+		return err
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if err := os.RemoveAll(filepath.Join(dir, e.Name())); err != nil {
+			return fmt.Errorf("failed to remove %s: %w", e.Name(), err)
+		}
+	}
+
+	restored, err := os.ReadDir(tmp)
+	if err != nil {
+		return err
+	}
+	for _, e := range restored {
+		if err := os.Rename(filepath.Join(tmp, e.Name()), filepath.Join(dir, e.Name())); err != nil {
+			return fmt.Errorf("failed to restore %s: %w", e.Name(), err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package snapshot
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestTar_CreateAndRestore tests that a tar snapshot restores modified,
+// deleted, and newly created files.
+func TestTar_CreateAndRestore(t *testing.T) {
+	dir := t.TempDir()
+	storeDir := t.TempDir()
+
+	writeFile(t, filepath.Join(dir, "main.go"), "package main\n")
+	writeFile(t, filepath.Join(dir, "pkg", "util.go"), "package pkg\n")
+	if err := os.Symlink("main.go", filepath.Join(dir, "link.go")); err != nil {
+		t.Fatal(err)
+	}
+
+	location, err := (tarBackend{}).create(dir, storeDir, "test")
+	if err != nil {
+		t.Fatalf("create() error = %v", err)
+	}
+	snap := &Snapshot{Method: MethodTar, Source: dir, Location: location}
+	if err := snap.save(storeDir); err != nil {
+		t.Fatal(err)
+	}
+
+	// Simulate an agent run gone wrong
+	writeFile(t, filepath.Join(dir, "main.go"), "broken\n")
+	if err := os.RemoveAll(filepath.Join(dir, "pkg")); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, filepath.Join(dir, "junk.txt"), "junk\n")
+
+	loaded, err := Load(storeDir)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if err := loaded.Restore(); err != nil {
+		t.Fatalf("Restore() error = %v", err)
+	}
+
+	if data, _ := os.ReadFile(filepath.Join(dir, "main.go")); string(data) != "package main\n" {
+		t.Errorf("main.go = %q, want original content", data)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "pkg", "util.go")); err != nil {
+		t.Errorf("pkg/util.go not restored: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "junk.txt")); !os.IsNotExist(err) {
+		t.Errorf("junk.txt should have been removed, stat err = %v", err)
+	}
+	if target, err := os.Readlink(filepath.Join(dir, "link.go")); err != nil || target != "main.go" {
+		t.Errorf("link.go = %q, %v; want symlink to main.go", target, err)
+	}
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
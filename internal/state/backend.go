@@ -0,0 +1,214 @@
+package state
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Store keeps the records of finished and running runs: what `cortex
+// sessions`, reports, and reruns read. The run directory itself, with task
+// results, outputs, and other run files, always lives on disk (see
+// RunStore); a Store only decides where run records are kept and how they
+// are searched, so new backends don't need changes to the executor.
+type Store interface {
+	// Save creates or replaces the record of run in project.
+	Save(project string, run *RunResult) error
+
+	// Load returns the record of a run, or an error wrapping
+	// os.ErrNotExist if there is none.
+	Load(project, runID string) (*RunResult, error)
+
+	// List returns summaries of the runs filter selects, newest first.
+	List(filter SessionFilter) ([]SessionInfo, error)
+
+	// Query returns the task results q selects, newest run first.
+	Query(q Query) ([]TaskRecord, error)
+
+	// GC removes the runs policy doesn't keep, with their run
+	// directories, and returns them.
+	GC(policy GCPolicy) ([]SessionInfo, error)
+}
+
+// Query selects task results across stored runs.
+type Query struct {
+	Project    string    // Filter by project name (empty = all projects)
+	Task       string    // Filter by task name (empty = all tasks)
+	Agent      string    // Filter by agent name (empty = all agents)
+	FailedOnly bool      // Only failed task results
+	Since      time.Time // Only runs started at or after this time (zero = all)
+	Limit      int       // Maximum number of results to return (0 = no limit)
+}
+
+// TaskRecord is a task result and the run it is from.
+type TaskRecord struct {
+	Project string `json:"project"`
+	RunID   string `json:"run_id"`
+	TaskResult
+}
+
+// GCPolicy decides which runs GC keeps. A run is kept if either rule keeps
+// it; with neither set, every run is kept.
+type GCPolicy struct {
+	Project  string    // Only collect this project's runs (empty = all projects)
+	KeepLast int       // Keep each project's newest runs (0 = none by count)
+	Before   time.Time // Keep runs started at or after this time (zero = none by age)
+	DryRun   bool      // Return the runs GC would remove without removing them
+}
+
+// StoreOptions configures a Store opened with OpenStore.
+type StoreOptions struct {
+	Dir string // Cortex home, e.g. ~/.cortex, where run directories live
+	Key []byte // Encrypts stored runs (nil = plaintext, see the crypt package)
+}
+
+// StoreJSON is the name of the default Store, which keeps run records as
+// run.json files in their run directories.
+const StoreJSON = "json"
+
+var (
+	openersMu sync.Mutex
+	openers   = map[string]func(StoreOptions) (Store, error){
+		StoreJSON: func(opts StoreOptions) (Store, error) {
+			return NewJSONStore(opts.Dir, opts.Key), nil
+		},
+	}
+)
+
+// RegisterStore makes a Store available to OpenStore, and so to
+// settings.store, under name. Backends built in with a build tag register
+// themselves from init.
+func RegisterStore(name string, open func(StoreOptions) (Store, error)) {
+	openersMu.Lock()
+	defer openersMu.Unlock()
+	openers[name] = open
+}
+
+// StoreNames returns the names of the registered stores, sorted.
+func StoreNames() []string {
+	openersMu.Lock()
+	defer openersMu.Unlock()
+	names := make([]string, 0, len(openers))
+	for name := range openers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// OpenStore opens the Store registered as name ("" = StoreJSON).
+func OpenStore(name string, opts StoreOptions) (Store, error) {
+	if name == "" {
+		name = StoreJSON
+	}
+	openersMu.Lock()
+	open, ok := openers[name]
+	openersMu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown store %q: this build supports %s", name, strings.Join(StoreNames(), ", "))
+	}
+	store, err := open(opts)
+	if err != nil {
+		return nil, fmt.Errorf("cannot open %s store: %w", name, err)
+	}
+	return store, nil
+}
+
+// defaultStore is where the package-level session functions read runs
+// from (nil = JSON files in ~/.cortex).
+var defaultStore Store
+
+// UseStore makes ListSessions, GetSession, and the other package-level
+// session functions read runs from store.
+func UseStore(store Store) {
+	defaultStore = store
+}
+
+// DefaultStore returns the Store set with UseStore, or else the JSON store
+// in ~/.cortex.
+func DefaultStore() (Store, error) {
+	if defaultStore != nil {
+		return defaultStore, nil
+	}
+	baseDir, err := getCortexDir()
+	if err != nil {
+		return nil, err
+	}
+	return NewJSONStore(baseDir, nil), nil
+}
+
+// filterSessions applies filter's FailedOnly and Limit to sessions, sorting
+// them newest first.
+func filterSessions(sessions []SessionInfo, filter SessionFilter) []SessionInfo {
+	if filter.FailedOnly {
+		sessions = slices.DeleteFunc(sessions, func(s SessionInfo) bool { return s.Success })
+	}
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].StartTime.After(sessions[j].StartTime)
+	})
+	if filter.Limit > 0 && len(sessions) > filter.Limit {
+		sessions = sessions[:filter.Limit]
+	}
+	return sessions
+}
+
+// queryRuns answers q by loading the runs of store it could match, for
+// stores that can't search task results themselves.
+func queryRuns(store Store, q Query) ([]TaskRecord, error) {
+	sessions, err := store.List(SessionFilter{Project: q.Project})
+	if err != nil {
+		return nil, err
+	}
+	var records []TaskRecord
+	for _, session := range sessions { // Newest first
+		if session.StartTime.Before(q.Since) {
+			continue
+		}
+		run, err := store.Load(session.Project, session.RunID)
+		if err != nil {
+			continue // Skip runs we can't load, as List does
+		}
+		for _, task := range run.Tasks {
+			if !q.matches(&task) {
+				continue
+			}
+			records = append(records, TaskRecord{Project: session.Project, RunID: run.RunID, TaskResult: task})
+			if q.Limit > 0 && len(records) == q.Limit {
+				return records, nil
+			}
+		}
+	}
+	return records, nil
+}
+
+// matches reports whether q selects task, apart from its run.
+func (q Query) matches(task *TaskResult) bool {
+	return (q.Task == "" || task.TaskName == q.Task) &&
+		(q.Agent == "" || task.Agent == q.Agent) &&
+		(!q.FailedOnly || !task.Success)
+}
+
+// collect returns the sessions policy doesn't keep. Sessions must be
+// sorted newest first.
+func (p GCPolicy) collect(sessions []SessionInfo) []SessionInfo {
+	if p.KeepLast <= 0 && p.Before.IsZero() {
+		return nil
+	}
+	var removed []SessionInfo
+	seen := make(map[string]int) // Runs seen per project
+	for _, session := range sessions {
+		if p.Project != "" && session.Project != p.Project {
+			continue
+		}
+		seen[session.Project]++
+		keptByCount := p.KeepLast > 0 && seen[session.Project] <= p.KeepLast
+		keptByAge := !p.Before.IsZero() && !session.StartTime.Before(p.Before)
+		if !keptByCount && !keptByAge {
+			removed = append(removed, session)
+		}
+	}
+	return removed
+}
@@ -0,0 +1,201 @@
+package state
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/adityaraj/agentflow/internal/crypt"
+)
+
+// TestStores runs the Store conformance tests against each backend.
+func TestStores(t *testing.T) {
+	key := []byte("0123456789abcdef0123456789abcdef")
+	stores := map[string]func(t *testing.T) Store{
+		StoreJSON: func(t *testing.T) Store { return NewJSONStore(t.TempDir(), nil) },
+		"json (encrypted)": func(t *testing.T) Store {
+			t.Setenv(crypt.KeyEnv, hex.EncodeToString(key)) // Commands read runs with it
+			return NewJSONStore(t.TempDir(), key)
+		},
+		StoreSQLite: func(t *testing.T) Store { return openSQLite(t, nil) },
+		"sqlite (encrypted)": func(t *testing.T) Store {
+			t.Setenv(crypt.KeyEnv, hex.EncodeToString(key))
+			return openSQLite(t, key)
+		},
+	}
+	for name, open := range stores {
+		t.Run(name, func(t *testing.T) { testStore(t, open(t)) })
+	}
+}
+
+// openSQLite opens a SQLite store in a temp directory through OpenStore,
+// as settings.store does, closing it when the test ends.
+func openSQLite(t *testing.T, key []byte) Store {
+	t.Helper()
+	store, err := OpenStore(StoreSQLite, StoreOptions{Dir: t.TempDir(), Key: key})
+	if err != nil {
+		t.Fatalf("OpenStore(sqlite) error = %v", err)
+	}
+	t.Cleanup(func() { store.(*SQLiteStore).Close() })
+	return store
+}
+
+// testStore tests that store saves, loads, lists, queries, and collects
+// runs as the Store interface documents.
+func testStore(t *testing.T, store Store) {
+	start := time.Date(2026, 3, 10, 9, 0, 0, 0, time.UTC)
+	run := func(id string, hours int, success bool) *RunResult {
+		return &RunResult{
+			RunID: id, StartTime: start.Add(time.Duration(hours) * time.Hour), Success: success,
+			Tasks: []TaskResult{
+				{TaskName: "build", Agent: "sh", Success: true},
+				{TaskName: "review", Agent: "ai", Success: success},
+			},
+		}
+	}
+	runs := map[string][]*RunResult{
+		"api": {run("a1", 0, true), run("a2", 1, false), run("a3", 2, true)},
+		"web": {run("w1", 3, true)},
+	}
+	for project, list := range runs {
+		for _, r := range list {
+			if err := store.Save(project, r); err != nil {
+				t.Fatalf("Save(%s, %s) error = %v", project, r.RunID, err)
+			}
+		}
+	}
+
+	// Save replaces a run's record
+	a3 := run("a3", 2, true)
+	a3.Workflow = "nightly"
+	if err := store.Save("api", a3); err != nil {
+		t.Fatal(err)
+	}
+	got, err := store.Load("api", "a3")
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if got.Workflow != "nightly" || len(got.Tasks) != 2 || !got.StartTime.Equal(a3.StartTime) {
+		t.Errorf("Load() = %+v, want the saved run", got)
+	}
+	if _, err := store.Load("api", "missing"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Load() of a missing run error = %v, want os.ErrNotExist", err)
+	}
+	if err := store.Save("api", &RunResult{}); err == nil {
+		t.Errorf("Save() of a run without an ID succeeded")
+	}
+
+	ids := func(sessions []SessionInfo) []string {
+		var out []string
+		for _, s := range sessions {
+			out = append(out, s.RunID)
+		}
+		return out
+	}
+	lists := []struct {
+		filter SessionFilter
+		want   []string
+	}{
+		{SessionFilter{}, []string{"w1", "a3", "a2", "a1"}},
+		{SessionFilter{Project: "api"}, []string{"a3", "a2", "a1"}},
+		{SessionFilter{Project: "api", Limit: 2}, []string{"a3", "a2"}},
+		{SessionFilter{FailedOnly: true}, []string{"a2"}},
+		{SessionFilter{Project: "none"}, nil},
+	}
+	for _, tt := range lists {
+		sessions, err := store.List(tt.filter)
+		if err != nil {
+			t.Fatalf("List(%+v) error = %v", tt.filter, err)
+		}
+		if got := ids(sessions); !slices.Equal(got, tt.want) {
+			t.Errorf("List(%+v) = %v, want %v", tt.filter, got, tt.want)
+		}
+	}
+
+	records, err := store.Query(Query{Project: "api", Agent: "ai", Since: start.Add(time.Hour)})
+	if err != nil {
+		t.Fatalf("Query() error = %v", err)
+	}
+	var queried []string
+	for _, r := range records {
+		queried = append(queried, r.RunID+"/"+r.TaskName)
+	}
+	if want := []string{"a3/review", "a2/review"}; !slices.Equal(queried, want) {
+		t.Errorf("Query() = %v, want %v", queried, want)
+	}
+	if records, _ := store.Query(Query{FailedOnly: true}); len(records) != 1 || records[0].Project != "api" {
+		t.Errorf("Query(FailedOnly) = %+v, want a2's review", records)
+	}
+
+	// GC keeps each project's newest run, or those since a3 started
+	policy := GCPolicy{KeepLast: 1, Before: start.Add(2 * time.Hour), DryRun: true}
+	removed, err := store.GC(policy)
+	if err != nil {
+		t.Fatalf("GC(DryRun) error = %v", err)
+	}
+	if got := ids(removed); !slices.Equal(got, []string{"a2", "a1"}) {
+		t.Errorf("GC(DryRun) = %v, want [a2 a1]", got)
+	}
+	if sessions, _ := store.List(SessionFilter{}); len(sessions) != 4 {
+		t.Errorf("GC(DryRun) removed runs: %v", ids(sessions))
+	}
+	policy.DryRun = false
+	if _, err := store.GC(policy); err != nil {
+		t.Fatalf("GC() error = %v", err)
+	}
+	sessions, err := store.List(SessionFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := ids(sessions); !slices.Equal(got, []string{"w1", "a3"}) {
+		t.Errorf("after GC, List() = %v, want [w1 a3]", got)
+	}
+	if _, err := store.Load("api", "a1"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Load() of a collected run error = %v, want os.ErrNotExist", err)
+	}
+	if removed, err := store.GC(GCPolicy{}); err != nil || len(removed) != 0 {
+		t.Errorf("GC() with no policy removed %v (%v), want nothing", ids(removed), err)
+	}
+}
+
+// TestSQLiteStore_Reopen tests that runs saved to the SQLite store are
+// there when it's opened again, and encrypted in the database with a key.
+func TestSQLiteStore_Reopen(t *testing.T) {
+	dir := t.TempDir()
+	key := []byte("0123456789abcdef0123456789abcdef")
+	store, err := OpenSQLiteStore(dir, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	run := &RunResult{RunID: "r1", Success: true, Tasks: []TaskResult{{TaskName: "review", Stdout: "proprietary findings"}}}
+	if err := store.Save("api", run); err != nil {
+		t.Fatal(err)
+	}
+	store.Close()
+
+	data, err := os.ReadFile(filepath.Join(dir, SQLiteFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(data, []byte("proprietary findings")) {
+		t.Errorf("%s holds the run in plaintext", SQLiteFile)
+	}
+
+	store, err = OpenSQLiteStore(dir, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	got, err := store.Load("api", "r1")
+	if err != nil {
+		t.Fatalf("Load() after reopening error = %v", err)
+	}
+	if got.Tasks[0].Stdout != "proprietary findings" {
+		t.Errorf("Load() after reopening = %+v", got)
+	}
+}
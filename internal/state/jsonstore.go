@@ -0,0 +1,74 @@
+package state
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// JSONStore keeps run records as run.json files in their run directories
+// under a Cortex home: the default Store.
+type JSONStore struct {
+	baseDir string // Cortex home, e.g. ~/.cortex
+	key     []byte // Encrypts the run files it writes (nil = plaintext)
+}
+
+// NewJSONStore creates a JSONStore for the runs under baseDir, encrypting
+// the run files it writes with key unless it's nil.
+func NewJSONStore(baseDir string, key []byte) *JSONStore {
+	return &JSONStore{baseDir: baseDir, key: key}
+}
+
+// Save writes run to its run directory's run.json.
+func (s *JSONStore) Save(project string, run *RunResult) error {
+	if run.RunID == "" {
+		return fmt.Errorf("run has no ID")
+	}
+	runDir := RunDirPath(s.baseDir, project, run.RunID)
+	if err := os.MkdirAll(runDir, 0755); err != nil {
+		return fmt.Errorf("failed to create run directory: %w", err)
+	}
+
+	data, err := json.MarshalIndent(run, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal run result: %w", err)
+	}
+	if err := writeFile(filepath.Join(runDir, "run.json"), data, s.key); err != nil {
+		return fmt.Errorf("failed to write run result: %w", err)
+	}
+	return nil
+}
+
+// Load reads a run's run.json.
+func (s *JSONStore) Load(project, runID string) (*RunResult, error) {
+	return GetSessionFromPath(s.baseDir, project, runID)
+}
+
+// List reads the run.json of every run filter selects.
+func (s *JSONStore) List(filter SessionFilter) ([]SessionInfo, error) {
+	return ListSessionsFromPath(s.baseDir, filter)
+}
+
+// Query reads the run.json of every run q could select.
+func (s *JSONStore) Query(q Query) ([]TaskRecord, error) {
+	return queryRuns(s, q)
+}
+
+// GC removes the run directories of the runs policy doesn't keep.
+func (s *JSONStore) GC(policy GCPolicy) ([]SessionInfo, error) {
+	sessions, err := s.List(SessionFilter{Project: policy.Project})
+	if err != nil {
+		return nil, err
+	}
+	removed := policy.collect(sessions)
+	if policy.DryRun {
+		return removed, nil
+	}
+	for i, session := range removed {
+		if err := os.RemoveAll(session.RunDir); err != nil {
+			return removed[:i], fmt.Errorf("failed to remove run %s: %w", session.RunID, err)
+		}
+	}
+	return removed, nil
+}
@@ -0,0 +1,75 @@
+package state
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/adityaraj/agentflow/internal/crypt"
+)
+
+// ManifestFile is the name of the manifest saved in each run directory.
+const ManifestFile = "manifest.json"
+
+// Manifest records the resolved inputs of a run, so it can be re-executed
+// with `cortex rerun` and differences from the original can be flagged.
+type Manifest struct {
+	RunID      string            `json:"run_id"`
+	CreatedAt  time.Time         `json:"created_at"`
+	Dir        string            `json:"dir"`                   // Working directory of the run
+	ConfigPath string            `json:"config_path"`           // Cortexfile the run was started from
+	ConfigHash string            `json:"config_hash"`           // Hash of the Cortexfile as written
+	Config     string            `json:"config"`                // Resolved config as YAML: flows spliced in, files inlined, settings merged
+	Tools      map[string]string `json:"tools,omitempty"`       // CLI versions of the tools used
+	Vars       map[string]string `json:"vars,omitempty"`        // Values of metadata placeholders; env values are hashed
+	Tasks      []ManifestTask    `json:"tasks"`                 // In execution order
+	BaseCommit string            `json:"base_commit,omitempty"` // Commit the diff base ref resolved to, if any task is diff-scoped
+}
+
+// ManifestTask records how a task was resolved.
+type ManifestTask struct {
+	Name       string `json:"name"`
+	Agent      string `json:"agent"`
+	Tool       string `json:"tool"`
+	Model      string `json:"model,omitempty"`
+	PromptHash string `json:"prompt_hash"` // Hash of the prompt template, before placeholders are filled
+}
+
+// Hash returns a content hash of s for manifests ("sha256:<hex>").
+func Hash(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// SaveManifest saves the run's manifest to its run directory.
+func (s *RunStore) SaveManifest(m *Manifest) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal manifest: %w", err)
+	}
+	if err := s.WriteRunFile(ManifestFile, data); err != nil {
+		return fmt.Errorf("failed to write manifest: %w", err)
+	}
+	return nil
+}
+
+// LoadManifest loads the manifest saved in a run directory.
+func LoadManifest(runDir string) (*Manifest, error) {
+	data, err := crypt.ReadFile(filepath.Join(runDir, ManifestFile))
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("run has no %s (it predates run manifests)", ManifestFile)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var m Manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", ManifestFile, err)
+	}
+	return &m, nil
+}
@@ -0,0 +1,97 @@
+package state
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/adityaraj/agentflow/internal/crypt"
+)
+
+const (
+	// MemoryFile is the project memory, kept beside the project's runs.
+	MemoryFile = "memory.md"
+
+	// maxMemory caps how much of the memory is handed to prompts; the
+	// oldest entries are dropped first.
+	maxMemory = 32 * 1024
+
+	// memoryEntryPrefix starts each entry in the memory file.
+	memoryEntryPrefix = "## "
+)
+
+// memoryMu serializes appends from tasks running in parallel.
+var memoryMu sync.Mutex
+
+// MemoryPath returns the path of the project's memory file.
+func (s *RunStore) MemoryPath() string {
+	return filepath.Join(filepath.Dir(s.runDir), MemoryFile)
+}
+
+// ReadMemory returns the project memory, newest entries last. When the file
+// is larger than the prompt cap, only the most recent whole entries are
+// returned. A project without memory yields an empty string.
+func (s *RunStore) ReadMemory() (string, error) {
+	data, err := crypt.ReadFile(s.MemoryPath())
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read memory: %w", err)
+	}
+	return strings.TrimSpace(recentEntries(string(data), maxMemory)), nil
+}
+
+// AppendMemory records text written by task in this run as a new memory entry.
+func (s *RunStore) AppendMemory(task, text string) error {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return nil
+	}
+	entry := fmt.Sprintf("%s%s · run %s · %s\n\n%s\n\n",
+		memoryEntryPrefix, time.Now().Format("2006-01-02 15:04"), s.runID, task, text)
+
+	memoryMu.Lock()
+	defer memoryMu.Unlock()
+
+	if s.key != nil {
+		// An encrypted file can't be appended to; rewrite it whole
+		data, err := crypt.ReadFile(s.MemoryPath())
+		if err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to read memory: %w", err)
+		}
+		if err := s.writeFile(s.MemoryPath(), append(data, entry...)); err != nil {
+			return fmt.Errorf("failed to write memory: %w", err)
+		}
+		return nil
+	}
+	if data, err := os.ReadFile(s.MemoryPath()); err == nil && crypt.IsEncrypted(data) {
+		return fmt.Errorf("failed to write memory: %w", crypt.ErrNoKey)
+	}
+
+	f, err := os.OpenFile(s.MemoryPath(), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open memory: %w", err)
+	}
+	if _, err := f.WriteString(entry); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write memory: %w", err)
+	}
+	return f.Close()
+}
+
+// recentEntries returns the tail of memory that fits in limit bytes,
+// starting at an entry boundary when one is available.
+func recentEntries(memory string, limit int) string {
+	if len(memory) <= limit {
+		return memory
+	}
+	tail := memory[len(memory)-limit:]
+	if i := strings.Index(tail, "\n"+memoryEntryPrefix); i >= 0 {
+		return tail[i+1:]
+	}
+	return tail
+}
@@ -2,6 +2,7 @@
 package state
 
 import (
+	"encoding/json"
 	"time"
 )
 
@@ -12,6 +13,12 @@ type TokenUsage struct {
 	TotalTokens  int `json:"total_tokens"`
 	CacheRead    int `json:"cache_read_tokens,omitempty"`
 	CacheWrite   int `json:"cache_write_tokens,omitempty"`
+
+	CostUSD float64 `json:"cost_usd,omitempty"` // As reported by the tool, 0 if unknown
+
+	// Estimated is set when the tool didn't report usage and the counts
+	// were estimated from the prompt and output.
+	Estimated bool `json:"estimated,omitempty"`
 }
 
 // TaskResult represents the result of executing a single task.
@@ -20,6 +27,7 @@ type TaskResult struct {
 	Agent      string     `json:"agent"`
 	Tool       string     `json:"tool"`
 	Model      string     `json:"model,omitempty"`
+	RoutedFrom string     `json:"routed_from,omitempty"` // Declared agent, when routed to an interchangeable one
 	Prompt     string     `json:"prompt"`
 	Stdout     string     `json:"stdout"`
 	Stderr     string     `json:"stderr,omitempty"`
@@ -29,16 +37,157 @@ type TaskResult struct {
 	EndTime    time.Time  `json:"end_time"`
 	Duration   string     `json:"duration"` // Human-readable duration
 	TokenUsage TokenUsage `json:"token_usage,omitempty"`
+
+	ErrorCategory ErrorCategory `json:"error_category,omitempty"` // Why the task failed, if it did
+	ErrorCode     string        `json:"error_code,omitempty"`     // Stable code of ErrorCategory, e.g. CORTEX-RUN-003
+	Skipped       string        `json:"skipped,omitempty"`        // Why the task didn't run, if it was skipped
+	OutputFile    string        `json:"output_file,omitempty"`    // Where the task's output was saved, if configured
+	Patch         string        `json:"patch,omitempty"`          // Run file holding the changes of a 'write: patch' task, if it made any
+	Workdir       string        `json:"workdir,omitempty"`        // Absolute directory the task ran in
+
+	Verification *VerifyResult  `json:"verification,omitempty"` // Post-task verification, if configured
+	Expectation  *ExpectResult  `json:"expectation,omitempty"`  // Check of the output against 'expect', if configured
+	Changes      *ChangeSummary `json:"changes,omitempty"`      // Files changed by a write task, if tracked
+	Commit       *CommitResult  `json:"commit,omitempty"`       // Commit created for the task's changes, if configured
+	Degradation  *Degradation   `json:"degradation,omitempty"`  // Set when the prompt had to be reduced to run
+	Attempts     []Attempt      `json:"attempts,omitempty"`     // Each run of the agent, when the task has retries
+	Resources    *ResourceUsage `json:"resources,omitempty"`    // CPU, memory, and processes the agent used, where measured
+
+	Transcript []ToolCall `json:"transcript,omitempty"` // Tool calls made by the agent, when the tool exposes them
+
+	// StructuredOutput is the output of an 'expect: json' task, parsed and
+	// re-encoded, as dependent tasks see it.
+	StructuredOutput json.RawMessage `json:"structured_output,omitempty"`
+}
+
+// ToolCall records one tool call made by an agent, for auditing what it did.
+type ToolCall struct {
+	Time    time.Time `json:"time"`
+	Tool    string    `json:"tool"`
+	ID      string    `json:"id,omitempty"`
+	Summary string    `json:"summary,omitempty"` // Short description, e.g. the command run
+	Path    string    `json:"path,omitempty"`    // File the call read or changed
+	Edit    bool      `json:"edit,omitempty"`    // The call changed Path
+	Input   string    `json:"input,omitempty"`   // Raw JSON input
+	Output  string    `json:"output,omitempty"`  // Tool result, truncated
+	IsError bool      `json:"is_error,omitempty"`
+}
+
+// ResourceUsage records the resources a task's agent processes used, summed
+// over retries and fix attempts.
+type ResourceUsage struct {
+	UserCPUMs   int64 `json:"user_cpu_ms"`
+	SystemCPUMs int64 `json:"system_cpu_ms"`
+	PeakMemory  int64 `json:"peak_memory_bytes,omitempty"` // Largest combined resident memory, 0 if unknown
+	Processes   int   `json:"processes,omitempty"`         // Processes started, including the agent; 0 if unknown
+}
+
+// CPU returns the total CPU time.
+func (u *ResourceUsage) CPU() time.Duration {
+	return time.Duration(u.UserCPUMs+u.SystemCPUMs) * time.Millisecond
+}
+
+// Attempt records one run of a task's agent under its retry policy.
+type Attempt struct {
+	Number        int           `json:"number"` // 1 for the first run
+	StartTime     time.Time     `json:"start_time"`
+	Duration      string        `json:"duration"`
+	ExitCode      int           `json:"exit_code"`
+	Success       bool          `json:"success"`
+	ErrorCategory ErrorCategory `json:"error_category,omitempty"`
+	Error         string        `json:"error,omitempty"` // Run error or the tail of stderr, if the attempt failed
+}
+
+// Degradation records that a task ran with a reduced prompt after a context overflow.
+type Degradation struct {
+	Reason         ErrorCategory `json:"reason"`
+	Strategy       string        `json:"strategy"` // truncate or summarize
+	Attempts       int           `json:"attempts"` // Retries with a reduced prompt
+	OriginalLength int           `json:"original_length"`
+	FinalLength    int           `json:"final_length"`
+}
+
+// ErrorCategory classifies why a task failed.
+type ErrorCategory string
+
+// Error categories for failed tasks.
+const (
+	ErrorAuth            ErrorCategory = "auth"             // Invalid or missing credentials
+	ErrorRateLimit       ErrorCategory = "rate_limit"       // Provider rate limit or overload
+	ErrorContextOverflow ErrorCategory = "context_overflow" // Prompt exceeded the model's context window
+	ErrorNetwork         ErrorCategory = "network"          // Provider unreachable
+	ErrorCrash           ErrorCategory = "crash"            // Tool killed by a signal or panicked
+	ErrorCancelled       ErrorCategory = "cancelled"        // Run was interrupted
+	ErrorBudget          ErrorCategory = "budget_exceeded"  // Token budget for the task or run ran out
+	ErrorDiskSpace       ErrorCategory = "disk_space"       // A disk the run writes to was nearly full
+	ErrorTimeout         ErrorCategory = "timeout"          // Task ran longer than its timeout
+	ErrorFailed          ErrorCategory = "failed"           // Any other non-zero exit or failed check
+)
+
+// errorCodes are the stable codes of the error categories, for scripts and
+// support docs. A code is never reused for a different category.
+var errorCodes = map[ErrorCategory]string{
+	ErrorFailed:          "CORTEX-RUN-001",
+	ErrorAuth:            "CORTEX-RUN-002",
+	ErrorRateLimit:       "CORTEX-RUN-003",
+	ErrorContextOverflow: "CORTEX-RUN-004",
+	ErrorNetwork:         "CORTEX-RUN-005",
+	ErrorCrash:           "CORTEX-RUN-006",
+	ErrorCancelled:       "CORTEX-RUN-007",
+	ErrorBudget:          "CORTEX-RUN-008",
+	ErrorDiskSpace:       "CORTEX-RUN-009",
+	ErrorTimeout:         "CORTEX-RUN-010",
+}
+
+// Code returns the stable error code of the category, e.g. CORTEX-RUN-003
+// for rate_limit, or "" if there is none.
+func (c ErrorCategory) Code() string {
+	return errorCodes[c]
+}
+
+// ExpectResult records the check of a task's output against its expected
+// format and schema.
+type ExpectResult struct {
+	Format   string   `json:"format"` // e.g. json
+	Valid    bool     `json:"valid"`
+	Attempts int      `json:"attempts"`         // Outputs checked, including those of re-runs
+	Errors   []string `json:"errors,omitempty"` // What was wrong with the last output, if it was invalid
+}
+
+// CommitResult records the commit and pull request created for a write task.
+type CommitResult struct {
+	Branch         string `json:"branch"`
+	SHA            string `json:"sha"`
+	PullRequestURL string `json:"pull_request_url,omitempty"`
+	Error          string `json:"error,omitempty"` // Push or PR creation failure; the local commit is kept
+}
+
+// ChangeSummary records the files a write task changed relative to its baseline.
+type ChangeSummary struct {
+	Files    []string `json:"files"`
+	Lines    int      `json:"lines"`              // Added plus deleted lines
+	Reverted bool     `json:"reverted,omitempty"` // Changes were rolled back for exceeding limits
+}
+
+// VerifyResult records the outcome of a task's verify command.
+type VerifyResult struct {
+	Command  string `json:"command"`
+	Output   string `json:"output"`
+	ExitCode int    `json:"exit_code"`
+	Success  bool   `json:"success"`
+	Attempts int    `json:"attempts"` // Number of verify runs, including fix-loop retries
 }
 
 // RunResult represents the complete result of an agentflow run.
 type RunResult struct {
 	RunID      string       `json:"run_id"`
+	Workflow   string       `json:"workflow,omitempty"` // Named workflow that ran ("" = the top-level tasks)
 	StartTime  time.Time    `json:"start_time"`
 	EndTime    time.Time    `json:"end_time"`
 	Success    bool         `json:"success"`
 	Tasks      []TaskResult `json:"tasks"`
 	TokenUsage TokenUsage   `json:"token_usage,omitempty"` // Aggregate token usage
+	DigestFile string       `json:"digest_file,omitempty"` // Markdown digest of the run, if configured
 }
 
 // CalculateTotalTokens calculates aggregate token usage from all tasks.
@@ -50,6 +199,8 @@ func (r *RunResult) CalculateTotalTokens() {
 		r.TokenUsage.TotalTokens += task.TokenUsage.TotalTokens
 		r.TokenUsage.CacheRead += task.TokenUsage.CacheRead
 		r.TokenUsage.CacheWrite += task.TokenUsage.CacheWrite
+		r.TokenUsage.CostUSD += task.TokenUsage.CostUSD
+		r.TokenUsage.Estimated = r.TokenUsage.Estimated || task.TokenUsage.Estimated
 	}
 }
 
@@ -85,3 +236,13 @@ func (r *TaskResult) SetTokenUsage(input, output, cacheRead, cacheWrite int) {
 		CacheWrite:   cacheWrite,
 	}
 }
+
+// SetResources sets the resource usage for the task.
+func (r *TaskResult) SetResources(userCPU, systemCPU time.Duration, peakMemory int64, processes int) {
+	r.Resources = &ResourceUsage{
+		UserCPUMs:   userCPU.Milliseconds(),
+		SystemCPUMs: systemCPU.Milliseconds(),
+		PeakMemory:  peakMemory,
+		Processes:   processes,
+	}
+}
@@ -0,0 +1,115 @@
+package state
+
+import (
+	"slices"
+
+	"github.com/adityaraj/agentflow/internal/config"
+	"github.com/adityaraj/agentflow/internal/secrets"
+)
+
+// Redacted replaces the text of redacted result fields.
+const Redacted = "[redacted]"
+
+// SetRetention makes the store keep only what r allows of task results
+// (nil = everything). Results in memory are left as they are.
+func (s *RunStore) SetRetention(r *config.RetentionConfig) {
+	s.retention = r
+}
+
+// SetSecrets makes the store mask the secrets r knows in the task results
+// it saves (nil = none). Results in memory are left as they are.
+func (s *RunStore) SetSecrets(r *secrets.Redactor) {
+	s.secrets = r
+}
+
+// Retained returns a copy of run with the store's retention settings
+// applied and its secrets masked, for writing it somewhere that persists,
+// e.g. a report.
+func (s *RunStore) Retained(run *RunResult) *RunResult {
+	if s.retention == nil && s.secrets == nil {
+		return run
+	}
+	retained := *run
+	retained.Tasks = make([]TaskResult, len(run.Tasks))
+	for i := range run.Tasks {
+		retained.Tasks[i] = *s.retainTask(&run.Tasks[i])
+	}
+	return &retained
+}
+
+// retainTask returns a copy of result with the store's retention settings
+// applied and its secrets masked.
+func (s *RunStore) retainTask(result *TaskResult) *TaskResult {
+	if s.secrets != nil {
+		result = maskSecrets(s.secrets, result)
+	}
+	r := s.retention
+	if r == nil {
+		return result
+	}
+	retained := *result
+
+	switch r.Prompts {
+	case config.RetainHash:
+		retained.Prompt = Hash(result.Prompt)
+	case config.RetainNone:
+		retained.Prompt = ""
+	}
+
+	if slices.Contains(r.Redact, config.RedactStdout) {
+		retained.Stdout = redact(result.Stdout)
+	}
+	if slices.Contains(r.Redact, config.RedactStderr) {
+		retained.Stderr = redact(result.Stderr)
+		retained.Attempts = slices.Clone(result.Attempts)
+		for i := range retained.Attempts {
+			retained.Attempts[i].Error = redact(retained.Attempts[i].Error)
+		}
+	}
+	if slices.Contains(r.Redact, config.RedactTranscript) {
+		// Keep which tools ran on which files, for auditing
+		retained.Transcript = slices.Clone(result.Transcript)
+		for i := range retained.Transcript {
+			call := &retained.Transcript[i]
+			call.Summary, call.Input, call.Output = redact(call.Summary), redact(call.Input), redact(call.Output)
+		}
+	}
+	if slices.Contains(r.Redact, config.RedactVerify) && result.Verification != nil {
+		verify := *result.Verification
+		verify.Output = redact(verify.Output)
+		retained.Verification = &verify
+	}
+	return &retained
+}
+
+// redact returns Redacted in place of non-empty text.
+func redact(text string) string {
+	if text == "" {
+		return ""
+	}
+	return Redacted
+}
+
+// maskSecrets returns a copy of result with the secrets masked in the text
+// agents were given or produced.
+func maskSecrets(r *secrets.Redactor, result *TaskResult) *TaskResult {
+	masked := *result
+	masked.Prompt = r.Redact(result.Prompt)
+	masked.Stdout = r.Redact(result.Stdout)
+	masked.Stderr = r.Redact(result.Stderr)
+	masked.Attempts = slices.Clone(result.Attempts)
+	for i := range masked.Attempts {
+		masked.Attempts[i].Error = r.Redact(masked.Attempts[i].Error)
+	}
+	masked.Transcript = slices.Clone(result.Transcript)
+	for i := range masked.Transcript {
+		call := &masked.Transcript[i]
+		call.Summary, call.Input, call.Output = r.Redact(call.Summary), r.Redact(call.Input), r.Redact(call.Output)
+	}
+	if result.Verification != nil {
+		verify := *result.Verification
+		verify.Output = r.Redact(verify.Output)
+		masked.Verification = &verify
+	}
+	return &masked
+}
@@ -2,17 +2,21 @@ package state
 
 import (
 	"encoding/json"
+	"fmt"
 	"os"
 	"path/filepath"
 	"sort"
 	"strings"
 	"time"
+
+	"github.com/adityaraj/agentflow/internal/crypt"
 )
 
 // SessionInfo contains summary information about a session.
 type SessionInfo struct {
 	RunID       string        `json:"run_id"`
 	Project     string        `json:"project"`
+	Workflow    string        `json:"workflow,omitempty"` // Named workflow that ran ("" = the top-level tasks)
 	StartTime   time.Time     `json:"start_time"`
 	EndTime     time.Time     `json:"end_time"`
 	Success     bool          `json:"success"`
@@ -20,6 +24,10 @@ type SessionInfo struct {
 	Duration    time.Duration `json:"duration"`
 	RunDir      string        `json:"run_dir"`
 	TotalTokens int           `json:"total_tokens,omitempty"` // Total tokens used in session
+
+	FailedTask    string        `json:"failed_task,omitempty"`    // First task that failed, if any
+	ErrorCategory ErrorCategory `json:"error_category,omitempty"` // Why FailedTask failed
+	ErrorCode     string        `json:"error_code,omitempty"`     // Stable code of ErrorCategory
 }
 
 // SessionFilter contains filter options for listing sessions.
@@ -29,14 +37,14 @@ type SessionFilter struct {
 	FailedOnly bool   // Only show failed sessions
 }
 
-// ListSessions lists all sessions from ~/.cortex/sessions.
+// ListSessions lists the sessions in the default store (see UseStore).
 func ListSessions(filter SessionFilter) ([]SessionInfo, error) {
-	baseDir, err := getCortexDir()
+	store, err := DefaultStore()
 	if err != nil {
 		return nil, err
 	}
 
-	return ListSessionsFromPath(baseDir, filter)
+	return store.List(filter)
 }
 
 // ListSessionsFromPath lists sessions from a custom base path (for testing).
@@ -83,28 +91,7 @@ func ListSessionsFromPath(baseDir string, filter SessionFilter) ([]SessionInfo,
 		}
 	}
 
-	// Filter failed only
-	if filter.FailedOnly {
-		filtered := make([]SessionInfo, 0)
-		for _, s := range sessions {
-			if !s.Success {
-				filtered = append(filtered, s)
-			}
-		}
-		sessions = filtered
-	}
-
-	// Sort by start time (newest first)
-	sort.Slice(sessions, func(i, j int) bool {
-		return sessions[i].StartTime.After(sessions[j].StartTime)
-	})
-
-	// Apply limit
-	if filter.Limit > 0 && len(sessions) > filter.Limit {
-		sessions = sessions[:filter.Limit]
-	}
-
-	return sessions, nil
+	return filterSessions(sessions, filter), nil
 }
 
 // listProjectSessions lists all sessions within a project directory.
@@ -145,7 +132,7 @@ func listProjectSessions(projectDir, projectName string) ([]SessionInfo, error)
 func loadSessionInfo(runDir, runID, project string) (SessionInfo, error) {
 	runFile := filepath.Join(runDir, "run.json")
 
-	data, err := os.ReadFile(runFile)
+	data, err := crypt.ReadFile(runFile)
 	if err != nil {
 		// Try to construct info from directory name
 		return SessionInfo{
@@ -163,16 +150,25 @@ func loadSessionInfo(runDir, runID, project string) (SessionInfo, error) {
 			RunDir:  runDir,
 		}, nil
 	}
+	return sessionInfo(&runResult, project, runDir), nil
+}
 
-	// Calculate total tokens
+// sessionInfo summarizes a run of project.
+func sessionInfo(runResult *RunResult, project, runDir string) SessionInfo {
+	// Calculate total tokens and find the first failure
 	totalTokens := 0
-	for _, task := range runResult.Tasks {
+	var failed *TaskResult
+	for i, task := range runResult.Tasks {
 		totalTokens += task.TokenUsage.TotalTokens
+		if !task.Success && failed == nil {
+			failed = &runResult.Tasks[i]
+		}
 	}
 
-	return SessionInfo{
+	info := SessionInfo{
 		RunID:       runResult.RunID,
 		Project:     project,
+		Workflow:    runResult.Workflow,
 		StartTime:   runResult.StartTime,
 		EndTime:     runResult.EndTime,
 		Success:     runResult.Success,
@@ -180,17 +176,23 @@ func loadSessionInfo(runDir, runID, project string) (SessionInfo, error) {
 		Duration:    runResult.EndTime.Sub(runResult.StartTime),
 		RunDir:      runDir,
 		TotalTokens: totalTokens,
-	}, nil
+	}
+	if failed != nil {
+		info.FailedTask = failed.TaskName
+		info.ErrorCategory = failed.ErrorCategory
+		info.ErrorCode = failed.ErrorCategory.Code()
+	}
+	return info
 }
 
-// GetSession loads full session details by run ID.
+// GetSession loads full session details by run ID from the default store.
 func GetSession(project, runID string) (*RunResult, error) {
-	baseDir, err := getCortexDir()
+	store, err := DefaultStore()
 	if err != nil {
 		return nil, err
 	}
 
-	return GetSessionFromPath(baseDir, project, runID)
+	return store.Load(project, runID)
 }
 
 // GetSessionFromPath loads session from a custom base path.
@@ -198,7 +200,7 @@ func GetSessionFromPath(baseDir, project, runID string) (*RunResult, error) {
 	runDir := filepath.Join(baseDir, "sessions", project, "run-"+runID)
 	runFile := filepath.Join(runDir, "run.json")
 
-	data, err := os.ReadFile(runFile)
+	data, err := crypt.ReadFile(runFile)
 	if err != nil {
 		return nil, err
 	}
@@ -218,102 +220,47 @@ type ProjectSummary struct {
 	LatestTime   time.Time // Most recent session time
 }
 
-// ListProjectSummaries lists all projects with session summaries, sorted by latest time.
+// ListProjectSummaries lists all projects in the default store with session
+// summaries, sorted by latest time.
 func ListProjectSummaries(limit int) ([]ProjectSummary, error) {
-	baseDir, err := getCortexDir()
+	store, err := DefaultStore()
 	if err != nil {
 		return nil, err
 	}
-
-	return ListProjectSummariesFromPath(baseDir, limit)
-}
-
-// ListProjectSummariesFromPath lists project summaries from a custom base path.
-func ListProjectSummariesFromPath(baseDir string, limit int) ([]ProjectSummary, error) {
-	sessionsDir := filepath.Join(baseDir, "sessions")
-
-	if _, err := os.Stat(sessionsDir); os.IsNotExist(err) {
-		return []ProjectSummary{}, nil
-	}
-
-	entries, err := os.ReadDir(sessionsDir)
+	sessions, err := store.List(SessionFilter{})
 	if err != nil {
 		return nil, err
 	}
 
 	var summaries []ProjectSummary
-
-	for _, entry := range entries {
-		if !entry.IsDir() {
-			continue
+	index := make(map[string]int)
+	for _, s := range sessions { // Newest first
+		i, ok := index[s.Project]
+		if !ok {
+			i = len(summaries)
+			index[s.Project] = i
+			summaries = append(summaries, ProjectSummary{Name: s.Project, LatestTime: s.StartTime})
 		}
-
-		projectName := entry.Name()
-		projectDir := filepath.Join(sessionsDir, projectName)
-
-		sessions, err := listProjectSessions(projectDir, projectName)
-		if err != nil || len(sessions) == 0 {
-			continue
-		}
-
-		// Find latest session time
-		var latestTime time.Time
-		for _, s := range sessions {
-			if s.StartTime.After(latestTime) {
-				latestTime = s.StartTime
-			}
-		}
-
-		summaries = append(summaries, ProjectSummary{
-			Name:         projectName,
-			SessionCount: len(sessions),
-			LatestTime:   latestTime,
-		})
+		summaries[i].SessionCount++
 	}
 
-	// Sort by latest time (newest first)
-	sort.Slice(summaries, func(i, j int) bool {
-		return summaries[i].LatestTime.After(summaries[j].LatestTime)
-	})
-
-	// Apply limit
 	if limit > 0 && len(summaries) > limit {
 		summaries = summaries[:limit]
 	}
-
 	return summaries, nil
 }
 
-// ListProjects lists all projects with sessions.
+// ListProjects lists all projects with sessions in the default store.
 func ListProjects() ([]string, error) {
-	baseDir, err := getCortexDir()
-	if err != nil {
-		return nil, err
-	}
-
-	return ListProjectsFromPath(baseDir)
-}
-
-// ListProjectsFromPath lists projects from a custom base path.
-func ListProjectsFromPath(baseDir string) ([]string, error) {
-	sessionsDir := filepath.Join(baseDir, "sessions")
-
-	if _, err := os.Stat(sessionsDir); os.IsNotExist(err) {
-		return []string{}, nil
-	}
-
-	entries, err := os.ReadDir(sessionsDir)
+	summaries, err := ListProjectSummaries(0)
 	if err != nil {
 		return nil, err
 	}
 
-	var projects []string
-	for _, entry := range entries {
-		if entry.IsDir() {
-			projects = append(projects, entry.Name())
-		}
+	projects := make([]string, len(summaries))
+	for i, s := range summaries {
+		projects[i] = s.Name
 	}
-
 	sort.Strings(projects)
 	return projects, nil
 }
@@ -340,3 +287,17 @@ func FormatDuration(d time.Duration) string {
 	}
 	return d.Round(time.Minute).String()
 }
+
+// FindSession looks up a session by run ID across all projects.
+func FindSession(runID string) (*SessionInfo, error) {
+	sessions, err := ListSessions(SessionFilter{})
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range sessions {
+		if s.RunID == runID {
+			return &s, nil
+		}
+	}
+	return nil, fmt.Errorf("run %q not found", runID)
+}
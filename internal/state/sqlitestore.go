@@ -0,0 +1,185 @@
+package state
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	_ "modernc.org/sqlite" // Registers the "sqlite" database/sql driver
+
+	"github.com/adityaraj/agentflow/internal/crypt"
+)
+
+// StoreSQLite is the name of the SQLite Store.
+const StoreSQLite = "sqlite"
+
+// SQLiteFile is the SQLite Store's database, in the Cortex home.
+const SQLiteFile = "cortex.db"
+
+func init() {
+	RegisterStore(StoreSQLite, func(opts StoreOptions) (Store, error) {
+		return OpenSQLiteStore(opts.Dir, opts.Key)
+	})
+}
+
+// sqliteSchema creates the runs table. Run records are stored whole, as
+// JSON (encrypted with the store's key, if any); the columns beside them
+// are what List and GC select on.
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS runs (
+	project    TEXT NOT NULL,
+	run_id     TEXT NOT NULL,
+	start_time INTEGER NOT NULL,
+	success    INTEGER NOT NULL,
+	data       BLOB NOT NULL,
+	PRIMARY KEY (project, run_id)
+);
+CREATE INDEX IF NOT EXISTS runs_start_time ON runs (start_time);
+`
+
+// SQLiteStore keeps run records in a SQLite database, for machines with
+// enough runs that reading every run.json to list them is slow. Run
+// directories stay on disk.
+type SQLiteStore struct {
+	db      *sql.DB
+	baseDir string // Cortex home, e.g. ~/.cortex
+	key     []byte // Encrypts stored runs (nil = plaintext)
+}
+
+// OpenSQLiteStore opens (creating it if needed) the database in baseDir,
+// encrypting the runs it stores with key unless it's nil.
+func OpenSQLiteStore(baseDir string, key []byte) (*SQLiteStore, error) {
+	if err := os.MkdirAll(baseDir, 0755); err != nil {
+		return nil, err
+	}
+	db, err := sql.Open("sqlite", filepath.Join(baseDir, SQLiteFile)+"?_pragma=busy_timeout(5000)")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &SQLiteStore{db: db, baseDir: baseDir, key: key}, nil
+}
+
+// Close closes the database.
+func (s *SQLiteStore) Close() error {
+	return s.db.Close()
+}
+
+// Save inserts or replaces the record of run.
+func (s *SQLiteStore) Save(project string, run *RunResult) error {
+	if run.RunID == "" {
+		return fmt.Errorf("run has no ID")
+	}
+	data, err := json.Marshal(run)
+	if err != nil {
+		return fmt.Errorf("failed to marshal run result: %w", err)
+	}
+	if s.key != nil {
+		if data, err = crypt.Encrypt(s.key, data); err != nil {
+			return err
+		}
+	}
+	_, err = s.db.Exec(`INSERT INTO runs (project, run_id, start_time, success, data) VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (project, run_id) DO UPDATE SET start_time = excluded.start_time, success = excluded.success, data = excluded.data`,
+		project, run.RunID, run.StartTime.UnixNano(), run.Success, data)
+	if err != nil {
+		return fmt.Errorf("failed to save run %s: %w", run.RunID, err)
+	}
+	return nil
+}
+
+// Load reads the record of a run.
+func (s *SQLiteStore) Load(project, runID string) (*RunResult, error) {
+	var data []byte
+	err := s.db.QueryRow(`SELECT data FROM runs WHERE project = ? AND run_id = ?`, project, runID).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("run %s of %s: %w", runID, project, os.ErrNotExist)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return s.decode(data)
+}
+
+// List reads the records of the runs filter selects.
+func (s *SQLiteStore) List(filter SessionFilter) ([]SessionInfo, error) {
+	query := `SELECT project, data FROM runs WHERE (? = '' OR project = ?) AND (? = 0 OR success = 0) ORDER BY start_time DESC`
+	args := []any{filter.Project, filter.Project, filter.FailedOnly}
+	if filter.Limit > 0 {
+		query += ` LIMIT ?`
+		args = append(args, filter.Limit)
+	}
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := []SessionInfo{}
+	for rows.Next() {
+		var project string
+		var data []byte
+		if err := rows.Scan(&project, &data); err != nil {
+			return nil, err
+		}
+		run, err := s.decode(data)
+		if err != nil {
+			continue // Skip runs we can't read, as the JSON store does
+		}
+		sessions = append(sessions, sessionInfo(run, project, RunDirPath(s.baseDir, project, run.RunID)))
+	}
+	return sessions, rows.Err()
+}
+
+// Query reads the records of the runs q could select.
+func (s *SQLiteStore) Query(q Query) ([]TaskRecord, error) {
+	return queryRuns(s, q)
+}
+
+// GC deletes the records and run directories of the runs policy doesn't
+// keep.
+func (s *SQLiteStore) GC(policy GCPolicy) ([]SessionInfo, error) {
+	sessions, err := s.List(SessionFilter{Project: policy.Project})
+	if err != nil {
+		return nil, err
+	}
+	removed := policy.collect(sessions)
+	if policy.DryRun {
+		return removed, nil
+	}
+	for i, session := range removed {
+		if _, err := s.db.Exec(`DELETE FROM runs WHERE project = ? AND run_id = ?`, session.Project, session.RunID); err != nil {
+			return removed[:i], fmt.Errorf("failed to remove run %s: %w", session.RunID, err)
+		}
+		if err := os.RemoveAll(session.RunDir); err != nil {
+			return removed[:i+1], fmt.Errorf("failed to remove run %s: %w", session.RunID, err)
+		}
+	}
+	return removed, nil
+}
+
+// decode decrypts, if needed, and unmarshals a stored run.
+func (s *SQLiteStore) decode(data []byte) (*RunResult, error) {
+	key := s.key
+	if key == nil && crypt.IsEncrypted(data) {
+		var err error
+		if key, err = crypt.EnvKey(); err != nil {
+			return nil, err
+		}
+	}
+	data, err := crypt.Decrypt(key, data)
+	if err != nil {
+		return nil, err
+	}
+	var run RunResult
+	if err := json.Unmarshal(data, &run); err != nil {
+		return nil, err
+	}
+	return &run, nil
+}
@@ -0,0 +1,127 @@
+package state
+
+import (
+	"sort"
+	"time"
+)
+
+// AgentStats summarizes how an agent fared across stored runs, to help
+// decide which tool and model to trust with critical tasks.
+type AgentStats struct {
+	Agent string `json:"agent"`
+	Tool  string `json:"tool"`
+	Model string `json:"model,omitempty"`
+
+	Tasks       int `json:"tasks"`        // Task results, not counting skipped and cancelled tasks
+	Failures    int `json:"failures"`     // Tasks that failed after their retries
+	Retries     int `json:"retries"`      // Runs of the agent beyond each task's first
+	RateLimits  int `json:"rate_limits"`  // Attempts that hit a provider rate limit
+	Timeouts    int `json:"timeouts"`     // Attempts that ran past the task's timeout
+	FlakyEvents int `json:"flaky_events"` // Tasks that passed only on retry, or changed outcome since their last run
+
+	Errors   map[ErrorCategory]int `json:"errors,omitempty"` // Failures by category
+	LastSeen time.Time             `json:"last_seen"`        // Start of the newest task result
+}
+
+// FailureRate is the share of the agent's tasks that failed.
+func (s *AgentStats) FailureRate() float64 {
+	if s.Tasks == 0 {
+		return 0
+	}
+	return float64(s.Failures) / float64(s.Tasks)
+}
+
+// Flakiness is the share of the agent's tasks whose outcome wasn't
+// repeatable: they passed only on retry, or passed where the same task
+// failed on its previous run with the agent, or the other way around. An
+// agent that always fails a task isn't flaky, just failing.
+func (s *AgentStats) Flakiness() float64 {
+	if s.Tasks == 0 {
+		return 0
+	}
+	return float64(s.FlakyEvents) / float64(s.Tasks)
+}
+
+// ComputeAgentStats aggregates task records, newest first as Query returns
+// them, into one AgentStats per agent, tool, and model, sorted by agent.
+func ComputeAgentStats(records []TaskRecord) []AgentStats {
+	type key struct{ agent, tool, model string }
+	type taskKey struct {
+		key
+		project, task string
+	}
+	byAgent := make(map[key]*AgentStats)
+	newer := make(map[taskKey]bool) // Outcome of the next newer run of each task
+
+	for _, r := range records {
+		if r.Skipped != "" || r.ErrorCategory == ErrorCancelled {
+			continue
+		}
+		k := key{r.Agent, r.Tool, r.Model}
+		s := byAgent[k]
+		if s == nil {
+			s = &AgentStats{Agent: r.Agent, Tool: r.Tool, Model: r.Model, Errors: make(map[ErrorCategory]int)}
+			byAgent[k] = s
+		}
+		s.Tasks++
+		if r.StartTime.After(s.LastSeen) {
+			s.LastSeen = r.StartTime
+		}
+		if !r.Success {
+			s.Failures++
+			if r.ErrorCategory != "" {
+				s.Errors[r.ErrorCategory]++
+			}
+		}
+
+		failedAttempt := false
+		if len(r.Attempts) > 0 {
+			s.Retries += len(r.Attempts) - 1
+			for _, a := range r.Attempts {
+				s.count(a.ErrorCategory)
+				failedAttempt = failedAttempt || !a.Success
+			}
+		} else if !r.Success {
+			s.count(r.ErrorCategory)
+		}
+
+		tk := taskKey{k, r.Project, r.TaskName}
+		flipped := false
+		if success, ok := newer[tk]; ok {
+			flipped = success != r.Success
+		}
+		newer[tk] = r.Success
+		if flipped || r.Success && failedAttempt {
+			s.FlakyEvents++
+		}
+	}
+
+	stats := make([]AgentStats, 0, len(byAgent))
+	for _, s := range byAgent {
+		if len(s.Errors) == 0 {
+			s.Errors = nil
+		}
+		stats = append(stats, *s)
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		a, b := stats[i], stats[j]
+		if a.Agent != b.Agent {
+			return a.Agent < b.Agent
+		}
+		if a.Tool != b.Tool {
+			return a.Tool < b.Tool
+		}
+		return a.Model < b.Model
+	})
+	return stats
+}
+
+// count records an attempt's rate limit or timeout.
+func (s *AgentStats) count(category ErrorCategory) {
+	switch category {
+	case ErrorRateLimit:
+		s.RateLimits++
+	case ErrorTimeout:
+		s.Timeouts++
+	}
+}
@@ -0,0 +1,110 @@
+package state
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestComputeAgentStats(t *testing.T) {
+	start := time.Date(2026, 3, 10, 9, 0, 0, 0, time.UTC)
+	// record is a run of task on the "coder" agent, days after start;
+	// attempts lists the outcome of each attempt, if the task had retries.
+	record := func(task string, days int, success bool, category ErrorCategory, attempts ...Attempt) TaskRecord {
+		return TaskRecord{Project: "api", RunID: task, TaskResult: TaskResult{
+			TaskName: task, Agent: "coder", Tool: "claude-code", Model: "sonnet",
+			Success: success, ErrorCategory: category, Attempts: attempts,
+			StartTime: start.AddDate(0, 0, days),
+		}}
+	}
+	passed := Attempt{Success: true}
+	failed := func(category ErrorCategory) Attempt { return Attempt{ErrorCategory: category} }
+
+	tests := []struct {
+		name    string
+		records []TaskRecord // Newest first, as Query returns them
+		want    AgentStats
+	}{
+		{
+			name:    "pass after retry",
+			records: []TaskRecord{record("build", 0, true, "", failed(ErrorFailed), passed)},
+			want:    AgentStats{Tasks: 1, Retries: 1, FlakyEvents: 1},
+		},
+		{
+			name: "flip-flop is flaky",
+			records: []TaskRecord{
+				record("build", 3, true, ""),
+				record("build", 2, false, ErrorFailed),
+				record("build", 1, true, ""),
+			},
+			want: AgentStats{Tasks: 3, Failures: 1, FlakyEvents: 2, Errors: map[ErrorCategory]int{ErrorFailed: 1}},
+		},
+		{
+			name: "always failing isn't flaky",
+			records: []TaskRecord{
+				record("build", 2, false, ErrorFailed, failed(ErrorFailed), failed(ErrorFailed)),
+				record("build", 1, false, ErrorFailed, failed(ErrorFailed), failed(ErrorFailed)),
+			},
+			want: AgentStats{Tasks: 2, Failures: 2, Retries: 2, Errors: map[ErrorCategory]int{ErrorFailed: 2}},
+		},
+		{
+			name: "rate limits and timeouts",
+			records: []TaskRecord{
+				record("build", 1, true, "", failed(ErrorRateLimit), failed(ErrorRateLimit), passed),
+				record("lint", 0, false, ErrorTimeout),
+			},
+			want: AgentStats{Tasks: 2, Failures: 1, Retries: 2, RateLimits: 2, Timeouts: 1, FlakyEvents: 1,
+				Errors: map[ErrorCategory]int{ErrorTimeout: 1}},
+		},
+		{
+			name: "skipped and cancelled tasks don't count",
+			records: []TaskRecord{
+				record("build", 2, false, ErrorCancelled),
+				func() TaskRecord { r := record("build", 1, false, ""); r.Skipped = "condition is false"; return r }(),
+				record("build", 0, true, ""),
+			},
+			want: AgentStats{Tasks: 1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stats := ComputeAgentStats(tt.records)
+			if len(stats) != 1 {
+				t.Fatalf("got stats for %d agents, want 1", len(stats))
+			}
+			got := stats[0]
+			want := tt.want
+			want.Agent, want.Tool, want.Model = "coder", "claude-code", "sonnet"
+			want.LastSeen = got.LastSeen
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("ComputeAgentStats() =\n%+v\nwant\n%+v", got, want)
+			}
+		})
+	}
+}
+
+// TestComputeAgentStats_PerModel tests that runs are grouped by agent,
+// tool, and model, sorted by agent, with the newest start as LastSeen.
+func TestComputeAgentStats_PerModel(t *testing.T) {
+	start := time.Date(2026, 3, 10, 9, 0, 0, 0, time.UTC)
+	records := []TaskRecord{
+		{TaskResult: TaskResult{TaskName: "a", Agent: "reviewer", Tool: "opencode", Success: true, StartTime: start}},
+		{TaskResult: TaskResult{TaskName: "a", Agent: "coder", Tool: "claude-code", Model: "opus", Success: true, StartTime: start.Add(time.Hour)}},
+		{TaskResult: TaskResult{TaskName: "a", Agent: "coder", Tool: "claude-code", Model: "sonnet", Success: false, StartTime: start}},
+		{TaskResult: TaskResult{TaskName: "b", Agent: "coder", Tool: "claude-code", Model: "opus", Success: true, StartTime: start}},
+	}
+	stats := ComputeAgentStats(records)
+	var got []string
+	for _, s := range stats {
+		got = append(got, s.Agent+"/"+s.Model)
+	}
+	if want := []string{"coder/opus", "coder/sonnet", "reviewer/"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("agents = %v, want %v", got, want)
+	}
+	if opus := stats[0]; opus.Tasks != 2 || !opus.LastSeen.Equal(start.Add(time.Hour)) {
+		t.Errorf("coder/opus = %+v", opus)
+	}
+	if sonnet := stats[1]; sonnet.FailureRate() != 1 || sonnet.Flakiness() != 0 {
+		t.Errorf("coder/sonnet failure rate %v, flakiness %v", sonnet.FailureRate(), sonnet.Flakiness())
+	}
+}
@@ -6,19 +6,29 @@ import (
 	"os"
 	"path/filepath"
 	"time"
+
+	"github.com/adityaraj/agentflow/internal/config"
+	"github.com/adityaraj/agentflow/internal/crypt"
+	"github.com/adityaraj/agentflow/internal/secrets"
 )
 
-// Store handles persistence of run results to disk.
-type Store struct {
+// RunStore handles persistence of the current run: its directory of task
+// results and run files, and its run record, which it saves to a Store.
+type RunStore struct {
 	baseDir    string // Base directory (~/.agentflow)
 	runID      string // Current run ID (timestamp-based)
 	runDir     string // Full path to current run directory
 	projectDir string // Project directory where agentflow was run
+
+	retention *config.RetentionConfig // What is kept of task results (nil = everything)
+	secrets   *secrets.Redactor       // Masks secrets in saved task results (nil = none)
+	key       []byte                  // Encrypts the files the store writes (nil = plaintext)
+	backend   Store                   // Keeps run records (nil = JSON files in baseDir)
 }
 
-// NewStore creates a new Store using ~/.cortex as the base directory.
+// NewRunStore creates a new RunStore using ~/.cortex as the base directory.
 // Creates ~/.cortex/sessions/<project-name>/ structure if it doesn't exist.
-func NewStore(projectDir string) (*Store, error) {
+func NewRunStore(projectDir string) (*RunStore, error) {
 	homeDir, err := os.UserHomeDir()
 	if err != nil {
 		return nil, fmt.Errorf("failed to get home directory: %w", err)
@@ -36,7 +46,7 @@ func NewStore(projectDir string) (*Store, error) {
 		return nil, fmt.Errorf("failed to create run directory: %w", err)
 	}
 
-	return &Store{
+	return &RunStore{
 		baseDir:    baseDir,
 		runID:      runID,
 		runDir:     runDir,
@@ -44,8 +54,8 @@ func NewStore(projectDir string) (*Store, error) {
 	}, nil
 }
 
-// NewStoreWithPath creates a Store with a custom base path (for testing).
-func NewStoreWithPath(basePath, projectDir string) (*Store, error) {
+// NewRunStoreWithPath creates a RunStore with a custom base path (for testing).
+func NewRunStoreWithPath(basePath, projectDir string) (*RunStore, error) {
 	runID := time.Now().Format("20060102-150405")
 	projectName := filepath.Base(projectDir)
 	sessionsDir := filepath.Join(basePath, "sessions", projectName)
@@ -55,7 +65,7 @@ func NewStoreWithPath(basePath, projectDir string) (*Store, error) {
 		return nil, fmt.Errorf("failed to create run directory: %w", err)
 	}
 
-	return &Store{
+	return &RunStore{
 		baseDir:    basePath,
 		runID:      runID,
 		runDir:     runDir,
@@ -63,53 +73,106 @@ func NewStoreWithPath(basePath, projectDir string) (*Store, error) {
 	}, nil
 }
 
-// SaveTaskResult saves a task result to disk as JSON.
-func (s *Store) SaveTaskResult(result *TaskResult) error {
+// SaveTaskResult saves a task result to disk as JSON, filling in
+// ErrorCode from ErrorCategory.
+func (s *RunStore) SaveTaskResult(result *TaskResult) error {
+	result.ErrorCode = result.ErrorCategory.Code()
 	filename := filepath.Join(s.runDir, result.TaskName+".json")
 
-	data, err := json.MarshalIndent(result, "", "  ")
+	data, err := json.MarshalIndent(s.retainTask(result), "", "  ")
 	if err != nil {
 		return fmt.Errorf("failed to marshal result: %w", err)
 	}
 
-	if err := os.WriteFile(filename, data, 0644); err != nil {
+	if err := s.writeFile(filename, data); err != nil {
 		return fmt.Errorf("failed to write result file: %w", err)
 	}
 
 	return nil
 }
 
-// SaveRunResult saves the complete run result to disk.
-func (s *Store) SaveRunResult(result *RunResult) error {
-	filename := filepath.Join(s.runDir, "run.json")
-
-	data, err := json.MarshalIndent(result, "", "  ")
-	if err != nil {
-		return fmt.Errorf("failed to marshal run result: %w", err)
+// SaveRunResult saves the complete run result to the store's backend.
+func (s *RunStore) SaveRunResult(result *RunResult) error {
+	if err := s.Backend().Save(s.Project(), s.Retained(result)); err != nil {
+		return fmt.Errorf("failed to save run result: %w", err)
 	}
+	return nil
+}
+
+// SetBackend makes the store save run records to backend instead of JSON
+// files in its base directory.
+func (s *RunStore) SetBackend(backend Store) {
+	s.backend = backend
+}
 
-	if err := os.WriteFile(filename, data, 0644); err != nil {
-		return fmt.Errorf("failed to write run result: %w", err)
+// Backend returns the Store run records are saved to.
+func (s *RunStore) Backend() Store {
+	if s.backend != nil {
+		return s.backend
 	}
+	return NewJSONStore(s.baseDir, s.key)
+}
 
-	return nil
+// Project returns the name runs of this project are stored under.
+func (s *RunStore) Project() string {
+	return filepath.Base(filepath.Dir(s.runDir))
+}
+
+// SetKey makes the store encrypt the files it writes with key (see the
+// crypt package); nil writes plaintext. Encrypted files are decrypted
+// transparently when read, given the key in crypt.KeyEnv.
+func (s *RunStore) SetKey(key []byte) {
+	s.key = key
+}
+
+// Encrypted reports whether the store encrypts the files it writes.
+func (s *RunStore) Encrypted() bool {
+	return s.key != nil
+}
+
+// WriteRunFile saves data as name in the run directory, encrypted if the
+// store encrypts its files.
+func (s *RunStore) WriteRunFile(name string, data []byte) error {
+	return s.writeFile(filepath.Join(s.runDir, name), data)
+}
+
+// writeFile writes data to path, encrypting it if the store has a key.
+func (s *RunStore) writeFile(path string, data []byte) error {
+	return writeFile(path, data, s.key)
+}
+
+// writeFile writes data to path, encrypting it with key unless it's nil.
+func writeFile(path string, data []byte, key []byte) error {
+	if key != nil {
+		encrypted, err := crypt.Encrypt(key, data)
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(path, encrypted, 0600)
+	}
+	return os.WriteFile(path, data, 0644)
 }
 
 // RunDir returns the path to the current run directory.
-func (s *Store) RunDir() string {
+func (s *RunStore) RunDir() string {
 	return s.runDir
 }
 
 // RunID returns the current run ID.
-func (s *Store) RunID() string {
+func (s *RunStore) RunID() string {
 	return s.runID
 }
 
 // LoadTaskResult loads a task result from disk.
-func (s *Store) LoadTaskResult(taskName string) (*TaskResult, error) {
-	filename := filepath.Join(s.runDir, taskName+".json")
+func (s *RunStore) LoadTaskResult(taskName string) (*TaskResult, error) {
+	return ReadTaskResult(s.runDir, taskName)
+}
 
-	data, err := os.ReadFile(filename)
+// ReadTaskResult loads the result of a task of the run in runDir.
+func ReadTaskResult(runDir, taskName string) (*TaskResult, error) {
+	filename := filepath.Join(runDir, taskName+".json")
+
+	data, err := crypt.ReadFile(filename)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read result file: %w", err)
 	}
@@ -121,3 +184,25 @@ func (s *Store) LoadTaskResult(taskName string) (*TaskResult, error) {
 
 	return &result, nil
 }
+
+// LastSuccessfulRun returns the most recent earlier run of this project
+// that succeeded, or nil if there is none.
+func (s *RunStore) LastSuccessfulRun() (*RunResult, error) {
+	backend := s.Backend()
+	sessions, err := backend.List(SessionFilter{Project: s.Project()})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list runs: %w", err)
+	}
+
+	for _, session := range sessions { // Newest first
+		if !session.Success || session.RunID == s.runID {
+			continue
+		}
+		run, err := backend.Load(session.Project, session.RunID)
+		if err != nil {
+			continue
+		}
+		return run, nil
+	}
+	return nil, nil
+}
@@ -0,0 +1,48 @@
+package state
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/adityaraj/agentflow/internal/config"
+)
+
+// LogsDir is the run directory's subdirectory of task output logs.
+const LogsDir = "logs"
+
+// RunDirPath returns the directory of a run under a Cortex home, e.g.
+// ~/.cortex/sessions/<project>/run-<id>. Run directories are there
+// whichever Store keeps the run records.
+func RunDirPath(baseDir, project, runID string) string {
+	return filepath.Join(ProjectDirPath(baseDir, project), "run-"+runID)
+}
+
+// ProjectDirPath returns the directory holding a project's run
+// directories under a Cortex home, e.g. ~/.cortex/sessions/<project>.
+func ProjectDirPath(baseDir, project string) string {
+	return filepath.Join(baseDir, "sessions", project)
+}
+
+// TaskLogPath returns the file the output of a task in the run in runDir
+// is copied to as it runs.
+func TaskLogPath(runDir, task string) string {
+	return filepath.Join(runDir, LogsDir, task+".log")
+}
+
+// CreateTaskLog creates the log of task's output, for following a task as
+// it runs (see `cortex serve`). Returns nil if the store doesn't keep such
+// logs: when it encrypts its files, or retention redacts stdout or stderr.
+func (s *RunStore) CreateTaskLog(task string) (*os.File, error) {
+	if s.key != nil {
+		return nil, nil
+	}
+	if r := s.retention; r != nil && (slices.Contains(r.Redact, config.RedactStdout) || slices.Contains(r.Redact, config.RedactStderr)) {
+		return nil, nil
+	}
+	path := TaskLogPath(s.runDir, task)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	return os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+}
@@ -0,0 +1,8 @@
+//go:build !linux && !darwin && !freebsd && !windows
+
+package sysload
+
+// DiskFree is unsupported on this platform.
+func DiskFree(path string) (uint64, error) {
+	return 0, ErrUnsupported
+}
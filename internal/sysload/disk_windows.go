@@ -0,0 +1,17 @@
+package sysload
+
+import "golang.org/x/sys/windows"
+
+// DiskFree returns the bytes available to the current user on the volume
+// holding path.
+func DiskFree(path string) (uint64, error) {
+	p, err := windows.UTF16PtrFromString(path)
+	if err != nil {
+		return 0, err
+	}
+	var available, total, free uint64
+	if err := windows.GetDiskFreeSpaceEx(p, &available, &total, &free); err != nil {
+		return 0, err
+	}
+	return available, nil
+}
@@ -0,0 +1,90 @@
+// Package sysload reports how busy the machine is and how much disk space
+// is left, so work can be held back while other processes (or earlier
+// tasks) have the CPU or memory saturated, or before a disk fills up.
+package sysload
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrUnsupported is returned where system load can't be measured.
+var ErrUnsupported = errors.New("system load is not available on this platform")
+
+// Load is a snapshot of system-wide resource use.
+type Load struct {
+	CPU    float64 // Percent of total CPU capacity in use
+	Memory float64 // Percent of physical memory in use
+}
+
+// cpuWindow is how long Sample measures CPU use over when it has no recent
+// reading to compare against.
+const cpuWindow = 250 * time.Millisecond
+
+// cpuTimes is a reading of cumulative CPU time, in arbitrary ticks.
+type cpuTimes struct {
+	busy, total uint64
+}
+
+// Sampler measures system load. CPU use is measured between successive
+// samples, so a Sampler should be reused.
+type Sampler struct {
+	mu   sync.Mutex
+	last cpuTimes
+	at   time.Time
+}
+
+// NewSampler creates a Sampler.
+func NewSampler() *Sampler {
+	return &Sampler{}
+}
+
+// Sample returns the current load. CPU use is averaged since the previous
+// sample, or over a short window if that was long ago.
+func (s *Sampler) Sample() (Load, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	mem, err := memoryUsed()
+	if err != nil {
+		return Load{}, err
+	}
+	cpu, err := s.cpuUsed()
+	if err != nil {
+		return Load{}, err
+	}
+	return Load{CPU: cpu, Memory: mem}, nil
+}
+
+// cpuUsed returns the percent of CPU time spent busy since the last reading.
+func (s *Sampler) cpuUsed() (float64, error) {
+	// Platforms without cumulative CPU times report load directly
+	if pct, ok, err := cpuLoad(); ok || err != nil {
+		return pct, err
+	}
+
+	if s.at.IsZero() || time.Since(s.at) > time.Minute {
+		times, err := readCPUTimes()
+		if err != nil {
+			return 0, err
+		}
+		s.last, s.at = times, time.Now()
+		time.Sleep(cpuWindow)
+	}
+	times, err := readCPUTimes()
+	if err != nil {
+		return 0, err
+	}
+	pct := busyPercent(s.last, times)
+	s.last, s.at = times, time.Now()
+	return pct, nil
+}
+
+// busyPercent returns the percent of time spent busy between two readings.
+func busyPercent(before, after cpuTimes) float64 {
+	if after.total <= before.total || after.busy < before.busy {
+		return 0
+	}
+	return 100 * float64(after.busy-before.busy) / float64(after.total-before.total)
+}
@@ -0,0 +1,44 @@
+package sysload
+
+import (
+	"encoding/binary"
+	"fmt"
+	goruntime "runtime"
+
+	"golang.org/x/sys/unix"
+)
+
+// cpuLoad approximates CPU use on macOS from the one-minute load average:
+// runnable processes per CPU, capped at 100%.
+func cpuLoad() (float64, bool, error) {
+	raw, err := unix.SysctlRaw("vm.loadavg")
+	if err != nil {
+		return 0, true, err
+	}
+	// struct loadavg { fixpt_t ldavg[3]; long fscale; }
+	if len(raw) < 24 {
+		return 0, true, fmt.Errorf("unexpected vm.loadavg size %d", len(raw))
+	}
+	load := binary.LittleEndian.Uint32(raw[0:4])
+	scale := binary.LittleEndian.Uint64(raw[16:24])
+	if scale == 0 {
+		return 0, true, fmt.Errorf("unexpected vm.loadavg scale")
+	}
+	pct := 100 * float64(load) / float64(scale) / float64(goruntime.NumCPU())
+	return min(pct, 100), true, nil
+}
+
+// readCPUTimes is unused on macOS: cpuLoad reports load directly.
+func readCPUTimes() (cpuTimes, error) {
+	return cpuTimes{}, ErrUnsupported
+}
+
+// memoryUsed returns the percent of memory in use, from the memory pressure
+// level the kernel reports (the percent of memory available).
+func memoryUsed() (float64, error) {
+	level, err := unix.SysctlUint32("kern.memorystatus_level")
+	if err != nil {
+		return 0, err
+	}
+	return 100 - float64(min(level, 100)), nil
+}
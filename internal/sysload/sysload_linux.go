@@ -0,0 +1,87 @@
+package sysload
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// cpuLoad is unused on Linux: CPU times come from /proc/stat.
+func cpuLoad() (float64, bool, error) {
+	return 0, false, nil
+}
+
+// readCPUTimes reads the aggregate CPU times from /proc/stat.
+func readCPUTimes() (cpuTimes, error) {
+	data, err := os.ReadFile("/proc/stat")
+	if err != nil {
+		return cpuTimes{}, err
+	}
+	return parseProcStat(data)
+}
+
+// parseProcStat parses the "cpu" line of /proc/stat: user nice system idle
+// iowait irq softirq steal, in clock ticks.
+func parseProcStat(data []byte) (cpuTimes, error) {
+	line, _, _ := bytes.Cut(data, []byte("\n"))
+	fields := strings.Fields(string(line))
+	if len(fields) < 5 || fields[0] != "cpu" {
+		return cpuTimes{}, fmt.Errorf("unexpected /proc/stat format")
+	}
+	var times cpuTimes
+	for i, f := range fields[1:] {
+		if i >= 8 { // guest times are already counted in user and nice
+			break
+		}
+		n, err := strconv.ParseUint(f, 10, 64)
+		if err != nil {
+			return cpuTimes{}, fmt.Errorf("unexpected /proc/stat format: %w", err)
+		}
+		times.total += n
+		if i != 3 && i != 4 { // idle and iowait
+			times.busy += n
+		}
+	}
+	return times, nil
+}
+
+// memoryUsed returns the percent of memory in use from /proc/meminfo,
+// counting reclaimable caches as available.
+func memoryUsed() (float64, error) {
+	f, err := os.Open("/proc/meminfo")
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	return parseMeminfo(f)
+}
+
+// parseMeminfo returns the percent of memory in use from the contents of
+// /proc/meminfo.
+func parseMeminfo(r io.Reader) (float64, error) {
+	var total, available uint64
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+		switch fields[0] {
+		case "MemTotal:":
+			total, _ = strconv.ParseUint(fields[1], 10, 64)
+		case "MemAvailable:":
+			available, _ = strconv.ParseUint(fields[1], 10, 64)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, err
+	}
+	if total == 0 || available > total {
+		return 0, fmt.Errorf("unexpected /proc/meminfo format")
+	}
+	return 100 * float64(total-available) / float64(total), nil
+}
@@ -0,0 +1,54 @@
+package sysload
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseProcStat(t *testing.T) {
+	stat := "cpu  100 10 50 800 20 5 5 10 7 0\ncpu0 50 5 25 400 10 2 3 5 0 0\n"
+	times, err := parseProcStat([]byte(stat))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Busy: user+nice+system+irq+softirq+steal; guest isn't counted twice
+	if times.busy != 180 || times.total != 1000 {
+		t.Errorf("times = %+v, want busy 180 of 1000", times)
+	}
+
+	if _, err := parseProcStat([]byte("intr 1 2 3\n")); err == nil {
+		t.Error("expected an error for a stat without a cpu line")
+	}
+}
+
+func TestBusyPercent(t *testing.T) {
+	before := cpuTimes{busy: 100, total: 1000}
+	after := cpuTimes{busy: 175, total: 1100}
+	if got := busyPercent(before, after); got != 75 {
+		t.Errorf("busyPercent = %v, want 75", got)
+	}
+	if got := busyPercent(after, after); got != 0 {
+		t.Errorf("busyPercent with no elapsed time = %v, want 0", got)
+	}
+}
+
+func TestParseMeminfo(t *testing.T) {
+	meminfo := "MemTotal:       16000000 kB\nMemFree:         1000000 kB\nMemAvailable:    4000000 kB\n"
+	got, err := parseMeminfo(strings.NewReader(meminfo))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != 75 {
+		t.Errorf("parseMeminfo = %v, want 75", got)
+	}
+}
+
+func TestSampler(t *testing.T) {
+	load, err := NewSampler().Sample()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if load.CPU < 0 || load.CPU > 100 || load.Memory <= 0 || load.Memory > 100 {
+		t.Errorf("Sample() = %+v, want percentages", load)
+	}
+}
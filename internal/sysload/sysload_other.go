@@ -0,0 +1,15 @@
+//go:build !linux && !darwin && !windows
+
+package sysload
+
+func cpuLoad() (float64, bool, error) {
+	return 0, true, ErrUnsupported
+}
+
+func readCPUTimes() (cpuTimes, error) {
+	return cpuTimes{}, ErrUnsupported
+}
+
+func memoryUsed() (float64, error) {
+	return 0, ErrUnsupported
+}
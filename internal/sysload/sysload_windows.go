@@ -0,0 +1,60 @@
+package sysload
+
+import (
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+var (
+	kernel32                 = windows.NewLazySystemDLL("kernel32.dll")
+	procGetSystemTimes       = kernel32.NewProc("GetSystemTimes")
+	procGlobalMemoryStatusEx = kernel32.NewProc("GlobalMemoryStatusEx")
+)
+
+// memoryStatusEx is the MEMORYSTATUSEX structure.
+type memoryStatusEx struct {
+	Length               uint32
+	MemoryLoad           uint32 // Percent of physical memory in use
+	TotalPhys            uint64
+	AvailPhys            uint64
+	TotalPageFile        uint64
+	AvailPageFile        uint64
+	TotalVirtual         uint64
+	AvailVirtual         uint64
+	AvailExtendedVirtual uint64
+}
+
+// cpuLoad is unused on Windows: CPU times come from GetSystemTimes.
+func cpuLoad() (float64, bool, error) {
+	return 0, false, nil
+}
+
+// readCPUTimes reads the system's idle, kernel, and user times. Kernel time
+// includes idle time.
+func readCPUTimes() (cpuTimes, error) {
+	var idle, kernel, user windows.Filetime
+	r, _, err := procGetSystemTimes.Call(
+		uintptr(unsafe.Pointer(&idle)),
+		uintptr(unsafe.Pointer(&kernel)),
+		uintptr(unsafe.Pointer(&user)))
+	if r == 0 {
+		return cpuTimes{}, err
+	}
+	ticks := func(ft windows.Filetime) uint64 {
+		return uint64(ft.HighDateTime)<<32 | uint64(ft.LowDateTime)
+	}
+	total := ticks(kernel) + ticks(user)
+	return cpuTimes{busy: total - ticks(idle), total: total}, nil
+}
+
+// memoryUsed returns the percent of physical memory in use.
+func memoryUsed() (float64, error) {
+	status := memoryStatusEx{}
+	status.Length = uint32(unsafe.Sizeof(status))
+	r, _, err := procGlobalMemoryStatusEx.Call(uintptr(unsafe.Pointer(&status)))
+	if r == 0 {
+		return 0, err
+	}
+	return float64(status.MemoryLoad), nil
+}
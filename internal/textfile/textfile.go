@@ -0,0 +1,74 @@
+// Package textfile reads files that are quoted in prompts, refusing binary
+// files, which would corrupt a prompt, and files too large to be worth the
+// tokens they'd use.
+package textfile
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"unicode/utf8"
+)
+
+// sniffLen is how much of a file IsBinary looks at, as git does.
+const sniffLen = 8000
+
+var (
+	// ErrBinary is returned for files that aren't text.
+	ErrBinary = errors.New("binary file")
+
+	// ErrTooLarge is returned for files over the size limit.
+	ErrTooLarge = errors.New("file too large")
+)
+
+// IsBinary reports whether data looks like a binary file's content: its
+// start has a NUL byte or isn't valid UTF-8.
+func IsBinary(data []byte) bool {
+	head := data[:min(len(data), sniffLen)]
+	if bytes.IndexByte(head, 0) >= 0 {
+		return true
+	}
+	if len(head) < len(data) {
+		// The cut may split the last character
+		for i := 1; i < utf8.UTFMax && !utf8.Valid(head); i++ {
+			head = head[:len(head)-1]
+		}
+	}
+	return !utf8.Valid(head)
+}
+
+// Read returns the content of the text file at path, failing with an
+// error wrapping ErrBinary or ErrTooLarge if it's binary or larger than
+// limit bytes.
+func Read(path string, limit int64) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	data, err := io.ReadAll(io.LimitReader(f, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > limit {
+		return nil, fmt.Errorf("%s is larger than %s: %w", path, formatSize(limit), ErrTooLarge)
+	}
+	if IsBinary(data) {
+		return nil, fmt.Errorf("%s: %w", path, ErrBinary)
+	}
+	return data, nil
+}
+
+// formatSize renders a byte count like "256 KiB" or "8 MiB".
+func formatSize(n int64) string {
+	switch {
+	case n >= 1<<20 && n%(1<<20) == 0:
+		return fmt.Sprintf("%d MiB", n>>20)
+	case n >= 1<<10 && n%(1<<10) == 0:
+		return fmt.Sprintf("%d KiB", n>>10)
+	}
+	return fmt.Sprintf("%d bytes", n)
+}
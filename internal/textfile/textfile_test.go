@@ -0,0 +1,42 @@
+package textfile
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestIsBinary(t *testing.T) {
+	for text, want := range map[string]bool{
+		"":                      false,
+		"package main\n":        false,
+		"héllo wörld":           false,
+		"\x89PNG\r\n\x1a\n\x00": true,
+		"caf\xe9":               true, // Latin-1, not UTF-8
+		strings.Repeat("a", sniffLen-1) + "é" + "\x00": false, // Split character, NUL past the sniffed start
+	} {
+		if got := IsBinary([]byte(text)); got != want {
+			t.Errorf("IsBinary(%.20q) = %v, want %v", text, got, want)
+		}
+	}
+}
+
+func TestRead(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{"a.md": "# Notes\n", "logo.png": "\x89PNG\x00", "big.txt": strings.Repeat("x", 100)} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if data, err := Read(filepath.Join(dir, "a.md"), 64); err != nil || string(data) != "# Notes\n" {
+		t.Errorf("Read(a.md) = %q, %v", data, err)
+	}
+	if _, err := Read(filepath.Join(dir, "logo.png"), 64); !errors.Is(err, ErrBinary) {
+		t.Errorf("Read(logo.png) error = %v, want ErrBinary", err)
+	}
+	if _, err := Read(filepath.Join(dir, "big.txt"), 64); !errors.Is(err, ErrTooLarge) || !strings.Contains(err.Error(), "larger than 64 bytes") {
+		t.Errorf("Read(big.txt) error = %v, want ErrTooLarge", err)
+	}
+}
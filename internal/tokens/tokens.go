@@ -0,0 +1,289 @@
+// Package tokens counts how many tokens a model's tokenizer splits text
+// into, for token budgets, prompt budgets, and usage of tools that don't
+// report it.
+//
+// OpenAI's tokenizers are public: text for their models is encoded with
+// BPE over the o200k or cl100k vocabulary, built in, giving the counts
+// tiktoken gives. Other tokenizers, like Claude's, aren't public, so their
+// counts are estimated: text is cut into words, runs of up to three digits,
+// punctuation, and whitespace, as BPE tokenizers pre-split it, and each
+// piece is charged an average characters-per-token rate of the family.
+// Estimates can be well off for non-English text and minified or unusual
+// code.
+package tokens
+
+import (
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/pkoukk/tiktoken-go"
+	tiktokenloader "github.com/pkoukk/tiktoken-go-loader"
+)
+
+func init() {
+	// Vocabularies are built in rather than downloaded on first use
+	tiktoken.SetBpeLoader(tiktokenloader.NewOfflineLoader())
+}
+
+// Tokenizer counts the tokens of text for one tokenizer family.
+type Tokenizer struct {
+	// Name identifies the family, e.g. "o200k" or "claude".
+	Name string
+
+	// encoding is the tiktoken encoding that counts the family's tokens
+	// exactly ("" = estimate them with the rates below).
+	encoding string
+
+	once sync.Once
+	bpe  *tiktoken.Tiktoken // Loaded on first use; nil if it can't be
+
+	// wordChars is how many letters of a word one token covers on average
+	// (a word's first token, with its leading space, covers up to twice
+	// as many).
+	wordChars float64
+
+	// symbolChars is how many punctuation characters one token covers.
+	symbolChars float64
+
+	// spaceChars is how many spaces of indentation one token covers.
+	spaceChars int
+}
+
+// Tokenizer families. The rates of OpenAI's are only used if their
+// vocabulary can't be loaded. Claude's tokenizer isn't public; its rates
+// are those of a smaller BPE vocabulary, which it behaves like.
+var (
+	// O200k is the tokenizer of OpenAI's GPT-4o, GPT-4.1, GPT-5, and
+	// o-series models.
+	O200k = &Tokenizer{Name: "o200k", encoding: tiktoken.MODEL_O200K_BASE, wordChars: 4.6, symbolChars: 2, spaceChars: 16}
+
+	// Cl100k is the tokenizer of GPT-4 and GPT-3.5.
+	Cl100k = &Tokenizer{Name: "cl100k", encoding: tiktoken.MODEL_CL100K_BASE, wordChars: 4.2, symbolChars: 2, spaceChars: 16}
+
+	// Claude approximates the tokenizer of Anthropic's models, and is the
+	// default.
+	Claude = &Tokenizer{Name: "claude", wordChars: 3.6, symbolChars: 1.6, spaceChars: 8}
+)
+
+// ForModel returns the tokenizer of a model, as written in an agent's
+// model field ("sonnet", "gpt-4o", "openai/o3", ...). Models of unknown
+// families get Claude, the default tool's.
+func ForModel(model string) *Tokenizer {
+	model = strings.ToLower(model)
+	if i := strings.LastIndexByte(model, '/'); i >= 0 {
+		model = model[i+1:] // Provider prefix, e.g. openai/gpt-4o
+	}
+	switch {
+	case strings.HasPrefix(model, "gpt-4o"), strings.HasPrefix(model, "gpt-4.1"),
+		strings.HasPrefix(model, "gpt-5"), strings.HasPrefix(model, "chatgpt"),
+		len(model) >= 2 && model[0] == 'o' && model[1] >= '1' && model[1] <= '9':
+		return O200k
+	case strings.HasPrefix(model, "gpt-"):
+		return Cl100k
+	}
+	return Claude
+}
+
+// Count estimates the tokens of text with the default tokenizer, for text
+// not bound to a model.
+func Count(text string) int {
+	return Claude.Count(text)
+}
+
+// Count returns the tokens of text: exactly for families with a built-in
+// vocabulary, estimated for the others (see Exact).
+func (t *Tokenizer) Count(text string) int {
+	if bpe := t.vocabulary(); bpe != nil {
+		return len(bpe.EncodeOrdinary(text))
+	}
+	return t.estimate(text)
+}
+
+// Exact reports whether Count encodes text with the family's own
+// vocabulary rather than estimating.
+func (t *Tokenizer) Exact() bool {
+	return t.vocabulary() != nil
+}
+
+// vocabulary returns the family's BPE encoder, loading it the first time,
+// or nil if it has none.
+func (t *Tokenizer) vocabulary() *tiktoken.Tiktoken {
+	if t.encoding == "" {
+		return nil
+	}
+	t.once.Do(func() {
+		t.bpe, _ = tiktoken.GetEncoding(t.encoding) // Fall back to estimates
+	})
+	return t.bpe
+}
+
+// estimate estimates the tokens of text from the family's rates.
+func (t *Tokenizer) estimate(text string) int {
+	n := 0
+	for text != "" {
+		piece, kind := nextPiece(text)
+		text = text[len(piece):]
+		n += t.countPiece(piece, kind)
+	}
+	return n
+}
+
+// pieceKind is what a piece of pre-split text holds.
+type pieceKind int
+
+const (
+	pieceWord   pieceKind = iota // Letters, with an optional leading space
+	pieceNumber                  // Up to three digits
+	pieceSymbol                  // Punctuation, with an optional leading space
+	pieceSpace                   // Whitespace
+)
+
+// nextPiece returns the first piece of text, cut roughly where BPE
+// tokenizers pre-split text before merging.
+func nextPiece(text string) (string, pieceKind) {
+	r, size := utf8.DecodeRuneInString(text)
+	start := 0
+	if r == ' ' && len(text) > 1 {
+		// A single leading space belongs to the word or symbols after it
+		next, nextSize := utf8.DecodeRuneInString(text[1:])
+		if !unicode.IsSpace(next) && !unicode.IsDigit(next) {
+			start, r, size = 1, next, nextSize
+		}
+	}
+
+	end := start + size
+	switch {
+	case unicode.IsLetter(r) || r == '\'' && start == 0 && contractionLen(text) > 0:
+		if r == '\'' {
+			return text[:contractionLen(text)], pieceWord
+		}
+		for end < len(text) {
+			r, size := utf8.DecodeRuneInString(text[end:])
+			if !unicode.IsLetter(r) && !unicode.IsMark(r) {
+				break
+			}
+			end += size
+		}
+		return text[:end], pieceWord
+	case unicode.IsDigit(r):
+		for digits := 1; end < len(text) && digits < 3; digits++ {
+			r, size := utf8.DecodeRuneInString(text[end:])
+			if !unicode.IsDigit(r) {
+				break
+			}
+			end += size
+		}
+		return text[:end], pieceNumber
+	case unicode.IsSpace(r):
+		for end < len(text) {
+			r, size := utf8.DecodeRuneInString(text[end:])
+			if !unicode.IsSpace(r) {
+				break
+			}
+			end += size
+		}
+		return text[:end], pieceSpace
+	}
+	for end < len(text) {
+		r, size := utf8.DecodeRuneInString(text[end:])
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.IsSpace(r) {
+			break
+		}
+		end += size
+	}
+	return text[:end], pieceSymbol
+}
+
+// contractions are the suffixes BPE tokenizers split off words.
+var contractions = []string{"'s", "'t", "'re", "'ve", "'m", "'ll", "'d"}
+
+// contractionLen returns the length of the contraction text starts with,
+// or 0.
+func contractionLen(text string) int {
+	for _, c := range contractions {
+		if len(text) >= len(c) && strings.EqualFold(text[:len(c)], c) {
+			rest := text[len(c):]
+			if r, _ := utf8.DecodeRuneInString(rest); rest == "" || !unicode.IsLetter(r) {
+				return len(c)
+			}
+		}
+	}
+	return 0
+}
+
+// countPiece estimates the tokens of one piece.
+func (t *Tokenizer) countPiece(piece string, kind pieceKind) int {
+	switch kind {
+	case pieceNumber:
+		return 1
+	case pieceSpace:
+		// Newlines and the indentation after them merge into few tokens
+		spaces := len(strings.TrimLeft(piece, "\r\n"))
+		return 1 + spaces/t.spaceChars
+	case pieceSymbol:
+		n := utf8.RuneCountInString(strings.TrimPrefix(piece, " "))
+		return ceil(float64(n) / t.symbolChars)
+	}
+
+	n := 0
+	for _, part := range splitCase(strings.TrimPrefix(piece, " ")) {
+		n += t.countWord(part)
+	}
+	return n
+}
+
+// countWord estimates the tokens of a word without case changes.
+func (t *Tokenizer) countWord(word string) int {
+	latin, other := 0, 0
+	for _, r := range word {
+		if r < utf8.RuneSelf {
+			latin++
+		} else if unicode.Is(unicode.Han, r) || unicode.Is(unicode.Hiragana, r) || unicode.Is(unicode.Katakana, r) || unicode.Is(unicode.Hangul, r) {
+			other += 2 // About a token per character, sometimes two
+		} else {
+			other++ // Accented and non-Latin letters take about half a token
+		}
+	}
+	n := (other + 1) / 2
+	if latin > 0 {
+		// Common words are a single token; longer ones split into subwords
+		n++
+		if rest := float64(latin) - 2*t.wordChars; rest > 0 {
+			n += ceil(rest / t.wordChars)
+		}
+	}
+	return max(n, 1)
+}
+
+// splitCase splits a word at camelCase boundaries, as in
+// parseHTTPRequest: parse, HTTP, Request. Tokenizers rarely merge across
+// them.
+func splitCase(word string) []string {
+	var parts []string
+	start := 0
+	runes := []rune(word)
+	offset := 0
+	for i, r := range runes {
+		if i > 0 && unicode.IsUpper(r) {
+			prev := runes[i-1]
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if unicode.IsLower(prev) || unicode.IsUpper(prev) && nextLower {
+				parts = append(parts, word[start:offset])
+				start = offset
+			}
+		}
+		offset += utf8.RuneLen(r)
+	}
+	return append(parts, word[start:])
+}
+
+// ceil returns f rounded up, and at least 1.
+func ceil(f float64) int {
+	n := int(f)
+	if float64(n) < f {
+		n++
+	}
+	return max(n, 1)
+}
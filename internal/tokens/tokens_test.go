@@ -0,0 +1,84 @@
+package tokens
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCount(t *testing.T) {
+	// Counts of tiktoken with the same encodings
+	tests := []struct {
+		text          string
+		cl100k, o200k int
+	}{
+		{"Hello world", 2, 2},
+		{"tiktoken is great!", 6, 6},
+		{"antidisestablishmentarianism", 6, 6},
+		{"お誕生日おめでとう", 9, 8},
+		{"if err != nil {\n\treturn fmt.Errorf(\"x: %w\", err)\n}\n", 17, 17},
+		{"<|endoftext|>", 7, 7}, // Special tokens are counted as text
+		{"", 0, 0},
+	}
+	for _, tt := range tests {
+		if got := Cl100k.Count(tt.text); got != tt.cl100k {
+			t.Errorf("Cl100k.Count(%q) = %d, want %d", tt.text, got, tt.cl100k)
+		}
+		if got := O200k.Count(tt.text); got != tt.o200k {
+			t.Errorf("O200k.Count(%q) = %d, want %d", tt.text, got, tt.o200k)
+		}
+	}
+	if !O200k.Exact() || !Cl100k.Exact() || Claude.Exact() {
+		t.Errorf("Exact() = o200k %v, cl100k %v, claude %v; want only OpenAI's", O200k.Exact(), Cl100k.Exact(), Claude.Exact())
+	}
+}
+
+func TestEstimate(t *testing.T) {
+	// Close to the real cl100k counts for plain text
+	for text, want := range map[string]int{
+		"Hello world": 2,
+		"The quick brown fox jumps over the lazy dog.": 10,
+		"I can't believe it's not butter!":             9,
+		"1234567":                                      3,
+		"":                                             0,
+	} {
+		if got := Cl100k.estimate(text); got != want {
+			t.Errorf("estimate(%q) = %d, want %d", text, got, want)
+		}
+	}
+
+	// Code splits into more tokens than prose of the same length
+	code := strings.Repeat("if (err != nil) { return fmt.Errorf(\"x: %w\", err); }\n", 20)
+	prose := strings.Repeat("the team reviewed every change before it was merged and deployed.\n", 20)[:len(code)]
+	if c, p := Count(code), Count(prose); c <= p {
+		t.Errorf("Count(code) = %d, Count(prose) = %d, want code higher", c, p)
+	}
+
+	// Deep indentation costs little
+	if got := Count("\n" + strings.Repeat(" ", 32) + "x"); got > 6 {
+		t.Errorf("Count(indented) = %d, want few tokens", got)
+	}
+}
+
+func TestForModel(t *testing.T) {
+	for model, want := range map[string]*Tokenizer{
+		"gpt-4o-mini":         O200k,
+		"openai/o3":           O200k,
+		"gpt-5":               O200k,
+		"gpt-4-turbo":         Cl100k,
+		"sonnet":              Claude,
+		"anthropic/claude-3":  Claude,
+		"":                    Claude,
+		"ollama/llama3.1:70b": Claude,
+	} {
+		if got := ForModel(model); got != want {
+			t.Errorf("ForModel(%q) = %s, want %s", model, got.Name, want.Name)
+		}
+	}
+}
+
+func TestSplitCase(t *testing.T) {
+	got := strings.Join(splitCase("parseHTTPRequest"), " ")
+	if got != "parse HTTP Request" {
+		t.Errorf("splitCase() = %q", got)
+	}
+}
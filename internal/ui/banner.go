@@ -5,6 +5,7 @@ import (
 	"os"
 	"os/user"
 	"path/filepath"
+	"time"
 )
 
 // PrintBanner prints the welcome banner with ASCII art
@@ -100,12 +101,14 @@ func PrintExecutionPlan(tasks []TaskInfo) {
 			)
 		}
 
-		// Agent info
-		fmt.Printf("  %s│%s  %s◇%s %s%s%s\n",
-			Orange, Reset,
-			Dim, Reset,
-			Orange, task.Agent, Reset,
-		)
+		// Agent info; built-in steps have none
+		if task.Agent != "" {
+			fmt.Printf("  %s│%s  %s◇%s %s%s%s\n",
+				Orange, Reset,
+				Dim, Reset,
+				Orange, task.Agent, Reset,
+			)
+		}
 
 		// Tool and model
 		toolInfo := task.Tool
@@ -142,11 +145,18 @@ func PrintTaskStart(index, total int, name, agent, tool, model string) {
 		Dim, index, total, Reset,
 		Bold+Orange, name, Reset,
 	)
-	fmt.Printf("%s│%s  %s%s%s %s· %s%s%s\n",
-		Orange, Reset,
-		Orange, agent, Reset,
-		Dim, tool, modelStr, Reset,
-	)
+	if agent == "" {
+		fmt.Printf("%s│%s  %s%s%s%s\n",
+			Orange, Reset,
+			Dim, tool, modelStr, Reset,
+		)
+	} else {
+		fmt.Printf("%s│%s  %s%s%s %s· %s%s%s\n",
+			Orange, Reset,
+			Orange, agent, Reset,
+			Dim, tool, modelStr, Reset,
+		)
+	}
 }
 
 // PrintTaskStatus prints task status
@@ -176,6 +186,141 @@ func PrintTaskStatusWithTokens(status string, success bool, duration string, inp
 	fmt.Printf("%s└─%s %s\n", Orange, Reset, statusStr)
 }
 
+// PrintVerifyStatus prints the outcome of a task's verify command
+func PrintVerifyStatus(command string, success bool, exitCode int) {
+	if len(command) > 50 {
+		command = command[:47] + "..."
+	}
+	if success {
+		fmt.Printf("%s│%s  %s◇ verify:%s %s %s✓%s\n", Orange, Reset, Dim, Reset, command, Green, Reset)
+	} else {
+		fmt.Printf("%s│%s  %s◇ verify:%s %s %s✗ exit %d%s\n", Orange, Reset, Dim, Reset, command, Red, exitCode, Reset)
+	}
+}
+
+// PrintExpectStatus prints the result of checking a task's output against
+// its expected format, with the first problem found if it didn't match
+func PrintExpectStatus(format string, valid bool, problem string) {
+	if valid {
+		fmt.Printf("%s│%s  %s◇ expect:%s %s %s✓%s\n", Orange, Reset, Dim, Reset, format, Green, Reset)
+		return
+	}
+	if len(problem) > 60 {
+		problem = problem[:57] + "..."
+	}
+	fmt.Printf("%s│%s  %s◇ expect:%s %s %s✗ %s%s\n", Orange, Reset, Dim, Reset, format, Red, problem, Reset)
+}
+
+// PrintRouted prints that a task was moved to an interchangeable agent
+func PrintRouted(from, to, tool, model string) {
+	if model != "" {
+		tool += " · " + model
+	}
+	fmt.Printf("%s│%s  %s◇ routed:%s %s busy → %s%s%s %s(%s)%s\n",
+		Orange, Reset, Dim, Reset, from, Orange, to, Reset, Dim, tool, Reset)
+}
+
+// PrintBackoff prints that a task is waiting out a shared rate-limit cooldown
+func PrintBackoff(tool, model string, remaining time.Duration) {
+	target := tool
+	if model != "" {
+		target += "/" + model
+	}
+	fmt.Printf("%s│%s  %s◇ rate limited:%s %s%s cooling down %s%s\n",
+		Orange, Reset, Dim, Reset, Yellow, target, remaining.Round(time.Second), Reset)
+}
+
+// PrintLoadDeferred prints that a task is waiting for system load to drop
+func PrintLoadDeferred(task string, cpu, memory float64) {
+	fmt.Printf("  %s◇ system busy (CPU %.0f%%, memory %.0f%%):%s %s%s%s %swaits for running tasks to ease off%s\n",
+		Dim, cpu, memory, Reset, Yellow, task, Reset, Dim, Reset)
+}
+
+// PrintDiskLow prints that a task is waiting for disk space to be freed
+func PrintDiskLow(task, reason string, wait time.Duration) {
+	fmt.Printf("  %s◇ disk low:%s %s%s%s; %s%s waits up to %s for space%s\n",
+		Dim, Reset, Yellow, reason, Reset, Dim, task, wait.Round(time.Second), Reset)
+}
+
+// PrintDegraded prints a notice that a task is retrying with a reduced prompt
+func PrintDegraded(strategy string, originalLen, reducedLen int) {
+	fmt.Printf("%s│%s  %s◇ context overflow:%s %sretrying with %s prompt (%s → %s chars)%s\n",
+		Orange, Reset, Dim, Reset, Yellow, strategy+"d",
+		FormatTokenCount(originalLen), FormatTokenCount(reducedLen), Reset)
+}
+
+// PrintRetry prints that a failed task is re-run after a pause
+func PrintRetry(attempt, attempts int, reason string, wait time.Duration) {
+	fmt.Printf("%s│%s  %s◇ attempt %d/%d failed:%s %s%s; retrying in %s%s\n",
+		Orange, Reset, Dim, attempt, attempts, Reset, Yellow, reason, wait.Round(time.Second), Reset)
+}
+
+// PrintErrorCategory prints the classified cause of a task failure and its
+// error code
+func PrintErrorCategory(category, code, hint string) {
+	fmt.Printf("%s│%s  %s◇ error:%s %s%s%s %s[%s] — %s%s\n", Orange, Reset, Dim, Reset, Red, category, Reset, Dim, code, hint, Reset)
+}
+
+// PrintCommitStatus prints the commit (and pull request) created for a task
+func PrintCommitStatus(branch, sha, prURL string) {
+	if len(sha) > 7 {
+		sha = sha[:7]
+	}
+	fmt.Printf("%s│%s  %s◇ commit:%s %s %son %s%s\n", Orange, Reset, Dim, Reset, sha, Dim, branch, Reset)
+	if prURL != "" {
+		fmt.Printf("%s│%s  %s◇ pr:%s %s\n", Orange, Reset, Dim, Reset, prURL)
+	}
+}
+
+// Heartbeat describes a long-running task for PrintHeartbeat
+type Heartbeat struct {
+	Task     string
+	Elapsed  time.Duration
+	Bytes    int64
+	Tokens   int
+	Activity string // Current tool call, if known
+	Edits    int
+	LastLine string
+}
+
+// PrintHeartbeat prints that a long-running task is still making progress
+func PrintHeartbeat(h Heartbeat) {
+	usage := FormatBytes(h.Bytes)
+	if h.Tokens > 0 {
+		usage += " · " + FormatTokenCount(h.Tokens) + " tokens"
+	}
+	if h.Edits > 0 {
+		usage += fmt.Sprintf(" · %d edits", h.Edits)
+	}
+	fmt.Printf("%s│%s  %s◇ still running:%s %s %s%s · %s%s\n",
+		Orange, Reset, Dim, Reset, h.Task, Dim, h.Elapsed.Round(time.Second), usage, Reset)
+	if h.Activity != "" {
+		fmt.Printf("%s│%s    %sdoing: %s%s\n", Orange, Reset, Dim, h.Activity, Reset)
+	} else if h.LastLine != "" {
+		fmt.Printf("%s│%s    %slast: %s%s\n", Orange, Reset, Dim, h.LastLine, Reset)
+	}
+}
+
+// PrintBudgetExceeded prints that a task is being stopped by a token budget
+func PrintBudgetExceeded(scope string, used, limit int) {
+	fmt.Printf("%s│%s  %s◇ budget:%s %s%s token limit reached (%s / %s), stopping%s\n",
+		Orange, Reset, Dim, Reset, Red, scope, FormatTokenCount(used), FormatTokenCount(limit), Reset)
+}
+
+// FormatBytes formats a byte count with a binary unit suffix
+func FormatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
 // FormatTokenCount formats a token count with commas for readability
 func FormatTokenCount(n int) string {
 	if n < 1000 {
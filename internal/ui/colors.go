@@ -100,8 +100,21 @@ func Success(format string, args ...interface{}) {
 	fmt.Printf(GreenText("✓ ")+format+"\n", args...)
 }
 
+// quiet sends errors to stderr (see SetQuiet)
+var quiet bool
+
+// SetQuiet makes Error print to stderr instead of stdout, so errors still
+// show while stdout is silenced for --quiet and --summary.
+func SetQuiet(q bool) {
+	quiet = q
+}
+
 // Error prints an error message
 func Error(format string, args ...interface{}) {
+	if quiet {
+		fmt.Fprintf(os.Stderr, RedText("✗ ")+format+"\n", args...)
+		return
+	}
 	fmt.Printf(RedText("✗ ")+format+"\n", args...)
 }
 
@@ -0,0 +1,14 @@
+//go:build !windows
+
+package ui
+
+import "os"
+
+// interruptSelf delivers an interrupt to this process, as Ctrl+C would
+// outside raw mode.
+func interruptSelf() {
+	p, _ := os.FindProcess(os.Getpid())
+	if p != nil {
+		_ = p.Signal(os.Interrupt)
+	}
+}
@@ -0,0 +1,12 @@
+//go:build windows
+
+package ui
+
+import "golang.org/x/sys/windows"
+
+// interruptSelf delivers an interrupt to this process, as Ctrl+C would
+// outside raw mode. Windows can't signal a process directly, so a Ctrl+C
+// event is sent to the console instead.
+func interruptSelf() {
+	_ = windows.GenerateConsoleCtrlEvent(windows.CTRL_C_EVENT, 0)
+}
@@ -138,11 +138,7 @@ func (t *TerminalController) listenKeys() {
 
 			// Ctrl+C is ASCII 3 - propagate interrupt
 			if buf[0] == 3 {
-				// Send SIGINT to self
-				p, _ := os.FindProcess(os.Getpid())
-				if p != nil {
-					_ = p.Signal(os.Interrupt)
-				}
+				interruptSelf()
 			}
 		}
 	}
@@ -12,6 +12,7 @@ const (
 	EventTaskStart    = "task_start"
 	EventTaskComplete = "task_complete"
 	EventTaskFailed   = "task_failed"
+	EventTaskProgress = "task_progress"
 )
 
 // Event represents a webhook event payload.
@@ -33,6 +34,14 @@ type TaskEvent struct {
 	Duration string `json:"duration,omitempty"`
 	Success  bool   `json:"success"`
 	Error    string `json:"error,omitempty"`
+
+	// Progress of a running task (task_progress only)
+	Elapsed  string `json:"elapsed,omitempty"`
+	Bytes    int64  `json:"bytes,omitempty"`
+	Tokens   int    `json:"tokens,omitempty"`
+	Activity string `json:"activity,omitempty"`
+	Edits    int    `json:"edits,omitempty"`
+	LastLine string `json:"last_line,omitempty"`
 }
 
 // RunEvent contains run-specific event data.
@@ -119,3 +128,15 @@ func NewTaskFailedEvent(runID, project, taskName, agent, tool, model, duration,
 		},
 	}
 }
+
+// NewTaskProgressEvent creates a task_progress event for a long-running task.
+// task carries the task's identity and its progress fields.
+func NewTaskProgressEvent(runID, project string, task TaskEvent) Event {
+	return Event{
+		Type:      EventTaskProgress,
+		Timestamp: time.Now(),
+		RunID:     runID,
+		Project:   project,
+		Task:      &task,
+	}
+}
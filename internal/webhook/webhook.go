@@ -10,6 +10,7 @@ import (
 	"time"
 
 	"github.com/adityaraj/agentflow/internal/config"
+	"github.com/adityaraj/agentflow/internal/network"
 )
 
 // Manager handles sending webhook notifications.
@@ -22,10 +23,8 @@ type Manager struct {
 // NewManager creates a new webhook manager.
 func NewManager(hooks []config.WebhookConfig) *Manager {
 	return &Manager{
-		hooks: hooks,
-		client: &http.Client{
-			Timeout: 10 * time.Second,
-		},
+		hooks:  hooks,
+		client: network.Client(10 * time.Second),
 	}
 }
 
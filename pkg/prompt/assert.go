@@ -0,0 +1,169 @@
+package prompt
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Suite is a file of template assertions, run by `cortex template test`.
+//
+// Example:
+//
+//	tests:
+//	  - name: review includes the analysis
+//	    task: review
+//	    vars:
+//	      outputs.analyze: "Found 3 issues"
+//	    contains: ["Found 3 issues"]
+type Suite struct {
+	Path  string `yaml:"-"`
+	Tests []Case `yaml:"tests"`
+}
+
+// Case renders one template with vars and checks the result. The template
+// is given inline, as a file (relative to the suite), or as a Cortexfile task.
+type Case struct {
+	Name          string            `yaml:"name"`
+	Template      string            `yaml:"template,omitempty"`
+	TemplateFile  string            `yaml:"template_file,omitempty"`
+	Task          string            `yaml:"task,omitempty"`
+	Vars          map[string]string `yaml:"vars,omitempty"`
+	Expect        *string           `yaml:"expect,omitempty"`
+	Contains      []string          `yaml:"contains,omitempty"`
+	NotContains   []string          `yaml:"not_contains,omitempty"`
+	AllowUnfilled bool              `yaml:"allow_unfilled,omitempty"` // Don't fail on leftover placeholders
+}
+
+// Result is the outcome of one Case.
+type Result struct {
+	Name     string
+	Rendered string
+	Failures []string
+}
+
+// Passed reports whether the case met all its expectations.
+func (r Result) Passed() bool {
+	return len(r.Failures) == 0
+}
+
+// TaskResolver returns the template of a Cortexfile task and the variables
+// it sets (such as task.name).
+type TaskResolver func(task string) (string, map[string]string, error)
+
+// LoadSuite reads and checks an assertion file.
+func LoadSuite(path string) (*Suite, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	suite := &Suite{Path: path}
+	if err := yaml.Unmarshal(data, suite); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	if len(suite.Tests) == 0 {
+		return nil, fmt.Errorf("%s: no tests defined", path)
+	}
+
+	for i, c := range suite.Tests {
+		sources := 0
+		for _, s := range []string{c.Template, c.TemplateFile, c.Task} {
+			if s != "" {
+				sources++
+			}
+		}
+		if sources != 1 {
+			return nil, fmt.Errorf("%s: test %d: set exactly one of template, template_file, or task", path, i+1)
+		}
+		if c.Expect == nil && len(c.Contains) == 0 && len(c.NotContains) == 0 {
+			return nil, fmt.Errorf("%s: test %d: nothing to check (set expect, contains, or not_contains)", path, i+1)
+		}
+		if c.Name == "" {
+			suite.Tests[i].Name = fmt.Sprintf("test %d", i+1)
+		}
+	}
+	return suite, nil
+}
+
+// Run renders and checks every case. resolve is only called for cases
+// that name a task; it may be nil if none do.
+func (s *Suite) Run(resolve TaskResolver) []Result {
+	results := make([]Result, 0, len(s.Tests))
+	for _, c := range s.Tests {
+		results = append(results, s.run(c, resolve))
+	}
+	return results
+}
+
+func (s *Suite) run(c Case, resolve TaskResolver) Result {
+	result := Result{Name: c.Name}
+	vars := make(map[string]string)
+
+	tpl := c.Template
+	switch {
+	case c.TemplateFile != "":
+		path := c.TemplateFile
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(filepath.Dir(s.Path), path)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			result.Failures = append(result.Failures, fmt.Sprintf("cannot read template: %v", err))
+			return result
+		}
+		tpl = string(data)
+	case c.Task != "":
+		if resolve == nil {
+			result.Failures = append(result.Failures, "task templates need a Cortexfile")
+			return result
+		}
+		taskTpl, taskVars, err := resolve(c.Task)
+		if err != nil {
+			result.Failures = append(result.Failures, err.Error())
+			return result
+		}
+		tpl = taskTpl
+		for k, v := range taskVars {
+			vars[k] = v
+		}
+	}
+	for k, v := range c.Vars {
+		vars[k] = v
+	}
+
+	result.Rendered = Render(tpl, vars)
+	result.Failures = c.Check(result.Rendered)
+	return result
+}
+
+// Check returns a description of each expectation that rendered fails.
+// Trailing newlines are ignored when comparing with expect.
+func (c Case) Check(rendered string) []string {
+	var failures []string
+	if c.Expect != nil {
+		got, want := strings.TrimRight(rendered, "\n"), strings.TrimRight(*c.Expect, "\n")
+		if got != want {
+			failures = append(failures, fmt.Sprintf("output differs from expect\n--- expect\n%s\n--- got\n%s", want, got))
+		}
+	}
+	for _, s := range c.Contains {
+		if !strings.Contains(rendered, s) {
+			failures = append(failures, fmt.Sprintf("missing %q", s))
+		}
+	}
+	for _, s := range c.NotContains {
+		if strings.Contains(rendered, s) {
+			failures = append(failures, fmt.Sprintf("unexpectedly contains %q", s))
+		}
+	}
+	if !c.AllowUnfilled {
+		if unfilled := Placeholders(rendered); len(unfilled) > 0 {
+			failures = append(failures, "unfilled placeholders: "+strings.Join(unfilled, ", "))
+		}
+	}
+	return failures
+}
@@ -0,0 +1,84 @@
+package prompt
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSuiteRun(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "fix.md"), []byte("Fix: {{outputs.review}}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	suiteYAML := `tests:
+  - name: inline
+    template: "Hello {{name}}"
+    vars: {name: world}
+    expect: "Hello world"
+  - name: file
+    template_file: fix.md
+    vars: {outputs.review: "2 bugs"}
+    expect: "Fix: 2 bugs"
+  - name: task
+    task: review
+    contains: ["Review review"]
+    not_contains: ["TODO"]
+  - name: unfilled
+    template: "{{memory}}"
+    contains: ["memory"]
+  - name: allowed
+    template: "{{memory}}"
+    contains: ["memory"]
+    allow_unfilled: true
+`
+	path := filepath.Join(dir, "p.template-test.yml")
+	if err := os.WriteFile(path, []byte(suiteYAML), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	suite, err := LoadSuite(path)
+	if err != nil {
+		t.Fatalf("LoadSuite() error = %v", err)
+	}
+	resolve := func(task string) (string, map[string]string, error) {
+		return "Review {{task.name}}", map[string]string{"task.name": task}, nil
+	}
+	results := suite.Run(resolve)
+
+	want := map[string]bool{"inline": true, "file": true, "task": true, "unfilled": false, "allowed": true}
+	for _, r := range results {
+		if r.Passed() != want[r.Name] {
+			t.Errorf("%s: Passed() = %v, want %v (failures: %v)", r.Name, r.Passed(), want[r.Name], r.Failures)
+		}
+	}
+	if len(results) != len(want) {
+		t.Errorf("got %d results, want %d", len(results), len(want))
+	}
+}
+
+func TestLoadSuite_Invalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		yaml    string
+		wantErr string
+	}{
+		{"empty", "tests: []", "no tests"},
+		{"no source", "tests:\n  - expect: x", "exactly one of"},
+		{"two sources", "tests:\n  - {template: a, task: b, expect: x}", "exactly one of"},
+		{"no checks", "tests:\n  - template: a", "nothing to check"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "t.yml")
+			if err := os.WriteFile(path, []byte(tt.yaml), 0644); err != nil {
+				t.Fatal(err)
+			}
+			_, err := LoadSuite(path)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("LoadSuite() error = %v, want containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
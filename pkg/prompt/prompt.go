@@ -0,0 +1,17 @@
+// Package prompt exposes Cortex's prompt template engine, so tools and tests
+// outside this module can render templates exactly as `cortex run` does.
+package prompt
+
+import "github.com/adityaraj/agentflow/internal/config"
+
+// Render replaces each {{name}} placeholder in tpl that has a value in vars,
+// keyed by name without braces (e.g. "outputs.analyze", "memory").
+// Placeholders without a value are left as-is.
+func Render(tpl string, vars map[string]string) string {
+	return config.RenderTemplate(tpl, vars)
+}
+
+// Placeholders returns the distinct names of the {{name}} placeholders in tpl.
+func Placeholders(tpl string) []string {
+	return config.Placeholders(tpl)
+}